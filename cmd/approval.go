@@ -0,0 +1,147 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// errApprovalTokenRequired is returned when a deploy needs an approval token that
+// cannot be asked for
+var errApprovalTokenRequired = errors.New("this deploy needs an approval token; pass --approval-token")
+
+// secretTimeout bounds how long fetching the approval token may take
+const secretTimeout = time.Minute
+
+// approveDeploy gets approval for deploying the artifacts when --environment names a
+// protected environment: from the approval webhook, then from a second person's
+// confirmation token, whichever are configured. It returns an error, which wraps
+// slarty.ErrApprovalDenied when the deploy was refused, unless the deploy may go ahead.
+func approveDeploy(cmd *cobra.Command, opts *doDeploysOptions, artifactConfig *slarty.ArtifactsConfig, artifacts []slarty.ArtifactConfig, artifactNames map[string]string) error {
+	approvals := artifactConfig.Approvals
+	protected, err := approvals.IsProtected(opts.environment)
+	if err != nil || !protected {
+		return err
+	}
+	fmt.Printf("%s is a protected environment, so this deploy needs approval\n", opts.environment)
+
+	record := slarty.AuditRecord{Action: slarty.AuditApprove, Command: "do-deploys", Location: opts.environment, Result: slarty.AuditSucceeded}
+	err = requestApprovals(cmd, opts, artifactConfig, artifacts, artifactNames, &record)
+	if err != nil {
+		record.Result, record.Error = slarty.AuditFailed, err.Error()
+	}
+	opts.root.audit(artifactConfig, record)
+	return err
+}
+
+// requestApprovals asks for each configured kind of approval in turn, noting who
+// approved in the audit record
+func requestApprovals(cmd *cobra.Command, opts *doDeploysOptions, artifactConfig *slarty.ArtifactsConfig, artifacts []slarty.ArtifactConfig, artifactNames map[string]string, record *slarty.AuditRecord) error {
+	approvals := artifactConfig.Approvals
+	if approvals.WebhookURL != "" {
+		request := slarty.ApprovalRequest{
+			Application: artifactConfig.Application,
+			Environment: opts.environment,
+			User:        slarty.AuditUser(),
+			RequestedAt: time.Now().UTC(),
+		}
+		request.Host, _ = os.Hostname()
+		for _, artifact := range artifacts {
+			artifactName := artifactNames[artifact.Name]
+			request.Artifacts = append(request.Artifacts, slarty.ApprovalArtifact{
+				Artifact:     artifact.Name,
+				ArtifactName: artifactName,
+				Hash:         slarty.HashFromArtifactName(artifact, artifactName, artifactConfig),
+			})
+		}
+
+		fmt.Println(" - Waiting for the approval webhook")
+		approver, err := slarty.RequestApproval(approvals, request, artifactConfig.Offline)
+		if err != nil {
+			return err
+		}
+		if approver != "" {
+			fmt.Printf(" - Approved by %s\n", approver)
+			record.Approver = approver
+		} else {
+			fmt.Println(" - Approved by the webhook")
+		}
+	}
+
+	if approvals.Token != "" {
+		options := artifactConfig.Repository.Options
+		ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+		defer cancel()
+		expected, err := approvals.ResolveToken(ctx, plainOption(options.Region), plainOption(options.Profile), artifactConfig.Offline)
+		if err != nil {
+			return err
+		}
+
+		given := opts.approvalToken
+		if given == "" {
+			if given, err = askApprovalToken(cmd, opts.root.noPrompt); err != nil {
+				return err
+			}
+		}
+		if err := slarty.CheckApprovalToken(expected, given); err != nil {
+			return err
+		}
+		fmt.Println(" - Approval token accepted")
+	}
+
+	return nil
+}
+
+// plainOption returns a repository option, or nothing when it is a secret_ref, which
+// would need resolving first
+func plainOption(value string) string {
+	if slarty.IsSecretRef(value) {
+		return ""
+	}
+	return value
+}
+
+// askApprovalToken asks for the confirmation token. Like confirm, it fails instead of
+// asking when nobody can answer, as with noPrompt.
+func askApprovalToken(cmd *cobra.Command, noPrompt bool) (string, error) {
+	in := cmd.InOrStdin()
+	if f, ok := in.(*os.File); noPrompt || (ok && !isTerminal(f)) {
+		return "", errApprovalTokenRequired
+	}
+
+	fmt.Fprint(cmd.OutOrStdout(), "Approval token from a second person: ")
+	token, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(token), nil
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+func TestApproveDeploy(t *testing.T) {
+	root := t.TempDir()
+	config := &slarty.ArtifactsConfig{
+		Application:   "shop",
+		RootDirectory: root,
+		Audit:         slarty.AuditConfig{File: "audit.jsonl"},
+		Approvals:     slarty.ApprovalConfig{Protected: []string{"prod*"}, Token: "s3cret"},
+	}
+	artifact := slarty.ArtifactConfig{Name: "api", ArtifactPrefix: "api"}
+	artifacts := []slarty.ArtifactConfig{artifact}
+	names := map[string]string{"api": "api-abc.tar.gz"}
+
+	opts := &doDeploysOptions{root: &rootOptions{}}
+	approve := func(environment, token, input string) (string, error) {
+		opts.environment, opts.approvalToken = environment, token
+		cmd := &cobra.Command{Use: "test"}
+		cmd.SetIn(strings.NewReader(input))
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		err := approveDeploy(cmd, opts, config, artifacts, names)
+		return out.String(), err
+	}
+
+	// An environment that is not protected goes ahead without asking
+	if prompt, err := approve("staging", "", ""); err != nil || prompt != "" {
+		t.Errorf("Expected staging to need no approval, got %q (%v)", prompt, err)
+	}
+	if _, err := approve("", "", ""); err != nil {
+		t.Errorf("Expected no environment to need no approval, got %v", err)
+	}
+
+	if _, err := approve("production", "s3cret", ""); err != nil {
+		t.Errorf("Expected the token from the flag to approve the deploy, got %v", err)
+	}
+	if _, err := approve("production", "guess", ""); !errors.Is(err, slarty.ErrApprovalDenied) {
+		t.Errorf("Expected a wrong token to deny the deploy, got %v", err)
+	}
+	prompt, err := approve("prod-eu", "", "s3cret\n")
+	if err != nil || !strings.Contains(prompt, "Approval token") {
+		t.Errorf("Expected to be asked for the token, got %q (%v)", prompt, err)
+	}
+
+	opts.root.noPrompt = true
+	if _, err := approve("production", "", "s3cret\n"); !errors.Is(err, errApprovalTokenRequired) {
+		t.Errorf("Expected a token to be required when nobody can be asked, got %v", err)
+	}
+
+	// Every decision is in the audit log
+	records, err := slarty.ReadAuditLog(filepath.Join(root, "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadAuditLog failed: %v", err)
+	}
+	var results []string
+	for _, record := range records {
+		if record.Action != slarty.AuditApprove {
+			t.Errorf("Expected only approvals, got %+v", record)
+		}
+		results = append(results, record.Location+" "+record.Result)
+	}
+	want := "production succeeded, production failed, prod-eu succeeded, production failed"
+	if strings.Join(results, ", ") != want {
+		t.Errorf("Expected audit records %q, got %q", want, strings.Join(results, ", "))
+	}
+}
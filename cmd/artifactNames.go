@@ -24,7 +24,6 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/dstockto/slarty/slarty"
 	"github.com/spf13/cobra"
 	"log"
 	"os"
@@ -32,36 +31,69 @@ import (
 	"text/tabwriter"
 )
 
-// artifactNamesCmd represents the artifactNames command
-var artifactNamesCmd = &cobra.Command{
-	Use:   "artifact-names [--config=...]",
-	Short: "List the artifacts defined in a config file",
-	Long: `Lists the names of the artifacts defined in either the artifacts.json in the current 
+// artifactNamesOptions are the flags of the artifact-names command
+type artifactNamesOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	json      bool
+	output    string
+	sort      string
+	variant   string
+	dirty     bool
+}
+
+// newArtifactNamesCmd creates the artifact-names command
+func newArtifactNamesCmd(root *rootOptions) *cobra.Command {
+	opts := &artifactNamesOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "artifact-names [--config=...]",
+		Short: "List the artifacts defined in a config file",
+		Long: `Lists the names of the artifacts defined in either the artifacts.json in the current 
 directory (default) or from another artifacts.json specified with the --config/-c flag.`,
-	Run: runArtifactNames,
+		Run: func(cmd *cobra.Command, args []string) {
+			runArtifactNames(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "", root.completeArtifactNames)
+	cmd.Flags().BoolVar(&opts.json, "json", false, "output results as JSON")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", outputTable, "output format: table, json, csv or markdown")
+	cmd.Flags().StringVar(&opts.sort, "sort", sortByConfig, "order rows by config (as in artifacts.json) or name")
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// cmd.PersistentFlags().String("foo", "", "A help for foo")
+
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// cmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+	cmd.Flags().BoolVar(&opts.dirty, "dirty", false, "name artifacts after the working tree, including unstaged changes, marking changed ones -dirty")
+
+	return cmd
 }
 
-func runArtifactNames(cmd *cobra.Command, args []string) {
-	artifactConfig, err := slarty.ReadArtifactsJson(artifactsJson)
+func runArtifactNames(cmd *cobra.Command, args []string, opts *artifactNamesOptions) {
+	artifactConfig, err := opts.root.readConfig()
 	if err != nil {
 		log.Fatalln(err)
 	}
+	if err := artifactConfig.ApplyVariant(opts.variant); err != nil {
+		log.Fatalln(err)
+	}
+	artifactConfig.HashWorkingTree = opts.dirty
 
+	format := outputFormatFromFlags(opts.json, opts.output)
 	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
 
 	artifactNames := make(map[string]string)
 	var longestName int
 	var longestFilename int
 
-	var filters []string
-	if filter != "" {
-		filters = strings.Split(filter, ",")
-	}
-
-	artifacts := artifactConfig.GetByArtifactsByNameWithFilter(filters)
+	artifacts := sortedArtifacts(artifactConfig.SelectArtifacts(opts.selection.selection()), opts.sort)
 
 	for _, artifact := range artifacts {
-		filename, err := slarty.GetArtifactName(artifact.Name, artifactConfig)
+		filename, err := opts.root.artifactName(artifact.Name, artifactConfig)
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -75,7 +107,7 @@ func runArtifactNames(cmd *cobra.Command, args []string) {
 		artifactNames[artifact.Name] = filename
 	}
 
-	if jsonOutput {
+	if format == outputJSON {
 		type artifactNameEntry struct {
 			Application  string `json:"application"`
 			ArtifactName string `json:"artifact_name"`
@@ -95,6 +127,17 @@ func runArtifactNames(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if format == outputCSV || format == outputMarkdown {
+		rows := make([][]string, 0, len(artifacts))
+		for _, artifact := range artifacts {
+			rows = append(rows, []string{artifact.Name, artifactNames[artifact.Name]})
+		}
+		if err := writeRows(os.Stdout, format, []string{"Application", "Artifact Name"}, rows); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
 	if longestName == 0 {
 		fmt.Println("No artifacts found")
 		return
@@ -114,18 +157,3 @@ func runArtifactNames(cmd *cobra.Command, args []string) {
 
 	w.Flush()
 }
-
-func init() {
-	rootCmd.AddCommand(artifactNamesCmd)
-
-	// Here you will define your flags and configuration settings.
-	artifactNamesCmd.Flags().StringVarP(&filter, "filter", "f", "", "-f \"application1,application2\"")
-	artifactNamesCmd.Flags().BoolVar(&jsonOutput, "json", false, "output results as JSON")
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// artifactNamesCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// artifactNamesCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-}
@@ -15,26 +15,28 @@ import (
 
 func TestArtifactNamesCommand(t *testing.T) {
 	// Test that the artifact-names command is properly initialized
-	if artifactNamesCmd.Use != "artifact-names [--config=...]" {
-		t.Errorf("Expected artifact-names command Use to be 'artifact-names [--config=...]', got '%s'", artifactNamesCmd.Use)
+	cmd := newArtifactNamesCmd(&rootOptions{})
+	if cmd.Use != "artifact-names [--config=...]" {
+		t.Errorf("Expected artifact-names command Use to be 'artifact-names [--config=...]', got '%s'", cmd.Use)
 	}
 
-	if artifactNamesCmd.Short == "" {
+	if cmd.Short == "" {
 		t.Error("artifact-names command Short description should not be empty")
 	}
 
-	if artifactNamesCmd.Long == "" {
+	if cmd.Long == "" {
 		t.Error("artifact-names command Long description should not be empty")
 	}
 
-	if artifactNamesCmd.Run == nil {
+	if cmd.Run == nil {
 		t.Error("artifact-names command Run function should not be nil")
 	}
 }
 
 func TestArtifactNamesCommandFlags(t *testing.T) {
 	// Test that the artifact-names command has the expected flags
-	flags := artifactNamesCmd.Flags()
+	cmd := newArtifactNamesCmd(&rootOptions{})
+	flags := cmd.Flags()
 
 	// Check filter flag
 	if flags.Lookup("filter") == nil {
@@ -45,6 +47,13 @@ func TestArtifactNamesCommandFlags(t *testing.T) {
 	if flags.Lookup("json") == nil {
 		t.Error("artifact-names command should have 'json' flag")
 	}
+
+	// Check tag selection flags
+	for _, name := range []string{"regex", "tag", "exclude-tag", "variant"} {
+		if flags.Lookup(name) == nil {
+			t.Errorf("artifact-names command should have '%s' flag", name)
+		}
+	}
 }
 
 func TestRunArtifactNamesJSON(t *testing.T) {
@@ -105,16 +114,7 @@ func TestRunArtifactNamesJSON(t *testing.T) {
 	runGit("add", ".")
 	runGit("commit", "-m", "Initial commit")
 
-	oldArtifactsJson := artifactsJson
-	defer func() { artifactsJson = oldArtifactsJson }()
-	artifactsJson = configPath
-
-	oldFilter := filter
-	defer func() { filter = oldFilter }()
-	filter = ""
-
-	oldJSON := jsonOutput
-	defer func() { jsonOutput = oldJSON }()
+	opts := &artifactNamesOptions{root: &rootOptions{artifactsJson: configPath}}
 
 	type entry struct {
 		Application  string `json:"application"`
@@ -122,14 +122,14 @@ func TestRunArtifactNamesJSON(t *testing.T) {
 	}
 
 	t.Run("ValidJSONInConfigOrder", func(t *testing.T) {
-		jsonOutput = true
-		defer func() { jsonOutput = false }()
+		opts.json = true
+		defer func() { opts.json = false }()
 
 		oldStdout := os.Stdout
 		r, w, _ := os.Pipe()
 		os.Stdout = w
 
-		runArtifactNames(&cobra.Command{Use: "test"}, []string{})
+		runArtifactNames(&cobra.Command{Use: "test"}, []string{}, opts)
 
 		w.Close()
 		os.Stdout = oldStdout
@@ -156,19 +156,19 @@ func TestRunArtifactNamesJSON(t *testing.T) {
 	})
 
 	t.Run("EmptyArrayWhenNoArtifacts", func(t *testing.T) {
-		jsonOutput = true
-		oldF := filter
-		filter = "non-existent"
+		opts.json = true
+		oldF := opts.selection.filter
+		opts.selection.filter = "non-existent"
 		defer func() {
-			jsonOutput = false
-			filter = oldF
+			opts.json = false
+			opts.selection.filter = oldF
 		}()
 
 		oldStdout := os.Stdout
 		r, w, _ := os.Pipe()
 		os.Stdout = w
 
-		runArtifactNames(&cobra.Command{Use: "test"}, []string{})
+		runArtifactNames(&cobra.Command{Use: "test"}, []string{}, opts)
 
 		w.Close()
 		os.Stdout = oldStdout
@@ -252,20 +252,14 @@ func TestRunArtifactNamesPreservesConfigOrder(t *testing.T) {
 	runGit("add", ".")
 	runGit("commit", "-m", "Initial commit")
 
-	oldArtifactsJson := artifactsJson
-	defer func() { artifactsJson = oldArtifactsJson }()
-	artifactsJson = configPath
-
-	oldFilter := filter
-	defer func() { filter = oldFilter }()
-	filter = ""
+	opts := &artifactNamesOptions{root: &rootOptions{artifactsJson: configPath}}
 
 	// Capture stdout
 	oldStdout := os.Stdout
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	runArtifactNames(&cobra.Command{Use: "test"}, []string{})
+	runArtifactNames(&cobra.Command{Use: "test"}, []string{}, opts)
 
 	w.Close()
 	os.Stdout = oldStdout
@@ -386,18 +380,11 @@ func TestRunArtifactNames(t *testing.T) {
 		Short: "Test command",
 	}
 
-	// Save original artifactsJson and restore after test
-	oldArtifactsJson := artifactsJson
-	defer func() { artifactsJson = oldArtifactsJson }()
-	artifactsJson = configPath
-
-	// Save original filter and restore after test
-	oldFilter := filter
-	defer func() { filter = oldFilter }()
+	opts := &artifactNamesOptions{root: &rootOptions{artifactsJson: configPath}}
 
 	// Test with no filter
 	t.Run("NoFilter", func(t *testing.T) {
-		filter = ""
+		opts.selection.filter = ""
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -405,7 +392,7 @@ func TestRunArtifactNames(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runArtifactNames(cmd, []string{})
+		runArtifactNames(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -427,7 +414,7 @@ func TestRunArtifactNames(t *testing.T) {
 
 	// Test with filter
 	t.Run("WithFilter", func(t *testing.T) {
-		filter = "test-artifact-1"
+		opts.selection.filter = "test-artifact-1"
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -435,7 +422,7 @@ func TestRunArtifactNames(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runArtifactNames(cmd, []string{})
+		runArtifactNames(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -457,7 +444,7 @@ func TestRunArtifactNames(t *testing.T) {
 
 	// Test with non-existent filter
 	t.Run("NonExistentFilter", func(t *testing.T) {
-		filter = "non-existent"
+		opts.selection.filter = "non-existent"
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -465,7 +452,7 @@ func TestRunArtifactNames(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runArtifactNames(cmd, []string{})
+		runArtifactNames(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -0,0 +1,142 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+// pendingAudit holds the audit records of the running command until they are stored
+// in the repository, for configurations that keep the audit log there
+type pendingAudit struct {
+	mu      sync.Mutex
+	config  *slarty.ArtifactsConfig
+	records []slarty.AuditRecord
+}
+
+// auditPath returns the audit log file for the application: the configured file,
+// relative to the root directory, or audit.jsonl in the application's cache directory
+func auditPath(artifactConfig *slarty.ArtifactsConfig) (string, error) {
+	if file := artifactConfig.Audit.File; file != "" {
+		if filepath.IsAbs(file) {
+			return file, nil
+		}
+		return filepath.Join(artifactConfig.RootDirectory, file), nil
+	}
+
+	dir, err := applicationCacheDir(artifactConfig)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "audit.jsonl"), nil
+}
+
+// artifactAudit returns the audit record of an action on an artifact, which failed
+// when err is set. Deploys record where the artifact was deployed to.
+func artifactAudit(artifactConfig *slarty.ArtifactsConfig, action, command string, artifact slarty.ArtifactConfig, artifactName string, err error) slarty.AuditRecord {
+	record := slarty.AuditRecord{
+		Action:       action,
+		Command:      command,
+		Artifact:     artifact.Name,
+		ArtifactName: artifactName,
+		Hash:         slarty.HashFromArtifactName(artifact, artifactName, artifactConfig),
+		Result:       slarty.AuditSucceeded,
+	}
+	if action == slarty.AuditDeploy {
+		record.Location = artifact.DeployLocation
+	}
+	if err != nil {
+		record.Result = slarty.AuditFailed
+		record.Error = err.Error()
+	}
+	return record
+}
+
+// assetAudit returns the audit record of deploying an asset from filename, which
+// failed when err is set
+func assetAudit(asset slarty.Asset, filename string, err error) slarty.AuditRecord {
+	record := slarty.AuditRecord{
+		Action:       slarty.AuditDeploy,
+		Command:      "deploy-assets",
+		Artifact:     asset.Name,
+		ArtifactName: filename,
+		Location:     asset.DeployLocation,
+		Result:       slarty.AuditSucceeded,
+	}
+	if err != nil {
+		record.Result = slarty.AuditFailed
+		record.Error = err.Error()
+	}
+	return record
+}
+
+// audit adds record to the audit log, filling in when it happened and who did it.
+// Like build history, failing to write the audit log is only reported as a warning.
+func (o *rootOptions) audit(artifactConfig *slarty.ArtifactsConfig, record slarty.AuditRecord) {
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	record.User = slarty.AuditUser()
+	record.Host, _ = os.Hostname()
+	record.Application = artifactConfig.Application
+
+	path, err := auditPath(artifactConfig)
+	if err == nil {
+		err = slarty.AppendAuditRecords(path, record)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to write audit log: %v\n", err)
+	}
+
+	if artifactConfig.Audit.Repository {
+		o.pendingAudit.mu.Lock()
+		o.pendingAudit.config = artifactConfig
+		o.pendingAudit.records = append(o.pendingAudit.records, record)
+		o.pendingAudit.mu.Unlock()
+	}
+}
+
+// flushAudit stores the audit records of the running command in the repository. It
+// runs once the command has finished, and before it exits early on a failure.
+func (o *rootOptions) flushAudit() {
+	o.pendingAudit.mu.Lock()
+	artifactConfig, records := o.pendingAudit.config, o.pendingAudit.records
+	o.pendingAudit.records = nil
+	o.pendingAudit.mu.Unlock()
+	if len(records) == 0 {
+		return
+	}
+
+	repoAdapter, err := o.openRepository(artifactConfig)
+	if err == nil {
+		err = slarty.StoreAuditRecords(repoAdapter, records)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to store the audit log in the repository: %v\n", err)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// historyOptions are the flags of the history command
+type historyOptions struct {
+	root       *rootOptions
+	query      slarty.AuditQuery
+	since      time.Duration
+	limit      int
+	repository bool
+	json       bool
+}
+
+// newHistoryCmd creates the history command
+func newHistoryCmd(root *rootOptions) *cobra.Command {
+	opts := &historyOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the audit log of builds, stores, retrieves, deploys and cleanups",
+		Long: `Shows who built, stored, retrieved, deployed or cleaned up what, and when, from
+the audit log every command appends to. Each record has the time, the user and host,
+the action, the artifact and its hash, and whether it succeeded.
+
+The log is read from the audit file, audit.jsonl in slarty's cache directory unless
+"audit": {"file": ...} is set. With --repository, the logs stored in the repository by
+every host are read instead, which needs "audit": {"repository": true} to have been
+set when the commands ran.
+
+Use --action, --artifact, --hash, --user, --result and --since to narrow the records
+down, for example to find who deployed a hash:
+
+  slarty history --action deploy --hash 51286ac`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runHistory(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().StringVar(&opts.query.Action, "action", "", "only show build, store, retrieve, deploy, approve or cleanup records")
+	cmd.Flags().StringVar(&opts.query.Artifact, "artifact", "", "only show records for this artifact, asset or artifact name")
+	cmd.Flags().StringVar(&opts.query.Hash, "hash", "", "only show records for hashes starting with this")
+	cmd.Flags().StringVar(&opts.query.User, "user", "", "only show records for this user")
+	cmd.Flags().StringVar(&opts.query.Result, "result", "", "only show succeeded or failed records")
+	cmd.Flags().DurationVar(&opts.since, "since", 0, "only show records from this long ago, such as 168h")
+	cmd.Flags().IntVar(&opts.limit, "limit", 0, "only show this many of the newest records")
+	cmd.Flags().BoolVar(&opts.repository, "repository", false, "read the audit logs stored in the repository instead of the local audit file")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "output results as JSON")
+
+	cmd.RegisterFlagCompletionFunc("artifact", root.completeArtifactNames)
+
+	return cmd
+}
+
+func runHistory(cmd *cobra.Command, args []string, opts *historyOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	switch opts.query.Action {
+	case "", slarty.AuditBuild, slarty.AuditStore, slarty.AuditRetrieve, slarty.AuditDeploy, slarty.AuditApprove, slarty.AuditCleanup:
+	default:
+		log.Fatalf("unknown action %q; use build, store, retrieve, deploy, approve or cleanup", opts.query.Action)
+	}
+
+	var records []slarty.AuditRecord
+	if opts.repository {
+		repoAdapter, err := opts.root.openRepository(artifactConfig)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		records, err = slarty.ReadRepositoryAuditLog(repoAdapter)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	} else {
+		path, err := auditPath(artifactConfig)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		records, err = slarty.ReadAuditLog(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	query := opts.query
+	if opts.since > 0 {
+		query.Since = time.Now().Add(-opts.since)
+	}
+	records = selectHistory(records, query, opts.limit)
+
+	if opts.json {
+		if records == nil {
+			records = []slarty.AuditRecord{}
+		}
+		out, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No audit records found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	fmt.Fprintf(w, " %s\t %s\t %s\t %s\t %s\t %s\t %s \n", "Time", "Action", "Artifact", "Hash", "User", "Host", "Result")
+	for _, record := range records {
+		result := record.Result
+		if record.Error != "" {
+			result += ": " + record.Error
+		}
+		fmt.Fprintf(w, " %s\t %s\t %s\t %s\t %s\t %s\t %s \n", record.Time.Local().Format("2006-01-02 15:04:05"), record.Action, record.Artifact, record.Hash, record.User, record.Host, result)
+	}
+	w.Flush()
+}
+
+// selectHistory returns the records matching query, keeping only the newest limit of
+// them when limit is set
+func selectHistory(records []slarty.AuditRecord, query slarty.AuditQuery, limit int) []slarty.AuditRecord {
+	selected := query.Filter(records)
+	if limit > 0 && len(selected) > limit {
+		selected = selected[len(selected)-limit:]
+	}
+	return selected
+}
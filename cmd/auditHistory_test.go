@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestSelectHistory(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []slarty.AuditRecord{
+		{Time: start, Action: slarty.AuditBuild, Artifact: "api", Hash: "aaa111", Result: slarty.AuditSucceeded},
+		{Time: start.Add(time.Hour), Action: slarty.AuditDeploy, Artifact: "api", Hash: "aaa111", User: "dana", Result: slarty.AuditSucceeded},
+		{Time: start.Add(2 * time.Hour), Action: slarty.AuditDeploy, Artifact: "web", Hash: "bbb222", User: "dana", Result: slarty.AuditFailed},
+		{Time: start.Add(3 * time.Hour), Action: slarty.AuditDeploy, Artifact: "api", Hash: "ccc333", User: "lee", Result: slarty.AuditSucceeded},
+	}
+
+	deploys := selectHistory(records, slarty.AuditQuery{Action: slarty.AuditDeploy}, 0)
+	if len(deploys) != 3 {
+		t.Errorf("Expected 3 deploys, got %d", len(deploys))
+	}
+
+	byHash := selectHistory(records, slarty.AuditQuery{Action: slarty.AuditDeploy, Hash: "aaa"}, 0)
+	if len(byHash) != 1 || byHash[0].User != "dana" {
+		t.Errorf("Expected dana's deploy of aaa111, got %+v", byHash)
+	}
+
+	newest := selectHistory(records, slarty.AuditQuery{Artifact: "api"}, 2)
+	if len(newest) != 2 || newest[1].Hash != "ccc333" || newest[0].Hash != "aaa111" || newest[0].Action != slarty.AuditDeploy {
+		t.Errorf("Expected the two newest api records, got %+v", newest)
+	}
+}
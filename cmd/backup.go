@@ -0,0 +1,52 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+// newRunBackup returns the backup for a run starting now
+func newRunBackup(artifactConfig *slarty.ArtifactsConfig) *slarty.Backup {
+	return slarty.NewBackup(artifactConfig.Cleanup.BackupsPath(artifactConfig.RootDirectory), artifactConfig.RootDirectory, time.Now())
+}
+
+// pruneBackups removes the oldest backups beyond keep_backups once a run has saved
+// one. The run has already succeeded, so failing to prune is only a warning.
+func pruneBackups(artifactConfig *slarty.ArtifactsConfig, backup *slarty.Backup) {
+	if backup == nil || backup.Dir() == "" {
+		return
+	}
+	fmt.Printf("Backed up the old contents to %s\n", backup.Dir())
+
+	removed, err := slarty.PruneBackups(artifactConfig.Cleanup.BackupsPath(artifactConfig.RootDirectory), artifactConfig.Cleanup.Retention())
+	for _, name := range removed {
+		fmt.Printf(" - Removed old backup %s\n", name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: %v\n", err)
+	}
+}
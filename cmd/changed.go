@@ -0,0 +1,123 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// changedOptions are the flags of the changed command
+type changedOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	since     string
+	to        string
+	json      bool
+	sort      string
+}
+
+// newChangedCmd creates the changed command
+func newChangedCmd(root *rootOptions) *cobra.Command {
+	opts := &changedOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "changed",
+		Short: "List the artifacts affected by changes between two git refs",
+		Long: `Compares two git refs and reports which artifacts have changes in their
+directories. The comparison is made from the merge base of --since and --to (HEAD by
+default), the same as "git diff since...to", so on a branch it reports what the
+branch changed. Use --json to feed the result to CI.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runChanged(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().StringVar(&opts.since, "since", "", "git ref to compare from, such as origin/main")
+	cmd.Flags().StringVar(&opts.to, "to", "HEAD", "git ref to compare to")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "output results as JSON")
+	cmd.Flags().StringVar(&opts.sort, "sort", sortByConfig, "order rows by config (as in artifacts.json) or name")
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "application3,application4", root.completeArtifactNames)
+	cmd.MarkFlagRequired("since")
+
+	return cmd
+}
+
+// changedArtifact is the result for a single artifact
+type changedArtifact struct {
+	Name    string   `json:"name"`
+	Changed bool     `json:"changed"`
+	Files   []string `json:"files"`
+}
+
+func runChanged(cmd *cobra.Command, args []string, opts *changedOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	artifacts := sortedArtifacts(artifactConfig.SelectArtifacts(opts.selection.selection()), opts.sort)
+
+	results := make([]changedArtifact, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		files, err := slarty.ChangedFiles(artifactConfig.RootDirectory, opts.since, opts.to, artifact.Directories)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if files == nil {
+			files = []string{}
+		}
+		results = append(results, changedArtifact{Name: artifact.Name, Changed: len(files) > 0, Files: files})
+	}
+
+	if opts.json {
+		out, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No artifacts found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	fmt.Fprintf(w, " %s\t %s\t %s \n", "Application", "Changed", "Files")
+	for _, result := range results {
+		changed := "no"
+		if result.Changed {
+			changed = "yes"
+		}
+		fmt.Fprintf(w, " %s\t %s\t %d \n", result.Name, changed, len(result.Files))
+	}
+	w.Flush()
+}
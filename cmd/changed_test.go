@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestChangedCommand(t *testing.T) {
+	// Test that the changed command is properly initialized
+	cmd := newChangedCmd(&rootOptions{})
+	if cmd.Use != "changed" {
+		t.Errorf("Expected Use to be 'changed', got '%s'", cmd.Use)
+	}
+
+	if cmd.Short == "" {
+		t.Error("Expected Short description to be set")
+	}
+
+	// Check flags
+	flags := cmd.Flags()
+	for _, name := range []string{"since", "to", "json", "filter", "exclude"} {
+		if flags.Lookup(name) == nil {
+			t.Errorf("changed command should have '%s' flag", name)
+		}
+	}
+	if to := flags.Lookup("to"); to != nil && to.DefValue != "HEAD" {
+		t.Errorf("Expected --to to default to HEAD, got %s", to.DefValue)
+	}
+}
+
+func TestRunChangedJSON(t *testing.T) {
+	tempDir := t.TempDir()
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+
+	for _, dir := range []string{"api", "web"} {
+		if err := os.MkdirAll(filepath.Join(tempDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(tempDir, dir, "file.txt"), []byte(dir), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	configPath := filepath.Join(tempDir, "artifacts.json")
+	jsonContent := `{
+		"application": "Test App",
+		"root_directory": "__DIR__",
+		"repository": { "adapter": "Local", "options": { "root": "/tmp/repo" } },
+		"artifacts": [
+			{ "name": "api", "directories": ["api"], "artifact_prefix": "api" },
+			{ "name": "web", "directories": ["web"], "artifact_prefix": "web" }
+		]
+	}`
+	if err := os.WriteFile(configPath, []byte(jsonContent), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	git("init")
+	git("config", "user.email", "test@example.com")
+	git("config", "user.name", "Test User")
+	git("add", ".")
+	git("commit", "-m", "Initial commit")
+	git("tag", "base")
+
+	if err := os.WriteFile(filepath.Join(tempDir, "web", "file.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	git("commit", "-am", "Change web")
+
+	opts := &changedOptions{root: &rootOptions{artifactsJson: configPath}, since: "base", to: "HEAD", json: true}
+
+	output := captureStdout(t, func() { runChanged(&cobra.Command{}, []string{}, opts) })
+
+	var results []changedArtifact
+	if err := json.Unmarshal([]byte(output), &results); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v\n%s", err, output)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].Name != "api" || results[0].Changed || len(results[0].Files) != 0 {
+		t.Errorf("Expected api to be unchanged, got %+v", results[0])
+	}
+	if results[1].Name != "web" || !results[1].Changed || len(results[1].Files) != 1 || results[1].Files[0] != "web/file.txt" {
+		t.Errorf("Expected web to have changed web/file.txt, got %+v", results[1])
+	}
+}
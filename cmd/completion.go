@@ -0,0 +1,143 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// completeArtifactNames completes comma-separated artifact names from artifacts.json
+func (o *rootOptions) completeArtifactNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	artifactConfig, err := o.readConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, artifact := range artifactConfig.Artifacts {
+		names = append(names, artifact.Name)
+	}
+
+	return completeNames(names, toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeAssetNames completes comma-separated asset names from artifacts.json
+func (o *rootOptions) completeAssetNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	artifactConfig, err := o.readConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, asset := range artifactConfig.Assets {
+		names = append(names, asset.Name)
+	}
+
+	return completeNames(names, toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeGroupNames completes comma-separated group names from artifacts.json
+func (o *rootOptions) completeGroupNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	artifactConfig, err := o.readConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return completeNames(artifactConfig.GroupNames(), toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeInspectArgs completes the single artifact or asset argument of inspect
+func (o *rootOptions) completeInspectArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	artifactConfig, err := o.readConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, artifact := range artifactConfig.Artifacts {
+		if strings.HasPrefix(strings.ToLower(artifact.Name), strings.ToLower(toComplete)) {
+			names = append(names, artifact.Name)
+		}
+	}
+	for _, asset := range artifactConfig.Assets {
+		if strings.HasPrefix(strings.ToLower(asset.Name), strings.ToLower(toComplete)) {
+			names = append(names, asset.Name)
+		}
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePipelineNames completes the name of a pipeline from artifacts.json
+func (o *rootOptions) completePipelineNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	artifactConfig, err := o.readConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, name := range artifactConfig.PipelineNames() {
+		if strings.HasPrefix(strings.ToLower(name), strings.ToLower(toComplete)) {
+			names = append(names, name)
+		}
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeNames completes the last entry of a comma-separated list. Names already
+// present earlier in the list are not offered again, and each suggestion keeps the
+// earlier entries so the shell replaces the whole word correctly.
+func completeNames(names []string, toComplete string) []string {
+	done := ""
+	current := toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		done = toComplete[:i+1]
+		current = toComplete[i+1:]
+	}
+
+	used := make(map[string]bool)
+	for _, name := range strings.Split(done, ",") {
+		used[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	var suggestions []string
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		if used[lower] || !strings.HasPrefix(lower, strings.ToLower(current)) {
+			continue
+		}
+		suggestions = append(suggestions, done+name)
+	}
+
+	return suggestions
+}
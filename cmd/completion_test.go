@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCompleteNames(t *testing.T) {
+	names := []string{"Frontend", "Fonts", "API"}
+
+	tests := []struct {
+		toComplete string
+		expected   []string
+	}{
+		{"", []string{"Frontend", "Fonts", "API"}},
+		{"f", []string{"Frontend", "Fonts"}},
+		{"FRO", []string{"Frontend"}},
+		{"api,f", []string{"api,Frontend", "api,Fonts"}},
+		{"Frontend,", []string{"Frontend,Fonts", "Frontend,API"}},
+		{"x", nil},
+	}
+
+	for _, tt := range tests {
+		got := completeNames(names, tt.toComplete)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("completeNames(%q) = %v, expected %v", tt.toComplete, got, tt.expected)
+		}
+	}
+}
+
+func TestCompletionFromConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "artifacts.json")
+	jsonContent := `{
+		"root_directory": "__DIR__",
+		"repository": { "adapter": "Local", "options": { "root": "/tmp/repo" } },
+		"artifacts": [ { "name": "api", "groups": ["backend"] }, { "name": "web", "groups": ["frontend"] } ],
+		"assets": [ { "name": "fonts", "groups": ["frontend"] } ]
+	}`
+	if err := os.WriteFile(configPath, []byte(jsonContent), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	opts := &rootOptions{artifactsJson: configPath}
+	cmd := &cobra.Command{Use: "test"}
+
+	artifacts, directive := opts.completeArtifactNames(cmd, nil, "")
+	if !reflect.DeepEqual(artifacts, []string{"api", "web"}) {
+		t.Errorf("Expected artifact names, got %v", artifacts)
+	}
+	if directive&cobra.ShellCompDirectiveNoFileComp == 0 {
+		t.Error("Expected file completion to be disabled")
+	}
+
+	assets, _ := opts.completeAssetNames(cmd, nil, "")
+	if !reflect.DeepEqual(assets, []string{"fonts"}) {
+		t.Errorf("Expected asset names, got %v", assets)
+	}
+
+	groups, _ := opts.completeGroupNames(cmd, nil, "backend,")
+	if !reflect.DeepEqual(groups, []string{"backend,frontend"}) {
+		t.Errorf("Expected the remaining group, got %v", groups)
+	}
+
+	inspectArgs, _ := opts.completeInspectArgs(cmd, nil, "")
+	if !reflect.DeepEqual(inspectArgs, []string{"api", "web", "fonts"}) {
+		t.Errorf("Expected artifact and asset names, got %v", inspectArgs)
+	}
+
+	opts.artifactsJson = filepath.Join(tempDir, "missing.json")
+	if names, _ := opts.completeArtifactNames(cmd, nil, ""); names != nil {
+		t.Errorf("Expected no suggestions without a config, got %v", names)
+	}
+}
+
+func TestFilterFlagCompletion(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "artifacts.json")
+	jsonContent := `{
+		"root_directory": "__DIR__",
+		"repository": { "adapter": "Local", "options": { "root": "/tmp/repo" } },
+		"artifacts": [ { "name": "api" }, { "name": "web" } ],
+		"assets": [ { "name": "fonts" } ]
+	}`
+	if err := os.WriteFile(configPath, []byte(jsonContent), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	tests := map[string]string{
+		"do-builds":     "api\nweb\n",
+		"should-build":  "api\nweb\n",
+		"deploy-assets": "fonts\n",
+		"do-cleanup":    "fonts\n",
+	}
+	for command, expected := range tests {
+		var out bytes.Buffer
+		root := newRootCmd()
+		root.SetOut(&out)
+		root.SetArgs([]string{cobra.ShellCompRequestCmd, command, "--artifacts", configPath, "--filter", ""})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("completion for %s failed: %v", command, err)
+		}
+		if !strings.HasPrefix(out.String(), expected) {
+			t.Errorf("Expected %s --filter completion to start with %q, got %q", command, expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// configOptions holds the entries built from the flags of the config add commands
+type configOptions struct {
+	root        *rootOptions
+	newArtifact slarty.ArtifactConfig
+	newAsset    slarty.Asset
+	noUnpack    bool
+}
+
+// newConfigCmd creates the config command, which groups the commands that inspect
+// and edit artifacts.json
+func newConfigCmd(root *rootOptions) *cobra.Command {
+	opts := &configOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and edit artifacts.json",
+		Long: `Commands for working with the artifacts.json configuration without editing the
+JSON by hand.`,
+	}
+
+	addArtifactCmd := &cobra.Command{
+		Use:   "add-artifact",
+		Short: "Append an artifact to artifacts.json",
+		Long: `Appends a new artifact built from the flags to artifacts.json. The entry is
+validated first and the rest of the file keeps its existing formatting.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAddArtifact(cmd, args, opts)
+		},
+	}
+	addArtifactCmd.Flags().StringVar(&opts.newArtifact.Name, "name", "", "name of the artifact")
+	addArtifactCmd.Flags().StringSliceVar(&opts.newArtifact.Directories, "directories", nil, "directories used to calculate the hash (comma separated)")
+	addArtifactCmd.Flags().StringVar(&opts.newArtifact.Command, "command", "", "command that builds the artifact")
+	addArtifactCmd.Flags().StringSliceVar((*[]string)(&opts.newArtifact.OutputDirectory), "output-directory", nil, "directories archived after the build (comma separated)")
+	addArtifactCmd.Flags().StringVar(&opts.newArtifact.DeployLocation, "deploy-location", "", "directory the artifact is extracted to on deploy")
+	addArtifactCmd.Flags().StringVar(&opts.newArtifact.ArtifactPrefix, "artifact-prefix", "", "prefix used in the artifact filename")
+
+	addAssetCmd := &cobra.Command{
+		Use:   "add-asset",
+		Short: "Append an asset to artifacts.json",
+		Long: `Appends a new asset built from the flags to artifacts.json. The entry is
+validated first and the rest of the file keeps its existing formatting.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runAddAsset(cmd, args, opts)
+		},
+	}
+	addAssetCmd.Flags().StringVar(&opts.newAsset.Name, "name", "", "name of the asset")
+	addAssetCmd.Flags().StringVar(&opts.newAsset.Filename, "filename", "", "filename of the asset in the repository")
+	addAssetCmd.Flags().StringVar(&opts.newAsset.DeployLocation, "deploy-location", "", "directory the asset is extracted to on deploy")
+	addAssetCmd.Flags().StringVar(&opts.newAsset.SHA256, "sha256", "", "expected SHA-256 of the asset's file, checked before it is deployed")
+	addAssetCmd.Flags().BoolVar(&opts.noUnpack, "no-unpack", false, "copy the asset's file to the deploy location instead of extracting it")
+
+	cmd.AddCommand(
+		addArtifactCmd,
+		addAssetCmd,
+		newConfigMigrateCmd(root),
+		newConfigSchemaCmd(),
+		newConfigShowCmd(root),
+	)
+
+	return cmd
+}
+
+func runAddArtifact(cmd *cobra.Command, args []string, opts *configOptions) {
+	path := opts.root.artifactsJson
+	err := opts.root.appendToConfig(path, "artifacts", opts.newArtifact, func(config *slarty.ArtifactsConfig) {
+		config.Artifacts = append(config.Artifacts, opts.newArtifact)
+	}, cmd.OutOrStdout())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Added artifact %s to %s\n", opts.newArtifact.Name, path)
+}
+
+func runAddAsset(cmd *cobra.Command, args []string, opts *configOptions) {
+	asset := opts.newAsset
+	if opts.noUnpack {
+		unpack := false
+		asset.Unpack = &unpack
+	}
+	path := opts.root.artifactsJson
+	err := opts.root.appendToConfig(path, "assets", asset, func(config *slarty.ArtifactsConfig) {
+		config.Assets = append(config.Assets, asset)
+	}, cmd.OutOrStdout())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Added asset %s to %s\n", asset.Name, path)
+}
+
+// appendToConfig validates the configuration with the new entry added and, if it is
+// free of errors, appends the entry to the array named key in the file at path. Any
+// validation problems are written to w.
+func (o *rootOptions) appendToConfig(path, key string, entry interface{}, add func(*slarty.ArtifactsConfig), w io.Writer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	config, err := o.readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	add(config)
+
+	var problems bytes.Buffer
+	if errCount, _ := validateConfig(&problems, config); errCount > 0 {
+		w.Write(problems.Bytes())
+		return fmt.Errorf("not adding entry because the configuration would be invalid")
+	}
+
+	updated, err := slarty.AppendConfigEntry(data, key, entry)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, updated, info.Mode().Perm())
+}
@@ -0,0 +1,103 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// configMigrateOptions are the flags of the config migrate command
+type configMigrateOptions struct {
+	root   *rootOptions
+	dryRun bool
+}
+
+// newConfigMigrateCmd creates the config migrate command
+func newConfigMigrateCmd(root *rootOptions) *cobra.Command {
+	opts := &configMigrateOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade artifacts.json to the current config format",
+		Long: fmt.Sprintf(`Upgrades artifacts.json in place to the current config format, schema_version %d,
+renaming and removing keys that older versions used and setting schema_version. The
+order of the keys and the file's indentation are kept. A config without schema_version
+is version 1. Use --dry-run to see the changes without writing them.
+
+Workspaces have their own artifacts.json, so migrate each of them with --artifacts.`, slarty.CurrentSchemaVersion),
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigMigrate(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "list the changes without writing them")
+
+	return cmd
+}
+
+func runConfigMigrate(cmd *cobra.Command, args []string, opts *configMigrateOptions) {
+	if err := migrateConfig(cmd.OutOrStdout(), opts.root.artifactsJson, opts.dryRun); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// migrateConfig upgrades the config at path to the current format, writing the changes
+// made to w. With dryRun set the file is left as it is.
+func migrateConfig(w io.Writer, path string, dryRun bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	migrated, changes, err := slarty.MigrateConfig(data)
+	if err != nil {
+		return fmt.Errorf("unable to migrate %s: %w", path, err)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "%s is already at schema_version %d\n", path, slarty.CurrentSchemaVersion)
+		return nil
+	}
+
+	for _, change := range changes {
+		fmt.Fprintf(w, "  %s\n", change)
+	}
+	if dryRun {
+		fmt.Fprintf(w, "Would migrate %s to schema_version %d (dry run)\n", path, slarty.CurrentSchemaVersion)
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, migrated, info.Mode().Perm()); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Migrated %s to schema_version %d\n", path, slarty.CurrentSchemaVersion)
+	return nil
+}
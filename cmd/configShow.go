@@ -0,0 +1,153 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// configShowOptions are the flags of the config show command
+type configShowOptions struct {
+	root *rootOptions
+	// resolved shows the configuration as commands use it rather than as written
+	resolved bool
+	// output is the format config show prints in: json or yaml
+	output string
+}
+
+// newConfigShowCmd creates the config show command
+func newConfigShowCmd(root *rootOptions) *cobra.Command {
+	opts := &configShowOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the configuration from artifacts.json",
+		Long: `Prints artifacts.json as JSON or YAML. With --resolved it prints the configuration
+as commands use it instead: workspaces and matrix artifacts expanded, deploy location
+placeholders filled in, root_directory made absolute, the repository that --local and
+--channel select with its path placeholders expanded, and the defaults of settings that
+are left out. Use it to answer questions like "which bucket is this actually using?".`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runConfigShow(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().BoolVar(&opts.resolved, "resolved", false, "show the configuration as commands use it, with defaults filled in")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "json", "output format: json or yaml")
+
+	return cmd
+}
+
+func runConfigShow(cmd *cobra.Command, args []string, opts *configShowOptions) {
+	if err := opts.root.showConfig(cmd.OutOrStdout(), opts.root.artifactsJson, opts.resolved, opts.output); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// showConfig writes the configuration at path to w in format, as it is written or,
+// with resolved set, as commands use it
+func (o *rootOptions) showConfig(w io.Writer, path string, resolved bool, format string) error {
+	if format != "json" && format != "yaml" {
+		return fmt.Errorf("unknown output %q; use json or yaml", format)
+	}
+
+	var data []byte
+	if resolved {
+		config, err := o.readConfigFile(path)
+		if err != nil {
+			return err
+		}
+		if o.channel != "" {
+			config.Repository.Channel = o.channel
+		}
+		config, err = config.Resolved(o.local)
+		if err != nil {
+			return err
+		}
+		data, err = json.Marshal(config)
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !json.Valid(data) {
+			return fmt.Errorf("%s is not valid JSON", path)
+		}
+	}
+
+	if format == "yaml" {
+		out, err := jsonToYAML(data)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return err
+	}
+	out.WriteString("\n")
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// jsonToYAML converts JSON to block style YAML, keeping the order of the keys
+func jsonToYAML(data []byte) ([]byte, error) {
+	// JSON is YAML written in flow style, so it can be read as YAML directly
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	var blockStyle func(n *yaml.Node)
+	blockStyle = func(n *yaml.Node) {
+		n.Style &^= yaml.FlowStyle | yaml.DoubleQuotedStyle
+		for _, child := range n.Content {
+			blockStyle(child)
+		}
+	}
+	blockStyle(&node)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
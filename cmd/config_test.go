@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestConfigCommands(t *testing.T) {
+	cmd := newConfigCmd(&rootOptions{})
+	if cmd.Use != "config" {
+		t.Errorf("Expected config command Use to be 'config', got '%s'", cmd.Use)
+	}
+
+	addArtifactCmd, _, err := cmd.Find([]string{"add-artifact"})
+	if err != nil {
+		t.Fatalf("Expected an add-artifact command: %v", err)
+	}
+	addAssetCmd, _, err := cmd.Find([]string{"add-asset"})
+	if err != nil {
+		t.Fatalf("Expected an add-asset command: %v", err)
+	}
+
+	for _, name := range []string{"name", "directories", "command", "output-directory", "deploy-location", "artifact-prefix"} {
+		if addArtifactCmd.Flags().Lookup(name) == nil {
+			t.Errorf("add-artifact command should have '%s' flag", name)
+		}
+	}
+
+	for _, name := range []string{"name", "filename", "deploy-location", "sha256", "no-unpack"} {
+		if addAssetCmd.Flags().Lookup(name) == nil {
+			t.Errorf("add-asset command should have '%s' flag", name)
+		}
+	}
+}
+
+func TestAppendToConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tempDir, "src"), 0755); err != nil {
+		t.Fatalf("Failed to create src directory: %v", err)
+	}
+
+	original := `{
+	"application": "Test App",
+	"root_directory": "__DIR__",
+	"repository": { "adapter": "Local", "options": { "root": "/tmp/repo" } },
+	"artifacts": [],
+	"assets": []
+}
+`
+	configPath := filepath.Join(tempDir, "artifacts.json")
+	if err := os.WriteFile(configPath, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	t.Run("ValidArtifact", func(t *testing.T) {
+		artifact := slarty.ArtifactConfig{
+			Name:            "api",
+			Directories:     []string{"src"},
+			Command:         "make api",
+			OutputDirectory: slarty.OutputDirectories{"build/api"},
+			DeployLocation:  "deploy/api",
+			ArtifactPrefix:  "api",
+		}
+		err := (&rootOptions{}).appendToConfig(configPath, "artifacts", artifact, func(c *slarty.ArtifactsConfig) {
+			c.Artifacts = append(c.Artifacts, artifact)
+		}, &bytes.Buffer{})
+		if err != nil {
+			t.Fatalf("appendToConfig failed: %v", err)
+		}
+
+		config, err := slarty.ReadArtifactsJson(configPath)
+		if err != nil {
+			t.Fatalf("Failed to read updated config: %v", err)
+		}
+		if len(config.Artifacts) != 1 || config.Artifacts[0].Name != "api" {
+			t.Errorf("Expected api artifact to be added, got %+v", config.Artifacts)
+		}
+
+		content, _ := os.ReadFile(configPath)
+		if !strings.Contains(string(content), `"repository": { "adapter": "Local", "options": { "root": "/tmp/repo" } },`) {
+			t.Errorf("Expected untouched lines to keep their formatting:\n%s", content)
+		}
+	})
+
+	t.Run("InvalidAssetIsRejected", func(t *testing.T) {
+		before, _ := os.ReadFile(configPath)
+
+		asset := slarty.Asset{Name: "fonts", Filename: "", DeployLocation: "public/fonts"}
+		var out bytes.Buffer
+		err := (&rootOptions{}).appendToConfig(configPath, "assets", asset, func(c *slarty.ArtifactsConfig) {
+			c.Assets = append(c.Assets, asset)
+		}, &out)
+		if err == nil {
+			t.Fatal("Expected appendToConfig to reject an asset without a filename")
+		}
+		if !strings.Contains(out.String(), "fonts has an empty filename") {
+			t.Errorf("Expected validation problem in output, got:\n%s", out.String())
+		}
+
+		after, _ := os.ReadFile(configPath)
+		if !bytes.Equal(before, after) {
+			t.Error("Config file should not change when validation fails")
+		}
+	})
+}
+
+func TestShowConfig(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "artifacts.json")
+	content := `{
+		"application": "demo",
+		"root_directory": "__DIR__",
+		"repository": {"adapter": "S3", "channel": "main", "options": {"region": "us-east-1", "bucket-name": "builds", "path-prefix": "/{app}/artifacts"}},
+		"artifacts": [{"name": "web", "directories": ["web"], "command": "make", "output_directory": "build", "deploy_location": "public", "artifact_prefix": "web"}]
+	}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	opts := &rootOptions{}
+	var out bytes.Buffer
+	if err := opts.showConfig(&out, configPath, false, "yaml"); err != nil {
+		t.Fatalf("showConfig failed: %v", err)
+	}
+	for _, want := range []string{"root_directory: __DIR__\n", "  adapter: S3\n", "    path-prefix: /{app}/artifacts\n", "  - name: web\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the YAML to contain %q, got:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "keep_backups") {
+		t.Errorf("Expected the configuration as written to leave out defaults, got:\n%s", out.String())
+	}
+
+	opts.channel = "feature-x"
+	out.Reset()
+	if err := opts.showConfig(&out, configPath, true, "json"); err != nil {
+		t.Fatalf("showConfig failed: %v", err)
+	}
+	var resolved slarty.ArtifactsConfig
+	if err := json.Unmarshal(out.Bytes(), &resolved); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, out.String())
+	}
+	if resolved.RootDirectory != tempDir {
+		t.Errorf("Expected root_directory %s, got %s", tempDir, resolved.RootDirectory)
+	}
+	if resolved.Repository.Adapter != "s3" || resolved.Repository.Options.PathPrefix != "demo/artifacts/feature-x" {
+		t.Errorf("Expected the s3 repository under demo/artifacts/feature-x, got %+v", resolved.Repository)
+	}
+	if resolved.Cleanup.KeepBackups != slarty.DefaultKeepBackups || resolved.DirtyTree != slarty.DirtyTreeWarn {
+		t.Errorf("Expected defaults to be filled in, got %+v and %q", resolved.Cleanup, resolved.DirtyTree)
+	}
+
+	opts.local = true
+	out.Reset()
+	if err := opts.showConfig(&out, configPath, true, "yaml"); err != nil {
+		t.Fatalf("showConfig failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "  adapter: local\n") {
+		t.Errorf("Expected --local to select the local adapter, got:\n%s", out.String())
+	}
+
+	if err := opts.showConfig(&out, configPath, false, "toml"); err == nil {
+		t.Errorf("Expected an unknown output format to fail")
+	}
+}
+
+func TestRunConfigSchema(t *testing.T) {
+	var out bytes.Buffer
+	cmd := newConfigSchemaCmd()
+	cmd.SetOut(&out)
+	runConfigSchema(cmd, nil)
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &schema); err != nil {
+		t.Fatalf("Expected the schema to be JSON: %v", err)
+	}
+	if schema["$schema"] != "http://json-schema.org/draft-07/schema#" {
+		t.Errorf("Expected a draft-07 schema, got %v", schema["$schema"])
+	}
+}
+
+func TestMigrateConfigCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "artifacts.json")
+	original := `{"application": "Test", "repository": {"adapter": "s3", "options": {"bucket_name": "builds"}}}`
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := migrateConfig(&out, path, true); err != nil {
+		t.Fatalf("migrateConfig failed: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != original {
+		t.Errorf("Expected a dry run to leave the file alone, got %s", data)
+	}
+	if !strings.Contains(out.String(), "renamed repository.options.bucket_name to bucket-name") || !strings.Contains(out.String(), "dry run") {
+		t.Errorf("Expected the dry run to list the changes, got %s", out.String())
+	}
+
+	out.Reset()
+	if err := migrateConfig(&out, path, false); err != nil {
+		t.Fatalf("migrateConfig failed: %v", err)
+	}
+	config, err := slarty.ReadArtifactsJson(path)
+	if err != nil {
+		t.Fatalf("Failed to read the migrated config: %v", err)
+	}
+	if config.SchemaVersion != slarty.CurrentSchemaVersion || config.Repository.Options.BucketName != "builds" {
+		t.Errorf("Expected the migrated config to be current with its bucket kept, got %+v", config)
+	}
+
+	out.Reset()
+	if err := migrateConfig(&out, path, false); err != nil {
+		t.Fatalf("migrateConfig failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "already at schema_version") {
+		t.Errorf("Expected a current config to be reported as such, got %s", out.String())
+	}
+}
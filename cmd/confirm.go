@@ -0,0 +1,61 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// errConfirmationRequired is returned by confirm when it cannot ask the question
+var errConfirmationRequired = errors.New("confirmation required; pass --yes to go ahead without a prompt")
+
+// confirm asks question on the command's output and reports whether it was answered
+// with yes. With --yes it is answered without asking. With --non-interactive, or
+// when the input is not a terminal, nobody can answer, so errConfirmationRequired
+// is returned rather than going ahead.
+func (o *rootOptions) confirm(cmd *cobra.Command, question string) (bool, error) {
+	if o.assumeYes {
+		return true, nil
+	}
+	in := cmd.InOrStdin()
+	if f, ok := in.(*os.File); o.noPrompt || (ok && !isTerminal(f)) {
+		return false, errConfirmationRequired
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestConfirm(t *testing.T) {
+	opts := &rootOptions{}
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{" YES \n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+		{"sure\n", false},
+	}
+	for _, tt := range tests {
+		cmd := &cobra.Command{Use: "test"}
+		cmd.SetIn(strings.NewReader(tt.input))
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		got, err := opts.confirm(cmd, "Delete them?")
+		if err != nil {
+			t.Fatalf("confirm(%q) failed: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("confirm(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+		if out.String() != "Delete them? [y/N]: " {
+			t.Errorf("Expected the question to be asked, got %q", out.String())
+		}
+	}
+
+	// --non-interactive refuses to ask
+	opts.noPrompt = true
+	cmd := &cobra.Command{Use: "test"}
+	cmd.SetIn(strings.NewReader("y\n"))
+	if _, err := opts.confirm(cmd, "Delete them?"); !errors.Is(err, errConfirmationRequired) {
+		t.Errorf("Expected a confirmation required error, got %v", err)
+	}
+
+	// --yes wins without reading anything
+	opts.assumeYes = true
+	ok, err := opts.confirm(cmd, "Delete them?")
+	if err != nil || !ok {
+		t.Errorf("Expected --yes to confirm, got %v, %v", ok, err)
+	}
+}
@@ -22,127 +22,252 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/dstockto/slarty/slarty"
 	"github.com/spf13/cobra"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
 )
 
-// deployAssetsCmd represents the deployAssets command
-var deployAssetsCmd = &cobra.Command{
-	Use:   "deploy-assets",
-	Short: "Deploy assets from the repository",
-	Long: `Deploys assets from the repository to their deploy locations.
-The command downloads assets from the repository and extracts them to the
-specified deploy locations. If an asset cannot be found in the repository,
-it will be treated as a fatal error.`,
-	Run: runDeployAssets,
+// deployAssetsOptions are the flags of the deploy-assets command
+type deployAssetsOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	// latest deploys the file named by each asset's latest pointer
+	latest   bool
+	dryRun   bool
+	parallel int
+	// noMaintenance deploys without running the maintenance commands
+	noMaintenance bool
 }
 
-// filterAssetsByName filters assets by name based on the provided filter
-func filterAssetsByName(assets []slarty.Asset, filter []string) []slarty.Asset {
-	if len(filter) == 0 {
-		return assets
+// newDeployAssetsCmd creates the deploy-assets command
+func newDeployAssetsCmd(root *rootOptions) *cobra.Command {
+	opts := &deployAssetsOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "deploy-assets",
+		Short: "Deploy assets from the repository",
+		Long: `Deploys assets from the repository to their deploy locations.
+The command downloads assets from the repository and extracts them to the
+specified deploy locations. Every asset is looked up in the repository before any is
+deployed, and one that cannot be found is a fatal error.
+Use --filter to limit the deploy to matching assets and --exclude to leave matching
+assets out. With --latest, each asset's file is looked up through the latest pointer
+stored for the asset's name instead of using the configured filename. An asset with a
+sha256 in artifacts.json is verified before it is extracted and is not deployed if the
+downloaded file does not match. An asset with "unpack": false is not an archive and is
+copied into its deploy location under its filename instead of being extracted.
+With --dry-run the assets are looked up and listed with their deploy locations, but
+nothing is downloaded. --parallel downloads up to that many assets at once; after a
+failure no more are started, and the command exits once those running have finished.
+The maintenance commands in artifacts.json are run before the first asset and after
+the last, as for do-deploys; use --no-maintenance to skip them.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runDeployAssets(cmd, args, opts)
+		},
 	}
 
-	var selected []slarty.Asset
-	for _, asset := range assets {
-		name := strings.ToLower(asset.Name)
-		for _, f := range filter {
-			if name == strings.TrimSpace(strings.ToLower(f)) {
-				selected = append(selected, asset)
-				break
-			}
-		}
-	}
+	// Here you will define your flags and configuration settings.
+	bindSelectionFlags(cmd, root, &opts.selection, "asset1,asset2", "asset3,asset4", root.completeAssetNames)
+	cmd.Flags().BoolVar(&opts.latest, "latest", false, "deploy the file named by each asset's latest pointer")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "list the assets that would be deployed without downloading them")
+	cmd.Flags().IntVar(&opts.parallel, "parallel", 1, "download up to this many assets at once")
+	cmd.Flags().BoolVar(&opts.noMaintenance, "no-maintenance", false, "don't run the maintenance mode commands around the deploy")
 
-	return selected
+	return cmd
 }
 
-func runDeployAssets(cmd *cobra.Command, args []string) {
+// assetDeploy is an asset to deploy and the file in the repository it comes from
+type assetDeploy struct {
+	asset    slarty.Asset
+	filename string
+}
+
+func runDeployAssets(cmd *cobra.Command, args []string, opts *deployAssetsOptions) {
+	if opts.parallel < 1 {
+		log.Fatalln("--parallel must be at least 1")
+	}
+
 	// Read the artifacts configuration
-	artifactConfig, err := slarty.ReadArtifactsJson(artifactsJson)
+	artifactConfig, err := opts.root.readConfig()
 	if err != nil {
 		log.Fatalln(err)
 	}
 
 	// Create a repository adapter
-	repoAdapter, err := slarty.NewRepositoryAdapter(artifactConfig, local)
+	repoAdapter, err := opts.root.openRepository(artifactConfig)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	// Parse the filter flag
-	var filters []string
-	if filter != "" {
-		filters = strings.Split(filter, ",")
-	}
-
 	// Get the assets based on the filter
-	assets := filterAssetsByName(artifactConfig.Assets, filters)
+	assets := artifactConfig.SelectAssets(opts.selection.selection())
 
 	if len(assets) == 0 {
 		fmt.Println("No assets found")
 		return
 	}
 
-	// Deploy each asset
 	for _, asset := range assets {
-		fmt.Printf("Found asset %s (%s)\n", asset.Name, asset.Filename)
-
-		// Check if the asset exists in the repository
-		exists, err := repoAdapter.ArtifactExists(asset.Filename)
-		if err != nil {
-			log.Fatalf("Failed to check if asset exists in repository: %v", err)
+		if err := slarty.CheckLocation(asset.DeployLocation); err != nil {
+			log.Fatalf("Cannot deploy %s: %v", asset.Name, err)
 		}
-		if !exists {
-			log.Fatalf("Asset %s not found in repository", asset.Filename)
+	}
+
+	deploys, err := findAssets(repoAdapter, assets, opts.latest)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if opts.dryRun {
+		for _, d := range deploys {
+			deployPath := filepath.Join(artifactConfig.RootDirectory, d.asset.DeployLocation)
+			fmt.Printf("Would deploy asset %s (%s) to %s\n", d.asset.Name, d.filename, deployPath)
 		}
+		return
+	}
 
-		// Create a temporary file to download the asset
-		tempFile, err := os.CreateTemp("", "slarty-asset-*.tar.gz")
-		if err != nil {
-			log.Fatalf("Failed to create temporary file: %v", err)
+	maint := opts.root.newMaintenance(artifactConfig, opts.noMaintenance)
+
+	// fail reports a failure and exits, warning if the application was left in
+	// maintenance mode
+	fail := func(format string, a ...interface{}) {
+		maint.WarnIfActive()
+		opts.root.flushAudit()
+		log.Fatalf(format, a...)
+	}
+
+	if err := maint.Enable(); err != nil {
+		fail("%v", err)
+	}
+
+	// Deploy each asset
+	deployer := opts.root.newDeployer(artifactConfig, repoAdapter, nil)
+	var failure error
+	for _, result := range deployAllAssets(os.Stdout, deployer, deploys, opts.parallel) {
+		opts.root.audit(artifactConfig, assetAudit(result.asset, result.filename, result.err))
+		if failure == nil {
+			failure = result.err
 		}
-		tempFilePath := tempFile.Name()
-		tempFile.Close() // Close the file so we can reopen it for writing
+	}
+	if failure != nil {
+		fail("%v", failure)
+	}
 
-		// Download the asset from the repository
-		err = repoAdapter.RetrieveArtifact(asset.Filename, tempFilePath)
-		if err != nil {
-			os.Remove(tempFilePath)
-			log.Fatalf("Failed to retrieve asset from repository: %v", err)
+	if err := maint.Disable(); err != nil {
+		fail("%v", err)
+	}
+}
+
+// findAssets looks up the file each asset is deployed from, through its latest
+// pointer with latest, and checks that it is in the repository
+func findAssets(repoAdapter slarty.RepositoryAdapter, assets []slarty.Asset, latest bool) ([]assetDeploy, error) {
+	deploys := make([]assetDeploy, 0, len(assets))
+	for _, asset := range assets {
+		d := assetDeploy{asset: asset, filename: asset.Filename}
+		if latest {
+			pointer, err := slarty.ReadLatestPointer(repoAdapter, asset.Name)
+			if err != nil {
+				return nil, err
+			}
+			d.filename = pointer.ArtifactName
 		}
-		fmt.Println(" - Downloaded asset")
 
-		// Create the deploy location directory if it doesn't exist
-		deployPath := filepath.Join(artifactConfig.RootDirectory, asset.DeployLocation)
-		err = os.MkdirAll(deployPath, 0755)
+		exists, err := repoAdapter.ArtifactExists(d.filename)
 		if err != nil {
-			os.Remove(tempFilePath)
-			log.Fatalf("Failed to create deploy directory: %v", err)
+			return nil, fmt.Errorf("failed to check if asset %s exists in repository: %w", d.filename, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: asset %s", slarty.ErrArtifactNotFound, d.filename)
 		}
+		deploys = append(deploys, d)
+	}
+	return deploys, nil
+}
 
-		// Extract the asset to the deploy location
-		err = extractTarGz(tempFilePath, deployPath)
-		if err != nil {
-			os.Remove(tempFilePath)
-			log.Fatalf("Failed to extract asset: %v", err)
+// assetResult is how deploying an asset went
+type assetResult struct {
+	assetDeploy
+	err error
+}
+
+// deployAllAssets deploys up to parallel assets at a time, writing each one's
+// output to w in one piece once it is done so the assets' lines don't interleave.
+// Assets with the same deploy location are deployed one after another, so two
+// archives are never extracted into one directory at once. After a failure no more
+// assets are started. The results are in the order the assets finished.
+func deployAllAssets(w io.Writer, deployer *slarty.Deployer, deploys []assetDeploy, parallel int) []assetResult {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		failed  bool
+		results []assetResult
+	)
+	stopped := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return failed
+	}
+	slots := make(chan struct{}, parallel)
+	for _, group := range groupByDeployPath(deployer.Config.RootDirectory, deploys) {
+		slots <- struct{}{}
+		if stopped() {
+			break
 		}
-		fmt.Println(" - Extracted asset")
 
-		// Delete the temporary file
-		os.Remove(tempFilePath)
-		fmt.Println(" - Deleted (tar.gz) asset")
+		wg.Add(1)
+		go func(group []assetDeploy) {
+			defer func() { <-slots; wg.Done() }()
+			for _, d := range group {
+				if stopped() {
+					return
+				}
+				var out bytes.Buffer
+				err := deployerWritingTo(deployer, &out).DeployAsset(d.asset, d.filename)
+
+				mu.Lock()
+				w.Write(out.Bytes())
+				results = append(results, assetResult{assetDeploy: d, err: err})
+				failed = failed || err != nil
+				mu.Unlock()
+			}
+		}(group)
 	}
+	wg.Wait()
+	return results
 }
 
-func init() {
-	rootCmd.AddCommand(deployAssetsCmd)
+// groupByDeployPath splits the deploys into groups sharing a deploy location under
+// root, in the order each location first appears
+func groupByDeployPath(root string, deploys []assetDeploy) [][]assetDeploy {
+	var groups [][]assetDeploy
+	index := map[string]int{}
+	for _, d := range deploys {
+		deployPath := filepath.Join(root, d.asset.DeployLocation)
+		i, ok := index[deployPath]
+		if !ok {
+			i = len(groups)
+			index[deployPath] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], d)
+	}
+	return groups
+}
 
-	// Here you will define your flags and configuration settings.
-	deployAssetsCmd.Flags().StringVarP(&filter, "filter", "f", "", "-f \"asset1,asset2\"")
+// deployerWritingTo returns a copy of deployer that writes its progress to w, still
+// auditing and recording metrics as deployer does
+func deployerWritingTo(deployer *slarty.Deployer, w io.Writer) *slarty.Deployer {
+	d := *deployer
+	printer := &slarty.PrintObserver{Out: w, Err: w}
+	if o, ok := deployer.Observer.(*deployObserver); ok {
+		d.Observer = &deployObserver{PrintObserver: printer, root: o.root, artifactConfig: o.artifactConfig, recorder: o.recorder}
+	} else {
+		d.Observer = printer
+	}
+	return &d
 }
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestFindAssets(t *testing.T) {
+	repo := slarty.NewLocalRepositoryAdapter(t.TempDir())
+	if err := repo.StoreArtifact(strings.NewReader("fonts"), "fonts-1.0.tar.gz"); err != nil {
+		t.Fatalf("Failed to store asset: %v", err)
+	}
+
+	fonts := slarty.Asset{Name: "fonts", Filename: "fonts-1.0.tar.gz", DeployLocation: "fonts"}
+	deploys, err := findAssets(repo, []slarty.Asset{fonts}, false)
+	if err != nil || len(deploys) != 1 || deploys[0].filename != "fonts-1.0.tar.gz" {
+		t.Fatalf("Expected the stored asset to be found, got %+v, %v", deploys, err)
+	}
+
+	icons := slarty.Asset{Name: "icons", Filename: "icons-2.0.tar.gz", DeployLocation: "icons"}
+	if _, err := findAssets(repo, []slarty.Asset{fonts, icons}, false); !errors.Is(err, slarty.ErrArtifactNotFound) || !strings.Contains(err.Error(), "asset icons-2.0.tar.gz") {
+		t.Errorf("Expected the missing asset to be reported, got %v", err)
+	}
+}
+
+func TestDeployAllAssets(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := slarty.NewLocalRepositoryAdapter(filepath.Join(tempDir, "repo"))
+	config := &slarty.ArtifactsConfig{RootDirectory: tempDir}
+	unpack := false
+
+	var deploys []assetDeploy
+	for _, name := range []string{"alpha", "beta", "gamma", "delta"} {
+		filename := name + ".dat"
+		if err := repo.StoreArtifact(strings.NewReader(name+" data"), filename); err != nil {
+			t.Fatalf("Failed to store asset: %v", err)
+		}
+		asset := slarty.Asset{Name: name, Filename: filename, DeployLocation: "data", Unpack: &unpack}
+		deploys = append(deploys, assetDeploy{asset: asset, filename: filename})
+	}
+
+	var out strings.Builder
+	results := deployAllAssets(&out, (&rootOptions{}).newDeployer(config, repo, nil), deploys, 3)
+	if len(results) != len(deploys) {
+		t.Fatalf("Expected a result for every asset, got %+v", results)
+	}
+	for _, d := range deploys {
+		data, err := os.ReadFile(filepath.Join(tempDir, "data", d.filename))
+		if err != nil || string(data) != d.asset.Name+" data" {
+			t.Errorf("Expected %s to be deployed, got %q, %v", d.asset.Name, data, err)
+		}
+		// Each asset's lines are written together
+		expected := fmt.Sprintf("Found asset %s (%s)\n - Downloaded asset to %s\n", d.asset.Name, d.filename, filepath.Join(tempDir, "data", d.filename))
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected the output of %s in one piece, got:\n%s", d.asset.Name, out.String())
+		}
+	}
+
+	// After a failure no more assets are started
+	missing := assetDeploy{asset: slarty.Asset{Name: "missing", Filename: "missing.dat", DeployLocation: "data", Unpack: &unpack}, filename: "missing.dat"}
+	results = deployAllAssets(io.Discard, (&rootOptions{}).newDeployer(config, repo, nil), append([]assetDeploy{missing}, deploys...), 1)
+	if len(results) != 1 || !errors.Is(results[0].err, slarty.ErrArtifactNotFound) || !strings.Contains(results[0].err.Error(), "missing.dat") {
+		t.Errorf("Expected only the failed asset to be deployed, got %+v", results)
+	}
+
+	// What the deployer reports goes to w with the rest of the asset's output
+	mode := slarty.Asset{Name: "moded", Filename: "alpha.dat", DeployLocation: "moded", Unpack: &unpack, Mode: "0640"}
+	out.Reset()
+	results = deployAllAssets(&out, (&rootOptions{}).newDeployer(config, repo, nil), []assetDeploy{{asset: mode, filename: "alpha.dat"}}, 1)
+	if len(results) != 1 || results[0].err != nil {
+		t.Fatalf("Expected the asset to be deployed, got %+v", results)
+	}
+	if !strings.Contains(out.String(), " - Set owner, group and mode\n") {
+		t.Errorf("Expected the permissions message in the asset's output, got:\n%s", out.String())
+	}
+}
+
+func TestGroupByDeployPath(t *testing.T) {
+	deploy := func(name, location string) assetDeploy {
+		return assetDeploy{asset: slarty.Asset{Name: name, DeployLocation: location}}
+	}
+	deploys := []assetDeploy{deploy("a", "data"), deploy("b", "web"), deploy("c", "./data"), deploy("d", "data/sub")}
+
+	var got [][]string
+	for _, group := range groupByDeployPath("/srv/app", deploys) {
+		var names []string
+		for _, d := range group {
+			names = append(names, d.asset.Name)
+		}
+		got = append(got, names)
+	}
+	expected := [][]string{{"a", "c"}, {"b"}, {"d"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected groups %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// diffOptions are the flags of the diff command
+type diffOptions struct {
+	root    *rootOptions
+	content bool
+	json    bool
+	variant string
+}
+
+// newDiffCmd creates the diff command
+func newDiffCmd(root *rootOptions) *cobra.Command {
+	opts := &diffOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "diff <artifact> <hashA> <hashB>",
+		Short: "Show the files that differ between two builds of an artifact",
+		Long: `Downloads the archives of an artifact built from two hashes, such as the one
+running on a server and the one about to replace it, and lists the files added,
+removed and changed between them. A file is changed when its contents, type or
+permissions differ. With --content, the changes to text files up to 1 MiB are shown
+as unified diffs as well. The archives are extracted to .slarty/tmp and removed
+afterwards.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runDiff(cmd, args, opts)
+		},
+		Args:              cobra.ExactArgs(3),
+		ValidArgsFunction: root.completeDiffArgs,
+	}
+
+	cmd.Flags().BoolVar(&opts.content, "content", false, "show unified diffs of the changed text files")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "output results as JSON")
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+
+	return cmd
+}
+
+func runDiff(cmd *cobra.Command, args []string, opts *diffOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := artifactConfig.ApplyVariant(opts.variant); err != nil {
+		log.Fatalln(err)
+	}
+
+	// Create a repository adapter
+	repoAdapter, err := opts.root.openRepository(artifactConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	artifact, err := artifactConfig.GetArtifactConfig(args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if artifact.IsDocker() {
+		log.Fatalf("%s is a docker artifact, compare its images with docker instead", artifact.Name)
+	}
+
+	var names [2]string
+	for i, hash := range args[1:] {
+		if names[i], err = slarty.ArtifactNameForHash(*artifact, hash, artifactConfig); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	changes, err := opts.root.diffArtifacts(repoAdapter, artifactConfig, names[0], names[1], opts.content)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if opts.json {
+		out, err := json.MarshalIndent(struct {
+			Artifact string              `json:"artifact"`
+			From     string              `json:"from"`
+			To       string              `json:"to"`
+			Changes  []slarty.FileChange `json:"changes"`
+		}{artifact.Name, names[0], names[1], changes}, "", "  ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	printFileChanges(os.Stdout, names[0], names[1], changes)
+}
+
+// diffArtifacts downloads and extracts the archives from and to and compares their
+// files, with content diffs of changed text files if content is set
+func (o *rootOptions) diffArtifacts(repoAdapter slarty.RepositoryAdapter, artifactConfig *slarty.ArtifactsConfig, from, to string, content bool) ([]slarty.FileChange, error) {
+	tempDir, err := slarty.TempDirectory(artifactConfig.RootDirectory)
+	if err != nil {
+		return nil, err
+	}
+	workDir, err := os.MkdirTemp(tempDir, "slarty-diff-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	var dirs [2]string
+	for i, name := range []string{from, to} {
+		exists, err := repoAdapter.ArtifactExists(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if %s exists in repository: %w", name, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", slarty.ErrArtifactNotFound, name)
+		}
+
+		archivePath := filepath.Join(workDir, fmt.Sprintf("archive-%d", i))
+		if err := slarty.RetrieveArtifactFile(repoAdapter, name, archivePath); err != nil {
+			return nil, fmt.Errorf("failed to retrieve %s from repository: %w", name, err)
+		}
+		dirs[i] = filepath.Join(workDir, fmt.Sprintf("files-%d", i))
+		if err := os.Mkdir(dirs[i], 0755); err != nil {
+			return nil, err
+		}
+		if err := extractArchive(archivePath, dirs[i], artifactConfig.Extraction, o.maxFileBytes); err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+	}
+
+	return slarty.DiffDirectories(dirs[0], dirs[1], content)
+}
+
+// printFileChanges lists the changes from one archive to another, with their content
+// diffs, and a count of each kind
+func printFileChanges(w io.Writer, from, to string, changes []slarty.FileChange) {
+	fmt.Fprintf(w, "Comparing %s with %s\n\n", from, to)
+
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.Change]++
+		switch change.Change {
+		case slarty.FileAdded:
+			fmt.Fprintf(w, " A %s (%s)\n", change.Path, formatBytes(change.NewSize))
+		case slarty.FileRemoved:
+			fmt.Fprintf(w, " D %s\n", change.Path)
+		default:
+			fmt.Fprintf(w, " M %s (%s -> %s)\n", change.Path, formatBytes(change.OldSize), formatBytes(change.NewSize))
+		}
+		if change.Diff != "" {
+			fmt.Fprintf(w, "\n%s\n", change.Diff)
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(w, " No files differ")
+	}
+
+	fmt.Fprintf(w, "\n%d added, %d removed, %d changed\n", counts[slarty.FileAdded], counts[slarty.FileRemoved], counts[slarty.FileChanged])
+}
+
+// completeDiffArgs completes the artifact argument of diff
+func (o *rootOptions) completeDiffArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names, _ := o.completeArtifactNames(cmd, args, toComplete)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/dstockto/slarty/slarty/archive"
+)
+
+func TestDiffCommand(t *testing.T) {
+	cmd := newDiffCmd(&rootOptions{})
+	if cmd.Flags().Lookup("content") == nil || cmd.Flags().Lookup("json") == nil {
+		t.Error("diff command should have 'content' and 'json' flags")
+	}
+	if err := cmd.Args(cmd, []string{"web", "abc"}); err == nil {
+		t.Error("Expected diff to need an artifact and two hashes")
+	}
+}
+
+func TestDiffArtifacts(t *testing.T) {
+	tempDir := t.TempDir()
+	config := &slarty.ArtifactsConfig{
+		RootDirectory: tempDir,
+		Artifacts:     []slarty.ArtifactConfig{{Name: "web", ArtifactPrefix: "web"}},
+	}
+	repo := slarty.NewLocalRepositoryAdapter(filepath.Join(tempDir, "repo"))
+
+	// Store a build of web for each set of files, named after its hash
+	store := func(hash string, files map[string]string) string {
+		t.Helper()
+		sourceDir := filepath.Join(tempDir, "source-"+hash)
+		for name, content := range files {
+			path := filepath.Join(sourceDir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		name, err := slarty.ArtifactNameForHash(config.Artifacts[0], hash, config)
+		if err != nil {
+			t.Fatalf("ArtifactNameForHash failed: %v", err)
+		}
+		archivePath := filepath.Join(tempDir, name)
+		if err := archive.CreateFile(archive.TarGz, archivePath, []archive.Source{{Dir: sourceDir}}, archive.WriteOptions{}); err != nil {
+			t.Fatalf("Failed to create archive: %v", err)
+		}
+		file, err := os.Open(archivePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		if err := repo.StoreArtifact(file, name); err != nil {
+			t.Fatalf("Failed to store archive: %v", err)
+		}
+		return name
+	}
+	from := store("1111111", map[string]string{"index.html": "<p>old</p>\n", "old.js": "old()"})
+	to := store("2222222", map[string]string{"index.html": "<p>new</p>\n", "js/app.js": "app()"})
+
+	changes, err := (&rootOptions{}).diffArtifacts(repo, config, from, to, true)
+	if err != nil {
+		t.Fatalf("diffArtifacts failed: %v", err)
+	}
+
+	var out strings.Builder
+	printFileChanges(&out, from, to, changes)
+	for _, expected := range []string{
+		"Comparing web-1111111.tar.gz with web-2222222.tar.gz",
+		" A js/app.js (5 B)",
+		" M index.html (11 B -> 11 B)",
+		"-<p>old</p>\n+<p>new</p>",
+		" D old.js",
+		"1 added, 1 removed, 1 changed",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in the output, got:\n%s", expected, out.String())
+		}
+	}
+
+	// Nothing is left behind in the temp directory
+	entries, err := os.ReadDir(filepath.Join(tempDir, slarty.WorkDirName, "tmp"))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected the temp directory to be empty, got %d entries (%v)", len(entries), err)
+	}
+
+	if _, err := (&rootOptions{}).diffArtifacts(repo, config, from, "web-3333333.tar.gz", false); !errors.Is(err, slarty.ErrArtifactNotFound) {
+		t.Errorf("Expected a missing archive to be ErrArtifactNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+// maxDirtyFiles is how many unstaged files are listed for an artifact
+const maxDirtyFiles = 5
+
+// checkDirtyTree applies the dirty_tree policy to artifacts whose directories have
+// unstaged changes, which their names do not reflect, so a build or deploy does not
+// silently ship code other than what it is named after. Warnings are written to w,
+// and an error is returned for the fail policy. With --dirty the names include the
+// changes, so there is nothing to check.
+func checkDirtyTree(w io.Writer, artifactConfig *slarty.ArtifactsConfig, artifacts []slarty.ArtifactConfig) error {
+	policy := artifactConfig.DirtyTreePolicy()
+	if err := slarty.ValidateDirtyTreePolicy(policy); err != nil {
+		return err
+	}
+	if artifactConfig.HashWorkingTree || policy == slarty.DirtyTreeIgnore {
+		return nil
+	}
+
+	var dirty []string
+	for _, artifact := range artifacts {
+		files, err := slarty.UnstagedChanges(artifactConfig.RootDirectory, artifact.Directories)
+		if err != nil {
+			return fmt.Errorf("Failed to check %s for unstaged changes: %w", artifact.Name, err)
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		listed := files
+		if len(listed) > maxDirtyFiles {
+			listed = listed[:maxDirtyFiles]
+		}
+		message := fmt.Sprintf("%s has unstaged changes that are not in its artifact name: %s", artifact.Name, strings.Join(listed, ", "))
+		if more := len(files) - len(listed); more > 0 {
+			message += fmt.Sprintf(" and %d more", more)
+		}
+		if policy == slarty.DirtyTreeFail {
+			dirty = append(dirty, message)
+			continue
+		}
+		fmt.Fprintf(w, "WARNING: %s\n", message)
+	}
+
+	if len(dirty) > 0 {
+		return fmt.Errorf("%s\nStage or stash the changes, or use --dirty to name artifacts after them", strings.Join(dirty, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestCheckDirtyTree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+	runGit := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	write := func(name, content string) {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	runGit("init")
+	runGit("config", "user.email", "test@example.com")
+	runGit("config", "user.name", "Test User")
+	for i := 0; i < 7; i++ {
+		write(filepath.Join("web", string(rune('a'+i))+".js"), "original")
+	}
+	write("api/main.go", "package main")
+	runGit("add", ".")
+	runGit("commit", "-m", "Initial commit")
+
+	artifacts := []slarty.ArtifactConfig{
+		{Name: "web", Directories: []string{"web"}},
+		{Name: "api", Directories: []string{"api"}},
+	}
+	check := func(policy string, hashDirty bool) (string, error) {
+		var stderr bytes.Buffer
+		config := &slarty.ArtifactsConfig{RootDirectory: tempDir, DirtyTree: policy, HashWorkingTree: hashDirty}
+		err := checkDirtyTree(&stderr, config, artifacts)
+		return stderr.String(), err
+	}
+
+	if out, err := check("", false); err != nil || out != "" {
+		t.Fatalf("Expected a clean tree to pass quietly, got %q, %v", out, err)
+	}
+
+	for i := 0; i < 7; i++ {
+		write(filepath.Join("web", string(rune('a'+i))+".js"), "changed")
+	}
+
+	// The default policy warns, listing the first few files
+	out, err := check("", false)
+	if err != nil {
+		t.Fatalf("Expected the warn policy not to fail, got %v", err)
+	}
+	expected := "WARNING: web has unstaged changes that are not in its artifact name: web/a.js, web/b.js, web/c.js, web/d.js, web/e.js and 2 more\n"
+	if out != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+
+	out, err = check(slarty.DirtyTreeFail, false)
+	if err == nil || !strings.Contains(err.Error(), "web has unstaged changes") || strings.Contains(err.Error(), "api") {
+		t.Errorf("Expected the fail policy to fail for web only, got %v", err)
+	}
+	if out != "" {
+		t.Errorf("Expected no warnings with the fail policy, got %q", out)
+	}
+
+	if out, err := check(slarty.DirtyTreeIgnore, false); err != nil || out != "" {
+		t.Errorf("Expected the ignore policy to pass quietly, got %q, %v", out, err)
+	}
+
+	// --dirty puts the changes in the names
+	if out, err := check(slarty.DirtyTreeFail, true); err != nil || out != "" {
+		t.Errorf("Expected --dirty to skip the check, got %q, %v", out, err)
+	}
+
+	// Staging the changes puts them in the names too
+	runGit("add", ".")
+	if out, err := check(slarty.DirtyTreeFail, false); err != nil || out != "" {
+		t.Errorf("Expected staged changes to pass, got %q, %v", out, err)
+	}
+}
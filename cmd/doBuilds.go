@@ -22,280 +22,280 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	"fmt"
 	"github.com/dstockto/slarty/slarty"
 	"github.com/spf13/cobra"
-	"io"
 	"log"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
+	"time"
 )
 
-var (
-	force    bool
-	failFast bool
-)
+// doBuildsOptions are the flags of the do-builds command
+type doBuildsOptions struct {
+	root           *rootOptions
+	selection      selectionOptions
+	force          bool
+	failFast       bool
+	cache          bool
+	reproducible   bool
+	allowOverwrite bool
+	markLatest     bool
+	allowEmpty     bool
+	// labels are the --label key=value flags, attached to every artifact stored
+	labels  []string
+	variant string
+	dirty   bool
+}
 
-// doBuildsCmd represents the doBuilds command
-var doBuildsCmd = &cobra.Command{
-	Use:   "do-builds",
-	Short: "Build artifacts that don't exist in the repository",
-	Long: `Builds artifacts that don't exist in the repository.
+// newDoBuildsCmd creates the do-builds command
+func newDoBuildsCmd(root *rootOptions) *cobra.Command {
+	opts := &doBuildsOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "do-builds",
+		Short: "Build artifacts that don't exist in the repository",
+		Long: `Builds artifacts that don't exist in the repository.
 If an artifact already exists in the repository, it will not be built unless the --force flag is used.
 The command will execute the build command for each artifact, archive the output directory,
 and store the artifact in the repository.
 By default it attempts every build and reports which ones failed at the end; use --fail-fast
-to stop after the first failure.`,
-	Run: runDoBuilds,
+to stop after the first failure. With --cache each archive is also kept in a local
+build cache, from which the restore command can repopulate output directories.
+With --reproducible, archives leave out timestamps and file ownership so identical
+output always produces a byte-identical archive. In a repository marked immutable an
+existing artifact is never replaced, even with --force, unless --allow-overwrite is used.
+With --mark-latest, a latest pointer recording each artifact's name, hash and commit
+is written to the repository, so do-deploys --latest can deploy it without a hash.
+Each --label key=value is attached to the stored artifacts, as S3 object tags or in a
+file next to each artifact in a local repository.
+An archive with no files, or smaller than the artifact's min_size, is never stored,
+since it would stop that hash from being rebuilt; --allow-empty stores it anyway.
+Use --filter to limit the build to matching artifacts and --exclude to leave matching
+artifacts out.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runDoBuilds(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "application3,application4", root.completeArtifactNames)
+	cmd.Flags().BoolVarP(&opts.force, "force", "", false, "Force build even if artifact exists")
+	cmd.Flags().BoolVar(&opts.failFast, "fail-fast", false, "Stop after the first failed build")
+	cmd.Flags().BoolVar(&opts.cache, "cache", false, "also keep built archives in the local build cache")
+	cmd.Flags().BoolVar(&opts.reproducible, "reproducible", false, "build byte-identical archives for identical output")
+	cmd.Flags().BoolVar(&opts.allowOverwrite, "allow-overwrite", false, "replace existing artifacts even when the repository is immutable")
+	cmd.Flags().BoolVar(&opts.allowEmpty, "allow-empty", false, "store archives with no files or smaller than the artifact's min_size")
+	cmd.Flags().StringArrayVar(&opts.labels, "label", nil, "attach a key=value label, such as branch=main, to the stored artifacts (repeatable)")
+	cmd.Flags().BoolVar(&opts.markLatest, "mark-latest", false, "write a latest pointer for each artifact to the repository for do-deploys --latest")
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+	cmd.Flags().BoolVar(&opts.dirty, "dirty", false, "name artifacts after the working tree, including unstaged changes, marking changed ones -dirty")
+
+	return cmd
 }
 
-func runDoBuilds(cmd *cobra.Command, args []string) {
+func runDoBuilds(cmd *cobra.Command, args []string, opts *doBuildsOptions) {
+	labels, err := slarty.ParseLabels(opts.labels)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	// Read the artifacts configuration
-	artifactConfig, err := slarty.ReadArtifactsJson(artifactsJson)
+	artifactConfig, err := opts.root.readConfig()
 	if err != nil {
 		log.Fatalln(err)
 	}
+	if err := artifactConfig.ApplyVariant(opts.variant); err != nil {
+		log.Fatalln(err)
+	}
+	artifactConfig.HashWorkingTree = opts.dirty
 
 	// Create a repository adapter
-	repoAdapter, err := slarty.NewRepositoryAdapter(artifactConfig, local)
+	repoAdapter, err := opts.root.openRepository(artifactConfig)
 	if err != nil {
 		log.Fatalln(err)
 	}
-
-	// Parse the filter flag
-	var filters []string
-	if filter != "" {
-		filters = strings.Split(filter, ",")
+	if opts.allowOverwrite {
+		repoAdapter = slarty.AllowOverwrite(repoAdapter)
 	}
 
 	// Get the artifacts based on the filter
-	artifacts := artifactConfig.GetByArtifactsByNameWithFilter(filters)
+	artifacts := artifactConfig.SelectArtifacts(opts.selection.selection())
 
 	if len(artifacts) == 0 {
 		fmt.Println("No artifacts found")
 		return
 	}
+	if err := checkDirtyTree(os.Stderr, artifactConfig, artifacts); err != nil {
+		log.Fatalln(err)
+	}
 
 	// Execute the builds; exit non-zero if any failed.
-	if failed := executeBuilds(artifacts, artifactConfig, repoAdapter); len(failed) > 0 {
+	if failed := executeBuilds(opts, artifacts, artifactConfig, repoAdapter, labels); len(failed) > 0 {
+		opts.root.flushAudit()
 		os.Exit(1)
 	}
 }
 
-// executeBuilds determines which artifacts need building, runs the builds, and
-// stores the results in the repository. It prints progress and a final summary,
-// and returns the names of any artifacts that failed to build. It does not call
-// os.Exit so it can be exercised by tests.
-func executeBuilds(artifacts []slarty.ArtifactConfig, artifactConfig *slarty.ArtifactsConfig, repoAdapter slarty.RepositoryAdapter) []string {
-	// Track which artifacts need to be built
-	buildNeeded := make(map[string]bool)
-	artifactNames := make(map[string]string)
-
-	// Check if each artifact exists in the repository
-	for _, artifact := range artifacts {
-		// Get the artifact name
-		artifactName, err := slarty.GetArtifactName(artifact.Name, artifactConfig)
-		if err != nil {
-			log.Fatalln(err)
-		}
+// executeBuilds builds the artifacts that need it with Builder.BuildAll, printing
+// progress and a final summary, and returns the names of any artifacts that failed to
+// build. The labels are attached to every artifact stored. With --force every
+// artifact is built, even if it is already stored. It does not call os.Exit so it
+// can be exercised by tests.
+func executeBuilds(opts *doBuildsOptions, artifacts []slarty.ArtifactConfig, artifactConfig *slarty.ArtifactsConfig, repoAdapter slarty.RepositoryAdapter, labels map[string]string) []string {
+	started := time.Now()
+	recorder := slarty.NewMetricsRecorder()
+	builder := newBuilder(opts, artifactConfig, repoAdapter)
+	builder.Observer = slarty.Observers{
+		&buildObserver{PrintObserver: &slarty.PrintObserver{}, root: opts.root, artifactConfig: artifactConfig, recorder: recorder},
+		slarty.NewEventObserver(opts.root.events, "do-builds"),
+	}
 
-		artifactNames[artifact.Name] = artifactName
+	results, err := builder.BuildAll(artifacts, slarty.BuildOptions{
+		Force:      opts.force,
+		FailFast:   opts.failFast,
+		MarkLatest: opts.markLatest,
+		Labels:     labels,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-		// Check if the artifact exists in the repository
-		exists, err := repoAdapter.ArtifactExists(artifactName)
-		if err != nil {
-			log.Fatalln(err)
+	summary := slarty.RunSummary{Command: "do-builds", Application: artifactConfig.Application}
+	var failedBuilds []string
+	var cacheHits int
+	for _, result := range results {
+		runResult := slarty.RunResult{
+			Name:         result.Artifact.Name,
+			ArtifactName: result.ArtifactName,
+			Status:       result.Status,
+			Duration:     result.Duration,
 		}
-
-		buildNeeded[artifact.Name] = force || !exists
-
-		// Display if build is needed
-		buildStatus := "NO"
-		if buildNeeded[artifact.Name] {
-			buildStatus = "YES"
+		if result.Err != nil {
+			runResult.Error = result.Err.Error()
+			failedBuilds = append(failedBuilds, result.Artifact.Name)
 		}
-		fmt.Printf("Doing build for %s - %s\n", artifact.Name, buildStatus)
-	}
-
-	// Count how many builds are needed
-	totalBuildsNeeded := 0
-	for _, artifact := range artifacts {
-		if buildNeeded[artifact.Name] {
-			totalBuildsNeeded++
+		summary.Results = append(summary.Results, runResult)
+		if result.AlreadyStored {
+			cacheHits++
 		}
 	}
 
-	successfulBuilds := 0
-	var failedBuilds []string
+	summary.Duration = time.Since(started).Round(time.Millisecond)
+	sendNotification(artifactConfig, summary)
 
-	// Execute builds for artifacts that need it
-	for _, artifact := range artifacts {
-		if !buildNeeded[artifact.Name] {
-			continue
-		}
+	recorder.Observe("cache_hits", float64(cacheHits), nil)
+	recorder.Observe("cache_misses", float64(len(results)-cacheHits), nil)
+	if len(results) > 0 {
+		recorder.Observe("cache_hit_ratio", float64(cacheHits)/float64(len(results)), nil)
+	}
+	recorder.Observe("builds_failed", float64(len(failedBuilds)), nil)
+	recorder.ObserveDuration("run_duration_seconds", time.Since(started), map[string]string{"command": "do-builds"})
+	pushRunMetrics(artifactConfig, recorder)
 
-		fmt.Printf("\nBeginning build for %s application\n", artifact.Name)
-		fmt.Println(strings.Repeat("-", 40+len(artifact.Name)))
+	return failedBuilds
+}
 
-		if err := buildAndStoreArtifact(artifact, artifactConfig, repoAdapter, artifactNames[artifact.Name]); err != nil {
-			fmt.Printf("Build failed for %s: %v\n", artifact.Name, err)
-			failedBuilds = append(failedBuilds, artifact.Name)
-			if failFast {
-				fmt.Println("\n-- Stopping early because --fail-fast is set")
-				break
+// newBuilder returns the builder for a do-builds run, set up from its flags
+func newBuilder(opts *doBuildsOptions, artifactConfig *slarty.ArtifactsConfig, repoAdapter slarty.RepositoryAdapter) *slarty.Builder {
+	builder := slarty.NewBuilder(artifactConfig, repoAdapter)
+	builder.Runner = opts.root.commandRunner()
+	builder.Namer = opts.root.artifactName
+	builder.Reproducible = opts.reproducible
+	builder.AllowEmpty = opts.allowEmpty
+	builder.SkipSpaceCheck = opts.root.noSpaceCheck
+	if opts.cache {
+		builder.OpenCache = func() (slarty.RepositoryAdapter, error) {
+			cache, err := openBuildCache(artifactConfig)
+			if err != nil {
+				return nil, err
 			}
-			continue
+			return cache, nil
 		}
-
-		successfulBuilds++
-
-		// Display progress
-		fmt.Printf(" %d/%d [", successfulBuilds, totalBuildsNeeded)
-		progressWidth := 28
-		completedWidth := int(float64(successfulBuilds) / float64(totalBuildsNeeded) * float64(progressWidth))
-		fmt.Print(strings.Repeat("=", completedWidth))
-		if completedWidth < progressWidth {
-			fmt.Print(">")
-			fmt.Print(strings.Repeat("-", progressWidth-completedWidth-1))
+		if dir, err := applicationCacheDir(artifactConfig); err == nil {
+			builder.SpaceDirs = append(builder.SpaceDirs, dir)
 		}
-		fmt.Printf("] %3d%%\n", int(float64(successfulBuilds)/float64(totalBuildsNeeded)*100))
-		fmt.Printf("-- Saved %s to repository.\n", artifactNames[artifact.Name])
 	}
-
-	// Print a summary, listing exactly which builds failed.
-	if len(failedBuilds) > 0 {
-		fmt.Printf("\nBuilds failed for %d/%d artifacts:\n", len(failedBuilds), totalBuildsNeeded)
-		for _, name := range failedBuilds {
-			fmt.Printf(" - %s\n", name)
-		}
-	} else {
-		fmt.Printf("\nBuilds succeeded for %d artifacts\n", successfulBuilds)
+	if resolved, err := artifactConfig.ResolvedRepository(opts.root.local); err == nil && resolved.Adapter == "local" {
+		builder.SpaceDirs = append(builder.SpaceDirs, resolved.Options.Root)
 	}
-
-	return failedBuilds
+	return builder
 }
 
-// buildAndStoreArtifact runs an artifact's build command, archives its output
-// directory into a tar.gz, and stores the result in the repository.
-func buildAndStoreArtifact(artifact slarty.ArtifactConfig, artifactConfig *slarty.ArtifactsConfig, repoAdapter slarty.RepositoryAdapter, artifactName string) error {
-	// Execute the build command
-	cmd := exec.Command("sh", "-c", artifact.Command)
-	cmd.Dir = artifactConfig.RootDirectory
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// buildObserver prints a build's progress, and audits each build and upload, records
+// their metrics and keeps the build history
+type buildObserver struct {
+	*slarty.PrintObserver
+	root           *rootOptions
+	artifactConfig *slarty.ArtifactsConfig
+	recorder       *slarty.MetricsRecorder
+	// started are the artifacts being built by their artifact name, so uploads can be
+	// told apart
+	started map[string]slarty.ArtifactConfig
+}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("build command failed: %w", err)
+func (o *buildObserver) OnArtifactStart(artifact slarty.ArtifactConfig, artifactName string) {
+	o.PrintObserver.OnArtifactStart(artifact, artifactName)
+	if o.started == nil {
+		o.started = make(map[string]slarty.ArtifactConfig)
 	}
+	o.started[artifactName] = artifact
+}
 
-	fmt.Printf("\n Build succeeded for %s\n", artifact.Name)
-
-	// Create a temporary tar.gz file
-	tempTarGzFile, err := os.CreateTemp("", "slarty-*.tar.gz")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary tar.gz file: %w", err)
+func (o *buildObserver) OnBuildComplete(artifact slarty.ArtifactConfig, duration time.Duration, err error) {
+	o.PrintObserver.OnBuildComplete(artifact, duration, err)
+	if err == nil {
+		o.recorder.ObserveDuration("build_duration_seconds", duration, map[string]string{"artifact": artifact.Name})
 	}
-	tempTarGzPath := tempTarGzFile.Name()
-	tempTarGzFile.Close() // Close the file so we can reopen it for archiving
-	defer os.Remove(tempTarGzPath)
+}
 
-	// Archive the output directory
-	if err := createTarGz(filepath.Join(artifactConfig.RootDirectory, artifact.OutputDirectory), tempTarGzPath); err != nil {
-		return fmt.Errorf("failed to archive output directory: %w", err)
+func (o *buildObserver) OnUploadComplete(artifactName string, size int64, elapsed time.Duration, err error) {
+	o.PrintObserver.OnUploadComplete(artifactName, size, elapsed, err)
+	artifact := o.started[artifactName]
+	o.root.audit(o.artifactConfig, artifactAudit(o.artifactConfig, slarty.AuditStore, "do-builds", artifact, artifactName, err))
+	if err != nil {
+		return
 	}
-
-	// Store the artifact in the repository
-	if err := repoAdapter.StoreArtifact(tempTarGzPath, artifactName); err != nil {
-		return fmt.Errorf("failed to store artifact in repository: %w", err)
+	labels := map[string]string{"artifact": artifact.Name}
+	if !artifact.IsDocker() {
+		o.recorder.Observe("archive_size_bytes", float64(size), labels)
 	}
-
-	return nil
+	o.recorder.ObserveDuration("upload_duration_seconds", elapsed, labels)
 }
 
-// createTarGz archives the contents of a directory into a tar.gz file
-func createTarGz(sourceDir, tarGzPath string) error {
-	// Create the tar.gz file
-	tarGzFile, err := os.Create(tarGzPath)
-	if err != nil {
-		return fmt.Errorf("failed to create tar.gz file: %w", err)
+func (o *buildObserver) OnArtifactComplete(result slarty.BuildResult, built, needed int) {
+	artifact := result.Artifact
+	if _, ok := o.started[result.ArtifactName]; !ok {
+		// Nothing was built
+		o.PrintObserver.OnArtifactComplete(result, built, needed)
+		return
 	}
-	defer tarGzFile.Close()
-
-	// Create a gzip writer
-	gzipWriter := gzip.NewWriter(tarGzFile)
-	defer gzipWriter.Close()
-
-	// Create a tar writer
-	tarWriter := tar.NewWriter(gzipWriter)
-	defer tarWriter.Close()
-
-	// Walk the directory and add files to the archive
-	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Create a relative path for the file in the archive
-		relPath, err := filepath.Rel(sourceDir, path)
-		if err != nil {
-			return fmt.Errorf("failed to get relative path: %w", err)
-		}
-
-		// Create a tar header
-		header, err := tar.FileInfoHeader(info, "")
-		if err != nil {
-			return fmt.Errorf("failed to create tar header: %w", err)
-		}
-
-		// Set the name to the relative path
-		header.Name = relPath
-
-		// Skip directories themselves (we'll create them when needed)
-		if info.IsDir() {
-			// For directories, write the header and continue
-			if err := tarWriter.WriteHeader(header); err != nil {
-				return fmt.Errorf("failed to write directory header: %w", err)
-			}
-			return nil
-		}
-
-		// Write the header to the tar archive
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return fmt.Errorf("failed to write file header: %w", err)
-		}
-
-		// Open the source file
-		file, err := os.Open(path)
-		if err != nil {
-			return fmt.Errorf("failed to open source file: %w", err)
-		}
-		defer file.Close()
 
-		// Copy the file contents to the archive
-		_, err = io.Copy(tarWriter, file)
-		if err != nil {
-			return fmt.Errorf("failed to copy file to archive: %w", err)
+	o.root.audit(o.artifactConfig, artifactAudit(o.artifactConfig, slarty.AuditBuild, "do-builds", artifact, result.ArtifactName, result.Err))
+	if result.Err == nil {
+		if message := archiveSizeWarning(artifact, o.artifactConfig, result.Size); message != "" {
+			o.OnWarning(message)
 		}
-
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("failed to walk directory: %w", err)
+		recordBuild(o.artifactConfig, slarty.BuildRecord{
+			Artifact:     artifact.Name,
+			ArtifactName: result.ArtifactName,
+			Size:         result.Size,
+			Duration:     result.BuildDuration.Seconds(),
+			BuiltAt:      time.Now().UTC(),
+		})
 	}
-
-	return nil
+	o.PrintObserver.OnArtifactComplete(result, built, needed)
 }
 
-func init() {
-	rootCmd.AddCommand(doBuildsCmd)
-
-	// Here you will define your flags and configuration settings.
-	doBuildsCmd.Flags().StringVarP(&filter, "filter", "f", "", "-f \"application1,application2\"")
-	doBuildsCmd.Flags().BoolVarP(&force, "force", "", false, "Force build even if artifact exists")
-	doBuildsCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Stop after the first failed build")
+// archiveSizeWarning returns a warning when an archive is larger than its artifact's
+// warn_size, with the size of the last recorded build so any growth shows, or "" when
+// it is not
+func archiveSizeWarning(artifact slarty.ArtifactConfig, artifactConfig *slarty.ArtifactsConfig, size int64) string {
+	budget, err := artifact.SizeBudget()
+	if err != nil || !budget.OverWarning(size) {
+		return ""
+	}
+	message := fmt.Sprintf("the archive for %s is %s, over its warn_size of %s", artifact.Name, formatBytes(size), formatBytes(budget.Warn))
+	if last, ok := readLatestBuilds(artifactConfig)[artifact.Name]; ok && last.Size > 0 {
+		message += fmt.Sprintf(" (the last recorded build was %s)", formatBytes(last.Size))
+	}
+	return message
 }
@@ -1,46 +1,60 @@
 package cmd
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dstockto/slarty/slarty"
+	"github.com/dstockto/slarty/slarty/archive"
 	"github.com/spf13/cobra"
 )
 
 func TestDoBuildsCommand(t *testing.T) {
 	// Test that the do-builds command is properly initialized
-	if doBuildsCmd.Use != "do-builds" {
-		t.Errorf("Expected do-builds command Use to be 'do-builds', got '%s'", doBuildsCmd.Use)
+	cmd := newDoBuildsCmd(&rootOptions{})
+	if cmd.Use != "do-builds" {
+		t.Errorf("Expected do-builds command Use to be 'do-builds', got '%s'", cmd.Use)
 	}
 
-	if doBuildsCmd.Short == "" {
+	if cmd.Short == "" {
 		t.Error("do-builds command Short description should not be empty")
 	}
 
-	if doBuildsCmd.Long == "" {
+	if cmd.Long == "" {
 		t.Error("do-builds command Long description should not be empty")
 	}
 
-	if doBuildsCmd.Run == nil {
+	if cmd.Run == nil {
 		t.Error("do-builds command Run function should not be nil")
 	}
 }
 
 func TestDoBuildsCommandFlags(t *testing.T) {
 	// Test that the do-builds command has the expected flags
-	flags := doBuildsCmd.Flags()
+	cmd := newDoBuildsCmd(&rootOptions{})
+	flags := cmd.Flags()
 
 	// Check filter flag
 	if flags.Lookup("filter") == nil {
 		t.Error("do-builds command should have 'filter' flag")
 	}
 
+	// Check exclude flag
+	if flags.Lookup("exclude") == nil {
+		t.Error("do-builds command should have 'exclude' flag")
+	}
+
 	// Check force flag
 	if flags.Lookup("force") == nil {
 		t.Error("do-builds command should have 'force' flag")
@@ -50,6 +64,21 @@ func TestDoBuildsCommandFlags(t *testing.T) {
 	if flags.Lookup("fail-fast") == nil {
 		t.Error("do-builds command should have 'fail-fast' flag")
 	}
+
+	// Check reproducible flag
+	if flags.Lookup("reproducible") == nil {
+		t.Error("do-builds command should have 'reproducible' flag")
+	}
+
+	// Check allow-overwrite flag
+	if flags.Lookup("allow-overwrite") == nil {
+		t.Error("do-builds command should have 'allow-overwrite' flag")
+	}
+
+	// Check mark-latest flag
+	if flags.Lookup("mark-latest") == nil {
+		t.Error("do-builds command should have 'mark-latest' flag")
+	}
 }
 
 // buildTestSetup creates a temporary git repo with the given artifacts JSON
@@ -117,15 +146,19 @@ func buildTestSetup(t *testing.T, artifactsJSON string, dirs []string) (*slarty.
 
 // captureExecuteBuilds runs executeBuilds with stdout captured, returning the
 // failed-artifact slice and the captured output.
-func captureExecuteBuilds(t *testing.T, config *slarty.ArtifactsConfig, repo slarty.RepositoryAdapter) ([]string, string) {
+func captureExecuteBuilds(t *testing.T, opts *doBuildsOptions, config *slarty.ArtifactsConfig, repo slarty.RepositoryAdapter) ([]string, string) {
 	t.Helper()
-	artifacts := config.GetByArtifactsByNameWithFilter(nil)
+	artifacts := config.SelectArtifacts(slarty.Selection{})
+	labels, err := slarty.ParseLabels(opts.labels)
+	if err != nil {
+		t.Fatalf("ParseLabels failed: %v", err)
+	}
 
 	oldStdout := os.Stdout
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	failed := executeBuilds(artifacts, config, repo)
+	failed := executeBuilds(opts, artifacts, config, repo, labels)
 
 	w.Close()
 	os.Stdout = oldStdout
@@ -141,12 +174,9 @@ func TestExecuteBuildsReportsFailures(t *testing.T) {
 		{ "name": "bad", "directories": ["src/bad"], "command": "exit 1", "output_directory": "build/bad", "deploy_location": "deploy/bad", "artifact_prefix": "bad" }`
 	config, repo := buildTestSetup(t, artifacts, []string{"src/good", "src/bad", "build/good", "build/bad"})
 
-	oldForce, oldFailFast := force, failFast
-	defer func() { force, failFast = oldForce, oldFailFast }()
-	force = true // build regardless of repo state
-	failFast = false
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
 
-	failed, output := captureExecuteBuilds(t, config, repo)
+	failed, output := captureExecuteBuilds(t, opts, config, repo)
 
 	if len(failed) != 1 || failed[0] != "bad" {
 		t.Fatalf("Expected failed=[bad], got %v", failed)
@@ -172,12 +202,9 @@ func TestExecuteBuildsFailFastStopsEarly(t *testing.T) {
 		{ "name": "bad2", "directories": ["src/bad2"], "command": "echo SHOULD_NOT_RUN_BAD2", "output_directory": "build/bad2", "deploy_location": "d/bad2", "artifact_prefix": "bad2" }`
 	config, repo := buildTestSetup(t, artifacts, []string{"src/bad1", "src/bad2", "build/bad1", "build/bad2"})
 
-	oldForce, oldFailFast := force, failFast
-	defer func() { force, failFast = oldForce, oldFailFast }()
-	force = true
-	failFast = true
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true, failFast: true}
 
-	failed, output := captureExecuteBuilds(t, config, repo)
+	failed, output := captureExecuteBuilds(t, opts, config, repo)
 
 	if len(failed) != 1 || failed[0] != "bad1" {
 		t.Fatalf("Expected failed=[bad1] with fail-fast, got %v", failed)
@@ -191,6 +218,335 @@ func TestExecuteBuildsFailFastStopsEarly(t *testing.T) {
 	}
 }
 
+func TestExecuteBuildsSkipsIdenticalUpload(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "true", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/web", "build/web"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	failed, output := captureExecuteBuilds(t, opts, config, repo)
+	if len(failed) != 0 {
+		t.Fatalf("Expected the first build to succeed, got failures %v:\n%s", failed, output)
+	}
+	if strings.Contains(output, "skipped upload") {
+		t.Fatalf("Expected the first build to upload, got:\n%s", output)
+	}
+
+	// Rebuilding unchanged output with --force leaves the stored artifact alone
+	failed, output = captureExecuteBuilds(t, opts, config, repo)
+	if len(failed) != 0 {
+		t.Fatalf("Expected the forced rebuild to succeed, got failures %v:\n%s", failed, output)
+	}
+	if !strings.Contains(output, "already present, skipped upload") {
+		t.Errorf("Expected the identical upload to be skipped, got:\n%s", output)
+	}
+
+	// Changed output is uploaded again
+	if err := os.WriteFile(filepath.Join(config.RootDirectory, "build", "web", "f.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to change output: %v", err)
+	}
+	_, output = captureExecuteBuilds(t, opts, config, repo)
+	if strings.Contains(output, "skipped upload") {
+		t.Errorf("Expected changed output to be uploaded, got:\n%s", output)
+	}
+}
+
+func TestExecuteBuildsSizeBudget(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "head -c 65536 /dev/urandom > build/web/f.bin", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web", "max_size": "16K" },
+		{ "name": "api", "directories": ["src/api"], "command": "echo small > build/api/f.txt", "output_directory": "build/api", "deploy_location": "deploy/api", "artifact_prefix": "api", "max_size": "16K" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/web", "src/api", "build/web", "build/api"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	failed, output := captureExecuteBuilds(t, opts, config, repo)
+	if len(failed) != 1 || failed[0] != "web" {
+		t.Fatalf("Expected only web to fail its max_size, got failures %v:\n%s", failed, output)
+	}
+	if !strings.Contains(output, "larger than max_size") {
+		t.Errorf("Expected the size budget in the failure, got:\n%s", output)
+	}
+
+	stored, _ := filepath.Glob(filepath.Join(config.Repository.Options.Root, "web-*"))
+	if len(stored) != 0 {
+		t.Errorf("Expected the oversized archive not to be stored, found %v", stored)
+	}
+}
+
+func TestExecuteBuildsLabels(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "true", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/web", "build/web"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true, labels: []string{"branch=feature/login", "build=42"}}
+
+	if failed, output := captureExecuteBuilds(t, opts, config, repo); len(failed) != 0 {
+		t.Fatalf("Expected the build to succeed, got failures %v:\n%s", failed, output)
+	}
+	artifactName, err := slarty.GetArtifactName("web", config)
+	if err != nil {
+		t.Fatalf("GetArtifactName failed: %v", err)
+	}
+	labels, err := slarty.ArtifactLabels(repo, artifactName)
+	if err != nil || slarty.FormatLabels(labels) != "branch=feature/login, build=42" {
+		t.Errorf("Expected the stored artifact to be labelled, got %v (%v)", labels, err)
+	}
+}
+
+func TestExecuteBuildsShortHash(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "true", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/web", "build/web"})
+	config.HashLength = 10
+
+	opts := &doBuildsOptions{root: &rootOptions{}}
+	if failed, output := captureExecuteBuilds(t, opts, config, repo); len(failed) != 0 {
+		t.Fatalf("Expected the build to succeed, got failures %v:\n%s", failed, output)
+	}
+	fullHash, err := slarty.ArtifactHash("web", config)
+	if err != nil {
+		t.Fatalf("ArtifactHash failed: %v", err)
+	}
+	artifactName := "web-" + fullHash[:10] + ".tar.gz"
+	if exists, _ := repo.ArtifactExists(artifactName); !exists {
+		t.Fatalf("Expected the artifact to be stored as %s", artifactName)
+	}
+
+	// The full hash is kept with it, and the same code is found again without a
+	// collision
+	labels, err := slarty.ArtifactLabels(repo, artifactName)
+	if err != nil || labels["slarty-hash"] != fullHash {
+		t.Errorf("Expected the full hash as a label, got %v (%v)", labels, err)
+	}
+	if failed, output := captureExecuteBuilds(t, opts, config, repo); len(failed) != 0 || !strings.Contains(output, "Doing build for web - NO") {
+		t.Errorf("Expected the stored build to be found, got failures %v:\n%s", failed, output)
+	}
+}
+
+func TestExecuteBuildsArchiveFormat(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "echo built > build/web/index.html", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web", "archive": "zip" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/web", "build/web"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}}
+	if failed, output := captureExecuteBuilds(t, opts, config, repo); len(failed) != 0 {
+		t.Fatalf("Expected the build to succeed, got failures %v:\n%s", failed, output)
+	}
+	artifactName, err := slarty.GetArtifactName("web", config)
+	if err != nil || !strings.HasSuffix(artifactName, ".zip") {
+		t.Fatalf("Expected a .zip artifact name, got %s (%v)", artifactName, err)
+	}
+
+	// Deploys tell the format from the archive
+	destDir := filepath.Join(t.TempDir(), "dest")
+	if _, err := (&rootOptions{}).newDeployer(&slarty.ArtifactsConfig{RootDirectory: config.RootDirectory, Extraction: slarty.ExtractionLimits{}}, repo, nil).Extract(artifactName, destDir); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(destDir, "index.html"))
+	if err != nil || string(content) != "built\n" {
+		t.Errorf("Expected index.html from the zip archive, got %q (%v)", content, err)
+	}
+}
+
+func TestExecuteBuildsRefusesEmptyArchives(t *testing.T) {
+	artifacts := `
+		{ "name": "empty", "directories": ["src/empty"], "command": "rm -f build/empty/f.txt", "output_directory": "build/empty", "deploy_location": "deploy/empty", "artifact_prefix": "empty" },
+		{ "name": "tiny", "directories": ["src/tiny"], "command": "true", "output_directory": "build/tiny", "deploy_location": "deploy/tiny", "artifact_prefix": "tiny", "min_size": "1M" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/empty", "src/tiny", "build/empty", "build/tiny"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	failed, output := captureExecuteBuilds(t, opts, config, repo)
+	if len(failed) != 2 {
+		t.Fatalf("Expected both builds to fail, got failures %v:\n%s", failed, output)
+	}
+	for _, want := range []string{"nothing to archive", "archive is smaller than min_size", "--allow-empty"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+	stored, _ := filepath.Glob(filepath.Join(config.Repository.Options.Root, "*.tar.gz"))
+	if len(stored) != 0 {
+		t.Errorf("Expected nothing to be stored, found %v", stored)
+	}
+
+	// --allow-empty stores them anyway
+	opts.allowEmpty = true
+	if failed, output := captureExecuteBuilds(t, opts, config, repo); len(failed) != 0 {
+		t.Fatalf("Expected --allow-empty to store both, got failures %v:\n%s", failed, output)
+	}
+}
+
+func TestExecuteBuildsCleanOutput(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "echo fresh > build/web/new.txt", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web", "clean_output": true }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/web", "build/web"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	// buildTestSetup leaves a file in build/web that an earlier build could have made
+	if failed, output := captureExecuteBuilds(t, opts, config, repo); len(failed) != 0 {
+		t.Fatalf("Expected the build to succeed, got failures %v:\n%s", failed, output)
+	}
+	outputDir := filepath.Join(config.RootDirectory, "build", "web")
+	if _, err := os.Stat(filepath.Join(outputDir, "f.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale file to be cleaned away")
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "new.txt")); err != nil {
+		t.Errorf("Expected the build's own output to be kept: %v", err)
+	}
+
+	stored, _ := filepath.Glob(filepath.Join(config.Repository.Options.Root, "web-*"))
+	if len(stored) != 1 {
+		t.Fatalf("Expected one stored archive, found %v", stored)
+	}
+	extractDir := t.TempDir()
+	if err := extractArchive(stored[0], extractDir, slarty.ExtractionLimits{}, 0); err != nil {
+		t.Fatalf("extractArchive failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(extractDir, "f.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale file to be left out of the archive")
+	}
+}
+
+func TestExecuteBuildsExpect(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "true", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web", "expect": ["f.txt", "index.html"] },
+		{ "name": "api", "directories": ["src/api"], "command": "true", "output_directory": "build/api", "deploy_location": "deploy/api", "artifact_prefix": "api", "expect": ["*.txt"] }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/web", "src/api", "build/web", "build/api"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	failed, output := captureExecuteBuilds(t, opts, config, repo)
+	if len(failed) != 1 || failed[0] != "web" {
+		t.Fatalf("Expected only web to fail, got failures %v:\n%s", failed, output)
+	}
+	if !strings.Contains(output, "has nothing matching expect index.html") {
+		t.Errorf("Expected the missing pattern to be named, got:\n%s", output)
+	}
+	stored, _ := filepath.Glob(filepath.Join(config.Repository.Options.Root, "web-*"))
+	if len(stored) != 0 {
+		t.Errorf("Expected nothing to be stored for web, found %v", stored)
+	}
+}
+
+func TestExecuteBuildsMultipleOutputDirectories(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "true", "output_directory": ["dist", "public/build"], "deploy_location": "deploy/web", "artifact_prefix": "web" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/web", "dist", "public/build"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	if failed, output := captureExecuteBuilds(t, opts, config, repo); len(failed) != 0 {
+		t.Fatalf("Expected the build to succeed, got failures %v:\n%s", failed, output)
+	}
+	stored, _ := filepath.Glob(filepath.Join(config.Repository.Options.Root, "web-*"))
+	if len(stored) != 1 {
+		t.Fatalf("Expected one stored archive, found %v", stored)
+	}
+
+	// Each directory is archived under its path from the root directory
+	extractDir := t.TempDir()
+	if err := extractArchive(stored[0], extractDir, slarty.ExtractionLimits{}, 0); err != nil {
+		t.Fatalf("extractArchive failed: %v", err)
+	}
+	for _, dir := range []string{"dist", "public/build"} {
+		content, err := os.ReadFile(filepath.Join(extractDir, filepath.FromSlash(dir), "f.txt"))
+		if err != nil || string(content) != dir {
+			t.Errorf("Expected %s/f.txt in the archive, got %q (%v)", dir, content, err)
+		}
+	}
+
+	// Restoring puts each directory back in its place
+	for _, dir := range []string{"dist", "public/build"} {
+		if err := os.RemoveAll(filepath.Join(config.RootDirectory, filepath.FromSlash(dir))); err != nil {
+			t.Fatalf("Failed to remove %s: %v", dir, err)
+		}
+	}
+	web, _ := config.GetArtifactConfig("web")
+	if _, err := (&rootOptions{}).restoreOutput(*web, config, nil, repo); err != nil {
+		t.Fatalf("restoreOutput failed: %v", err)
+	}
+	for _, dir := range []string{"dist", "public/build"} {
+		content, err := os.ReadFile(filepath.Join(config.RootDirectory, filepath.FromSlash(dir), "f.txt"))
+		if err != nil || string(content) != dir {
+			t.Errorf("Expected %s/f.txt to be restored, got %q (%v)", dir, content, err)
+		}
+	}
+}
+
+func TestExecuteBuildsImmutableRepository(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "true", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/web", "build/web"})
+	immutable := slarty.NewImmutableRepositoryAdapter(repo)
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	if failed, output := captureExecuteBuilds(t, opts, config, immutable); len(failed) != 0 {
+		t.Fatalf("Expected the first build to succeed, got failures %v:\n%s", failed, output)
+	}
+
+	// A forced rebuild with different output must not replace the stored artifact
+	if err := os.WriteFile(filepath.Join(config.RootDirectory, "build", "web", "f.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to change output: %v", err)
+	}
+	failed, output := captureExecuteBuilds(t, opts, config, immutable)
+	if len(failed) != 1 || !strings.Contains(output, "already exists in immutable repository") {
+		t.Fatalf("Expected the overwrite to be refused, got failures %v:\n%s", failed, output)
+	}
+
+	// --allow-overwrite replaces it
+	if failed, output := captureExecuteBuilds(t, opts, config, slarty.AllowOverwrite(immutable)); len(failed) != 0 {
+		t.Fatalf("Expected the overwrite to be allowed, got failures %v:\n%s", failed, output)
+	}
+}
+
+func TestExecuteBuildsMarksLatest(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "true", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/web", "build/web"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}, markLatest: true}
+
+	expected, err := slarty.GetArtifactName("web", config)
+	if err != nil {
+		t.Fatalf("GetArtifactName failed: %v", err)
+	}
+
+	// Both a build and a skipped build mark the artifact as latest
+	for i := 0; i < 2; i++ {
+		if err := os.Remove(filepath.Join(config.RootDirectory, "repo", slarty.LatestPointerName("web"))); err != nil && !os.IsNotExist(err) {
+			t.Fatalf("Failed to remove latest pointer: %v", err)
+		}
+		failed, output := captureExecuteBuilds(t, opts, config, repo)
+		if len(failed) != 0 {
+			t.Fatalf("Expected no failures, got %v:\n%s", failed, output)
+		}
+		if !strings.Contains(output, "Marked "+expected+" as latest for web") {
+			t.Errorf("Expected the latest pointer to be reported, got:\n%s", output)
+		}
+	}
+
+	pointer, err := slarty.ReadLatestPointer(repo, "web")
+	if err != nil {
+		t.Fatalf("ReadLatestPointer failed: %v", err)
+	}
+	if pointer.Hash == "" || !strings.Contains(expected, pointer.Hash) || pointer.Commit == "" {
+		t.Errorf("Expected the pointer to record the hash and commit, got %+v", pointer)
+	}
+
+	// do-deploys --latest resolves the pointer without hashing
+	var name string
+	captureStdout(t, func() {
+		name, err = deployArtifactName(&doDeploysOptions{latest: true}, config.Artifacts[0], config, repo, nil, nil)
+	})
+	if err != nil || name != expected {
+		t.Errorf("Expected %s from the latest pointer, got %q, %v", expected, name, err)
+	}
+}
+
 func TestCreateTarGz(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "slarty-targz-test")
@@ -237,9 +593,9 @@ func TestCreateTarGz(t *testing.T) {
 
 	// Create a tar.gz file
 	tarGzPath := filepath.Join(tempDir, "test.tar.gz")
-	err = createTarGz(sourceDir, tarGzPath)
+	err = archive.CreateFile(archive.TarGz, tarGzPath, []archive.Source{{Dir: sourceDir}}, slarty.NewBuilder(nil, nil).WriteOptions(nil))
 	if err != nil {
-		t.Fatalf("createTarGz failed: %v", err)
+		t.Fatalf("CreateFile failed: %v", err)
 	}
 
 	// Verify the tar.gz file was created
@@ -255,7 +611,7 @@ func TestCreateTarGz(t *testing.T) {
 	}
 
 	// Use the extractTarGz function from doDeploys.go to extract the tar.gz file
-	err = extractTarGz(tarGzPath, extractDir)
+	err = extractArchive(tarGzPath, extractDir, slarty.ExtractionLimits{}, 0)
 	if err != nil {
 		t.Fatalf("Failed to extract tar.gz file: %v", err)
 	}
@@ -288,6 +644,142 @@ func TestCreateTarGz(t *testing.T) {
 	}
 }
 
+func TestCreateTarGzSkipsWorkDirectory(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "index.html"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// Archive the root into slarty's own temp directory inside it
+	tempFile, err := slarty.CreateTempArchive(root, "slarty-*.tar.gz")
+	if err != nil {
+		t.Fatalf("CreateTempArchive failed: %v", err)
+	}
+	tempFile.Close()
+
+	if err := archive.CreateFile(archive.TarGz, tempFile.Name(), []archive.Source{{Dir: root}}, slarty.NewBuilder(nil, nil).WriteOptions(nil)); err != nil {
+		t.Fatalf("CreateFile failed: %v", err)
+	}
+
+	extractDir := t.TempDir()
+	if err := extractArchive(tempFile.Name(), extractDir, slarty.ExtractionLimits{}, 0); err != nil {
+		t.Fatalf("extractArchive failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(extractDir, "index.html")); err != nil {
+		t.Errorf("Expected index.html in the archive: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(extractDir, slarty.WorkDirName)); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be left out of the archive", slarty.WorkDirName)
+	}
+}
+
+func TestWriteTarGzOutputFilter(t *testing.T) {
+	sourceDir := t.TempDir()
+	for _, name := range []string{"index.html", "app.js", "app.js.map", ".cache/x", "assets/img/logo.png", "assets/img/logo.png.map", "docs/readme.md"} {
+		path := filepath.Join(sourceDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	filter, err := slarty.ArtifactConfig{
+		Name:          "web",
+		OutputInclude: []string{"*.html", "*.js", "assets"},
+		OutputExclude: []string{"*.map", ".cache"},
+	}.OutputFilter()
+	if err != nil {
+		t.Fatalf("OutputFilter failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := archive.TarGz.Write(&buf, []archive.Source{{Dir: sourceDir}}, slarty.NewBuilder(nil, nil).WriteOptions(filter)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	gzipReader, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	var names []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive entry: %v", err)
+		}
+		names = append(names, filepath.ToSlash(header.Name))
+	}
+
+	// docs has nothing included, so it is not archived even as an empty directory
+	expected := []string{".", "app.js", "assets", "assets/img", "assets/img/logo.png", "index.html"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected entries %v, got %v", expected, names)
+	}
+}
+
+func TestCreateTarGzReproducible(t *testing.T) {
+	builder := slarty.NewBuilder(nil, nil)
+	builder.Reproducible = true
+
+	// Build the same content twice with different modification times
+	archiveOf := func(mtime time.Time) []byte {
+		sourceDir := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(sourceDir, "b", "nested"), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		for _, name := range []string{"z.txt", "a.txt", filepath.Join("b", "nested", "c.txt")} {
+			path := filepath.Join(sourceDir, name)
+			if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatalf("Failed to set times on %s: %v", name, err)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := archive.TarGz.Write(&buf, []archive.Source{{Dir: sourceDir}}, builder.WriteOptions(nil)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	first := archiveOf(time.Now().Add(-time.Hour))
+	second := archiveOf(time.Now())
+	if !bytes.Equal(first, second) {
+		t.Fatalf("Expected identical archives for identical content")
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(first))
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	var names []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		if !header.ModTime.Equal(time.Unix(0, 0)) || header.Uid != 0 || header.Gid != 0 || header.Uname != "" {
+			t.Errorf("Expected %s to have a normalized header, got %+v", header.Name, header)
+		}
+		names = append(names, header.Name)
+	}
+	want := []string{".", "a.txt", "b", "b/nested", "b/nested/c.txt", "z.txt"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected entries %v, got %v", want, names)
+	}
+}
+
 func TestRunDoBuilds(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "slarty-do-builds-test")
@@ -403,28 +895,13 @@ func TestRunDoBuilds(t *testing.T) {
 		Short: "Test command",
 	}
 
-	// Save original artifactsJson and restore after test
-	oldArtifactsJson := artifactsJson
-	defer func() { artifactsJson = oldArtifactsJson }()
-	artifactsJson = configPath
-
-	// Save original filter and restore after test
-	oldFilter := filter
-	defer func() { filter = oldFilter }()
-
-	// Save original force flag and restore after test
-	oldForce := force
-	defer func() { force = oldForce }()
+	// Use local repository adapter for testing
+	opts := &doBuildsOptions{root: &rootOptions{artifactsJson: configPath, local: true}}
 
-	// Save original local flag and restore after test
-	oldLocal := local
-	defer func() { local = oldLocal }()
-	local = true // Use local repository adapter for testing
-
-	// Test with no filter and no force
+	// Test with no filter and no doBuildsForce
 	t.Run("NoFilterNoForce", func(t *testing.T) {
-		filter = ""
-		force = false
+		opts.selection.filter = ""
+		opts.force = false
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -432,7 +909,7 @@ func TestRunDoBuilds(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runDoBuilds(cmd, []string{})
+		runDoBuilds(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -463,8 +940,8 @@ func TestRunDoBuilds(t *testing.T) {
 
 	// Test with filter
 	t.Run("WithFilter", func(t *testing.T) {
-		filter = "test-artifact-1"
-		force = true // Force rebuild
+		opts.selection.filter = "test-artifact-1"
+		opts.force = true // Force rebuild
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -472,7 +949,7 @@ func TestRunDoBuilds(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runDoBuilds(cmd, []string{})
+		runDoBuilds(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -492,10 +969,28 @@ func TestRunDoBuilds(t *testing.T) {
 		}
 	})
 
-	// Test with force flag
+	// Test with exclude
+	t.Run("WithExclude", func(t *testing.T) {
+		opts.selection.filter = ""
+		opts.selection.exclude = "test-artifact-1"
+		opts.force = true // Force rebuild
+		defer func() { opts.selection.exclude = "" }()
+
+		output := captureStdout(t, func() { runDoBuilds(cmd, []string{}, opts) })
+
+		// Check that the excluded artifact is not built
+		if strings.Contains(output, "Building test-artifact-1") {
+			t.Errorf("Expected output to not contain 'Building test-artifact-1', got: %s", output)
+		}
+		if !strings.Contains(output, "Building test-artifact-2") {
+			t.Errorf("Expected output to contain 'Building test-artifact-2', got: %s", output)
+		}
+	})
+
+	// Test with doBuildsForce flag
 	t.Run("WithForce", func(t *testing.T) {
-		filter = ""
-		force = true
+		opts.selection.filter = ""
+		opts.force = true
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -503,7 +998,7 @@ func TestRunDoBuilds(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runDoBuilds(cmd, []string{})
+		runDoBuilds(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -523,3 +1018,207 @@ func TestRunDoBuilds(t *testing.T) {
 		}
 	})
 }
+
+func TestExecuteBuildsSendsNotification(t *testing.T) {
+	artifacts := `
+		{ "name": "good", "directories": ["src/good"], "command": "echo built", "output_directory": "build/good", "deploy_location": "deploy/good", "artifact_prefix": "good" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/good", "build/good"})
+
+	var summary slarty.RunSummary
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Summary slarty.RunSummary `json:"summary"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode notification: %v", err)
+		}
+		summary = payload.Summary
+	}))
+	defer server.Close()
+	config.Notifications.WebhookURL = server.URL
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	captureExecuteBuilds(t, opts, config, repo)
+
+	if summary.Command != "do-builds" {
+		t.Fatalf("Expected do-builds summary to be posted, got %+v", summary)
+	}
+	if len(summary.Results) != 1 || summary.Results[0].Status != "built" {
+		t.Errorf("Expected one built result, got %+v", summary.Results)
+	}
+}
+
+func TestExecuteBuildsPushesMetrics(t *testing.T) {
+	artifacts := `
+		{ "name": "good", "directories": ["src/good"], "command": "echo built", "output_directory": "build/good", "deploy_location": "deploy/good", "artifact_prefix": "good" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/good", "build/good"})
+
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+	config.Metrics = slarty.MetricsConfig{Type: "pushgateway", Endpoint: server.URL}
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	captureExecuteBuilds(t, opts, config, repo)
+
+	for _, expected := range []string{
+		`slarty_build_duration_seconds{artifact="good"}`,
+		`slarty_archive_size_bytes{artifact="good"}`,
+		`slarty_upload_duration_seconds{artifact="good"}`,
+		"slarty_cache_hit_ratio 0",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected pushed metrics to contain %q, got:\n%s", expected, body)
+		}
+	}
+}
+
+func TestExecuteBuildsEmitsEvents(t *testing.T) {
+	artifacts := `
+		{ "name": "good", "directories": ["src/good"], "command": "echo built", "output_directory": "build/good", "deploy_location": "deploy/good", "artifact_prefix": "good" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/good", "build/good"})
+
+	var buf bytes.Buffer
+	opts := &doBuildsOptions{root: &rootOptions{events: slarty.NewEventWriter(&buf)}, force: true}
+
+	captureExecuteBuilds(t, opts, config, repo)
+
+	var types []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var event slarty.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Event is not valid JSON: %v: %s", err, line)
+		}
+		types = append(types, event.Type)
+	}
+
+	expected := []string{
+		slarty.EventRunStarted,
+		slarty.EventBuildStarted,
+		slarty.EventUploadProgress,
+		slarty.EventUploadProgress,
+		slarty.EventBuildFinished,
+		slarty.EventRunFinished,
+	}
+	if strings.Join(types, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected events %v, got %v", expected, types)
+	}
+}
+
+func TestExecuteBuildsRecordsHistory(t *testing.T) {
+	artifacts := `
+		{ "name": "good", "directories": ["src/good"], "command": "echo built", "output_directory": "build/good", "deploy_location": "deploy/good", "artifact_prefix": "good" },
+		{ "name": "bad", "directories": ["src/bad"], "command": "exit 1", "output_directory": "build/bad", "deploy_location": "deploy/bad", "artifact_prefix": "bad" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/good", "src/bad", "build/good", "build/bad"})
+	config.Application = "History Test"
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	captureExecuteBuilds(t, opts, config, repo)
+
+	path, err := historyPath(config)
+	if err != nil {
+		t.Fatalf("Failed to get history path: %v", err)
+	}
+	defer os.Remove(path)
+
+	records, err := slarty.ReadBuildHistory(path)
+	if err != nil {
+		t.Fatalf("Failed to read history: %v", err)
+	}
+	if len(records) != 1 || records[0].Artifact != "good" {
+		t.Fatalf("Expected only the successful build to be recorded, got %+v", records)
+	}
+	if records[0].Size <= 0 || !strings.HasPrefix(records[0].ArtifactName, "good-") {
+		t.Errorf("Expected the record to carry the archive name and size, got %+v", records[0])
+	}
+}
+
+func TestExecuteBuildsMatrix(t *testing.T) {
+	artifacts := `
+		{ "name": "api", "directories": ["src/api"], "command": "mkdir -p build/{os}-{arch} && echo \"$GOOS/$GOARCH\" > build/{os}-{arch}/platform", "output_directory": "build/{os}-{arch}", "deploy_location": "deploy/api", "artifact_prefix": "api", "matrix": ["linux/amd64", "darwin/arm64"] }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/api"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	failed, output := captureExecuteBuilds(t, opts, config, repo)
+	if len(failed) != 0 {
+		t.Fatalf("Expected no failures, got %v:\n%s", failed, output)
+	}
+
+	for _, platform := range []string{"linux/amd64", "darwin/arm64"} {
+		dir := strings.ReplaceAll(platform, "/", "-")
+		got, err := os.ReadFile(filepath.Join(config.RootDirectory, "build", dir, "platform"))
+		if err != nil {
+			t.Fatalf("Expected build output for %s: %v", platform, err)
+		}
+		if strings.TrimSpace(string(got)) != platform {
+			t.Errorf("Expected GOOS/GOARCH %s in the build environment, got %q", platform, got)
+		}
+
+		artifactName, err := slarty.GetArtifactName("api-"+dir, config)
+		if err != nil {
+			t.Fatalf("GetArtifactName failed: %v", err)
+		}
+		if !strings.HasPrefix(artifactName, "api-"+dir+"-") {
+			t.Errorf("Expected the platform in the archive name, got %s", artifactName)
+		}
+		if exists, _ := repo.ArtifactExists(artifactName); !exists {
+			t.Errorf("Expected %s to be stored in the repository", artifactName)
+		}
+	}
+}
+
+func TestExecuteBuildsAudit(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "true", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web" },
+		{ "name": "broken", "directories": ["src/broken"], "command": "false", "output_directory": "build/broken", "deploy_location": "deploy/broken", "artifact_prefix": "broken" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/web", "src/broken", "build/web", "build/broken"})
+	config.Audit = slarty.AuditConfig{File: "audit.jsonl", Repository: true}
+	t.Setenv("SLARTY_AUDIT_USER", "release-bot")
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	if failed, output := captureExecuteBuilds(t, opts, config, repo); len(failed) != 1 {
+		t.Fatalf("Expected one build to fail, got failures %v:\n%s", failed, output)
+	}
+	webName, err := slarty.GetArtifactName("web", config)
+	if err != nil {
+		t.Fatalf("GetArtifactName failed: %v", err)
+	}
+
+	records, err := slarty.ReadAuditLog(filepath.Join(config.RootDirectory, "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadAuditLog failed: %v", err)
+	}
+	var got []string
+	for _, record := range records {
+		got = append(got, record.Action+" "+record.Artifact+" "+record.Result)
+		if record.User != "release-bot" || record.Command != "do-builds" || record.Time.IsZero() {
+			t.Errorf("Expected the record to say who ran what and when, got %+v", record)
+		}
+		if record.Artifact == "web" && (record.ArtifactName != webName || record.Hash == "" || !strings.Contains(webName, record.Hash)) {
+			t.Errorf("Expected the web record to name the artifact and hash, got %+v", record)
+		}
+	}
+	want := []string{"store web succeeded", "build web succeeded", "build broken failed"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected records %v, got %v", want, got)
+	}
+
+	// The same records are stored in the repository once the command has finished
+	opts.root.flushAudit()
+	stored, err := slarty.ReadRepositoryAuditLog(repo)
+	if err != nil || len(stored) != len(records) {
+		t.Fatalf("Expected %d records in the repository, got %d (%v)", len(records), len(stored), err)
+	}
+	opts.root.flushAudit()
+	if again, _ := slarty.ReadRepositoryAuditLog(repo); len(again) != len(records) {
+		t.Errorf("Expected records to be stored once, got %d", len(again))
+	}
+}
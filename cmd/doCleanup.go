@@ -26,175 +26,148 @@ import (
 	"github.com/dstockto/slarty/slarty"
 	"github.com/spf13/cobra"
 	"log"
-	"os"
-	"path/filepath"
-	"strings"
 )
 
-var exclude string
+// doCleanupOptions are the flags of the do-cleanup command
+type doCleanupOptions struct {
+	root             *rootOptions
+	selection        selectionOptions
+	assets           bool
+	artifacts        bool
+	all              bool
+	allowOutsideRoot bool
+	// backup moves the old contents into a backup directory instead of deleting them
+	backup bool
+}
 
-// doCleanupCmd represents the doCleanup command
-var doCleanupCmd = &cobra.Command{
-	Use:   "do-cleanup",
-	Short: "Clear deployment directories for assets",
-	Long: `The do-cleanup command is used to clear the deployment directories for your assets.
+// newDoCleanupCmd creates the do-cleanup command
+func newDoCleanupCmd(root *rootOptions) *cobra.Command {
+	opts := &doCleanupOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "do-cleanup",
+		Short: "Clear deployment directories for assets and artifacts",
+		Long: `The do-cleanup command is used to clear the deployment directories for your assets.
 The command reads the configuration for any defined assets you've defined, and will delete
 the contents of the deploy_location directories as defined in artifacts.json.
-You can pass in the --filter command to limit the assets to only those that match the pattern provided.
-You can use the --exclude flag to remove assets that match the provided pattern from consideration.
-If neither --filter, nor --exclude is provided, the command will run against all defined assets.`,
-	Run: runDoCleanup,
-}
-
-// filterAssetsByNameWithExclusion filters assets by name based on the provided filter and exclude patterns
-func filterAssetsByNameWithExclusion(assets []slarty.Asset, filter []string, exclude []string) []slarty.Asset {
-	// If no filter and no exclude, return all assets
-	if len(filter) == 0 && len(exclude) == 0 {
-		return assets
+Use --artifact-locations to clear the artifacts' deploy locations instead, or --all to clear
+both; --assets, the default, clears only the assets'. Artifacts using the symlink deploy
+strategy are never cleaned, and docker artifacts have no deploy location to clean.
+You can pass in the --filter command to limit the entries to only those that match the pattern provided.
+You can use the --exclude flag to remove entries that match the provided pattern from consideration.
+If neither --filter, nor --exclude is provided, the command will run against all defined entries.
+The directories to be emptied are listed with their file counts and sizes, and nothing is
+deleted until you confirm. Pass --yes to skip the prompt in scripts; without it, the command
+fails rather than deleting anything when it cannot ask, such as when input is not a terminal.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runDoCleanup(cmd, args, opts)
+		},
 	}
 
-	var selected []slarty.Asset
-	for _, asset := range assets {
-		name := strings.ToLower(asset.Name)
-
-		// Check if the asset should be excluded
-		excluded := false
-		for _, e := range exclude {
-			if name == strings.TrimSpace(strings.ToLower(e)) {
-				excluded = true
-				break
-			}
-		}
+	// Define flags specific to this command
+	bindSelectionFlags(cmd, root, &opts.selection, "asset1,asset2", "asset3,asset4", opts.completeCleanupNames)
+	cmd.Flags().BoolVar(&opts.assets, "assets", false, "clean the assets' deploy locations (the default)")
+	cmd.Flags().BoolVar(&opts.artifacts, "artifact-locations", false, "clean the artifacts' deploy locations")
+	cmd.Flags().BoolVar(&opts.all, "all", false, "clean the deploy locations of both assets and artifacts")
+	cmd.Flags().BoolVar(&opts.backup, "backup", false, "move the contents into a timestamped backup directory instead of deleting them")
+	cmd.Flags().BoolVar(&opts.allowOutsideRoot, "allow-outside-root", false, "allow cleaning deploy locations outside root_directory")
+
+	return cmd
+}
 
-		// Skip this asset if it's excluded
-		if excluded {
-			continue
-		}
+func runDoCleanup(cmd *cobra.Command, args []string, opts *doCleanupOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-		// If there's no filter, include all non-excluded assets
-		if len(filter) == 0 {
-			selected = append(selected, asset)
-			continue
+	// Collect the deploy locations of the selected assets and artifacts
+	var locations []slarty.CleanupTarget
+	selection := opts.selection.selection()
+	if opts.assets || opts.all || !opts.artifacts {
+		for _, asset := range artifactConfig.SelectAssets(selection) {
+			locations = append(locations, slarty.CleanupTarget{Name: asset.Name, Location: asset.DeployLocation})
 		}
-
-		// Check if the asset matches the filter
-		for _, f := range filter {
-			if name == strings.TrimSpace(strings.ToLower(f)) {
-				selected = append(selected, asset)
-				break
+	}
+	if opts.artifacts || opts.all {
+		for _, artifact := range artifactConfig.SelectArtifacts(selection) {
+			if artifact.IsDocker() {
+				continue
 			}
+			locations = append(locations, slarty.CleanupTarget{Name: artifact.Name, Location: artifact.DeployLocation, Symlink: artifact.UsesSymlinkStrategy()})
 		}
 	}
 
-	return selected
-}
+	if len(locations) == 0 {
+		fmt.Println("Nothing found to clean up")
+		return
+	}
 
-func runDoCleanup(cmd *cobra.Command, args []string) {
-	// Read the artifacts configuration
-	artifactConfig, err := slarty.ReadArtifactsJson(artifactsJson)
+	// Work out which deploy locations will be emptied before touching any of them
+	cleaner := slarty.NewCleaner(artifactConfig)
+	cleaner.AllowOutsideRoot = opts.allowOutsideRoot
+	targets, err := cleaner.Targets(locations)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	// Parse the filter flag
-	var filters []string
-	if filter != "" {
-		filters = strings.Split(filter, ",")
+	if len(targets) == 0 {
+		return
 	}
 
-	// Parse the exclude flag
-	var excludes []string
-	if exclude != "" {
-		excludes = strings.Split(exclude, ",")
+	action, question := "deleted", "Delete them?"
+	if opts.backup {
+		action, question = "moved to a backup", "Move them?"
 	}
-
-	// Get the assets based on the filter and exclude
-	assets := filterAssetsByNameWithExclusion(artifactConfig.Assets, filters, excludes)
-
-	if len(assets) == 0 {
-		fmt.Println("No assets found")
+	fmt.Printf("The contents of these directories will be %s:\n", action)
+	for _, target := range targets {
+		fmt.Printf(" - %s (%d files, %s)\n", target.Path, target.Files, formatBytes(target.Size))
+	}
+	ok, err := opts.root.confirm(cmd, question)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if !ok {
+		fmt.Println("Nothing was " + action)
 		return
 	}
 
-	// Clean up each asset's deploy location
-	for _, asset := range assets {
-		fmt.Printf("Cleaning up deploy location for %s: %s\n", asset.Name, asset.DeployLocation)
+	if opts.backup {
+		cleaner.Backup = newRunBackup(artifactConfig)
+	}
 
-		// Get the full path to the deploy location
-		deployPath := filepath.Join(artifactConfig.RootDirectory, asset.DeployLocation)
+	// Clean up each asset's deploy location
+	for _, target := range targets {
+		fmt.Printf("Cleaning up deploy location for %s: %s\n", target.Name, target.Location)
 
-		// Guard against cleaning the project root itself. An empty or "."
-		// deploy_location causes filepath.Join to collapse to RootDirectory,
-		// which would otherwise wipe the entire project. Refuse to clean when
-		// the resolved deploy path is the project root or an ancestor of it.
-		deployAbs, err := filepath.Abs(deployPath)
+		// Move the contents aside, or remove them
+		err := cleaner.Clean(target)
+		record := slarty.AuditRecord{Action: slarty.AuditCleanup, Command: "do-cleanup", Artifact: target.Name, Location: target.Location, Result: slarty.AuditSucceeded}
 		if err != nil {
-			log.Fatalf("Failed to resolve deploy directory: %v", err)
+			record.Result, record.Error = slarty.AuditFailed, err.Error()
 		}
-		deployAbs = filepath.Clean(deployAbs)
-
-		rootAbs, err := filepath.Abs(artifactConfig.RootDirectory)
-		if err != nil {
-			log.Fatalf("Failed to resolve root directory: %v", err)
-		}
-		rootAbs = filepath.Clean(rootAbs)
-
-		if deployAbs == rootAbs {
-			fmt.Printf(" - Refusing to clean %s: resolves to or contains the project root\n", deployPath)
-			continue
-		}
-		if rel, relErr := filepath.Rel(deployAbs, rootAbs); relErr == nil &&
-			rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel) {
-			// rootAbs is inside deployAbs, so deployPath is an ancestor of the root.
-			fmt.Printf(" - Refusing to clean %s: resolves to or contains the project root\n", deployPath)
-			continue
-		}
-
-		// Check if the directory exists
-		_, err = os.Stat(deployPath)
-		if os.IsNotExist(err) {
-			fmt.Printf(" - Directory does not exist: %s\n", deployPath)
-			continue
-		} else if err != nil {
-			log.Fatalf("Failed to check deploy directory: %v", err)
-		}
-
-		// Remove all contents of the directory
-		err = removeContents(deployPath)
+		opts.root.audit(artifactConfig, record)
 		if err != nil {
+			opts.root.flushAudit()
 			log.Fatalf("Failed to clean up deploy directory: %v", err)
 		}
-		fmt.Printf(" - Successfully cleaned up %s\n", deployPath)
+		fmt.Printf(" - Successfully cleaned up %s\n", target.Path)
 	}
-}
 
-// removeContents removes all files and directories within the specified directory
-func removeContents(dir string) error {
-	d, err := os.Open(dir)
-	if err != nil {
-		return err
-	}
-	defer d.Close()
+	pruneBackups(artifactConfig, cleaner.Backup)
+}
 
-	names, err := d.Readdirnames(-1)
-	if err != nil {
-		return err
+// completeCleanupNames completes the names of the entries whose deploy locations are
+// being cleaned
+func (o *doCleanupOptions) completeCleanupNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var names []string
+	directive := cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	if o.assets || o.all || !o.artifacts {
+		names, directive = o.root.completeAssetNames(cmd, args, toComplete)
 	}
-
-	for _, name := range names {
-		err = os.RemoveAll(filepath.Join(dir, name))
-		if err != nil {
-			return err
-		}
+	if o.artifacts || o.all {
+		artifactNames, artifactDirective := o.root.completeArtifactNames(cmd, args, toComplete)
+		names, directive = append(names, artifactNames...), artifactDirective
 	}
-
-	return nil
-}
-
-func init() {
-	rootCmd.AddCommand(doCleanupCmd)
-
-	// Define flags specific to this command
-	doCleanupCmd.Flags().StringVarP(&filter, "filter", "f", "", "-f \"asset1,asset2\"")
-	doCleanupCmd.Flags().StringVarP(&exclude, "exclude", "e", "", "-e \"asset3,asset4\"")
+	return names, directive
 }
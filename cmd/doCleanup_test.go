@@ -8,32 +8,33 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/dstockto/slarty/slarty"
 	"github.com/spf13/cobra"
 )
 
 func TestDoCleanupCommand(t *testing.T) {
 	// Test that the do-cleanup command is properly initialized
-	if doCleanupCmd.Use != "do-cleanup" {
-		t.Errorf("Expected do-cleanup command Use to be 'do-cleanup', got '%s'", doCleanupCmd.Use)
+	cmd := newDoCleanupCmd(&rootOptions{})
+	if cmd.Use != "do-cleanup" {
+		t.Errorf("Expected do-cleanup command Use to be 'do-cleanup', got '%s'", cmd.Use)
 	}
 
-	if doCleanupCmd.Short == "" {
+	if cmd.Short == "" {
 		t.Error("do-cleanup command Short description should not be empty")
 	}
 
-	if doCleanupCmd.Long == "" {
+	if cmd.Long == "" {
 		t.Error("do-cleanup command Long description should not be empty")
 	}
 
-	if doCleanupCmd.Run == nil {
+	if cmd.Run == nil {
 		t.Error("do-cleanup command Run function should not be nil")
 	}
 }
 
 func TestDoCleanupCommandFlags(t *testing.T) {
 	// Test that the do-cleanup command has the expected flags
-	flags := doCleanupCmd.Flags()
+	cmd := newDoCleanupCmd(&rootOptions{})
+	flags := cmd.Flags()
 
 	// Check filter flag
 	if flags.Lookup("filter") == nil {
@@ -44,119 +45,10 @@ func TestDoCleanupCommandFlags(t *testing.T) {
 	if flags.Lookup("exclude") == nil {
 		t.Error("do-cleanup command should have 'exclude' flag")
 	}
-}
-
-func TestFilterAssetsByNameWithExclusion(t *testing.T) {
-	// Create test assets
-	assets := []slarty.Asset{
-		{Name: "asset1", Filename: "file1.tar.gz", DeployLocation: "deploy/asset1"},
-		{Name: "asset2", Filename: "file2.tar.gz", DeployLocation: "deploy/asset2"},
-		{Name: "asset3", Filename: "file3.tar.gz", DeployLocation: "deploy/asset3"},
-	}
-
-	// Test with no filter and no exclude
-	filtered := filterAssetsByNameWithExclusion(assets, nil, nil)
-	if len(filtered) != 3 {
-		t.Errorf("Expected 3 assets with no filter and no exclude, got %d", len(filtered))
-	}
-
-	// Test with filter only
-	filtered = filterAssetsByNameWithExclusion(assets, []string{"asset1", "asset2"}, nil)
-	if len(filtered) != 2 {
-		t.Errorf("Expected 2 assets with filter, got %d", len(filtered))
-	}
-	if filtered[0].Name != "asset1" || filtered[1].Name != "asset2" {
-		t.Errorf("Expected filtered assets to be asset1 and asset2, got %s and %s", filtered[0].Name, filtered[1].Name)
-	}
-
-	// Test with exclude only
-	filtered = filterAssetsByNameWithExclusion(assets, nil, []string{"asset3"})
-	if len(filtered) != 2 {
-		t.Errorf("Expected 2 assets with exclude, got %d", len(filtered))
-	}
-	if filtered[0].Name != "asset1" || filtered[1].Name != "asset2" {
-		t.Errorf("Expected filtered assets to be asset1 and asset2, got %s and %s", filtered[0].Name, filtered[1].Name)
-	}
-
-	// Test with both filter and exclude
-	filtered = filterAssetsByNameWithExclusion(assets, []string{"asset1", "asset3"}, []string{"asset3"})
-	if len(filtered) != 1 {
-		t.Errorf("Expected 1 asset with filter and exclude, got %d", len(filtered))
-	}
-	if filtered[0].Name != "asset1" {
-		t.Errorf("Expected filtered asset to be asset1, got %s", filtered[0].Name)
-	}
-
-	// Test with case insensitivity
-	filtered = filterAssetsByNameWithExclusion(assets, []string{"ASSET1"}, nil)
-	if len(filtered) != 1 {
-		t.Errorf("Expected 1 asset with case-insensitive filter, got %d", len(filtered))
-	}
-	if filtered[0].Name != "asset1" {
-		t.Errorf("Expected filtered asset to be asset1, got %s", filtered[0].Name)
-	}
-}
-
-func TestRemoveContents(t *testing.T) {
-	// Create a temporary directory for testing
-	tempDir, err := os.MkdirTemp("", "slarty-remove-contents-test")
-	if err != nil {
-		t.Fatalf("Failed to create temp directory: %v", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Create some files in the directory
-	files := []string{
-		filepath.Join(tempDir, "file1.txt"),
-		filepath.Join(tempDir, "file2.txt"),
-	}
-
-	for _, file := range files {
-		err = os.WriteFile(file, []byte("test content"), 0644)
-		if err != nil {
-			t.Fatalf("Failed to create file %s: %v", file, err)
-		}
-	}
-
-	// Create a subdirectory with a file
-	subDir := filepath.Join(tempDir, "subdir")
-	err = os.Mkdir(subDir, 0755)
-	if err != nil {
-		t.Fatalf("Failed to create subdirectory: %v", err)
-	}
-
-	subFile := filepath.Join(subDir, "subfile.txt")
-	err = os.WriteFile(subFile, []byte("subfile content"), 0644)
-	if err != nil {
-		t.Fatalf("Failed to create file in subdirectory: %v", err)
-	}
 
-	// Verify files and directory exist
-	for _, file := range files {
-		if _, err := os.Stat(file); os.IsNotExist(err) {
-			t.Fatalf("File %s does not exist before test", file)
-		}
-	}
-	if _, err := os.Stat(subDir); os.IsNotExist(err) {
-		t.Fatalf("Subdirectory does not exist before test")
-	}
-	if _, err := os.Stat(subFile); os.IsNotExist(err) {
-		t.Fatalf("File in subdirectory does not exist before test")
-	}
-
-	// Remove contents
-	err = removeContents(tempDir)
-	if err != nil {
-		t.Fatalf("removeContents failed: %v", err)
-	}
-
-	// Verify directory is empty
-	entries, err := os.ReadDir(tempDir)
-	if err != nil {
-		t.Fatalf("Failed to read directory: %v", err)
-	}
-	if len(entries) != 0 {
-		t.Errorf("Expected directory to be empty, got %d entries", len(entries))
+	// Check regex flag
+	if flags.Lookup("regex") == nil {
+		t.Error("do-cleanup command should have 'regex' flag")
 	}
 }
 
@@ -228,23 +120,13 @@ func TestRunDoCleanup(t *testing.T) {
 		Short: "Test command",
 	}
 
-	// Save original artifactsJson and restore after test
-	oldArtifactsJson := artifactsJson
-	defer func() { artifactsJson = oldArtifactsJson }()
-	artifactsJson = configPath
-
-	// Save original filter and restore after test
-	oldFilter := filter
-	defer func() { filter = oldFilter }()
-
-	// Save original exclude and restore after test
-	oldExclude := exclude
-	defer func() { exclude = oldExclude }()
+	// Answer the confirmation prompt
+	opts := &doCleanupOptions{root: &rootOptions{artifactsJson: configPath, assumeYes: true}}
 
 	// Test with no filter and no exclude
 	t.Run("NoFilterNoExclude", func(t *testing.T) {
-		filter = ""
-		exclude = ""
+		opts.selection.filter = ""
+		opts.selection.exclude = ""
 
 		// Verify files exist before cleanup
 		if _, err := os.Stat(file1); os.IsNotExist(err) {
@@ -260,7 +142,7 @@ func TestRunDoCleanup(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runDoCleanup(cmd, []string{})
+		runDoCleanup(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -296,8 +178,8 @@ func TestRunDoCleanup(t *testing.T) {
 
 	// Test with filter
 	t.Run("WithFilter", func(t *testing.T) {
-		filter = "asset1"
-		exclude = ""
+		opts.selection.filter = "asset1"
+		opts.selection.exclude = ""
 
 		// Verify files exist before cleanup
 		if _, err := os.Stat(file1); os.IsNotExist(err) {
@@ -313,7 +195,7 @@ func TestRunDoCleanup(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runDoCleanup(cmd, []string{})
+		runDoCleanup(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -347,8 +229,8 @@ func TestRunDoCleanup(t *testing.T) {
 
 	// Test with exclude
 	t.Run("WithExclude", func(t *testing.T) {
-		filter = ""
-		exclude = "asset1"
+		opts.selection.filter = ""
+		opts.selection.exclude = "asset1"
 
 		// Verify files exist before cleanup
 		if _, err := os.Stat(file1); os.IsNotExist(err) {
@@ -364,7 +246,7 @@ func TestRunDoCleanup(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runDoCleanup(cmd, []string{})
+		runDoCleanup(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -439,25 +321,14 @@ func TestRunDoCleanupRefusesProjectRoot(t *testing.T) {
 		Short: "Test command",
 	}
 
-	// Save and restore globals around the run.
-	oldArtifactsJson := artifactsJson
-	defer func() { artifactsJson = oldArtifactsJson }()
-	artifactsJson = configPath
-
-	oldFilter := filter
-	defer func() { filter = oldFilter }()
-	filter = ""
-
-	oldExclude := exclude
-	defer func() { exclude = oldExclude }()
-	exclude = ""
+	opts := &doCleanupOptions{root: &rootOptions{artifactsJson: configPath}}
 
 	// Capture stdout.
 	oldStdout := os.Stdout
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	runDoCleanup(cmd, []string{})
+	runDoCleanup(cmd, []string{}, opts)
 
 	w.Close()
 	os.Stdout = oldStdout
@@ -481,3 +352,151 @@ func TestRunDoCleanupRefusesProjectRoot(t *testing.T) {
 		t.Errorf("Expected output to indicate the asset was refused, got: %s", output)
 	}
 }
+
+func TestRunDoCleanupConfirmation(t *testing.T) {
+	tempDir := t.TempDir()
+	deployDir := filepath.Join(tempDir, "deploy", "asset1")
+	if err := os.MkdirAll(filepath.Join(deployDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create deploy directory: %v", err)
+	}
+	file := filepath.Join(deployDir, "sub", "file1.txt")
+	if err := os.WriteFile(file, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	opts := &doCleanupOptions{root: &rootOptions{artifactsJson: filepath.Join(tempDir, "artifacts.json")}}
+	jsonContent := `{
+		"application": "Test App",
+		"root_directory": "` + tempDir + `",
+		"repository": {"adapter": "Local", "options": {"root": "` + tempDir + `/repo"}},
+		"assets": [{"name": "asset1", "filename": "file1.tar.gz", "deploy_location": "deploy/asset1"}]
+	}`
+	if err := os.WriteFile(opts.root.artifactsJson, []byte(jsonContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	// Declining leaves everything in place
+	cmd := &cobra.Command{Use: "test"}
+	cmd.SetIn(strings.NewReader("n\n"))
+	output := captureStdout(t, func() { runDoCleanup(cmd, []string{}, opts) })
+	if !strings.Contains(output, deployDir+" (1 files, 12 B)") {
+		t.Errorf("Expected the directory to be listed with its usage, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Nothing was deleted") {
+		t.Errorf("Expected the cleanup to be cancelled, got:\n%s", output)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("Expected %s to be kept: %v", file, err)
+	}
+
+	cmd = &cobra.Command{Use: "test"}
+	cmd.SetIn(strings.NewReader("yes\n"))
+	output = captureStdout(t, func() { runDoCleanup(cmd, []string{}, opts) })
+	if !strings.Contains(output, "Successfully cleaned up") {
+		t.Errorf("Expected the cleanup to run, got:\n%s", output)
+	}
+	if entries, _ := os.ReadDir(deployDir); len(entries) != 0 {
+		t.Errorf("Expected the deploy directory to be emptied, got %d entries", len(entries))
+	}
+}
+
+func TestRunDoCleanupArtifactLocations(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"asset":   filepath.Join(tempDir, "deploy", "asset1", "file1.txt"),
+		"public":  filepath.Join(tempDir, "public", "index.html"),
+		"release": filepath.Join(tempDir, "app", "releases", "abc", "app.js"),
+	}
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, []byte("test content"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if err := os.Symlink(filepath.Join("releases", "abc"), filepath.Join(tempDir, "app", "current")); err != nil {
+		t.Fatalf("Failed to create release link: %v", err)
+	}
+
+	opts := &doCleanupOptions{root: &rootOptions{artifactsJson: filepath.Join(tempDir, "artifacts.json")}}
+	jsonContent := `{
+		"application": "Test App",
+		"root_directory": "` + tempDir + `",
+		"repository": {"adapter": "Local", "options": {"root": "` + tempDir + `/repo"}},
+		"artifacts": [
+			{"name": "web", "artifact_prefix": "web", "deploy_location": "public"},
+			{"name": "api", "artifact_prefix": "api", "deploy_location": "public"},
+			{"name": "app", "artifact_prefix": "app", "deploy_location": "app/current", "deploy_strategy": "symlink"}
+		],
+		"assets": [{"name": "asset1", "filename": "file1.tar.gz", "deploy_location": "deploy/asset1"}]
+	}`
+	if err := os.WriteFile(opts.root.artifactsJson, []byte(jsonContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	opts.root.assumeYes, opts.artifacts = true, true
+
+	cmd := &cobra.Command{Use: "test"}
+	output := captureStdout(t, func() { runDoCleanup(cmd, []string{}, opts) })
+	if !strings.Contains(output, "Cleaning up deploy location for web, api: public") {
+		t.Errorf("Expected the shared location to be cleaned once, got:\n%s", output)
+	}
+	if !strings.Contains(output, "links to a release managed by do-deploys") {
+		t.Errorf("Expected the symlink deploy to be refused, got:\n%s", output)
+	}
+	if _, err := os.Stat(files["public"]); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", files["public"], err)
+	}
+	for _, key := range []string{"asset", "release"} {
+		if _, err := os.Stat(files[key]); err != nil {
+			t.Errorf("Expected %s to be kept: %v", files[key], err)
+		}
+	}
+
+	// --all cleans the assets as well
+	opts.artifacts, opts.all = false, true
+	output = captureStdout(t, func() { runDoCleanup(cmd, []string{}, opts) })
+	if !strings.Contains(output, "Cleaning up deploy location for asset1") {
+		t.Errorf("Expected the asset to be cleaned with --all, got:\n%s", output)
+	}
+	if _, err := os.Stat(files["asset"]); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", files["asset"], err)
+	}
+}
+
+func TestRunDoCleanupBackup(t *testing.T) {
+	tempDir := t.TempDir()
+	file := filepath.Join(tempDir, "deploy", "asset1", "file1.txt")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatalf("Failed to create deploy directory: %v", err)
+	}
+	if err := os.WriteFile(file, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	opts := &doCleanupOptions{root: &rootOptions{artifactsJson: filepath.Join(tempDir, "artifacts.json")}}
+	jsonContent := `{
+		"application": "Test App",
+		"root_directory": "` + tempDir + `",
+		"repository": {"adapter": "Local", "options": {"root": "` + tempDir + `/repo"}},
+		"assets": [{"name": "asset1", "filename": "file1.tar.gz", "deploy_location": "deploy/asset1"}]
+	}`
+	if err := os.WriteFile(opts.root.artifactsJson, []byte(jsonContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	opts.root.assumeYes, opts.backup = true, true
+
+	output := captureStdout(t, func() { runDoCleanup(&cobra.Command{Use: "test"}, []string{}, opts) })
+	if !strings.Contains(output, "will be moved to a backup") {
+		t.Errorf("Expected the backup to be announced, got:\n%s", output)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be moved, got %v", file, err)
+	}
+	backups, err := filepath.Glob(filepath.Join(tempDir, ".slarty", "backups", "*", "deploy", "asset1", "file1.txt"))
+	if err != nil || len(backups) != 1 {
+		t.Errorf("Expected the file in one backup, got %v (%v)", backups, err)
+	}
+}
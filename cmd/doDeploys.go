@@ -22,237 +22,408 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	"fmt"
 	"github.com/dstockto/slarty/slarty"
+	"github.com/dstockto/slarty/slarty/archive"
 	"github.com/spf13/cobra"
-	"io"
 	"log"
 	"os"
-	"path/filepath"
-	"strings"
+	"time"
 )
 
-// maxDecompressedFileBytes caps the number of bytes extracted for any single
-// archive entry. It guards against decompression bombs that could otherwise
-// fill the disk. The limit is intentionally generous (5 GiB) so legitimate
-// large artifacts are unaffected; tune this constant if larger entries are
-// expected.
-const maxDecompressedFileBytes = 5 << 30 // 5 GiB
-
-// maxDecompressedFileBytesForTest holds the effective per-entry cap. It is
-// seeded from maxDecompressedFileBytes and exists as a variable only so tests
-// can lower the limit without writing gigabytes of data. Production code never
-// reassigns it.
-var maxDecompressedFileBytesForTest int64 = maxDecompressedFileBytes
-
-// doDeploysCmd represents the doDeploys command
-var doDeploysCmd = &cobra.Command{
-	Use:   "do-deploys",
-	Short: "Deploy artifacts from the repository",
-	Long: `Deploys artifacts from the repository to their deploy locations.
+// exitRestartFailed is the exit status of do-deploys when every artifact deployed
+// but a service could not be restarted
+const exitRestartFailed = 2
+
+// doDeploysOptions are the flags of the do-deploys command
+type doDeploysOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	variant   string
+	dirty     bool
+	// platform is the os/arch whose matrix artifacts are deployed
+	platform string
+	// pins and pinFile pin artifacts to the archive built from a given hash
+	pins    []string
+	pinFile string
+	// manifest is a manifest written by freeze naming the archives to deploy
+	manifest string
+	// latest deploys through the latest pointers in the repository, written for
+	// artifacts by do-builds --mark-latest
+	latest bool
+	// keep is how many old releases are kept for the symlink deploy strategy
+	keep int
+	// noRestart leaves the artifacts' services alone after a deploy
+	noRestart bool
+	// noMaintenance deploys without running the maintenance commands
+	noMaintenance bool
+	// backup moves the old contents of deploy locations into a backup directory
+	// instead of deleting or overwriting them
+	backup bool
+	// atomic stages every artifact before any of them is put in place
+	atomic bool
+	// environment is the environment deployed to, which may need the deploy to be
+	// approved
+	environment string
+	// approvalToken is the confirmation token from a second person, given instead
+	// of being asked for
+	approvalToken string
+	// resume skips the artifacts already deployed by a run that failed
+	resume bool
+}
+
+// newDoDeploysCmd creates the do-deploys command
+func newDoDeploysCmd(root *rootOptions) *cobra.Command {
+	opts := &doDeploysOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "do-deploys",
+		Short: "Deploy artifacts from the repository",
+		Long: `Deploys artifacts from the repository to their deploy locations.
 The command identifies the archives that match the current repository's code state,
 downloads them from the repository, and extracts them into the deploy_location directory.
-If an archive cannot be found in the repository, it will be treated as a fatal error.`,
-	Run: runDoDeploys,
+If an archive cannot be found in the repository, it will be treated as a fatal error.
+Use --filter to limit the deploy to matching artifacts and --exclude to leave matching
+artifacts out. Of the artifacts expanded from a matrix, only those for this machine's
+platform are deployed; use --platform os/arch to deploy a different one.
+Use --pin artifact=hash, or --pin-file with one pin per line, to deploy the archive
+built from an exact hash instead of the one matching the working tree, for example
+to roll back or deploy a hotfix.
+Use --manifest with a manifest written by slarty freeze to deploy exactly the archives
+it names, or --latest to deploy the artifacts last marked by do-builds --mark-latest.
+Neither needs a git checkout on the deploy host. Pins win over both.
+Artifacts with "deploy_strategy": "symlink" are extracted into a release directory and
+deploy_location is switched to it with a symlink; --keep sets how many old releases
+are kept.
+Once every artifact is deployed, the services listed for them are restarted. A failed
+restart does not undo the deploy: it is reported separately and do-deploys exits with
+status 2 instead of 1. Use --no-restart to skip restarts.
+With maintenance commands in artifacts.json, the application is put into maintenance
+mode before the first artifact is deployed and taken out of it after the last one and
+the restarts. A failed deploy leaves it in maintenance mode. Use --no-maintenance to
+skip the maintenance commands.
+Progress is saved as each artifact is deployed. If a run fails part way through, rerun
+it with --resume to skip the artifacts it had already deployed, as long as they would
+be deployed from the same archives.
+With --atomic, every artifact is downloaded and extracted into a staging directory
+before any deploy location is touched, and then all of them are switched into place
+together. If any artifact fails, nothing is changed, and if switching fails part way
+the artifacts already switched are rolled back.
+With --backup, each deploy location's old contents are moved into a timestamped
+directory under .slarty/backups before the new artifact is extracted, instead of being
+overwritten, and only the newest backups are kept. Symlink deploys already keep their
+old releases and are not backed up.
+When --environment names an environment listed as protected under "approvals" in
+artifacts.json, the deploy waits for approval before anything is changed: from the
+approval webhook, and from a second person's confirmation token, given with
+--approval-token or typed in when asked.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runDoDeploys(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "application3,application4", root.completeArtifactNames)
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+	cmd.Flags().BoolVar(&opts.dirty, "dirty", false, "name artifacts after the working tree, including unstaged changes, marking changed ones -dirty")
+	cmd.Flags().StringVar(&opts.platform, "platform", slarty.HostPlatform(), "os/arch of the matrix artifacts to deploy")
+	cmd.Flags().StringArrayVar(&opts.pins, "pin", nil, "deploy the archive built from this hash for an artifact (artifact=hash, repeatable)")
+	cmd.Flags().StringVar(&opts.pinFile, "pin-file", "", "read artifact=hash pins from this file, one per line")
+	cmd.Flags().StringVar(&opts.manifest, "manifest", "", "deploy the artifact names in this manifest written by slarty freeze")
+	cmd.Flags().BoolVar(&opts.latest, "latest", false, "deploy the artifacts named by the latest pointers from do-builds --mark-latest")
+	cmd.Flags().IntVar(&opts.keep, "keep", 5, "old releases to keep for artifacts deployed with the symlink strategy")
+	cmd.Flags().BoolVar(&opts.noRestart, "no-restart", false, "don't restart the artifacts' services after deploying")
+	cmd.Flags().BoolVar(&opts.noMaintenance, "no-maintenance", false, "don't run the maintenance mode commands around the deploy")
+	cmd.Flags().BoolVar(&opts.backup, "backup", false, "move each deploy location's old contents into a timestamped backup directory before deploying")
+	cmd.Flags().BoolVar(&opts.atomic, "atomic", false, "stage every artifact first and switch them into place together, or not at all")
+	cmd.Flags().StringVar(&opts.environment, "environment", "", "the environment being deployed to, which may need the deploy approved")
+	cmd.Flags().StringVar(&opts.approvalToken, "approval-token", "", "the confirmation token from a second person approving a deploy to a protected environment")
+	cmd.Flags().BoolVar(&opts.resume, "resume", false, "skip the artifacts already deployed by the last run if it failed part way through")
+
+	return cmd
 }
 
-func runDoDeploys(cmd *cobra.Command, args []string) {
+func runDoDeploys(cmd *cobra.Command, args []string, opts *doDeploysOptions) {
+	if opts.manifest != "" && opts.latest {
+		log.Fatalln("--manifest and --latest cannot be used together")
+	}
+	if opts.keep < 0 {
+		log.Fatalln("--keep must not be negative")
+	}
+
+	// Read the manifest first, since it decides the variant and channel
+	var manifest *slarty.Manifest
+	variant := opts.variant
+	if opts.manifest != "" {
+		var err error
+		manifest, err = slarty.ReadManifest(opts.manifest)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if variant == "" {
+			variant = manifest.Variant
+		}
+	}
+
 	// Read the artifacts configuration
-	artifactConfig, err := slarty.ReadArtifactsJson(artifactsJson)
+	artifactConfig, err := opts.root.readConfig()
 	if err != nil {
 		log.Fatalln(err)
 	}
+	if err := artifactConfig.ApplyVariant(variant); err != nil {
+		log.Fatalln(err)
+	}
+	artifactConfig.HashWorkingTree = opts.dirty
+	if manifest != nil && manifest.Channel != "" {
+		artifactConfig.Repository.Channel = manifest.Channel
+	}
 
 	// Create a repository adapter
-	repoAdapter, err := slarty.NewRepositoryAdapter(artifactConfig, local)
+	repoAdapter, err := opts.root.openRepository(artifactConfig)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	// Parse the filter flag
-	var filters []string
-	if filter != "" {
-		filters = strings.Split(filter, ",")
-	}
-
-	// Get the artifacts based on the filter
-	artifacts := artifactConfig.GetByArtifactsByNameWithFilter(filters)
+	// Get the artifacts based on the filter, keeping only matrix artifacts for the platform
+	artifacts := slarty.SelectPlatform(artifactConfig.SelectArtifacts(opts.selection.selection()), opts.platform)
 
 	if len(artifacts) == 0 {
 		fmt.Println("No artifacts found")
 		return
 	}
 
-	// Track artifact names
-	artifactNames := make(map[string]string)
-
-	// Get the artifact names and check if they exist in the repository
-	for _, artifact := range artifacts {
-		// Get the artifact name
-		artifactName, err := slarty.GetArtifactName(artifact.Name, artifactConfig)
-		if err != nil {
+	pins, err := deployPinsFromFlags(opts, artifactConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	// Only artifacts named after the current code can differ from it
+	if manifest == nil && !opts.latest {
+		var unpinned []slarty.ArtifactConfig
+		for _, artifact := range artifacts {
+			if _, ok := pins[artifact.Name]; !ok {
+				unpinned = append(unpinned, artifact)
+			}
+		}
+		if err := checkDirtyTree(os.Stderr, artifactConfig, unpinned); err != nil {
 			log.Fatalln(err)
 		}
+	}
 
-		artifactNames[artifact.Name] = artifactName
+	state, err := deployStateForRun(artifactConfig.RootDirectory, opts.resume)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-		// Check if the artifact exists in the repository
-		exists, err := repoAdapter.ArtifactExists(artifactName)
-		if err != nil {
-			log.Fatalf("Failed to check if artifact exists in repository: %v", err)
-		}
-		if !exists {
-			log.Fatalf("Artifact %s for %s not found in repository", artifactName, artifact.Name)
-		}
+	started := time.Now()
+	recorder := slarty.NewMetricsRecorder()
+	deployer := opts.root.newDeployer(artifactConfig, repoAdapter, recorder)
+	deployer.Observer = slarty.Observers{deployer.Observer, slarty.NewEventObserver(opts.root.events, "do-deploys")}
+	deployer.Keep = opts.keep
+	if opts.backup {
+		deployer.Backup = newRunBackup(artifactConfig)
 	}
 
-	// Deploy each artifact
-	for _, artifact := range artifacts {
-		artifactName := artifactNames[artifact.Name]
-		fmt.Printf("Found artifact %s for %s\n", artifactName, artifact.Name)
+	report, err := deployer.DeployAll(artifacts, slarty.DeployOptions{
+		ArtifactName: func(artifact slarty.ArtifactConfig) (string, error) {
+			return deployArtifactName(opts, artifact, artifactConfig, repoAdapter, pins, manifest)
+		},
+		// Only artifacts named after the current code can have a colliding hash
+		NamedAfterCode: func(artifact slarty.ArtifactConfig) bool {
+			_, pinned := pins[artifact.Name]
+			return !pinned && manifest == nil && !opts.latest
+		},
+		Approve: func(artifactNames map[string]string) error {
+			return approveDeploy(cmd, opts, artifactConfig, artifacts, artifactNames)
+		},
+		Maintenance:  opts.root.newMaintenance(artifactConfig, opts.noMaintenance),
+		State:        state,
+		Atomic:       opts.atomic,
+		SkipRestarts: opts.noRestart,
+	})
+	if err == nil {
+		pruneBackups(artifactConfig, deployer.Backup)
+	}
 
-		// Create a temporary file to download the artifact
-		tempFile, err := os.CreateTemp("", "slarty-*.tar.gz")
-		if err != nil {
-			log.Fatalf("Failed to create temporary file: %v", err)
-		}
-		tempFilePath := tempFile.Name()
-		tempFile.Close() // Close the file so we can reopen it for writing
+	summary := slarty.RunSummary{Command: "do-deploys", Application: artifactConfig.Application, Results: deployRunResults(report)}
+	summary.Duration = time.Since(started).Round(time.Millisecond)
+	sendNotification(artifactConfig, summary)
 
-		// Download the artifact from the repository
-		err = repoAdapter.RetrieveArtifact(artifactName, tempFilePath)
-		if err != nil {
-			os.Remove(tempFilePath)
-			log.Fatalf("Failed to retrieve artifact from repository: %v", err)
-		}
-		fmt.Println(" - Downloaded artifact")
+	restartsFailed := report.RestartsFailed()
+	if err != nil {
+		recorder.Observe("deploys_failed", 1, nil)
+	} else {
+		recorder.ObserveDuration("run_duration_seconds", time.Since(started), map[string]string{"command": "do-deploys"})
+	}
+	if restartsFailed > 0 {
+		recorder.Observe("restarts_failed", float64(restartsFailed), nil)
+	}
+	pushRunMetrics(artifactConfig, recorder)
 
-		// Create the deploy location directory if it doesn't exist
-		deployPath := filepath.Join(artifactConfig.RootDirectory, artifact.DeployLocation)
-		err = os.MkdirAll(deployPath, 0755)
-		if err != nil {
-			os.Remove(tempFilePath)
-			log.Fatalf("Failed to create deploy directory: %v", err)
-		}
+	if err != nil {
+		opts.root.flushAudit()
+		log.Fatalln(err)
+	}
+	// The deploy state is kept after a failed restart, so --resume only retries the
+	// restarts
+	if restartsFailed > 0 {
+		os.Exit(exitRestartFailed)
+	}
+}
 
-		// Extract the artifact to the deploy location
-		err = extractTarGz(tempFilePath, deployPath)
-		if err != nil {
-			os.Remove(tempFilePath)
-			log.Fatalf("Failed to extract artifact: %v", err)
+// deployRunResults returns the artifacts deployed and services restarted by a deploy
+// as the results of its run summary
+func deployRunResults(report slarty.DeployReport) []slarty.RunResult {
+	var results []slarty.RunResult
+	for _, deploy := range report.Deploys {
+		result := slarty.RunResult{Name: deploy.Name(), ArtifactName: deploy.ArtifactName, Status: deploy.Status, Duration: deploy.Duration}
+		if deploy.Err != nil {
+			result.Error = deploy.Err.Error()
 		}
-		fmt.Println(" - Extracted artifact")
-
-		// Delete the temporary file
-		os.Remove(tempFilePath)
-		fmt.Println(" - Deleted (tar.gz) artifact")
+		results = append(results, result)
 	}
+	for _, restart := range report.Restarts {
+		result := slarty.RunResult{Name: restart.Name, Status: restart.Status, Duration: restart.Duration}
+		if restart.Err != nil {
+			result.Error = restart.Err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
 }
 
-// extractTarGz extracts the contents of a tar.gz file to a destination directory
-func extractTarGz(tarGzPath, destDir string) error {
-	// Open the tar.gz file
-	file, err := os.Open(tarGzPath)
-	if err != nil {
-		return fmt.Errorf("failed to open tar.gz file: %w", err)
+// deployStateForRun returns the deploy state for a run. With resume it is the state
+// left by the last run that did not finish, if there is one. Otherwise any old state
+// is discarded, since the deploy locations may have changed since it was written.
+func deployStateForRun(root string, resume bool) (*slarty.DeployState, error) {
+	if !resume {
+		if err := slarty.RemoveDeployState(root); err != nil {
+			return nil, err
+		}
+		return slarty.NewDeployState(), nil
 	}
-	defer file.Close()
 
-	// Create a gzip reader
-	gzipReader, err := gzip.NewReader(file)
+	state, err := slarty.ReadDeployState(root)
 	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
+		return nil, err
 	}
-	defer gzipReader.Close()
-
-	// Create a tar reader
-	tarReader := tar.NewReader(gzipReader)
+	if state == nil {
+		fmt.Println("No unfinished deploy to resume, deploying every artifact")
+		return slarty.NewDeployState(), nil
+	}
+	fmt.Printf("Resuming the deploy started at %s\n", state.StartedAt.Local().Format(time.RFC1123))
+	return state, nil
+}
 
-	// Create destination directory if it doesn't exist
-	err = os.MkdirAll(destDir, 0755)
-	if err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+// deployArtifactName returns the artifact name to deploy for an artifact: from its pin
+// when it has one, then from the manifest when one is given or the latest pointer with
+// --latest, and otherwise from the hash of the current code
+func deployArtifactName(opts *doDeploysOptions, artifact slarty.ArtifactConfig, artifactConfig *slarty.ArtifactsConfig, repoAdapter slarty.RepositoryAdapter, pins map[string]string, manifest *slarty.Manifest) (string, error) {
+	if hash, ok := pins[artifact.Name]; ok {
+		fmt.Printf("Pinned %s to %s\n", artifact.Name, hash)
+		return slarty.ArtifactNameForHash(artifact, hash, artifactConfig)
 	}
 
-	// Extract each file
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break // End of archive
-		}
-		if err != nil {
-			return fmt.Errorf("failed to read tar header: %w", err)
+	if manifest != nil {
+		artifactName, ok := manifest.ArtifactName(artifact.Name)
+		if !ok {
+			return "", fmt.Errorf("artifact %s is not in manifest %s", artifact.Name, opts.manifest)
 		}
+		return artifactName, nil
+	}
 
-		err = extractTarFile(header, tarReader, destDir)
+	if opts.latest {
+		pointer, err := slarty.ReadLatestPointer(repoAdapter, artifact.ArtifactPrefix)
 		if err != nil {
-			return err
+			return "", err
 		}
+		fmt.Printf("Latest %s is %s\n", artifact.Name, pointer.ArtifactName)
+		return pointer.ArtifactName, nil
 	}
 
-	return nil
+	return opts.root.artifactName(artifact.Name, artifactConfig)
 }
 
-// extractTarFile extracts a single file from a tar.gz archive
-func extractTarFile(header *tar.Header, tarReader *tar.Reader, destDir string) error {
-	// Prepare the destination path
-	destPath := filepath.Join(destDir, header.Name)
-
-	// Guard against path traversal (Zip Slip): a malicious archive entry such as
-	// "../../etc/cron.d/x" must not be allowed to write outside destDir.
-	rel, err := filepath.Rel(destDir, destPath)
-	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
-		return fmt.Errorf("illegal path in archive: %s", header.Name)
-	}
-
-	// Handle different types of files
-	switch header.Typeflag {
-	case tar.TypeDir:
-		// Create directory
-		err := os.MkdirAll(destPath, 0755)
-		if err != nil {
-			return fmt.Errorf("failed to create directory: %w", err)
-		}
-	case tar.TypeReg:
-		// Create the directory for the file
-		err := os.MkdirAll(filepath.Dir(destPath), 0755)
+// deployPinsFromFlags returns the hashes artifacts are pinned to by --pin and
+// --pin-file. Pins on the command line win over those in the file. Pinning an
+// artifact that is not in the configuration is an error, so a typo is not ignored.
+func deployPinsFromFlags(opts *doDeploysOptions, artifactConfig *slarty.ArtifactsConfig) (map[string]string, error) {
+	pins := make(map[string]string)
+	if opts.pinFile != "" {
+		specs, err := slarty.ReadPinFile(opts.pinFile)
 		if err != nil {
-			return fmt.Errorf("failed to create directory for file: %w", err)
+			return nil, err
 		}
-
-		// Create the destination file. Mask the header mode to 0o777 so a
-		// malicious archive cannot set setuid/setgid/sticky or other special
-		// bits (those live above 0o777) on extracted files.
-		destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode&0o777))
+		filePins, err := slarty.ParsePins(specs)
 		if err != nil {
-			return fmt.Errorf("failed to create destination file: %w", err)
+			return nil, fmt.Errorf("%s: %w", opts.pinFile, err)
 		}
-		defer destFile.Close()
-
-		// Copy the file contents, bounding the amount written per entry to
-		// guard against decompression bombs. io.CopyN with a limit one byte
-		// over the cap lets us detect an entry that exceeds the cap.
-		limit := maxDecompressedFileBytesForTest
-		written, err := io.CopyN(destFile, tarReader, limit+1)
-		if err != nil && err != io.EOF {
-			return fmt.Errorf("failed to copy file contents: %w", err)
+		for name, hash := range filePins {
+			pins[name] = hash
 		}
-		if written > limit {
-			return fmt.Errorf("file %s in archive exceeds max size", header.Name)
+	}
+
+	flagPins, err := slarty.ParsePins(opts.pins)
+	if err != nil {
+		return nil, err
+	}
+	for name, hash := range flagPins {
+		pins[name] = hash
+	}
+
+	for name := range pins {
+		if _, err := artifactConfig.GetArtifactConfig(name); err != nil {
+			return nil, fmt.Errorf("pinned artifact %s is not in the configuration", name)
 		}
-	default:
-		// Skip other types of files (symlinks, etc.)
-		// Could be handled in the future if needed
 	}
 
-	return nil
+	return pins, nil
 }
 
-func init() {
-	rootCmd.AddCommand(doDeploysCmd)
+// newDeployer returns a deployer set up from the global flags. Each archive it
+// retrieves and each artifact it deploys is audited and, with a recorder, measured.
+func (o *rootOptions) newDeployer(artifactConfig *slarty.ArtifactsConfig, repoAdapter slarty.RepositoryAdapter, recorder *slarty.MetricsRecorder) *slarty.Deployer {
+	deployer := slarty.NewDeployer(artifactConfig, repoAdapter)
+	deployer.MaxFileBytes = o.maxFileBytes
+	deployer.SkipSpaceCheck = o.noSpaceCheck
+	deployer.Runner = o.commandRunner()
+	deployer.Observer = &deployObserver{PrintObserver: &slarty.PrintObserver{}, root: o, artifactConfig: artifactConfig, recorder: recorder}
+	return deployer
+}
 
-	// Here you will define your flags and configuration settings.
-	doDeploysCmd.Flags().StringVarP(&filter, "filter", "f", "", "-f \"application1,application2\"")
+// deployObserver prints a deploy's progress, and audits each download and records
+// its metrics
+type deployObserver struct {
+	*slarty.PrintObserver
+	root           *rootOptions
+	artifactConfig *slarty.ArtifactsConfig
+	recorder       *slarty.MetricsRecorder
+}
+
+func (o *deployObserver) OnDownloadComplete(artifact slarty.ArtifactConfig, artifactName string, size int64, elapsed time.Duration, err error) {
+	o.PrintObserver.OnDownloadComplete(artifact, artifactName, size, elapsed, err)
+	o.root.audit(o.artifactConfig, artifactAudit(o.artifactConfig, slarty.AuditRetrieve, "do-deploys", artifact, artifactName, err))
+	if err != nil || o.recorder == nil {
+		return
+	}
+	// The archive is extracted as it streams in, so one duration covers both
+	labels := map[string]string{"artifact": artifact.Name}
+	o.recorder.ObserveDuration("download_duration_seconds", elapsed, labels)
+	if !artifact.IsDocker() {
+		o.recorder.Observe("archive_size_bytes", float64(size), labels)
+	}
+}
+
+func (o *deployObserver) OnArtifactDeployed(result slarty.DeployResult) {
+	o.PrintObserver.OnArtifactDeployed(result)
+	if result.Step != "" || result.Status == slarty.DeploySkipped {
+		return
+	}
+	o.root.audit(o.artifactConfig, artifactAudit(o.artifactConfig, slarty.AuditDeploy, "do-deploys", result.Artifact, result.ArtifactName, result.Err))
+}
+
+// extractArchive extracts an archive file to a destination directory, telling its
+// format from the first bytes of the file rather than its name. A file is read where
+// it is, so no temporary copy is needed. Each file is capped at maxFileBytes, or
+// archive.DefaultMaxFileBytes when it is 0.
+func extractArchive(archivePath, destDir string, limits slarty.ExtractionLimits, maxFileBytes int64) error {
+	return archive.ExtractFile(archivePath, destDir, archive.ReadOptions{Limits: limits, MaxFileBytes: maxFileBytes})
 }
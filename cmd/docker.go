@@ -0,0 +1,49 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"github.com/dstockto/slarty/slarty"
+)
+
+// repositoryFor returns the repository an artifact is stored in: the registry for
+// docker artifacts and the configured repository for everything else
+func repositoryFor(artifact slarty.ArtifactConfig, artifactConfig *slarty.ArtifactsConfig, repoAdapter slarty.RepositoryAdapter) slarty.ArtifactChecker {
+	if artifact.IsDocker() {
+		return artifactConfig.DockerAdapter()
+	}
+
+	return repoAdapter
+}
+
+// prefetchArtifacts lets the repository find out whether the archives of the artifacts,
+// named by artifactNames in the same order, exist in bulk ahead of checking each one.
+// Docker images are left to their registry.
+func prefetchArtifacts(repoAdapter slarty.RepositoryAdapter, artifacts []slarty.ArtifactConfig, artifactNames []string) {
+	var archives []string
+	for i, artifact := range artifacts {
+		if !artifact.IsDocker() {
+			archives = append(archives, artifactNames[i])
+		}
+	}
+	slarty.PrefetchArtifacts(repoAdapter, archives)
+}
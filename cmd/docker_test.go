@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+// useFakeDocker points SLARTY_DOCKER at a stand-in that logs its arguments and keeps
+// pushed image references in a registry file, returning the log path
+func useFakeDocker(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "calls.log")
+	registry := filepath.Join(dir, "registry")
+
+	script := `#!/bin/sh
+echo "$@" >> "` + logPath + `"
+case "$1" in
+manifest)
+	grep -qx "$3" "` + registry + `" 2>/dev/null && exit 0
+	echo "manifest unknown" >&2
+	exit 1
+	;;
+push)
+	echo "$2" >> "` + registry + `"
+	;;
+image)
+	echo "sha256:${FAKE_DOCKER_DIGEST:-0000}"
+	;;
+run)
+	shift $(($# - 1))
+	sh -c "$1"
+	exit $?
+	;;
+esac
+exit 0
+`
+	path := filepath.Join(dir, "docker")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake docker: %v", err)
+	}
+	t.Setenv("SLARTY_DOCKER", path)
+
+	return logPath
+}
+
+func TestExecuteBuildsDocker(t *testing.T) {
+	logPath := useFakeDocker(t)
+
+	artifacts := `
+		{ "name": "api", "type": "docker", "image": "registry.example.com/api", "dockerfile": "src/api/Dockerfile", "context": "src/api", "directories": ["src/api"], "artifact_prefix": "api", "deploy_location": "registry.example.com/api:production" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/api"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}}
+
+	failed, output := captureExecuteBuilds(t, opts, config, repo)
+	if len(failed) != 0 {
+		t.Fatalf("Expected no failures, got %v:\n%s", failed, output)
+	}
+	if !strings.Contains(output, "Doing build for api - YES") {
+		t.Errorf("Expected a build for the missing image, got:\n%s", output)
+	}
+
+	imageRef, err := slarty.GetArtifactName("api", config)
+	if err != nil {
+		t.Fatalf("GetArtifactName failed: %v", err)
+	}
+
+	// The image is now in the registry, so a second run skips the build
+	_, output = captureExecuteBuilds(t, opts, config, repo)
+	if !strings.Contains(output, "Doing build for api - NO") {
+		t.Errorf("Expected the pushed image to be reused, got:\n%s", output)
+	}
+
+	artifact, _ := config.GetArtifactConfig("api")
+	captureStdout(t, func() {
+		if err := (&rootOptions{}).newDeployer(config, repo, nil).Deploy(*artifact, imageRef); err != nil {
+			t.Fatalf("Deploy failed: %v", err)
+		}
+	})
+
+	calls, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read calls: %v", err)
+	}
+	for _, want := range []string{
+		"build -t " + imageRef + " -f src/api/Dockerfile src/api",
+		"push " + imageRef,
+		"pull " + imageRef,
+		"tag " + imageRef + " registry.example.com/api:production",
+	} {
+		if !strings.Contains(string(calls), want+"\n") {
+			t.Errorf("Expected docker to be called with %q, got:\n%s", want, calls)
+		}
+	}
+
+	// Nothing was archived into the tar.gz repository
+	if exists, _ := repo.ArtifactExists(imageRef); exists {
+		t.Errorf("Expected docker artifacts to stay out of the tar.gz repository")
+	}
+}
+
+func TestExecuteBuildsInContainer(t *testing.T) {
+	logPath := useFakeDocker(t)
+	t.Setenv("FAKE_DOCKER_DIGEST", "c0ffee")
+
+	artifacts := `
+		{ "name": "app", "directories": ["src/app"], "command": "mkdir -p build/app && echo \"$APP_ENV\" > build/app/env", "output_directory": "build/app", "deploy_location": "deploy/app", "artifact_prefix": "app", "env": {"APP_ENV": "ci"}, "container": { "image": "golang:1.22-execute-builds-test" } }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src/app"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+
+	failed, output := captureExecuteBuilds(t, opts, config, repo)
+	if len(failed) != 0 {
+		t.Fatalf("Expected no failures, got %v:\n%s", failed, output)
+	}
+
+	calls, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read calls: %v", err)
+	}
+	if !strings.Contains(string(calls), "-e APP_ENV=ci golang:1.22-execute-builds-test sh -c") {
+		t.Errorf("Expected the build to run in the container, got:\n%s", calls)
+	}
+
+	artifactName, err := slarty.GetArtifactName("app", config)
+	if err != nil {
+		t.Fatalf("GetArtifactName failed: %v", err)
+	}
+	if exists, _ := repo.ArtifactExists(artifactName); !exists {
+		t.Errorf("Expected %s to be stored in the repository", artifactName)
+	}
+}
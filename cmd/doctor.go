@@ -0,0 +1,354 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// doctorMinFreeSpace is the least free disk space doctor is happy with, raised to
+// twice the largest recorded archive when that is larger
+const doctorMinFreeSpace = 1 << 30 // 1 GiB
+
+// doctorProbeArtifact is looked up in the repository to see whether it can be reached
+const doctorProbeArtifact = ".slarty-doctor"
+
+// doctorOptions are the flags of the doctor command
+type doctorOptions struct {
+	root *rootOptions
+	// noClean makes doctor only check, leaving stale temp files in place
+	noClean bool
+}
+
+// newDoctorCmd creates the doctor command
+func newDoctorCmd(root *rootOptions) *cobra.Command {
+	opts := &doctorOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check this machine can build and deploy, and clean up leftover files",
+		Long: `Checks what slarty needs on this machine: git, docker when artifacts use it, a
+repository that can be reached with the configured credentials, deploy locations that
+can be written to, and free disk space for archives. Each problem is reported with a
+hint on how to fix it, and the command exits non-zero if any are errors. Temp files and
+partial uploads left behind by runs that crashed more than a day ago are then removed
+from the project's .slarty/tmp, the user cache and a local repository, unless
+--no-clean is given.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runDoctor(cmd, args, opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.noClean, "no-clean", false, "only check, leaving stale temp files in place")
+
+	return cmd
+}
+
+// doctorFinding is the result of one of doctor's checks. Level is OK, WARNING or ERROR.
+type doctorFinding struct {
+	level   string
+	message string
+	hint    string
+}
+
+func doctorOK(format string, a ...interface{}) doctorFinding {
+	return doctorFinding{level: "OK", message: fmt.Sprintf(format, a...)}
+}
+
+func doctorWarning(hint, format string, a ...interface{}) doctorFinding {
+	return doctorFinding{level: "WARNING", message: fmt.Sprintf(format, a...), hint: hint}
+}
+
+func doctorError(hint, format string, a ...interface{}) doctorFinding {
+	return doctorFinding{level: "ERROR", message: fmt.Sprintf(format, a...), hint: hint}
+}
+
+func runDoctor(cmd *cobra.Command, args []string, opts *doctorOptions) {
+	findings := []doctorFinding{checkGitInstalled()}
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		findings = append(findings, doctorError("run slarty validate, or pass --artifacts with the path to artifacts.json", "unable to read %s: %v", opts.root.artifactsJson, err))
+	} else {
+		findings = append(findings, doctorChecks(opts.root, artifactConfig)...)
+	}
+
+	errCount, warnCount := writeDoctorFindings(os.Stdout, findings)
+
+	if artifactConfig != nil && !opts.noClean {
+		if err := doctorCleanup(os.Stdout, artifactConfig, opts.root.local); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: failed to clean up stale temp files: %v\n", err)
+		}
+	}
+
+	if errCount == 0 && warnCount == 0 {
+		fmt.Println("\nEverything looks good")
+	} else {
+		fmt.Printf("\nFound %d error(s) and %d warning(s)\n", errCount, warnCount)
+	}
+	if errCount > 0 {
+		os.Exit(1)
+	}
+}
+
+// doctorChecks checks what the configuration needs of this machine
+func doctorChecks(root *rootOptions, artifactConfig *slarty.ArtifactsConfig) []doctorFinding {
+	findings := []doctorFinding{checkGitRepository(artifactConfig)}
+	if finding, needed := checkDocker(artifactConfig); needed {
+		findings = append(findings, finding)
+	}
+	findings = append(findings, checkRepository(root, artifactConfig)...)
+	findings = append(findings, checkDeployLocations(artifactConfig)...)
+	findings = append(findings, checkDiskSpace(artifactConfig)...)
+	return findings
+}
+
+// writeDoctorFindings writes each finding, and its hint, to w and returns the number of
+// errors and warnings
+func writeDoctorFindings(w io.Writer, findings []doctorFinding) (errCount, warnCount int) {
+	for _, finding := range findings {
+		fmt.Fprintf(w, "%s: %s\n", finding.level, finding.message)
+		if finding.hint != "" {
+			fmt.Fprintf(w, "  hint: %s\n", finding.hint)
+		}
+		switch finding.level {
+		case "ERROR":
+			errCount++
+		case "WARNING":
+			warnCount++
+		}
+	}
+	return errCount, warnCount
+}
+
+// checkGitInstalled checks for the git binary. Without it, slarty reads repositories
+// with go-git, which gives the same hashes.
+func checkGitInstalled() doctorFinding {
+	path, err := exec.LookPath("git")
+	if err != nil {
+		return doctorWarning("install git so slarty uses the same git as the rest of your tools", "git is not installed, so slarty reads the repository with its built-in git support")
+	}
+	version, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return doctorError("reinstall git, or remove it so slarty uses its built-in git support", "%s does not run: %v", path, err)
+	}
+	return doctorOK("%s", strings.TrimSpace(string(version)))
+}
+
+// checkGitRepository checks the project is a git checkout, since artifacts are named
+// after the files git tracks
+func checkGitRepository(artifactConfig *slarty.ArtifactsConfig) doctorFinding {
+	top, err := slarty.GitRoot(artifactConfig.RootDirectory)
+	if err != nil {
+		return doctorError("run slarty in a git checkout of the project; artifacts are named after the files git tracks", "%s is not in a git repository: %v", artifactConfig.RootDirectory, err)
+	}
+	return doctorOK("%s is in the git repository at %s", artifactConfig.RootDirectory, top)
+}
+
+// checkDocker checks for the docker CLI when an artifact is a docker image or builds in
+// a container, and reports whether it is needed at all
+func checkDocker(artifactConfig *slarty.ArtifactsConfig) (doctorFinding, bool) {
+	var users []string
+	for _, artifact := range artifactConfig.Artifacts {
+		if artifact.IsDocker() || artifact.Container != nil {
+			users = append(users, artifact.Name)
+		}
+	}
+	if len(users) == 0 {
+		return doctorFinding{}, false
+	}
+
+	binary := os.Getenv("SLARTY_DOCKER")
+	if binary == "" {
+		binary = "docker"
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		return doctorError("install docker, or set SLARTY_DOCKER to a compatible CLI such as podman", "%s is needed by %s but is not installed", binary, strings.Join(users, ", ")), true
+	}
+	return doctorOK("%s is installed for %s", binary, strings.Join(users, ", ")), true
+}
+
+// checkRepository checks the repository can be opened and an artifact looked up in it,
+// and that a local repository can be written to
+func checkRepository(root *rootOptions, artifactConfig *slarty.ArtifactsConfig) []doctorFinding {
+	root.applyRepositoryFlags(artifactConfig)
+	resolved, err := artifactConfig.ResolvedRepository(root.local)
+	if err != nil {
+		return []doctorFinding{doctorError("run slarty validate to check the repository section", "repository: %v", err)}
+	}
+	hint := "check the repository section of artifacts.json"
+	location := resolved.Options.Root
+	if resolved.Adapter == "s3" {
+		hint = "check the region, bucket-name and profile, and that the AWS credentials can read the bucket (s3:GetObject and s3:ListBucket)"
+		location = "s3://" + resolved.Options.BucketName + "/" + resolved.Options.PathPrefix
+	}
+
+	repoAdapter, err := root.openRepository(artifactConfig)
+	if err != nil {
+		return []doctorFinding{doctorError(hint, "unable to open the repository: %v", err)}
+	}
+	if _, err := repoAdapter.ArtifactExists(doctorProbeArtifact); err != nil {
+		return []doctorFinding{doctorError(hint, "unable to reach the repository at %s: %v", location, err)}
+	}
+
+	findings := []doctorFinding{doctorOK("the repository at %s can be reached", location)}
+	if resolved.Adapter == "local" {
+		if err := checkWritable(location); err != nil {
+			findings = append(findings, doctorError("give this user write access to the repository root", "unable to store artifacts in %s: %v", location, err))
+		}
+	}
+	return findings
+}
+
+// checkDeployLocations checks each deploy location of the artifacts and assets can be
+// written to, or created
+func checkDeployLocations(artifactConfig *slarty.ArtifactsConfig) []doctorFinding {
+	type target struct{ name, location string }
+	var targets []target
+	for _, artifact := range artifactConfig.Artifacts {
+		if !artifact.IsDocker() {
+			targets = append(targets, target{artifact.Name, artifact.DeployLocation})
+		}
+	}
+	for _, asset := range artifactConfig.Assets {
+		targets = append(targets, target{asset.Name, asset.DeployLocation})
+	}
+
+	var findings []doctorFinding
+	checked := make(map[string]bool)
+	writable := 0
+	for _, t := range targets {
+		if err := slarty.CheckLocation(t.location); err != nil {
+			findings = append(findings, doctorWarning("set the placeholder with --var or its environment variable before deploying", "%s: %v", t.name, err))
+			continue
+		}
+		path := filepath.Join(artifactConfig.RootDirectory, t.location)
+		if checked[path] {
+			continue
+		}
+		checked[path] = true
+		if err := checkWritable(path); err != nil {
+			findings = append(findings, doctorError("give the deploying user write access, such as with chown, or deploy as the owner", "unable to deploy %s to %s: %v", t.name, path, err))
+			continue
+		}
+		writable++
+	}
+	if writable > 0 {
+		findings = append(findings, doctorOK("%d deploy location(s) can be written to", writable))
+	}
+	return findings
+}
+
+// checkWritable checks a file can be created in dir, or in the nearest directory above
+// it that exists, when dir does not exist yet
+func checkWritable(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) || filepath.Dir(dir) == dir {
+			return err
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	probe, err := os.CreateTemp(dir, ".slarty-doctor-*")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// checkDiskSpace checks there is room for archives in the project, where temp archives
+// are kept and artifacts are deployed, and in the user cache, where the build cache is
+func checkDiskSpace(artifactConfig *slarty.ArtifactsConfig) []doctorFinding {
+	needed := int64(doctorMinFreeSpace)
+	for _, record := range readLatestBuilds(artifactConfig) {
+		needed = max(needed, 2*record.Size)
+	}
+
+	dirs := []string{artifactConfig.RootDirectory}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		dirs = append(dirs, cacheDir)
+	}
+
+	var findings []doctorFinding
+	for _, dir := range dirs {
+		free, err := slarty.FreeDiskSpace(dir)
+		if err != nil {
+			findings = append(findings, doctorWarning("", "unable to check the free space in %s: %v", dir, err))
+			continue
+		}
+		if free < needed {
+			findings = append(findings, doctorWarning("free up space; archives are written to .slarty/tmp while they build and do-builds --cache keeps them in the user cache", "only %s free in %s, less than the %s suggested", formatBytes(free), dir, formatBytes(needed)))
+			continue
+		}
+		findings = append(findings, doctorOK("%s free in %s", formatBytes(free), dir))
+	}
+	return findings
+}
+
+// doctorCleanup removes temp files and partial uploads left behind by runs that crashed
+// more than slarty.StaleTempFileAge ago, and reports how many went from where. local
+// is set by --local.
+func doctorCleanup(w io.Writer, artifactConfig *slarty.ArtifactsConfig, local bool) error {
+	tempDir := filepath.Join(artifactConfig.RootDirectory, slarty.WorkDirName, "tmp")
+	removed, err := slarty.CleanStaleTempFiles(tempDir, slarty.StaleTempFileAge)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	reportCleanup(w, removed, tempDir)
+
+	var dirs []string
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		dirs = append(dirs, filepath.Join(cacheDir, "slarty"))
+	}
+	if resolved, err := artifactConfig.ResolvedRepository(local); err == nil && resolved.Adapter == "local" && resolved.Options.Root != "" {
+		dirs = append(dirs, resolved.Options.Root)
+	}
+	for _, dir := range dirs {
+		removed, err := slarty.CleanStalePartialFiles(dir, slarty.StaleTempFileAge)
+		if err != nil {
+			return err
+		}
+		reportCleanup(w, removed, dir)
+	}
+	return nil
+}
+
+func reportCleanup(w io.Writer, removed int, dir string) {
+	if removed > 0 {
+		fmt.Fprintf(w, "Removed %d stale temp file(s) from %s\n", removed, dir)
+	}
+}
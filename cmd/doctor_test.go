@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestDoctorCommand(t *testing.T) {
+	cmd := newDoctorCmd(&rootOptions{})
+	if cmd.Use != "doctor" {
+		t.Errorf("Expected doctor command Use to be 'doctor', got '%s'", cmd.Use)
+	}
+	if cmd.Run == nil {
+		t.Error("doctor command Run function should not be nil")
+	}
+	if cmd.Flags().Lookup("no-clean") == nil {
+		t.Error("doctor command should have 'no-clean' flag")
+	}
+}
+
+func TestDoctorChecks(t *testing.T) {
+	config := writeConfig(t, `{
+		"application": "app",
+		"root_directory": ".",
+		"repository": {"adapter": "local", "options": {"root": "repo"}},
+		"artifacts": [
+			{"name": "web", "directories": ["web"], "command": "true", "output_directory": "web/dist", "deploy_location": "public/web", "artifact_prefix": "web"},
+			{"name": "api", "directories": ["api"], "command": "true", "output_directory": "api/dist", "deploy_location": "blocked/api", "artifact_prefix": "api"}
+		],
+		"assets": [{"name": "logo", "filename": "logo.tar.gz", "deploy_location": "{SITE}/logo"}]
+	}`)
+	config.Repository.Options.Root = filepath.Join(config.RootDirectory, "repo")
+	// A file in the way of a deploy location
+	os.WriteFile(filepath.Join(config.RootDirectory, "blocked"), nil, 0644)
+
+	var out bytes.Buffer
+	errCount, warnCount := writeDoctorFindings(&out, doctorChecks(&rootOptions{}, config))
+	output := out.String()
+
+	for _, expected := range []string{
+		"ERROR: " + config.RootDirectory + " is not in a git repository",
+		"OK: the repository at " + config.Repository.Options.Root + " can be reached",
+		"ERROR: unable to deploy api to " + filepath.Join(config.RootDirectory, "blocked", "api"),
+		"  hint: give the deploying user write access",
+		"WARNING: logo:",
+		"OK: 1 deploy location(s) can be written to",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in the findings, got:\n%s", expected, output)
+		}
+	}
+	if errCount != 2 || warnCount < 1 {
+		t.Errorf("Expected 2 errors and a warning, got %d and %d:\n%s", errCount, warnCount, output)
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkWritable(filepath.Join(dir, "not", "yet", "created")); err != nil {
+		t.Errorf("Expected a location that can be created to be writable, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "not")); !os.IsNotExist(err) {
+		t.Errorf("Expected checking a location not to create it")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Expected the probe file to be removed, got %v", entries)
+	}
+
+	os.WriteFile(filepath.Join(dir, "file"), nil, 0644)
+	if err := checkWritable(filepath.Join(dir, "file", "below")); err == nil {
+		t.Errorf("Expected a location below a file not to be writable")
+	}
+}
+
+func TestDoctorCleanup(t *testing.T) {
+	config := writeConfig(t, `{
+		"application": "app",
+		"root_directory": ".",
+		"repository": {"adapter": "local", "options": {"root": "repo"}},
+		"artifacts": []
+	}`)
+	root := config.RootDirectory
+	config.Repository.Options.Root = filepath.Join(root, "repo")
+	old := time.Now().Add(-2 * slarty.StaleTempFileAge)
+	stale := []string{
+		filepath.Join(root, slarty.WorkDirName, "tmp", "slarty-1.tar.gz"),
+		filepath.Join(root, "repo", ".web-1.tar.gz.1.tmp"),
+	}
+	kept := filepath.Join(root, "repo", "web-1.tar.gz")
+	for _, path := range append(stale, kept) {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, nil, 0644)
+		os.Chtimes(path, old, old)
+	}
+
+	var out bytes.Buffer
+	if err := doctorCleanup(&out, config, false); err != nil {
+		t.Fatalf("doctorCleanup failed: %v", err)
+	}
+	for _, path := range stale {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("Expected the stored artifact to be kept: %v", err)
+	}
+	if !strings.Contains(out.String(), "Removed 1 stale temp file(s) from "+filepath.Join(root, "repo")) {
+		t.Errorf("Expected the cleanup to be reported, got:\n%s", out.String())
+	}
+}
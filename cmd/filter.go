@@ -0,0 +1,136 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// Orders for the rows of artifact tables
+const (
+	sortByConfig = "config"
+	sortByName   = "name"
+)
+
+// selectionOptions are the --filter, --exclude, --regex, --group, --tag and
+// --exclude-tag flags choosing the entries a command works on. Each command binds its own, so the flags
+// given to one command are never seen by another.
+type selectionOptions struct {
+	filter  string
+	exclude string
+	// regex switches filter and exclude from glob patterns to regular expressions
+	regex bool
+	// groups holds the comma separated --group value
+	groups string
+	// tags and excludeTags hold the comma separated --tag and --exclude-tag values
+	tags        string
+	excludeTags string
+}
+
+// bindSelectionFlags adds the selection flags to cmd, storing them in opts. The
+// examples are the names shown in the --filter and --exclude help, and --exclude is
+// left out when they have none. complete completes the names, and root the groups.
+func bindSelectionFlags(cmd *cobra.Command, root *rootOptions, opts *selectionOptions, filterExample, excludeExample string, complete func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective)) {
+	cmd.Flags().StringVarP(&opts.filter, "filter", "f", "", "-f \""+filterExample+"\"")
+	if excludeExample != "" {
+		cmd.Flags().StringVarP(&opts.exclude, "exclude", "e", "", "-e \""+excludeExample+"\"")
+	}
+	cmd.Flags().BoolVar(&opts.regex, "regex", false, "treat filter patterns as regular expressions instead of globs")
+	cmd.Flags().StringVar(&opts.groups, "group", "", "only include entries in one of these groups (comma separated)")
+	cmd.Flags().StringVar(&opts.tags, "tag", "", "only include entries with one of these tags (comma separated)")
+	cmd.Flags().StringVar(&opts.excludeTags, "exclude-tag", "", "leave out entries with any of these tags (comma separated)")
+
+	cmd.RegisterFlagCompletionFunc("filter", complete)
+	if excludeExample != "" {
+		cmd.RegisterFlagCompletionFunc("exclude", complete)
+	}
+	cmd.RegisterFlagCompletionFunc("group", root.completeGroupNames)
+}
+
+// selection builds the selection the flags describe
+func (o selectionOptions) selection() slarty.Selection {
+	return slarty.Selection{
+		Names:        o.nameMatcher(o.filter),
+		ExcludeNames: o.nameMatcher(o.exclude),
+		Groups:       splitList(o.groups),
+		Tags:         splitList(o.tags),
+		ExcludeTags:  splitList(o.excludeTags),
+	}
+}
+
+// nameMatcher turns a comma separated --filter or --exclude value into a matcher,
+// honouring --regex. An invalid regular expression is fatal.
+func (o selectionOptions) nameMatcher(value string) *slarty.NameMatcher {
+	matcher, err := slarty.NewNameMatcher(strings.Split(value, ","), o.regex)
+	if err != nil {
+		log.Fatalf("Invalid filter: %v", err)
+	}
+	return matcher
+}
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// sortedArtifacts orders artifacts for a table by the --sort order by. An unknown
+// order is fatal.
+func sortedArtifacts(artifacts []slarty.ArtifactConfig, by string) []slarty.ArtifactConfig {
+	sorted, err := sortArtifacts(artifacts, by)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return sorted
+}
+
+// sortArtifacts returns artifacts in the order they are in artifacts.json, or sorted
+// by name ignoring case. Either way the order is the same on every run, so tables
+// can be compared with diff.
+func sortArtifacts(artifacts []slarty.ArtifactConfig, by string) ([]slarty.ArtifactConfig, error) {
+	switch by {
+	case "", sortByConfig:
+		return artifacts, nil
+	case sortByName:
+		sorted := append([]slarty.ArtifactConfig(nil), artifacts...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, b := strings.ToLower(sorted[i].Name), strings.ToLower(sorted[j].Name)
+			if a != b {
+				return a < b
+			}
+			return sorted[i].Name < sorted[j].Name
+		})
+		return sorted, nil
+	}
+	return nil, fmt.Errorf("unknown sort %q; use %s or %s", by, sortByConfig, sortByName)
+}
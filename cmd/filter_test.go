@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestSortArtifacts(t *testing.T) {
+	artifacts := []slarty.ArtifactConfig{{Name: "zebra"}, {Name: "Mango"}, {Name: "apple"}, {Name: "mango"}}
+	names := func(artifacts []slarty.ArtifactConfig) []string {
+		var names []string
+		for _, artifact := range artifacts {
+			names = append(names, artifact.Name)
+		}
+		return names
+	}
+
+	for _, by := range []string{"", sortByConfig} {
+		sorted, err := sortArtifacts(artifacts, by)
+		if err != nil {
+			t.Fatalf("sortArtifacts(%q) failed: %v", by, err)
+		}
+		if expected := []string{"zebra", "Mango", "apple", "mango"}; !reflect.DeepEqual(names(sorted), expected) {
+			t.Errorf("Expected config order %v for %q, got %v", expected, by, names(sorted))
+		}
+	}
+
+	sorted, err := sortArtifacts(artifacts, sortByName)
+	if err != nil {
+		t.Fatalf("sortArtifacts failed: %v", err)
+	}
+	if expected := []string{"apple", "Mango", "mango", "zebra"}; !reflect.DeepEqual(names(sorted), expected) {
+		t.Errorf("Expected name order %v, got %v", expected, names(sorted))
+	}
+	if artifacts[0].Name != "zebra" {
+		t.Errorf("Expected the artifacts to be left in config order")
+	}
+
+	if _, err := sortArtifacts(artifacts, "size"); err == nil {
+		t.Errorf("Expected an unknown sort to fail")
+	}
+}
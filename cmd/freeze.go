@@ -0,0 +1,118 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// freezeOptions are the flags of the freeze command
+type freezeOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	output    string
+	variant   string
+}
+
+// newFreezeCmd creates the freeze command
+func newFreezeCmd(root *rootOptions) *cobra.Command {
+	opts := &freezeOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "freeze",
+		Short: "Write a manifest of the artifact names for the current code",
+		Long: `Writes a JSON manifest mapping each artifact to the archive name (or image
+reference) that matches the current repository's code state. Run it alongside
+do-builds, then deploy with do-deploys --manifest on hosts that have no git checkout:
+the manifest says exactly which archives to deploy, so no hashes are computed there.
+
+The manifest also records the variant and channel the names were resolved for, which
+do-deploys uses unless --variant or --channel are given.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runFreeze(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "write the manifest to this file instead of stdout")
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "application3,application4", root.completeArtifactNames)
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+
+	return cmd
+}
+
+func runFreeze(cmd *cobra.Command, args []string, opts *freezeOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := artifactConfig.ApplyVariant(opts.variant); err != nil {
+		log.Fatalln(err)
+	}
+
+	manifest, err := opts.root.freezeArtifacts(artifactConfig, artifactConfig.SelectArtifacts(opts.selection.selection()), opts.variant)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var out io.Writer = os.Stdout
+	if opts.output != "" {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := slarty.WriteManifest(out, manifest); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// freezeArtifacts resolves the artifact name of each artifact into a manifest for
+// the variant, recording the channel when one was given by --channel
+func (o *rootOptions) freezeArtifacts(artifactConfig *slarty.ArtifactsConfig, artifacts []slarty.ArtifactConfig, variant string) (*slarty.Manifest, error) {
+	manifest := &slarty.Manifest{
+		Application: artifactConfig.Application,
+		Variant:     variant,
+		Channel:     artifactConfig.Repository.Channel,
+		Artifacts:   make([]slarty.ManifestEntry, 0, len(artifacts)),
+	}
+	if o.channel != "" {
+		manifest.Channel = o.channel
+	}
+
+	for _, artifact := range artifacts {
+		artifactName, err := o.artifactName(artifact.Name, artifactConfig)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Artifacts = append(manifest.Artifacts, slarty.ManifestEntry{Name: artifact.Name, ArtifactName: artifactName})
+	}
+	return manifest, nil
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestFreezeCommand(t *testing.T) {
+	cmd := newFreezeCmd(&rootOptions{})
+	if cmd.Use != "freeze" {
+		t.Errorf("Expected freeze command Use to be 'freeze', got '%s'", cmd.Use)
+	}
+
+	if cmd.Run == nil {
+		t.Error("freeze command Run function should not be nil")
+	}
+
+	flags := cmd.Flags()
+	for _, name := range []string{"output", "filter", "exclude", "regex", "tag", "exclude-tag", "variant"} {
+		if flags.Lookup(name) == nil {
+			t.Errorf("freeze command should have '%s' flag", name)
+		}
+	}
+}
+
+func TestFreezeArtifacts(t *testing.T) {
+	artifacts := `
+		{ "name": "web", "directories": ["src/web"], "command": "echo built", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web" },
+		{ "name": "api", "directories": ["src/api"], "command": "echo built", "output_directory": "build/api", "deploy_location": "deploy/api", "artifact_prefix": "api" }`
+	config, _ := buildTestSetup(t, artifacts, []string{"src/web", "src/api"})
+
+	manifest, err := (&rootOptions{channel: "release/1.4"}).freezeArtifacts(config, config.Artifacts, "")
+	if err != nil {
+		t.Fatalf("freezeArtifacts failed: %v", err)
+	}
+
+	if manifest.Application != "Test App" || manifest.Channel != "release/1.4" {
+		t.Errorf("Expected the application and channel to be recorded, got %+v", manifest)
+	}
+	if len(manifest.Artifacts) != 2 {
+		t.Fatalf("Expected 2 manifest entries, got %d", len(manifest.Artifacts))
+	}
+	for _, entry := range manifest.Artifacts {
+		expected, err := slarty.GetArtifactName(entry.Name, config)
+		if err != nil {
+			t.Fatalf("GetArtifactName failed: %v", err)
+		}
+		if entry.ArtifactName != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, entry.Name, entry.ArtifactName)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// generateCIOptions are the flags of the generate-ci command
+type generateCIOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	image     string
+	stage     string
+	output    string
+	variant   string
+}
+
+// newGenerateCICmd creates the generate-ci command
+func newGenerateCICmd(root *rootOptions) *cobra.Command {
+	opts := &generateCIOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "generate-ci gitlab",
+		Short: "Generate a CI pipeline with jobs only for artifacts that need building",
+		Long: `Generates a pipeline definition containing a build job for each artifact that
+needs a build, as decided by should-build. Artifacts already in the repository get no
+job, so the pipeline scales with what actually changed.
+
+Currently the only supported CI system is gitlab, which writes a child pipeline to
+trigger from the parent pipeline. Each job runs slarty do-builds for its artifact,
+which is also available to the job as $SLARTY_ARTIFACT, along with its archive name as
+$SLARTY_ARTIFACT_NAME. When nothing needs building a single no-op job is written,
+since GitLab rejects an empty pipeline.`,
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"gitlab"},
+		Run: func(cmd *cobra.Command, args []string) {
+			runGenerateCI(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().StringVar(&opts.image, "image", "", "image for the generated jobs (defaults to the pipeline's image)")
+	cmd.Flags().StringVar(&opts.stage, "stage", "build", "stage for the generated jobs")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "", "write the pipeline to this file instead of stdout")
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "application3,application4", root.completeArtifactNames)
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+
+	return cmd
+}
+
+func runGenerateCI(cmd *cobra.Command, args []string, opts *generateCIOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := artifactConfig.ApplyVariant(opts.variant); err != nil {
+		log.Fatalln(err)
+	}
+
+	// Create a repository adapter
+	repoAdapter, err := opts.root.openRepository(artifactConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	artifacts := artifactConfig.SelectArtifacts(opts.selection.selection())
+	decisions := opts.root.decideBuilds(artifacts, artifactConfig, repoAdapter)
+
+	var out io.Writer = os.Stdout
+	if opts.output != "" {
+		f, err := os.Create(opts.output)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	command, variables := opts.gitLabBuildCommand()
+	if err := opts.writeGitLabPipeline(out, decisions, command, variables); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// gitLabBuildCommand returns the command each job runs and the pipeline variables it
+// uses to pass on the flags that change how artifacts are read and named
+func (o *generateCIOptions) gitLabBuildCommand() (string, map[string]string) {
+	command := `slarty do-builds --filter "$SLARTY_ARTIFACT"`
+	variables := make(map[string]string)
+
+	if o.root.artifactsJson != "./artifacts.json" {
+		command += ` --artifacts "$SLARTY_ARTIFACTS_JSON"`
+		variables["SLARTY_ARTIFACTS_JSON"] = o.root.artifactsJson
+	}
+	if o.root.local {
+		command += " --local"
+	}
+	if o.variant != "" {
+		command += ` --variant "$SLARTY_VARIANT"`
+		variables["SLARTY_VARIANT"] = o.variant
+	}
+	if o.root.channel != "" {
+		command += ` --channel "$SLARTY_CHANNEL"`
+		variables["SLARTY_CHANNEL"] = o.root.channel
+	}
+
+	return command, variables
+}
+
+// writeGitLabPipeline writes a GitLab CI child pipeline with a job running command
+// for each artifact that needs a build, and variables set for the whole pipeline
+func (o *generateCIOptions) writeGitLabPipeline(w io.Writer, decisions []buildDecision, command string, variables map[string]string) error {
+	var b strings.Builder
+	b.WriteString("# Generated by slarty generate-ci gitlab\n")
+	fmt.Fprintf(&b, "stages:\n  - %s\n", yamlString(o.stage))
+
+	if len(variables) > 0 {
+		names := make([]string, 0, len(variables))
+		for name := range variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		b.WriteString("\nvariables:\n")
+		for _, name := range names {
+			fmt.Fprintf(&b, "  %s: %s\n", name, yamlString(variables[name]))
+		}
+	}
+
+	jobs := 0
+	for _, decision := range decisions {
+		if !decision.BuildNeeded {
+			continue
+		}
+		jobs++
+
+		fmt.Fprintf(&b, "\n%s:\n", yamlString("build:"+decision.Application))
+		fmt.Fprintf(&b, "  stage: %s\n", yamlString(o.stage))
+		if o.image != "" {
+			fmt.Fprintf(&b, "  image: %s\n", yamlString(o.image))
+		}
+		b.WriteString("  variables:\n")
+		fmt.Fprintf(&b, "    SLARTY_ARTIFACT: %s\n", yamlString(decision.Application))
+		fmt.Fprintf(&b, "    SLARTY_ARTIFACT_NAME: %s\n", yamlString(decision.ArtifactName))
+		fmt.Fprintf(&b, "  script:\n    - %s\n", yamlString(command))
+	}
+
+	if jobs == 0 {
+		fmt.Fprintf(&b, "\n%s:\n", yamlString("slarty:nothing-to-build"))
+		fmt.Fprintf(&b, "  stage: %s\n", yamlString(o.stage))
+		if o.image != "" {
+			fmt.Fprintf(&b, "  image: %s\n", yamlString(o.image))
+		}
+		fmt.Fprintf(&b, "  script:\n    - %s\n", yamlString("echo All artifacts are already in the repository"))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// yamlString quotes s as a YAML double-quoted scalar. JSON strings are valid YAML.
+func yamlString(s string) string {
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
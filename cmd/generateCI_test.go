@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteGitLabPipeline(t *testing.T) {
+	opts := &generateCIOptions{image: "golang:1.22", stage: "build"}
+
+	decisions := []buildDecision{
+		{Application: "api", ArtifactName: "api-abc.tar.gz", BuildNeeded: true},
+		{Application: "docs", ArtifactName: "docs-def.tar.gz", BuildNeeded: false},
+		{Application: "services/web", ArtifactName: "web-123.tar.gz", BuildNeeded: true},
+	}
+
+	var buf bytes.Buffer
+	err := opts.writeGitLabPipeline(&buf, decisions, `slarty do-builds --filter "$SLARTY_ARTIFACT" --variant "$SLARTY_VARIANT"`, map[string]string{"SLARTY_VARIANT": "release"})
+	if err != nil {
+		t.Fatalf("writeGitLabPipeline failed: %v", err)
+	}
+	output := buf.String()
+
+	want := `# Generated by slarty generate-ci gitlab
+stages:
+  - "build"
+
+variables:
+  SLARTY_VARIANT: "release"
+
+"build:api":
+  stage: "build"
+  image: "golang:1.22"
+  variables:
+    SLARTY_ARTIFACT: "api"
+    SLARTY_ARTIFACT_NAME: "api-abc.tar.gz"
+  script:
+    - "slarty do-builds --filter \"$SLARTY_ARTIFACT\" --variant \"$SLARTY_VARIANT\""
+
+"build:services/web":
+`
+	if !strings.HasPrefix(output, want) {
+		t.Fatalf("Unexpected pipeline, got:\n%s", output)
+	}
+	if strings.Contains(output, "docs") {
+		t.Errorf("Expected no job for an artifact that is already built, got:\n%s", output)
+	}
+
+	t.Run("NothingToBuild", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := opts.writeGitLabPipeline(&buf, decisions[1:2], "slarty do-builds", nil); err != nil {
+			t.Fatalf("writeGitLabPipeline failed: %v", err)
+		}
+		if !strings.Contains(buf.String(), `"slarty:nothing-to-build":`) {
+			t.Errorf("Expected a no-op job so the pipeline is not empty, got:\n%s", buf.String())
+		}
+		if strings.Contains(buf.String(), "build:") {
+			t.Errorf("Expected no build jobs, got:\n%s", buf.String())
+		}
+	})
+}
+
+func TestGitLabBuildCommand(t *testing.T) {
+	opts := &generateCIOptions{root: &rootOptions{artifactsJson: "./artifacts.json"}}
+	command, variables := opts.gitLabBuildCommand()
+	if command != `slarty do-builds --filter "$SLARTY_ARTIFACT"` || len(variables) != 0 {
+		t.Errorf("Expected a plain do-builds command, got %q %v", command, variables)
+	}
+
+	opts = &generateCIOptions{root: &rootOptions{artifactsJson: "ci/artifacts.json", local: true, channel: "feature/login"}, variant: "debug"}
+	command, variables = opts.gitLabBuildCommand()
+	if command != `slarty do-builds --filter "$SLARTY_ARTIFACT" --artifacts "$SLARTY_ARTIFACTS_JSON" --local --variant "$SLARTY_VARIANT" --channel "$SLARTY_CHANNEL"` {
+		t.Errorf("Expected flags to be passed on, got %q", command)
+	}
+	if variables["SLARTY_ARTIFACTS_JSON"] != "ci/artifacts.json" || variables["SLARTY_VARIANT"] != "debug" || variables["SLARTY_CHANNEL"] != "feature/login" {
+		t.Errorf("Expected pipeline variables for the flags, got %v", variables)
+	}
+}
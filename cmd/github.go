@@ -0,0 +1,124 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// writeGitHubOutputs appends should-build results to the files GitHub Actions names in
+// GITHUB_OUTPUT and GITHUB_STEP_SUMMARY, warning about any that are not set
+func writeGitHubOutputs(decisions []buildDecision) {
+	targets := []struct {
+		env   string
+		write func(io.Writer, []buildDecision) error
+	}{
+		{"GITHUB_OUTPUT", writeGitHubOutput},
+		{"GITHUB_STEP_SUMMARY", writeGitHubSummary},
+	}
+
+	for _, target := range targets {
+		path := os.Getenv(target.env)
+		if path == "" {
+			fmt.Fprintf(os.Stderr, "WARNING: --github-output is set but %s is not, is this running in GitHub Actions?\n", target.env)
+			continue
+		}
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err == nil {
+			err = target.write(f, decisions)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: failed to write %s: %v\n", target.env, err)
+		}
+	}
+}
+
+// writeGitHubOutput writes step outputs: build_needed is true when any artifact needs a
+// build, artifacts is a JSON list of those artifacts for use in a job matrix, results
+// holds every decision as JSON, and each artifact gets <name>_build_needed and
+// <name>_artifact_name outputs
+func writeGitHubOutput(w io.Writer, decisions []buildDecision) error {
+	needed := make([]string, 0, len(decisions))
+	for _, decision := range decisions {
+		if decision.BuildNeeded {
+			needed = append(needed, decision.Application)
+		}
+	}
+
+	neededJSON, err := json.Marshal(needed)
+	if err != nil {
+		return err
+	}
+	resultsJSON, err := json.Marshal(decisions)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "build_needed=%t\n", len(needed) > 0)
+	fmt.Fprintf(&b, "artifacts=%s\n", neededJSON)
+	fmt.Fprintf(&b, "results=%s\n", resultsJSON)
+	for _, decision := range decisions {
+		key := githubOutputKey(decision.Application)
+		fmt.Fprintf(&b, "%s_build_needed=%t\n", key, decision.BuildNeeded)
+		fmt.Fprintf(&b, "%s_artifact_name=%s\n", key, decision.ArtifactName)
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// writeGitHubSummary writes the decisions as a markdown table for the job summary
+func writeGitHubSummary(w io.Writer, decisions []buildDecision) error {
+	var b strings.Builder
+	b.WriteString("### slarty should-build\n\n")
+	b.WriteString("| Application | Artifact | Build Needed |\n")
+	b.WriteString("| --- | --- | --- |\n")
+	for _, decision := range decisions {
+		status := "NO"
+		if decision.BuildNeeded {
+			status = "YES"
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | %s |\n", markdownEscaper.Replace(decision.Application), decision.ArtifactName, status)
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownEscaper escapes characters that would break a markdown table cell
+var markdownEscaper = strings.NewReplacer("|", "\\|", "\n", " ")
+
+// githubOutputKey turns an artifact name into a step output name such as
+// "services_api_web"
+func githubOutputKey(name string) string {
+	return strings.ReplaceAll(slugify(name), "-", "_")
+}
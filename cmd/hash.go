@@ -28,37 +28,49 @@ import (
 	"log"
 )
 
-// hashCmd represents the hash command
-var hashCmd = &cobra.Command{
-	Use:   "hash",
-	Short: "Returns the git hash of one or more directories relative to a root",
-	Long: `Provides the git hash of one or more directories relative to a git root. This
-is the basis for determining if a build has been created before or not.`,
-	Run:  runHash,
-	Args: cobra.MinimumNArgs(2),
+// hashOptions are the flags of the hash command
+type hashOptions struct {
+	algorithm string
 }
 
-func runHash(cmd *cobra.Command, args []string) {
-	root := args[0]
-	directories := args[1:]
+// newHashCmd creates the hash command
+func newHashCmd() *cobra.Command {
+	opts := &hashOptions{}
+	cmd := &cobra.Command{
+		Use:   "hash",
+		Short: "Returns the git hash of one or more directories relative to a root",
+		Long: `Provides the git hash of one or more directories relative to a git root. This
+is the basis for determining if a build has been created before or not.
 
-	hash, err := slarty.HashDirectories(root, directories)
-	if err != nil {
-		log.Fatalln(err)
+--algorithm sha256 hashes the listing of the directories' files with SHA-256, as
+hash_algorithm does, instead of the SHA-1 git hash-object gives.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runHash(cmd, args, opts)
+		},
+		Args: cobra.MinimumNArgs(2),
 	}
-	fmt.Println(hash)
-}
-
-func init() {
-	rootCmd.AddCommand(hashCmd)
 
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
 	// and all subcommands, e.g.:
-	// hashCmd.PersistentFlags().String("foo", "", "A help for foo")
+	// cmd.PersistentFlags().String("foo", "", "A help for foo")
 
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
-	// hashCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	// cmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	cmd.Flags().StringVar(&opts.algorithm, "algorithm", slarty.HashAlgorithmSHA1, "Hash algorithm: sha1 or sha256")
+
+	return cmd
+}
+
+func runHash(cmd *cobra.Command, args []string, opts *hashOptions) {
+	root := args[0]
+	directories := args[1:]
+
+	hash, err := slarty.HashDirectoriesWithAlgorithm(root, directories, opts.algorithm)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(hash)
 }
@@ -24,7 +24,6 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/dstockto/slarty/slarty"
 	"github.com/spf13/cobra"
 	"log"
 	"os"
@@ -32,57 +31,118 @@ import (
 	"text/tabwriter"
 )
 
-// hashApplicationCmd represents the hashApplication command
-var hashApplicationCmd = &cobra.Command{
-	Use:   "hash-application",
-	Short: "Calculates the hashes for applications defined in artifacts.json",
-	Long: `Outputs the hashes for the applications defined in the artifacts.json config
-file.`,
-	Run: runHashApplication,
+// hashApplicationOptions are the flags of the hash-application command
+type hashApplicationOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	json      bool
+	output    string
+	sort      string
+	// verifyRepo checks each artifact hashed against the repository
+	verifyRepo bool
 }
 
-func runHashApplication(cmd *cobra.Command, args []string) {
-	artifactConfig, err := slarty.ReadArtifactsJson(artifactsJson)
+// newHashApplicationCmd creates the hash-application command
+func newHashApplicationCmd(root *rootOptions) *cobra.Command {
+	opts := &hashApplicationOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "hash-application",
+		Short: "Calculates the hashes for applications defined in artifacts.json",
+		Long: `Outputs the hashes for the applications defined in the artifacts.json config
+file. With --verify-repo each hash is shown with the artifact name it gives and whether
+that artifact is in the repository, the same check should-build makes, to help find why
+an artifact is rebuilt or cannot be deployed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runHashApplication(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+
+	// Cobra supports Persistent Flags which will work for this command
+	// and all subcommands, e.g.:
+	// cmd.PersistentFlags().String("foo", "", "A help for foo")
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "", root.completeArtifactNames)
+	cmd.Flags().BoolVar(&opts.json, "json", false, "output results as JSON")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", outputTable, "output format: table, json, csv or markdown")
+	cmd.Flags().StringVar(&opts.sort, "sort", sortByConfig, "order rows by config (as in artifacts.json) or name")
+	cmd.Flags().BoolVar(&opts.verifyRepo, "verify-repo", false, "also show each artifact name and whether it is in the repository")
+	// Cobra supports local flags which will only run when this command
+	// is called directly, e.g.:
+	// cmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+
+	return cmd
+}
+
+func runHashApplication(cmd *cobra.Command, args []string, opts *hashApplicationOptions) {
+	artifactConfig, err := opts.root.readConfig()
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	format := outputFormatFromFlags(opts.json, opts.output)
 	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
 
 	artifactHashes := make(map[string]string)
+	// Tables show hashes shortened to hash_length, as artifact names have them
+	displayHashes := make(map[string]string)
 	var longestName int
 	var longestHash int
 
-	var filters []string
-	if filter != "" {
-		filters = strings.Split(filter, ",")
-	}
-	artifacts := artifactConfig.GetByArtifactsByNameWithFilter(filters)
+	artifacts := sortedArtifacts(artifactConfig.SelectArtifacts(opts.selection.selection()), opts.sort)
 	for _, artifact := range artifacts {
-		hash, err := slarty.HashDirectories(artifactConfig.RootDirectory, artifact.Directories)
+		hash, err := artifactConfig.HashDirectories(artifact.Directories)
 		if err != nil {
 			log.Fatalln(err)
 		}
 		artifactHashes[artifact.Name] = hash
+		displayHashes[artifact.Name] = artifactConfig.DisplayHash(hash)
 		if len(artifact.Name) > longestName {
 			longestName = len(artifact.Name)
 		}
-		if len(hash) > longestHash {
-			longestHash = len(hash)
+		if len(displayHashes[artifact.Name]) > longestHash {
+			longestHash = len(displayHashes[artifact.Name])
 		}
 	}
 
-	if jsonOutput {
+	// The artifact names and whether they are stored come from the same check
+	// should-build makes
+	artifactNames := make(map[string]string)
+	stored := make(map[string]bool)
+	longestArtifactName := len("Artifact")
+	if opts.verifyRepo {
+		repoAdapter, err := opts.root.openRepository(artifactConfig)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, decision := range opts.root.decideBuilds(artifacts, artifactConfig, repoAdapter) {
+			artifactNames[decision.Application] = decision.ArtifactName
+			stored[decision.Application] = !decision.BuildNeeded
+			if len(decision.ArtifactName) > longestArtifactName {
+				longestArtifactName = len(decision.ArtifactName)
+			}
+		}
+	}
+
+	if format == outputJSON {
 		type artifactHashEntry struct {
-			Application string `json:"application"`
-			Hash        string `json:"hash"`
+			Application  string `json:"application"`
+			Hash         string `json:"hash"`
+			ArtifactName string `json:"artifact_name,omitempty"`
+			InRepository *bool  `json:"in_repository,omitempty"`
 		}
 		entries := make([]artifactHashEntry, 0, len(artifacts))
 		for _, artifact := range artifacts {
-			entries = append(entries, artifactHashEntry{
+			entry := artifactHashEntry{
 				Application: artifact.Name,
 				Hash:        artifactHashes[artifact.Name],
-			})
+			}
+			if opts.verifyRepo {
+				inRepository := stored[artifact.Name]
+				entry.ArtifactName = artifactNames[artifact.Name]
+				entry.InRepository = &inRepository
+			}
+			entries = append(entries, entry)
 		}
 		out, err := json.MarshalIndent(entries, "", "  ")
 		if err != nil {
@@ -92,11 +152,54 @@ func runHashApplication(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if format == outputCSV || format == outputMarkdown {
+		headers := []string{"Application", "Hash"}
+		if opts.verifyRepo {
+			headers = append(headers, "Artifact", "In Repository")
+		}
+		rows := make([][]string, 0, len(artifacts))
+		for _, artifact := range artifacts {
+			row := []string{artifact.Name, displayHashes[artifact.Name]}
+			if opts.verifyRepo {
+				inRepository := "NO"
+				if stored[artifact.Name] {
+					inRepository = "YES"
+				}
+				row = append(row, artifactNames[artifact.Name], inRepository)
+			}
+			rows = append(rows, row)
+		}
+		if err := writeRows(os.Stdout, format, headers, rows); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
 	if longestName == 0 {
 		fmt.Println("No artifacts found")
 		return
 	}
 
+	if opts.verifyRepo {
+		separator := strings.Repeat("-", longestName+2) + "\t" + strings.Repeat("-", longestHash+2) + "\t" + strings.Repeat("-", longestArtifactName+2) + "\t" + strings.Repeat("-", 15) + "\n"
+
+		fmt.Fprintf(w, separator)
+		fmt.Fprintf(w, " %s \t %s \t %s \t %s \n", "Application", "Hash", "Artifact", "In Repository")
+		fmt.Fprintf(w, separator)
+
+		for _, artifact := range artifacts {
+			inRepository := "NO"
+			if stored[artifact.Name] {
+				inRepository = "YES"
+			}
+			fmt.Fprintf(w, " %s\t %s\t %s\t %s\n", artifact.Name, displayHashes[artifact.Name], artifactNames[artifact.Name], inRepository)
+		}
+
+		fmt.Fprintf(w, separator)
+		w.Flush()
+		return
+	}
+
 	separator := strings.Repeat("-", longestName+2) + "\t" + strings.Repeat("-", longestHash+2) + "\n"
 
 	fmt.Fprintf(w, separator)
@@ -104,7 +207,7 @@ func runHashApplication(cmd *cobra.Command, args []string) {
 	fmt.Fprintf(w, separator)
 
 	for _, artifact := range artifacts {
-		fmt.Fprintf(w, " "+artifact.Name+"\t "+artifactHashes[artifact.Name]+"\n")
+		fmt.Fprintf(w, " "+artifact.Name+"\t "+displayHashes[artifact.Name]+"\n")
 	}
 
 	fmt.Fprintf(w, separator)
@@ -112,18 +215,3 @@ func runHashApplication(cmd *cobra.Command, args []string) {
 	w.Flush()
 
 }
-
-func init() {
-	rootCmd.AddCommand(hashApplicationCmd)
-
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// hashApplicationCmd.PersistentFlags().String("foo", "", "A help for foo")
-	hashApplicationCmd.Flags().StringVarP(&filter, "filter", "f", "", "-f \"application1,application2\"")
-	hashApplicationCmd.Flags().BoolVar(&jsonOutput, "json", false, "output results as JSON")
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// hashApplicationCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-}
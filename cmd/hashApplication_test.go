@@ -10,31 +10,34 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/dstockto/slarty/slarty"
 	"github.com/spf13/cobra"
 )
 
 func TestHashApplicationCommand(t *testing.T) {
 	// Test that the hash-application command is properly initialized
-	if hashApplicationCmd.Use != "hash-application" {
-		t.Errorf("Expected hash-application command Use to be 'hash-application', got '%s'", hashApplicationCmd.Use)
+	cmd := newHashApplicationCmd(&rootOptions{})
+	if cmd.Use != "hash-application" {
+		t.Errorf("Expected hash-application command Use to be 'hash-application', got '%s'", cmd.Use)
 	}
 
-	if hashApplicationCmd.Short == "" {
+	if cmd.Short == "" {
 		t.Error("hash-application command Short description should not be empty")
 	}
 
-	if hashApplicationCmd.Long == "" {
+	if cmd.Long == "" {
 		t.Error("hash-application command Long description should not be empty")
 	}
 
-	if hashApplicationCmd.Run == nil {
+	if cmd.Run == nil {
 		t.Error("hash-application command Run function should not be nil")
 	}
 }
 
 func TestHashApplicationCommandFlags(t *testing.T) {
 	// Test that the hash-application command has the expected flags
-	flags := hashApplicationCmd.Flags()
+	cmd := newHashApplicationCmd(&rootOptions{})
+	flags := cmd.Flags()
 
 	// Check filter flag
 	if flags.Lookup("filter") == nil {
@@ -105,16 +108,7 @@ func TestRunHashApplicationJSON(t *testing.T) {
 	runGit("add", ".")
 	runGit("commit", "-m", "Initial commit")
 
-	oldArtifactsJson := artifactsJson
-	defer func() { artifactsJson = oldArtifactsJson }()
-	artifactsJson = configPath
-
-	oldFilter := filter
-	defer func() { filter = oldFilter }()
-	filter = ""
-
-	oldJSON := jsonOutput
-	defer func() { jsonOutput = oldJSON }()
+	opts := &hashApplicationOptions{root: &rootOptions{artifactsJson: configPath}}
 
 	type entry struct {
 		Application string `json:"application"`
@@ -122,14 +116,14 @@ func TestRunHashApplicationJSON(t *testing.T) {
 	}
 
 	t.Run("ValidJSONInConfigOrder", func(t *testing.T) {
-		jsonOutput = true
-		defer func() { jsonOutput = false }()
+		opts.json = true
+		defer func() { opts.json = false }()
 
 		oldStdout := os.Stdout
 		r, w, _ := os.Pipe()
 		os.Stdout = w
 
-		runHashApplication(&cobra.Command{Use: "test"}, []string{})
+		runHashApplication(&cobra.Command{Use: "test"}, []string{}, opts)
 
 		w.Close()
 		os.Stdout = oldStdout
@@ -156,19 +150,19 @@ func TestRunHashApplicationJSON(t *testing.T) {
 	})
 
 	t.Run("EmptyArrayWhenNoArtifacts", func(t *testing.T) {
-		jsonOutput = true
-		oldF := filter
-		filter = "non-existent"
+		opts.json = true
+		oldF := opts.selection.filter
+		opts.selection.filter = "non-existent"
 		defer func() {
-			jsonOutput = false
-			filter = oldF
+			opts.json = false
+			opts.selection.filter = oldF
 		}()
 
 		oldStdout := os.Stdout
 		r, w, _ := os.Pipe()
 		os.Stdout = w
 
-		runHashApplication(&cobra.Command{Use: "test"}, []string{})
+		runHashApplication(&cobra.Command{Use: "test"}, []string{}, opts)
 
 		w.Close()
 		os.Stdout = oldStdout
@@ -287,18 +281,11 @@ func TestRunHashApplication(t *testing.T) {
 		Short: "Test command",
 	}
 
-	// Save original artifactsJson and restore after test
-	oldArtifactsJson := artifactsJson
-	defer func() { artifactsJson = oldArtifactsJson }()
-	artifactsJson = configPath
-
-	// Save original filter and restore after test
-	oldFilter := filter
-	defer func() { filter = oldFilter }()
+	opts := &hashApplicationOptions{root: &rootOptions{artifactsJson: configPath}}
 
 	// Test with no filter
 	t.Run("NoFilter", func(t *testing.T) {
-		filter = ""
+		opts.selection.filter = ""
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -306,7 +293,7 @@ func TestRunHashApplication(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runHashApplication(cmd, []string{})
+		runHashApplication(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -328,7 +315,7 @@ func TestRunHashApplication(t *testing.T) {
 
 	// Test with filter
 	t.Run("WithFilter", func(t *testing.T) {
-		filter = "test-artifact-1"
+		opts.selection.filter = "test-artifact-1"
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -336,7 +323,7 @@ func TestRunHashApplication(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runHashApplication(cmd, []string{})
+		runHashApplication(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -358,7 +345,7 @@ func TestRunHashApplication(t *testing.T) {
 
 	// Test with non-existent filter
 	t.Run("NonExistentFilter", func(t *testing.T) {
-		filter = "non-existent"
+		opts.selection.filter = "non-existent"
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -366,7 +353,7 @@ func TestRunHashApplication(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runHashApplication(cmd, []string{})
+		runHashApplication(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -383,3 +370,93 @@ func TestRunHashApplication(t *testing.T) {
 		}
 	})
 }
+
+func TestRunHashApplicationVerifyRepo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+	repoRoot := filepath.Join(tempDir, "repo")
+	jsonContent := `{
+		"application": "Test App",
+		"root_directory": "__DIR__",
+		"repository": { "adapter": "Local", "options": { "root": "` + repoRoot + `" } },
+		"artifacts": [
+			{"name": "web", "directories": ["web"], "command": "make web", "output_directory": "build/web", "deploy_location": "deploy/web", "artifact_prefix": "web"},
+			{"name": "api", "directories": ["api"], "command": "make api", "output_directory": "build/api", "deploy_location": "deploy/api", "artifact_prefix": "api"}
+		]
+	}`
+	configPath := filepath.Join(tempDir, "artifacts.json")
+	if err := os.WriteFile(configPath, []byte(jsonContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	runGit := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	runGit("init")
+	for _, name := range []string{"web", "api"} {
+		if err := os.Mkdir(filepath.Join(tempDir, name), 0755); err != nil {
+			t.Fatalf("Failed to create directory %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(tempDir, name, "test.txt"), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create file in %s: %v", name, err)
+		}
+	}
+	runGit("add", ".")
+
+	config, err := slarty.ReadArtifactsJson(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	webHash, err := slarty.HashDirectories(config.RootDirectory, []string{"web"})
+	if err != nil {
+		t.Fatalf("HashDirectories failed: %v", err)
+	}
+	webArtifact := "web-" + webHash + ".tar.gz"
+	if err := slarty.NewLocalRepositoryAdapter(repoRoot).StoreArtifact(strings.NewReader("archive"), webArtifact); err != nil {
+		t.Fatalf("Failed to store artifact: %v", err)
+	}
+
+	opts := &hashApplicationOptions{root: &rootOptions{artifactsJson: configPath}, verifyRepo: true}
+
+	output := captureStdout(t, func() {
+		runHashApplication(&cobra.Command{Use: "test"}, []string{}, opts)
+	})
+	for _, want := range []string{"In Repository", webHash, webArtifact} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected the table to contain %q, got:\n%s", want, output)
+		}
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, " web ") && !strings.HasSuffix(strings.TrimSpace(line), "YES") {
+			t.Errorf("Expected web to be in the repository, got: %s", line)
+		}
+		if strings.Contains(line, " api ") && !strings.HasSuffix(strings.TrimSpace(line), "NO") {
+			t.Errorf("Expected api not to be in the repository, got: %s", line)
+		}
+	}
+
+	opts.json = true
+	output = captureStdout(t, func() {
+		runHashApplication(&cobra.Command{Use: "test"}, []string{}, opts)
+	})
+	var entries []struct {
+		Application  string `json:"application"`
+		Hash         string `json:"hash"`
+		ArtifactName string `json:"artifact_name"`
+		InRepository *bool  `json:"in_repository"`
+	}
+	if err := json.Unmarshal([]byte(output), &entries); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\nOutput: %s", err, output)
+	}
+	if len(entries) != 2 || entries[0].ArtifactName != webArtifact || entries[0].InRepository == nil || !*entries[0].InRepository ||
+		entries[1].InRepository == nil || *entries[1].InRepository {
+		t.Errorf("Expected web in the repository and api not, got: %s", output)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+// applicationCacheDir returns the directory in the user's cache where slarty keeps
+// data for the application, so it never shows up in the project checkout
+func applicationCacheDir(artifactConfig *slarty.ArtifactsConfig) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	application := slugify(artifactConfig.Application)
+	if application == "" {
+		application = "default"
+	}
+
+	return filepath.Join(cacheDir, "slarty", application), nil
+}
+
+// historyPath returns the build history file for the application
+func historyPath(artifactConfig *slarty.ArtifactsConfig) (string, error) {
+	dir, err := applicationCacheDir(artifactConfig)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// openBuildCache returns the local build cache for the application. The cache holds
+// archives of build outputs by artifact name, and so by hash, and is laid out like a
+// local repository.
+func openBuildCache(artifactConfig *slarty.ArtifactsConfig) (*slarty.LocalRepositoryAdapter, error) {
+	dir, err := applicationCacheDir(artifactConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return slarty.NewLocalRepositoryAdapter(filepath.Join(dir, "outputs")), nil
+}
+
+// recordBuild adds a successful build to the build history. Like notifications, a
+// failure to record history is only reported as a warning.
+func recordBuild(artifactConfig *slarty.ArtifactsConfig, record slarty.BuildRecord) {
+	path, err := historyPath(artifactConfig)
+	if err == nil {
+		err = slarty.AppendBuildRecord(path, record)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to record build history: %v\n", err)
+	}
+}
+
+// readLatestBuilds returns the most recent recorded build of each artifact. History
+// is best effort, so a history that cannot be read is treated as empty.
+func readLatestBuilds(artifactConfig *slarty.ArtifactsConfig) map[string]slarty.BuildRecord {
+	path, err := historyPath(artifactConfig)
+	if err != nil {
+		return nil
+	}
+
+	records, err := slarty.ReadBuildHistory(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to read build history: %v\n", err)
+		return nil
+	}
+
+	return slarty.LatestBuilds(records)
+}
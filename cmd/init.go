@@ -0,0 +1,252 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// initOptions are the flags of the init command
+type initOptions struct {
+	root  *rootOptions
+	force bool
+}
+
+// newInitCmd creates the init command
+func newInitCmd(root *rootOptions) *cobra.Command {
+	opts := &initOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Interactively create an artifacts.json",
+		Long: `Asks for the application name, repository settings and a first artifact and
+writes a valid artifacts.json to the path given by --artifacts (./artifacts.json by
+default). An existing file is never overwritten unless --force is used.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runInit(cmd, args, opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.force, "force", false, "overwrite an existing artifacts.json")
+
+	return cmd
+}
+
+// scaffoldRepository is the repository section written by init. Options are a map
+// so that only the options relevant to the chosen adapter are written.
+type scaffoldRepository struct {
+	Adapter string            `json:"adapter"`
+	Options map[string]string `json:"options"`
+}
+
+// scaffoldConfig is the artifacts.json written by init
+type scaffoldConfig struct {
+	SchemaVersion int                     `json:"schema_version"`
+	Application   string                  `json:"application"`
+	RootDirectory string                  `json:"root_directory"`
+	Repository    scaffoldRepository      `json:"repository"`
+	Artifacts     []slarty.ArtifactConfig `json:"artifacts"`
+	Assets        []slarty.Asset          `json:"assets"`
+}
+
+func runInit(cmd *cobra.Command, args []string, opts *initOptions) {
+	if _, err := os.Stat(opts.root.artifactsJson); err == nil && !opts.force {
+		log.Fatalf("%s already exists; use --force to overwrite it", opts.root.artifactsJson)
+	}
+
+	config, err := promptForConfig(cmd.InOrStdin(), cmd.OutOrStdout())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := os.WriteFile(opts.root.artifactsJson, append(out, '\n'), 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", opts.root.artifactsJson, err)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "\nWrote %s\n", opts.root.artifactsJson)
+
+	// Validate what we wrote so any problems (such as directories that do not
+	// exist yet) are reported straight away.
+	written, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	validateConfig(cmd.OutOrStdout(), written)
+}
+
+// promptForConfig asks the questions needed to build a minimal configuration
+func promptForConfig(in io.Reader, out io.Writer) (*scaffoldConfig, error) {
+	reader := bufio.NewReader(in)
+
+	ask := func(question, def string) (string, error) {
+		if def != "" {
+			fmt.Fprintf(out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(out, "%s: ", question)
+		}
+		answer, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			answer = def
+		}
+		if answer == "" && err == io.EOF {
+			return "", io.EOF
+		}
+		return answer, nil
+	}
+
+	// askOptional allows an empty answer, even at the end of the input
+	askOptional := func(question string) (string, error) {
+		answer, err := ask(question, "")
+		if err == io.EOF {
+			return "", nil
+		}
+		return answer, err
+	}
+
+	// askRequired keeps asking until a non-empty answer is given
+	askRequired := func(question, def string) (string, error) {
+		for {
+			answer, err := ask(question, def)
+			if err == io.EOF {
+				return "", fmt.Errorf("no answer given for %q", question)
+			}
+			if err != nil {
+				return "", err
+			}
+			if answer != "" {
+				return answer, nil
+			}
+			fmt.Fprintln(out, "  A value is required.")
+		}
+	}
+
+	config := &scaffoldConfig{SchemaVersion: slarty.CurrentSchemaVersion, RootDirectory: "__DIR__", Artifacts: []slarty.ArtifactConfig{}, Assets: []slarty.Asset{}}
+
+	var err error
+	if config.Application, err = askRequired("Application name", ""); err != nil {
+		return nil, err
+	}
+
+	for {
+		adapter, err := askRequired("Repository type (local or s3)", "local")
+		if err != nil {
+			return nil, err
+		}
+		adapter = strings.ToLower(adapter)
+		if adapter == "local" || adapter == "s3" {
+			config.Repository.Adapter = adapter
+			break
+		}
+		fmt.Fprintln(out, "  Please answer local or s3.")
+	}
+
+	config.Repository.Options = make(map[string]string)
+	if config.Repository.Adapter == "local" {
+		root, err := askRequired("Local repository root", "/tmp/artifact-repo")
+		if err != nil {
+			return nil, err
+		}
+		config.Repository.Options["root"] = root
+	} else {
+		region, err := askRequired("S3 region", "us-east-1")
+		if err != nil {
+			return nil, err
+		}
+		bucket, err := askRequired("S3 bucket name", "")
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := askOptional("S3 path prefix (optional)")
+		if err != nil {
+			return nil, err
+		}
+		profile, err := askOptional("AWS profile (optional)")
+		if err != nil {
+			return nil, err
+		}
+		config.Repository.Options["region"] = region
+		config.Repository.Options["bucket-name"] = bucket
+		if prefix != "" {
+			config.Repository.Options["path-prefix"] = prefix
+		}
+		if profile != "" {
+			config.Repository.Options["profile"] = profile
+		}
+	}
+
+	fmt.Fprintln(out, "\nNow describe the first artifact.")
+	var artifact slarty.ArtifactConfig
+	if artifact.Name, err = askRequired("Artifact name", ""); err != nil {
+		return nil, err
+	}
+	directories, err := askRequired("Directories used to calculate the hash (comma separated)", "")
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range strings.Split(directories, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			artifact.Directories = append(artifact.Directories, dir)
+		}
+	}
+	if artifact.Command, err = askRequired("Build command", ""); err != nil {
+		return nil, err
+	}
+	outputDirectory, err := askRequired("Output directory", "")
+	if err != nil {
+		return nil, err
+	}
+	artifact.OutputDirectory = slarty.OutputDirectories{outputDirectory}
+	if artifact.DeployLocation, err = askRequired("Deploy location", ""); err != nil {
+		return nil, err
+	}
+	if artifact.ArtifactPrefix, err = askRequired("Artifact prefix", slugify(artifact.Name)); err != nil {
+		return nil, err
+	}
+	config.Artifacts = append(config.Artifacts, artifact)
+
+	return config, nil
+}
+
+var nonSlugCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify turns a name into something suitable for an artifact prefix
+func slugify(name string) string {
+	return strings.Trim(nonSlugCharacters.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+func TestInitCommand(t *testing.T) {
+	cmd := newInitCmd(&rootOptions{})
+	if cmd.Use != "init" {
+		t.Errorf("Expected init command Use to be 'init', got '%s'", cmd.Use)
+	}
+
+	if cmd.Short == "" {
+		t.Error("init command Short description should not be empty")
+	}
+
+	if cmd.Flags().Lookup("force") == nil {
+		t.Error("init command should have 'force' flag")
+	}
+}
+
+func TestPromptForConfig(t *testing.T) {
+	t.Run("LocalRepository", func(t *testing.T) {
+		input := strings.Join([]string{
+			"My App",
+			"",                // accept default local adapter
+			"/tmp/repo",       // root
+			"Web Frontend",    // artifact name
+			"src/web, assets", // directories
+			"make web",
+			"build/web",
+			"public/web",
+			"", // accept default prefix
+		}, "\n") + "\n"
+
+		var out bytes.Buffer
+		config, err := promptForConfig(strings.NewReader(input), &out)
+		if err != nil {
+			t.Fatalf("promptForConfig failed: %v", err)
+		}
+
+		if config.Application != "My App" {
+			t.Errorf("Expected application 'My App', got %q", config.Application)
+		}
+		if config.Repository.Adapter != "local" || config.Repository.Options["root"] != "/tmp/repo" {
+			t.Errorf("Unexpected repository %+v", config.Repository)
+		}
+		artifact := config.Artifacts[0]
+		if len(artifact.Directories) != 2 || artifact.Directories[1] != "assets" {
+			t.Errorf("Unexpected directories %v", artifact.Directories)
+		}
+		if artifact.ArtifactPrefix != "web-frontend" {
+			t.Errorf("Expected default prefix 'web-frontend', got %q", artifact.ArtifactPrefix)
+		}
+	})
+
+	t.Run("S3RepositoryRepromptsInvalidAnswers", func(t *testing.T) {
+		input := strings.Join([]string{
+			"My App",
+			"ftp", // invalid adapter
+			"S3",
+			"", // default region
+			"", // bucket is required
+			"my-bucket",
+			"apps/my-app",
+			"", // no profile
+			"api", "src", "make", "dist", "deploy", "api",
+		}, "\n") + "\n"
+
+		var out bytes.Buffer
+		config, err := promptForConfig(strings.NewReader(input), &out)
+		if err != nil {
+			t.Fatalf("promptForConfig failed: %v", err)
+		}
+
+		options := config.Repository.Options
+		if config.Repository.Adapter != "s3" || options["region"] != "us-east-1" || options["bucket-name"] != "my-bucket" {
+			t.Errorf("Unexpected repository %+v", config.Repository)
+		}
+		if _, ok := options["profile"]; ok {
+			t.Errorf("Expected empty profile to be omitted, got %+v", options)
+		}
+		if !strings.Contains(out.String(), "Please answer local or s3.") || !strings.Contains(out.String(), "A value is required.") {
+			t.Errorf("Expected re-prompts in output, got:\n%s", out.String())
+		}
+	})
+
+	t.Run("EndOfInput", func(t *testing.T) {
+		if _, err := promptForConfig(strings.NewReader("My App\n"), &bytes.Buffer{}); err == nil {
+			t.Error("Expected error when input ends before required answers")
+		}
+	})
+}
+
+func TestRunInit(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "artifacts.json")
+
+	opts := &initOptions{root: &rootOptions{artifactsJson: configPath}}
+
+	input := "My App\nlocal\n/tmp/repo\napi\nsrc\nmake api\ndist\ndeploy/api\n\n"
+	cmd := &cobra.Command{Use: "test"}
+	cmd.SetIn(strings.NewReader(input))
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+
+	runInit(cmd, []string{}, opts)
+
+	config, err := slarty.ReadArtifactsJson(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read written config: %v", err)
+	}
+	if config.Application != "My App" || len(config.Artifacts) != 1 || config.Artifacts[0].ArtifactPrefix != "api" {
+		t.Errorf("Unexpected written config %+v", config)
+	}
+	if config.RootDirectory != tempDir {
+		t.Errorf("Expected __DIR__ root to resolve to %s, got %s", tempDir, config.RootDirectory)
+	}
+	if !strings.Contains(out.String(), "Wrote "+configPath) {
+		t.Errorf("Expected confirmation in output, got:\n%s", out.String())
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		t.Errorf("Expected config file to exist: %v", err)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	if got := slugify("  My Great_App 2 "); got != "my-great-app-2" {
+		t.Errorf("Expected 'my-great-app-2', got %q", got)
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dstockto/slarty/slarty"
+	"github.com/dstockto/slarty/slarty/archive"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// inspectOptions are the flags of the inspect command
+type inspectOptions struct {
+	root    *rootOptions
+	json    bool
+	variant string
+	dirty   bool
+}
+
+// newInspectCmd creates the inspect command
+func newInspectCmd(root *rootOptions) *cobra.Command {
+	opts := &inspectOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "inspect <artifact>",
+		Short: "List the contents of an artifact without extracting it",
+		Long: `Downloads an artifact from the repository and lists the files it contains along
+with their sizes, modes and modification times, and any labels it was stored with.
+Nothing is extracted to disk.
+The argument may be the name of an artifact or asset from artifacts.json, in which
+case the archive matching the current code is inspected, or the filename of an
+archive stored in the repository.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runInspect(cmd, args, opts)
+		},
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: root.completeInspectArgs,
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().BoolVar(&opts.json, "json", false, "output results as JSON")
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+	cmd.Flags().BoolVar(&opts.dirty, "dirty", false, "name artifacts after the working tree, including unstaged changes, marking changed ones -dirty")
+
+	return cmd
+}
+
+func runInspect(cmd *cobra.Command, args []string, opts *inspectOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := artifactConfig.ApplyVariant(opts.variant); err != nil {
+		log.Fatalln(err)
+	}
+	artifactConfig.HashWorkingTree = opts.dirty
+
+	// Create a repository adapter
+	repoAdapter, err := opts.root.openRepository(artifactConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	filename, err := opts.root.resolveArchiveFilename(args[0], artifactConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	exists, err := repoAdapter.ArtifactExists(filename)
+	if err != nil {
+		log.Fatalf("Failed to check if artifact exists in repository: %v", err)
+	}
+	if !exists {
+		log.Fatalf("Artifact %s not found in repository", filename)
+	}
+
+	// Create a temporary file to download the artifact
+	tempFile, err := slarty.CreateTempArchive(artifactConfig.RootDirectory, "slarty-inspect-*.tar.gz")
+	if err != nil {
+		log.Fatalf("Failed to create temporary file: %v", err)
+	}
+	tempFilePath := tempFile.Name()
+	tempFile.Close() // Close the file so we can reopen it for writing
+	defer os.Remove(tempFilePath)
+
+	if err := slarty.RetrieveArtifactFile(repoAdapter, filename, tempFilePath); err != nil {
+		log.Fatalf("Failed to retrieve artifact from repository: %v", err)
+	}
+
+	info, err := os.Stat(tempFilePath)
+	if err != nil {
+		log.Fatalf("Failed to read downloaded artifact: %v", err)
+	}
+
+	entries, err := archive.ListFile(tempFilePath, archive.ReadOptions{})
+	if err != nil {
+		log.Fatalf("Failed to read artifact: %v", err)
+	}
+
+	// Labels are extra information, so a repository that cannot report them is fine
+	labels, err := slarty.ArtifactLabels(repoAdapter, filename)
+	if err != nil && !errors.Is(err, slarty.ErrLabelsUnsupported) {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to read the labels of %s: %v\n", filename, err)
+	}
+
+	var totalSize int64
+	var fileCount int
+	for _, entry := range entries {
+		if entry.Type == "file" {
+			fileCount++
+			totalSize += entry.Size
+		}
+	}
+
+	if opts.json {
+		out, err := json.MarshalIndent(struct {
+			Artifact    string            `json:"artifact"`
+			ArchiveSize int64             `json:"archive_size"`
+			Labels      map[string]string `json:"labels,omitempty"`
+			Files       int               `json:"files"`
+			TotalSize   int64             `json:"total_size"`
+			Entries     []archive.Entry   `json:"entries"`
+		}{filename, info.Size(), labels, fileCount, totalSize, entries}, "", "  ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	fmt.Printf("Artifact: %s\n", filename)
+	fmt.Printf("Archive size: %d bytes\n", info.Size())
+	if len(labels) > 0 {
+		fmt.Printf("Labels: %s\n", slarty.FormatLabels(labels))
+	}
+	fmt.Printf("Files: %d (%d bytes uncompressed)\n\n", fileCount, totalSize)
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	fmt.Fprintf(w, " %s\t %s\t %s\t %s \n", "Mode", "Size", "Modified", "Name")
+	for _, entry := range entries {
+		fmt.Fprintf(w, " %s\t %d\t %s\t %s \n", entry.Mode, entry.Size, entry.Modified.Format("2006-01-02 15:04:05"), entry.Name)
+	}
+	w.Flush()
+}
+
+// resolveArchiveFilename maps the argument given to inspect onto a filename in the
+// repository. Configured artifact names resolve to the archive for the current
+// code, asset names resolve to the asset's filename, and anything else is assumed
+// to already be a filename.
+func (o *rootOptions) resolveArchiveFilename(name string, artifactConfig *slarty.ArtifactsConfig) (string, error) {
+	if artifact, err := artifactConfig.GetArtifactConfig(name); err == nil {
+		if artifact.IsDocker() {
+			return "", fmt.Errorf("%s is a docker artifact, use docker image inspect instead", name)
+		}
+		return o.artifactName(name, artifactConfig)
+	}
+
+	for _, asset := range artifactConfig.Assets {
+		if strings.EqualFold(asset.Name, name) {
+			return asset.Filename, nil
+		}
+	}
+
+	return name, nil
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/dstockto/slarty/slarty/archive"
+	"github.com/spf13/cobra"
+)
+
+// captureStdout runs fn with os.Stdout redirected and returns what was written.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	oldStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+
+	fn()
+	w.Close()
+	os.Stdout = oldStdout
+
+	return <-done
+}
+
+func TestInspectCommand(t *testing.T) {
+	cmd := newInspectCmd(&rootOptions{})
+	if cmd.Use != "inspect <artifact>" {
+		t.Errorf("Expected inspect command Use to be 'inspect <artifact>', got '%s'", cmd.Use)
+	}
+
+	if cmd.Short == "" {
+		t.Error("inspect command Short description should not be empty")
+	}
+
+	if cmd.Run == nil {
+		t.Error("inspect command Run function should not be nil")
+	}
+
+	if cmd.Flags().Lookup("json") == nil {
+		t.Error("inspect command should have 'json' flag")
+	}
+}
+
+// writeInspectFixture creates a source tree, archives it into a local repository and
+// writes an artifacts.json pointing at that repository. It returns the config path.
+func writeInspectFixture(t *testing.T, archiveName string) string {
+	t.Helper()
+
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "source")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "index.html"), []byte("<html></html>"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "sub", "app.js"), []byte("console.log(1)"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	repoDir := filepath.Join(tempDir, "repo")
+	if err := os.Mkdir(repoDir, 0755); err != nil {
+		t.Fatalf("Failed to create repo directory: %v", err)
+	}
+	if err := archive.CreateFile(archive.TarGz, filepath.Join(repoDir, archiveName), []archive.Source{{Dir: sourceDir}}, slarty.NewBuilder(nil, nil).WriteOptions(nil)); err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+
+	jsonContent := `{
+		"application": "Test App",
+		"root_directory": "` + tempDir + `",
+		"repository": { "adapter": "Local", "options": { "root": "` + repoDir + `" } },
+		"assets": [ { "name": "Library", "filename": "` + archiveName + `", "deploy_location": "lib" } ]
+	}`
+	configPath := filepath.Join(tempDir, "artifacts.json")
+	if err := os.WriteFile(configPath, []byte(jsonContent), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	return configPath
+}
+
+func TestListTarGz(t *testing.T) {
+	configPath := writeInspectFixture(t, "sample.tar.gz")
+	archivePath := filepath.Join(filepath.Dir(configPath), "repo", "sample.tar.gz")
+
+	entries, err := archive.ListFile(archivePath, archive.ReadOptions{})
+	if err != nil {
+		t.Fatalf("ListFile failed: %v", err)
+	}
+
+	found := make(map[string]archive.Entry)
+	for _, entry := range entries {
+		found[entry.Name] = entry
+	}
+
+	if entry, ok := found["index.html"]; !ok || entry.Type != "file" || entry.Size != int64(len("<html></html>")) {
+		t.Errorf("Expected index.html file entry with correct size, got %+v", entry)
+	}
+	if entry, ok := found[filepath.Join("sub", "app.js")]; !ok || entry.Type != "file" {
+		t.Errorf("Expected sub/app.js file entry, got %+v", entry)
+	}
+	if entry, ok := found["sub"]; !ok || entry.Type != "dir" {
+		t.Errorf("Expected sub directory entry, got %+v", entry)
+	}
+
+	if _, err := archive.ListFile(filepath.Join(t.TempDir(), "missing.tar.gz"), archive.ReadOptions{}); err == nil {
+		t.Error("Expected error listing a missing archive")
+	}
+}
+
+func TestRunInspect(t *testing.T) {
+	configPath := writeInspectFixture(t, "sample.tar.gz")
+
+	opts := &inspectOptions{root: &rootOptions{artifactsJson: configPath}}
+
+	t.Run("TableByFilename", func(t *testing.T) {
+		opts.json = false
+		output := captureStdout(t, func() {
+			runInspect(&cobra.Command{Use: "test"}, []string{"sample.tar.gz"}, opts)
+		})
+
+		if !strings.Contains(output, "Artifact: sample.tar.gz") {
+			t.Errorf("Expected artifact header, got:\n%s", output)
+		}
+		if !strings.Contains(output, "Files: 2") {
+			t.Errorf("Expected 2 files, got:\n%s", output)
+		}
+		if !strings.Contains(output, "index.html") {
+			t.Errorf("Expected index.html to be listed, got:\n%s", output)
+		}
+	})
+
+	t.Run("JSONByAssetName", func(t *testing.T) {
+		opts.json = true
+		output := captureStdout(t, func() {
+			runInspect(&cobra.Command{Use: "test"}, []string{"library"}, opts)
+		})
+
+		var result struct {
+			Artifact string          `json:"artifact"`
+			Files    int             `json:"files"`
+			Entries  []archive.Entry `json:"entries"`
+		}
+		if err := json.Unmarshal([]byte(output), &result); err != nil {
+			t.Fatalf("Output is not valid JSON: %v\nOutput: %s", err, output)
+		}
+		if result.Artifact != "sample.tar.gz" {
+			t.Errorf("Expected asset name to resolve to sample.tar.gz, got %q", result.Artifact)
+		}
+		if result.Files != 2 {
+			t.Errorf("Expected 2 files, got %d", result.Files)
+		}
+	})
+}
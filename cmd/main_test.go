@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain points the user cache directory at a temporary directory so build
+// history written by the tests never lands in the real cache
+func TestMain(m *testing.M) {
+	cacheDir, err := os.MkdirTemp("", "slarty-cache")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CACHE_HOME", cacheDir)
+
+	code := m.Run()
+
+	os.RemoveAll(cacheDir)
+	os.Exit(code)
+}
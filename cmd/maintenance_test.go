@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+func TestMaintenance(t *testing.T) {
+	root := t.TempDir()
+	config := &slarty.ArtifactsConfig{RootDirectory: root}
+	config.Maintenance = slarty.MaintenanceConfig{
+		EnableCmd:  "echo on >> maintenance.log",
+		DisableCmd: "echo off >> maintenance.log",
+	}
+
+	opts := &rootOptions{}
+	m := opts.newMaintenance(config, false)
+	output := captureStdout(t, func() {
+		if err := m.Enable(); err != nil {
+			t.Fatalf("Enable failed: %v", err)
+		}
+		if err := m.Disable(); err != nil {
+			t.Fatalf("Disable failed: %v", err)
+		}
+		// Leaving maintenance mode twice runs the command once
+		if err := m.Disable(); err != nil {
+			t.Fatalf("Disable failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "Entering maintenance mode") || !strings.Contains(output, "Leaving maintenance mode") {
+		t.Errorf("Expected maintenance mode to be reported, got:\n%s", output)
+	}
+	log, err := os.ReadFile(filepath.Join(root, "maintenance.log"))
+	if err != nil || string(log) != "on\noff\n" {
+		t.Errorf("Expected the commands to run once each from the root directory, got %q (%v)", log, err)
+	}
+
+	// A failed command is an error and the application counts as in maintenance mode
+	config.Maintenance.DisableCmd = "exit 1"
+	m = opts.newMaintenance(config, false)
+	captureStdout(t, func() {
+		if err := m.Enable(); err != nil {
+			t.Fatalf("Enable failed: %v", err)
+		}
+		if err := m.Disable(); err == nil || !strings.Contains(err.Error(), "leave maintenance mode") {
+			t.Errorf("Expected a failed disable_cmd to be an error, got %v", err)
+		}
+	})
+	if !m.Active() {
+		t.Errorf("Expected the application to still be in maintenance mode")
+	}
+
+	// --no-maintenance and a configuration without commands do nothing
+	for _, m := range []*slarty.Maintenance{opts.newMaintenance(config, true), opts.newMaintenance(&slarty.ArtifactsConfig{RootDirectory: root}, false)} {
+		output := captureStdout(t, func() {
+			if err := m.Enable(); err != nil {
+				t.Errorf("Expected no maintenance commands to run, got %v", err)
+			}
+		})
+		if output != "" || m.Active() {
+			t.Errorf("Expected maintenance mode to be skipped, got:\n%s", output)
+		}
+	}
+}
+
+func TestMaintenanceFlags(t *testing.T) {
+	for _, c := range []*cobra.Command{newDoDeploysCmd(&rootOptions{}), newDeployAssetsCmd(&rootOptions{})} {
+		if c.Flags().Lookup("no-maintenance") == nil {
+			t.Errorf("%s command should have 'no-maintenance' flag", c.Use)
+		}
+	}
+}
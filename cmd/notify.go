@@ -0,0 +1,52 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/dstockto/slarty/slarty"
+	"os"
+)
+
+// sendNotification posts the run summary to the configured notification target.
+// A failure to notify is reported as a warning and never fails the run itself.
+func sendNotification(artifactConfig *slarty.ArtifactsConfig, summary slarty.RunSummary) {
+	if !artifactConfig.Notifications.Enabled() {
+		return
+	}
+
+	if err := slarty.Notify(artifactConfig.Notifications, summary, artifactConfig.Offline); err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to send notification: %v\n", err)
+	}
+}
+
+// pushRunMetrics sends the metrics recorded during a run to the configured metrics
+// endpoint. Like notifications, a failure to push is only reported as a warning.
+func pushRunMetrics(artifactConfig *slarty.ArtifactsConfig, recorder *slarty.MetricsRecorder) {
+	if !artifactConfig.Metrics.Enabled() {
+		return
+	}
+
+	if err := slarty.PushMetrics(artifactConfig.Metrics, recorder.Metrics(), artifactConfig.Offline); err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: failed to push metrics: %v\n", err)
+	}
+}
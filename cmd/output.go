@@ -0,0 +1,90 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// Formats for artifact tables
+const (
+	outputTable    = "table"
+	outputJSON     = "json"
+	outputCSV      = "csv"
+	outputMarkdown = "markdown"
+)
+
+// outputFormatFromFlags returns the format chosen with --output, or json for --json.
+// An unknown format is fatal.
+func outputFormatFromFlags(jsonOutput bool, outputFormat string) string {
+	if jsonOutput {
+		return outputJSON
+	}
+	switch outputFormat {
+	case "":
+		return outputTable
+	case outputTable, outputJSON, outputCSV, outputMarkdown:
+		return outputFormat
+	}
+	log.Fatalf("unknown output %q; use %s, %s, %s or %s", outputFormat, outputTable, outputJSON, outputCSV, outputMarkdown)
+	return ""
+}
+
+// writeRows writes a table with headers and rows to w as CSV, or as a Markdown table
+// for pasting into pull requests and issues
+func writeRows(w io.Writer, format string, headers []string, rows [][]string) error {
+	if format == outputCSV {
+		writer := csv.NewWriter(w)
+		if err := writer.Write(headers); err != nil {
+			return err
+		}
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		return writer.Error()
+	}
+
+	line := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = markdownEscaper.Replace(cell)
+		}
+		return "| " + strings.Join(escaped, " | ") + " |\n"
+	}
+	dividers := make([]string, len(headers))
+	for i := range dividers {
+		dividers[i] = "---"
+	}
+
+	var b strings.Builder
+	b.WriteString(line(headers))
+	b.WriteString("| " + strings.Join(dividers, " | ") + " |\n")
+	for _, row := range rows {
+		b.WriteString(line(row))
+	}
+	_, err := fmt.Fprint(w, b.String())
+	return err
+}
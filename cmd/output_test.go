@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteRows(t *testing.T) {
+	headers := []string{"Application", "Artifact Name"}
+	rows := [][]string{
+		{"web", "web-15ab981.tar.gz"},
+		{"api, v2", "api|v2-51286ac.tar.gz"},
+	}
+
+	var csvOut bytes.Buffer
+	if err := writeRows(&csvOut, outputCSV, headers, rows); err != nil {
+		t.Fatalf("writeRows failed: %v", err)
+	}
+	expectedCSV := "Application,Artifact Name\nweb,web-15ab981.tar.gz\n\"api, v2\",api|v2-51286ac.tar.gz\n"
+	if csvOut.String() != expectedCSV {
+		t.Errorf("Expected CSV:\n%s\ngot:\n%s", expectedCSV, csvOut.String())
+	}
+
+	var markdownOut bytes.Buffer
+	if err := writeRows(&markdownOut, outputMarkdown, headers, rows); err != nil {
+		t.Fatalf("writeRows failed: %v", err)
+	}
+	expectedMarkdown := "| Application | Artifact Name |\n| --- | --- |\n| web | web-15ab981.tar.gz |\n| api, v2 | api\\|v2-51286ac.tar.gz |\n"
+	if markdownOut.String() != expectedMarkdown {
+		t.Errorf("Expected Markdown:\n%s\ngot:\n%s", expectedMarkdown, markdownOut.String())
+	}
+}
+
+func TestOutputFormatFromFlags(t *testing.T) {
+	tests := []struct {
+		json     bool
+		format   string
+		expected string
+	}{
+		{false, "", outputTable},
+		{false, outputCSV, outputCSV},
+		{false, outputMarkdown, outputMarkdown},
+		{false, outputJSON, outputJSON},
+		{true, outputTable, outputJSON},
+	}
+	for _, tt := range tests {
+		if format := outputFormatFromFlags(tt.json, tt.format); format != tt.expected {
+			t.Errorf("Expected %s for --json=%v --output=%q, got %s", tt.expected, tt.json, tt.format, format)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// planOptions are the flags of the plan command
+type planOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	ref       string
+	base      string
+	json      bool
+	sort      string
+	variant   string
+}
+
+// newPlanCmd creates the plan command
+func newPlanCmd(root *rootOptions) *cobra.Command {
+	opts := &planOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Report which artifacts a ref would rebuild or reuse",
+		Long: `Reports, for the code in --ref (HEAD by default), which artifacts already exist in
+the repository and would be reused, and which would have to be rebuilt. When --base
+is given, artifacts whose code did not change since the base but whose archive is not
+in the repository are reported as missing, since the base should already have
+produced them. Sizes and build durations come from the local build history and the
+repository.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runPlan(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().StringVar(&opts.ref, "ref", "HEAD", "git ref to plan the build for")
+	cmd.Flags().StringVar(&opts.base, "base", "", "git ref whose artifacts should already be in the repository")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "output results as JSON")
+	cmd.Flags().StringVar(&opts.sort, "sort", sortByConfig, "order rows by config (as in artifacts.json) or name")
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "application3,application4", root.completeArtifactNames)
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+
+	return cmd
+}
+
+// planEntry is the plan for a single artifact
+type planEntry struct {
+	Application   string     `json:"application"`
+	ArtifactName  string     `json:"artifact_name"`
+	Status        string     `json:"status"`
+	Size          int64      `json:"size,omitempty"`
+	SizeEstimated bool       `json:"size_estimated,omitempty"`
+	LastBuild     float64    `json:"last_build_seconds,omitempty"`
+	LastBuiltAt   *time.Time `json:"last_built_at,omitempty"`
+}
+
+func runPlan(cmd *cobra.Command, args []string, opts *planOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := artifactConfig.ApplyVariant(opts.variant); err != nil {
+		log.Fatalln(err)
+	}
+
+	// Create a repository adapter
+	repoAdapter, err := opts.root.openRepository(artifactConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	artifacts := sortedArtifacts(artifactConfig.SelectArtifacts(opts.selection.selection()), opts.sort)
+	entries := planArtifacts(artifacts, artifactConfig, repoAdapter, opts.ref, opts.base, readLatestBuilds(artifactConfig))
+
+	if opts.json {
+		out, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No artifacts found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	fmt.Fprintf(w, " %s\t %s\t %s\t %s\t %s \n", "Application", "Artifact Name", "Plan", "Size", "Last Build")
+	counts := make(map[string]int)
+	for _, entry := range entries {
+		counts[entry.Status]++
+
+		size := "-"
+		if entry.Size > 0 {
+			size = formatBytes(entry.Size)
+			if entry.SizeEstimated {
+				size = "~" + size
+			}
+		}
+		lastBuild := "-"
+		if entry.LastBuiltAt != nil {
+			lastBuild = formatSeconds(entry.LastBuild)
+		}
+		fmt.Fprintf(w, " %s\t %s\t %s\t %s\t %s \n", entry.Application, entry.ArtifactName, entry.Status, size, lastBuild)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d to rebuild, %d to reuse, %d missing\n", counts["rebuild"], counts["reuse"], counts["missing"])
+}
+
+// planArtifacts works out whether each artifact for the code in ref would be reused
+// from the repository, rebuilt, or is missing even though its code has not changed
+// since base. latest holds the most recent recorded build of each artifact and is
+// used for durations and to estimate the size of archives not yet built.
+func planArtifacts(artifacts []slarty.ArtifactConfig, artifactConfig *slarty.ArtifactsConfig, repoAdapter slarty.RepositoryAdapter, ref, base string, latest map[string]slarty.BuildRecord) []planEntry {
+	// Get the artifact names first, so the repository can look them up in bulk
+	artifactNames := make([]string, len(artifacts))
+	for i, artifact := range artifacts {
+		artifactName, err := slarty.GetArtifactNameAtRef(artifact.Name, artifactConfig, ref)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		artifactNames[i] = artifactName
+	}
+	prefetchArtifacts(repoAdapter, artifacts, artifactNames)
+
+	entries := make([]planEntry, 0, len(artifacts))
+	for i, artifact := range artifacts {
+		artifactName := artifactNames[i]
+
+		repository := repositoryFor(artifact, artifactConfig, repoAdapter)
+		exists, err := repository.ArtifactExists(artifactName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		entry := planEntry{Application: artifact.Name, ArtifactName: artifactName, Status: "reuse"}
+		if !exists {
+			entry.Status = "rebuild"
+			if base != "" {
+				baseName, err := slarty.GetArtifactNameAtRef(artifact.Name, artifactConfig, base)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				if baseName == artifactName {
+					entry.Status = "missing"
+				}
+			}
+		}
+
+		if record, ok := latest[artifact.Name]; ok {
+			entry.LastBuild = record.Duration
+			builtAt := record.BuiltAt
+			entry.LastBuiltAt = &builtAt
+			entry.Size = record.Size
+			entry.SizeEstimated = record.ArtifactName != artifactName
+		}
+
+		if sizer, ok := repository.(slarty.ArtifactSizer); ok && exists {
+			if size, err := sizer.ArtifactSize(artifactName); err == nil {
+				entry.Size = size
+				entry.SizeEstimated = false
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// formatBytes formats a size in bytes for display
+func formatBytes(size int64) string {
+	return slarty.FormatBytes(size)
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestPlanCommand(t *testing.T) {
+	// Test that the plan command is properly initialized
+	cmd := newPlanCmd(&rootOptions{})
+	if cmd.Use != "plan" {
+		t.Errorf("Expected Use to be 'plan', got '%s'", cmd.Use)
+	}
+
+	if cmd.Short == "" {
+		t.Error("Expected Short description to be set")
+	}
+
+	// Check flags
+	flags := cmd.Flags()
+	for _, name := range []string{"ref", "base", "json", "filter", "exclude"} {
+		if flags.Lookup(name) == nil {
+			t.Errorf("plan command should have '%s' flag", name)
+		}
+	}
+}
+
+func TestPlanArtifacts(t *testing.T) {
+	tempDir := t.TempDir()
+	repoDir := filepath.Join(tempDir, "repo")
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	for _, dir := range []string{"api", "web", "docs"} {
+		write(dir+"/file.txt", dir)
+	}
+	git("init")
+	git("config", "user.email", "test@example.com")
+	git("config", "user.name", "Test User")
+	git("add", ".")
+	git("commit", "-m", "Initial commit")
+	git("tag", "base")
+
+	write("web/file.txt", "changed")
+	git("commit", "-am", "Change web")
+
+	config := &slarty.ArtifactsConfig{
+		RootDirectory: tempDir,
+		Artifacts: []slarty.ArtifactConfig{
+			{Name: "api", Directories: []string{"api"}, ArtifactPrefix: "api"},
+			{Name: "web", Directories: []string{"web"}, ArtifactPrefix: "web"},
+			{Name: "docs", Directories: []string{"docs"}, ArtifactPrefix: "docs"},
+		},
+	}
+	repo := slarty.NewLocalRepositoryAdapter(repoDir)
+
+	// api is already in the repository; docs is unchanged since base but was never stored
+	apiName, err := slarty.GetArtifactNameAtRef("api", config, "HEAD")
+	if err != nil {
+		t.Fatalf("Failed to get artifact name: %v", err)
+	}
+	write("api.tar.gz", "0123456789")
+	if err := slarty.StoreArtifactFile(repo, filepath.Join(tempDir, "api.tar.gz"), apiName); err != nil {
+		t.Fatalf("Failed to store artifact: %v", err)
+	}
+
+	latest := map[string]slarty.BuildRecord{
+		"web": {Artifact: "web", ArtifactName: "web-old.tar.gz", Size: 2048, Duration: 42, BuiltAt: time.Now()},
+	}
+
+	entries := planArtifacts(config.Artifacts, config, repo, "HEAD", "base", latest)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	if entries[0].Status != "reuse" || entries[0].Size != 10 || entries[0].SizeEstimated {
+		t.Errorf("Expected api to be reused with its stored size, got %+v", entries[0])
+	}
+	if entries[1].Status != "rebuild" || entries[1].Size != 2048 || !entries[1].SizeEstimated || entries[1].LastBuild != 42 {
+		t.Errorf("Expected web to be rebuilt with an estimate from its last build, got %+v", entries[1])
+	}
+	if entries[2].Status != "missing" {
+		t.Errorf("Expected docs to be missing, got %+v", entries[2])
+	}
+
+	// Without a base nothing can be known to be missing
+	entries = planArtifacts(config.Artifacts, config, repo, "HEAD", "", nil)
+	if entries[2].Status != "rebuild" {
+		t.Errorf("Expected docs to be rebuilt without a base, got %+v", entries[2])
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		512:             "512 B",
+		2048:            "2.0 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+	}
+	for size, expected := range tests {
+		if got := formatBytes(size); got != expected {
+			t.Errorf("formatBytes(%d) = %s, expected %s", size, got, expected)
+		}
+	}
+}
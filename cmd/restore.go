@@ -0,0 +1,222 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// errNotAvailable is returned when an artifact is neither cached nor in the repository
+var errNotAvailable = errors.New("not in the local build cache or the repository")
+
+// restoreOptions are the flags of the restore-outputs command
+type restoreOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	noCache   bool
+	variant   string
+	dirty     bool
+}
+
+// newRestoreCmd creates the restore-outputs command
+func newRestoreCmd(root *rootOptions) *cobra.Command {
+	opts := &restoreOptions{root: root}
+	cmd := &cobra.Command{
+		Use:     "restore",
+		Aliases: []string{"restore-outputs"},
+		Short:   "Populate output directories from the build cache or repository",
+		Long: `Fills the output_directory of each artifact with the build output matching the
+current code, without running the build. The archive is taken from the local build
+cache (see do-builds --cache) when it is there, and otherwise downloaded from the
+repository and added to the cache. The existing contents of each output directory are
+replaced. Use --no-cache to always download from the repository without touching the
+local cache. Artifacts that have never been built are reported and the command exits
+non-zero.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runRestore(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().BoolVar(&opts.noCache, "no-cache", false, "download from the repository without using the local build cache")
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "application3,application4", root.completeArtifactNames)
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+	cmd.Flags().BoolVar(&opts.dirty, "dirty", false, "name artifacts after the working tree, including unstaged changes, marking changed ones -dirty")
+
+	return cmd
+}
+
+func runRestore(cmd *cobra.Command, args []string, opts *restoreOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := artifactConfig.ApplyVariant(opts.variant); err != nil {
+		log.Fatalln(err)
+	}
+	artifactConfig.HashWorkingTree = opts.dirty
+
+	// Create a repository adapter
+	repoAdapter, err := opts.root.openRepository(artifactConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var cache slarty.RepositoryAdapter
+	if !opts.noCache {
+		cache, err = openBuildCache(artifactConfig)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	artifacts := artifactConfig.SelectArtifacts(opts.selection.selection())
+	if len(artifacts) == 0 {
+		fmt.Println("No artifacts found")
+		return
+	}
+
+	var unavailable []string
+	for _, artifact := range artifacts {
+		if artifact.IsDocker() {
+			fmt.Printf("%s: skipped, docker artifacts have no output directory\n", artifact.Name)
+			continue
+		}
+
+		source, err := opts.root.restoreOutput(artifact, artifactConfig, cache, repoAdapter)
+		if errors.Is(err, errNotAvailable) {
+			fmt.Printf("%s: %v, a build is needed\n", artifact.Name, err)
+			unavailable = append(unavailable, artifact.Name)
+			continue
+		}
+		if err != nil {
+			opts.root.flushAudit()
+			log.Fatalf("Failed to restore %s: %v", artifact.Name, err)
+		}
+		fmt.Printf("%s: restored %s from the %s\n", artifact.Name, artifact.OutputDirectory, source)
+	}
+
+	if len(unavailable) > 0 {
+		opts.root.flushAudit()
+		os.Exit(1)
+	}
+}
+
+// restoreOutput extracts the archive for the artifact's current hash into its output
+// directories and returns where the archive came from ("build cache" or "repository").
+// Archives downloaded from the repository are added to the cache. A nil cache
+// restores straight from the repository.
+func (o *rootOptions) restoreOutput(artifact slarty.ArtifactConfig, artifactConfig *slarty.ArtifactsConfig, cache slarty.RepositoryAdapter, repoAdapter slarty.RepositoryAdapter) (string, error) {
+	artifactName, err := o.artifactName(artifact.Name, artifactConfig)
+	if err != nil {
+		return "", err
+	}
+
+	// Several output directories are each archived under their path from the root
+	// directory, so their archive is extracted into the root directory
+	sources, err := artifactConfig.OutputSources(artifact)
+	if err != nil {
+		return "", err
+	}
+	extractPath := artifactConfig.RootDirectory
+	if len(sources) == 1 {
+		extractPath = sources[0].Dir
+	}
+	for _, source := range sources {
+		if err := artifactConfig.Cleanup.CheckCleanupPath(source.Dir, artifactConfig.RootDirectory, false); err != nil {
+			return "", fmt.Errorf("refusing to replace output directory: %w", err)
+		}
+	}
+
+	// Create a temporary file to hold the archive
+	tempFile, err := slarty.CreateTempArchive(artifactConfig.RootDirectory, "slarty-restore-*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempFilePath := tempFile.Name()
+	tempFile.Close()
+	defer os.Remove(tempFilePath)
+
+	source := "build cache"
+	cached := false
+	if cache != nil {
+		if cached, err = cache.ArtifactExists(artifactName); err != nil {
+			return "", err
+		}
+	}
+	if cached {
+		if err := slarty.RetrieveArtifactFile(cache, artifactName, tempFilePath); err != nil {
+			return "", err
+		}
+	} else {
+		source = "repository"
+		exists, err := repoAdapter.ArtifactExists(artifactName)
+		if err != nil {
+			return "", fmt.Errorf("failed to check if artifact exists in repository: %w", err)
+		}
+		if !exists {
+			return "", errNotAvailable
+		}
+		err = slarty.RetrieveArtifactFile(repoAdapter, artifactName, tempFilePath)
+		o.audit(artifactConfig, artifactAudit(artifactConfig, slarty.AuditRetrieve, "restore", artifact, artifactName, err))
+		if errors.Is(err, slarty.ErrArtifactNotFound) {
+			// Removed from the repository since it was checked for
+			return "", errNotAvailable
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to retrieve artifact from repository: %w", err)
+		}
+		if cache != nil {
+			if err := slarty.StoreArtifactFile(cache, tempFilePath, artifactName); err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: failed to add %s to the local build cache: %v\n", artifactName, err)
+			}
+		}
+	}
+
+	// Check the archive size before clearing the output directory for it
+	if info, err := os.Stat(tempFilePath); err == nil {
+		if err := artifactConfig.Extraction.CheckArchiveSize(info.Size()); err != nil {
+			return "", err
+		}
+	}
+
+	for _, source := range sources {
+		if err := os.MkdirAll(source.Dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := slarty.RemoveContents(source.Dir); err != nil {
+			return "", fmt.Errorf("failed to clear output directory: %w", err)
+		}
+	}
+	if err := extractArchive(tempFilePath, extractPath, artifactConfig.Extraction, o.maxFileBytes); err != nil {
+		return "", fmt.Errorf("failed to extract artifact: %w", err)
+	}
+
+	return source, nil
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestRestoreCommand(t *testing.T) {
+	// Test that the restore command is properly initialized
+	cmd := newRestoreCmd(&rootOptions{})
+	if cmd.Use != "restore" {
+		t.Errorf("Expected Use to be 'restore', got '%s'", cmd.Use)
+	}
+
+	if cmd.Short == "" {
+		t.Error("Expected Short description to be set")
+	}
+
+	// Check flags
+	if cmd.Flags().Lookup("filter") == nil {
+		t.Error("restore command should have 'filter' flag")
+	}
+	if newDoBuildsCmd(&rootOptions{}).Flags().Lookup("cache") == nil {
+		t.Error("do-builds command should have 'cache' flag")
+	}
+}
+
+func TestRestoreOutput(t *testing.T) {
+	artifacts := `
+		{ "name": "app", "directories": ["src"], "command": "echo built > build/out.txt", "output_directory": "build", "deploy_location": "deploy", "artifact_prefix": "app" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src", "build"})
+	config.Application = "Restore Test"
+
+	cache, err := openBuildCache(config)
+	if err != nil {
+		t.Fatalf("Failed to open build cache: %v", err)
+	}
+	t.Cleanup(func() {
+		dir, _ := applicationCacheDir(config)
+		os.RemoveAll(dir)
+	})
+
+	outputPath := filepath.Join(config.RootDirectory, "build")
+	stale := filepath.Join(outputPath, "stale.txt")
+
+	// Nothing has been built yet
+	if _, err := (&rootOptions{}).restoreOutput(config.Artifacts[0], config, cache, repo); !errors.Is(err, errNotAvailable) {
+		t.Fatalf("Expected errNotAvailable before any build, got %v", err)
+	}
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true, cache: true}
+	captureExecuteBuilds(t, opts, config, repo)
+
+	artifactName, _ := slarty.GetArtifactName("app", config)
+	if exists, _ := cache.ArtifactExists(artifactName); !exists {
+		t.Fatalf("Expected do-builds --cache to keep %s in the build cache", artifactName)
+	}
+
+	// Restoring from the cache replaces whatever was in the output directory
+	os.RemoveAll(outputPath)
+	os.MkdirAll(outputPath, 0755)
+	os.WriteFile(stale, []byte("stale"), 0644)
+
+	source, err := (&rootOptions{}).restoreOutput(config.Artifacts[0], config, cache, repo)
+	if err != nil {
+		t.Fatalf("restoreOutput failed: %v", err)
+	}
+	if source != "build cache" {
+		t.Errorf("Expected to restore from the build cache, got %s", source)
+	}
+	if content, err := os.ReadFile(filepath.Join(outputPath, "out.txt")); err != nil || string(content) != "built\n" {
+		t.Errorf("Expected out.txt to be restored, got %q, %v", content, err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("Expected stale files to be removed from the output directory")
+	}
+
+	// With an empty cache the archive comes from the repository and is then cached
+	dir, _ := applicationCacheDir(config)
+	os.RemoveAll(dir)
+	source, err = (&rootOptions{}).restoreOutput(config.Artifacts[0], config, cache, repo)
+	if err != nil {
+		t.Fatalf("restoreOutput failed: %v", err)
+	}
+	if source != "repository" {
+		t.Errorf("Expected to restore from the repository, got %s", source)
+	}
+	if exists, _ := cache.ArtifactExists(artifactName); !exists {
+		t.Error("Expected the downloaded archive to be added to the build cache")
+	}
+}
+
+func TestRestoreOutputRefusesUnsafeDirectories(t *testing.T) {
+	for _, dir := range []string{".", "../shared"} {
+		artifacts := `
+		{ "name": "app", "directories": ["src"], "command": "true", "output_directory": "` + dir + `", "deploy_location": "deploy", "artifact_prefix": "app" }`
+		config, repo := buildTestSetup(t, artifacts, []string{"src"})
+
+		_, err := (&rootOptions{}).restoreOutput(config.Artifacts[0], config, slarty.NewLocalRepositoryAdapter(t.TempDir()), repo)
+		if !errors.Is(err, slarty.ErrUnsafeCleanupPath) {
+			t.Fatalf("Expected restoring into %s to be refused, got %v", dir, err)
+		}
+		if _, statErr := os.Stat(filepath.Join(config.RootDirectory, "src")); statErr != nil {
+			t.Errorf("Expected the project to be left alone, got %v", statErr)
+		}
+	}
+}
+
+func TestRestoreOutputWithoutCache(t *testing.T) {
+	artifacts := `
+		{ "name": "app", "directories": ["src"], "command": "echo built > build/out.txt", "output_directory": "build", "deploy_location": "deploy", "artifact_prefix": "app" }`
+	config, repo := buildTestSetup(t, artifacts, []string{"src", "build"})
+
+	opts := &doBuildsOptions{root: &rootOptions{}, force: true}
+	captureExecuteBuilds(t, opts, config, repo)
+
+	outputPath := filepath.Join(config.RootDirectory, "build")
+	os.RemoveAll(outputPath)
+
+	source, err := (&rootOptions{}).restoreOutput(config.Artifacts[0], config, nil, repo)
+	if err != nil {
+		t.Fatalf("restoreOutput failed: %v", err)
+	}
+	if source != "repository" {
+		t.Errorf("Expected to restore from the repository, got %s", source)
+	}
+	if _, err := os.Stat(filepath.Join(outputPath, "out.txt")); err != nil {
+		t.Errorf("Expected out.txt to be restored: %v", err)
+	}
+}
+
+func TestRestoreOutputsAlias(t *testing.T) {
+	found, _, err := newRootCmd().Find([]string{"restore-outputs"})
+	if err != nil || found.Name() != "restore" {
+		t.Errorf("Expected restore-outputs to run the restore command, got %v, %v", found, err)
+	}
+	if found.Flags().Lookup("no-cache") == nil {
+		t.Error("restore command should have 'no-cache' flag")
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// rollbackOptions are the flags of the rollback command
+type rollbackOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	to        string
+	// platform is the os/arch whose matrix artifacts are rolled back
+	platform string
+}
+
+// newRollbackCmd creates the rollback command
+func newRollbackCmd(root *rootOptions) *cobra.Command {
+	opts := &rollbackOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Switch symlink-deployed artifacts back to their previous release",
+		Long: `Points deploy_location back at the previous release for artifacts deployed with
+"deploy_strategy": "symlink". Nothing is downloaded or extracted, so the rollback is
+instant. The previous release is the newest one deployed before the current release.
+Use --to with a release name, the hash it was built from, to switch to a particular
+release instead. Use --filter, --exclude and --tag to choose the artifacts.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runRollback(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().StringVar(&opts.to, "to", "", "release to switch to instead of the previous one")
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "application3,application4", root.completeArtifactNames)
+	cmd.Flags().StringVar(&opts.platform, "platform", slarty.HostPlatform(), "os/arch of the matrix artifacts to roll back")
+
+	return cmd
+}
+
+func runRollback(cmd *cobra.Command, args []string, opts *rollbackOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	artifacts := slarty.SelectPlatform(artifactConfig.SelectArtifacts(opts.selection.selection()), opts.platform)
+	if len(artifacts) == 0 {
+		fmt.Println("No artifacts found")
+		return
+	}
+
+	for _, artifact := range artifacts {
+		if !artifact.UsesSymlinkStrategy() {
+			fmt.Printf("Skipping %s, which is not deployed with the symlink strategy\n", artifact.Name)
+			continue
+		}
+		if err := artifact.CheckLocations(); err != nil {
+			log.Fatalf("Cannot roll back %s: %v", artifact.Name, err)
+		}
+		err := rollbackArtifact(os.Stdout, artifact, artifactConfig.RootDirectory, opts.to)
+
+		// The release rolled back to is named after its hash
+		record := artifactAudit(artifactConfig, slarty.AuditDeploy, "rollback", artifact, "", err)
+		if err == nil {
+			record.Hash, _ = slarty.CurrentRelease(filepath.Join(artifactConfig.RootDirectory, artifact.DeployLocation))
+		}
+		opts.root.audit(artifactConfig, record)
+		if err != nil {
+			opts.root.flushAudit()
+			log.Fatalln(err)
+		}
+	}
+}
+
+// rollbackArtifact points the artifact's deploy location at the release to, or the
+// release before the current one when to is empty
+func rollbackArtifact(w io.Writer, artifact slarty.ArtifactConfig, root, to string) error {
+	linkPath := filepath.Join(root, artifact.DeployLocation)
+	releasesPath := artifact.ReleasesPath(root)
+
+	current, err := slarty.CurrentRelease(linkPath)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return fmt.Errorf("%s has not been deployed with the symlink strategy", artifact.Name)
+	}
+	releases, err := slarty.ListReleases(releasesPath)
+	if err != nil {
+		return err
+	}
+
+	target := to
+	if target == "" {
+		target = slarty.PreviousRelease(releases, current)
+		if target == "" {
+			return fmt.Errorf("%s has no release before %s to roll back to", artifact.Name, current)
+		}
+	} else {
+		found := false
+		for _, release := range releases {
+			found = found || release.Name == target
+		}
+		if !found {
+			return fmt.Errorf("%s has no release %s", artifact.Name, target)
+		}
+	}
+
+	if err := slarty.LinkRelease(linkPath, filepath.Join(releasesPath, target)); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Rolled back %s from %s to %s\n", artifact.Name, current, target)
+
+	return nil
+}
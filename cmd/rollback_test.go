@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestRollbackCommand(t *testing.T) {
+	cmd := newRollbackCmd(&rootOptions{})
+	if cmd.Use != "rollback" {
+		t.Errorf("Expected rollback command Use to be 'rollback', got '%s'", cmd.Use)
+	}
+	for _, name := range []string{"to", "filter", "exclude", "tag", "platform"} {
+		if cmd.Flags().Lookup(name) == nil {
+			t.Errorf("rollback command should have '%s' flag", name)
+		}
+	}
+}
+
+func TestRollbackArtifact(t *testing.T) {
+	root := t.TempDir()
+	artifact := slarty.ArtifactConfig{Name: "web", DeployLocation: "current", DeployStrategy: slarty.DeployStrategySymlink}
+
+	// Nothing to roll back before the first deploy
+	var out bytes.Buffer
+	if err := rollbackArtifact(&out, artifact, root, ""); err == nil {
+		t.Errorf("Expected an error without a current release")
+	}
+
+	base := time.Now().Add(-time.Hour)
+	for i, release := range []string{"aaa", "bbb", "ccc"} {
+		path := filepath.Join(root, "releases", release)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatalf("Failed to create release: %v", err)
+		}
+		modTime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set release time: %v", err)
+		}
+	}
+	linkPath := filepath.Join(root, "current")
+	if err := slarty.LinkRelease(linkPath, filepath.Join(root, "releases", "ccc")); err != nil {
+		t.Fatalf("LinkRelease failed: %v", err)
+	}
+
+	if err := rollbackArtifact(&out, artifact, root, ""); err != nil {
+		t.Fatalf("rollbackArtifact failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Rolled back web from ccc to bbb") {
+		t.Errorf("Expected the rollback to be reported, got:\n%s", out.String())
+	}
+	// Rolling back again goes further back
+	if err := rollbackArtifact(&out, artifact, root, ""); err != nil {
+		t.Fatalf("rollbackArtifact failed: %v", err)
+	}
+	if current, _ := slarty.CurrentRelease(linkPath); current != "aaa" {
+		t.Errorf("Expected aaa to be current, got %s", current)
+	}
+	if err := rollbackArtifact(&out, artifact, root, ""); err == nil {
+		t.Errorf("Expected an error with no older release")
+	}
+
+	if err := rollbackArtifact(&out, artifact, root, "ccc"); err != nil {
+		t.Fatalf("rollbackArtifact --to failed: %v", err)
+	}
+	if current, _ := slarty.CurrentRelease(linkPath); current != "ccc" {
+		t.Errorf("Expected ccc to be current, got %s", current)
+	}
+	if err := rollbackArtifact(&out, artifact, root, "zzz"); err == nil {
+		t.Errorf("Expected an unknown release to be an error")
+	}
+}
@@ -17,57 +17,145 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/dstockto/slarty/slarty"
 	"github.com/spf13/cobra"
 	"os"
+	"regexp"
+	"strings"
 
 	"github.com/spf13/viper"
 )
 
-var (
-	cfgFile       string
+// rootOptions holds the root command's persistent flags, shared by every command,
+// and the state that lasts for the whole run
+type rootOptions struct {
+	configFile    string
 	artifactsJson string
-	filter        string
 	local         bool
-	jsonOutput    bool
-)
+	channel       string
+	limitRate     string
+	readOnly      bool
+	// strict makes unrecognized keys in artifacts.json an error
+	strict bool
+	// offline keeps slarty off the network
+	offline bool
+	// hashLength shortens the hashes in artifact names in place of hash_length, when
+	// it is not 0
+	hashLength int
+	// eventsTarget is where --events writes the progress events
+	eventsTarget string
+	// locationVars are the --var name=value deploy location placeholders, and
+	// variables their values by name once preRun has checked them
+	locationVars []string
+	variables    map[string]string
+	// assumeYes answers yes to confirmation prompts, and noPrompt never prompts
+	assumeYes bool
+	noPrompt  bool
+	// noSpaceCheck archives and extracts without checking for free disk space
+	noSpaceCheck bool
+	// runner runs the build, restart and maintenance commands and namer names
+	// artifacts, in place of the shell and hashing the git checkout when set. Tests,
+	// and programs running the commands themselves, set them so no processes are
+	// started.
+	runner slarty.CommandRunner
+	namer  slarty.ArtifactNamer
+	// maxFileBytes caps the size of each file extracted from an archive,
+	// archive.DefaultMaxFileBytes when 0
+	maxFileBytes int64
+
+	// events is the progress event stream, opened before the command runs when
+	// --events is given
+	events *slarty.EventWriter
+	// pendingAudit holds the audit records waiting to be stored in the repository
+	pendingAudit pendingAudit
+}
 
-// rootCmd represents the base command when called without any subcommands
-var rootCmd = &cobra.Command{
-	Use:   "slarty",
-	Short: "Slarty helps avoid building apps you have already built",
-	Long: `Slarty is a build and deployment manager that can figure out if you've 
+// newRootCmd creates the base command, run without any subcommands, with every
+// command added to it
+func newRootCmd() *cobra.Command {
+	opts := &rootOptions{}
+	cmd := &cobra.Command{
+		Use:   "slarty",
+		Short: "Slarty helps avoid building apps you have already built",
+		Long: `Slarty is a build and deployment manager that can figure out if you've 
 already created a build of an application based on the code it contains. It can also
 deploy application artifacts by figuring out which build matches the code you're
 deploying and grabbing it from a repository.`,
-	// Uncomment the following line if your bare application
-	// has an action associated with it:
-	// Run: func(cmd *cobra.Command, args []string) { },
-}
-
-// Execute adds all child commands to the root command and sets flags appropriately.
-// This is called by main.main(). It only needs to happen once to the rootCmd.
-func Execute() {
-	cobra.CheckErr(rootCmd.Execute())
-}
-
-func init() {
-	cobra.OnInitialize(initConfig)
+		// Uncomment the following line if your bare application
+		// has an action associated with it:
+		// Run: func(cmd *cobra.Command, args []string) { },
+		PersistentPreRunE: opts.preRun,
+		PersistentPostRun: opts.postRun,
+	}
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.slarty.yaml)")
-	rootCmd.PersistentFlags().StringVarP(&artifactsJson, "artifacts", "a", "./artifacts.json", "path to artifacts.json")
-	rootCmd.PersistentFlags().BoolVarP(&local, "local", "l", false, "-l (use local repo settings)")
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&opts.configFile, "config", "", "config file (default is $HOME/.slarty.yaml)")
+	flags.StringVarP(&opts.artifactsJson, "artifacts", "a", "./artifacts.json", "path to artifacts.json")
+	flags.BoolVarP(&opts.local, "local", "l", false, "-l (use local repo settings)")
+	flags.StringVar(&opts.channel, "channel", "", "keep artifacts under this channel (e.g. a branch name) in the repository")
+	flags.BoolVar(&opts.offline, "offline", false, "never touch the network; use the local repository and fail whatever needs AWS, vault, a registry or a webhook")
+	flags.BoolVar(&opts.readOnly, "read-only", false, "never store, label or delete anything in the repository; for inspecting it safely")
+	flags.IntVar(&opts.hashLength, "hash-length", 0, "shorten the hashes in artifact names to this many characters, overriding hash_length")
+	flags.StringVar(&opts.limitRate, "limit-rate", "", "limit repository transfers to this many bytes a second, such as 500K or 10M")
+	flags.StringVar(&opts.eventsTarget, "events", "", "write newline-delimited JSON progress events to a file, fd:N, or - for stderr")
+	flags.StringArrayVar(&opts.locationVars, "var", nil, "set a deploy location placeholder, as name=value (repeatable)")
+	flags.BoolVarP(&opts.assumeYes, "yes", "y", false, "answer yes to confirmation prompts, for automation")
+	flags.BoolVar(&opts.noPrompt, "non-interactive", false, "never prompt; commands that need confirmation fail unless --yes is given")
+	flags.BoolVar(&opts.strict, "strict", false, "fail on unrecognized keys in artifacts.json instead of warning about them")
+	// Unrecognized keys are only warned about now, which is what --no-strict did
+	flags.Bool("no-strict", false, "warn about unrecognized keys in artifacts.json instead of failing")
+	flags.MarkDeprecated("no-strict", "unrecognized keys are only warned about unless --strict is given")
+	flags.BoolVar(&opts.noSpaceCheck, "no-space-check", false, "archive and extract without first checking for free disk space")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	cmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+
+	cmd.AddCommand(
+		newArtifactNamesCmd(opts),
+		newChangedCmd(opts),
+		newConfigCmd(opts),
+		newDeployAssetsCmd(opts),
+		newDiffCmd(opts),
+		newDoBuildsCmd(opts),
+		newDoCleanupCmd(opts),
+		newDoDeploysCmd(opts),
+		newDoctorCmd(opts),
+		newFreezeCmd(opts),
+		newGenerateCICmd(opts),
+		newHashApplicationCmd(opts),
+		newHashCmd(),
+		newHistoryCmd(opts),
+		newInitCmd(opts),
+		newInspectCmd(opts),
+		newPlanCmd(opts),
+		newRestoreCmd(opts),
+		newRollbackCmd(opts),
+		newRunCmd(opts),
+		newShouldBuildCmd(opts),
+		newStatsCmd(opts),
+		newSyncCmd(opts),
+		newUICmd(),
+		newValidateCmd(opts),
+		newVersionCmd(),
+		newWatchCmd(opts),
+	)
+
+	return cmd
+}
+
+// Execute creates the root command and runs it with the command line arguments.
+// This is called by main.main().
+func Execute() {
+	cobra.CheckErr(newRootCmd().Execute())
 }
 
 // initConfig reads in config file and ENV variables if set.
-func initConfig() {
+func initConfig(cfgFile string) {
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -89,3 +177,104 @@ func initConfig() {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+// readConfig reads the artifacts.json given by --artifacts with readConfigFile
+func (o *rootOptions) readConfig() (*slarty.ArtifactsConfig, error) {
+	return o.readConfigFile(o.artifactsJson)
+}
+
+// readConfigFile reads the artifacts.json at path with the --var placeholders,
+// failing on unrecognized keys with --strict, keeps it off the network with --offline
+// and overrides its hash_length with --hash-length
+func (o *rootOptions) readConfigFile(path string) (*slarty.ArtifactsConfig, error) {
+	artifactConfig, err := slarty.ReadArtifactsJsonWithOptions(path, slarty.ReadOptions{Strict: o.strict, Variables: o.variables})
+	if err != nil {
+		return nil, err
+	}
+	artifactConfig.Offline = o.offline
+	if o.hashLength != 0 {
+		artifactConfig.HashLength = o.hashLength
+	}
+	return artifactConfig, nil
+}
+
+// openRepository creates the repository adapter for artifactConfig, applying the
+// --local, --offline, --channel, --limit-rate and --read-only flags
+func (o *rootOptions) openRepository(artifactConfig *slarty.ArtifactsConfig) (slarty.RepositoryAdapter, error) {
+	o.applyRepositoryFlags(artifactConfig)
+	return slarty.NewRepositoryAdapter(artifactConfig, o.local)
+}
+
+// applyRepositoryFlags sets the repository's channel and rate from the --channel and
+// --limit-rate flags, and makes it read-only with --read-only
+func (o *rootOptions) applyRepositoryFlags(artifactConfig *slarty.ArtifactsConfig) {
+	if o.channel != "" {
+		artifactConfig.Repository.Channel = o.channel
+	}
+	if o.limitRate != "" {
+		artifactConfig.Repository.LimitRate = o.limitRate
+	}
+	if o.readOnly {
+		artifactConfig.Repository.ReadOnly = true
+	}
+}
+
+// preRun runs before every command, reading the config file, checking the --var
+// placeholders and --hash-length, warning when artifacts.json needs a newer slarty
+// and opening the event stream
+func (o *rootOptions) preRun(cmd *cobra.Command, args []string) error {
+	initConfig(o.configFile)
+	variables, err := parseLocationVariables(o.locationVars)
+	if err != nil {
+		return err
+	}
+	o.variables = variables
+	if err := slarty.ValidateHashLength(o.hashLength); err != nil {
+		return fmt.Errorf("--hash-length: %w", err)
+	}
+	warnIfConfigNeedsNewerVersion(os.Stderr, o.artifactsJson)
+	return o.openEvents()
+}
+
+// locationVariableName matches the name of a deploy location placeholder
+var locationVariableName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// parseLocationVariables returns the deploy location placeholders given with --var
+// name=value by name. The configuration is read with them, so they win over the
+// environment.
+func parseLocationVariables(vars []string) (map[string]string, error) {
+	variables := make(map[string]string)
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || !locationVariableName.MatchString(name) {
+			return nil, fmt.Errorf("invalid --var %q, expected name=value", v)
+		}
+		if err := slarty.CheckLocationVariable(name, value); err != nil {
+			return nil, fmt.Errorf("--var %s: %w", v, err)
+		}
+		variables[name] = value
+	}
+	return variables, nil
+}
+
+// openEvents opens the event stream when --events is set
+func (o *rootOptions) openEvents() error {
+	if o.eventsTarget == "" {
+		return nil
+	}
+
+	stream, err := slarty.OpenEventStream(o.eventsTarget)
+	if err != nil {
+		return err
+	}
+	o.events = stream
+	return nil
+}
+
+// postRun runs after every command, storing its audit records in the repository and
+// closing the event stream
+func (o *rootOptions) postRun(cmd *cobra.Command, args []string) {
+	o.flushAudit()
+	o.events.Close()
+	o.events = nil
+}
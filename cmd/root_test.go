@@ -2,13 +2,13 @@ package cmd
 
 import (
 	"os"
+	"reflect"
 	"testing"
-
-	"github.com/spf13/cobra"
 )
 
 func TestRootCommand(t *testing.T) {
 	// Test that the root command is properly initialized
+	rootCmd := newRootCmd()
 	if rootCmd.Use != "slarty" {
 		t.Errorf("Expected root command Use to be 'slarty', got '%s'", rootCmd.Use)
 	}
@@ -24,7 +24,7 @@ func TestRootCommand(t *testing.T) {
 
 func TestRootCommandFlags(t *testing.T) {
 	// Test that the root command has the expected flags
-	flags := rootCmd.PersistentFlags()
+	flags := newRootCmd().PersistentFlags()
 
 	// Check config flag
 	if flags.Lookup("config") == nil {
@@ -54,6 +54,62 @@ func TestRootCommandFlags(t *testing.T) {
 	if localFlag != false {
 		t.Errorf("Expected local flag default to be false, got %v", localFlag)
 	}
+
+	// Check events flag
+	if flags.Lookup("events") == nil {
+		t.Error("Root command should have 'events' flag")
+	}
+
+	// Check channel flag
+	if flags.Lookup("channel") == nil {
+		t.Error("Root command should have 'channel' flag")
+	}
+
+	// Check var flag
+	if flags.Lookup("var") == nil {
+		t.Error("Root command should have 'var' flag")
+	}
+
+	// Check limit-rate flag
+	if flags.Lookup("limit-rate") == nil {
+		t.Error("Root command should have 'limit-rate' flag")
+	}
+
+	// Check offline flag
+	if flags.Lookup("offline") == nil {
+		t.Error("Root command should have 'offline' flag")
+	}
+
+	// Check read-only flag
+	if flags.Lookup("read-only") == nil {
+		t.Error("Root command should have 'read-only' flag")
+	}
+
+	// Check hash-length flag
+	if flags.Lookup("hash-length") == nil {
+		t.Error("Root command should have 'hash-length' flag")
+	}
+}
+
+func TestParseLocationVariables(t *testing.T) {
+	t.Setenv("SLARTY_ENV", "dev")
+
+	variables, err := parseLocationVariables([]string{"env=prod", "hostname=web1"})
+	if err != nil {
+		t.Fatalf("parseLocationVariables failed: %v", err)
+	}
+	if !reflect.DeepEqual(variables, map[string]string{"env": "prod", "hostname": "web1"}) {
+		t.Errorf("Expected the placeholders by name, got %v", variables)
+	}
+	if got := os.Getenv("SLARTY_ENV"); got != "dev" {
+		t.Errorf("Expected the environment to be left alone, got %q", got)
+	}
+
+	for _, v := range []string{"env", "=prod", "my-env=prod", "env=../prod", "env="} {
+		if _, err := parseLocationVariables([]string{v}); err == nil {
+			t.Errorf("Expected --var %q to be rejected", v)
+		}
+	}
 }
 
 func TestExecute(t *testing.T) {
@@ -64,19 +120,6 @@ func TestExecute(t *testing.T) {
 	// Set up a test case that should not fail
 	os.Args = []string{"slarty", "--help"}
 
-	// Create a test command to replace rootCmd temporarily
-	oldRoot := rootCmd
-	defer func() { rootCmd = oldRoot }()
-
-	testCmd := &cobra.Command{
-		Use:   "slarty",
-		Short: "Test command",
-		Run: func(cmd *cobra.Command, args []string) {
-			// Do nothing
-		},
-	}
-	rootCmd = testCmd
-
 	// This should not panic
 	Execute()
 }
@@ -97,22 +140,13 @@ func TestInitConfig(t *testing.T) {
 			t.Fatalf("Failed to write to temp file: %v", err)
 		}
 
-		// Set the config file
-		oldCfgFile := cfgFile
-		cfgFile = tmpfile.Name()
-		defer func() { cfgFile = oldCfgFile }()
-
-		// Call initConfig
-		initConfig()
+		// Call initConfig with the config file
+		initConfig(tmpfile.Name())
 	})
 
 	// Test without config file specified
 	t.Run("WithoutConfigFile", func(t *testing.T) {
-		oldCfgFile := cfgFile
-		cfgFile = ""
-		defer func() { cfgFile = oldCfgFile }()
-
 		// Call initConfig
-		initConfig()
+		initConfig("")
 	})
 }
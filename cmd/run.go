@@ -0,0 +1,144 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// runOptions are the flags of the run command
+type runOptions struct {
+	root   *rootOptions
+	dryRun bool
+}
+
+// newRunCmd creates the run command
+func newRunCmd(root *rootOptions) *cobra.Command {
+	opts := &runOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "run <pipeline>",
+		Short: "Run a pipeline of slarty commands defined in artifacts.json",
+		Long: `Runs the steps of a pipeline defined in the "pipelines" section of artifacts.json,
+one after another, stopping at the first step that fails. Each step is a slarty
+command line without the leading "slarty", for example "do-deploys --filter api".
+The --artifacts, --config, --local, --channel, --limit-rate, --read-only and
+--hash-length flags given to run are passed on to every step. Use --dry-run to list the steps without
+running them.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runRun(cmd, args, opts)
+		},
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: root.completePipelineNames,
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "list the pipeline's steps without running them")
+
+	return cmd
+}
+
+func runRun(cmd *cobra.Command, args []string, opts *runOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	steps, err := artifactConfig.Pipeline(args[0])
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if opts.dryRun {
+		for i, step := range steps {
+			fmt.Printf("%d. slarty %s\n", i+1, strings.Join(step, " "))
+		}
+		return
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := runPipeline(os.Stdout, args[0], steps, executable, opts.root.pipelineGlobalArgs()); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// pipelineGlobalArgs returns the global flags passed on to every step of a pipeline.
+// They come before the step's own arguments, so a step can still override them.
+func (o *rootOptions) pipelineGlobalArgs() []string {
+	args := []string{"--artifacts", o.artifactsJson}
+	if o.configFile != "" {
+		args = append(args, "--config", o.configFile)
+	}
+	if o.local {
+		args = append(args, "--local")
+	}
+	if o.offline {
+		args = append(args, "--offline")
+	}
+	if o.channel != "" {
+		args = append(args, "--channel", o.channel)
+	}
+	if o.limitRate != "" {
+		args = append(args, "--limit-rate", o.limitRate)
+	}
+	if o.readOnly {
+		args = append(args, "--read-only")
+	}
+	if o.hashLength != 0 {
+		args = append(args, "--hash-length", strconv.Itoa(o.hashLength))
+	}
+	for _, v := range o.locationVars {
+		args = append(args, "--var", v)
+	}
+	return args
+}
+
+// runPipeline runs each step as its own slarty process, so no flag set by one step
+// leaks into the next, and stops at the first step that fails
+func runPipeline(w io.Writer, name string, steps [][]string, executable string, globalArgs []string) error {
+	for i, step := range steps {
+		fmt.Fprintf(w, "==> [%d/%d] slarty %s\n", i+1, len(steps), strings.Join(step, " "))
+
+		args := append(append([]string{}, globalArgs...), step...)
+		c := exec.Command(executable, args...)
+		c.Stdin = os.Stdin
+		c.Stdout = w
+		c.Stderr = os.Stderr
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("pipeline %s failed at step %d (%s): %w", name, i+1, step[0], err)
+		}
+	}
+
+	fmt.Fprintf(w, "Pipeline %s finished %d steps\n", name, len(steps))
+	return nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunCommand(t *testing.T) {
+	cmd := newRunCmd(&rootOptions{})
+	if cmd.Use != "run <pipeline>" {
+		t.Errorf("Expected run command Use to be 'run <pipeline>', got '%s'", cmd.Use)
+	}
+
+	if cmd.Run == nil {
+		t.Error("run command Run function should not be nil")
+	}
+
+	if cmd.Flags().Lookup("dry-run") == nil {
+		t.Error("run command should have 'dry-run' flag")
+	}
+}
+
+func TestRunPipeline(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "calls.log")
+
+	// A stand-in for slarty that logs its arguments and fails for "fail"
+	script := `#!/bin/sh
+echo "$@" >> "` + logPath + `"
+for arg in "$@"; do
+	[ "$arg" = "fail" ] && exit 3
+done
+exit 0
+`
+	executable := filepath.Join(dir, "slarty")
+	if err := os.WriteFile(executable, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake slarty: %v", err)
+	}
+
+	global := []string{"--artifacts", "ci/artifacts.json"}
+	steps := [][]string{{"should-build"}, {"fail", "--filter", "api"}, {"do-deploys"}}
+
+	var out bytes.Buffer
+	err := runPipeline(&out, "release", steps, executable, global)
+	if err == nil || !strings.Contains(err.Error(), "pipeline release failed at step 2 (fail)") {
+		t.Fatalf("Expected the pipeline to fail at step 2, got %v", err)
+	}
+
+	calls, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read calls: %v", err)
+	}
+	expected := "--artifacts ci/artifacts.json should-build\n--artifacts ci/artifacts.json fail --filter api\n"
+	if string(calls) != expected {
+		t.Errorf("Expected the steps up to the failure with global flags first, got:\n%s", calls)
+	}
+	if !strings.Contains(out.String(), "==> [2/3] slarty fail --filter api") {
+		t.Errorf("Expected each step to be announced, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runPipeline(&out, "release", steps[:1], executable, global); err != nil {
+		t.Fatalf("Expected the pipeline to succeed, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Pipeline release finished 1 steps") {
+		t.Errorf("Expected a finished message, got:\n%s", out.String())
+	}
+}
+
+func TestPipelineGlobalArgs(t *testing.T) {
+	opts := &rootOptions{artifactsJson: "artifacts.json", local: true, locationVars: []string{"env=prod", "tenant=acme"}}
+	expected := "--artifacts artifacts.json --local --var env=prod --var tenant=acme"
+	if got := strings.Join(opts.pipelineGlobalArgs(), " "); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import "github.com/dstockto/slarty/slarty"
+
+// commandRunner returns what runs the build, restart and maintenance commands: the
+// runner set on the options, or the shell
+func (o *rootOptions) commandRunner() slarty.CommandRunner {
+	if o.runner != nil {
+		return o.runner
+	}
+	return slarty.ShellRunner{}
+}
+
+// artifactName names an artifact after its current code, with the namer set on the
+// options or by hashing the git checkout
+func (o *rootOptions) artifactName(name string, artifactConfig *slarty.ArtifactsConfig) (string, error) {
+	if o.namer != nil {
+		return o.namer(name, artifactConfig)
+	}
+	return slarty.GetArtifactName(name, artifactConfig)
+}
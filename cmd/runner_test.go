@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+// recordingRunner records the commands it is asked to run instead of running them,
+// failing any listed in fail
+type recordingRunner struct {
+	commands []string
+	fail     map[string]bool
+}
+
+func (r *recordingRunner) RunCommand(dir, command string, env map[string]string) error {
+	r.commands = append(r.commands, dir+": "+command)
+	if r.fail[command] {
+		return errors.New("exit status 1")
+	}
+	return nil
+}
+
+func TestCommandRunnerRunsDeployCommands(t *testing.T) {
+	root := t.TempDir()
+	config := &slarty.ArtifactsConfig{RootDirectory: root}
+	config.Maintenance = slarty.MaintenanceConfig{EnableCmd: "maintenance on", DisableCmd: "maintenance off"}
+	runner := &recordingRunner{fail: map[string]bool{"systemctl restart worker": true}}
+
+	opts := &rootOptions{runner: runner}
+	var results []slarty.RestartResult
+	captureStdout(t, func() {
+		m := opts.newMaintenance(config, false)
+		deployer := opts.newDeployer(config, nil, nil)
+		if err := m.Enable(); err != nil {
+			t.Fatalf("Enable failed: %v", err)
+		}
+		for _, name := range []string{"php-fpm", "worker"} {
+			results = append(results, deployer.Restart(slarty.Restart{Name: name, Command: "systemctl restart " + name}))
+		}
+		if err := m.Disable(); err != nil {
+			t.Fatalf("Disable failed: %v", err)
+		}
+	})
+
+	expected := []string{
+		root + ": maintenance on",
+		root + ": systemctl restart php-fpm",
+		root + ": systemctl restart worker",
+		root + ": maintenance off",
+	}
+	if strings.Join(runner.commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected commands %v, got %v", expected, runner.commands)
+	}
+	if results[0].Status != "restarted" || results[1].Status != "restart-failed" {
+		t.Errorf("Expected the worker restart to fail, got %+v", results)
+	}
+}
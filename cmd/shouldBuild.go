@@ -32,67 +32,89 @@ import (
 	"text/tabwriter"
 )
 
-// shouldBuildCmd represents the shouldBuild command
-var shouldBuildCmd = &cobra.Command{
-	Use:   "should-build",
-	Short: "Determine if a build is needed for each artifact",
-	Long: `Determines if a build is needed for each artifact by checking if the artifact
+// shouldBuildOptions are the flags of the should-build command
+type shouldBuildOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	json      bool
+	output    string
+	sort      string
+	// githubOutput also writes the results for GitHub Actions
+	githubOutput bool
+	variant      string
+	dirty        bool
+}
+
+// newShouldBuildCmd creates the should-build command
+func newShouldBuildCmd(root *rootOptions) *cobra.Command {
+	opts := &shouldBuildOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "should-build",
+		Short: "Determine if a build is needed for each artifact",
+		Long: `Determines if a build is needed for each artifact by checking if the artifact
 exists in the repository. If the artifact exists, a build is not needed. If it does not
-exist, a build is needed.`,
-	Run: runShouldBuild,
+exist, a build is needed.
+With --github-output the results are also written to $GITHUB_OUTPUT as step outputs
+(build_needed, artifacts, results and <name>_build_needed / <name>_artifact_name for
+each artifact) and to $GITHUB_STEP_SUMMARY as a markdown table.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runShouldBuild(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "", root.completeArtifactNames)
+	cmd.Flags().BoolVar(&opts.json, "json", false, "output results as JSON")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", outputTable, "output format: table, json, csv or markdown")
+	cmd.Flags().StringVar(&opts.sort, "sort", sortByConfig, "order rows by config (as in artifacts.json) or name")
+	cmd.Flags().BoolVar(&opts.githubOutput, "github-output", false, "also write results to $GITHUB_OUTPUT and $GITHUB_STEP_SUMMARY")
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+	cmd.Flags().BoolVar(&opts.dirty, "dirty", false, "name artifacts after the working tree, including unstaged changes, marking changed ones -dirty")
+
+	return cmd
 }
 
-func runShouldBuild(cmd *cobra.Command, args []string) {
+func runShouldBuild(cmd *cobra.Command, args []string, opts *shouldBuildOptions) {
 	// Read the artifacts configuration
-	artifactConfig, err := slarty.ReadArtifactsJson(artifactsJson)
+	artifactConfig, err := opts.root.readConfig()
 	if err != nil {
 		log.Fatalln(err)
 	}
+	if err := artifactConfig.ApplyVariant(opts.variant); err != nil {
+		log.Fatalln(err)
+	}
+	artifactConfig.HashWorkingTree = opts.dirty
 
 	// Create a repository adapter
-	repoAdapter, err := slarty.NewRepositoryAdapter(artifactConfig, local)
+	repoAdapter, err := opts.root.openRepository(artifactConfig)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
+	format := outputFormatFromFlags(opts.json, opts.output)
 	// Set up the table writer
 	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
 
-	// Parse the filter flag
-	var filters []string
-	if filter != "" {
-		filters = strings.Split(filter, ",")
-	}
-
 	// Get the artifacts based on the filter
-	artifacts := artifactConfig.GetByArtifactsByNameWithFilter(filters)
+	artifacts := sortedArtifacts(artifactConfig.SelectArtifacts(opts.selection.selection()), opts.sort)
+
+	decisions := opts.root.decideBuilds(artifacts, artifactConfig, repoAdapter)
 
 	// Track the longest name for formatting
 	var longestName int
 	buildNeeded := make(map[string]bool)
-
-	// Check if each artifact exists in the repository
-	for _, artifact := range artifacts {
-		// Get the artifact name
-		artifactName, err := slarty.GetArtifactName(artifact.Name, artifactConfig)
-		if err != nil {
-			log.Fatalln(err)
+	for _, decision := range decisions {
+		buildNeeded[decision.Application] = decision.BuildNeeded
+		if len(decision.Application) > longestName {
+			longestName = len(decision.Application)
 		}
+	}
 
-		// Check if the artifact exists in the repository
-		exists, err := repoAdapter.ArtifactExists(artifactName)
-		if err != nil {
-			log.Fatalln(err)
-		}
-		buildNeeded[artifact.Name] = !exists
-
-		// Track the longest name for formatting
-		if len(artifact.Name) > longestName {
-			longestName = len(artifact.Name)
-		}
+	if opts.githubOutput {
+		writeGitHubOutputs(decisions)
 	}
 
-	if jsonOutput {
+	if format == outputJSON {
 		type buildNeededEntry struct {
 			Application string `json:"application"`
 			BuildNeeded bool   `json:"build_needed"`
@@ -112,6 +134,21 @@ func runShouldBuild(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if format == outputCSV || format == outputMarkdown {
+		rows := make([][]string, 0, len(artifacts))
+		for _, artifact := range artifacts {
+			buildStatus := "NO"
+			if buildNeeded[artifact.Name] {
+				buildStatus = "YES"
+			}
+			rows = append(rows, []string{artifact.Name, buildStatus})
+		}
+		if err := writeRows(os.Stdout, format, []string{"Application", "Build Needed"}, rows); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
 	if len(artifacts) == 0 {
 		fmt.Println("No artifacts found")
 		return
@@ -141,10 +178,44 @@ func runShouldBuild(cmd *cobra.Command, args []string) {
 	w.Flush()
 }
 
-func init() {
-	rootCmd.AddCommand(shouldBuildCmd)
+// buildDecision is the should-build result for a single artifact
+type buildDecision struct {
+	Application  string `json:"application"`
+	ArtifactName string `json:"artifact_name"`
+	BuildNeeded  bool   `json:"build_needed"`
+}
 
-	// Here you will define your flags and configuration settings.
-	shouldBuildCmd.Flags().StringVarP(&filter, "filter", "f", "", "-f \"application1,application2\"")
-	shouldBuildCmd.Flags().BoolVar(&jsonOutput, "json", false, "output results as JSON")
+// decideBuilds works out the archive name for each artifact and whether it needs a
+// build because that archive is not in the repository yet
+func (o *rootOptions) decideBuilds(artifacts []slarty.ArtifactConfig, artifactConfig *slarty.ArtifactsConfig, repoAdapter slarty.RepositoryAdapter) []buildDecision {
+	// Get the artifact names first, so the repository can look them up in bulk
+	artifactNames := make([]string, len(artifacts))
+	for i, artifact := range artifacts {
+		artifactName, err := o.artifactName(artifact.Name, artifactConfig)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		artifactNames[i] = artifactName
+	}
+	prefetchArtifacts(repoAdapter, artifacts, artifactNames)
+
+	decisions := make([]buildDecision, 0, len(artifacts))
+	for i, artifact := range artifacts {
+		artifactName := artifactNames[i]
+
+		// Check if the artifact exists in the repository
+		exists, err := repositoryFor(artifact, artifactConfig, repoAdapter).ArtifactExists(artifactName)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if exists {
+			if err := slarty.CheckHashCollision(repoAdapter, artifactConfig, artifact, artifactName); err != nil {
+				log.Fatalln(err)
+			}
+		}
+
+		decisions = append(decisions, buildDecision{Application: artifact.Name, ArtifactName: artifactName, BuildNeeded: !exists})
+	}
+
+	return decisions
 }
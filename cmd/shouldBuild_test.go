@@ -16,26 +16,28 @@ import (
 
 func TestShouldBuildCommand(t *testing.T) {
 	// Test that the should-build command is properly initialized
-	if shouldBuildCmd.Use != "should-build" {
-		t.Errorf("Expected should-build command Use to be 'should-build', got '%s'", shouldBuildCmd.Use)
+	cmd := newShouldBuildCmd(&rootOptions{})
+	if cmd.Use != "should-build" {
+		t.Errorf("Expected should-build command Use to be 'should-build', got '%s'", cmd.Use)
 	}
 
-	if shouldBuildCmd.Short == "" {
+	if cmd.Short == "" {
 		t.Error("should-build command Short description should not be empty")
 	}
 
-	if shouldBuildCmd.Long == "" {
+	if cmd.Long == "" {
 		t.Error("should-build command Long description should not be empty")
 	}
 
-	if shouldBuildCmd.Run == nil {
+	if cmd.Run == nil {
 		t.Error("should-build command Run function should not be nil")
 	}
 }
 
 func TestShouldBuildCommandFlags(t *testing.T) {
 	// Test that the should-build command has the expected flags
-	flags := shouldBuildCmd.Flags()
+	cmd := newShouldBuildCmd(&rootOptions{})
+	flags := cmd.Flags()
 
 	// Check filter flag
 	if flags.Lookup("filter") == nil {
@@ -125,20 +127,7 @@ func TestRunShouldBuildJSON(t *testing.T) {
 		t.Fatalf("Failed to create artifact file: %v", err)
 	}
 
-	oldArtifactsJson := artifactsJson
-	defer func() { artifactsJson = oldArtifactsJson }()
-	artifactsJson = configPath
-
-	oldFilter := filter
-	defer func() { filter = oldFilter }()
-	filter = ""
-
-	oldLocal := local
-	defer func() { local = oldLocal }()
-	local = true
-
-	oldJSON := jsonOutput
-	defer func() { jsonOutput = oldJSON }()
+	opts := &shouldBuildOptions{root: &rootOptions{artifactsJson: configPath, local: true}}
 
 	type entry struct {
 		Application string `json:"application"`
@@ -146,14 +135,14 @@ func TestRunShouldBuildJSON(t *testing.T) {
 	}
 
 	t.Run("ValidJSONInConfigOrder", func(t *testing.T) {
-		jsonOutput = true
-		defer func() { jsonOutput = false }()
+		opts.json = true
+		defer func() { opts.json = false }()
 
 		oldStdout := os.Stdout
 		r, w, _ := os.Pipe()
 		os.Stdout = w
 
-		runShouldBuild(&cobra.Command{Use: "test"}, []string{})
+		runShouldBuild(&cobra.Command{Use: "test"}, []string{}, opts)
 
 		w.Close()
 		os.Stdout = oldStdout
@@ -184,19 +173,19 @@ func TestRunShouldBuildJSON(t *testing.T) {
 	})
 
 	t.Run("EmptyArrayWhenNoArtifacts", func(t *testing.T) {
-		jsonOutput = true
-		oldF := filter
-		filter = "non-existent"
+		opts.json = true
+		oldF := opts.selection.filter
+		opts.selection.filter = "non-existent"
 		defer func() {
-			jsonOutput = false
-			filter = oldF
+			opts.json = false
+			opts.selection.filter = oldF
 		}()
 
 		oldStdout := os.Stdout
 		r, w, _ := os.Pipe()
 		os.Stdout = w
 
-		runShouldBuild(&cobra.Command{Use: "test"}, []string{})
+		runShouldBuild(&cobra.Command{Use: "test"}, []string{}, opts)
 
 		w.Close()
 		os.Stdout = oldStdout
@@ -215,6 +204,44 @@ func TestRunShouldBuildJSON(t *testing.T) {
 			t.Errorf("JSON mode should not print 'No artifacts found', got: %s", buf.String())
 		}
 	})
+
+	t.Run("GitHubOutput", func(t *testing.T) {
+		opts.githubOutput = true
+		defer func() { opts.githubOutput = false }()
+
+		outputPath := filepath.Join(t.TempDir(), "output")
+		summaryPath := filepath.Join(t.TempDir(), "summary")
+		t.Setenv("GITHUB_OUTPUT", outputPath)
+		t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+
+		captureStdout(t, func() {
+			runShouldBuild(&cobra.Command{Use: "test"}, []string{}, opts)
+		})
+
+		output, err := os.ReadFile(outputPath)
+		if err != nil {
+			t.Fatalf("Failed to read GITHUB_OUTPUT: %v", err)
+		}
+		for _, want := range []string{
+			"build_needed=true\n",
+			`artifacts=["apple","mango"]` + "\n",
+			"zebra_build_needed=false\n",
+			"apple_build_needed=true\n",
+			"zebra_artifact_name=" + zebraName + "\n",
+		} {
+			if !strings.Contains(string(output), want) {
+				t.Errorf("Expected GITHUB_OUTPUT to contain %q, got:\n%s", want, output)
+			}
+		}
+
+		summary, err := os.ReadFile(summaryPath)
+		if err != nil {
+			t.Fatalf("Failed to read GITHUB_STEP_SUMMARY: %v", err)
+		}
+		if !strings.Contains(string(summary), "| zebra | `"+zebraName+"` | NO |") || !strings.Contains(string(summary), "| apple |") {
+			t.Errorf("Expected a markdown table in the step summary, got:\n%s", summary)
+		}
+	})
 }
 
 func TestRunShouldBuild(t *testing.T) {
@@ -315,19 +342,8 @@ func TestRunShouldBuild(t *testing.T) {
 		Short: "Test command",
 	}
 
-	// Save original artifactsJson and restore after test
-	oldArtifactsJson := artifactsJson
-	defer func() { artifactsJson = oldArtifactsJson }()
-	artifactsJson = configPath
-
-	// Save original filter and restore after test
-	oldFilter := filter
-	defer func() { filter = oldFilter }()
-
-	// Save original local flag and restore after test
-	oldLocal := local
-	defer func() { local = oldLocal }()
-	local = true // Use local repository adapter for testing
+	// Use local repository adapter for testing
+	opts := &shouldBuildOptions{root: &rootOptions{artifactsJson: configPath, local: true}}
 
 	// Create a repository directory
 	repoDir := filepath.Join(tempDir, "repo")
@@ -393,7 +409,7 @@ func TestRunShouldBuild(t *testing.T) {
 
 	// Test with no filter
 	t.Run("NoFilter", func(t *testing.T) {
-		filter = ""
+		opts.selection.filter = ""
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -401,7 +417,7 @@ func TestRunShouldBuild(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runShouldBuild(cmd, []string{})
+		runShouldBuild(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -431,7 +447,7 @@ func TestRunShouldBuild(t *testing.T) {
 
 	// Test with filter
 	t.Run("WithFilter", func(t *testing.T) {
-		filter = "test-artifact-1"
+		opts.selection.filter = "test-artifact-1"
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -439,7 +455,7 @@ func TestRunShouldBuild(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runShouldBuild(cmd, []string{})
+		runShouldBuild(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
@@ -466,7 +482,7 @@ func TestRunShouldBuild(t *testing.T) {
 
 	// Test with non-existent filter
 	t.Run("NonExistentFilter", func(t *testing.T) {
-		filter = "non-existent"
+		opts.selection.filter = "non-existent"
 
 		// Capture stdout
 		oldStdout := os.Stdout
@@ -474,7 +490,7 @@ func TestRunShouldBuild(t *testing.T) {
 		os.Stdout = w
 
 		// Run the command
-		runShouldBuild(cmd, []string{})
+		runShouldBuild(cmd, []string{}, opts)
 
 		// Restore stdout
 		w.Close()
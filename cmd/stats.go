@@ -0,0 +1,171 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// statsOptions are the flags of the stats command
+type statsOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	since     time.Duration
+	sort      string
+	top       int
+	json      bool
+}
+
+// newStatsCmd creates the stats command
+func newStatsCmd(root *rootOptions) *cobra.Command {
+	opts := &statsOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show build size and duration trends from the build history",
+		Long: `Summarises the build history recorded by do-builds: for each artifact the number
+of builds, the latest archive size, how much the archive grew between the oldest and
+newest recorded build, and average and latest build durations. Artifacts are sorted
+so the top offenders come first; use --sort to choose growth, size or duration and
+--top to only show the worst few.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runStats(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().DurationVar(&opts.since, "since", 0, "only consider builds from this long ago, such as 720h")
+	cmd.Flags().StringVar(&opts.sort, "sort", "growth", "order by growth, size, duration or name")
+	cmd.Flags().IntVar(&opts.top, "top", 0, "only show this many artifacts")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "output results as JSON")
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "application3,application4", root.completeArtifactNames)
+
+	return cmd
+}
+
+func runStats(cmd *cobra.Command, args []string, opts *statsOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	path, err := historyPath(artifactConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	records, err := slarty.ReadBuildHistory(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	stats, err := buildStats(records, artifactConfig, opts.selection.selection(), opts.since, opts.sort, opts.top)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if opts.json {
+		out, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	if len(stats) == 0 {
+		fmt.Println("No build history found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	fmt.Fprintf(w, " %s\t %s\t %s\t %s\t %s\t %s \n", "Application", "Builds", "Size", "Growth", "Avg Build", "Last Build")
+	for _, s := range stats {
+		sign, delta := "+", s.SizeGrowth
+		if delta < 0 {
+			sign, delta = "-", -delta
+		}
+		growth := fmt.Sprintf("%s%s (%+.1f%%)", sign, formatBytes(delta), s.GrowthPercent)
+		fmt.Fprintf(w, " %s\t %d\t %s\t %s\t %s\t %s \n", s.Artifact, s.Builds, formatBytes(s.LastSize), growth, formatSeconds(s.AvgDuration), formatSeconds(s.LastDuration))
+	}
+	w.Flush()
+}
+
+// buildStats summarises the history for the selected artifacts, keeping only builds
+// newer than since (if set), ordered worst first by sortBy and limited to top entries
+// (if set)
+func buildStats(records []slarty.BuildRecord, artifactConfig *slarty.ArtifactsConfig, selection slarty.Selection, since time.Duration, sortBy string, top int) ([]slarty.BuildStats, error) {
+	artifacts := make(map[string]slarty.ArtifactConfig)
+	for _, artifact := range artifactConfig.Artifacts {
+		artifacts[artifact.Name] = artifact
+	}
+
+	var cutoff time.Time
+	if since > 0 {
+		cutoff = time.Now().Add(-since)
+	}
+
+	var kept []slarty.BuildRecord
+	for _, record := range records {
+		if record.BuiltAt.Before(cutoff) || !selection.Includes(record.Artifact, artifacts[record.Artifact].Groups, artifacts[record.Artifact].Tags) {
+			continue
+		}
+		kept = append(kept, record)
+	}
+
+	stats := slarty.SummarizeBuilds(kept)
+
+	var less func(a, b slarty.BuildStats) bool
+	switch sortBy {
+	case "growth":
+		less = func(a, b slarty.BuildStats) bool { return a.SizeGrowth > b.SizeGrowth }
+	case "size":
+		less = func(a, b slarty.BuildStats) bool { return a.LastSize > b.LastSize }
+	case "duration":
+		less = func(a, b slarty.BuildStats) bool { return a.AvgDuration > b.AvgDuration }
+	case "name":
+		less = func(a, b slarty.BuildStats) bool { return a.Artifact < b.Artifact }
+	default:
+		return nil, fmt.Errorf("unknown sort %q; use growth, size, duration or name", sortBy)
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return less(stats[i], stats[j]) })
+
+	if top > 0 && len(stats) > top {
+		stats = stats[:top]
+	}
+
+	return stats, nil
+}
+
+// formatSeconds formats a duration in seconds for display
+func formatSeconds(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second).String()
+}
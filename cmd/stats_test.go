@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestStatsCommand(t *testing.T) {
+	// Test that the stats command is properly initialized
+	cmd := newStatsCmd(&rootOptions{})
+	if cmd.Use != "stats" {
+		t.Errorf("Expected Use to be 'stats', got '%s'", cmd.Use)
+	}
+
+	if cmd.Short == "" {
+		t.Error("Expected Short description to be set")
+	}
+
+	// Check flags
+	flags := cmd.Flags()
+	for _, name := range []string{"since", "sort", "top", "json", "filter", "tag"} {
+		if flags.Lookup(name) == nil {
+			t.Errorf("stats command should have '%s' flag", name)
+		}
+	}
+}
+
+func TestBuildStats(t *testing.T) {
+	now := time.Now()
+	records := []slarty.BuildRecord{
+		{Artifact: "api", Size: 1000, Duration: 60, BuiltAt: now.Add(-48 * time.Hour)},
+		{Artifact: "web", Size: 100, Duration: 10, BuiltAt: now.Add(-48 * time.Hour)},
+		{Artifact: "docs", Size: 50, Duration: 5, BuiltAt: now.Add(-2 * time.Hour)},
+		{Artifact: "api", Size: 1100, Duration: 70, BuiltAt: now.Add(-time.Hour)},
+		{Artifact: "web", Size: 900, Duration: 15, BuiltAt: now.Add(-time.Hour)},
+	}
+	config := &slarty.ArtifactsConfig{
+		Artifacts: []slarty.ArtifactConfig{
+			{Name: "api", Tags: []string{"backend"}},
+			{Name: "web", Tags: []string{"frontend"}},
+			{Name: "docs"},
+		},
+	}
+
+	names := func(stats []slarty.BuildStats) []string {
+		var n []string
+		for _, s := range stats {
+			n = append(n, s.Artifact)
+		}
+		return n
+	}
+
+	tests := []struct {
+		name      string
+		selection slarty.Selection
+		since     time.Duration
+		sortBy    string
+		top       int
+		expected  []string
+	}{
+		{"GrowthFirst", slarty.Selection{}, 0, "growth", 0, []string{"web", "api", "docs"}},
+		{"SizeFirst", slarty.Selection{}, 0, "size", 0, []string{"api", "web", "docs"}},
+		{"DurationFirst", slarty.Selection{}, 0, "duration", 0, []string{"api", "web", "docs"}},
+		{"Top", slarty.Selection{}, 0, "growth", 1, []string{"web"}},
+		{"Tag", slarty.Selection{Tags: []string{"backend"}}, 0, "growth", 0, []string{"api"}},
+		{"Since", slarty.Selection{}, 3 * time.Hour, "name", 0, []string{"api", "docs", "web"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := buildStats(records, config, tt.selection, tt.since, tt.sortBy, tt.top)
+			if err != nil {
+				t.Fatalf("buildStats failed: %v", err)
+			}
+			got := names(stats)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, got)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Fatalf("Expected %v, got %v", tt.expected, got)
+				}
+			}
+		})
+	}
+
+	// Only builds inside the window count towards growth
+	stats, _ := buildStats(records, config, slarty.Selection{}, 3*time.Hour, "name", 0)
+	if stats[2].Artifact != "web" || stats[2].Builds != 1 || stats[2].SizeGrowth != 0 {
+		t.Errorf("Expected a single recent web build, got %+v", stats[2])
+	}
+
+	if _, err := buildStats(records, config, slarty.Selection{}, 0, "bogus", 0); err == nil {
+		t.Error("Expected an error for an unknown sort")
+	}
+}
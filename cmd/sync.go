@@ -0,0 +1,248 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// syncOptions are the flags of the sync command
+type syncOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	pull      bool
+	push      bool
+	dir       string
+	ref       string
+	dryRun    bool
+	variant   string
+}
+
+// newSyncCmd creates the sync command
+func newSyncCmd(root *rootOptions) *cobra.Command {
+	opts := &syncOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "sync [--pull|--push]",
+		Short: "Mirror artifacts between the repository and a local copy of it",
+		Long: `Copies the archives of the artifacts and assets in artifacts.json between the
+configured repository and a local copy of it at the repository root, or --dir. With
+--pull, the default, archives are downloaded into the local copy, such as to seed an
+air-gapped host that then deploys with --offline. With --push, archives in the local
+copy are uploaded to the repository, such as to restore a bucket from a backup.
+Archives already at the destination are skipped, and archives are copied as they are
+stored, so encrypted ones stay encrypted. Artifacts are named after the code in --ref,
+or the index by default. Docker images are left to their registry.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runSync(cmd, args, opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.pull, "pull", false, "download archives from the repository into the local copy (the default)")
+	cmd.Flags().BoolVar(&opts.push, "push", false, "upload archives from the local copy to the repository")
+	cmd.Flags().StringVar(&opts.dir, "dir", "", "directory of the local copy (default is the repository root)")
+	cmd.Flags().StringVar(&opts.ref, "ref", "", "git ref to name artifacts after (default is the index)")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "list the archives that would be copied without copying them")
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "application3,application4", root.completeArtifactNames)
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+
+	return cmd
+}
+
+// syncResult is what happened to one archive
+type syncResult struct {
+	name   string
+	status string
+	size   int64
+}
+
+func runSync(cmd *cobra.Command, args []string, opts *syncOptions) {
+	if opts.pull && opts.push {
+		log.Fatalln("pass --pull or --push, not both")
+	}
+
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := artifactConfig.ApplyVariant(opts.variant); err != nil {
+		log.Fatalln(err)
+	}
+	if opts.dir != "" {
+		artifactConfig.Repository.Options.Root = opts.dir
+	}
+	opts.root.applyRepositoryFlags(artifactConfig)
+
+	remote, localCopy, err := slarty.MirrorRepositories(artifactConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	resolved, err := artifactConfig.ResolvedRepository(true)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	names, err := syncArchiveNames(artifactConfig, opts.selection.selection(), opts.ref)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(names) == 0 {
+		fmt.Println("No artifacts found")
+		return
+	}
+
+	from, to := remote, localCopy
+	fmt.Printf("Pulling %d archives from the repository into %s\n", len(names), resolved.Options.Root)
+	if opts.push {
+		from, to = localCopy, remote
+		fmt.Printf("Pushing %d archives from %s to the repository\n", len(names), resolved.Options.Root)
+	}
+
+	results, err := syncArchives(os.Stdout, from, to, names, opts.dryRun)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.status]++
+	}
+	copied := "copied"
+	if opts.dryRun {
+		copied = "to copy"
+	}
+	fmt.Printf("\n%d %s, %d already there, %d missing from the source\n", counts["copy"], copied, counts["present"], counts["missing"])
+}
+
+// syncArchiveNames returns the names of the archives of the selected artifacts, for the
+// code in ref, and the files of the selected assets. Docker artifacts have no archive.
+func syncArchiveNames(artifactConfig *slarty.ArtifactsConfig, selection slarty.Selection, ref string) ([]string, error) {
+	var names []string
+	for _, artifact := range artifactConfig.SelectArtifacts(selection) {
+		if artifact.IsDocker() {
+			continue
+		}
+		artifactName, err := slarty.GetArtifactNameAtRef(artifact.Name, artifactConfig, ref)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, artifactName)
+	}
+	for _, asset := range artifactConfig.SelectAssets(selection) {
+		names = append(names, asset.Filename)
+	}
+	return names, nil
+}
+
+// syncArchives copies each archive that is in from but not in to, reporting each one to
+// w. With dryRun nothing is copied. It stops at the first archive that fails to copy.
+func syncArchives(w io.Writer, from, to slarty.RepositoryAdapter, names []string, dryRun bool) ([]syncResult, error) {
+	slarty.PrefetchArtifacts(from, names)
+	slarty.PrefetchArtifacts(to, names)
+
+	var results []syncResult
+	seen := make(map[string]bool)
+	for _, name := range names {
+		// Artifacts with the same code share an archive
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		result := syncResult{name: name, status: "present"}
+		exists, err := to.ArtifactExists(name)
+		if err != nil {
+			return results, fmt.Errorf("failed to check for %s at the destination: %w", name, err)
+		}
+		if !exists {
+			if exists, err = from.ArtifactExists(name); err != nil {
+				return results, fmt.Errorf("failed to check for %s at the source: %w", name, err)
+			}
+			result.status = "missing"
+			if exists {
+				result.status = "copy"
+			}
+		}
+
+		switch {
+		case result.status == "present":
+			fmt.Fprintf(w, " - %s: already there\n", name)
+		case result.status == "missing":
+			fmt.Fprintf(w, " - %s: not in the source\n", name)
+		case dryRun:
+			fmt.Fprintf(w, " - %s: would be copied\n", name)
+		default:
+			if result.size, err = copyArchive(from, to, name); err != nil {
+				return results, err
+			}
+			fmt.Fprintf(w, " - %s: copied (%s)\n", name, formatBytes(result.size))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// copyArchive streams an archive from one repository into another, without a copy on
+// disk, and returns its size
+func copyArchive(from, to slarty.RepositoryAdapter, name string) (int64, error) {
+	pr, pw := io.Pipe()
+	retrieved := make(chan error, 1)
+	go func() {
+		err := from.RetrieveArtifact(name, pw)
+		pw.CloseWithError(err)
+		retrieved <- err
+	}()
+
+	counter := &countingReader{r: pr}
+	storeErr := to.StoreArtifact(counter, name)
+	// Stop the download if the destination gave up part way through
+	pr.CloseWithError(storeErr)
+
+	// A download error that is only the store failure echoed back is reported as the
+	// store failure
+	if err := <-retrieved; err != nil && !errors.Is(err, storeErr) {
+		return 0, fmt.Errorf("failed to retrieve %s: %w", name, err)
+	}
+	if storeErr != nil {
+		return 0, fmt.Errorf("failed to store %s: %w", name, storeErr)
+	}
+	return counter.n, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestSyncCommand(t *testing.T) {
+	cmd := newSyncCmd(&rootOptions{})
+	if cmd.Use != "sync [--pull|--push]" {
+		t.Errorf("Expected sync command Use to be 'sync [--pull|--push]', got '%s'", cmd.Use)
+	}
+	if cmd.Run == nil {
+		t.Error("sync command Run function should not be nil")
+	}
+	for _, name := range []string{"pull", "push", "dir", "ref", "dry-run", "filter", "exclude"} {
+		if cmd.Flags().Lookup(name) == nil {
+			t.Errorf("sync command should have '%s' flag", name)
+		}
+	}
+}
+
+func TestSyncArchives(t *testing.T) {
+	fromDir, toDir := t.TempDir(), t.TempDir()
+	from := slarty.NewLocalRepositoryAdapter(fromDir)
+	to := slarty.NewLocalRepositoryAdapter(toDir)
+	os.WriteFile(filepath.Join(fromDir, "a.tar.gz"), []byte("archive a"), 0644)
+	os.WriteFile(filepath.Join(fromDir, "b.tar.gz"), []byte("archive b"), 0644)
+	os.WriteFile(filepath.Join(toDir, "b.tar.gz"), []byte("archive b"), 0644)
+	names := []string{"a.tar.gz", "b.tar.gz", "c.tar.gz", "a.tar.gz"}
+
+	var out bytes.Buffer
+	_, err := syncArchives(&out, from, to, names, true)
+	if err != nil {
+		t.Fatalf("syncArchives failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(toDir, "a.tar.gz")); !os.IsNotExist(err) {
+		t.Errorf("Expected a dry run not to copy anything")
+	}
+	if !strings.Contains(out.String(), "a.tar.gz: would be copied") {
+		t.Errorf("Expected the dry run to list a.tar.gz, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	results, err := syncArchives(&out, from, to, names, false)
+	if err != nil {
+		t.Fatalf("syncArchives failed: %v", err)
+	}
+	expected := map[string]string{"a.tar.gz": "copy", "b.tar.gz": "present", "c.tar.gz": "missing"}
+	if len(results) != len(expected) {
+		t.Errorf("Expected each archive once, got %d results", len(results))
+	}
+	for _, result := range results {
+		if result.status != expected[result.name] {
+			t.Errorf("Expected %s to be %s, got %s", result.name, expected[result.name], result.status)
+		}
+	}
+	if copied, _ := os.ReadFile(filepath.Join(toDir, "a.tar.gz")); string(copied) != "archive a" {
+		t.Errorf("Expected a.tar.gz to be copied, got %q", copied)
+	}
+	for _, line := range []string{"a.tar.gz: copied (9 B)", "b.tar.gz: already there", "c.tar.gz: not in the source"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected %q in the output, got:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestCopyArchiveFailure(t *testing.T) {
+	from := slarty.NewLocalRepositoryAdapter(t.TempDir())
+	to := slarty.NewLocalRepositoryAdapter(t.TempDir())
+	if _, err := copyArchive(from, to, "missing.tar.gz"); err == nil || !strings.Contains(err.Error(), "failed to retrieve") {
+		t.Errorf("Expected a missing archive to fail to retrieve, got %v", err)
+	}
+}
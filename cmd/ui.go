@@ -0,0 +1,355 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// uiLogLines is how many lines of the command's output the dashboard shows
+const uiLogLines = 10
+
+// uiRefresh is how often the dashboard is redrawn while the command runs
+const uiRefresh = 200 * time.Millisecond
+
+// newUICmd creates the ui command
+func newUICmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ui <do-builds|do-deploys> [flags]",
+		Short: "Run builds or deploys with a live terminal dashboard",
+		Long: `Runs do-builds or do-deploys with the given flags and shows a dashboard in the
+terminal instead of the raw output: the status and duration of each artifact, overall
+progress, and the last lines the command printed. When artifacts fail, the dashboard
+offers to retry just those artifacts.
+
+For example: slarty ui do-builds --filter "api,web" --cache
+
+The dashboard is drawn from the command's progress events, so every flag after the
+command name, global flags included, is passed on to it unchanged.`,
+		Args:               cobra.MinimumNArgs(1),
+		DisableFlagParsing: true,
+		Run:                runUI,
+	}
+}
+
+func runUI(cmd *cobra.Command, args []string) {
+	if args[0] == "-h" || args[0] == "--help" {
+		cmd.Help()
+		return
+	}
+	if args[0] != "do-builds" && args[0] != "do-deploys" {
+		log.Fatalf("slarty ui runs do-builds or do-deploys, not %s", args[0])
+	}
+	if !isTerminal(os.Stdout) {
+		log.Fatalln("slarty ui needs a terminal; run the command directly instead")
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	runArgs := args
+	for {
+		board := newDashboard(args[0])
+		err := runDashboard(os.Stdout, board, executable, runArgs)
+		if err == nil {
+			return
+		}
+
+		retry := board.retry()
+		if len(retry) == 0 || !isTerminal(os.Stdin) {
+			log.Fatalf("%s failed: %v", args[0], err)
+		}
+		fmt.Printf("\nRetry %s? [y/N] ", strings.Join(retry, ", "))
+		answer, _ := stdin.ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			log.Fatalf("%s failed: %v", args[0], err)
+		}
+
+		// A later --filter replaces the one in the original flags
+		runArgs = append(append([]string{}, args...), "--filter", strings.Join(retry, ","))
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// runDashboard runs slarty with args, drawing the dashboard from its events and
+// output until it exits
+func runDashboard(w io.Writer, board *dashboard, executable string, args []string) error {
+	eventsReader, eventsWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	outputReader, outputWriter, err := os.Pipe()
+	if err != nil {
+		eventsReader.Close()
+		eventsWriter.Close()
+		return err
+	}
+
+	// The events go to the child's file descriptor 3, its first extra file
+	c := exec.Command(executable, append(append([]string{}, args...), "--events", "fd:3")...)
+	c.Stdout = outputWriter
+	c.Stderr = outputWriter
+	c.ExtraFiles = []*os.File{eventsWriter}
+	err = c.Start()
+	eventsWriter.Close()
+	outputWriter.Close()
+	if err != nil {
+		eventsReader.Close()
+		outputReader.Close()
+		return err
+	}
+
+	updates := make(chan func(*dashboard))
+	done := make(chan struct{}, 2)
+	go func() {
+		scanner := bufio.NewScanner(eventsReader)
+		for scanner.Scan() {
+			var event slarty.Event
+			if json.Unmarshal(scanner.Bytes(), &event) == nil {
+				updates <- func(d *dashboard) { d.apply(event) }
+			}
+		}
+		done <- struct{}{}
+	}()
+	go func() {
+		scanner := bufio.NewScanner(outputReader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			updates <- func(d *dashboard) { d.addLog(line) }
+		}
+		done <- struct{}{}
+	}()
+
+	ticker := time.NewTicker(uiRefresh)
+	defer ticker.Stop()
+	for open := 2; open > 0; {
+		select {
+		case update := <-updates:
+			update(board)
+		case <-done:
+			open--
+		case <-ticker.C:
+			board.draw(w, time.Now())
+		}
+	}
+	eventsReader.Close()
+	outputReader.Close()
+
+	err = c.Wait()
+	board.draw(w, time.Now())
+	return err
+}
+
+// dashboardRow is the state of one artifact on the dashboard
+type dashboardRow struct {
+	artifactName string
+	status       string
+	started      time.Time
+	duration     time.Duration
+	bytes        int64
+	err          string
+}
+
+// dashboard is the state the ui command draws, built up from the command's events
+// and output
+type dashboard struct {
+	command string
+	// selected are the artifacts the run-started event says the command selected
+	selected []string
+	order    []string
+	rows     map[string]*dashboardRow
+	logs     []string
+	started  time.Time
+	finished string
+}
+
+func newDashboard(command string) *dashboard {
+	return &dashboard{command: command, rows: make(map[string]*dashboardRow), started: time.Now()}
+}
+
+// row returns the row for an artifact, adding it in the order artifacts first appear
+func (d *dashboard) row(artifact string) *dashboardRow {
+	row, ok := d.rows[artifact]
+	if !ok {
+		row = &dashboardRow{status: "queued"}
+		d.rows[artifact] = row
+		d.order = append(d.order, artifact)
+	}
+	return row
+}
+
+// apply updates the dashboard with an event
+func (d *dashboard) apply(event slarty.Event) {
+	switch event.Type {
+	case slarty.EventRunStarted:
+		d.selected = event.Artifacts
+		for _, artifact := range event.Artifacts {
+			d.row(artifact)
+		}
+	case slarty.EventRunFinished:
+		d.finished = event.Status
+	case slarty.EventBuildStarted, slarty.EventDeployStarted:
+		row := d.row(event.Artifact)
+		row.artifactName = event.ArtifactName
+		row.status = "running"
+		row.started = event.Time
+	case slarty.EventUploadProgress:
+		row := d.row(event.Artifact)
+		row.status = "uploading"
+		row.bytes = event.Bytes
+	case slarty.EventBuildFinished, slarty.EventDeployFinished:
+		row := d.row(event.Artifact)
+		if event.ArtifactName != "" {
+			row.artifactName = event.ArtifactName
+		}
+		row.status = event.Status
+		row.duration = time.Duration(event.Duration * float64(time.Second)).Round(time.Millisecond)
+		row.err = event.Error
+	}
+}
+
+// addLog adds a line of the command's output, keeping only the last uiLogLines
+func (d *dashboard) addLog(line string) {
+	if i := strings.LastIndex(line, "\r"); i >= 0 {
+		line = line[i+1:]
+	}
+	d.logs = append(d.logs, line)
+	if len(d.logs) > uiLogLines {
+		d.logs = d.logs[len(d.logs)-uiLogLines:]
+	}
+}
+
+// retry returns the selected artifacts to run again: those that failed and those the
+// command stopped before finishing, such as the ones after the first failed deploy.
+// Rows for anything else, like a failed approval or maintenance command, are not
+// artifacts and are left out.
+func (d *dashboard) retry() []string {
+	var retry []string
+	for _, artifact := range d.selected {
+		switch d.row(artifact).status {
+		case slarty.BuildBuilt, slarty.BuildSkipped, "deployed":
+		default:
+			retry = append(retry, artifact)
+		}
+	}
+	return retry
+}
+
+// draw clears the terminal and draws the dashboard
+func (d *dashboard) draw(w io.Writer, now time.Time) {
+	io.WriteString(w, "\x1b[H\x1b[2J")
+	d.render(w, now)
+}
+
+// render writes the dashboard as plain text
+func (d *dashboard) render(w io.Writer, now time.Time) {
+	width := 100
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 20 {
+		width = columns
+	}
+
+	counts := make(map[string]int)
+	for _, artifact := range d.order {
+		counts[d.rows[artifact].status]++
+	}
+	done := len(d.order) - counts["queued"] - counts["running"] - counts["uploading"]
+
+	state := "running"
+	if d.finished != "" {
+		state = d.finished
+	}
+	fmt.Fprintf(w, "slarty %s - %s - %s\n\n", d.command, state, now.Sub(d.started).Round(time.Second))
+
+	tw := tabwriter.NewWriter(w, 1, 1, 2, ' ', 0)
+	fmt.Fprintln(tw, " Artifact\tStatus\tTime\tArtifact Name")
+	for _, artifact := range d.order {
+		row := d.rows[artifact]
+		elapsed := ""
+		switch {
+		case row.duration > 0:
+			elapsed = row.duration.String()
+		case !row.started.IsZero():
+			elapsed = now.Sub(row.started).Round(time.Second).String()
+		}
+		status := row.status
+		if row.status == "uploading" && row.bytes > 0 {
+			status += " " + formatBytes(row.bytes)
+		}
+		fmt.Fprintf(tw, " %s\t%s\t%s\t%s\n", artifact, status, elapsed, row.artifactName)
+	}
+	tw.Flush()
+
+	barWidth := 30
+	filled := 0
+	if len(d.order) > 0 {
+		filled = done * barWidth / len(d.order)
+	}
+	fmt.Fprintf(w, "\n [%s%s] %d/%d done", strings.Repeat("=", filled), strings.Repeat("-", barWidth-filled), done, len(d.order))
+	for _, status := range []string{"built", "deployed", "skipped", "failed"} {
+		if counts[status] > 0 {
+			fmt.Fprintf(w, ", %d %s", counts[status], status)
+		}
+	}
+	fmt.Fprintln(w)
+
+	for _, artifact := range d.order {
+		if row := d.rows[artifact]; row.err != "" {
+			fmt.Fprintf(w, " %s: %s\n", artifact, truncate(row.err, width-len(artifact)-3))
+		}
+	}
+
+	fmt.Fprintln(w, "\n Output:")
+	for _, line := range d.logs {
+		fmt.Fprintf(w, "   %s\n", truncate(line, width-3))
+	}
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if n < 1 || len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
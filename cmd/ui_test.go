@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestUICommand(t *testing.T) {
+	cmd := newUICmd()
+	if cmd.Run == nil {
+		t.Error("ui command Run function should not be nil")
+	}
+
+	if !cmd.DisableFlagParsing {
+		t.Error("ui command should pass its flags on to the command it runs")
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	board := newDashboard("do-builds")
+	board.started = started
+
+	board.apply(slarty.Event{Type: slarty.EventRunStarted, Artifacts: []string{"models", "web", "api"}})
+	board.apply(slarty.Event{Type: slarty.EventBuildFinished, Artifact: "models", ArtifactName: "models-aaa.tar.gz", Status: "skipped"})
+	board.apply(slarty.Event{Type: slarty.EventBuildStarted, Artifact: "web", ArtifactName: "web-bbb.tar.gz", Time: started})
+	board.apply(slarty.Event{Type: slarty.EventBuildStarted, Artifact: "api", ArtifactName: "api-ccc.tar.gz", Time: started})
+	board.apply(slarty.Event{Type: slarty.EventBuildFinished, Artifact: "api", Status: "failed", Error: "build command failed: exit status 1", Duration: 1.5})
+	board.apply(slarty.Event{Type: slarty.EventUploadProgress, Artifact: "web", Bytes: 2048})
+	for i := 0; i < uiLogLines+5; i++ {
+		board.addLog("progress\rline " + string(rune('a'+i)))
+	}
+
+	var out bytes.Buffer
+	board.render(&out, started.Add(3*time.Second))
+	output := out.String()
+
+	for _, expected := range []string{
+		"slarty do-builds - running - 3s",
+		" models    skipped",
+		" web       uploading 2.0 KiB  3s",
+		" api       failed             1.5s",
+		"2/3 done, 1 skipped, 1 failed",
+		"api: build command failed: exit status 1",
+		"   line o\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected the dashboard to contain %q, got:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "line e") || strings.Contains(output, "progress") {
+		t.Errorf("Expected only the last %d output lines without overwritten text, got:\n%s", uiLogLines, output)
+	}
+
+	if retry := board.retry(); strings.Join(retry, ",") != "web,api" {
+		t.Errorf("Expected web and api to be retried, got %v", retry)
+	}
+}
+
+func TestDashboardRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []slarty.Event
+		expected string
+	}{
+		{
+			name: "a failed deploy stops the rest",
+			events: []slarty.Event{
+				{Type: slarty.EventDeployStarted, Artifact: "api"},
+				{Type: slarty.EventDeployFinished, Artifact: "api", Status: "deployed"},
+				{Type: slarty.EventDeployStarted, Artifact: "web"},
+				{Type: slarty.EventDeployFinished, Artifact: "web", Status: "failed", Error: "extract failed"},
+			},
+			expected: "web,worker",
+		},
+		{
+			name: "a failure that is not an artifact",
+			events: []slarty.Event{
+				{Type: slarty.EventDeployFinished, Artifact: "approval", Status: "failed", Error: "approval token required"},
+			},
+			expected: "api,web,worker",
+		},
+		{
+			name: "every artifact deployed",
+			events: []slarty.Event{
+				{Type: slarty.EventDeployFinished, Artifact: "api", Status: "deployed"},
+				{Type: slarty.EventDeployFinished, Artifact: "web", Status: "skipped"},
+				{Type: slarty.EventDeployFinished, Artifact: "worker", Status: "deployed"},
+			},
+			expected: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			board := newDashboard("do-deploys")
+			board.apply(slarty.Event{Type: slarty.EventRunStarted, Artifacts: []string{"api", "web", "worker"}})
+			for _, event := range tt.events {
+				board.apply(event)
+			}
+			if retry := strings.Join(board.retry(), ","); retry != tt.expected {
+				t.Errorf("Expected to retry %q, got %q", tt.expected, retry)
+			}
+		})
+	}
+}
+
+func TestRunDashboard(t *testing.T) {
+	dir := t.TempDir()
+
+	// A stand-in for slarty that writes events to fd 3 and output to stdout
+	script := `#!/bin/sh
+echo "building with $*"
+echo '{"type":"run-started","artifacts":["web"]}' >&3
+echo '{"type":"build-started","artifact":"web","artifact_name":"web-abc.tar.gz"}' >&3
+echo '{"type":"build-finished","artifact":"web","status":"failed","error":"boom"}' >&3
+echo '{"type":"run-finished","status":"failed"}' >&3
+exit 1
+`
+	executable := filepath.Join(dir, "slarty")
+	if err := os.WriteFile(executable, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake slarty: %v", err)
+	}
+
+	board := newDashboard("do-builds")
+	var out bytes.Buffer
+	if err := runDashboard(&out, board, executable, []string{"do-builds", "--filter", "web"}); err == nil {
+		t.Fatalf("Expected the failed command to return an error")
+	}
+
+	if retry := board.retry(); len(retry) != 1 || retry[0] != "web" {
+		t.Errorf("Expected web to be retried, got %v", retry)
+	}
+	output := out.String()
+	if !strings.Contains(output, "building with do-builds --filter web --events fd:3") {
+		t.Errorf("Expected the command's output with the events flag added, got:\n%s", output)
+	}
+	if !strings.Contains(output, "slarty do-builds - failed") {
+		t.Errorf("Expected the final dashboard to show the run failed, got:\n%s", output)
+	}
+}
@@ -27,26 +27,44 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/dstockto/slarty/slarty"
+	"github.com/dstockto/slarty/slarty/archive"
 	"github.com/spf13/cobra"
 )
 
-// validateCmd represents the validate command
-var validateCmd = &cobra.Command{
-	Use:   "validate",
-	Short: "Validate artifacts.json and report common problems",
-	Long: `Validates the artifacts.json configuration and reports common problems such as
+// newValidateCmd creates the validate command
+func newValidateCmd(root *rootOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Validate artifacts.json and report common problems",
+		Long: `Validates the artifacts.json configuration and reports common problems such as
 duplicate names, missing directories, and empty deploy locations (which can wipe the
 project root). All problems are reported, and the command exits non-zero if any errors
-are found.`,
-	Run: runValidate,
+are found. The file is first checked against the schema printed by "slarty config schema",
+which catches misspelled keys and values of the wrong type.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runValidate(cmd, args, root)
+		},
+	}
 }
 
-func runValidate(cmd *cobra.Command, args []string) {
-	artifactConfig, err := slarty.ReadArtifactsJson(artifactsJson)
+func runValidate(cmd *cobra.Command, args []string, root *rootOptions) {
+	data, err := os.ReadFile(root.artifactsJson)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: unable to read %s: %v\n", root.artifactsJson, err)
+		os.Exit(1)
+	}
+	// A config that does not match the schema would fail to read, or read wrongly, so
+	// its problems are reported on their own
+	if validateSchema(os.Stdout, data) > 0 {
+		os.Exit(1)
+	}
+
+	artifactConfig, err := root.readConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: unable to read %s: %v\n", artifactsJson, err)
+		fmt.Fprintf(os.Stderr, "ERROR: unable to read %s: %v\n", root.artifactsJson, err)
 		os.Exit(1)
 	}
 
@@ -57,6 +75,24 @@ func runValidate(cmd *cobra.Command, args []string) {
 	}
 }
 
+// validateSchema checks the config data against the schema of artifacts.json, writes
+// any problems and a summary to w, and returns the number of problems found
+func validateSchema(w io.Writer, data []byte) int {
+	problems, err := slarty.ValidateConfigSchema(data)
+	if err != nil {
+		problems = []string{fmt.Sprintf("artifacts.json is not valid JSON: %v", err)}
+	}
+	if len(problems) == 0 {
+		return 0
+	}
+
+	for _, problem := range problems {
+		fmt.Fprintf(w, "ERROR: %s\n", problem)
+	}
+	fmt.Fprintf(w, "Found %d error(s) and 0 warning(s)\n", len(problems))
+	return len(problems)
+}
+
 // validateConfig inspects the configuration, writes any problems and a summary to w,
 // and returns the number of errors and warnings found. It never calls os.Exit so it
 // can be exercised by tests.
@@ -70,6 +106,18 @@ func validateConfig(w io.Writer, config *slarty.ArtifactsConfig) (errCount, warn
 	addWarning := func(format string, a ...interface{}) {
 		warns = append(warns, fmt.Sprintf(format, a...))
 	}
+	// The owner and group may only exist on the servers being deployed to, so a
+	// missing one is a warning
+	checkPermissions := func(label string, perms slarty.Permissions) {
+		if perms.Mode != "" {
+			if _, err := slarty.ParseMode(perms.Mode); err != nil {
+				addError("%s has an %v", label, err)
+			}
+		}
+		if _, _, err := slarty.LookupOwnership(perms.Owner, perms.Group); err != nil {
+			addWarning("%s: %v on this machine", label, err)
+		}
+	}
 
 	// No artifacts and no assets is a warning.
 	if len(config.Artifacts) == 0 && len(config.Assets) == 0 {
@@ -78,6 +126,7 @@ func validateConfig(w io.Writer, config *slarty.ArtifactsConfig) (errCount, warn
 
 	// Validate artifacts.
 	seenArtifactNames := make(map[string]bool)
+	releasesPaths := make(map[string]string)
 	for i, artifact := range config.Artifacts {
 		label := artifact.Name
 		if strings.TrimSpace(label) == "" {
@@ -94,10 +143,55 @@ func validateConfig(w io.Writer, config *slarty.ArtifactsConfig) (errCount, warn
 			seenArtifactNames[lower] = true
 		}
 
-		if strings.TrimSpace(artifact.DeployLocation) == "" {
-			addError("%s has an empty deploy_location (this can wipe the project root)", label)
-		} else if artifact.DeployLocation == "." {
-			addWarning("%s has deploy_location \".\" which resolves to the project root", label)
+		if artifact.Type != "" && !artifact.IsDocker() {
+			addError("%s has unknown type %q (expected %q)", label, artifact.Type, slarty.ArtifactTypeDocker)
+		}
+		if artifact.IsDocker() && strings.TrimSpace(artifact.Image) == "" {
+			addError("%s is a docker artifact with an empty image", label)
+		}
+		if artifact.Container != nil && strings.TrimSpace(artifact.Container.Image) == "" {
+			addError("%s has a container with an empty image", label)
+		}
+
+		// For docker artifacts deploy_location is an optional image reference to tag the pulled image as
+		if !artifact.IsDocker() {
+			if strings.TrimSpace(artifact.DeployLocation) == "" {
+				addError("%s has an empty deploy_location (this can wipe the project root)", label)
+			} else if artifact.DeployLocation == "." {
+				addWarning("%s has deploy_location \".\" which resolves to the project root", label)
+			}
+		}
+
+		checkPermissions(label, artifact.Permissions())
+		// Deploy location placeholders may only be set on the servers being deployed to
+		if !artifact.IsDocker() {
+			if err := artifact.CheckLocations(); err != nil {
+				addWarning("%s: %v on this machine", label, err)
+			}
+		}
+
+		if artifact.DeployStrategy != "" && !artifact.UsesSymlinkStrategy() {
+			addError("%s has unknown deploy_strategy %q (expected %q)", label, artifact.DeployStrategy, slarty.DeployStrategySymlink)
+		}
+		if artifact.UsesSymlinkStrategy() {
+			releasesPath := artifact.ReleasesPath(config.RootDirectory)
+			linkPath := filepath.Join(config.RootDirectory, artifact.DeployLocation)
+			if artifact.IsDocker() {
+				addError("%s is a docker artifact and cannot use the symlink deploy strategy", label)
+			} else if rel, err := filepath.Rel(linkPath, releasesPath); err == nil && (rel == "." || !strings.HasPrefix(rel, "..")) {
+				addError("%s keeps its releases inside its deploy_location", label)
+			}
+			// Pruning one artifact's releases would remove the other's
+			if other, ok := releasesPaths[releasesPath]; ok {
+				addError("%s and %s share the releases directory %s (set releases_directory)", other, label, releasesPath)
+			}
+			releasesPaths[releasesPath] = label
+		}
+
+		for _, service := range artifact.Services {
+			if strings.TrimSpace(service) == "" {
+				addError("%s has an empty service name", label)
+			}
 		}
 
 		if len(artifact.Directories) == 0 {
@@ -115,15 +209,79 @@ func validateConfig(w io.Writer, config *slarty.ArtifactsConfig) (errCount, warn
 			}
 		}
 
-		if strings.TrimSpace(artifact.Command) == "" {
+		if strings.TrimSpace(artifact.Command) == "" && !artifact.IsDocker() {
 			addError("%s has an empty command", label)
 		}
-		if strings.TrimSpace(artifact.OutputDirectory) == "" {
-			addError("%s has an empty output_directory", label)
+		for _, name := range artifact.VariantNames() {
+			if strings.TrimSpace(name) == "" {
+				addError("%s has a variant with an empty name", label)
+			} else if strings.TrimSpace(artifact.Variants[name]) == "" {
+				addError("%s variant %q has an empty command", label, name)
+			}
+		}
+		if !artifact.IsDocker() {
+			emptyOutput := len(artifact.OutputDirectory) == 0
+			for _, dir := range artifact.OutputDirectory {
+				emptyOutput = emptyOutput || strings.TrimSpace(dir) == ""
+			}
+			if emptyOutput {
+				addError("%s has an empty output_directory", label)
+			} else if _, err := artifact.OutputDirectory.ArchivePrefixes(); err != nil {
+				addError("%s: %v", label, err)
+			} else if artifact.CleanOutput {
+				// Cleaning must not wipe the project or the files the artifact is built from
+				for _, dir := range artifact.OutputDirectory {
+					outputPath := filepath.Join(config.RootDirectory, dir)
+					if containsRoot, _ := slarty.ContainsProjectRoot(outputPath, config.RootDirectory); containsRoot {
+						addError("%s has clean_output but its output directory %q resolves to or contains the project root", label, dir)
+						continue
+					}
+					for _, input := range artifact.Directories {
+						if inside, _ := slarty.ContainsProjectRoot(outputPath, filepath.Join(config.RootDirectory, input)); inside {
+							addError("%s has clean_output but its output directory %q contains %q, which it is built from", label, dir, input)
+						}
+					}
+				}
+			}
 		}
 		if strings.TrimSpace(artifact.ArtifactPrefix) == "" {
 			addError("%s has an empty artifact_prefix", label)
 		}
+		// The configuration's own artifact_name is reported once, below
+		if artifact.NameTemplate != config.NameTemplate {
+			if err := slarty.CheckArtifactNameTemplate(artifact.NameTemplate); err != nil {
+				addError("%s: %v", label, err)
+			}
+		}
+		if _, err := archive.Lookup(artifact.Archive); err != nil {
+			addError("%s: %v", label, err)
+		}
+		maxSize, maxErr := slarty.ParseSize(artifact.MaxSize)
+		if maxErr != nil {
+			addError("%s max_size: %v", label, maxErr)
+		}
+		warnSize, warnErr := slarty.ParseSize(artifact.WarnSize)
+		if warnErr != nil {
+			addError("%s warn_size: %v", label, warnErr)
+		}
+		if maxErr == nil && warnErr == nil && maxSize > 0 && warnSize > maxSize {
+			addError("%s has a warn_size larger than its max_size", label)
+		}
+		minSize, minErr := slarty.ParseSize(artifact.MinSize)
+		if minErr != nil {
+			addError("%s min_size: %v", label, minErr)
+		}
+		if maxErr == nil && minErr == nil && maxSize > 0 && minSize > maxSize {
+			addError("%s has a min_size larger than its max_size", label)
+		}
+		labelled := artifact
+		labelled.Name = label
+		if _, err := labelled.OutputFilter(); err != nil {
+			addError("%v", err)
+		}
+		if _, err := labelled.OutputExpectations(); err != nil {
+			addError("%v", err)
+		}
 	}
 
 	// Validate assets.
@@ -148,6 +306,17 @@ func validateConfig(w io.Writer, config *slarty.ArtifactsConfig) (errCount, warn
 			addError("%s has an empty filename", label)
 		}
 
+		if asset.SHA256 != "" {
+			if err := slarty.ValidateSHA256(asset.SHA256); err != nil {
+				addError("%s has an %v", label, err)
+			}
+		}
+
+		checkPermissions(label, asset.Permissions())
+		if err := slarty.CheckLocation(asset.DeployLocation); err != nil {
+			addWarning("%s: %v on this machine", label, err)
+		}
+
 		if strings.TrimSpace(asset.DeployLocation) == "" {
 			addError("%s has an empty deploy_location (this can wipe the project root)", label)
 		} else if asset.DeployLocation == "." {
@@ -172,6 +341,93 @@ func validateConfig(w io.Writer, config *slarty.ArtifactsConfig) (errCount, warn
 	default:
 		addError("unknown repository adapter %q (expected \"local\" or \"s3\")", config.Repository.Adapter)
 	}
+	for _, problem := range config.Repository.Options.CheckSecrets() {
+		addError("%v", problem)
+	}
+	if _, err := slarty.ParseRate(config.Repository.LimitRate); err != nil {
+		addError("repository limit-rate: %v", err)
+	}
+	if ttl := config.Repository.ExistsCacheTTL; ttl != "" {
+		if d, err := time.ParseDuration(ttl); err != nil || d < 0 {
+			addError("repository exists-cache-ttl %q is not a duration such as 10m", ttl)
+		}
+	}
+	if config.Repository.Encryption != nil {
+		if err := config.Repository.Encryption.Check(config.RootDirectory); err != nil {
+			addError("%v", err)
+		}
+	}
+	if err := slarty.ValidateChannel(config.Repository.Channel); err != nil {
+		addError("repository %v", err)
+	}
+	for _, path := range []string{config.Repository.Options.Root, config.Repository.Options.PathPrefix} {
+		if _, err := slarty.ExpandPathTemplate(path, config.Application, config.Repository.Channel, time.Now()); err != nil {
+			addError("repository %v", err)
+		} else if strings.Contains(path, "{yyyy}") || strings.Contains(path, "{mm}") || strings.Contains(path, "{dd}") {
+			addWarning("repository path %q uses date placeholders, so artifacts stored in an earlier period are not found and will be rebuilt", path)
+		}
+	}
+
+	// Validate pipelines.
+	commands := newRootCmd()
+	for _, name := range config.PipelineNames() {
+		steps, err := config.Pipeline(name)
+		if err != nil {
+			addError("%v", err)
+			continue
+		}
+		for i, step := range steps {
+			if found, _, err := commands.Find(step); err != nil || found == commands {
+				addError("pipeline %s step %d runs unknown command %q", name, i+1, step[0])
+			}
+		}
+	}
+
+	// Validate maintenance commands.
+	if config.Maintenance.EnableCmd != "" && config.Maintenance.DisableCmd == "" {
+		addWarning("maintenance has an enable_cmd but no disable_cmd, so deploys leave the application in maintenance mode")
+	}
+
+	// Validate extraction limits.
+	if config.Extraction.MaxArchiveBytes < 0 {
+		addError("extraction max_archive_bytes must not be negative")
+	}
+	if config.Extraction.MaxExtractedBytes < 0 {
+		addError("extraction max_extracted_bytes must not be negative")
+	}
+	if config.Extraction.MaxFiles < 0 {
+		addError("extraction max_files must not be negative")
+	}
+
+	// Validate cleanup settings.
+	if config.Cleanup.KeepBackups < 0 {
+		addError("cleanup keep_backups must not be negative")
+	}
+
+	// Validate deploy approvals.
+	for _, err := range config.Approvals.Check() {
+		addError("%v", err)
+	}
+
+	// Validate the artifact name template.
+	if err := slarty.CheckArtifactNameTemplate(config.NameTemplate); err != nil {
+		addError("%v", err)
+	}
+
+	// Validate the hash length.
+	if err := slarty.ValidateHashLength(config.HashLength); err != nil {
+		addError("%v", err)
+	}
+
+	// Validate the hash algorithm.
+	if err := slarty.ValidateHashAlgorithm(config.HashAlgorithm); err != nil {
+		addError("%v", err)
+	}
+
+	// Validate the dirty tree policy.
+	if err := slarty.ValidateDirtyTreePolicy(config.DirtyTree); err != nil {
+		addError("%v", err)
+	}
 
 	for _, e := range errs {
 		fmt.Fprintf(w, "ERROR: %s\n", e)
@@ -191,7 +447,3 @@ func validateConfig(w io.Writer, config *slarty.ArtifactsConfig) (errCount, warn
 
 	return errCount, warnCount
 }
-
-func init() {
-	rootCmd.AddCommand(validateCmd)
-}
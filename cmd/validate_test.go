@@ -11,16 +11,17 @@ import (
 )
 
 func TestValidateCommand(t *testing.T) {
-	if validateCmd.Use != "validate" {
-		t.Errorf("Expected validate command Use to be 'validate', got '%s'", validateCmd.Use)
+	cmd := newValidateCmd(&rootOptions{})
+	if cmd.Use != "validate" {
+		t.Errorf("Expected validate command Use to be 'validate', got '%s'", cmd.Use)
 	}
-	if validateCmd.Short == "" {
+	if cmd.Short == "" {
 		t.Error("validate command Short description should not be empty")
 	}
-	if validateCmd.Long == "" {
+	if cmd.Long == "" {
 		t.Error("validate command Long description should not be empty")
 	}
-	if validateCmd.Run == nil {
+	if cmd.Run == nil {
 		t.Error("validate command Run function should not be nil")
 	}
 }
@@ -100,7 +101,12 @@ func TestValidateConfigValid(t *testing.T) {
 
 func TestValidateConfigSeededProblems(t *testing.T) {
 	// Two artifacts named "dupe" (case-insensitive), one with an empty
-	// deploy_location and a missing directory; an unknown repository adapter.
+	// deploy_location and a missing directory, the other with an empty variant
+	// command and container image, bad size budgets and bad output globs; two that
+	// would clean away the project root or their own sources; an unknown repository
+	// adapter, a negative extraction limit, a negative backup retention and protected
+	// environments with no way to approve deploys, and artifact name templates
+	// without a hash or with an unknown placeholder, and an unknown archive format.
 	config := writeConfig(t, `{
 		"application": "Test App",
 		"root_directory": "__DIR__",
@@ -108,6 +114,12 @@ func TestValidateConfigSeededProblems(t *testing.T) {
 			"adapter": "ftp",
 			"options": {}
 		},
+		"extraction": {"max_files": -1},
+		"cleanup": {"keep_backups": -1},
+		"approvals": {"protected": ["production"], "timeout": "soon"},
+		"dirty_tree": "abort",
+		"hash_algorithm": "md5",
+		"artifact_name": "{prefix}-{hash}-{build}.{ext}",
 		"artifacts": [
 			{
 				"name": "Dupe",
@@ -115,7 +127,10 @@ func TestValidateConfigSeededProblems(t *testing.T) {
 				"command": "make a",
 				"output_directory": "build/a",
 				"deploy_location": "",
-				"artifact_prefix": "a"
+				"artifact_prefix": "a",
+				"max_size": "lots",
+				"output_exclude": ["[cache"],
+				"expect": ["dist/["]
 			},
 			{
 				"name": "dupe",
@@ -123,7 +138,32 @@ func TestValidateConfigSeededProblems(t *testing.T) {
 				"command": "make b",
 				"output_directory": "build/b",
 				"deploy_location": "deploy/b",
-				"artifact_prefix": "b"
+				"artifact_prefix": "b",
+				"variants": {"debug": ""},
+				"container": {"image": ""},
+				"max_size": "10M",
+				"warn_size": "20M",
+				"min_size": "11M"
+			},
+			{
+				"name": "wipe",
+				"directories": ["src"],
+				"command": "make",
+				"output_directory": ".",
+				"deploy_location": "deploy/wipe",
+				"artifact_prefix": "wipe",
+				"archive": "rar",
+				"clean_output": true
+			},
+			{
+				"name": "generated",
+				"directories": ["gen/src"],
+				"command": "make",
+				"output_directory": "gen",
+				"deploy_location": "deploy/gen",
+				"artifact_prefix": "gen",
+				"artifact_name": "{prefix}-{date}.{ext}",
+				"clean_output": true
 			}
 		]
 	}`)
@@ -138,10 +178,28 @@ func TestValidateConfigSeededProblems(t *testing.T) {
 	_ = warnCount
 
 	checks := map[string]string{
-		"empty deploy_location": "deploy_location",
-		"duplicate name":        "duplicate artifact name",
-		"missing directory":     "does not exist",
-		"unknown adapter":       "unknown repository adapter",
+		"empty deploy_location":  "deploy_location",
+		"duplicate name":         "duplicate artifact name",
+		"missing directory":      "does not exist",
+		"unknown adapter":        "unknown repository adapter",
+		"empty variant command":  "variant \"debug\" has an empty command",
+		"empty container image":  "has a container with an empty image",
+		"negative limit":         "max_files must not be negative",
+		"negative retention":     "keep_backups must not be negative",
+		"unknown dirty policy":   "unknown dirty_tree policy \"abort\"",
+		"unknown hash algorithm": "unknown hash_algorithm \"md5\"",
+		"unparsable max_size":    "max_size: invalid size \"lots\"",
+		"warn over max":          "warn_size larger than its max_size",
+		"min over max":           "min_size larger than its max_size",
+		"bad output glob":        "output_exclude: invalid pattern",
+		"bad expect glob":        "expect: invalid pattern \"dist/[\"",
+		"cleaning the root":      "wipe has clean_output but its output directory \".\" resolves to or contains the project root",
+		"cleaning the sources":   "contains \"gen/src\", which it is built from",
+		"unapprovable deploys":   "neither a webhook_url nor a token",
+		"bad approval timeout":   "invalid approvals timeout \"soon\"",
+		"unknown name template":  "unknown placeholder {build} in artifact_name",
+		"name without a hash":    "generated: artifact_name \"{prefix}-{date}.{ext}\" must contain {hash}",
+		"unknown archive format": "wipe: unknown archive format \"rar\"",
 	}
 	for desc, want := range checks {
 		if !strings.Contains(output, want) {
@@ -244,3 +302,193 @@ func TestValidateConfigS3MissingOptions(t *testing.T) {
 		t.Errorf("Expected errors about region and bucket-name, got:\n%s", output)
 	}
 }
+
+func TestValidateConfigPathTemplate(t *testing.T) {
+	config := writeConfig(t, `{
+		"application": "Test App",
+		"root_directory": "__DIR__",
+		"repository": {
+			"adapter": "s3",
+			"options": { "region": "us-east-1", "bucket-name": "artifacts", "path-prefix": "{app}/{artifact}/{yyyy}/{bucket}" }
+		},
+		"artifacts": [
+			{
+				"name": "alpha",
+				"directories": ["dir1"],
+				"command": "make alpha",
+				"output_directory": "build/alpha",
+				"deploy_location": "deploy/alpha",
+				"artifact_prefix": "alpha"
+			}
+		]
+	}`)
+
+	var buf bytes.Buffer
+	errCount, _ := validateConfig(&buf, config)
+	output := buf.String()
+	if errCount == 0 || !strings.Contains(output, "unknown placeholder {bucket}") {
+		t.Errorf("Expected an error for the unknown placeholder, got:\n%s", output)
+	}
+
+	config.Repository.Options.PathPrefix = "{app}/{artifact}/{yyyy}"
+	buf.Reset()
+	errCount, warnCount := validateConfig(&buf, config)
+	output = buf.String()
+	if errCount != 0 || warnCount == 0 || !strings.Contains(output, "date placeholders") {
+		t.Errorf("Expected only a warning for the date placeholder, got:\n%s", output)
+	}
+}
+
+func TestValidateConfigPipelines(t *testing.T) {
+	config := writeConfig(t, `{
+		"application": "Test App",
+		"root_directory": "__DIR__",
+		"repository": { "adapter": "local", "options": { "root": "/tmp/repo" } },
+		"artifacts": [
+			{
+				"name": "alpha",
+				"directories": ["dir1"],
+				"command": "make alpha",
+				"output_directory": "build/alpha",
+				"deploy_location": "deploy/alpha",
+				"artifact_prefix": "alpha"
+			}
+		],
+		"pipelines": {
+			"release": ["should-build", "do-builds", "do-deploys --filter alpha"],
+			"typo": ["do-build"],
+			"quotes": ["do-builds --filter 'alpha"]
+		}
+	}`)
+
+	var buf bytes.Buffer
+	errCount, _ := validateConfig(&buf, config)
+	output := buf.String()
+	if errCount != 2 {
+		t.Errorf("Expected 2 errors, got %d:\n%s", errCount, output)
+	}
+	if !strings.Contains(output, `pipeline typo step 1 runs unknown command "do-build"`) {
+		t.Errorf("Expected an error for the unknown command, got:\n%s", output)
+	}
+	if !strings.Contains(output, "pipeline quotes step 1: unterminated") {
+		t.Errorf("Expected an error for the unterminated quote, got:\n%s", output)
+	}
+}
+
+func TestValidateConfigAssetSHA256(t *testing.T) {
+	config := writeConfig(t, `{
+		"application": "Test App",
+		"root_directory": "__DIR__",
+		"repository": { "adapter": "local", "options": { "root": "/tmp/repo" } },
+		"artifacts": [],
+		"assets": [
+			{ "name": "good", "filename": "good.tar.gz", "deploy_location": "lib/good", "sha256": "`+strings.Repeat("a", 64)+`" },
+			{ "name": "bad", "filename": "bad.tar.gz", "deploy_location": "lib/bad", "sha256": "abc123" }
+		]
+	}`)
+
+	var buf bytes.Buffer
+	validateConfig(&buf, config)
+	output := buf.String()
+	if !strings.Contains(output, "bad has an invalid sha256") {
+		t.Errorf("Expected an error for the invalid sha256, got:\n%s", output)
+	}
+	if strings.Contains(output, "good has") {
+		t.Errorf("Expected the valid sha256 to be accepted, got:\n%s", output)
+	}
+}
+
+func TestValidatePermissions(t *testing.T) {
+	config := writeConfig(t, `{
+		"application": "Test App",
+		"root_directory": "__DIR__",
+		"repository": { "adapter": "local", "options": { "root": "/tmp/repo" } },
+		"artifacts": [],
+		"assets": [
+			{ "name": "good", "filename": "good.tar.gz", "deploy_location": "lib/good", "owner": "0", "mode": "0640" },
+			{ "name": "badmode", "filename": "bad.tar.gz", "deploy_location": "lib/bad", "mode": "0999" },
+			{ "name": "nobody-here", "filename": "web.tar.gz", "deploy_location": "lib/web", "group": "slarty-no-such-group" }
+		]
+	}`)
+
+	var buf bytes.Buffer
+	errCount, warnCount := validateConfig(&buf, config)
+	output := buf.String()
+	if errCount != 1 || !strings.Contains(output, "ERROR: badmode has an invalid mode") {
+		t.Errorf("Expected an error for the invalid mode, got:\n%s", output)
+	}
+	if warnCount != 1 || !strings.Contains(output, "WARNING: nobody-here: unknown group slarty-no-such-group on this machine") {
+		t.Errorf("Expected a warning for the unknown group, got:\n%s", output)
+	}
+	if strings.Contains(output, "good has") {
+		t.Errorf("Expected the valid permissions to be accepted, got:\n%s", output)
+	}
+}
+
+func TestValidateDeployStrategy(t *testing.T) {
+	config := writeConfig(t, `{
+		"application": "Test App",
+		"root_directory": "__DIR__",
+		"repository": { "adapter": "local", "options": { "root": "/tmp/repo" } },
+		"artifacts": [
+			{ "name": "web", "directories": ["."], "command": "make", "output_directory": "build", "deploy_location": "app/current", "artifact_prefix": "web", "deploy_strategy": "symlink" },
+			{ "name": "admin", "directories": ["."], "command": "make", "output_directory": "build", "deploy_location": "app/admin", "artifact_prefix": "admin", "deploy_strategy": "symlink" },
+			{ "name": "api", "directories": ["."], "command": "make", "output_directory": "build", "deploy_location": "api/current", "artifact_prefix": "api", "deploy_strategy": "symlink", "releases_directory": "api/current/releases" },
+			{ "name": "docs", "directories": ["."], "command": "make", "output_directory": "build", "deploy_location": "docs", "artifact_prefix": "docs", "deploy_strategy": "bluegreen" }
+		]
+	}`)
+
+	var buf bytes.Buffer
+	validateConfig(&buf, config)
+	output := buf.String()
+	for _, expected := range []string{
+		"ERROR: web and admin share the releases directory",
+		"ERROR: api keeps its releases inside its deploy_location",
+		`ERROR: docs has unknown deploy_strategy "bluegreen"`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q, got:\n%s", expected, output)
+		}
+	}
+	if strings.Count(output, "ERROR:") != 3 {
+		t.Errorf("Expected 3 errors, got:\n%s", output)
+	}
+}
+
+func TestValidateMaintenance(t *testing.T) {
+	config := writeConfig(t, `{
+		"application": "Test App",
+		"root_directory": "__DIR__",
+		"repository": { "adapter": "local", "options": { "root": "/tmp/repo" } },
+		"assets": [{ "name": "lib", "filename": "lib.tar.gz", "deploy_location": "lib" }],
+		"maintenance": { "enable_cmd": "php artisan down" }
+	}`)
+
+	var buf bytes.Buffer
+	validateConfig(&buf, config)
+	if !strings.Contains(buf.String(), "WARNING: maintenance has an enable_cmd but no disable_cmd") {
+		t.Errorf("Expected a warning for the missing disable_cmd, got:\n%s", buf.String())
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	var out bytes.Buffer
+	valid := `{"$schema": "./artifacts.schema.json", "application": "Test", "repository": {"adapter": "local", "options": {"root": "/tmp"}}}`
+	if count := validateSchema(&out, []byte(valid)); count != 0 || out.Len() != 0 {
+		t.Errorf("Expected no problems, got %d: %s", count, out.String())
+	}
+
+	out.Reset()
+	invalid := `{"application": "Test", "repository": {"adapter": "local"}, "artifacts": [{"name": "api", "directories": "api"}]}`
+	if count := validateSchema(&out, []byte(invalid)); count != 1 {
+		t.Errorf("Expected 1 problem, got %d: %s", count, out.String())
+	}
+	if !strings.Contains(out.String(), "ERROR: artifacts[0].directories must be array or null, not string") {
+		t.Errorf("Expected the problem to name the key, got %s", out.String())
+	}
+
+	out.Reset()
+	if count := validateSchema(&out, []byte(`{"application": `)); count != 1 || !strings.Contains(out.String(), "not valid JSON") {
+		t.Errorf("Expected invalid JSON to be reported, got %d: %s", count, out.String())
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/spf13/cobra"
+)
+
+// Build information, set at build time with
+// -ldflags "-X github.com/dstockto/slarty/cmd.version=1.2.3 -X ...cmd.commit=... -X ...cmd.buildDate=..."
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildInfo describes the running binary
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// versionOptions are the flags of the version command
+type versionOptions struct {
+	json bool
+}
+
+// newVersionCmd creates the version command
+func newVersionCmd() *cobra.Command {
+	opts := &versionOptions{}
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Show the slarty version and build information",
+		Long: `Shows the version of slarty along with the commit and date it was built from,
+and the Go version and platform it was built for. Include this when reporting a problem.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runVersion(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().BoolVar(&opts.json, "json", false, "output results as JSON")
+
+	return cmd
+}
+
+func runVersion(cmd *cobra.Command, args []string, opts *versionOptions) {
+	info := currentBuildInfo()
+
+	if opts.json {
+		out, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	fmt.Printf("slarty %s\n", info.Version)
+	if info.Commit != "" {
+		fmt.Printf("commit: %s\n", info.Commit)
+	}
+	if info.BuildDate != "" {
+		fmt.Printf("built: %s\n", info.BuildDate)
+	}
+	fmt.Printf("go: %s %s\n", info.GoVersion, info.Platform)
+}
+
+// currentBuildInfo returns the build information set with ldflags, falling back to
+// what the Go toolchain recorded for builds made with go install or go build
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if recorded, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "dev" && recorded.Main.Version != "" && recorded.Main.Version != "(devel)" {
+			info.Version = recorded.Main.Version
+		}
+		for _, setting := range recorded.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// warnIfConfigNeedsNewerVersion warns on w when the artifacts.json at path declares a
+// min_slarty_version newer than this binary. Configs that can't be read are left for
+// the command itself to report.
+func warnIfConfigNeedsNewerVersion(w io.Writer, path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var config struct {
+		MinSlartyVersion string `json:"min_slarty_version"`
+	}
+	if json.Unmarshal(data, &config) != nil || config.MinSlartyVersion == "" {
+		return
+	}
+
+	running := currentBuildInfo().Version
+	if result, ok := slarty.CompareVersions(running, config.MinSlartyVersion); ok && result < 0 {
+		fmt.Fprintf(w, "WARNING: %s requires slarty %s or newer, but this is slarty %s. Please upgrade.\n", path, config.MinSlartyVersion, running)
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestRunVersion(t *testing.T) {
+	oldVersion, oldCommit, oldDate := version, commit, buildDate
+	defer func() { version, commit, buildDate = oldVersion, oldCommit, oldDate }()
+	version, commit, buildDate = "1.4.0", "abc1234", "2026-10-01T12:00:00Z"
+
+	output := captureStdout(t, func() {
+		runVersion(&cobra.Command{Use: "test"}, []string{}, &versionOptions{})
+	})
+
+	for _, want := range []string{"slarty 1.4.0\n", "commit: abc1234\n", "built: 2026-10-01T12:00:00Z\n", "go: go"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestWarnIfConfigNeedsNewerVersion(t *testing.T) {
+	oldVersion := version
+	defer func() { version = oldVersion }()
+	version = "1.4.0"
+
+	configPath := filepath.Join(t.TempDir(), "artifacts.json")
+	warning := func(config string) string {
+		t.Helper()
+		if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		var buf bytes.Buffer
+		warnIfConfigNeedsNewerVersion(&buf, configPath)
+		return buf.String()
+	}
+
+	if got := warning(`{"min_slarty_version": "1.5.0"}`); !strings.Contains(got, "requires slarty 1.5.0 or newer, but this is slarty 1.4.0") {
+		t.Errorf("Expected a warning for a newer minimum version, got %q", got)
+	}
+	if got := warning(`{"min_slarty_version": "v1.4.0"}`); got != "" {
+		t.Errorf("Expected no warning when the version is new enough, got %q", got)
+	}
+	if got := warning(`{"application": "no minimum"}`); got != "" {
+		t.Errorf("Expected no warning without a minimum version, got %q", got)
+	}
+
+	version = "dev"
+	if got := warning(`{"min_slarty_version": "9.0.0"}`); got != "" {
+		t.Errorf("Expected no warning for a dev build, got %q", got)
+	}
+
+	var buf bytes.Buffer
+	warnIfConfigNeedsNewerVersion(&buf, filepath.Join(t.TempDir(), "missing.json"))
+	if buf.Len() != 0 {
+		t.Errorf("Expected no warning for a missing config, got %q", buf.String())
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright © 2025 David Stockton <dave@davidstockton.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// watchOptions are the flags of the watch command
+type watchOptions struct {
+	root      *rootOptions
+	selection selectionOptions
+	// build runs do-builds for the artifacts whose hash changed
+	build    bool
+	debounce time.Duration
+	variant  string
+}
+
+// newWatchCmd creates the watch command
+func newWatchCmd(root *rootOptions) *cobra.Command {
+	opts := &watchOptions{root: root}
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Watch artifact directories and report artifacts whose hash changes",
+		Long: `Watches the directories of each artifact and recomputes the artifact hashes when
+files change. Changes are debounced so a burst of writes results in a single check.
+Hashes are calculated from the git index, so an edit changes an artifact's hash
+once it is staged. Use --build to run do-builds for the artifacts that changed.
+Stop watching with Ctrl-C.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			runWatch(cmd, args, opts)
+		},
+	}
+
+	// Here you will define your flags and configuration settings.
+	cmd.Flags().BoolVar(&opts.build, "build", false, "run do-builds for artifacts whose hash changed")
+	cmd.Flags().DurationVar(&opts.debounce, "debounce", 500*time.Millisecond, "how long to wait for changes to settle before rehashing")
+	bindSelectionFlags(cmd, root, &opts.selection, "application1,application2", "application3,application4", root.completeArtifactNames)
+	cmd.Flags().StringVar(&opts.variant, "variant", "", "build variant to use for artifacts that define variants")
+
+	return cmd
+}
+
+func runWatch(cmd *cobra.Command, args []string, opts *watchOptions) {
+	// Read the artifacts configuration
+	artifactConfig, err := opts.root.readConfig()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := artifactConfig.ApplyVariant(opts.variant); err != nil {
+		log.Fatalln(err)
+	}
+
+	artifacts := artifactConfig.SelectArtifacts(opts.selection.selection())
+	if len(artifacts) == 0 {
+		fmt.Println("No artifacts found")
+		return
+	}
+
+	var repoAdapter slarty.RepositoryAdapter
+	if opts.build {
+		repoAdapter, err = opts.root.openRepository(artifactConfig)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		<-signals
+		close(done)
+	}()
+
+	builds := &doBuildsOptions{root: opts.root}
+	err = watchArtifacts(artifacts, artifactConfig, opts.debounce, done, func(changed []slarty.ArtifactConfig) {
+		if opts.build {
+			executeBuilds(builds, changed, artifactConfig, repoAdapter, nil)
+		}
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// watchArtifacts watches the directories of the given artifacts until done is closed.
+// After each burst of file changes settles for the debounce period the artifact
+// hashes are recomputed, and onChange is called with the artifacts whose hash changed.
+func watchArtifacts(artifacts []slarty.ArtifactConfig, artifactConfig *slarty.ArtifactsConfig, debounce time.Duration, done <-chan struct{}, onChange func([]slarty.ArtifactConfig)) error {
+	rootDir := artifactConfig.RootDirectory
+	if rootDir == "__DIR__" {
+		workingDir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		rootDir = workingDir
+	}
+
+	hashes := make(map[string]string)
+	for _, artifact := range artifacts {
+		hash, err := artifactConfig.HashDirectories(artifact.Directories)
+		if err != nil {
+			return err
+		}
+		hashes[artifact.Name] = hash
+		fmt.Printf("Watching %s (%s)\n", artifact.Name, artifactConfig.DisplayHash(hash))
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	for _, artifact := range artifacts {
+		for _, dir := range artifact.Directories {
+			if err := addWatchPath(watcher, filepath.Join(rootDir, dir)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Hashes come from the git index, so staging a change is what changes them
+	gitDir := filepath.Join(rootDir, ".git")
+	if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
+		if err := watcher.Add(gitDir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", gitDir, err)
+		}
+	}
+
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case <-done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Dir(event.Name) == gitDir && filepath.Base(event.Name) != "index" {
+				continue
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			if event.Op.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addWatchPath(watcher, event.Name); err != nil {
+						fmt.Fprintf(os.Stderr, "WARNING: %v\n", err)
+					}
+				}
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(debounce)
+			fire = timer.C
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "WARNING: watch error: %v\n", err)
+
+		case <-fire:
+			fire = nil
+
+			var changed []slarty.ArtifactConfig
+			for _, artifact := range artifacts {
+				hash, err := artifactConfig.HashDirectories(artifact.Directories)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "WARNING: failed to hash %s: %v\n", artifact.Name, err)
+					continue
+				}
+				if hash == hashes[artifact.Name] {
+					continue
+				}
+				fmt.Printf("%s changed: %s -> %s\n", artifact.Name, artifactConfig.DisplayHash(hashes[artifact.Name]), artifactConfig.DisplayHash(hash))
+				hashes[artifact.Name] = hash
+				changed = append(changed, artifact)
+			}
+
+			if len(changed) > 0 {
+				onChange(changed)
+			}
+		}
+	}
+}
+
+// addWatchPath watches path and, if it is a directory, every directory below it.
+// fsnotify does not watch recursively, and a file is watched through its directory.
+func addWatchPath(watcher *fsnotify.Watcher, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+
+	if !info.IsDir() {
+		return watcher.Add(filepath.Dir(path))
+	}
+
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" || d.Name() == slarty.WorkDirName {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(p); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", p, err)
+		}
+		return nil
+	})
+}
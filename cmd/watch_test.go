@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dstockto/slarty/slarty"
+)
+
+func TestWatchCommand(t *testing.T) {
+	// Test that the watch command is properly initialized
+	cmd := newWatchCmd(&rootOptions{})
+	if cmd.Use != "watch" {
+		t.Errorf("Expected Use to be 'watch', got '%s'", cmd.Use)
+	}
+
+	if cmd.Short == "" {
+		t.Error("Expected Short description to be set")
+	}
+
+	// Check flags
+	flags := cmd.Flags()
+	for _, name := range []string{"build", "debounce", "filter", "exclude", "tag", "exclude-tag"} {
+		if flags.Lookup(name) == nil {
+			t.Errorf("watch command should have '%s' flag", name)
+		}
+	}
+}
+
+func TestWatchArtifactsReportsChangedHash(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for _, dir := range []string{"api", "web"} {
+		if err := os.MkdirAll(filepath.Join(tempDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(tempDir, dir, "main.txt"), []byte(dir), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	git("init")
+	git("add", ".")
+
+	config := &slarty.ArtifactsConfig{
+		RootDirectory: tempDir,
+		Artifacts: []slarty.ArtifactConfig{
+			{Name: "api", Directories: []string{"api"}},
+			{Name: "web", Directories: []string{"web"}},
+		},
+	}
+
+	changes := make(chan []slarty.ArtifactConfig, 1)
+	done := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		result <- watchArtifacts(config.Artifacts, config, 50*time.Millisecond, done, func(changed []slarty.ArtifactConfig) {
+			changes <- changed
+		})
+	}()
+
+	// Give the watcher time to start before changing anything
+	time.Sleep(200 * time.Millisecond)
+
+	if err := os.WriteFile(filepath.Join(tempDir, "api", "main.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	git("add", "api")
+
+	select {
+	case changed := <-changes:
+		if len(changed) != 1 || changed[0].Name != "api" {
+			t.Errorf("Expected only api to change, got %+v", changed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a change to be reported")
+	}
+
+	close(done)
+	if err := <-result; err != nil {
+		t.Errorf("watchArtifacts returned an error: %v", err)
+	}
+}
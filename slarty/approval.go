@@ -0,0 +1,212 @@
+package slarty
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrApprovalDenied is returned when a deploy to a protected environment is not
+// approved
+var ErrApprovalDenied = errors.New("deploy was not approved")
+
+// defaultApprovalTimeout is how long the approval webhook may take to answer when no
+// timeout is configured. The webhook may hold the request open until someone approves
+// the deploy, so it is generous.
+const defaultApprovalTimeout = 15 * time.Minute
+
+// ApprovalConfig holds the "approvals" section of artifacts.json: the environments
+// whose deploys need someone else to approve them, and how they are approved
+type ApprovalConfig struct {
+	// Protected lists the environments, or glob patterns matching them, that need
+	// approval before do-deploys runs against them
+	Protected []string `json:"protected,omitempty"`
+	// WebhookURL is asked to approve each deploy to a protected environment
+	WebhookURL string `json:"webhook_url,omitempty"`
+	// Token is a confirmation token held by a second person, who gives it to approve
+	// the deploy. It is usually a secret_ref.
+	Token string `json:"token,omitempty"`
+	// Timeout is how long the webhook has to answer, such as 30m
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// IsProtected reports whether deploys to environment need approval. An empty
+// environment is never protected.
+func (a ApprovalConfig) IsProtected(environment string) (bool, error) {
+	if environment == "" {
+		return false, nil
+	}
+	for _, pattern := range a.Protected {
+		matched, err := path.Match(pattern, environment)
+		if err != nil {
+			return false, fmt.Errorf("invalid protected environment pattern %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// WaitTimeout returns how long the approval webhook has to answer
+func (a ApprovalConfig) WaitTimeout() (time.Duration, error) {
+	if a.Timeout == "" {
+		return defaultApprovalTimeout, nil
+	}
+	timeout, err := time.ParseDuration(a.Timeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid approvals timeout %q, expected a duration such as 30m", a.Timeout)
+	}
+	return timeout, nil
+}
+
+// Check reports problems with the approvals configuration without contacting the
+// webhook or fetching the token
+func (a ApprovalConfig) Check() []error {
+	var errs []error
+	for _, pattern := range a.Protected {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid protected environment pattern %q: %w", pattern, err))
+		}
+	}
+	if len(a.Protected) > 0 && a.WebhookURL == "" && a.Token == "" {
+		errs = append(errs, errors.New("approvals lists protected environments but has neither a webhook_url nor a token to approve deploys with"))
+	}
+	if a.Token != "" && IsSecretRef(a.Token) {
+		if _, err := ParseSecretRef(a.Token); err != nil {
+			errs = append(errs, fmt.Errorf("approvals token: %w", err))
+		}
+	}
+	if _, err := a.WaitTimeout(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// ResolveToken returns the confirmation token, fetching it when it is a secret_ref.
+// region and profile are those of the repository, used to reach AWS Secrets Manager.
+func (a ApprovalConfig) ResolveToken(ctx context.Context, region, profile string, offline bool) (string, error) {
+	if !IsSecretRef(a.Token) {
+		return a.Token, nil
+	}
+	ref, err := ParseSecretRef(a.Token)
+	if err != nil {
+		return "", fmt.Errorf("approvals token: %w", err)
+	}
+	token, err := ref.Resolve(ctx, region, profile, offline)
+	if err != nil {
+		return "", fmt.Errorf("approvals token: %w", err)
+	}
+	if token == "" {
+		return "", errors.New("approvals token is empty")
+	}
+	return token, nil
+}
+
+// CheckApprovalToken returns an error wrapping ErrApprovalDenied unless given is the
+// expected confirmation token
+func CheckApprovalToken(expected, given string) error {
+	given = strings.TrimSpace(given)
+	if given == "" {
+		return fmt.Errorf("%w: no approval token was given", ErrApprovalDenied)
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(given)) != 1 {
+		return fmt.Errorf("%w: the approval token is wrong", ErrApprovalDenied)
+	}
+	return nil
+}
+
+// ApprovalRequest describes a deploy waiting for approval. It is posted to the
+// approval webhook as JSON.
+type ApprovalRequest struct {
+	Application string             `json:"application"`
+	Environment string             `json:"environment"`
+	User        string             `json:"user"`
+	Host        string             `json:"host,omitempty"`
+	RequestedAt time.Time          `json:"requested_at"`
+	Artifacts   []ApprovalArtifact `json:"artifacts"`
+}
+
+// ApprovalArtifact is one artifact in a deploy waiting for approval
+type ApprovalArtifact struct {
+	Artifact     string `json:"artifact"`
+	ArtifactName string `json:"artifact_name"`
+	Hash         string `json:"hash,omitempty"`
+}
+
+// approvalResponse is what the approval webhook may answer with. A 2xx response
+// without a body, or without "approved", approves the deploy.
+type approvalResponse struct {
+	Approved *bool  `json:"approved"`
+	Approver string `json:"approver"`
+	Reason   string `json:"reason"`
+}
+
+// RequestApproval asks the approval webhook to approve a deploy and returns who
+// approved it, when the webhook says. The webhook approves with a 2xx response and
+// denies with a 403 or {"approved": false}, optionally giving a reason. It may hold the
+// request open until someone has decided, up to the configured timeout. Offline, it
+// fails with ErrOffline.
+func RequestApproval(a ApprovalConfig, request ApprovalRequest, offline bool) (string, error) {
+	if err := offlineError(offline, "the approval webhook"); err != nil {
+		return "", err
+	}
+	timeout, err := a.WaitTimeout()
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode approval request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create approval request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request approval: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Only the start of the body is needed, and a bad webhook cannot send much more
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var response approvalResponse
+	parsed := json.Unmarshal(data, &response) == nil
+
+	reason := response.Reason
+	if !parsed {
+		reason = strings.TrimSpace(string(data))
+	}
+	denied := func() error {
+		if reason == "" {
+			return ErrApprovalDenied
+		}
+		return fmt.Errorf("%w: %s", ErrApprovalDenied, reason)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return "", denied()
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return "", fmt.Errorf("approval webhook returned %s", resp.Status)
+	case parsed && response.Approved != nil && !*response.Approved:
+		return "", denied()
+	}
+	return response.Approver, nil
+}
@@ -0,0 +1,130 @@
+package slarty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestApprovalConfigIsProtected(t *testing.T) {
+	approvals := ApprovalConfig{Protected: []string{"production", "prod-*"}}
+	for environment, expected := range map[string]bool{
+		"production": true,
+		"prod-eu":    true,
+		"staging":    false,
+		"":           false,
+	} {
+		protected, err := approvals.IsProtected(environment)
+		if err != nil || protected != expected {
+			t.Errorf("Expected %q to be protected to be %v, got %v (%v)", environment, expected, protected, err)
+		}
+	}
+
+	if _, err := (ApprovalConfig{Protected: []string{"[prod"}}).IsProtected("prod"); err == nil {
+		t.Errorf("Expected a bad pattern to be an error")
+	}
+}
+
+func TestApprovalConfigCheck(t *testing.T) {
+	if errs := (ApprovalConfig{}).Check(); len(errs) != 0 {
+		t.Errorf("Expected no approvals to be fine, got %v", errs)
+	}
+	if errs := (ApprovalConfig{Protected: []string{"production"}, Token: "secret_ref:env:DEPLOY_TOKEN", Timeout: "30m"}).Check(); len(errs) != 0 {
+		t.Errorf("Expected a token to be enough, got %v", errs)
+	}
+
+	errs := (ApprovalConfig{Protected: []string{"[prod"}, Token: "secret_ref:env", Timeout: "-1m"}).Check()
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	for _, want := range []string{"invalid protected environment pattern", "approvals token: invalid secret_ref", "invalid approvals timeout"} {
+		if !strings.Contains(strings.Join(messages, "\n"), want) {
+			t.Errorf("Expected a problem containing %q, got %v", want, messages)
+		}
+	}
+
+	if errs := (ApprovalConfig{Protected: []string{"production"}}).Check(); len(errs) != 1 {
+		t.Errorf("Expected protected environments without a way to approve to be an error, got %v", errs)
+	}
+}
+
+func TestApprovalToken(t *testing.T) {
+	t.Setenv("SLARTY_TEST_APPROVAL_TOKEN", "correct horse")
+	token, err := ApprovalConfig{Token: "secret_ref:env:SLARTY_TEST_APPROVAL_TOKEN"}.ResolveToken(context.Background(), "", "", false)
+	if err != nil || token != "correct horse" {
+		t.Fatalf("Expected the token from the environment, got %q (%v)", token, err)
+	}
+	if token, _ := (ApprovalConfig{Token: "plain"}).ResolveToken(context.Background(), "", "", false); token != "plain" {
+		t.Errorf("Expected a plain token as it is, got %q", token)
+	}
+
+	if err := CheckApprovalToken("plain", " plain\n"); err != nil {
+		t.Errorf("Expected the right token to be accepted, got %v", err)
+	}
+	for _, given := range []string{"", "wrong", "plai"} {
+		if err := CheckApprovalToken("plain", given); !errors.Is(err, ErrApprovalDenied) {
+			t.Errorf("Expected %q to be denied, got %v", given, err)
+		}
+	}
+}
+
+func TestRequestApproval(t *testing.T) {
+	var received ApprovalRequest
+	status, body := http.StatusOK, ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode the approval request: %v", err)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	approvals := ApprovalConfig{WebhookURL: server.URL}
+	request := ApprovalRequest{
+		Application: "shop",
+		Environment: "production",
+		User:        "dana",
+		Artifacts:   []ApprovalArtifact{{Artifact: "api", ArtifactName: "api-abc.tar.gz", Hash: "abc"}},
+	}
+
+	// An empty 2xx response approves without saying who
+	approver, err := RequestApproval(approvals, request, false)
+	if err != nil || approver != "" {
+		t.Errorf("Expected the deploy to be approved, got %q (%v)", approver, err)
+	}
+	if received.Environment != "production" || len(received.Artifacts) != 1 || received.Artifacts[0].Hash != "abc" {
+		t.Errorf("Expected the webhook to be told about the deploy, got %+v", received)
+	}
+
+	body = `{"approved": true, "approver": "lee"}`
+	if approver, err := RequestApproval(approvals, request, false); err != nil || approver != "lee" {
+		t.Errorf("Expected lee to approve, got %q (%v)", approver, err)
+	}
+
+	for _, test := range []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{http.StatusOK, `{"approved": false, "reason": "change freeze"}`, "change freeze"},
+		{http.StatusForbidden, "outside the deploy window", "outside the deploy window"},
+	} {
+		status, body = test.status, test.body
+		_, err := RequestApproval(approvals, request, false)
+		if !errors.Is(err, ErrApprovalDenied) || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Expected the deploy to be denied with %q, got %v", test.want, err)
+		}
+	}
+
+	// A broken webhook does not approve, but it has not denied the deploy either
+	status, body = http.StatusInternalServerError, ""
+	if _, err := RequestApproval(approvals, request, false); err == nil || errors.Is(err, ErrApprovalDenied) {
+		t.Errorf("Expected a webhook error, got %v", err)
+	}
+}
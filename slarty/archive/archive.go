@@ -0,0 +1,441 @@
+// Package archive writes, extracts and lists the archives artifacts are stored as.
+// Each format is an Archiver, and archives are read and written as streams, so an
+// archive never has to be on disk whole unless its format needs it to be.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrUnknownFormat is returned for an archive format slarty has no Archiver for
+var ErrUnknownFormat = errors.New("unknown archive format")
+
+// DefaultMaxFileBytes caps the number of bytes extracted for any single archive entry
+// unless ReadOptions sets another cap. It guards against decompression bombs that
+// could otherwise fill the disk, and is generous (5 GiB) so legitimate large
+// artifacts are unaffected.
+const DefaultMaxFileBytes = 5 << 30 // 5 GiB
+
+// Source is a directory to archive and the folder its contents go under in the
+// archive, which is empty for the top of the archive
+type Source struct {
+	Dir    string
+	Prefix string
+}
+
+// Filter picks the files that go in an archive by their slash separated path
+// relative to their source directory
+type Filter interface {
+	// Excludes reports whether the file or directory, and everything in it, is left out
+	Excludes(relPath string) bool
+	// Includes reports whether the file, or the directory itself, is archived
+	Includes(relPath string) bool
+}
+
+// Limits bounds the archives that are extracted
+type Limits interface {
+	// CheckArchiveSize returns an error if an archive of the given size is too large
+	CheckArchiveSize(size int64) error
+	// CheckExtracted returns an error if extracting the given number of entries and
+	// bytes is too much
+	CheckExtracted(files int, size int64) error
+	// ArchiveSizeLimit returns the largest archive allowed, or 0 for no limit
+	ArchiveSizeLimit() int64
+}
+
+// WriteOptions are how an archive is written
+type WriteOptions struct {
+	// Filter picks the files archived. Nil archives everything.
+	Filter Filter
+	// Reproducible strips timestamps and ownership from the entries, so the same files
+	// always archive to the same bytes
+	Reproducible bool
+	// SkipDir is the name of a directory never archived below the top of a source,
+	// such as slarty's own working directory
+	SkipDir string
+}
+
+// ReadOptions are how an archive is extracted or listed
+type ReadOptions struct {
+	// Limits bounds what is extracted. Nil means no limits.
+	Limits Limits
+	// MaxFileBytes caps the size of any single extracted entry, DefaultMaxFileBytes
+	// when it is 0
+	MaxFileBytes int64
+	// TempFile creates the temporary file a format that cannot be read as a stream,
+	// such as zip, is downloaded to first, with a pattern as in os.CreateTemp. The
+	// file is created in the system's temporary directory when it is nil.
+	TempFile func(pattern string) (*os.File, error)
+}
+
+// Entry describes a single entry within an archive
+type Entry struct {
+	Name     string    `json:"name"`
+	Type     string    `json:"type"`
+	Mode     string    `json:"mode"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Archiver writes, extracts and lists archives in one format
+type Archiver interface {
+	// Name is the name of the format, as "archive" in artifacts.json gives it
+	Name() string
+	// Extension is what the names of archives in the format end with, without the
+	// leading "."
+	Extension() string
+	// Write archives the files of the sources to w
+	Write(w io.Writer, sources []Source, opts WriteOptions) error
+	// Extract extracts the archive read from r into destDir, refusing entries that
+	// would end up outside it
+	Extract(r io.Reader, destDir string, opts ReadOptions) error
+	// List reads the entries of the archive read from r without extracting them
+	List(r io.Reader, opts ReadOptions) ([]Entry, error)
+}
+
+// The archive formats. TarGz is the default, Zip is for tools that expect zip files
+// and Tar leaves the archive uncompressed, for output that is already compressed.
+var (
+	TarGz Archiver = tarArchiver{compressed: true}
+	Zip   Archiver = zipArchiver{}
+	Tar   Archiver = tarArchiver{}
+)
+
+// archivers are the formats in the order they are listed
+var archivers = []Archiver{TarGz, Zip, Tar}
+
+// Formats returns the names of the archive formats
+func Formats() []string {
+	names := make([]string, len(archivers))
+	for i, archiver := range archivers {
+		names[i] = archiver.Name()
+	}
+	return names
+}
+
+// Lookup returns the Archiver for the named format. An empty name is tar.gz.
+func Lookup(name string) (Archiver, error) {
+	if name == "" {
+		return TarGz, nil
+	}
+	for _, archiver := range archivers {
+		if archiver.Name() == name {
+			return archiver, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q (expected one of %s)", ErrUnknownFormat, name, strings.Join(Formats(), ", "))
+}
+
+// detectLength is how many bytes Detect needs to see, enough for the magic at the
+// end of a tar header's name
+const detectLength = 262
+
+var (
+	// zipMagic is the signature at the start of a zip file's first entry
+	zipMagic = []byte("PK\x03\x04")
+	// zipEmptyMagic is the signature at the start of a zip file with no entries
+	zipEmptyMagic = []byte("PK\x05\x06")
+	// gzipMagic is the signature at the start of a gzip stream
+	gzipMagic = []byte{0x1f, 0x8b}
+	// tarMagic is the signature 257 bytes into a POSIX tar file
+	tarMagic = []byte("ustar")
+)
+
+// Detect tells which format an archive is in from its first bytes, rather than its
+// name, so any archive can be extracted whatever the artifact's format is now.
+// Anything not recognised is taken to be tar.gz, so it fails as a broken one.
+func Detect(magic []byte) Archiver {
+	switch {
+	case bytes.HasPrefix(magic, zipMagic) || bytes.HasPrefix(magic, zipEmptyMagic):
+		return Zip
+	case bytes.HasPrefix(magic, gzipMagic):
+		return TarGz
+	case len(magic) >= 257+len(tarMagic) && bytes.Equal(magic[257:257+len(tarMagic)], tarMagic):
+		return Tar
+	}
+	return TarGz
+}
+
+// detectReader wraps r so its first bytes can be looked at, and returns the format
+// they are in
+func detectReader(r io.Reader) (*bufio.Reader, Archiver) {
+	reader := bufio.NewReaderSize(r, 4096)
+	magic, _ := reader.Peek(detectLength)
+	return reader, Detect(magic)
+}
+
+// Extract extracts an archive in any of the formats, telling which from its first
+// bytes, and reads whatever follows the end of the archive, so a download feeding r
+// completes
+func Extract(r io.Reader, destDir string, opts ReadOptions) error {
+	reader, archiver := detectReader(r)
+	if err := archiver.Extract(reader, destDir, opts); err != nil {
+		return err
+	}
+	_, err := io.Copy(io.Discard, reader)
+	return err
+}
+
+// ExtractFile extracts the archive file at path to destDir, in whichever format it is
+func ExtractFile(path, destDir string, opts ReadOptions) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	// A zip file is already on disk, so it is read where it is
+	reader, archiver := detectReader(file)
+	if archiver == Zip {
+		zipReader, err := openZipFile(path, opts)
+		if err != nil {
+			return err
+		}
+		defer zipReader.Close()
+		return extractZip(zipReader, destDir, opts)
+	}
+	return archiver.Extract(reader, destDir, opts)
+}
+
+// List lists the entries of an archive in any of the formats
+func List(r io.Reader, opts ReadOptions) ([]Entry, error) {
+	reader, archiver := detectReader(r)
+	return archiver.List(reader, opts)
+}
+
+// ListFile lists the entries of the archive file at path, in whichever format it is
+func ListFile(path string, opts ReadOptions) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	reader, archiver := detectReader(file)
+	if archiver == Zip {
+		zipReader, err := openZipFile(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		defer zipReader.Close()
+		return listZip(zipReader), nil
+	}
+	return archiver.List(reader, opts)
+}
+
+// CreateFile archives the sources to a new file at path with archiver
+func CreateFile(archiver Archiver, path string, sources []Source, opts WriteOptions) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s file: %w", archiver.Name(), err)
+	}
+	defer file.Close()
+
+	if err := archiver.Write(file, sources, opts); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// noLimits are the Limits of ReadOptions without any
+type noLimits struct{}
+
+func (noLimits) CheckArchiveSize(int64) error    { return nil }
+func (noLimits) CheckExtracted(int, int64) error { return nil }
+func (noLimits) ArchiveSizeLimit() int64         { return 0 }
+
+// createTemp creates a temporary file with the options' TempFile
+func (o ReadOptions) createTemp(pattern string) (*os.File, error) {
+	if o.TempFile == nil {
+		return os.CreateTemp("", pattern)
+	}
+	return o.TempFile(pattern)
+}
+
+// limits returns the options' limits, which are never nil
+func (o ReadOptions) limits() Limits {
+	if o.Limits == nil {
+		return noLimits{}
+	}
+	return o.Limits
+}
+
+// maxFileBytes returns the cap on the size of a single extracted entry
+func (o ReadOptions) maxFileBytes() int64 {
+	if o.MaxFileBytes > 0 {
+		return o.MaxFileBytes
+	}
+	return DefaultMaxFileBytes
+}
+
+// limitReader wraps r so it fails once more than the archive size limit has been read
+func (o ReadOptions) limitReader(r io.Reader) io.Reader {
+	limits := o.limits()
+	if limits.ArchiveSizeLimit() <= 0 {
+		return r
+	}
+	return &archiveLimitReader{r: r, limits: limits}
+}
+
+// archiveLimitReader fails once more than the archive size limit has been read
+type archiveLimitReader struct {
+	r      io.Reader
+	limits Limits
+	n      int64
+}
+
+func (a *archiveLimitReader) Read(p []byte) (int, error) {
+	if err := a.limits.CheckArchiveSize(a.n); err != nil {
+		return 0, err
+	}
+	// Never hand over more than one byte past the limit, so a buffering reader
+	// cannot finish the archive without asking again
+	if max := a.limits.ArchiveSizeLimit() + 1 - a.n; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := a.r.Read(p)
+	a.n += int64(n)
+	return n, err
+}
+
+// destination returns where an entry named name is extracted to in destDir, guarding
+// against path traversal (Zip Slip): a malicious entry such as "../../etc/cron.d/x"
+// must not be allowed to write outside destDir
+func destination(destDir, name string) (string, error) {
+	destPath := filepath.Join(destDir, name)
+	rel, err := filepath.Rel(destDir, destPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal path in archive: %s", name)
+	}
+	return destPath, nil
+}
+
+// extractFile writes the content of a regular entry to destPath with the permission
+// bits of mode, writing no more than maxBytes
+func extractFile(destPath, name string, mode os.FileMode, r io.Reader, maxBytes int64) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for file: %w", err)
+	}
+
+	// Only keep the permission bits, so a malicious archive cannot set setuid, setgid,
+	// sticky or other special bits on extracted files
+	destFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer destFile.Close()
+
+	// Copy one byte over the cap to tell an entry that exceeds it
+	written, err := io.CopyN(destFile, r, maxBytes+1)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to copy file contents: %w", err)
+	}
+	if written > maxBytes {
+		return fmt.Errorf("file %s in archive exceeds max size", name)
+	}
+
+	return destFile.Close()
+}
+
+// walkSources calls add for every file and directory of the sources that opts
+// archives, with its name in the archive. A directory is only added once something
+// in it is, so excluded files leave no empty directories behind.
+func walkSources(sources []Source, opts WriteOptions, add func(path, name string, info os.FileInfo) error) error {
+	filter := opts.Filter
+	if filter == nil {
+		filter = everything{}
+	}
+
+	// The directories above the entry being walked that are still to be added. The
+	// walk is depth first, so this is always a chain from the root.
+	type pendingDir struct {
+		relPath string
+		path    string
+		info    os.FileInfo
+		name    string
+	}
+	var pending []pendingDir
+	flushPending := func() error {
+		for _, dir := range pending {
+			if err := add(dir.path, dir.name, dir.info); err != nil {
+				return err
+			}
+		}
+		pending = pending[:0]
+		return nil
+	}
+
+	for _, source := range sources {
+		sourceDir := source.Dir
+		pending = pending[:0]
+
+		err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if opts.SkipDir != "" && info.IsDir() && info.Name() == opts.SkipDir && path != sourceDir {
+				return filepath.SkipDir
+			}
+
+			relPath, err := filepath.Rel(sourceDir, path)
+			if err != nil {
+				return fmt.Errorf("failed to get relative path: %w", err)
+			}
+			name := filepath.Join(source.Prefix, relPath)
+
+			slashPath := filepath.ToSlash(relPath)
+			if relPath != "." && filter.Excludes(slashPath) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Forget directories the walk has left without adding anything in them
+			for len(pending) > 0 && !strings.HasPrefix(relPath, pending[len(pending)-1].relPath+string(filepath.Separator)) {
+				pending = pending[:len(pending)-1]
+			}
+
+			if info.IsDir() {
+				pending = append(pending, pendingDir{relPath: relPath, path: path, info: info, name: name})
+				if relPath == "." || filter.Includes(slashPath) {
+					return flushPending()
+				}
+				return nil
+			}
+
+			if !filter.Includes(slashPath) {
+				return nil
+			}
+			if err := flushPending(); err != nil {
+				return err
+			}
+			return add(path, name, info)
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to walk directory: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// everything is the Filter of WriteOptions without one
+type everything struct{}
+
+func (everything) Excludes(string) bool { return false }
+func (everything) Includes(string) bool { return true }
+
+// reproducibleModTime is the modification time of every entry in a reproducible archive
+var reproducibleModTime = time.Unix(0, 0)
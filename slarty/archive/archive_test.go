@@ -0,0 +1,166 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeTree writes files, keyed by slash separated path, under dir
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// prefixFilter leaves out everything under excluded
+type prefixFilter struct {
+	excluded string
+}
+
+func (f prefixFilter) Excludes(relPath string) bool {
+	return relPath == f.excluded || strings.HasPrefix(relPath, f.excluded+"/")
+}
+
+func (f prefixFilter) Includes(relPath string) bool {
+	return !f.Excludes(relPath)
+}
+
+func TestArchiversRoundTrip(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeTree(t, sourceDir, map[string]string{
+		"index.html":        "<html>",
+		"assets/app.js":     "console.log(1)",
+		"cache/stale.txt":   "stale",
+		".slarty/tmp/x.tgz": "work file",
+	})
+	sources := []Source{{Dir: sourceDir, Prefix: "web"}}
+	opts := WriteOptions{Filter: prefixFilter{excluded: "cache"}, SkipDir: ".slarty"}
+
+	for _, archiver := range []Archiver{TarGz, Zip, Tar} {
+		t.Run(archiver.Name(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := archiver.Write(&buf, sources, opts); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if detected := Detect(buf.Bytes()); detected != archiver {
+				t.Errorf("Expected %s to be detected, got %s", archiver.Name(), detected.Name())
+			}
+
+			// Extract tells the format from the content
+			destDir := filepath.Join(t.TempDir(), "dest")
+			if err := Extract(bytes.NewReader(buf.Bytes()), destDir, ReadOptions{}); err != nil {
+				t.Fatalf("Extract failed: %v", err)
+			}
+			content, err := os.ReadFile(filepath.Join(destDir, "web", "assets", "app.js"))
+			if err != nil || string(content) != "console.log(1)" {
+				t.Errorf("Expected web/assets/app.js, got %q (%v)", content, err)
+			}
+			for _, left := range []string{"cache", ".slarty"} {
+				if _, err := os.Stat(filepath.Join(destDir, "web", left)); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be left out, got %v", left, err)
+				}
+			}
+
+			entries, err := List(bytes.NewReader(buf.Bytes()), ReadOptions{})
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			var files []string
+			for _, entry := range entries {
+				if entry.Type == "file" {
+					files = append(files, filepath.ToSlash(entry.Name))
+				}
+			}
+			sort.Strings(files)
+			if strings.Join(files, ",") != "web/assets/app.js,web/index.html" {
+				t.Errorf("Expected the two files listed, got %v", files)
+			}
+		})
+	}
+}
+
+func TestArchiversReproducible(t *testing.T) {
+	for _, archiver := range []Archiver{TarGz, Zip, Tar} {
+		t.Run(archiver.Name(), func(t *testing.T) {
+			write := func() []byte {
+				sourceDir := t.TempDir()
+				writeTree(t, sourceDir, map[string]string{"a.txt": "a", "b/c.txt": "c"})
+				var buf bytes.Buffer
+				if err := archiver.Write(&buf, []Source{{Dir: sourceDir}}, WriteOptions{Reproducible: true}); err != nil {
+					t.Fatalf("Write failed: %v", err)
+				}
+				return buf.Bytes()
+			}
+			if !bytes.Equal(write(), write()) {
+				t.Errorf("Expected identical archives for identical content")
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for name, want := range map[string]Archiver{"": TarGz, "tar.gz": TarGz, "zip": Zip, "tar": Tar} {
+		if archiver, err := Lookup(name); err != nil || archiver != want {
+			t.Errorf("Lookup(%q) = %v, %v", name, archiver, err)
+		}
+	}
+	if _, err := Lookup("rar"); !errors.Is(err, ErrUnknownFormat) || !strings.Contains(err.Error(), "tar.gz, zip, tar") {
+		t.Errorf("Expected an unknown format error listing the formats, got %v", err)
+	}
+	if Zip.Extension() != "zip" || TarGz.Extension() != "tar.gz" {
+		t.Errorf("Unexpected extensions %s and %s", Zip.Extension(), TarGz.Extension())
+	}
+}
+
+// fileLimit limits how many entries are extracted
+type fileLimit int
+
+func (l fileLimit) CheckArchiveSize(int64) error { return nil }
+func (l fileLimit) ArchiveSizeLimit() int64      { return 0 }
+func (l fileLimit) CheckExtracted(files int, size int64) error {
+	if files > int(l) {
+		return errors.New("too many files")
+	}
+	return nil
+}
+
+func TestZipFromStreamUsesTempFile(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeTree(t, sourceDir, map[string]string{"a.txt": "a", "b.txt": "b"})
+	var buf bytes.Buffer
+	if err := Zip.Write(&buf, []Source{{Dir: sourceDir}}, WriteOptions{}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	tempDir := t.TempDir()
+	created := 0
+	opts := ReadOptions{TempFile: func(pattern string) (*os.File, error) {
+		created++
+		return os.CreateTemp(tempDir, pattern)
+	}}
+	if err := Extract(bytes.NewReader(buf.Bytes()), filepath.Join(t.TempDir(), "dest"), opts); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	leftover, _ := os.ReadDir(tempDir)
+	if created != 1 || len(leftover) != 0 {
+		t.Errorf("Expected one temporary file, removed afterwards; created %d, %d left", created, len(leftover))
+	}
+
+	// The limits apply whichever format the archive is in
+	opts.Limits = fileLimit(1)
+	if err := Extract(bytes.NewReader(buf.Bytes()), filepath.Join(t.TempDir(), "dest"), opts); err == nil {
+		t.Errorf("Expected the file limit to stop extraction")
+	}
+}
@@ -0,0 +1,233 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// tarArchiver is the tar format, gzip compressed unless it is plain tar
+type tarArchiver struct {
+	compressed bool
+}
+
+func (t tarArchiver) Name() string {
+	if t.compressed {
+		return "tar.gz"
+	}
+	return "tar"
+}
+
+func (t tarArchiver) Extension() string {
+	return t.Name()
+}
+
+// Write writes the files of the sources as a tar stream, gzip compressed for tar.gz,
+// each under its source's prefix
+func (t tarArchiver) Write(w io.Writer, sources []Source, opts WriteOptions) error {
+	var gzipWriter *gzip.Writer
+	if t.compressed {
+		gzipWriter = gzip.NewWriter(w)
+		defer gzipWriter.Close()
+		w = gzipWriter
+	}
+
+	tarWriter := tar.NewWriter(w)
+	defer tarWriter.Close()
+
+	err := walkSources(sources, opts, func(path, name string, info os.FileInfo) error {
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("failed to read symlink: %w", err)
+			}
+			link = target
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("failed to create tar header: %w", err)
+		}
+		header.Name = name
+		if opts.Reproducible {
+			normalizeTarHeader(header)
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write file header: %w", err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open source file: %w", err)
+		}
+		defer file.Close()
+
+		if _, err := io.Copy(tarWriter, file); err != nil {
+			return fmt.Errorf("failed to copy file to archive: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Flush the archive so a streaming reader sees the whole thing before EOF
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finish tar archive: %w", err)
+	}
+	if gzipWriter != nil {
+		if err := gzipWriter.Close(); err != nil {
+			return fmt.Errorf("failed to finish gzip stream: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// normalizeTarHeader strips the parts of a tar header that vary between builds of the
+// same content: timestamps and file ownership. Entries are already written in a fixed
+// order, since filepath.Walk visits each directory in lexical order.
+func normalizeTarHeader(header *tar.Header) {
+	header.ModTime = reproducibleModTime
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Uid = 0
+	header.Gid = 0
+	header.Uname = ""
+	header.Gname = ""
+	header.PAXRecords = nil
+}
+
+// reader returns a tar reader of the archive read from r, and a function that reads
+// to the end of the stream, checking the gzip checksum and the archive size limit
+func (t tarArchiver) reader(r io.Reader, opts ReadOptions) (*tar.Reader, func() error, error) {
+	r = opts.limitReader(r)
+	if !t.compressed {
+		return tar.NewReader(r), func() error {
+			_, err := io.Copy(io.Discard, r)
+			return err
+		}, nil
+	}
+
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	finish := func() error {
+		defer gzipReader.Close()
+		if _, err := io.Copy(io.Discard, gzipReader); err != nil {
+			return fmt.Errorf("failed to read gzip stream: %w", err)
+		}
+		return nil
+	}
+	return tar.NewReader(gzipReader), finish, nil
+}
+
+// Extract extracts a tar stream to destDir, stopping as soon as the archive goes over
+// any of the limits
+func (t tarArchiver) Extract(r io.Reader, destDir string, opts ReadOptions) error {
+	tarReader, finish, err := t.reader(r, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	limits := opts.limits()
+	var files int
+	var extracted int64
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		// The tar reader never returns more than header.Size bytes for an entry, so
+		// the limits can be checked before anything is written
+		files++
+		if header.Typeflag == tar.TypeReg {
+			extracted += header.Size
+		}
+		if err := limits.CheckExtracted(files, extracted); err != nil {
+			return err
+		}
+
+		if err := extractTarEntry(header, tarReader, destDir, opts.maxFileBytes()); err != nil {
+			return err
+		}
+	}
+
+	return finish()
+}
+
+// extractTarEntry extracts a single entry of a tar archive
+func extractTarEntry(header *tar.Header, tarReader *tar.Reader, destDir string, maxBytes int64) error {
+	destPath, err := destination(destDir, header.Name)
+	if err != nil {
+		return err
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(destPath, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	case tar.TypeReg:
+		return extractFile(destPath, header.Name, os.FileMode(header.Mode&0o777), tarReader, maxBytes)
+	default:
+		// Skip other types of files (symlinks, etc.)
+	}
+
+	return nil
+}
+
+// List reads the headers of a tar archive without extracting any contents
+func (t tarArchiver) List(r io.Reader, opts ReadOptions) ([]Entry, error) {
+	tarReader, _, err := t.reader(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		entryType := "other"
+		switch header.Typeflag {
+		case tar.TypeDir:
+			entryType = "dir"
+		case tar.TypeReg:
+			entryType = "file"
+		case tar.TypeSymlink:
+			entryType = "symlink"
+		}
+
+		entries = append(entries, Entry{
+			Name:     header.Name,
+			Type:     entryType,
+			Mode:     header.FileInfo().Mode().String(),
+			Size:     header.Size,
+			Modified: header.ModTime,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,241 @@
+package archive
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// zipArchiver is the zip format. A zip file keeps its index at the end, so it is
+// written as a stream but downloaded to a temporary file to be read.
+type zipArchiver struct{}
+
+func (zipArchiver) Name() string      { return "zip" }
+func (zipArchiver) Extension() string { return "zip" }
+
+// reproducibleZipTime is the modification time of every entry in a reproducible zip
+// archive, the earliest a zip file can record
+var reproducibleZipTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Write writes the files of the sources as a zip stream, each under its source's
+// prefix. Symlinks and other special files are left out, as they are when a zip
+// archive is extracted.
+func (zipArchiver) Write(w io.Writer, sources []Source, opts WriteOptions) error {
+	zipWriter := zip.NewWriter(w)
+	defer zipWriter.Close()
+
+	err := walkSources(sources, opts, func(path, name string, info os.FileInfo) error {
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		name = filepath.ToSlash(name)
+		if name == "." {
+			return nil
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return fmt.Errorf("failed to create zip header: %w", err)
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+		if opts.Reproducible {
+			header.Modified = reproducibleZipTime
+		}
+
+		entry, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to write file header: %w", err)
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open source file: %w", err)
+		}
+		defer file.Close()
+
+		if _, err := io.Copy(entry, file); err != nil {
+			return fmt.Errorf("failed to copy file to archive: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finish zip archive: %w", err)
+	}
+	return nil
+}
+
+// open downloads the zip archive read from r to a temporary file, since a zip file
+// can only be read from its end, and opens it. The returned function closes and
+// removes it.
+func (zipArchiver) open(r io.Reader, opts ReadOptions) (*zip.ReadCloser, func(), error) {
+	tempFile, err := opts.createTemp("slarty-*.zip")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	remove := func() { os.Remove(tempFile.Name()) }
+
+	_, err = io.Copy(tempFile, opts.limitReader(r))
+	if closeErr := tempFile.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		remove()
+		return nil, nil, fmt.Errorf("failed to download zip archive: %w", err)
+	}
+
+	zipReader, err := openZipFile(tempFile.Name(), opts)
+	if err != nil {
+		remove()
+		return nil, nil, err
+	}
+	return zipReader, func() {
+		zipReader.Close()
+		remove()
+	}, nil
+}
+
+// openZipFile opens the zip file at path, once it is known to be within the archive
+// size limit
+func openZipFile(path string, opts ReadOptions) (*zip.ReadCloser, error) {
+	if info, err := os.Stat(path); err == nil {
+		if err := opts.limits().CheckArchiveSize(info.Size()); err != nil {
+			return nil, err
+		}
+	}
+
+	zipReader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %w", err)
+	}
+	return zipReader, nil
+}
+
+// Extract extracts a zip archive to destDir with the same safety rules as a tar
+// archive
+func (z zipArchiver) Extract(r io.Reader, destDir string, opts ReadOptions) error {
+	zipReader, closeZip, err := z.open(r, opts)
+	if err != nil {
+		return err
+	}
+	defer closeZip()
+
+	return extractZip(zipReader, destDir, opts)
+}
+
+// extractZip extracts an open zip archive to destDir
+func extractZip(zipReader *zip.ReadCloser, destDir string, opts ReadOptions) error {
+	// Check the limits against the sizes in the zip directory before writing
+	// anything. The zip reader fails an entry that holds more than its recorded size.
+	var extracted int64
+	for _, file := range zipReader.File {
+		if file.Mode().IsRegular() {
+			extracted += int64(file.UncompressedSize64)
+		}
+	}
+	if err := opts.limits().CheckExtracted(len(zipReader.File), extracted); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	for _, file := range zipReader.File {
+		if err := extractZipEntry(file, destDir, opts.maxFileBytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractZipEntry extracts a single entry of a zip archive
+func extractZipEntry(file *zip.File, destDir string, maxBytes int64) error {
+	destPath, err := destination(destDir, file.Name)
+	if err == nil && strings.Contains(file.Name, `\`) {
+		err = fmt.Errorf("illegal path in archive: %s", file.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	mode := file.Mode()
+	switch {
+	case mode.IsDir():
+		if err := os.MkdirAll(destPath, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	case mode.IsRegular():
+		// Zip files written on some systems record no permissions at all
+		perm := mode.Perm()
+		if perm == 0 {
+			perm = 0644
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s from zip archive: %w", file.Name, err)
+		}
+		defer reader.Close()
+
+		return extractFile(destPath, file.Name, perm, reader, maxBytes)
+	default:
+		// Skip symlinks and other special files, as for tar entries
+	}
+
+	return nil
+}
+
+// List reads the directory of a zip archive without extracting any contents
+func (z zipArchiver) List(r io.Reader, opts ReadOptions) ([]Entry, error) {
+	zipReader, closeZip, err := z.open(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer closeZip()
+
+	return listZip(zipReader), nil
+}
+
+// listZip returns the entries of an open zip archive
+func listZip(zipReader *zip.ReadCloser) []Entry {
+	entries := make([]Entry, 0, len(zipReader.File))
+	for _, file := range zipReader.File {
+		mode := file.Mode()
+		entryType := "other"
+		switch {
+		case mode.IsDir():
+			entryType = "dir"
+		case mode.IsRegular():
+			entryType = "file"
+		case mode&os.ModeSymlink != 0:
+			entryType = "symlink"
+		}
+
+		entries = append(entries, Entry{
+			Name:     file.Name,
+			Type:     entryType,
+			Mode:     mode.String(),
+			Size:     int64(file.UncompressedSize64),
+			Modified: file.Modified,
+		})
+	}
+
+	return entries
+}
@@ -0,0 +1,257 @@
+package slarty
+
+import (
+	"fmt"
+	"github.com/dstockto/slarty/slarty/archive"
+	"regexp"
+	"strings"
+)
+
+// DefaultArtifactNameTemplate is how archives are named when neither the artifact nor
+// the configuration sets artifact_name
+const DefaultArtifactNameTemplate = "{prefix}-{hash}.{ext}"
+
+// shortHashLength is how many characters of the hash {short_hash} keeps, and the
+// shortest hash_length allowed
+const shortHashLength = 7
+
+// artifactNamePlaceholders are the placeholders an artifact name template may use
+var artifactNamePlaceholders = map[string]bool{
+	"{prefix}":     true,
+	"{hash}":       true,
+	"{short_hash}": true,
+	"{app}":        true,
+	"{channel}":    true,
+	"{version}":    true,
+	"{date}":       true,
+	"{ext}":        true,
+}
+
+// artifactNameLiteral matches the text an artifact name template may have around its
+// placeholders
+var artifactNameLiteral = regexp.MustCompile(`^[A-Za-z0-9._-]*$`)
+
+// nonNameCharacters matches what is replaced when a value, such as a channel, is put
+// in an artifact name
+var nonNameCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// artifactNameSeparators are dropped next to a placeholder that expands to nothing
+const artifactNameSeparators = "-_."
+
+// CheckArtifactNameTemplate returns an error if an artifact name template uses an
+// unknown placeholder, has no {hash}, so every build would get the same name, or has
+// text that can't be part of a name. An empty template is the default.
+func CheckArtifactNameTemplate(template string) error {
+	if template == "" {
+		return nil
+	}
+	if !strings.Contains(template, "{hash}") {
+		return fmt.Errorf("artifact_name %q must contain {hash}", template)
+	}
+	for _, placeholder := range pathPlaceholderPattern.FindAllString(template, -1) {
+		if !artifactNamePlaceholders[placeholder] {
+			return fmt.Errorf("unknown placeholder %s in artifact_name %q", placeholder, template)
+		}
+	}
+	if !artifactNameLiteral.MatchString(pathPlaceholderPattern.ReplaceAllString(template, "")) {
+		return fmt.Errorf("artifact_name %q may only contain letters, digits, '.', '_' and '-' around its placeholders", template)
+	}
+	return nil
+}
+
+// archiveExtension returns what {ext} expands to, the extension of the artifact's
+// archive format. An unknown format is left to validation and Archiver to report.
+func (a ArtifactConfig) archiveExtension() string {
+	archiver, err := a.Archiver()
+	if err != nil {
+		return archive.TarGz.Extension()
+	}
+	return archiver.Extension()
+}
+
+// Archiver returns the Archiver for the artifact's archive format
+func (a ArtifactConfig) Archiver() (archive.Archiver, error) {
+	archiver, err := archive.Lookup(a.Archive)
+	if err != nil {
+		return nil, fmt.Errorf("artifact %s: %w", a.Name, err)
+	}
+	return archiver, nil
+}
+
+// nameTemplate returns the template the artifact's archives are named with
+func (a ArtifactConfig) nameTemplate() string {
+	if a.NameTemplate != "" {
+		return a.NameTemplate
+	}
+	return DefaultArtifactNameTemplate
+}
+
+// applyNameTemplate gives every artifact without an artifact_name of its own the one
+// set for the whole configuration. It runs before workspaces are added, so each
+// workspace keeps its own naming convention.
+func (ac *ArtifactsConfig) applyNameTemplate() {
+	if ac.NameTemplate == "" {
+		return
+	}
+	for i := range ac.Artifacts {
+		if ac.Artifacts[i].NameTemplate == "" {
+			ac.Artifacts[i].NameTemplate = ac.NameTemplate
+		}
+	}
+}
+
+// nameValue makes a value, such as a channel like feature/login, safe to put in an
+// artifact name
+func nameValue(value string) string {
+	return strings.Trim(nonNameCharacters.ReplaceAllString(value, "-"), "-")
+}
+
+// artifactVersion returns what {version} expands to: the configuration's version or,
+// when that is not set, SLARTY_VERSION or --var version=...
+func (ac *ArtifactsConfig) artifactVersion() (string, error) {
+	if ac.Version != "" {
+		return ac.Version, nil
+	}
+	version, err := LocationVariable("version", ac.Variables)
+	if err != nil {
+		return "", fmt.Errorf("artifact_name uses {version}, but the version is not set: set \"version\" in artifacts.json, %s or pass --var version=value", LocationVariableEnv("version"))
+	}
+	return version, nil
+}
+
+// artifactNameForHash returns the archive name of the artifact built from code with
+// the given hash, at ref, or HEAD when ref is empty. The commit is only looked up when
+// the name has a {date}, which is the day the commit was made.
+func (ac *ArtifactsConfig) artifactNameForHash(config ArtifactConfig, hash, ref string) (string, error) {
+	template := config.nameTemplate()
+	if err := CheckArtifactNameTemplate(template); err != nil {
+		return "", fmt.Errorf("artifact %s: %w", config.Name, err)
+	}
+
+	values := map[string]string{
+		"{prefix}":     config.ArtifactPrefix,
+		"{hash}":       hash,
+		"{short_hash}": ShortenHash(hash, shortHashLength),
+		"{app}":        nameValue(ac.Application),
+		"{channel}":    nameValue(ac.Repository.Channel),
+		"{ext}":        config.archiveExtension(),
+	}
+	if strings.Contains(template, "{version}") {
+		version, err := ac.artifactVersion()
+		if err != nil {
+			return "", err
+		}
+		values["{version}"] = nameValue(version)
+	}
+	if strings.Contains(template, "{date}") {
+		committed, err := CommitTime(ac.RootDirectory, ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to find the commit date for artifact_name: %w", err)
+		}
+		values["{date}"] = committed.UTC().Format("20060102")
+	}
+
+	return expandArtifactName(template, func(placeholder string) string { return values[placeholder] }, nil), nil
+}
+
+// artifactNamePattern returns a regular expression matching the archive names of the
+// artifact, capturing the hash. Placeholders whose value is known are matched
+// exactly, and {short_hash}, {date} and an unset {version} by what they may look like.
+func (ac *ArtifactsConfig) artifactNamePattern(config ArtifactConfig) (*regexp.Regexp, error) {
+	template := config.nameTemplate()
+	if err := CheckArtifactNameTemplate(template); err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{
+		"{prefix}":     regexp.QuoteMeta(config.ArtifactPrefix),
+		"{hash}":       "(.+)",
+		"{short_hash}": "[0-9A-Za-z]+(?:" + regexp.QuoteMeta(DirtyMarker) + ")?",
+		"{version}":    "[A-Za-z0-9._-]+?",
+		"{date}":       "[0-9]{8}",
+		"{ext}":        regexp.QuoteMeta(config.archiveExtension()),
+	}
+	values["{app}"] = regexp.QuoteMeta(nameValue(ac.Application))
+	values["{channel}"] = regexp.QuoteMeta(nameValue(ac.Repository.Channel))
+	if version, err := ac.artifactVersion(); err == nil {
+		values["{version}"] = regexp.QuoteMeta(nameValue(version))
+	}
+
+	pattern := expandArtifactName(template, func(placeholder string) string { return values[placeholder] }, regexp.QuoteMeta)
+	return regexp.Compile("^" + pattern + "$")
+}
+
+// expandArtifactName expands the placeholders of an artifact name template with
+// value, passing the text around them through literal when it is given. A
+// placeholder that expands to nothing takes a separator next to it away too, so an
+// unset {channel} leaves no stray "-" behind.
+func expandArtifactName(template string, value func(placeholder string) string, literal func(string) string) string {
+	if literal == nil {
+		literal = func(text string) string { return text }
+	}
+
+	var name strings.Builder
+	pending := ""
+	dropNext := false
+	last := 0
+	for _, loc := range pathPlaceholderPattern.FindAllStringIndex(template, -1) {
+		text := template[last:loc[0]]
+		if dropNext && text != "" && strings.ContainsRune(artifactNameSeparators, rune(text[0])) {
+			text = text[1:]
+		}
+		dropNext = false
+		pending += text
+		last = loc[1]
+
+		expanded := value(template[loc[0]:loc[1]])
+		if expanded != "" {
+			name.WriteString(literal(pending))
+			name.WriteString(expanded)
+			pending = ""
+			continue
+		}
+		if pending != "" && strings.ContainsRune(artifactNameSeparators, rune(pending[len(pending)-1])) {
+			pending = pending[:len(pending)-1]
+		} else if pending == "" && name.Len() == 0 {
+			dropNext = true
+		}
+	}
+	text := template[last:]
+	if dropNext && text != "" && strings.ContainsRune(artifactNameSeparators, rune(text[0])) {
+		text = text[1:]
+	}
+	name.WriteString(literal(pending + text))
+	return name.String()
+}
+
+// artifactPathNamer returns what {artifact} in the repository path expands to for an
+// artifact name: the prefix of the artifact it is an archive of, and otherwise
+// everything before the last "-", as for latest pointers and audit logs. It is only
+// needed when an artifact has a name template of its own.
+func (ac *ArtifactsConfig) artifactPathNamer() func(artifactName string) string {
+	var patterns []*regexp.Regexp
+	var prefixes []string
+	for _, artifact := range ac.Artifacts {
+		if artifact.NameTemplate == "" || artifact.IsDocker() {
+			continue
+		}
+		pattern, err := ac.artifactNamePattern(artifact)
+		if err != nil {
+			continue
+		}
+		patterns = append(patterns, pattern)
+		prefixes = append(prefixes, artifact.ArtifactPrefix)
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	return func(artifactName string) string {
+		for i, pattern := range patterns {
+			if pattern.MatchString(artifactName) {
+				return prefixes[i]
+			}
+		}
+		return artifactPathName(artifactName)
+	}
+}
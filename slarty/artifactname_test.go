@@ -0,0 +1,176 @@
+package slarty
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckArtifactNameTemplate(t *testing.T) {
+	for _, template := range []string{"", DefaultArtifactNameTemplate, "{prefix}-{hash}-{channel}.{ext}", "{app}_{prefix}-{version}-{date}-{short_hash}-{hash}.tgz"} {
+		if err := CheckArtifactNameTemplate(template); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", template, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"{prefix}-{short_hash}.{ext}":  "must contain {hash}",
+		"{prefix}-{hash}-{branch}.tgz": "unknown placeholder {branch}",
+		"builds/{prefix}-{hash}.{ext}": "may only contain",
+		"{prefix} {hash}.{ext}":        "may only contain",
+	}
+	for template, want := range invalid {
+		if err := CheckArtifactNameTemplate(template); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q to fail with %q, got %v", template, want, err)
+		}
+	}
+}
+
+func TestArtifactNameTemplates(t *testing.T) {
+	t.Setenv(LocationVariableEnv("version"), "")
+	os.Unsetenv(LocationVariableEnv("version"))
+
+	config := &ArtifactsConfig{Application: "Shop Front", Version: "2.1.0"}
+	config.Repository.Channel = "feature/login"
+	hash := "51286ac9e1f0d3b2a8c7e6f5d4c3b2a1f0e9d8c7"
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"", "web-" + hash + ".tar.gz"},
+		{"{prefix}-{hash}-{channel}.{ext}", "web-" + hash + "-feature-login.tar.gz"},
+		{"{app}-{prefix}-{version}-{short_hash}.{hash}.{ext}", "Shop-Front-web-2.1.0-51286ac." + hash + ".tar.gz"},
+	}
+	for _, tt := range tests {
+		artifact := ArtifactConfig{Name: "web", ArtifactPrefix: "web", NameTemplate: tt.template}
+		name, err := ArtifactNameForHash(artifact, hash, config)
+		if err != nil || name != tt.want {
+			t.Errorf("%q: expected %s, got %s (%v)", tt.template, tt.want, name, err)
+			continue
+		}
+		if got := HashFromArtifactName(artifact, name, config); got != hash {
+			t.Errorf("%q: expected the hash back from %s, got %q", tt.template, name, got)
+		}
+		if got := HashFromArtifactName(artifact, "api-"+hash+".tar.gz", config); got != "" {
+			t.Errorf("%q: expected no hash from another artifact's name, got %q", tt.template, got)
+		}
+	}
+
+	// A placeholder without a value takes its separator with it
+	artifact := ArtifactConfig{Name: "web", ArtifactPrefix: "web", NameTemplate: "{channel}-{prefix}-{hash}-{channel}.{ext}"}
+	unchannelled := &ArtifactsConfig{}
+	name, err := ArtifactNameForHash(artifact, "abc-dirty", unchannelled)
+	if err != nil || name != "web-abc-dirty.tar.gz" {
+		t.Errorf("Expected web-abc-dirty.tar.gz without a channel, got %s (%v)", name, err)
+	}
+	if got := HashFromArtifactName(artifact, name, unchannelled); got != "abc-dirty" {
+		t.Errorf("Expected abc-dirty back, got %q", got)
+	}
+
+	// {version} falls back to --var version and SLARTY_VERSION, and is an error when
+	// nothing sets it
+	versioned := ArtifactConfig{Name: "web", ArtifactPrefix: "web", NameTemplate: "{prefix}-{version}-{hash}.{ext}"}
+	if _, err := ArtifactNameForHash(versioned, "abc", unchannelled); err == nil || !strings.Contains(err.Error(), "version is not set") {
+		t.Errorf("Expected an error for an unset version, got %v", err)
+	}
+	t.Setenv(LocationVariableEnv("version"), "3.0")
+	if name, err := ArtifactNameForHash(versioned, "abc", unchannelled); err != nil || name != "web-3.0-abc.tar.gz" {
+		t.Errorf("Expected web-3.0-abc.tar.gz, got %s (%v)", name, err)
+	}
+	unchannelled.Variables = map[string]string{"version": "3.1"}
+	if name, err := ArtifactNameForHash(versioned, "abc", unchannelled); err != nil || name != "web-3.1-abc.tar.gz" {
+		t.Errorf("Expected --var version to win, got %s (%v)", name, err)
+	}
+}
+
+func TestArtifactNameDate(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE=2024-03-05T23:30:00-05:00", "GIT_AUTHOR_DATE=2024-03-05T23:30:00-05:00")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	git("init")
+	git("config", "user.email", "test@example.com")
+	git("config", "user.name", "Test User")
+	if err := os.WriteFile(filepath.Join(tempDir, "README"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-m", "Initial commit")
+
+	config := &ArtifactsConfig{RootDirectory: tempDir}
+	artifact := ArtifactConfig{Name: "web", ArtifactPrefix: "web", NameTemplate: "{prefix}-{date}-{hash}.{ext}"}
+
+	// The date is the day of the commit in UTC
+	name, err := ArtifactNameForHash(artifact, "abc", config)
+	if err != nil || name != "web-20240306-abc.tar.gz" {
+		t.Errorf("Expected web-20240306-abc.tar.gz, got %s (%v)", name, err)
+	}
+	withoutGitBinary(t, func() {
+		if goGitName, err := ArtifactNameForHash(artifact, "abc", config); err != nil || goGitName != name {
+			t.Errorf("Expected %s without the git binary, got %s (%v)", name, goGitName, err)
+		}
+	})
+	if hash := HashFromArtifactName(artifact, name, config); hash != "abc" {
+		t.Errorf("Expected abc back, got %q", hash)
+	}
+}
+
+func TestArtifactNameTemplateDefaults(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifacts.json")
+	err := os.WriteFile(path, []byte(`{
+		"application": "app",
+		"artifact_name": "{prefix}-{hash}-{app}.{ext}",
+		"repository": {"adapter": "local", "options": {"root": "repo/{artifact}"}},
+		"artifacts": [
+			{"name": "web", "artifact_prefix": "web"},
+			{"name": "api", "artifact_prefix": "api", "artifact_name": "{prefix}.{hash}.{ext}"}
+		]
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ReadArtifactsJson(path)
+	if err != nil {
+		t.Fatalf("ReadArtifactsJson failed: %v", err)
+	}
+	if config.Artifacts[0].NameTemplate != "{prefix}-{hash}-{app}.{ext}" || config.Artifacts[1].NameTemplate != "{prefix}.{hash}.{ext}" {
+		t.Fatalf("Expected the default template only where none is set, got %q and %q", config.Artifacts[0].NameTemplate, config.Artifacts[1].NameTemplate)
+	}
+
+	// {artifact} in the repository path is the prefix of whichever artifact the
+	// archive belongs to
+	config.Repository.Options.Root = filepath.Join(dir, "repo", "{artifact}")
+	repo, err := NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+	local := repo.(*LocalRepositoryAdapter)
+	for _, artifact := range config.Artifacts {
+		name, err := ArtifactNameForHash(artifact, "abc", config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := filepath.Join(dir, "repo", artifact.ArtifactPrefix, name)
+		if path := local.artifactPath(name); path != want {
+			t.Errorf("Expected %s, got %s", want, path)
+		}
+	}
+	if path := local.artifactPath("web-latest.json"); path != filepath.Join(dir, "repo", "web", "web-latest.json") {
+		t.Errorf("Expected the latest pointer next to web's archives, got %s", path)
+	}
+}
@@ -0,0 +1,219 @@
+package slarty
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// atomicDeploy stages artifacts for an atomic DeployAll and then puts all of them in
+// place together, or none of them. Artifacts are extracted into a staging directory
+// next to their deploy location, or into a release for the symlink strategy. Commit
+// then renames each staging directory over its deploy location, keeping the old one
+// until every swap has worked, and links the releases. If any step fails, the
+// locations already swapped are put back as they were.
+type atomicDeploy struct {
+	deployer  *Deployer
+	root      string
+	backup    *Backup
+	locations []*stagedLocation
+	releases  []*stagedRelease
+}
+
+// stagedLocation is a deploy location whose new contents are waiting in stagingPath.
+// Artifacts sharing a deploy location are staged into the same directory.
+type stagedLocation struct {
+	deployPath  string
+	stagingPath string
+	backupPath  string
+	swapped     bool
+}
+
+// stagedRelease is an extracted release waiting to be linked, with the release that
+// was current before so the link can be put back
+type stagedRelease struct {
+	artifact ArtifactConfig
+	release  string
+	previous string
+	linked   bool
+}
+
+// newAtomicDeploy returns an atomic deploy staging artifacts with deployer, which
+// keeps the old contents of the deploy locations in its backup, if it has one
+func newAtomicDeploy(deployer *Deployer) *atomicDeploy {
+	return &atomicDeploy{deployer: deployer, root: deployer.Config.RootDirectory, backup: deployer.Backup}
+}
+
+// Stage downloads and extracts an artifact without touching its deploy location
+func (a *atomicDeploy) Stage(artifact ArtifactConfig, artifactName string) error {
+	if artifact.UsesSymlinkStrategy() {
+		previous, err := CurrentRelease(filepath.Join(a.root, artifact.DeployLocation))
+		if err != nil {
+			return err
+		}
+		release, err := a.deployer.ExtractRelease(artifact, artifactName)
+		if err != nil {
+			return err
+		}
+		a.releases = append(a.releases, &stagedRelease{artifact: artifact, release: release, previous: previous})
+		return nil
+	}
+
+	location, err := a.location(filepath.Join(a.root, artifact.DeployLocation))
+	if err != nil {
+		return err
+	}
+	if err := a.deployer.ExtractArtifact(artifact, artifactName, location.stagingPath); err != nil {
+		return err
+	}
+	if err := a.deployer.ApplyPermissions(location.stagingPath, artifact.Permissions()); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	a.deployer.observer().OnMessage(" - Staged artifact")
+
+	return nil
+}
+
+// location returns the staged location for a deploy path, creating its staging
+// directory the first time
+func (a *atomicDeploy) location(deployPath string) (*stagedLocation, error) {
+	for _, location := range a.locations {
+		if location.deployPath == deployPath {
+			return location, nil
+		}
+	}
+
+	// Stage next to the deploy location so it can be renamed into place
+	if err := os.MkdirAll(filepath.Dir(deployPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create deploy directory: %w", err)
+	}
+	stagingPath, err := os.MkdirTemp(filepath.Dir(deployPath), "."+filepath.Base(deployPath)+".staged-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	if err := os.Chmod(stagingPath, 0755); err != nil {
+		os.RemoveAll(stagingPath)
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	location := &stagedLocation{deployPath: deployPath, stagingPath: stagingPath}
+	a.locations = append(a.locations, location)
+	return location, nil
+}
+
+// Commit puts every staged artifact in place. If any of them cannot be, the ones
+// already in place are rolled back and the error is returned.
+func (a *atomicDeploy) Commit() error {
+	for _, location := range a.locations {
+		if err := location.swap(); err != nil {
+			a.Rollback()
+			return err
+		}
+	}
+	for _, staged := range a.releases {
+		if err := a.deployer.LinkRelease(staged.artifact, staged.release); err != nil {
+			a.Rollback()
+			return err
+		}
+		staged.linked = true
+	}
+	a.deployer.observer().OnMessage("Switched every artifact to the new version")
+
+	// Nothing needs to be put back any more, so the old contents are removed or, with
+	// a Backup, kept in the backup
+	for _, location := range a.locations {
+		if location.backupPath == "" {
+			continue
+		}
+		if a.backup != nil {
+			if _, err := a.backup.SaveDirectory(location.deployPath, location.backupPath); err != nil {
+				a.deployer.observer().OnWarning(fmt.Sprintf("%v; the old contents are still in %s", err, location.backupPath))
+			}
+			continue
+		}
+		if err := os.RemoveAll(location.backupPath); err != nil {
+			a.deployer.observer().OnWarning(fmt.Sprintf("failed to remove %s: %v", location.backupPath, err))
+		}
+	}
+	for _, staged := range a.releases {
+		a.deployer.PruneReleases(staged.artifact, staged.release)
+	}
+
+	return nil
+}
+
+// swap moves the current deploy location aside and the staged one into its place
+func (l *stagedLocation) swap() error {
+	if _, err := os.Lstat(l.deployPath); err == nil {
+		l.backupPath = fmt.Sprintf("%s.slarty-previous-%d", l.deployPath, os.Getpid())
+		if err := os.Rename(l.deployPath, l.backupPath); err != nil {
+			l.backupPath = ""
+			return fmt.Errorf("failed to move %s aside: %w", l.deployPath, err)
+		}
+	}
+	if err := os.Rename(l.stagingPath, l.deployPath); err != nil {
+		if l.backupPath != "" {
+			os.Rename(l.backupPath, l.deployPath)
+			l.backupPath = ""
+		}
+		return fmt.Errorf("failed to move %s into place: %w", l.deployPath, err)
+	}
+	l.swapped = true
+	return nil
+}
+
+// Rollback puts back every deploy location and release link changed by Commit and
+// removes what was staged. Rolling back is best effort: a step that fails is
+// reported and the rest are still tried.
+func (a *atomicDeploy) Rollback() {
+	for i := len(a.releases) - 1; i >= 0; i-- {
+		staged := a.releases[i]
+		if !staged.linked {
+			continue
+		}
+		linkPath := filepath.Join(a.root, staged.artifact.DeployLocation)
+		var err error
+		if staged.previous != "" {
+			err = LinkRelease(linkPath, filepath.Join(staged.artifact.ReleasesPath(a.root), staged.previous))
+		} else {
+			err = os.Remove(linkPath)
+		}
+		if err != nil {
+			a.deployer.observer().OnWarning(fmt.Sprintf("failed to roll back %s: %v", staged.artifact.DeployLocation, err))
+			continue
+		}
+		staged.linked = false
+	}
+
+	for i := len(a.locations) - 1; i >= 0; i-- {
+		location := a.locations[i]
+		if location.swapped {
+			// The new contents go back to staging so they are removed below
+			if err := os.Rename(location.deployPath, location.stagingPath); err != nil {
+				a.deployer.observer().OnWarning(fmt.Sprintf("failed to roll back %s: %v", location.deployPath, err))
+				continue
+			}
+			location.swapped = false
+		}
+		if location.backupPath != "" {
+			if err := os.Rename(location.backupPath, location.deployPath); err != nil {
+				a.deployer.observer().OnWarning(fmt.Sprintf("failed to restore %s from %s: %v", location.deployPath, location.backupPath, err))
+				continue
+			}
+			location.backupPath = ""
+		}
+	}
+
+	a.Discard()
+	a.deployer.observer().OnMessage("Rolled back every artifact to the previous version")
+}
+
+// Discard removes the staging directories of a deploy that will not be committed.
+// Extracted releases are kept, as they are complete and are pruned as usual.
+func (a *atomicDeploy) Discard() {
+	for _, location := range a.locations {
+		if !location.swapped {
+			os.RemoveAll(location.stagingPath)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dstockto/slarty/slarty/archive"
+)
+
+// atomicFixture stores web and api archives holding version in a local repository
+// and returns the repository and a configuration rooted in a new project directory
+func atomicFixture(t *testing.T, version string) (RepositoryAdapter, *ArtifactsConfig) {
+	t.Helper()
+	tempDir := t.TempDir()
+	repo := NewLocalRepositoryAdapter(filepath.Join(tempDir, "repo"))
+	for _, name := range []string{"web", "api"} {
+		sourceDir := filepath.Join(tempDir, "source-"+name)
+		if err := os.MkdirAll(sourceDir, 0755); err != nil {
+			t.Fatalf("Failed to create source directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(sourceDir, "version.txt"), []byte(version), 0644); err != nil {
+			t.Fatalf("Failed to write source file: %v", err)
+		}
+		if _, err := NewBuilder(nil, repo).StoreArchive(archive.TarGz, []archive.Source{{Dir: sourceDir}}, nil, name+"-"+version+".tar.gz", SizeBudget{}); err != nil {
+			t.Fatalf("StoreArchive failed: %v", err)
+		}
+	}
+	return repo, &ArtifactsConfig{RootDirectory: filepath.Join(tempDir, "project")}
+}
+
+// readVersion returns the version.txt deployed in dir
+func readVersion(t *testing.T, dir string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, "version.txt"))
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+func TestAtomicDeployCommit(t *testing.T) {
+	repo, config := atomicFixture(t, "222")
+	web := ArtifactConfig{Name: "web", ArtifactPrefix: "web", DeployLocation: "public"}
+	api := ArtifactConfig{Name: "api", ArtifactPrefix: "api", DeployLocation: "api/current", DeployStrategy: DeployStrategySymlink}
+
+	publicPath := filepath.Join(config.RootDirectory, "public")
+	if err := os.MkdirAll(publicPath, 0755); err != nil {
+		t.Fatalf("Failed to create deploy location: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(publicPath, "stale.txt"), []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to write old file: %v", err)
+	}
+
+	atomic := newAtomicDeploy(&Deployer{Config: config, Repo: repo, Now: time.Now})
+	for _, artifact := range []ArtifactConfig{web, api} {
+		if err := atomic.Stage(artifact, artifact.Name+"-222.tar.gz"); err != nil {
+			t.Fatalf("Stage failed for %s: %v", artifact.Name, err)
+		}
+	}
+
+	// Nothing is in place until the commit
+	if _, err := os.Stat(filepath.Join(publicPath, "version.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected the deploy location to be untouched before the commit")
+	}
+
+	if err := atomic.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if version := readVersion(t, publicPath); version != "222" {
+		t.Errorf("Expected web version 222, got %q", version)
+	}
+	if _, err := os.Stat(filepath.Join(publicPath, "stale.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected the deploy location to be replaced, got %v", err)
+	}
+	if version := readVersion(t, filepath.Join(config.RootDirectory, "api", "current")); version != "222" {
+		t.Errorf("Expected api version 222, got %q", version)
+	}
+
+	// Neither the staged copy nor the old contents are left behind
+	entries, err := os.ReadDir(config.RootDirectory)
+	if err != nil {
+		t.Fatalf("Failed to read project directory: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "public" && entry.Name() != "api" {
+			t.Errorf("Expected no leftover %s", entry.Name())
+		}
+	}
+}
+
+func TestAtomicDeployRollback(t *testing.T) {
+	repo, config := atomicFixture(t, "222")
+	web := ArtifactConfig{Name: "web", ArtifactPrefix: "web", DeployLocation: "public"}
+	api := ArtifactConfig{Name: "api", ArtifactPrefix: "api", DeployLocation: "api/current", DeployStrategy: DeployStrategySymlink}
+
+	publicPath := filepath.Join(config.RootDirectory, "public")
+	if err := os.MkdirAll(publicPath, 0755); err != nil {
+		t.Fatalf("Failed to create deploy location: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(publicPath, "version.txt"), []byte("111"), 0644); err != nil {
+		t.Fatalf("Failed to write old version: %v", err)
+	}
+	atomic := newAtomicDeploy(&Deployer{Config: config, Repo: repo, Now: time.Now})
+	for _, artifact := range []ArtifactConfig{web, api} {
+		if err := atomic.Stage(artifact, artifact.Name+"-222.tar.gz"); err != nil {
+			t.Fatalf("Stage failed for %s: %v", artifact.Name, err)
+		}
+	}
+	// A directory where the api symlink should go makes linking fail after web is swapped
+	if err := os.MkdirAll(filepath.Join(config.RootDirectory, "api", "current"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := atomic.Commit(); err == nil {
+		t.Fatalf("Expected the commit to fail")
+	}
+	if version := readVersion(t, publicPath); version != "111" {
+		t.Errorf("Expected web to be rolled back to 111, got %q", version)
+	}
+	entries, _ := os.ReadDir(config.RootDirectory)
+	if len(entries) != 2 {
+		t.Errorf("Expected the staged and old copies to be cleaned up, got %d entries", len(entries))
+	}
+}
+
+func TestAtomicDeployDiscard(t *testing.T) {
+	repo, config := atomicFixture(t, "222")
+	web := ArtifactConfig{Name: "web", ArtifactPrefix: "web", DeployLocation: "public"}
+
+	atomic := newAtomicDeploy(&Deployer{Config: config, Repo: repo, Now: time.Now})
+	if err := atomic.Stage(web, "web-222.tar.gz"); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if err := atomic.Stage(web, "web-missing.tar.gz"); err == nil {
+		t.Fatalf("Expected a missing archive to fail")
+	}
+	atomic.Discard()
+
+	entries, err := os.ReadDir(config.RootDirectory)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected nothing to be left after discarding, got %d entries (%v)", len(entries), err)
+	}
+}
+
+func TestAtomicDeployCommitBackup(t *testing.T) {
+	repo, config := atomicFixture(t, "222")
+	web := ArtifactConfig{Name: "web", ArtifactPrefix: "web", DeployLocation: "public"}
+
+	publicPath := filepath.Join(config.RootDirectory, "public")
+	if err := os.MkdirAll(publicPath, 0755); err != nil {
+		t.Fatalf("Failed to create deploy location: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(publicPath, "version.txt"), []byte("111"), 0644); err != nil {
+		t.Fatalf("Failed to write old version: %v", err)
+	}
+
+	atomic := newAtomicDeploy(&Deployer{Config: config, Repo: repo, Now: time.Now})
+	atomic.backup = NewBackup(config.Cleanup.BackupsPath(config.RootDirectory), config.RootDirectory, time.Now())
+	if err := atomic.Stage(web, "web-222.tar.gz"); err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if err := atomic.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	if version := readVersion(t, publicPath); version != "222" {
+		t.Errorf("Expected web version 222, got %q", version)
+	}
+	if version := readVersion(t, filepath.Join(atomic.backup.Dir(), "public")); version != "111" {
+		t.Errorf("Expected the old version in the backup, got %q", version)
+	}
+}
@@ -0,0 +1,262 @@
+package slarty
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditBuild    = "build"
+	AuditStore    = "store"
+	AuditRetrieve = "retrieve"
+	AuditDeploy   = "deploy"
+	AuditCleanup  = "cleanup"
+	AuditApprove  = "approve"
+)
+
+// Results recorded in the audit log
+const (
+	AuditSucceeded = "succeeded"
+	AuditFailed    = "failed"
+)
+
+// auditObjectPrefix is the start of the name of every audit log stored in the
+// repository. The part after the last "-" is the only part that changes, so an
+// {artifact} placeholder in the repository path expands to "audit" for all of them.
+const auditObjectPrefix = "audit/audit-"
+
+// AuditConfig configures the audit log, a record of who built, stored, retrieved,
+// deployed, approved and cleaned up what, and when
+type AuditConfig struct {
+	// File is the file records are appended to, relative to the root directory. It
+	// defaults to audit.jsonl in slarty's cache directory for the application.
+	File string `json:"file,omitempty"`
+	// Repository also stores the records of each command in the repository, so the
+	// history of every host can be read in one place
+	Repository bool `json:"repository,omitempty"`
+}
+
+// AuditRecord is a single audited operation on an artifact, asset or directory
+type AuditRecord struct {
+	Time         time.Time `json:"time"`
+	Action       string    `json:"action"`
+	Command      string    `json:"command,omitempty"`
+	User         string    `json:"user"`
+	Host         string    `json:"host,omitempty"`
+	Application  string    `json:"application,omitempty"`
+	Artifact     string    `json:"artifact,omitempty"`
+	ArtifactName string    `json:"artifact_name,omitempty"`
+	Hash         string    `json:"hash,omitempty"`
+	Location     string    `json:"location,omitempty"`
+	// Approver is who approved a deploy to a protected environment, when known
+	Approver string `json:"approver,omitempty"`
+	Result   string `json:"result"`
+	Error    string `json:"error,omitempty"`
+}
+
+// AuditUser returns who is running slarty: SLARTY_AUDIT_USER when it is set, then the
+// user that triggered a GitHub Actions or GitLab CI job, and otherwise the logged in
+// user
+func AuditUser() string {
+	for _, env := range []string{"SLARTY_AUDIT_USER", "GITHUB_ACTOR", "GITLAB_USER_LOGIN"} {
+		if value := strings.TrimSpace(os.Getenv(env)); value != "" {
+			return value
+		}
+	}
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// AppendAuditRecords appends records to the newline-delimited JSON audit log at path,
+// creating the file and its directory if needed
+func AppendAuditRecords(path string, records ...AuditRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	lines, err := marshalAuditRecords(records)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(lines); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// ReadAuditLog reads every record from the audit log at path, oldest first. A missing
+// file is an empty log. Lines that cannot be parsed are skipped.
+func ReadAuditLog(path string) ([]AuditRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	records, err := parseAuditRecords(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return records, nil
+}
+
+// StoreAuditRecords stores records in the repository as a log of their own, named
+// after the time of the first record and the host, so logs written by different
+// commands and hosts never replace each other
+func StoreAuditRecords(repo RepositoryAdapter, records []AuditRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	lines, err := marshalAuditRecords(records)
+	if err != nil {
+		return err
+	}
+
+	name := AuditObjectName(records[0].Time, records[0].Host, os.Getpid())
+	if err := repo.StoreArtifact(bytes.NewReader(lines), name); err != nil {
+		return fmt.Errorf("failed to store audit log %s: %w", name, err)
+	}
+	return nil
+}
+
+// unsafeAuditNameChars matches what may not appear in the host part of an audit log's
+// name, including "-", which must only separate the name's prefix from the rest
+var unsafeAuditNameChars = regexp.MustCompile(`[^A-Za-z0-9._]+`)
+
+// AuditObjectName returns the repository name of the audit log written at t by the
+// process pid on host. Names sort in the order the logs were written.
+func AuditObjectName(t time.Time, host string, pid int) string {
+	host = unsafeAuditNameChars.ReplaceAllString(host, "_")
+	if host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s%s_%s_%d.jsonl", auditObjectPrefix, t.UTC().Format("20060102T150405.000000000Z"), host, pid)
+}
+
+// ReadRepositoryAuditLog reads every audit log stored in the repository and returns
+// their records, oldest first
+func ReadRepositoryAuditLog(repo RepositoryAdapter) ([]AuditRecord, error) {
+	names, err := ListArtifacts(repo, auditObjectPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+
+	var records []AuditRecord
+	for _, name := range names {
+		var buf bytes.Buffer
+		if err := repo.RetrieveArtifact(name, &buf); err != nil {
+			return nil, fmt.Errorf("failed to read audit log %s: %w", name, err)
+		}
+		logRecords, err := parseAuditRecords(&buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log %s: %w", name, err)
+		}
+		records = append(records, logRecords...)
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+
+	return records, nil
+}
+
+// marshalAuditRecords writes records as newline-delimited JSON
+func marshalAuditRecords(records []AuditRecord) ([]byte, error) {
+	var lines []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	return lines, nil
+}
+
+// parseAuditRecords reads newline-delimited JSON records, skipping lines that cannot
+// be parsed
+func parseAuditRecords(r io.Reader) ([]AuditRecord, error) {
+	var records []AuditRecord
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// AuditQuery selects audit records. Empty fields match every record.
+type AuditQuery struct {
+	Action string
+	// Artifact matches the artifact or asset, or the full artifact name
+	Artifact string
+	// Hash matches hashes starting with it, so a short hash can be given
+	Hash   string
+	User   string
+	Result string
+	Since  time.Time
+	Until  time.Time
+}
+
+// Matches reports whether record is selected by the query
+func (q AuditQuery) Matches(record AuditRecord) bool {
+	switch {
+	case q.Action != "" && record.Action != q.Action:
+		return false
+	case q.Artifact != "" && record.Artifact != q.Artifact && record.ArtifactName != q.Artifact:
+		return false
+	case q.Hash != "" && (record.Hash == "" || !strings.HasPrefix(record.Hash, q.Hash)):
+		return false
+	case q.User != "" && record.User != q.User:
+		return false
+	case q.Result != "" && record.Result != q.Result:
+		return false
+	case !q.Since.IsZero() && record.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !record.Time.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// Filter returns the records selected by the query, in the order given
+func (q AuditQuery) Filter(records []AuditRecord) []AuditRecord {
+	var selected []AuditRecord
+	for _, record := range records {
+		if q.Matches(record) {
+			selected = append(selected, record)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,184 @@
+package slarty
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "audit.jsonl")
+
+	// A missing log is empty
+	records, err := ReadAuditLog(path)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Expected an empty log, got %v, %v", records, err)
+	}
+
+	start := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	if err := AppendAuditRecords(path,
+		AuditRecord{Time: start, Action: AuditBuild, Artifact: "api", Hash: "abc", User: "dana", Result: AuditSucceeded},
+		AuditRecord{Time: start.Add(time.Minute), Action: AuditStore, Artifact: "api", Hash: "abc", User: "dana", Result: AuditSucceeded},
+	); err != nil {
+		t.Fatalf("AppendAuditRecords failed: %v", err)
+	}
+	if err := AppendAuditRecords(path, AuditRecord{Time: start.Add(time.Hour), Action: AuditDeploy, Artifact: "api", Hash: "abc", User: "lee", Result: AuditFailed, Error: "disk full"}); err != nil {
+		t.Fatalf("AppendAuditRecords failed: %v", err)
+	}
+
+	// Corrupt lines are skipped rather than failing the whole log
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open log: %v", err)
+	}
+	file.WriteString("not json\n")
+	file.Close()
+
+	records, err = ReadAuditLog(path)
+	if err != nil {
+		t.Fatalf("ReadAuditLog failed: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	if records[2].User != "lee" || records[2].Error != "disk full" {
+		t.Errorf("Expected the failed deploy last, got %+v", records[2])
+	}
+}
+
+func TestAuditQuery(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := AuditRecord{Time: start, Action: AuditDeploy, Artifact: "api", ArtifactName: "api-abc123.tar.gz", Hash: "abc123", User: "dana", Result: AuditSucceeded}
+
+	for _, test := range []struct {
+		query    AuditQuery
+		expected bool
+	}{
+		{AuditQuery{}, true},
+		{AuditQuery{Action: AuditDeploy, Artifact: "api", User: "dana", Result: AuditSucceeded}, true},
+		{AuditQuery{Artifact: "api-abc123.tar.gz"}, true},
+		{AuditQuery{Hash: "abc"}, true},
+		{AuditQuery{Hash: "bc1"}, false},
+		{AuditQuery{Action: AuditBuild}, false},
+		{AuditQuery{Artifact: "web"}, false},
+		{AuditQuery{User: "lee"}, false},
+		{AuditQuery{Result: AuditFailed}, false},
+		{AuditQuery{Since: start}, true},
+		{AuditQuery{Since: start.Add(time.Second)}, false},
+		{AuditQuery{Until: start}, false},
+	} {
+		if got := test.query.Matches(record); got != test.expected {
+			t.Errorf("Expected %+v to match to be %v, got %v", test.query, test.expected, got)
+		}
+	}
+
+	// A record without a hash, such as a cleanup, never matches a hash
+	if (AuditQuery{Hash: "abc"}).Matches(AuditRecord{Action: AuditCleanup}) {
+		t.Errorf("Expected a record without a hash not to match one")
+	}
+}
+
+func TestAuditObjectName(t *testing.T) {
+	at := time.Date(2025, 3, 4, 5, 6, 7, 8, time.UTC)
+	name := AuditObjectName(at, "build-01.example.com", 42)
+	if name != "audit/audit-20250304T050607.000000008Z_build_01.example.com_42.jsonl" {
+		t.Errorf("Unexpected audit log name %s", name)
+	}
+	// Every log expands {artifact} the same way, so they all land in one place
+	if artifactPathName(name) != "audit" {
+		t.Errorf("Expected {artifact} to expand to audit, got %s", artifactPathName(name))
+	}
+	if err := validateArtifactName(name); err != nil {
+		t.Errorf("Expected a valid artifact name, got %v", err)
+	}
+}
+
+func TestRepositoryAuditLog(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "{artifact}")
+	repo := NewLocalRepositoryAdapter(root)
+
+	// Nothing has been stored yet
+	records, err := ReadRepositoryAuditLog(repo)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Expected no records, got %v, %v", records, err)
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := StoreAuditRecords(repo, []AuditRecord{
+		{Time: start.Add(time.Hour), Action: AuditDeploy, Host: "web-2", Result: AuditSucceeded},
+	}); err != nil {
+		t.Fatalf("StoreAuditRecords failed: %v", err)
+	}
+	if err := StoreAuditRecords(repo, []AuditRecord{
+		{Time: start, Action: AuditBuild, Host: "ci", Result: AuditSucceeded},
+		{Time: start.Add(2 * time.Hour), Action: AuditStore, Host: "ci", Result: AuditSucceeded},
+	}); err != nil {
+		t.Fatalf("StoreAuditRecords failed: %v", err)
+	}
+	if err := StoreAuditRecords(repo, nil); err != nil {
+		t.Fatalf("Expected storing no records to do nothing, got %v", err)
+	}
+
+	// An artifact in the same place is not mistaken for a log
+	if err := repo.StoreArtifact(strings.NewReader("x"), "audit/other-1.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+
+	records, err = ReadRepositoryAuditLog(repo)
+	if err != nil {
+		t.Fatalf("ReadRepositoryAuditLog failed: %v", err)
+	}
+	var actions []string
+	for _, record := range records {
+		actions = append(actions, record.Action)
+	}
+	if strings.Join(actions, ",") != "build,deploy,store" {
+		t.Errorf("Expected records from every log in time order, got %v", actions)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "audit", "audit")); err != nil {
+		t.Errorf("Expected the logs under the audit directory: %v", err)
+	}
+}
+
+func TestS3RepositoryAdapterListArtifacts(t *testing.T) {
+	stored := []string{"app/audit/audit-1_ci_1.jsonl", "app/audit/audit-2_ci_2.jsonl", "app/audit/audit-3_ci_3.jsonl", "app/api-1111.tar.gz"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.URL.Query().Get("prefix")
+		// Two keys a page, to see every page read
+		start := 0
+		if token := r.URL.Query().Get("continuation-token"); token != "" {
+			fmt.Sscan(token, &start)
+		}
+		var matching []string
+		for _, key := range stored {
+			if strings.HasPrefix(key, prefix) {
+				matching = append(matching, key)
+			}
+		}
+		end := min(start+2, len(matching))
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>builds</Name><Prefix>%s</Prefix><MaxKeys>2</MaxKeys><KeyCount>%d</KeyCount>`, prefix, end-start)
+		for _, key := range matching[start:end] {
+			fmt.Fprintf(w, `<Contents><Key>%s</Key></Contents>`, key)
+		}
+		if end < len(matching) {
+			fmt.Fprintf(w, `<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>`, end)
+		} else {
+			w.Write([]byte(`<IsTruncated>false</IsTruncated>`))
+		}
+		w.Write([]byte(`</ListBucketResult>`))
+	}))
+	defer server.Close()
+
+	names, err := newTestS3Adapter(t, server.URL, "app").ListArtifacts(auditObjectPrefix)
+	if err != nil {
+		t.Fatalf("ListArtifacts failed: %v", err)
+	}
+	if strings.Join(names, ",") != "audit/audit-1_ci_1.jsonl,audit/audit-2_ci_2.jsonl,audit/audit-3_ci_3.jsonl" {
+		t.Errorf("Unexpected artifacts %v", names)
+	}
+}
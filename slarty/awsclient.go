@@ -0,0 +1,69 @@
+package slarty
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Settings of the HTTP client AWS requests are sent with. Deploys make many requests
+// to the same bucket one after another, so idle connections are kept for reuse rather
+// than closed after each request.
+const (
+	awsMaxIdleConns        = 100
+	awsMaxIdleConnsPerHost = 32
+	awsIdleConnTimeout     = 90 * time.Second
+	awsConnectTimeout      = 10 * time.Second
+	awsKeepAlive           = 30 * time.Second
+	awsTLSHandshakeTimeout = 10 * time.Second
+	// awsResponseHeaderTimeout bounds the wait for a response once a request has been
+	// sent, so a stalled connection fails quickly instead of at s3OperationTimeout
+	awsResponseHeaderTimeout = time.Minute
+)
+
+// awsHTTPClient is shared by every AWS client slarty creates, so S3, KMS and Secrets
+// Manager requests, and repositories opened more than once, draw on one pool of
+// connections. It stays a BuildableClient so settings such as AWS_CA_BUNDLE can still
+// be applied to it, though those give the clients they apply to a pool of their own.
+var awsHTTPClient = awshttp.NewBuildableClient().
+	WithDialerOptions(func(d *net.Dialer) {
+		d.Timeout = awsConnectTimeout
+		d.KeepAlive = awsKeepAlive
+	}).
+	WithTransportOptions(func(t *http.Transport) {
+		t.MaxIdleConns = awsMaxIdleConns
+		t.MaxIdleConnsPerHost = awsMaxIdleConnsPerHost
+		t.IdleConnTimeout = awsIdleConnTimeout
+		t.TLSHandshakeTimeout = awsTLSHandshakeTimeout
+		t.ResponseHeaderTimeout = awsResponseHeaderTimeout
+	})
+
+// loadAWSConfig loads the AWS configuration for region, which may be empty to use the
+// default, and the shared config profile, if any. Credentials come from the AWS
+// credential chain unless credentials is given. Requests are sent with awsHTTPClient.
+func loadAWSConfig(ctx context.Context, region, profile string, credentials aws.CredentialsProvider) (aws.Config, error) {
+	configurers := []func(*config.LoadOptions) error{
+		config.WithHTTPClient(awsHTTPClient),
+	}
+	if region != "" {
+		configurers = append(configurers, config.WithRegion(region))
+	}
+	if profile != "" {
+		configurers = append(configurers, config.WithSharedConfigProfile(profile))
+	}
+	if credentials != nil {
+		configurers = append(configurers, config.WithCredentialsProvider(credentials))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, configurers...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,61 @@
+package slarty
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awscredentials "github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestAWSHTTPClient(t *testing.T) {
+	transport := awsHTTPClient.GetTransport()
+	if transport.MaxIdleConnsPerHost != awsMaxIdleConnsPerHost || transport.ResponseHeaderTimeout != awsResponseHeaderTimeout {
+		t.Errorf("Expected the transport to be tuned, got %d idle connections per host and a %v response header timeout", transport.MaxIdleConnsPerHost, transport.ResponseHeaderTimeout)
+	}
+}
+
+func TestAWSConnectionsReused(t *testing.T) {
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	credentials := awscredentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", "")
+	for i := 0; i < 2; i++ {
+		// Each repository has a client of its own, but they share connections
+		cfg, err := loadAWSConfig(context.Background(), "us-east-1", "", credentials)
+		if err != nil {
+			t.Fatalf("loadAWSConfig failed: %v", err)
+		}
+		client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(server.URL)
+			o.UsePathStyle = true
+		})
+		for j := 0; j < 3; j++ {
+			if _, err := client.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: aws.String("builds"), Key: aws.String("a.tar.gz")}); err != nil {
+				t.Fatalf("HeadObject failed: %v", err)
+			}
+		}
+	}
+	if n := connections.Load(); n != 1 {
+		t.Errorf("Expected one connection to be kept alive for every request, got %d", n)
+	}
+}
@@ -0,0 +1,190 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// DefaultKeepBackups is how many backups are kept when keep_backups is not set
+const DefaultKeepBackups = 5
+
+// backupTimeFormat names each run's backup directory so they sort oldest first
+const backupTimeFormat = "20060102-150405"
+
+// BackupsPath returns the directory backups are kept in: backup_directory, relative
+// to root unless it is absolute, or .slarty/backups under root
+func (c CleanupConfig) BackupsPath(root string) string {
+	if c.BackupDirectory == "" {
+		return filepath.Join(root, ".slarty", "backups")
+	}
+	if filepath.IsAbs(c.BackupDirectory) {
+		return c.BackupDirectory
+	}
+	return filepath.Join(root, c.BackupDirectory)
+}
+
+// Retention returns how many backups to keep
+func (c CleanupConfig) Retention() int {
+	if c.KeepBackups <= 0 {
+		return DefaultKeepBackups
+	}
+	return c.KeepBackups
+}
+
+// Backup moves the contents of deploy locations into a timestamped directory instead
+// of deleting them, so they can be put back by hand. Every location backed up in one
+// run goes into the same directory, under its path relative to the project root.
+type Backup struct {
+	backupsPath string
+	root        string
+	started     time.Time
+	dir         string
+	saved       map[string]bool
+}
+
+// NewBackup returns a backup for a run started at started, kept under backupsPath.
+// Nothing is created until the first location is saved.
+func NewBackup(backupsPath, root string, started time.Time) *Backup {
+	return &Backup{backupsPath: backupsPath, root: root, started: started, saved: make(map[string]bool)}
+}
+
+// Dir returns the run's backup directory, or "" if nothing has been backed up
+func (b *Backup) Dir() string {
+	return b.dir
+}
+
+// Save moves everything in dir into the backup and returns where it went. It returns
+// "" without moving anything if dir is missing or empty, or was already saved by this
+// run, so artifacts sharing a deploy location don't back up each other's new files.
+func (b *Backup) Save(dir string) (string, error) {
+	dir = filepath.Clean(dir)
+	if b.saved[dir] {
+		return "", nil
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	b.saved[dir] = true
+
+	backupsPath, _ := filepath.Abs(b.backupsPath)
+	var dest string
+	for _, entry := range entries {
+		source := filepath.Join(dir, entry.Name())
+		// Never move the backups into themselves
+		if abs, err := filepath.Abs(source); err == nil && pathContains(abs, backupsPath) {
+			continue
+		}
+		if dest == "" {
+			if dest, err = b.destination(dir); err != nil {
+				return "", err
+			}
+		}
+		if err := moveForBackup(source, filepath.Join(dest, entry.Name())); err != nil {
+			return "", err
+		}
+	}
+	return dest, nil
+}
+
+// SaveDirectory moves the directory from, which holds what used to be at dir, into the
+// backup as dir's contents and returns where it went
+func (b *Backup) SaveDirectory(dir, from string) (string, error) {
+	dir = filepath.Clean(dir)
+	b.saved[dir] = true
+	dest, err := b.destination(dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Remove(dest); err != nil {
+		return "", err
+	}
+	if err := moveForBackup(from, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// destination creates the directory dir's contents are backed up to, creating the
+// run's backup directory first if needed
+func (b *Backup) destination(dir string) (string, error) {
+	if b.dir == "" {
+		if err := os.MkdirAll(b.backupsPath, 0755); err != nil {
+			return "", fmt.Errorf("failed to create backup directory: %w", err)
+		}
+		name := b.started.Format(backupTimeFormat)
+		for i := 2; ; i++ {
+			err := os.Mkdir(filepath.Join(b.backupsPath, name), 0755)
+			if err == nil {
+				break
+			}
+			if !os.IsExist(err) {
+				return "", fmt.Errorf("failed to create backup directory: %w", err)
+			}
+			name = fmt.Sprintf("%s-%d", b.started.Format(backupTimeFormat), i)
+		}
+		b.dir = filepath.Join(b.backupsPath, name)
+	}
+
+	rel, err := filepath.Rel(b.root, dir)
+	if err != nil || !filepath.IsLocal(rel) {
+		// A location outside the project root is kept under its absolute path
+		abs, _ := filepath.Abs(dir)
+		rel = filepath.Join("outside-root", abs)
+	}
+	dest := filepath.Join(b.dir, rel)
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return dest, nil
+}
+
+// moveForBackup renames source to dest, explaining the failure when the backup
+// directory is on another filesystem
+func moveForBackup(source, dest string) error {
+	err := os.Rename(source, dest)
+	if errors.Is(err, syscall.EXDEV) {
+		return fmt.Errorf("failed to back up %s: the backup directory must be on the same filesystem", source)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to back up %s: %w", source, err)
+	}
+	return nil
+}
+
+// PruneBackups removes the oldest backups in backupsPath so that at most keep remain
+// and returns the names of those removed
+func PruneBackups(backupsPath string, keep int) ([]string, error) {
+	entries, err := os.ReadDir(backupsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var removed []string
+	for i := 0; i < len(names)-keep; i++ {
+		if err := os.RemoveAll(filepath.Join(backupsPath, names[i])); err != nil {
+			return removed, fmt.Errorf("failed to remove backup %s: %w", names[i], err)
+		}
+		removed = append(removed, names[i])
+	}
+	return removed, nil
+}
@@ -0,0 +1,134 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCleanupConfigBackups(t *testing.T) {
+	if got := (CleanupConfig{}).BackupsPath("/srv/app"); got != "/srv/app/.slarty/backups" {
+		t.Errorf("Expected the default backups path, got %s", got)
+	}
+	if got := (CleanupConfig{BackupDirectory: "backups"}).BackupsPath("/srv/app"); got != "/srv/app/backups" {
+		t.Errorf("Expected a path relative to the root, got %s", got)
+	}
+	if got := (CleanupConfig{BackupDirectory: "/var/backups/app"}).BackupsPath("/srv/app"); got != "/var/backups/app" {
+		t.Errorf("Expected an absolute path to be kept, got %s", got)
+	}
+	if got := (CleanupConfig{}).Retention(); got != DefaultKeepBackups {
+		t.Errorf("Expected the default retention, got %d", got)
+	}
+	if got := (CleanupConfig{KeepBackups: 2}).Retention(); got != 2 {
+		t.Errorf("Expected keep_backups to be used, got %d", got)
+	}
+}
+
+func TestBackupSave(t *testing.T) {
+	root := t.TempDir()
+	deployPath := filepath.Join(root, "public", "app")
+	if err := os.MkdirAll(filepath.Join(deployPath, "css"), 0755); err != nil {
+		t.Fatalf("Failed to create deploy location: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(deployPath, "css", "site.css"), []byte("body {}"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	backupsPath := filepath.Join(root, ".slarty", "backups")
+	started := time.Date(2025, 1, 10, 14, 22, 1, 0, time.UTC)
+	backup := NewBackup(backupsPath, root, started)
+
+	if dest, err := backup.Save(filepath.Join(root, "missing")); err != nil || dest != "" {
+		t.Errorf("Expected a missing location to be skipped, got %q, %v", dest, err)
+	}
+	if backup.Dir() != "" {
+		t.Errorf("Expected nothing to be created for a missing location, got %s", backup.Dir())
+	}
+
+	dest, err := backup.Save(deployPath)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	want := filepath.Join(backupsPath, "20250110-142201", "public", "app")
+	if dest != want {
+		t.Errorf("Expected the backup in %s, got %s", want, dest)
+	}
+	if content, err := os.ReadFile(filepath.Join(want, "css", "site.css")); err != nil || string(content) != "body {}" {
+		t.Errorf("Expected the old contents in the backup, got %q, %v", content, err)
+	}
+	if entries, _ := os.ReadDir(deployPath); len(entries) != 0 {
+		t.Errorf("Expected the deploy location to be emptied, got %d entries", len(entries))
+	}
+
+	// A location saved once is left alone, so a second artifact does not back up
+	// the files the first one deployed
+	if err := os.WriteFile(filepath.Join(deployPath, "index.html"), []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if dest, err := backup.Save(deployPath); err != nil || dest != "" {
+		t.Errorf("Expected a second save to do nothing, got %q, %v", dest, err)
+	}
+	if _, err := os.Stat(filepath.Join(deployPath, "index.html")); err != nil {
+		t.Errorf("Expected the new file to be kept: %v", err)
+	}
+
+	// Another run in the same second gets its own directory
+	other := NewBackup(backupsPath, root, started)
+	dest, err = other.Save(deployPath)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if want := filepath.Join(backupsPath, "20250110-142201-2", "public", "app"); dest != want {
+		t.Errorf("Expected the backup in %s, got %s", want, dest)
+	}
+}
+
+func TestBackupSaveDirectory(t *testing.T) {
+	root := t.TempDir()
+	old := filepath.Join(root, "public.previous")
+	if err := os.MkdirAll(old, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(old, "index.html"), []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	backup := NewBackup(filepath.Join(root, "backups"), root, time.Date(2025, 1, 10, 14, 22, 1, 0, time.UTC))
+	dest, err := backup.SaveDirectory(filepath.Join(root, "public"), old)
+	if err != nil {
+		t.Fatalf("SaveDirectory failed: %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(dest, "index.html")); err != nil || string(content) != "old" {
+		t.Errorf("Expected the old contents in the backup, got %q, %v", content, err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be moved, got %v", old, err)
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	backupsPath := t.TempDir()
+	for _, name := range []string{"20250110-142201", "20250108-090000", "20250109-120000", "20250110-142201-2"} {
+		if err := os.Mkdir(filepath.Join(backupsPath, name), 0755); err != nil {
+			t.Fatalf("Failed to create backup: %v", err)
+		}
+	}
+
+	removed, err := PruneBackups(backupsPath, 2)
+	if err != nil {
+		t.Fatalf("PruneBackups failed: %v", err)
+	}
+	if want := []string{"20250108-090000", "20250109-120000"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("Expected %v to be removed, got %v", want, removed)
+	}
+	entries, _ := os.ReadDir(backupsPath)
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 backups to be kept, got %d", len(entries))
+	}
+
+	if removed, err := PruneBackups(filepath.Join(backupsPath, "missing"), 2); err != nil || len(removed) != 0 {
+		t.Errorf("Expected a missing backups directory to be fine, got %v, %v", removed, err)
+	}
+}
@@ -0,0 +1,204 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The statuses of a BuildResult, which are also those of a RunResult
+const (
+	BuildBuilt   = "built"
+	BuildSkipped = "skipped"
+	BuildFailed  = "failed"
+)
+
+// BuildOptions are how BuildAll builds artifacts
+type BuildOptions struct {
+	// Force builds every artifact, even those already in the repository
+	Force bool
+	// FailFast stops at the first build that fails, leaving the rest unbuilt
+	FailFast bool
+	// MarkLatest records each artifact, built or already stored, as the latest build
+	// for deploying with --latest
+	MarkLatest bool
+	// Labels are attached to every artifact stored
+	Labels map[string]string
+}
+
+// BuildResult is what BuildAll did with an artifact
+type BuildResult struct {
+	Artifact     ArtifactConfig
+	ArtifactName string
+	// Status is BuildBuilt, BuildSkipped or BuildFailed
+	Status string
+	// AlreadyStored reports whether the artifact was in the repository before the build
+	AlreadyStored bool
+	// Size is the size of the stored archive
+	Size int64
+	// BuildDuration is how long the build command ran, and Duration how long the
+	// artifact took to build and store altogether
+	BuildDuration time.Duration
+	Duration      time.Duration
+	Err           error
+}
+
+// BuildAll builds the artifacts that are not in the repository yet, or all of them
+// with Force, storing and labelling each archive, and returns what became of each
+// artifact in order. A failed build does not stop the others unless FailFast is set,
+// when the artifacts after it are left out of the results. The error is only for
+// problems finding out what to build, such as a hash that cannot be worked out.
+func (b *Builder) BuildAll(artifacts []ArtifactConfig, opts BuildOptions) ([]BuildResult, error) {
+	b.observer().OnBuildsStart(artifacts)
+
+	// Get the artifact names first, so the repository can look them up in bulk
+	names := make([]string, len(artifacts))
+	var archives []string
+	for i, artifact := range artifacts {
+		artifactName, err := b.ArtifactName(artifact)
+		if err != nil {
+			return nil, err
+		}
+		names[i] = artifactName
+		if !artifact.IsDocker() {
+			archives = append(archives, artifactName)
+		}
+	}
+	PrefetchArtifacts(b.Repo, archives)
+
+	results := make([]BuildResult, len(artifacts))
+	needed := 0
+	for i, artifact := range artifacts {
+		var checker ArtifactChecker = b.Repo
+		if artifact.IsDocker() {
+			checker = b.Config.DockerAdapter()
+		}
+		exists, err := checker.ArtifactExists(names[i])
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			if err := CheckHashCollision(b.Repo, b.Config, artifact, names[i]); err != nil {
+				return nil, err
+			}
+		}
+
+		results[i] = BuildResult{Artifact: artifact, ArtifactName: names[i], Status: BuildSkipped, AlreadyStored: exists}
+		build := opts.Force || !exists
+		if build {
+			needed++
+		}
+		b.observer().OnBuildChecked(artifact, names[i], build)
+	}
+
+	built := 0
+	for i := range results {
+		result := &results[i]
+		if !opts.Force && result.AlreadyStored {
+			if opts.MarkLatest {
+				if err := b.MarkLatest(result.Artifact, result.ArtifactName); err != nil {
+					result.Status, result.Err = BuildFailed, err
+				}
+			}
+			b.observer().OnArtifactComplete(*result, built, needed)
+			continue
+		}
+
+		b.observer().OnArtifactStart(result.Artifact, result.ArtifactName)
+		started := b.Now()
+		err := b.buildAndStore(result, opts.Labels)
+		if err == nil && opts.MarkLatest {
+			err = b.MarkLatest(result.Artifact, result.ArtifactName)
+		}
+		result.Duration = b.Now().Sub(started).Round(time.Millisecond)
+		result.Status = BuildBuilt
+		if err != nil {
+			result.Status, result.Err = BuildFailed, err
+		} else {
+			built++
+		}
+		b.observer().OnArtifactComplete(*result, built, needed)
+
+		if err != nil && opts.FailFast {
+			b.observer().OnMessage("\n-- Stopping early because --fail-fast is set")
+			results = results[:i+1]
+			break
+		}
+	}
+
+	b.observer().OnBuildsComplete(results, needed)
+	return results, nil
+}
+
+// buildAndStore runs an artifact's build command, archives its output directories and
+// stores the result in the repository with the labels. When the artifact is already
+// stored, as on a forced rebuild, the upload is skipped if the content is unchanged.
+// Docker artifacts are built as an image and pushed to their registry instead.
+func (b *Builder) buildAndStore(result *BuildResult, labels map[string]string) error {
+	artifact, artifactName := result.Artifact, result.ArtifactName
+	if artifact.IsDocker() {
+		var err error
+		result.BuildDuration, err = b.buildImage(artifact, artifactName)
+		return err
+	}
+
+	output, err := b.Run(artifact)
+	if err != nil {
+		return err
+	}
+	result.BuildDuration = output.Duration
+
+	// Archive the output directories straight into the repository
+	result.Size, err = b.Store(output, artifactName, result.AlreadyStored)
+	if errors.Is(err, ErrUnderSizeBudget) {
+		return fmt.Errorf("%w; pass --allow-empty to store it anyway", err)
+	}
+	if err != nil {
+		return err
+	}
+	return b.labelArchive(artifact, artifactName, labels)
+}
+
+// buildImage builds a docker artifact's image tagged as imageRef and pushes it to the
+// registry
+func (b *Builder) buildImage(artifact ArtifactConfig, imageRef string) (time.Duration, error) {
+	docker := b.Config.DockerAdapter()
+
+	b.observer().OnBuildStart(artifact)
+	started := b.Now()
+	err := docker.Build(artifact, b.Config.RootDirectory, imageRef)
+	duration := b.Now().Sub(started)
+	b.observer().OnBuildComplete(artifact, duration, err)
+	if err != nil {
+		return duration, fmt.Errorf("image build failed: %w", err)
+	}
+
+	b.observer().OnUploadStart(imageRef)
+	started = b.Now()
+	err = docker.PushImage(imageRef, imageRef)
+	b.observer().OnUploadComplete(imageRef, 0, b.Now().Sub(started), err)
+	if err != nil {
+		return duration, fmt.Errorf("failed to push image to registry: %w", err)
+	}
+	return duration, nil
+}
+
+// labelArchive attaches the labels to a stored archive, along with the full hash when
+// hashes are shortened, so a later build can tell a collision from the same code
+func (b *Builder) labelArchive(artifact ArtifactConfig, artifactName string, labels map[string]string) error {
+	storedLabels, err := WithFullHashLabel(b.Config, artifact, labels)
+	if err != nil {
+		return err
+	}
+	if len(storedLabels) == 0 {
+		return nil
+	}
+	err = LabelArtifact(b.Repo, artifactName, storedLabels)
+	if errors.Is(err, ErrLabelsUnsupported) && len(labels) == 0 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to label %s: %w", artifactName, err)
+	}
+	return nil
+}
@@ -0,0 +1,494 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dstockto/slarty/slarty/archive"
+)
+
+// Builder runs artifacts' build commands and stores archives of their output in a
+// repository. Its fields can be swapped out, so a build can be run against an in
+// memory repository, a runner that starts no processes, or a fixed clock, and its
+// progress is reported to its Observer.
+type Builder struct {
+	Config *ArtifactsConfig
+	Repo   RepositoryAdapter
+	// OpenCache opens the local build cache each archive is also stored in. No cache
+	// is kept when it is nil.
+	OpenCache func() (RepositoryAdapter, error)
+	Runner    CommandRunner
+	// Namer names artifacts after their code, GetArtifactName when it is nil
+	Namer ArtifactNamer
+	Now   func() time.Time
+	// Observer is told how the build progresses, and ignores it when nil
+	Observer Observer
+	// Reproducible archives leave out timestamps and file ownership
+	Reproducible bool
+	// AllowEmpty stores archives with no files or smaller than min_size
+	AllowEmpty bool
+	// SpaceDirs are the directories on this machine, besides the temp directory, an
+	// archive is written to, which must have room for it
+	SpaceDirs []string
+	// SkipSpaceCheck archives without first checking there is room for the archive. It
+	// is set by --no-space-check.
+	SkipSpaceCheck bool
+}
+
+// NewBuilder returns a Builder storing archives in repo, running commands with sh and
+// printing its progress to stdout and stderr
+func NewBuilder(config *ArtifactsConfig, repo RepositoryAdapter) *Builder {
+	return &Builder{
+		Config:   config,
+		Repo:     repo,
+		Runner:   ShellRunner{},
+		Now:      time.Now,
+		Observer: &PrintObserver{},
+	}
+}
+
+// observer returns the Observer to report to
+func (b *Builder) observer() Observer {
+	if b.Observer == nil {
+		return NopObserver{}
+	}
+	return b.Observer
+}
+
+// ArtifactName returns the name of the artifact's archive for its current code
+func (b *Builder) ArtifactName(artifact ArtifactConfig) (string, error) {
+	if b.Namer != nil {
+		return b.Namer(artifact.Name, b.Config)
+	}
+	return GetArtifactName(artifact.Name, b.Config)
+}
+
+// BuildOutput is what an artifact's build left to archive
+type BuildOutput struct {
+	Artifact ArtifactConfig
+	Sources  []archive.Source
+	Filter   *OutputFilter
+	Archiver archive.Archiver
+	Budget   SizeBudget
+	// Duration is how long the build command took
+	Duration time.Duration
+}
+
+// Run runs an artifact's build command, after emptying its output directories when
+// it has clean_output, and checks the output is worth archiving: everything the
+// artifact expects is there and, unless AllowEmpty is set, there is at least one file.
+func (b *Builder) Run(artifact ArtifactConfig) (*BuildOutput, error) {
+	budget, err := artifact.SizeBudget()
+	if err != nil {
+		return nil, err
+	}
+	if b.AllowEmpty {
+		budget.Min = 0
+	}
+	filter, err := artifact.OutputFilter()
+	if err != nil {
+		return nil, err
+	}
+	sources, err := b.OutputSources(artifact)
+	if err != nil {
+		return nil, err
+	}
+	archiver, err := artifact.Archiver()
+	if err != nil {
+		return nil, err
+	}
+	expectations, err := artifact.OutputExpectations()
+	if err != nil {
+		return nil, err
+	}
+
+	if artifact.CleanOutput {
+		if err := b.cleanOutput(sources); err != nil {
+			return nil, err
+		}
+	}
+
+	// Execute the build command
+	b.observer().OnBuildStart(artifact)
+	started := b.Now()
+	err = b.runCommand(artifact)
+	b.observer().OnBuildComplete(artifact, b.Now().Sub(started), err)
+	if err != nil {
+		return nil, fmt.Errorf("%w: build command failed: %w", ErrBuildFailed, err)
+	}
+	output := &BuildOutput{
+		Artifact: artifact,
+		Sources:  sources,
+		Filter:   filter,
+		Archiver: archiver,
+		Budget:   budget,
+		Duration: b.Now().Sub(started),
+	}
+
+	// A command can exit cleanly without building anything, so check the output is
+	// there before archiving it
+	if err := checkExpectedOutputs(artifact, expectations, sources, filter); err != nil {
+		return nil, err
+	}
+
+	// A stored artifact marks its hash as built, so an empty one would never be
+	// replaced by a proper build
+	if !b.AllowEmpty {
+		files, err := archivedFileCount(sources, filter)
+		if err != nil {
+			return nil, err
+		}
+		if files == 0 {
+			return nil, fmt.Errorf("%w: %s has no files, and storing an empty artifact would stop this hash from being rebuilt; pass --allow-empty to store it anyway", ErrEmptyArchive, artifact.OutputDirectory)
+		}
+	}
+
+	return output, nil
+}
+
+// runCommand runs the artifact's build command from the root directory, inside the
+// artifact's container when it has one
+func (b *Builder) runCommand(artifact ArtifactConfig) error {
+	if artifact.Container != nil {
+		return b.Config.DockerAdapter().RunInContainer(*artifact.Container, b.Config.RootDirectory, artifact.Command, artifact.Env)
+	}
+	return b.Runner.RunCommand(b.Config.RootDirectory, artifact.Command, artifact.Env)
+}
+
+// Store archives a build's output into the repository as artifactName and returns the
+// size of the archive. The archive is streamed straight into the repository unless it
+// is also cached, or the artifact is already stored, as on a forced rebuild, when it is
+// written to a temporary file first so an unchanged archive is not uploaded again.
+func (b *Builder) Store(output *BuildOutput, artifactName string, alreadyStored bool) (int64, error) {
+	tempCopy := b.OpenCache != nil || alreadyStored
+	if err := b.checkArchiveSpace(output.Sources, tempCopy); err != nil {
+		return 0, err
+	}
+	if tempCopy {
+		return b.StoreArchiveFile(output.Archiver, output.Sources, output.Filter, artifactName, alreadyStored, output.Budget)
+	}
+	return b.StoreArchive(output.Archiver, output.Sources, output.Filter, artifactName, output.Budget)
+}
+
+// StoreArchive archives the files filter picks from the sources with archiver into the
+// repository as it is written, without a temporary copy on disk, and returns the size
+// of the archive. An archive that grows past the budget's maximum is abandoned before
+// it is stored.
+func (b *Builder) StoreArchive(archiver archive.Archiver, sources []archive.Source, filter *OutputFilter, artifactName string, budget SizeBudget) (int64, error) {
+	b.observer().OnUploadStart(artifactName)
+	started := b.Now()
+	pr, pw := io.Pipe()
+	counter := &countingWriter{w: pw, budget: budget, progress: func(sent int64) {
+		b.observer().OnUploadProgress(artifactName, sent)
+	}}
+	archived := make(chan error, 1)
+	go func() {
+		// Failing the stream before its end keeps a too small archive out of the
+		// repository as well
+		err := archiver.Write(counter, sources, b.WriteOptions(filter))
+		if err == nil {
+			err = budget.CheckMinimum(counter.n)
+		}
+		pw.CloseWithError(err)
+		archived <- err
+	}()
+
+	storeErr := b.Repo.StoreArtifact(pr, artifactName)
+	// Stop the archiver if the repository gave up part way through
+	pr.CloseWithError(storeErr)
+	// A write error that is only the repository's own failure echoed back is not an
+	// archiving problem
+	archiveErr := <-archived
+	var err error
+	switch {
+	case errors.Is(archiveErr, ErrOverSizeBudget) || errors.Is(archiveErr, ErrUnderSizeBudget):
+		err = archiveErr
+	case archiveErr != nil && !errors.Is(archiveErr, storeErr):
+		err = fmt.Errorf("failed to archive output directory: %w", archiveErr)
+	case storeErr != nil:
+		err = fmt.Errorf("failed to store artifact in repository: %w", storeErr)
+	}
+	b.observer().OnUploadComplete(artifactName, counter.n, b.Now().Sub(started), err)
+	if err != nil {
+		return 0, err
+	}
+
+	return counter.n, nil
+}
+
+// StoreArchiveFile archives the files filter picks from the sources with archiver to a
+// temporary file and stores it in the repository, and in the local build cache when
+// there is one. Failing to update the cache is only a warning. When the artifact is
+// already stored and the repository can compare content, an identical archive is not
+// uploaded again. An archive larger than the budget's maximum is not stored at all.
+func (b *Builder) StoreArchiveFile(archiver archive.Archiver, sources []archive.Source, filter *OutputFilter, artifactName string, alreadyStored bool, budget SizeBudget) (int64, error) {
+	tempArchiveFile, err := CreateTempArchive(b.Config.RootDirectory, "slarty-*."+archiver.Extension())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary %s file: %w", archiver.Name(), err)
+	}
+	tempArchivePath := tempArchiveFile.Name()
+	tempArchiveFile.Close() // Close the file so we can reopen it for archiving
+	defer os.Remove(tempArchivePath)
+
+	if err := archive.CreateFile(archiver, tempArchivePath, sources, b.WriteOptions(filter)); err != nil {
+		return 0, fmt.Errorf("failed to archive output directory: %w", err)
+	}
+	info, err := os.Stat(tempArchivePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive output directory: %w", err)
+	}
+	if err := budget.CheckSize(info.Size()); err != nil {
+		return 0, fmt.Errorf("%w: the archive is %s", err, FormatBytes(info.Size()))
+	}
+	if err := budget.CheckMinimum(info.Size()); err != nil {
+		return 0, err
+	}
+
+	same := false
+	if comparer, ok := b.Repo.(ArtifactComparer); ok && alreadyStored {
+		same, err = sameContent(comparer, tempArchivePath, artifactName)
+		if err != nil {
+			b.observer().OnWarning(fmt.Sprintf("failed to compare %s with the repository: %v", artifactName, err))
+		}
+	}
+	if same {
+		b.observer().OnMessage(fmt.Sprintf("%s already present, skipped upload", artifactName))
+	} else if err := b.uploadFile(tempArchivePath, artifactName); err != nil {
+		return 0, fmt.Errorf("failed to store artifact in repository: %w", err)
+	}
+
+	if b.OpenCache == nil {
+		return info.Size(), nil
+	}
+
+	// Keep a copy in the local build cache so restore can skip the build later
+	cache, err := b.OpenCache()
+	if err == nil {
+		err = StoreArtifactFile(cache, tempArchivePath, artifactName)
+	}
+	if err != nil {
+		b.observer().OnWarning(fmt.Sprintf("failed to add %s to the local build cache: %v", artifactName, err))
+	}
+
+	return info.Size(), nil
+}
+
+// uploadFile stores the archive at path in the repository, reporting its progress
+func (b *Builder) uploadFile(path, artifactName string) error {
+	b.observer().OnUploadStart(artifactName)
+	started := b.Now()
+	source, err := os.Open(path)
+	if err != nil {
+		err = fmt.Errorf("failed to open artifact file: %w", err)
+		b.observer().OnUploadComplete(artifactName, 0, b.Now().Sub(started), err)
+		return err
+	}
+	defer source.Close()
+
+	counter := &countingReader{r: source, progress: func(sent int64) {
+		b.observer().OnUploadProgress(artifactName, sent)
+	}}
+	err = b.Repo.StoreArtifact(counter, artifactName)
+	b.observer().OnUploadComplete(artifactName, counter.n, b.Now().Sub(started), err)
+	return err
+}
+
+// MarkLatest records artifactName as the latest build of the artifact in the
+// repository, for deploying with --latest
+func (b *Builder) MarkLatest(artifact ArtifactConfig, artifactName string) error {
+	pointer := LatestPointer{
+		ArtifactName: artifactName,
+		Hash:         HashFromArtifactName(artifact, artifactName, b.Config),
+		UpdatedAt:    b.Now().UTC(),
+	}
+	// The commit is only informational, so a build outside a git checkout leaves it out
+	if commit, err := HeadCommit(b.Config.RootDirectory); err == nil {
+		pointer.Commit = commit
+	}
+
+	if err := WriteLatestPointer(b.Repo, artifact.ArtifactPrefix, pointer); err != nil {
+		return err
+	}
+	b.observer().OnMessage(fmt.Sprintf("-- Marked %s as latest for %s", artifactName, artifact.Name))
+	return nil
+}
+
+// OutputSources returns the artifact's output directories as archive sources
+func (b *Builder) OutputSources(artifact ArtifactConfig) ([]archive.Source, error) {
+	return b.Config.OutputSources(artifact)
+}
+
+// OutputSources returns the artifact's output directories, under the root directory,
+// as archive sources
+func (ac *ArtifactsConfig) OutputSources(artifact ArtifactConfig) ([]archive.Source, error) {
+	if len(artifact.OutputDirectory) == 0 {
+		return nil, fmt.Errorf("%s has no output_directory", artifact.Name)
+	}
+	prefixes, err := artifact.OutputDirectory.ArchivePrefixes()
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]archive.Source, len(artifact.OutputDirectory))
+	for i, dir := range artifact.OutputDirectory {
+		sources[i] = archive.Source{Dir: filepath.Join(ac.RootDirectory, dir), Prefix: prefixes[i]}
+	}
+	return sources, nil
+}
+
+// WriteOptions returns how the files filter picks are archived: never with slarty's
+// own working files, which includes the archive itself when the output directory is
+// the project root, and without timestamps or ownership when Reproducible is set. A
+// nil filter archives everything.
+func (b *Builder) WriteOptions(filter *OutputFilter) archive.WriteOptions {
+	return archive.WriteOptions{Filter: filter, Reproducible: b.Reproducible, SkipDir: WorkDirName}
+}
+
+// cleanOutput empties the output directories before a build, so files left there by
+// an earlier build cannot end up in the archive. A directory that does not exist yet
+// is already clean. Directories the cleanup guardrails refuse, such as one outside
+// the root directory or in the denylist, fail the build instead.
+func (b *Builder) cleanOutput(sources []archive.Source) error {
+	for _, source := range sources {
+		if err := b.Config.Cleanup.CheckCleanupPath(source.Dir, b.Config.RootDirectory, false); err != nil {
+			return fmt.Errorf("refusing to clean output directory: %w", err)
+		}
+		if err := RemoveContents(source.Dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to clean output directory: %w", err)
+		}
+		b.observer().OnMessage(fmt.Sprintf("-- Cleaned %s", source.Dir))
+	}
+	return nil
+}
+
+// checkExpectedOutputs fails when something the artifact expects is not among the
+// files that would be archived
+func checkExpectedOutputs(artifact ArtifactConfig, expectations *OutputExpectations, sources []archive.Source, filter *OutputFilter) error {
+	dirs := make([]string, len(sources))
+	for i, source := range sources {
+		dirs[i] = source.Dir
+	}
+	missing, err := expectations.Missing(dirs, filter)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: the build command succeeded but %s has nothing matching expect %s", ErrBuildFailed, artifact.OutputDirectory, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// archivedFileCount counts the files, other than directories, that archiving the
+// sources would include. A directory that does not exist holds no files.
+func archivedFileCount(sources []archive.Source, filter *OutputFilter) (int, error) {
+	files := 0
+	for _, source := range sources {
+		err := filepath.WalkDir(source.Dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if path == source.Dir && errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if path == source.Dir {
+				return nil
+			}
+			relPath, err := filepath.Rel(source.Dir, path)
+			if err != nil {
+				return err
+			}
+			slashPath := filepath.ToSlash(relPath)
+			if entry.IsDir() {
+				if entry.Name() == WorkDirName || filter.Excludes(slashPath) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filter.Includes(slashPath) {
+				files++
+				return fs.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to read output directory: %w", err)
+		}
+		if files > 0 {
+			break
+		}
+	}
+	return files, nil
+}
+
+// checkArchiveSpace checks there is room for the archive of the sources wherever it
+// is written on this machine, the temp directory when tempCopy is set and SpaceDirs,
+// unless SkipSpaceCheck is set.
+// Compression only makes an archive smaller than the files in it, apart from a header
+// of up to 1 KiB for each file.
+func (b *Builder) checkArchiveSpace(sources []archive.Source, tempCopy bool) error {
+	if b.SkipSpaceCheck {
+		return nil
+	}
+	var needed int64
+	var sourceDirs []string
+	for _, source := range sources {
+		files, size, err := DirectoryUsage(source.Dir)
+		if err != nil {
+			// Archiving reports the problem with the output directory
+			return nil
+		}
+		needed += size + int64(files)<<10
+		sourceDirs = append(sourceDirs, source.Dir)
+	}
+	what := "archiving " + strings.Join(sourceDirs, ", ")
+
+	var dirs []string
+	if tempCopy {
+		dirs = append(dirs, filepath.Join(b.Config.RootDirectory, WorkDirName, "tmp"))
+	}
+	dirs = append(dirs, b.SpaceDirs...)
+	for _, dir := range dirs {
+		if err := CheckFreeSpace(dir, needed, what); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameContent reports whether the archive at path is identical to the stored artifact
+func sameContent(comparer ArtifactComparer, path, artifactName string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	return comparer.SameContent(artifactName, file)
+}
+
+// countingWriter counts the bytes written through it, failing a write that would take
+// the count past the budget's maximum
+type countingWriter struct {
+	w      io.Writer
+	n      int64
+	budget SizeBudget
+	// progress, when it is set, is called with the bytes written so far
+	progress func(int64)
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if err := c.budget.CheckSize(c.n + int64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if c.progress != nil && n > 0 {
+		c.progress(c.n)
+	}
+	return n, err
+}
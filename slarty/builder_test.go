@@ -0,0 +1,188 @@
+package slarty
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// outputRunner stands in for a build command, writing files into the root directory
+// instead of starting a process
+type outputRunner struct {
+	files    map[string]string
+	commands []string
+}
+
+func (r *outputRunner) RunCommand(dir, command string, env map[string]string) error {
+	r.commands = append(r.commands, command)
+	for name, content := range r.files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// testBuilder returns a builder for root storing into a local repository, with a
+// fixed clock and its output kept in out
+func testBuilder(t *testing.T, root string, runner CommandRunner, out *bytes.Buffer) *Builder {
+	t.Helper()
+	builder := NewBuilder(&ArtifactsConfig{RootDirectory: root}, NewLocalRepositoryAdapter(t.TempDir()))
+	builder.Runner = runner
+	builder.Now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	builder.Observer = &PrintObserver{Out: out, Err: out}
+	return builder
+}
+
+func TestBuilderRunAndStore(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "dist"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "dist", "stale.js"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	runner := &outputRunner{files: map[string]string{"dist/app.js": "console.log(1)"}}
+	builder := testBuilder(t, root, runner, &out)
+	artifact := ArtifactConfig{Name: "web", ArtifactPrefix: "web", Command: "npm run build", OutputDirectory: OutputDirectories{"dist"}, CleanOutput: true}
+
+	output, err := builder.Run(artifact)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(runner.commands) != 1 || runner.commands[0] != "npm run build" {
+		t.Errorf("Expected the build command to be run once, got %v", runner.commands)
+	}
+	if _, err := os.Stat(filepath.Join(root, "dist", "stale.js")); !os.IsNotExist(err) {
+		t.Errorf("Expected clean_output to remove the stale file, got %v", err)
+	}
+
+	size, err := builder.Store(output, "web-abc123.tar.gz", false)
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if exists, _ := builder.Repo.ArtifactExists("web-abc123.tar.gz"); !exists || size == 0 {
+		t.Errorf("Expected the archive to be stored, got exists=%v size=%d", exists, size)
+	}
+
+	if err := builder.MarkLatest(artifact, "web-abc123.tar.gz"); err != nil {
+		t.Fatalf("MarkLatest failed: %v", err)
+	}
+	pointer, err := ReadLatestPointer(builder.Repo, "web")
+	if err != nil {
+		t.Fatalf("ReadLatestPointer failed: %v", err)
+	}
+	if pointer.ArtifactName != "web-abc123.tar.gz" || !pointer.UpdatedAt.Equal(builder.Now()) {
+		t.Errorf("Expected a pointer to the archive from the builder's clock, got %+v", pointer)
+	}
+}
+
+func TestBuilderRunRefusesUnsafeCleanOutput(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "project")
+	shared := filepath.Join(parent, "shared")
+	for _, dir := range []string{root, filepath.Join(root, "storage"), shared} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{filepath.Join(shared, "keep.txt"), filepath.Join(root, "storage", "keep.txt")} {
+		if err := os.WriteFile(path, []byte("keep"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	runner := &outputRunner{files: map[string]string{"../shared/app.js": "console.log(1)"}}
+	builder := testBuilder(t, root, runner, &out)
+	builder.Config.Cleanup.Denylist = []string{"storage"}
+	for _, dir := range []string{"../shared", "storage"} {
+		artifact := ArtifactConfig{Name: "web", Command: "npm run build", OutputDirectory: OutputDirectories{dir}, CleanOutput: true}
+		if _, err := builder.Run(artifact); !errors.Is(err, ErrUnsafeCleanupPath) {
+			t.Errorf("Expected cleaning %s to be refused, got %v", dir, err)
+		}
+	}
+	if len(runner.commands) != 0 {
+		t.Errorf("Expected the build command not to run, got %v", runner.commands)
+	}
+	for _, path := range []string{filepath.Join(shared, "keep.txt"), filepath.Join(root, "storage", "keep.txt")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be left alone, got %v", path, err)
+		}
+	}
+}
+
+func TestBuilderRunRefusesEmptyOutput(t *testing.T) {
+	var out bytes.Buffer
+	builder := testBuilder(t, t.TempDir(), &outputRunner{}, &out)
+	artifact := ArtifactConfig{Name: "web", Command: "true", OutputDirectory: OutputDirectories{"dist"}}
+
+	if _, err := builder.Run(artifact); !errors.Is(err, ErrEmptyArchive) {
+		t.Errorf("Expected ErrEmptyArchive, got %v", err)
+	}
+
+	builder.AllowEmpty = true
+	if _, err := builder.Run(artifact); err != nil {
+		t.Errorf("Expected AllowEmpty to accept the empty output, got %v", err)
+	}
+}
+
+func TestBuilderBuildAll(t *testing.T) {
+	root := t.TempDir()
+	var out bytes.Buffer
+	runner := &outputRunner{files: map[string]string{"dist/app.js": "console.log(1)"}}
+	builder := testBuilder(t, root, runner, &out)
+	builder.Namer = func(name string, config *ArtifactsConfig) (string, error) {
+		return name + "-abc.tar.gz", nil
+	}
+	web := ArtifactConfig{Name: "web", ArtifactPrefix: "web", Command: "build web", OutputDirectory: OutputDirectories{"dist"}}
+	api := ArtifactConfig{Name: "api", ArtifactPrefix: "api", Command: "build api", OutputDirectory: OutputDirectories{"dist"}}
+	if err := builder.Repo.StoreArtifact(bytes.NewReader([]byte("stored")), "api-abc.tar.gz"); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := builder.BuildAll([]ArtifactConfig{web, api}, BuildOptions{MarkLatest: true})
+	if err != nil {
+		t.Fatalf("BuildAll failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Status != BuildBuilt || results[1].Status != BuildSkipped || !results[1].AlreadyStored {
+		t.Fatalf("Expected web to be built and api skipped, got %+v", results)
+	}
+	if results[0].Size == 0 || len(runner.commands) != 1 {
+		t.Errorf("Expected only web's command to run and its archive to be stored, got %v and %d bytes", runner.commands, results[0].Size)
+	}
+	for _, prefix := range []string{"web", "api"} {
+		if _, err := ReadLatestPointer(builder.Repo, prefix); err != nil {
+			t.Errorf("Expected %s to be marked latest, got %v", prefix, err)
+		}
+	}
+	for _, expected := range []string{"Doing build for web - YES", "Doing build for api - NO", "Beginning build for web application", " 1/1 [============================] 100%", "-- Saved web-abc.tar.gz to repository.", "Builds succeeded for 1 artifacts"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in the output, got:\n%s", expected, out.String())
+		}
+	}
+
+	// With FailFast the artifacts after a failed build are left out
+	out.Reset()
+	builder.Runner = failingRunner{}
+	results, err = builder.BuildAll([]ArtifactConfig{web, api}, BuildOptions{Force: true, FailFast: true})
+	if err != nil {
+		t.Fatalf("BuildAll failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Status != BuildFailed || !errors.Is(results[0].Err, ErrBuildFailed) {
+		t.Errorf("Expected only web's failure, got %+v", results)
+	}
+	if !strings.Contains(out.String(), "Stopping early because --fail-fast is set") || !strings.Contains(out.String(), "Builds failed for 1/2 artifacts:\n - web") {
+		t.Errorf("Expected the failure to be reported, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,28 @@
+package slarty
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// channelPattern is the characters a channel may contain, which covers branch names
+// such as "feature/login-form"
+var channelPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+
+// ValidateChannel checks that a channel is safe to use as part of a repository path.
+// An empty channel is valid and means artifacts are stored without one.
+func ValidateChannel(channel string) error {
+	if channel == "" {
+		return nil
+	}
+	if !channelPattern.MatchString(channel) {
+		return fmt.Errorf("invalid channel %q: only letters, digits, '.', '_', '-' and '/' are allowed", channel)
+	}
+	for _, segment := range strings.Split(channel, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid channel %q: empty, '.' and '..' path segments are not allowed", channel)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateChannel(t *testing.T) {
+	for _, channel := range []string{"", "main", "release", "feature/login-form", "v1.2_rc"} {
+		if err := ValidateChannel(channel); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", channel, err)
+		}
+	}
+	for _, channel := range []string{"../main", "/main", "main/", "feature//x", "a b", "x/./y"} {
+		if err := ValidateChannel(channel); err == nil {
+			t.Errorf("Expected %q to be invalid", channel)
+		}
+	}
+}
+
+func TestNewRepositoryAdapterChannel(t *testing.T) {
+	root := t.TempDir()
+	config := &ArtifactsConfig{}
+	config.Repository.Adapter = "Local"
+	config.Repository.Options.Root = root
+	config.Repository.Channel = "feature/login"
+
+	repo, err := NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+	if err := repo.StoreArtifact(strings.NewReader("archive"), "web-abc.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "feature", "login", "web-abc.tar.gz")); err != nil {
+		t.Errorf("Expected the artifact under the channel: %v", err)
+	}
+
+	// Without the channel the artifact is not visible
+	config.Repository.Channel = ""
+	repo, err = NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+	if exists, err := repo.ArtifactExists("web-abc.tar.gz"); exists || err != nil {
+		t.Errorf("Expected the channel's artifact not to be in the main namespace, got %v (%v)", exists, err)
+	}
+
+	config.Repository.Channel = "../escape"
+	if _, err := NewRepositoryAdapter(config, true); err == nil {
+		t.Errorf("Expected an invalid channel to be rejected")
+	}
+}
@@ -0,0 +1,31 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when a downloaded file does not have the checksum
+// it is expected to have
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// sha256Pattern matches a hex encoded SHA-256 digest
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// ValidateSHA256 checks that a checksum is a hex encoded SHA-256 digest
+func ValidateSHA256(checksum string) error {
+	if !sha256Pattern.MatchString(checksum) {
+		return fmt.Errorf("invalid sha256 %q: expected 64 hexadecimal characters", checksum)
+	}
+	return nil
+}
+
+// VerifySHA256 compares a hex encoded SHA-256 digest with the expected one, ignoring case
+func VerifySHA256(name, expected, actual string) error {
+	if !strings.EqualFold(expected, actual) {
+		return fmt.Errorf("%w for %s: expected sha256 %s, got %s", ErrChecksumMismatch, name, strings.ToLower(expected), strings.ToLower(actual))
+	}
+	return nil
+}
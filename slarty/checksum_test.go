@@ -0,0 +1,28 @@
+package slarty
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateSHA256(t *testing.T) {
+	if err := ValidateSHA256(strings.Repeat("aB", 32)); err != nil {
+		t.Errorf("Expected a 64 character hex digest to be valid, got %v", err)
+	}
+	for _, checksum := range []string{"", "abc", strings.Repeat("g", 64), strings.Repeat("a", 65)} {
+		if err := ValidateSHA256(checksum); err == nil {
+			t.Errorf("Expected %q to be invalid", checksum)
+		}
+	}
+}
+
+func TestVerifySHA256(t *testing.T) {
+	if err := VerifySHA256("lib.tar.gz", strings.Repeat("AB", 32), strings.Repeat("ab", 32)); err != nil {
+		t.Errorf("Expected digests differing only in case to match, got %v", err)
+	}
+	err := VerifySHA256("lib.tar.gz", strings.Repeat("a", 64), strings.Repeat("b", 64))
+	if !errors.Is(err, ErrChecksumMismatch) || !strings.Contains(err.Error(), "lib.tar.gz") {
+		t.Errorf("Expected a checksum mismatch naming the file, got %v", err)
+	}
+}
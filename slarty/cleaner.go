@@ -0,0 +1,183 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CleanupTarget is a deploy location a Cleaner empties
+type CleanupTarget struct {
+	// Name is the asset or artifact, or several of them sharing the location
+	Name     string
+	Location string
+	// Symlink is set for artifacts deployed with the symlink strategy, which are
+	// never cleaned
+	Symlink bool
+	// Path, Files and Size are filled in by Targets
+	Path  string
+	Files int
+	Size  int64
+}
+
+// Cleaner empties deploy locations, or moves their contents into a backup
+type Cleaner struct {
+	Config           *ArtifactsConfig
+	AllowOutsideRoot bool
+	// Backup keeps the contents instead of deleting them when it is set
+	Backup *Backup
+	// Out gets the reasons locations are skipped
+	Out io.Writer
+}
+
+// NewCleaner returns a Cleaner for the deploy locations of the configuration
+func NewCleaner(config *ArtifactsConfig) *Cleaner {
+	return &Cleaner{Config: config, Out: os.Stdout}
+}
+
+// Targets works out which of the locations can be emptied, before any of them is
+// touched. Locations that are unsafe to clean, belong to a symlink deploy or do not
+// exist are skipped with the reason printed, and entries sharing a location are
+// merged. The targets returned have their paths and usage filled in.
+func (c *Cleaner) Targets(locations []CleanupTarget) ([]CleanupTarget, error) {
+	var targets []CleanupTarget
+	seen := make(map[string]int)
+	for _, target := range locations {
+		// Get the full path to the deploy location
+		deployPath := filepath.Join(c.Config.RootDirectory, target.Location)
+
+		// A placeholder without a value would clean the wrong directory
+		if err := CheckLocation(target.Location); err != nil {
+			fmt.Fprintf(c.Out, "Refusing to clean %s for %s: %v\n", deployPath, target.Name, err)
+			continue
+		}
+
+		// Entries sharing a deploy location only need it cleaned once
+		if i, ok := seen[deployPath]; ok {
+			targets[i].Name += ", " + target.Name
+			continue
+		}
+
+		// Guard against cleaning the project root itself, or anything else a bad
+		// deploy_location could point at. An empty or "." deploy_location causes
+		// filepath.Join to collapse to RootDirectory, which would otherwise wipe
+		// the entire project.
+		err := c.Config.Cleanup.CheckCleanupPath(deployPath, c.Config.RootDirectory, c.AllowOutsideRoot)
+		if errors.Is(err, ErrUnsafeCleanupPath) {
+			fmt.Fprintf(c.Out, "Refusing to clean %s for %s: %v\n", deployPath, target.Name, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Emptying a symlink deploy would empty the release it points to
+		if target.Symlink {
+			fmt.Fprintf(c.Out, "Refusing to clean %s for %s: it links to a release managed by do-deploys\n", deployPath, target.Name)
+			continue
+		}
+
+		// Check if the directory exists
+		_, err = os.Stat(deployPath)
+		if os.IsNotExist(err) {
+			fmt.Fprintf(c.Out, "Directory for %s does not exist: %s\n", target.Name, deployPath)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to check deploy directory: %w", err)
+		}
+
+		files, size, err := DirectoryUsage(deployPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read deploy directory: %w", err)
+		}
+		target.Path, target.Files, target.Size = deployPath, files, size
+		seen[deployPath] = len(targets)
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// Clean empties a target returned by Targets, moving its contents into the backup
+// when there is one
+func (c *Cleaner) Clean(target CleanupTarget) error {
+	if c.Backup != nil {
+		_, err := c.Backup.Save(target.Path)
+		return err
+	}
+	return RemoveContents(target.Path)
+}
+
+// DirectoryUsage returns the number of files under dir and their total size
+func DirectoryUsage(dir string) (int, int64, error) {
+	var files int
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		files++
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size, err
+}
+
+// ContainsProjectRoot reports whether dir is the project root or an ancestor of it,
+// in which case emptying dir would wipe the project
+func ContainsProjectRoot(dir, rootDirectory string) (bool, error) {
+	dirAbs, err := filepath.Abs(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve directory: %w", err)
+	}
+	dirAbs = filepath.Clean(dirAbs)
+
+	rootAbs, err := filepath.Abs(rootDirectory)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+	rootAbs = filepath.Clean(rootAbs)
+
+	if dirAbs == rootAbs {
+		return true, nil
+	}
+
+	// rootAbs is inside dirAbs when the relative path does not climb out of dirAbs
+	rel, err := filepath.Rel(dirAbs, rootAbs)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel), nil
+}
+
+// RemoveContents removes all files and directories within the specified directory
+func RemoveContents(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		err = os.RemoveAll(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
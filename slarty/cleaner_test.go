@@ -0,0 +1,117 @@
+package slarty
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoveContents(t *testing.T) {
+	// Create a temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "slarty-remove-contents-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Create some files in the directory
+	files := []string{
+		filepath.Join(tempDir, "file1.txt"),
+		filepath.Join(tempDir, "file2.txt"),
+	}
+
+	for _, file := range files {
+		err = os.WriteFile(file, []byte("test content"), 0644)
+		if err != nil {
+			t.Fatalf("Failed to create file %s: %v", file, err)
+		}
+	}
+
+	// Create a subdirectory with a file
+	subDir := filepath.Join(tempDir, "subdir")
+	err = os.Mkdir(subDir, 0755)
+	if err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+
+	subFile := filepath.Join(subDir, "subfile.txt")
+	err = os.WriteFile(subFile, []byte("subfile content"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create file in subdirectory: %v", err)
+	}
+
+	// Verify files and directory exist
+	for _, file := range files {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			t.Fatalf("File %s does not exist before test", file)
+		}
+	}
+	if _, err := os.Stat(subDir); os.IsNotExist(err) {
+		t.Fatalf("Subdirectory does not exist before test")
+	}
+	if _, err := os.Stat(subFile); os.IsNotExist(err) {
+		t.Fatalf("File in subdirectory does not exist before test")
+	}
+
+	// Remove contents
+	err = RemoveContents(tempDir)
+	if err != nil {
+		t.Fatalf("RemoveContents failed: %v", err)
+	}
+
+	// Verify directory is empty
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to read directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected directory to be empty, got %d entries", len(entries))
+	}
+}
+
+func TestCleanerTargets(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"public/css", "assets"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "public", "css", "app.css"), []byte("body{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	cleaner := NewCleaner(&ArtifactsConfig{RootDirectory: root})
+	cleaner.Out = &out
+	targets, err := cleaner.Targets([]CleanupTarget{
+		{Name: "css", Location: "public"},
+		{Name: "js", Location: "public"},
+		{Name: "root", Location: "."},
+		{Name: "web", Location: "assets", Symlink: true},
+		{Name: "missing", Location: "missing"},
+	})
+	if err != nil {
+		t.Fatalf("Targets failed: %v", err)
+	}
+
+	if len(targets) != 1 {
+		t.Fatalf("Expected one target, got %+v", targets)
+	}
+	if target := targets[0]; target.Name != "css, js" || target.Files != 1 || target.Size != 6 {
+		t.Errorf("Expected public shared by css and js with one file, got %+v", target)
+	}
+	for _, skipped := range []string{"Refusing to clean " + root + " for root", "for web: it links to a release", "Directory for missing does not exist"} {
+		if !strings.Contains(out.String(), skipped) {
+			t.Errorf("Expected %q in the output, got %q", skipped, out.String())
+		}
+	}
+
+	if err := cleaner.Clean(targets[0]); err != nil {
+		t.Fatalf("Clean failed: %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(root, "public")); len(entries) != 0 {
+		t.Errorf("Expected public to be emptied, got %d entries", len(entries))
+	}
+}
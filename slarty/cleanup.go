@@ -0,0 +1,96 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafeCleanupPath is returned when emptying a directory could destroy something
+// outside of the deploy
+var ErrUnsafeCleanupPath = errors.New("unsafe cleanup path")
+
+// CleanupConfig holds the "cleanup" section of artifacts.json
+type CleanupConfig struct {
+	// Denylist holds paths, absolute or relative to root_directory, that are never
+	// emptied, along with anything inside them or containing them. Entries may also
+	// be glob patterns, which only refuse the paths they match.
+	Denylist []string `json:"denylist,omitempty"`
+	// BackupDirectory is where --backup moves the old contents of deploy locations
+	BackupDirectory string `json:"backup_directory,omitempty"`
+	// KeepBackups is how many backups are kept, DefaultKeepBackups if unset
+	KeepBackups int `json:"keep_backups,omitempty"`
+}
+
+// CheckCleanupPath returns an error wrapping ErrUnsafeCleanupPath if emptying dir could
+// be catastrophic: dir is /, the user's home directory or the project root, contains
+// one of them, or matches or overlaps the denylist. Symlinks are resolved first, so a
+// deploy location linking elsewhere is checked where it points. A dir outside the
+// project root is refused as well unless allowOutsideRoot is set; nothing else can be
+// allowed.
+func (c CleanupConfig) CheckCleanupPath(dir, root string, allowOutsideRoot bool) error {
+	dir, err := resolvePath(dir)
+	if err != nil {
+		return err
+	}
+	root, err = resolvePath(root)
+	if err != nil {
+		return err
+	}
+
+	if dir == filepath.Dir(dir) {
+		return fmt.Errorf("%w: %s is the filesystem root", ErrUnsafeCleanupPath, dir)
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		if home, err := resolvePath(home); err == nil && pathContains(dir, home) {
+			return fmt.Errorf("%w: %s resolves to or contains the home directory", ErrUnsafeCleanupPath, dir)
+		}
+	}
+	if pathContains(dir, root) {
+		return fmt.Errorf("%w: %s resolves to or contains the project root", ErrUnsafeCleanupPath, dir)
+	}
+
+	for _, entry := range c.Denylist {
+		pattern := entry
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(root, pattern)
+		}
+		pattern = filepath.Clean(pattern)
+		if matched, _ := filepath.Match(pattern, dir); matched {
+			return fmt.Errorf("%w: %s matches %q in the cleanup denylist", ErrUnsafeCleanupPath, dir, entry)
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			if resolved, err := resolvePath(pattern); err == nil && (pathContains(dir, resolved) || pathContains(resolved, dir)) {
+				return fmt.Errorf("%w: %s is or overlaps %q in the cleanup denylist", ErrUnsafeCleanupPath, dir, entry)
+			}
+		}
+	}
+
+	if !allowOutsideRoot && !pathContains(root, dir) {
+		return fmt.Errorf("%w: %s is outside the root directory %s", ErrUnsafeCleanupPath, dir, root)
+	}
+
+	return nil
+}
+
+// resolvePath returns the absolute, cleaned form of path with any symlinks resolved.
+// A path that does not exist yet is only made absolute.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved, nil
+	}
+	return filepath.Clean(abs), nil
+}
+
+// pathContains reports whether path is dir or lies inside it. Both must be absolute
+// and clean.
+func pathContains(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -0,0 +1,64 @@
+package slarty
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckCleanupPath(t *testing.T) {
+	tempDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+	home := filepath.Join(tempDir, "home")
+	root := filepath.Join(home, "project")
+	outside := filepath.Join(tempDir, "outside")
+	for _, dir := range []string{filepath.Join(root, "public"), filepath.Join(root, "storage", "logs"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "linked")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	t.Setenv("HOME", home)
+
+	config := CleanupConfig{Denylist: []string{"storage", "/etc", filepath.Join(root, "cache-*")}}
+	tests := []struct {
+		name             string
+		dir              string
+		allowOutsideRoot bool
+		wantErr          bool
+	}{
+		{"deploy location", filepath.Join(root, "public"), false, false},
+		{"missing deploy location", filepath.Join(root, "public", "new"), false, false},
+		{"filesystem root", "/", true, true},
+		{"home directory", home, true, true},
+		{"contains home", tempDir, true, true},
+		{"project root", filepath.Join(root, "public", ".."), true, true},
+		{"denied path", filepath.Join(root, "storage"), false, true},
+		{"contains denied path", filepath.Join(root, "storage", ".."), true, true},
+		{"inside denied path", filepath.Join(root, "storage", "logs"), false, true},
+		{"denied absolute path", "/etc", true, true},
+		{"denied pattern", filepath.Join(root, "cache-views"), false, true},
+		{"outside root", outside, false, true},
+		{"outside root allowed", outside, true, false},
+		{"escapes root", filepath.Join(root, "..", "..", "outside"), false, true},
+		{"links outside root", filepath.Join(root, "linked"), false, true},
+		{"links outside root allowed", filepath.Join(root, "linked"), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := config.CheckCleanupPath(tt.dir, root, tt.allowOutsideRoot)
+			if tt.wantErr && !errors.Is(err, ErrUnsafeCleanupPath) {
+				t.Errorf("Expected %s to be refused, got %v", tt.dir, err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected %s to be allowed, got %v", tt.dir, err)
+			}
+		})
+	}
+}
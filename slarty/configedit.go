@@ -0,0 +1,149 @@
+package slarty
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// AppendConfigEntry appends entry to the top-level array named key (for example
+// "artifacts" or "assets") in the raw artifacts.json content and returns the updated
+// content. Only the array is touched, so the rest of the file keeps its formatting;
+// the new entry is indented to match the surrounding file.
+func AppendConfigEntry(data []byte, key string, entry interface{}) ([]byte, error) {
+	start, end, found, err := findTopLevelArray(data, key)
+	if err != nil {
+		return nil, err
+	}
+
+	unit := detectIndentUnit(data)
+
+	if !found {
+		// Add the key just before the closing brace of the top-level object
+		closing := bytes.LastIndexByte(data, '}')
+		if closing < 0 {
+			return nil, errors.New("configuration is not a JSON object")
+		}
+		entryJSON, err := json.MarshalIndent(entry, unit+unit, unit)
+		if err != nil {
+			return nil, err
+		}
+		before := bytes.TrimRight(data[:closing], " \t\r\n")
+		separator := ","
+		if bytes.HasSuffix(before, []byte("{")) {
+			separator = ""
+		}
+		var out bytes.Buffer
+		out.Write(before)
+		fmt.Fprintf(&out, "%s\n%s%q: [\n%s%s\n%s]\n", separator, unit, key, unit+unit, entryJSON, unit)
+		out.Write(data[closing:])
+		return out.Bytes(), nil
+	}
+
+	keyIndent := lineIndent(data, start)
+	elementIndent := keyIndent + unit
+	entryJSON, err := json.MarshalIndent(entry, elementIndent, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	inner := bytes.TrimSpace(data[start+1 : end])
+
+	var out bytes.Buffer
+	if len(inner) == 0 {
+		out.Write(data[:start+1])
+		fmt.Fprintf(&out, "\n%s%s\n%s", elementIndent, entryJSON, keyIndent)
+		out.Write(data[end:])
+		return out.Bytes(), nil
+	}
+
+	// Insert after the last element, keeping whatever whitespace preceded the
+	// closing bracket.
+	last := start + 1 + len(bytes.TrimRight(data[start+1:end], " \t\r\n"))
+	out.Write(data[:last])
+	fmt.Fprintf(&out, ",\n%s%s", elementIndent, entryJSON)
+	out.Write(data[last:])
+	return out.Bytes(), nil
+}
+
+// findTopLevelArray returns the byte offsets of the opening and closing brackets of
+// the array stored under key in the top-level JSON object.
+func findTopLevelArray(data []byte, key string) (start, end int, found bool, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	token, err := decoder.Token()
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return 0, 0, false, errors.New("configuration is not a JSON object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, 0, false, fmt.Errorf("invalid configuration: %w", err)
+		}
+		name, _ := token.(string)
+
+		if name != key {
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return 0, 0, false, fmt.Errorf("invalid configuration: %w", err)
+			}
+			continue
+		}
+
+		token, err = decoder.Token()
+		if err != nil {
+			return 0, 0, false, fmt.Errorf("invalid configuration: %w", err)
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return 0, 0, false, fmt.Errorf("%q in configuration is not an array", key)
+		}
+		start = int(decoder.InputOffset()) - 1
+
+		for decoder.More() {
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return 0, 0, false, fmt.Errorf("invalid configuration: %w", err)
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			return 0, 0, false, fmt.Errorf("invalid configuration: %w", err)
+		}
+		end = int(decoder.InputOffset()) - 1
+
+		return start, end, true, nil
+	}
+
+	if _, err := decoder.Token(); err != nil && err != io.EOF {
+		return 0, 0, false, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return 0, 0, false, nil
+}
+
+// detectIndentUnit guesses the indentation used by the file from the first indented
+// line, defaulting to two spaces.
+func detectIndentUnit(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || len(trimmed) == len(line) {
+			continue
+		}
+		return line[:len(line)-len(trimmed)]
+	}
+	return "  "
+}
+
+// lineIndent returns the leading whitespace of the line containing offset
+func lineIndent(data []byte, offset int) string {
+	lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+	line := data[lineStart:offset]
+	trimmed := bytes.TrimLeft(line, " \t")
+	return string(line[:len(line)-len(trimmed)])
+}
@@ -0,0 +1,95 @@
+package slarty
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAppendConfigEntry(t *testing.T) {
+	asset := Asset{Name: "Fonts", Filename: "fonts.tar.gz", DeployLocation: "public/fonts"}
+
+	t.Run("NonEmptyArrayKeepsFormatting", func(t *testing.T) {
+		original := `{
+    "application": "Test",
+    "assets": [
+        {
+            "name": "Lib",
+            "filename": "lib.tar.gz",
+            "deploy_location": "lib"
+        }
+    ],
+    "artifacts": []
+}
+`
+		updated, err := AppendConfigEntry([]byte(original), "assets", asset)
+		if err != nil {
+			t.Fatalf("AppendConfigEntry failed: %v", err)
+		}
+
+		expected := `{
+    "application": "Test",
+    "assets": [
+        {
+            "name": "Lib",
+            "filename": "lib.tar.gz",
+            "deploy_location": "lib"
+        },
+        {
+            "name": "Fonts",
+            "filename": "fonts.tar.gz",
+            "deploy_location": "public/fonts"
+        }
+    ],
+    "artifacts": []
+}
+`
+		if string(updated) != expected {
+			t.Errorf("Unexpected result:\n%s\nexpected:\n%s", updated, expected)
+		}
+	})
+
+	t.Run("EmptyArray", func(t *testing.T) {
+		original := "{\n  \"application\": \"Test\",\n  \"assets\": []\n}\n"
+		updated, err := AppendConfigEntry([]byte(original), "assets", asset)
+		if err != nil {
+			t.Fatalf("AppendConfigEntry failed: %v", err)
+		}
+
+		expected := "{\n  \"application\": \"Test\",\n  \"assets\": [\n    {\n      \"name\": \"Fonts\",\n      \"filename\": \"fonts.tar.gz\",\n      \"deploy_location\": \"public/fonts\"\n    }\n  ]\n}\n"
+		if string(updated) != expected {
+			t.Errorf("Unexpected result:\n%s\nexpected:\n%s", updated, expected)
+		}
+	})
+
+	t.Run("MissingKey", func(t *testing.T) {
+		original := "{\n  \"application\": \"Test\"\n}\n"
+		updated, err := AppendConfigEntry([]byte(original), "assets", asset)
+		if err != nil {
+			t.Fatalf("AppendConfigEntry failed: %v", err)
+		}
+
+		var parsed ArtifactsConfig
+		if err := json.Unmarshal(updated, &parsed); err != nil {
+			t.Fatalf("Result is not valid JSON: %v\n%s", err, updated)
+		}
+		if len(parsed.Assets) != 1 || parsed.Assets[0].Name != "Fonts" {
+			t.Errorf("Expected appended asset, got %+v", parsed.Assets)
+		}
+		if !strings.HasPrefix(string(updated), "{\n  \"application\": \"Test\",\n  \"assets\": [") {
+			t.Errorf("Unexpected formatting:\n%s", updated)
+		}
+	})
+
+	t.Run("NotAnArray", func(t *testing.T) {
+		if _, err := AppendConfigEntry([]byte(`{"assets": {}}`), "assets", asset); err == nil {
+			t.Error("Expected error when key is not an array")
+		}
+	})
+
+	t.Run("InvalidJSON", func(t *testing.T) {
+		if _, err := AppendConfigEntry([]byte(`{"assets": [,]}`), "assets", asset); err == nil {
+			t.Error("Expected error for invalid JSON")
+		}
+	})
+}
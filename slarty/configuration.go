@@ -1,45 +1,178 @@
 package slarty
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 type Repository struct {
-	Adapter string `json:"adapter"`
-	Options struct {
-		Root       string `json:"root"`
-		Region     string `json:"region"`
-		BucketName string `json:"bucket-name"`
-		PathPrefix string `json:"path-prefix"`
-		Profile    string `json:"profile"`
-	} `json:"options"`
+	Adapter   string `json:"adapter"`
+	Immutable bool   `json:"immutable,omitempty"`
+	// ReadOnly refuses to store, label or delete anything in the repository
+	ReadOnly   bool                  `json:"read-only,omitempty"`
+	Channel    string                `json:"channel,omitempty"`
+	Options    RepositoryOptions     `json:"options"`
+	Encryption *RepositoryEncryption `json:"encryption,omitempty"`
+	// LimitRate caps how fast artifacts are stored and retrieved, such as 10M for 10
+	// MiB a second; see ParseRate
+	LimitRate string `json:"limit-rate,omitempty"`
+	// ExistsCacheTTL keeps the S3 artifacts found to exist in a file for this long,
+	// such as 10m, so later runs don't look them up again
+	ExistsCacheTTL string `json:"exists-cache-ttl,omitempty"`
+}
+
+// RepositoryEncryption turns on client-side encryption of stored artifacts, with the
+// data key of each artifact wrapped by the key in key-file or by the AWS KMS key
+// kms-key-id
+type RepositoryEncryption struct {
+	// KeyFile holds a base64 encoded 256 bit key. A relative path is relative to the
+	// root directory.
+	KeyFile  string `json:"key-file,omitempty"`
+	KMSKeyID string `json:"kms-key-id,omitempty"`
+	// AllowUnencrypted lets artifacts stored before encryption was turned on be read
+	AllowUnencrypted bool `json:"allow-unencrypted,omitempty"`
+}
+
+// RepositoryOptions configure the repository adapter. Multi-word keys are kebab-case;
+// the snake_case bucket_name and path_prefix are still read, with a warning. Any option
+// may be a secret_ref, fetched when the adapter is created; see SecretRef.
+type RepositoryOptions struct {
+	Root       string `json:"root"`
+	Region     string `json:"region"`
+	BucketName string `json:"bucket-name"`
+	PathPrefix string `json:"path-prefix"`
+	Profile    string `json:"profile"`
+	// AccessKeyID, SecretAccessKey and SessionToken are S3 credentials to use instead
+	// of the AWS credential chain. They must be secret_refs.
+	AccessKeyID     string `json:"access-key-id,omitempty"`
+	SecretAccessKey string `json:"secret-access-key,omitempty"`
+	SessionToken    string `json:"session-token,omitempty"`
 }
 
 type ArtifactConfig struct {
-	Name            string   `json:"name"`
-	Directories     []string `json:"directories"`
-	Command         string   `json:"command"`
-	OutputDirectory string   `json:"output_directory"`
-	DeployLocation  string   `json:"deploy_location"`
-	ArtifactPrefix  string   `json:"artifact_prefix"`
+	Name            string            `json:"name"`
+	Type            string            `json:"type,omitempty"`
+	Directories     []string          `json:"directories"`
+	Command         string            `json:"command"`
+	OutputDirectory OutputDirectories `json:"output_directory"`
+	DeployLocation  string            `json:"deploy_location"`
+	ArtifactPrefix  string            `json:"artifact_prefix"`
+	// NameTemplate is how the artifact's archives are named, such as
+	// {prefix}-{hash}-{channel}.{ext}; see CheckArtifactNameTemplate
+	NameTemplate string `json:"artifact_name,omitempty"`
+	// Archive is the format the artifact is archived in: tar.gz (the default), zip
+	// or tar
+	Archive    string            `json:"archive,omitempty"`
+	Groups     []string          `json:"groups,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+	Variants   map[string]string `json:"variants,omitempty"`
+	Matrix     []string          `json:"matrix,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	Image      string            `json:"image,omitempty"`
+	Dockerfile string            `json:"dockerfile,omitempty"`
+	Context    string            `json:"context,omitempty"`
+	Container  *ContainerConfig  `json:"container,omitempty"`
+	Owner      string            `json:"owner,omitempty"`
+	Group      string            `json:"group,omitempty"`
+	Mode       string            `json:"mode,omitempty"`
+	// DeployStrategy set to "symlink" deploys into a release directory and links
+	// deploy_location to it instead of extracting into deploy_location
+	DeployStrategy    string `json:"deploy_strategy,omitempty"`
+	ReleasesDirectory string `json:"releases_directory,omitempty"`
+	// Services are restarted after a deploy with the restart command, in which
+	// {service} is replaced by the service name
+	Services       []string `json:"services,omitempty"`
+	RestartCommand string   `json:"restart_command,omitempty"`
+	// MaxSize fails a build whose archive is larger, and WarnSize warns about one,
+	// such as 80M or 1G. MinSize fails a build whose archive is smaller.
+	MaxSize  string `json:"max_size,omitempty"`
+	WarnSize string `json:"warn_size,omitempty"`
+	MinSize  string `json:"min_size,omitempty"`
+	// OutputInclude and OutputExclude are globs that pick which files in the output
+	// directory are archived
+	OutputInclude []string `json:"output_include,omitempty"`
+	OutputExclude []string `json:"output_exclude,omitempty"`
+	// CleanOutput empties the output directories before the build command runs
+	CleanOutput bool `json:"clean_output,omitempty"`
+	// Expect are globs of files the build must leave in the output directories
+	Expect []string `json:"expect,omitempty"`
+
+	// Platform is the os/arch this artifact was expanded for from a matrix
+	Platform string `json:"-"`
 }
 
 type Asset struct {
-	Name           string `json:"name"`
-	Filename       string `json:"filename"`
-	DeployLocation string `json:"deploy_location"`
+	Name           string   `json:"name"`
+	Filename       string   `json:"filename"`
+	DeployLocation string   `json:"deploy_location"`
+	Groups         []string `json:"groups,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	// SHA256 is the expected hex SHA-256 digest of the asset's file, checked before
+	// the asset is extracted
+	SHA256 string `json:"sha256,omitempty"`
+	// Unpack set to false deploys the file as it is instead of extracting it
+	Unpack *bool `json:"unpack,omitempty"`
+	// Owner, Group and Mode are applied to the deployed files
+	Owner string `json:"owner,omitempty"`
+	Group string `json:"group,omitempty"`
+	Mode  string `json:"mode,omitempty"`
+}
+
+// ShouldUnpack reports whether the asset's file is an archive to extract, which it is
+// unless unpack is set to false
+func (a Asset) ShouldUnpack() bool {
+	return a.Unpack == nil || *a.Unpack
 }
 
 type ArtifactsConfig struct {
-	Application   string           `json:"application"`
-	RootDirectory string           `json:"root_directory"`
-	Repository    Repository       `json:"repository"`
-	Artifacts     []ArtifactConfig `json:"artifacts"`
-	Assets        []Asset          `json:"assets"`
+	// Schema is the JSON Schema editors validate the file against, which slarty ignores
+	Schema string `json:"$schema,omitempty"`
+	// SchemaVersion is the version of the config format, 1 if it is left out. Older
+	// configs are upgraded with slarty config migrate.
+	SchemaVersion int    `json:"schema_version,omitempty"`
+	Application   string `json:"application"`
+	// Version is the application's version, which artifact names can include
+	Version          string              `json:"version,omitempty"`
+	MinSlartyVersion string              `json:"min_slarty_version,omitempty"`
+	RootDirectory    string              `json:"root_directory"`
+	Repository       Repository          `json:"repository"`
+	Artifacts        []ArtifactConfig    `json:"artifacts"`
+	Assets           []Asset             `json:"assets"`
+	Notifications    Notifications       `json:"notifications"`
+	Metrics          MetricsConfig       `json:"metrics"`
+	Extraction       ExtractionLimits    `json:"extraction"`
+	Workspaces       []string            `json:"workspaces,omitempty"`
+	Pipelines        map[string][]string `json:"pipelines,omitempty"`
+	RestartCommand   string              `json:"restart_command,omitempty"`
+	Maintenance      MaintenanceConfig   `json:"maintenance"`
+	Cleanup          CleanupConfig       `json:"cleanup"`
+	Audit            AuditConfig         `json:"audit"`
+	Approvals        ApprovalConfig      `json:"approvals"`
+	// NameTemplate is how archives are named unless an artifact sets its own
+	// artifact_name
+	NameTemplate string `json:"artifact_name,omitempty"`
+	// HashLength shortens the hashes in artifact names to this many characters, for
+	// tools that limit how long a file name can be. 0 keeps them whole.
+	HashLength int `json:"hash_length,omitempty"`
+	// HashAlgorithm is what the listing of an artifact's files is hashed with to name
+	// it: sha1 (the default) or sha256
+	HashAlgorithm string `json:"hash_algorithm,omitempty"`
+	// DirtyTree is what to do when an artifact's directories have unstaged changes
+	// at build or deploy time: warn (the default), fail or ignore
+	DirtyTree string `json:"dirty_tree,omitempty"`
+	// HashWorkingTree names artifacts after the working tree instead of the index,
+	// marking those with unstaged changes as dirty. It is set by --dirty.
+	HashWorkingTree bool `json:"-"`
+	// Offline keeps slarty off the network, for air-gapped hosts that deploy from a
+	// copy of the repository synced to them beforehand. The repository is opened as a
+	// local one, and reaching AWS, vault, a docker registry or a notification, metrics
+	// or approval webhook fails with ErrOffline. It is set by --offline.
+	Offline bool `json:"-"`
+	// Variables are the deploy location placeholder values given with --var, which
+	// win over the SLARTY_ environment variables. They are set from ReadOptions.
+	Variables map[string]string `json:"-"`
 }
 
 func (ac *ArtifactsConfig) GetArtifactConfig(artifactname string) (*ArtifactConfig, error) {
@@ -52,26 +185,56 @@ func (ac *ArtifactsConfig) GetArtifactConfig(artifactname string) (*ArtifactConf
 	return nil, errors.New("config for " + artifactname + " name not found in artifacts.json")
 }
 
-func (ac *ArtifactsConfig) GetByArtifactsByNameWithFilter(filter []string) []ArtifactConfig {
-	if len(filter) == 0 {
-		return ac.Artifacts[:]
+// ReadOptions are how an artifacts.json is read
+type ReadOptions struct {
+	// Strict makes keys slarty does not recognize an error instead of a warning
+	Strict bool
+	// Variables are the deploy location placeholder values given with --var, by
+	// placeholder name. The SLARTY_ environment variables are used for the rest.
+	Variables map[string]string
+}
+
+// ReadArtifactsJson reads the config at path and the workspaces it includes, warning
+// about keys slarty does not recognize
+func ReadArtifactsJson(path string) (*ArtifactsConfig, error) {
+	return ReadArtifactsJsonWithOptions(path, ReadOptions{})
+}
+
+// ReadArtifactsJsonWithOptions reads the config at path and the workspaces it includes
+// as opts says
+func ReadArtifactsJsonWithOptions(path string, opts ReadOptions) (*ArtifactsConfig, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
 	}
 
-	var selected []ArtifactConfig
-	for i := range ac.Artifacts {
-		name := strings.ToLower(ac.Artifacts[i].Name)
-		for _, f := range filter {
-			if name == strings.TrimSpace(strings.ToLower(f)) {
-				selected = append(selected, ac.Artifacts[i])
-				break
-			}
+	return readArtifactsJson(path, map[string]bool{absPath: true}, opts)
+}
+
+// resolveRootDirectory resolves a config's root_directory: __DIR__ is the directory
+// the config is in, __GITROOT__ is the top of the git repository holding it, and a
+// relative path is relative to the config rather than the working directory, so the
+// same config works wherever slarty is run from. An empty root_directory is left for
+// the working directory, as it always has been.
+func resolveRootDirectory(root, configDir string) (string, error) {
+	switch {
+	case root == "__DIR__":
+		return configDir, nil
+	case root == "__GITROOT__":
+		gitRoot, err := GitRoot(configDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve root_directory __GITROOT__: %w", err)
 		}
+		return gitRoot, nil
+	case root == "" || filepath.IsAbs(root):
+		return root, nil
 	}
-
-	return selected
+	return filepath.Join(configDir, root), nil
 }
 
-func ReadArtifactsJson(path string) (*ArtifactsConfig, error) {
+// readArtifactsJson reads the config at path and the workspaces it includes. visited
+// holds the absolute paths of every config read so far.
+func readArtifactsJson(path string, visited map[string]bool, opts ReadOptions) (*ArtifactsConfig, error) {
 	file, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
@@ -79,15 +242,63 @@ func ReadArtifactsJson(path string) (*ArtifactsConfig, error) {
 
 	var artifacts ArtifactsConfig
 
-	err = json.Unmarshal(file, &artifacts)
+	err = decodeConfig(path, file, &artifacts, opts)
 
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+
+	artifacts.Variables = opts.Variables
+	artifacts.RootDirectory, err = resolveRootDirectory(artifacts.RootDirectory, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
 	}
 
-	if artifacts.RootDirectory == "__DIR__" {
-		artifacts.RootDirectory = filepath.Dir(path)
+	if err := artifacts.expandMatrix(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+	artifacts.expandLocations()
+	artifacts.applyNameTemplate()
+
+	if len(artifacts.Workspaces) > 0 {
+		if err := artifacts.loadWorkspaces(filepath.Dir(path), visited, opts); err != nil {
+			return nil, err
+		}
 	}
 
 	return &artifacts, nil
 }
+
+// Resolved returns a copy of the configuration as commands use it: the root directory
+// made absolute, the repository as the adapter is created with it (see
+// ResolvedRepository), and the defaults of settings that are left out filled in
+func (ac *ArtifactsConfig) Resolved(useLocal bool) (*ArtifactsConfig, error) {
+	resolved := *ac
+
+	root, err := filepath.Abs(ac.RootDirectory)
+	if err != nil {
+		return nil, err
+	}
+	resolved.RootDirectory = root
+
+	resolved.Repository, err = ac.ResolvedRepository(useLocal)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved.Cleanup.BackupDirectory = ac.Cleanup.BackupsPath(root)
+	resolved.Cleanup.KeepBackups = ac.Cleanup.Retention()
+	resolved.DirtyTree = ac.DirtyTreePolicy()
+	resolved.HashAlgorithm = ac.HashAlgorithmName()
+	if resolved.RestartCommand == "" {
+		resolved.RestartCommand = DefaultRestartCommand
+	}
+	if resolved.Artifacts == nil {
+		resolved.Artifacts = []ArtifactConfig{}
+	}
+	if resolved.Assets == nil {
+		resolved.Assets = []Asset{}
+	}
+
+	return &resolved, nil
+}
@@ -1,7 +1,9 @@
 package slarty
 
 import (
+	"encoding/json"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 )
@@ -54,86 +56,6 @@ func TestGetArtifactConfig(t *testing.T) {
 	})
 }
 
-func TestGetByArtifactsByNameWithFilter(t *testing.T) {
-	// Create a test configuration
-	config := &ArtifactsConfig{
-		Artifacts: []ArtifactConfig{
-			{
-				Name:           "test-artifact",
-				Directories:    []string{"dir1", "dir2"},
-				Command:        "make test",
-				ArtifactPrefix: "test",
-			},
-			{
-				Name:           "another-artifact",
-				Directories:    []string{"dir3"},
-				Command:        "make another",
-				ArtifactPrefix: "another",
-			},
-			{
-				Name:           "third-artifact",
-				Directories:    []string{"dir4"},
-				Command:        "make third",
-				ArtifactPrefix: "third",
-			},
-		},
-	}
-
-	// Test with no filter
-	t.Run("NoFilter", func(t *testing.T) {
-		artifacts := config.GetByArtifactsByNameWithFilter(nil)
-		if len(artifacts) != 3 {
-			t.Fatalf("Expected 3 artifacts, got %d", len(artifacts))
-		}
-	})
-
-	// Test with a filter matching one artifact
-	t.Run("SingleFilter", func(t *testing.T) {
-		artifacts := config.GetByArtifactsByNameWithFilter([]string{"test-artifact"})
-		if len(artifacts) != 1 {
-			t.Fatalf("Expected 1 artifact, got %d", len(artifacts))
-		}
-		if artifacts[0].Name != "test-artifact" {
-			t.Fatalf("Expected artifact name 'test-artifact', got '%s'", artifacts[0].Name)
-		}
-	})
-
-	// Test with a filter matching multiple artifacts
-	t.Run("MultipleFilters", func(t *testing.T) {
-		artifacts := config.GetByArtifactsByNameWithFilter([]string{"test-artifact", "third-artifact"})
-		if len(artifacts) != 2 {
-			t.Fatalf("Expected 2 artifacts, got %d", len(artifacts))
-		}
-		// Check that the correct artifacts were returned
-		names := make(map[string]bool)
-		for _, a := range artifacts {
-			names[a.Name] = true
-		}
-		if !names["test-artifact"] || !names["third-artifact"] {
-			t.Fatalf("Expected artifacts 'test-artifact' and 'third-artifact', got %v", names)
-		}
-	})
-
-	// Test with a filter matching no artifacts
-	t.Run("NoMatchFilter", func(t *testing.T) {
-		artifacts := config.GetByArtifactsByNameWithFilter([]string{"non-existing"})
-		if len(artifacts) != 0 {
-			t.Fatalf("Expected 0 artifacts, got %d", len(artifacts))
-		}
-	})
-
-	// Test with case-insensitive matching
-	t.Run("CaseInsensitiveFilter", func(t *testing.T) {
-		artifacts := config.GetByArtifactsByNameWithFilter([]string{"TEST-ARTIFACT"})
-		if len(artifacts) != 1 {
-			t.Fatalf("Expected 1 artifact, got %d", len(artifacts))
-		}
-		if artifacts[0].Name != "test-artifact" {
-			t.Fatalf("Expected artifact name 'test-artifact', got '%s'", artifacts[0].Name)
-		}
-	})
-}
-
 func TestReadArtifactsJson(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir, err := os.MkdirTemp("", "slarty-config-test")
@@ -238,3 +160,74 @@ func TestReadArtifactsJson(t *testing.T) {
 		}
 	})
 }
+
+func TestAssetShouldUnpack(t *testing.T) {
+	var assets []Asset
+	err := json.Unmarshal([]byte(`[
+		{"name": "ExtJS", "filename": "extjs.tar.gz"},
+		{"name": "Archive", "filename": "archive.zip", "unpack": true},
+		{"name": "GeoIP", "filename": "GeoLite2-City.mmdb", "unpack": false}
+	]`), &assets)
+	if err != nil {
+		t.Fatalf("Failed to parse assets: %v", err)
+	}
+
+	for i, expected := range []bool{true, true, false} {
+		if assets[i].ShouldUnpack() != expected {
+			t.Errorf("Expected ShouldUnpack for %s to be %v", assets[i].Name, expected)
+		}
+	}
+}
+
+func TestReadArtifactsJsonRootDirectory(t *testing.T) {
+	tempDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to resolve temp directory: %v", err)
+	}
+	configDir := filepath.Join(tempDir, "deploy", "config")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	cmd := exec.Command("git", "init")
+	cmd.Dir = tempDir
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("git init failed: %v", err)
+	}
+
+	tests := []struct {
+		root string
+		want string
+	}{
+		{"__DIR__", configDir},
+		{"__GITROOT__", tempDir},
+		{"../..", tempDir},
+		{"app", filepath.Join(configDir, "app")},
+		{"/srv/app", "/srv/app"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(configDir, "artifacts.json")
+		content := `{"application": "Test", "root_directory": "` + tt.root + `"}`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		config, err := ReadArtifactsJson(path)
+		if err != nil {
+			t.Fatalf("ReadArtifactsJson failed for %q: %v", tt.root, err)
+		}
+		if config.RootDirectory != tt.want {
+			t.Errorf("root_directory %q resolved to %q, want %q", tt.root, config.RootDirectory, tt.want)
+		}
+	}
+
+	// __GITROOT__ outside a repository is an error
+	outside := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(outside))
+	path := filepath.Join(outside, "artifacts.json")
+	if err := os.WriteFile(path, []byte(`{"root_directory": "__GITROOT__"}`), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := ReadArtifactsJson(path); err == nil {
+		t.Errorf("Expected __GITROOT__ outside a git repository to fail")
+	}
+}
@@ -0,0 +1,130 @@
+package slarty
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ContainerConfig describes the container an artifact's build command runs in
+type ContainerConfig struct {
+	Image  string            `json:"image"`
+	Mounts []string          `json:"mounts,omitempty"`
+	Env    map[string]string `json:"env,omitempty"`
+}
+
+// imageDigests caches resolved image digests for the life of the process
+var imageDigests sync.Map
+
+// ImageDigest returns the content digest of an image so a build can be tied to the
+// exact toolchain it ran with. Images pinned with @sha256:... use the pinned digest.
+// Otherwise the image is pulled if it is not available locally and its image ID,
+// which is the digest of its configuration, is used.
+func (d *DockerAdapter) ImageDigest(image string) (string, error) {
+	if _, digest, found := strings.Cut(image, "@"); found {
+		return digest, nil
+	}
+
+	if digest, ok := imageDigests.Load(image); ok {
+		return digest.(string), nil
+	}
+
+	inspect := func() (string, error) {
+		var out, stderr bytes.Buffer
+		cmd := exec.Command(d.binary, "image", "inspect", "--format", "{{.Id}}", image)
+		cmd.Stdout = &out
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("%s image inspect failed: %w: %s", d.binary, err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimSpace(out.String()), nil
+	}
+
+	digest, err := inspect()
+	if err != nil {
+		if offlineErr := offlineError(d.Offline, "pulling "+image); offlineErr != nil {
+			return "", fmt.Errorf("%w (%v)", offlineErr, err)
+		}
+		var stderr bytes.Buffer
+		pull := exec.Command(d.binary, "pull", image)
+		pull.Stdout = &stderr
+		pull.Stderr = &stderr
+		if pullErr := pull.Run(); pullErr != nil {
+			return "", fmt.Errorf("%s pull %s failed: %w: %s", d.binary, image, pullErr, strings.TrimSpace(stderr.String()))
+		}
+		if digest, err = inspect(); err != nil {
+			return "", err
+		}
+	}
+
+	imageDigests.Store(image, digest)
+	return digest, nil
+}
+
+// RunInContainer runs command with sh -c inside the container, with the root directory
+// mounted at the same path and used as the working directory. Relative mount sources
+// are resolved against the root directory. env is added to the container's env, and
+// on unix the command runs as the current user so its output is not owned by root.
+func (d *DockerAdapter) RunInContainer(container ContainerConfig, rootDirectory, command string, env map[string]string) error {
+	root, err := filepath.Abs(rootDirectory)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"run", "--rm", "-v", root + ":" + root, "-w", root}
+	if runtime.GOOS != "windows" {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+
+	for _, mount := range container.Mounts {
+		source, target, found := strings.Cut(mount, ":")
+		if found && !filepath.IsAbs(source) {
+			mount = filepath.Join(root, source) + ":" + target
+		}
+		args = append(args, "-v", mount)
+	}
+
+	merged := make(map[string]string, len(container.Env)+len(env))
+	for key, value := range container.Env {
+		merged[key] = value
+	}
+	for key, value := range env {
+		merged[key] = value
+	}
+	keys := make([]string, 0, len(merged))
+	for key := range merged {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "-e", key+"="+merged[key])
+	}
+
+	args = append(args, container.Image, "sh", "-c", command)
+
+	cmd := exec.Command(d.binary, args...)
+	cmd.Dir = root
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s run failed: %w", d.binary, err)
+	}
+
+	return nil
+}
+
+// withImageDigest folds an image digest into a directory hash, so the same code built
+// with a different toolchain gets a different artifact name
+func withImageDigest(hash, digest string) string {
+	sum := sha1.Sum([]byte(hash + "\n" + digest))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package slarty
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImageDigest(t *testing.T) {
+	binary, logPath := fakeDocker(t)
+	docker := NewDockerAdapter(binary)
+
+	digest, err := docker.ImageDigest("golang@sha256:abc123")
+	if err != nil {
+		t.Fatalf("ImageDigest failed: %v", err)
+	}
+	if digest != "sha256:abc123" {
+		t.Errorf("Expected the pinned digest, got %q", digest)
+	}
+
+	t.Setenv("FAKE_DOCKER_DIGEST", "feed")
+	digest, err = docker.ImageDigest("golang:1.22-image-digest-test")
+	if err != nil {
+		t.Fatalf("ImageDigest failed: %v", err)
+	}
+	if digest != "sha256:feed" {
+		t.Errorf("Expected the image ID, got %q", digest)
+	}
+
+	// The digest is cached, so a second lookup does not call docker again
+	if _, err := docker.ImageDigest("golang:1.22-image-digest-test"); err != nil {
+		t.Fatalf("ImageDigest failed: %v", err)
+	}
+	calls, _ := os.ReadFile(logPath)
+	if got := strings.Count(string(calls), "image inspect"); got != 1 {
+		t.Errorf("Expected 1 image inspect call, got %d:\n%s", got, calls)
+	}
+}
+
+func TestRunInContainer(t *testing.T) {
+	binary, logPath := fakeDocker(t)
+	docker := NewDockerAdapter(binary)
+	root := t.TempDir()
+
+	container := ContainerConfig{
+		Image:  "golang:1.22",
+		Mounts: []string{"cache:/root/.cache", "/tmp/shared:/shared:ro"},
+		Env:    map[string]string{"GOFLAGS": "-mod=mod", "CGO_ENABLED": "1"},
+	}
+	err := docker.RunInContainer(container, root, "echo built > out.txt", map[string]string{"CGO_ENABLED": "0"})
+	if err != nil {
+		t.Fatalf("RunInContainer failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "out.txt")); err != nil {
+		t.Errorf("Expected the command to run in the root directory: %v", err)
+	}
+
+	calls, _ := os.ReadFile(logPath)
+	call := strings.TrimSpace(string(calls))
+	for _, want := range []string{
+		"run --rm -v " + root + ":" + root + " -w " + root,
+		"-v " + filepath.Join(root, "cache") + ":/root/.cache",
+		"-v /tmp/shared:/shared:ro",
+		"-e CGO_ENABLED=0 -e GOFLAGS=-mod=mod",
+		"golang:1.22 sh -c echo built > out.txt",
+	} {
+		if !strings.Contains(call, want) {
+			t.Errorf("Expected %q in docker call, got:\n%s", want, call)
+		}
+	}
+}
+
+func TestGetArtifactNameContainer(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, args := range [][]string{{"init"}, {"add", "."}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	config := &ArtifactsConfig{
+		RootDirectory: tempDir,
+		Artifacts: []ArtifactConfig{
+			{Name: "plain", Directories: []string{"main.go"}, ArtifactPrefix: "app"},
+			{Name: "go122", Directories: []string{"main.go"}, ArtifactPrefix: "app", Container: &ContainerConfig{Image: "golang@sha256:122"}},
+			{Name: "go123", Directories: []string{"main.go"}, ArtifactPrefix: "app", Container: &ContainerConfig{Image: "golang@sha256:123"}},
+		},
+	}
+
+	names := make(map[string]bool)
+	for _, artifact := range config.Artifacts {
+		name, err := GetArtifactName(artifact.Name, config)
+		if err != nil {
+			t.Fatalf("GetArtifactName failed: %v", err)
+		}
+		names[name] = true
+	}
+	if len(names) != 3 {
+		t.Fatalf("Expected the image digest to change the artifact name, got %v", names)
+	}
+}
@@ -0,0 +1,329 @@
+package slarty
+
+import (
+	"fmt"
+	"time"
+)
+
+// The statuses of a DeployResult and a RestartResult, which are also those of a
+// RunResult
+const (
+	DeployDeployed   = "deployed"
+	DeploySkipped    = "skipped"
+	DeployFailed     = "failed"
+	RestartRestarted = "restarted"
+	RestartFailed    = "restart-failed"
+)
+
+// The steps of a deploy other than an artifact's that can fail it
+const (
+	DeployStepApproval    = "approval"
+	DeployStepMaintenance = "maintenance"
+)
+
+// DeployOptions are how DeployAll deploys artifacts
+type DeployOptions struct {
+	// ArtifactName names the archive to deploy for an artifact, such as from a pin or
+	// a manifest. The archive built from the current code is deployed when it is nil.
+	ArtifactName func(artifact ArtifactConfig) (string, error)
+	// NamedAfterCode reports whether an artifact's archive is named after the current
+	// code, which a shortened hash alone can't promise, so a different build with the
+	// same name is ruled out first. Every artifact is checked when it is nil.
+	NamedAfterCode func(artifact ArtifactConfig) bool
+	// Approve is called with the artifact names by artifact once every archive is
+	// found and before anything is changed, and stops the deploy if it fails
+	Approve func(artifactNames map[string]string) error
+	// Maintenance is entered before the first artifact is deployed and left after the
+	// restarts. A failed deploy leaves it entered.
+	Maintenance *Maintenance
+	// State records the artifacts deployed so far, so a deploy that fails part way
+	// through can be resumed. Artifacts it has already deployed from the same archive
+	// are skipped. A new state is started when it is nil.
+	State *DeployState
+	// Atomic stages every artifact before any of them is put in place, and then puts
+	// all of them in place together, or none of them
+	Atomic bool
+	// SkipRestarts leaves the artifacts' services alone after the deploy
+	SkipRestarts bool
+}
+
+// DeployResult is what DeployAll did with an artifact, or a step of the deploy that
+// failed
+type DeployResult struct {
+	Artifact     ArtifactConfig
+	ArtifactName string
+	// Step is the step that failed, such as DeployStepMaintenance, when the result is
+	// not an artifact's
+	Step string
+	// Status is DeployDeployed, DeploySkipped or DeployFailed
+	Status   string
+	Duration time.Duration
+	Err      error
+}
+
+// Name returns the name of the artifact, or of the step that failed
+func (r DeployResult) Name() string {
+	if r.Step != "" {
+		return r.Step
+	}
+	return r.Artifact.Name
+}
+
+// RestartResult is how restarting a service after a deploy went
+type RestartResult struct {
+	Restart
+	// Status is RestartRestarted or RestartFailed
+	Status   string
+	Duration time.Duration
+	Err      error
+}
+
+// DeployReport is what DeployAll did, in order
+type DeployReport struct {
+	Deploys  []DeployResult
+	Restarts []RestartResult
+}
+
+// RestartsFailed returns how many restarts failed
+func (r DeployReport) RestartsFailed() int {
+	failed := 0
+	for _, restart := range r.Restarts {
+		if restart.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// DeployAll deploys the artifacts and then restarts their services. Every archive is
+// looked up in the repository before anything is changed, and the deploy stops at the
+// first artifact that fails, returning its error along with what was done until then.
+// A failed restart does not undo the deploy and is only reported, so the state is
+// kept for a resumed deploy to retry the restarts.
+func (d *Deployer) DeployAll(artifacts []ArtifactConfig, opts DeployOptions) (DeployReport, error) {
+	state := opts.State
+	if state == nil {
+		state = NewDeployState()
+	}
+
+	d.observer().OnDeploysStart(artifacts)
+
+	var report DeployReport
+	finish := func(err error) (DeployReport, error) {
+		if err != nil && opts.Maintenance != nil {
+			opts.Maintenance.WarnIfActive()
+		}
+		d.observer().OnDeploysComplete(report, err)
+		return report, err
+	}
+	fail := func(result DeployResult) (DeployReport, error) {
+		result.Status = DeployFailed
+		report.Deploys = append(report.Deploys, result)
+		d.observer().OnArtifactDeployed(result)
+		return finish(result.Err)
+	}
+
+	for _, artifact := range artifacts {
+		if err := d.checkDeployable(artifact, opts); err != nil {
+			return fail(DeployResult{Artifact: artifact, Err: err})
+		}
+	}
+
+	// Get the artifact names first, so the repository can look them up in bulk
+	names := make([]string, len(artifacts))
+	var archives []string
+	for i, artifact := range artifacts {
+		artifactName, err := d.deployArtifactName(artifact, opts)
+		if err != nil {
+			return fail(DeployResult{Artifact: artifact, Err: err})
+		}
+		names[i] = artifactName
+		if !artifact.IsDocker() {
+			archives = append(archives, artifactName)
+		}
+	}
+	PrefetchArtifacts(d.Repo, archives)
+
+	for i, artifact := range artifacts {
+		if err := d.checkArchive(artifact, names[i], opts); err != nil {
+			return fail(DeployResult{Artifact: artifact, ArtifactName: names[i], Err: err})
+		}
+	}
+
+	// A protected environment needs someone else to approve the deploy first
+	if opts.Approve != nil {
+		artifactNames := make(map[string]string, len(artifacts))
+		for i, artifact := range artifacts {
+			artifactNames[artifact.Name] = names[i]
+		}
+		if err := opts.Approve(artifactNames); err != nil {
+			return fail(DeployResult{Step: DeployStepApproval, Err: err})
+		}
+	}
+
+	// Only enter maintenance mode once every archive is known to be there
+	if opts.Maintenance != nil {
+		if err := opts.Maintenance.Enable(); err != nil {
+			return fail(DeployResult{Step: DeployStepMaintenance, Err: err})
+		}
+	}
+
+	// An atomic deploy stages every artifact first and puts none in place until all
+	// of them have been staged
+	var atomic *atomicDeploy
+	var staged []DeployResult
+	if opts.Atomic {
+		atomic = newAtomicDeploy(d)
+	}
+	deploysStarted := d.Now()
+
+	for i, artifact := range artifacts {
+		result := DeployResult{Artifact: artifact, ArtifactName: names[i]}
+		d.observer().OnMessage(fmt.Sprintf("Found artifact %s for %s", result.ArtifactName, artifact.Name))
+		if state.Deployed[artifact.Name] == result.ArtifactName {
+			d.observer().OnMessage(" - Already deployed by the run being resumed")
+			result.Status = DeploySkipped
+			report.Deploys = append(report.Deploys, result)
+			d.observer().OnArtifactDeployed(result)
+			continue
+		}
+
+		started := d.Now()
+		var err error
+		if atomic != nil {
+			d.observer().OnDeployStart(artifact, result.ArtifactName)
+			err = atomic.Stage(artifact, result.ArtifactName)
+			d.observer().OnDeployComplete(artifact, result.ArtifactName, err)
+		} else {
+			err = d.Deploy(artifact, result.ArtifactName)
+		}
+		result.Duration = d.Now().Sub(started).Round(time.Millisecond)
+		if err != nil {
+			if atomic != nil {
+				atomic.Discard()
+				d.observer().OnMessage("Nothing was deployed, since --atomic was given")
+			}
+			result.Err = err
+			return fail(result)
+		}
+
+		if atomic != nil {
+			staged = append(staged, result)
+			continue
+		}
+		d.deployed(state, &report, result)
+	}
+
+	if atomic != nil {
+		if err := atomic.Commit(); err != nil {
+			// None of the staged artifacts is left in place
+			for _, result := range staged {
+				result.Status, result.Err = DeployFailed, err
+				report.Deploys = append(report.Deploys, result)
+				d.observer().OnArtifactDeployed(result)
+			}
+			return finish(err)
+		}
+		for _, result := range staged {
+			result.Duration = d.Now().Sub(deploysStarted).Round(time.Millisecond)
+			d.deployed(state, &report, result)
+		}
+	}
+
+	// Restart services once every artifact is deployed, so a service shared by
+	// several artifacts restarts once with all of the new code in place
+	if !opts.SkipRestarts {
+		for _, restart := range d.Config.Restarts(artifacts) {
+			report.Restarts = append(report.Restarts, d.Restart(restart))
+		}
+	}
+
+	if opts.Maintenance != nil {
+		if err := opts.Maintenance.Disable(); err != nil {
+			return fail(DeployResult{Step: DeployStepMaintenance, Err: err})
+		}
+	}
+
+	// Keep the state after a failed restart, so a resumed deploy only retries the
+	// restarts
+	if report.RestartsFailed() == 0 {
+		if err := RemoveDeployState(d.Config.RootDirectory); err != nil {
+			d.observer().OnWarning(err.Error())
+		}
+	}
+
+	return finish(nil)
+}
+
+// checkDeployable checks that an artifact can be deployed as opts asks, and that its
+// deploy locations stay under the root directory
+func (d *Deployer) checkDeployable(artifact ArtifactConfig, opts DeployOptions) error {
+	if artifact.IsDocker() {
+		if opts.Atomic {
+			return fmt.Errorf("cannot deploy docker artifact %s atomically", artifact.Name)
+		}
+		return nil
+	}
+	if err := artifact.CheckLocations(); err != nil {
+		return fmt.Errorf("cannot deploy %s: %w", artifact.Name, err)
+	}
+	return nil
+}
+
+// deployArtifactName returns the artifact name to deploy for an artifact
+func (d *Deployer) deployArtifactName(artifact ArtifactConfig, opts DeployOptions) (string, error) {
+	if opts.ArtifactName != nil {
+		return opts.ArtifactName(artifact)
+	}
+	return GetArtifactName(artifact.Name, d.Config)
+}
+
+// checkArchive checks that the archive or image for an artifact is in the repository
+// or registry, and that one named after the current code was built from it
+func (d *Deployer) checkArchive(artifact ArtifactConfig, artifactName string, opts DeployOptions) error {
+	var checker ArtifactChecker = d.Repo
+	if artifact.IsDocker() {
+		checker = d.Config.DockerAdapter()
+	}
+	exists, err := checker.ArtifactExists(artifactName)
+	if err != nil {
+		return fmt.Errorf("failed to check if artifact exists in repository: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s for %s", ErrArtifactNotFound, artifactName, artifact.Name)
+	}
+
+	if opts.NamedAfterCode == nil || opts.NamedAfterCode(artifact) {
+		return CheckHashCollision(d.Repo, d.Config, artifact, artifactName)
+	}
+	return nil
+}
+
+// deployed records an artifact that is in place, saving the state so a resumed deploy
+// skips it
+func (d *Deployer) deployed(state *DeployState, report *DeployReport, result DeployResult) {
+	state.Deployed[result.Artifact.Name] = result.ArtifactName
+	if err := state.Save(d.Config.RootDirectory); err != nil {
+		d.observer().OnWarning(err.Error())
+	}
+
+	result.Status = DeployDeployed
+	report.Deploys = append(report.Deploys, result)
+	d.observer().OnArtifactDeployed(result)
+}
+
+// Restart runs a restart command from the root directory. A failed restart is
+// reported in the result rather than as an error, since the deploy it follows is
+// already done.
+func (d *Deployer) Restart(restart Restart) RestartResult {
+	d.observer().OnMessage(fmt.Sprintf("Restarting %s", restart.Name))
+	started := d.Now()
+	err := d.runner().RunCommand(d.Config.RootDirectory, restart.Command, nil)
+
+	result := RestartResult{Restart: restart, Status: RestartRestarted, Duration: d.Now().Sub(started).Round(time.Millisecond)}
+	if err != nil {
+		result.Status, result.Err = RestartFailed, fmt.Errorf("%s: %w", restart.Command, err)
+	}
+	d.observer().OnRestartComplete(result)
+	return result
+}
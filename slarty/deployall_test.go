@@ -0,0 +1,254 @@
+package slarty
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// deployAllFixture returns a deployer for web and api archives holding version, with
+// its progress printed to out and written as events to stream
+func deployAllFixture(t *testing.T, version string, runner CommandRunner, out, stream *bytes.Buffer) (*Deployer, []ArtifactConfig) {
+	t.Helper()
+	repo, config := atomicFixture(t, version)
+	deployer := NewDeployer(config, repo)
+	deployer.Runner = runner
+	deployer.Observer = Observers{&PrintObserver{Out: out, Err: out}, NewEventObserver(NewEventWriter(stream), "do-deploys")}
+	artifacts := []ArtifactConfig{
+		{Name: "web", ArtifactPrefix: "web", DeployLocation: "public", Services: []string{"php-fpm"}},
+		{Name: "api", ArtifactPrefix: "api", DeployLocation: "api/current", DeployStrategy: DeployStrategySymlink, Services: []string{"php-fpm"}},
+	}
+	return deployer, artifacts
+}
+
+// deployOptionsFor returns options deploying each artifact from the archive holding
+// version
+func deployOptionsFor(version string) DeployOptions {
+	return DeployOptions{
+		ArtifactName: func(artifact ArtifactConfig) (string, error) {
+			return artifact.Name + "-" + version + ".tar.gz", nil
+		},
+		NamedAfterCode: func(ArtifactConfig) bool { return false },
+	}
+}
+
+// eventTypes returns the types and statuses of the events in stream
+func eventTypes(t *testing.T, stream *bytes.Buffer) []string {
+	t.Helper()
+	var types []string
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Line is not valid JSON: %v: %s", err, scanner.Text())
+		}
+		fields := []string{event.Type}
+		for _, field := range []string{event.Artifact + event.Service, event.Status} {
+			if field != "" {
+				fields = append(fields, field)
+			}
+		}
+		types = append(types, strings.Join(fields, " "))
+	}
+	return types
+}
+
+func TestDeployerDeployAll(t *testing.T) {
+	var out, stream bytes.Buffer
+	runner := &outputRunner{}
+	deployer, artifacts := deployAllFixture(t, "222", runner, &out, &stream)
+	root := deployer.Config.RootDirectory
+	deployer.Config.Maintenance = MaintenanceConfig{EnableCmd: "maintenance on", DisableCmd: "maintenance off"}
+
+	opts := deployOptionsFor("222")
+	opts.Maintenance = NewMaintenance(deployer.Config, runner)
+	opts.Maintenance.Observer = deployer.Observer
+	report, err := deployer.DeployAll(artifacts, opts)
+	if err != nil {
+		t.Fatalf("DeployAll failed: %v\n%s", err, out.String())
+	}
+
+	for _, result := range report.Deploys {
+		if result.Status != DeployDeployed || result.Err != nil {
+			t.Errorf("Expected %s to be deployed, got %+v", result.Name(), result)
+		}
+	}
+	if version := readVersion(t, filepath.Join(root, "public")); version != "222" {
+		t.Errorf("Expected web version 222, got %q", version)
+	}
+	if version := readVersion(t, filepath.Join(root, "api", "current")); version != "222" {
+		t.Errorf("Expected api version 222, got %q", version)
+	}
+
+	// The shared service restarts once, inside maintenance mode
+	expectedCommands := []string{"maintenance on", "systemctl restart 'php-fpm'", "maintenance off"}
+	if !reflect.DeepEqual(runner.commands, expectedCommands) {
+		t.Errorf("Expected commands %v, got %v", expectedCommands, runner.commands)
+	}
+	if len(report.Restarts) != 1 || report.RestartsFailed() != 0 {
+		t.Errorf("Expected one successful restart, got %+v", report.Restarts)
+	}
+
+	expectedEvents := []string{
+		"run-started",
+		"deploy-started web", "deploy-finished web deployed",
+		"deploy-started api", "deploy-finished api deployed",
+		"restart php-fpm restarted",
+		"run-finished succeeded",
+	}
+	if types := eventTypes(t, &stream); !reflect.DeepEqual(types, expectedEvents) {
+		t.Errorf("Expected events %v, got %v", expectedEvents, types)
+	}
+
+	// A finished deploy leaves nothing to resume
+	if _, err := os.Stat(DeployStatePath(root)); !os.IsNotExist(err) {
+		t.Errorf("Expected the deploy state to be removed, got %v", err)
+	}
+}
+
+func TestDeployerDeployAllResumesAndKeepsStateAfterFailedRestart(t *testing.T) {
+	var out, stream bytes.Buffer
+	runner := failingRunner{}
+	deployer, artifacts := deployAllFixture(t, "222", runner, &out, &stream)
+	root := deployer.Config.RootDirectory
+
+	state := NewDeployState()
+	state.Deployed["web"] = "web-222.tar.gz"
+	opts := deployOptionsFor("222")
+	opts.State = state
+	report, err := deployer.DeployAll(artifacts, opts)
+	if err != nil {
+		t.Fatalf("Expected a failed restart not to fail the deploy, got %v", err)
+	}
+
+	statuses := []string{report.Deploys[0].Status, report.Deploys[1].Status}
+	if !reflect.DeepEqual(statuses, []string{DeploySkipped, DeployDeployed}) {
+		t.Errorf("Expected web to be skipped and api deployed, got %v", statuses)
+	}
+	if _, err := os.Stat(filepath.Join(root, "public")); !os.IsNotExist(err) {
+		t.Errorf("Expected the resumed web deploy to be left alone, got %v", err)
+	}
+	if report.RestartsFailed() != 1 || !strings.Contains(report.Restarts[0].Err.Error(), "systemctl restart 'php-fpm'") {
+		t.Errorf("Expected the restart to fail, got %+v", report.Restarts)
+	}
+	if !strings.Contains(out.String(), "RESTART FAILED: php-fpm") || !strings.Contains(out.String(), "but 1 restart(s) failed") {
+		t.Errorf("Expected the failed restart to be reported, got:\n%s", out.String())
+	}
+
+	// The state is kept so a resumed deploy only retries the restart
+	saved, err := ReadDeployState(root)
+	if err != nil || saved == nil || saved.Deployed["api"] != "api-222.tar.gz" {
+		t.Errorf("Expected the state to record api, got %+v (%v)", saved, err)
+	}
+	if types := eventTypes(t, &stream); types[len(types)-1] != "run-finished restart-failed" {
+		t.Errorf("Expected the run to finish with a failed restart, got %v", types)
+	}
+}
+
+func TestDeployerDeployAllFailures(t *testing.T) {
+	t.Run("MissingArchive", func(t *testing.T) {
+		var out, stream bytes.Buffer
+		runner := &outputRunner{}
+		deployer, artifacts := deployAllFixture(t, "222", runner, &out, &stream)
+		deployer.Config.Maintenance = MaintenanceConfig{EnableCmd: "maintenance on"}
+		opts := deployOptionsFor("333")
+		opts.Maintenance = NewMaintenance(deployer.Config, runner)
+
+		report, err := deployer.DeployAll(artifacts, opts)
+		if !errors.Is(err, ErrArtifactNotFound) {
+			t.Fatalf("Expected a missing archive to fail the deploy, got %v", err)
+		}
+		if len(report.Deploys) != 1 || report.Deploys[0].Name() != "web" || report.Deploys[0].Status != DeployFailed {
+			t.Errorf("Expected web to fail, got %+v", report.Deploys)
+		}
+		// Nothing is changed until every archive is found
+		if len(runner.commands) != 0 || opts.Maintenance.Active() {
+			t.Errorf("Expected maintenance mode not to be entered, got %v", runner.commands)
+		}
+		expected := []string{"run-started", "deploy-finished web failed", "run-finished failed"}
+		if types := eventTypes(t, &stream); !reflect.DeepEqual(types, expected) {
+			t.Errorf("Expected events %v, got %v", expected, types)
+		}
+	})
+
+	t.Run("Approval", func(t *testing.T) {
+		var out, stream bytes.Buffer
+		deployer, artifacts := deployAllFixture(t, "222", &outputRunner{}, &out, &stream)
+		opts := deployOptionsFor("222")
+		var approved map[string]string
+		opts.Approve = func(artifactNames map[string]string) error {
+			approved = artifactNames
+			return errors.New("approval denied")
+		}
+
+		report, err := deployer.DeployAll(artifacts, opts)
+		if err == nil || report.Deploys[0].Step != DeployStepApproval {
+			t.Fatalf("Expected the approval to fail the deploy, got %+v (%v)", report.Deploys, err)
+		}
+		if approved["api"] != "api-222.tar.gz" {
+			t.Errorf("Expected the archives to be approved, got %v", approved)
+		}
+		if _, err := os.Stat(filepath.Join(deployer.Config.RootDirectory, "public")); !os.IsNotExist(err) {
+			t.Errorf("Expected nothing to be deployed, got %v", err)
+		}
+	})
+
+	t.Run("AtomicDocker", func(t *testing.T) {
+		var out, stream bytes.Buffer
+		deployer, artifacts := deployAllFixture(t, "222", &outputRunner{}, &out, &stream)
+		artifacts = append(artifacts, ArtifactConfig{Name: "worker", Type: ArtifactTypeDocker})
+		opts := deployOptionsFor("222")
+		opts.Atomic = true
+
+		if _, err := deployer.DeployAll(artifacts, opts); err == nil || !strings.Contains(err.Error(), "atomically") {
+			t.Errorf("Expected a docker artifact to be refused, got %v", err)
+		}
+	})
+
+	t.Run("MaintenanceLeftOn", func(t *testing.T) {
+		var out, stream bytes.Buffer
+		runner := failingRunner{}
+		deployer, artifacts := deployAllFixture(t, "222", runner, &out, &stream)
+		deployer.Config.Maintenance = MaintenanceConfig{EnableCmd: "maintenance on"}
+		opts := deployOptionsFor("222")
+		opts.Maintenance = NewMaintenance(deployer.Config, runner)
+		opts.Maintenance.Observer = deployer.Observer
+
+		report, err := deployer.DeployAll(artifacts, opts)
+		if err == nil || report.Deploys[0].Step != DeployStepMaintenance {
+			t.Fatalf("Expected entering maintenance mode to fail the deploy, got %+v (%v)", report.Deploys, err)
+		}
+		if !strings.Contains(out.String(), "WARNING: the deploy failed, so the application was left in maintenance mode") {
+			t.Errorf("Expected a warning about maintenance mode, got:\n%s", out.String())
+		}
+	})
+}
+
+func TestDeployerRestart(t *testing.T) {
+	root := t.TempDir()
+	var out bytes.Buffer
+	deployer := NewDeployer(&ArtifactsConfig{RootDirectory: root}, nil)
+	deployer.Observer = &PrintObserver{Out: &out, Err: &out}
+
+	result := deployer.Restart(Restart{Name: "php-fpm", Command: "touch restarted"})
+	if result.Status != RestartRestarted || result.Err != nil {
+		t.Errorf("Expected the restart to succeed, got %+v", result)
+	}
+	if !strings.Contains(out.String(), "Restarting php-fpm\n - Restarted php-fpm") {
+		t.Errorf("Expected the restart to be reported, got:\n%s", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(root, "restarted")); err != nil {
+		t.Errorf("Expected the command to run in the root directory: %v", err)
+	}
+
+	result = deployer.Restart(Restart{Name: "nginx", Command: "exit 3"})
+	if result.Status != RestartFailed || !strings.Contains(result.Err.Error(), "exit 3") {
+		t.Errorf("Expected the restart to fail, got %+v", result)
+	}
+}
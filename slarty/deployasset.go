@@ -0,0 +1,138 @@
+package slarty
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dstockto/slarty/slarty/archive"
+)
+
+// DeployAsset downloads an asset from filename in the repository into its deploy
+// location, extracting it unless it is a plain file. An asset with a sha256 is
+// verified before anything reaches the deploy location.
+func (d *Deployer) DeployAsset(asset Asset, filename string) error {
+	d.observer().OnMessage(fmt.Sprintf("Found asset %s (%s)", asset.Name, filename))
+
+	// Create the deploy location directory if it doesn't exist
+	deployPath := filepath.Join(d.Config.RootDirectory, asset.DeployLocation)
+	if err := os.MkdirAll(deployPath, 0755); err != nil {
+		return fmt.Errorf("failed to create deploy directory: %w", err)
+	}
+
+	// A plain file is copied into the deploy location under its configured name,
+	// otherwise the asset is downloaded and extracted into the deploy location
+	var err error
+	destPath := deployPath
+	if !asset.ShouldUnpack() {
+		destPath = filepath.Join(deployPath, filepath.Base(asset.Filename))
+		err = d.deployAssetFile(filename, asset.SHA256, destPath)
+	} else if asset.SHA256 != "" {
+		err = d.deployVerifiedAsset(filename, asset.SHA256, deployPath)
+	} else {
+		_, err = d.Extract(filename, deployPath)
+	}
+	if errors.Is(err, ErrArtifactNotFound) {
+		return fmt.Errorf("asset %s not found in repository: %w", filename, err)
+	}
+	var downloadErr *DownloadError
+	if errors.As(err, &downloadErr) {
+		return fmt.Errorf("failed to retrieve asset from repository: %w", downloadErr.Err)
+	}
+	if errors.Is(err, ErrChecksumMismatch) {
+		return fmt.Errorf("refusing to deploy asset %s: %w", asset.Name, err)
+	}
+	if err != nil && !asset.ShouldUnpack() {
+		return fmt.Errorf("failed to copy asset: %w", err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract asset: %w", err)
+	}
+
+	if asset.ShouldUnpack() {
+		d.observer().OnMessage(" - Downloaded and extracted asset")
+	} else {
+		d.observer().OnMessage(fmt.Sprintf(" - Downloaded asset to %s", destPath))
+	}
+	if err := d.ApplyPermissions(destPath, asset.Permissions()); err != nil {
+		return fmt.Errorf("failed to set permissions on asset %s: %w", asset.Name, err)
+	}
+	return nil
+}
+
+// deployAssetFile downloads an asset that is not an archive to destPath. The file is
+// written next to destPath and renamed into place once it is complete and, if
+// expected is set, its SHA-256 matches, so a partial or replaced file is never left
+// at destPath.
+func (d *Deployer) deployAssetFile(filename, expected, destPath string) error {
+	if err := d.preflight(filename, filepath.Dir(destPath), ""); err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(destPath), "."+filepath.Base(destPath)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempFilePath := tempFile.Name()
+	defer os.Remove(tempFilePath)
+
+	hash := sha256.New()
+	err = d.Repo.RetrieveArtifact(filename, io.MultiWriter(tempFile, hash))
+	if err == nil {
+		err = tempFile.Chmod(0644)
+	} else {
+		err = &DownloadError{Err: err}
+	}
+	if closeErr := tempFile.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if expected != "" {
+		if err := VerifySHA256(filename, expected, hex.EncodeToString(hash.Sum(nil))); err != nil {
+			return err
+		}
+		d.observer().OnMessage(" - Verified sha256")
+	}
+
+	return os.Rename(tempFilePath, destPath)
+}
+
+// deployVerifiedAsset downloads an asset in full, checks it against its expected
+// SHA-256 digest and only then extracts it, so a replaced file never reaches the
+// deploy location
+func (d *Deployer) deployVerifiedAsset(filename, expected, deployPath string) error {
+	root := d.Config.RootDirectory
+	if err := d.preflight(filename, filepath.Join(root, WorkDirName, "tmp"), deployPath); err != nil {
+		return err
+	}
+
+	tempFile, err := CreateTempArchive(root, "slarty-asset-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tempFilePath := tempFile.Name()
+	defer os.Remove(tempFilePath)
+
+	hash := sha256.New()
+	err = d.Repo.RetrieveArtifact(filename, io.MultiWriter(tempFile, hash))
+	if closeErr := tempFile.Close(); err == nil && closeErr != nil {
+		return closeErr
+	}
+	if err != nil {
+		return &DownloadError{Err: err}
+	}
+
+	if err := VerifySHA256(filename, expected, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return err
+	}
+	d.observer().OnMessage(" - Verified sha256")
+
+	return archive.ExtractFile(tempFilePath, deployPath, d.ReadOptions())
+}
@@ -0,0 +1,137 @@
+package slarty
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty/archive"
+)
+
+func TestDeployerDeployVerifiedAsset(t *testing.T) {
+	root := t.TempDir()
+	sourceDir := filepath.Join(t.TempDir(), "library")
+	if err := os.MkdirAll(filepath.Join(sourceDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "sub", "app.js"), []byte("console.log(1)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewLocalRepositoryAdapter(t.TempDir())
+	if _, err := NewBuilder(nil, repo).StoreArchive(archive.TarGz, []archive.Source{{Dir: sourceDir}}, nil, "library-1.0.tar.gz", SizeBudget{}); err != nil {
+		t.Fatalf("StoreArchive failed: %v", err)
+	}
+	var stored bytes.Buffer
+	if err := repo.RetrieveArtifact("library-1.0.tar.gz", &stored); err != nil {
+		t.Fatalf("RetrieveArtifact failed: %v", err)
+	}
+	digest := sha256.Sum256(stored.Bytes())
+	checksum := strings.ToUpper(hex.EncodeToString(digest[:]))
+
+	var out bytes.Buffer
+	deployer := NewDeployer(&ArtifactsConfig{RootDirectory: root}, repo)
+	deployer.Observer = &PrintObserver{Out: &out, Err: &out}
+
+	// A file that was replaced in the repository is not extracted
+	wrongPath := filepath.Join(root, "wrong")
+	err := deployer.deployVerifiedAsset("library-1.0.tar.gz", strings.Repeat("0", 64), wrongPath)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Expected a checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wrongPath, "sub", "app.js")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be extracted on a mismatch, got %v", err)
+	}
+
+	deployPath := filepath.Join(root, "lib")
+	if err := deployer.deployVerifiedAsset("library-1.0.tar.gz", checksum, deployPath); err != nil {
+		t.Fatalf("Expected the matching asset to deploy, got %v", err)
+	}
+	if !strings.Contains(out.String(), "Verified sha256") {
+		t.Errorf("Expected the verification to be reported, got:\n%s", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(deployPath, "sub", "app.js")); err != nil {
+		t.Errorf("Expected the asset to be extracted: %v", err)
+	}
+
+	// The archive is checked against the limits before it is downloaded
+	deployer.Config.Extraction.MaxArchiveBytes = 4
+	if err := deployer.deployVerifiedAsset("library-1.0.tar.gz", checksum, deployPath); !errors.Is(err, ErrExtractionLimit) {
+		t.Errorf("Expected a max_archive_bytes error, got %v", err)
+	}
+	deployer.Config.Extraction.MaxArchiveBytes = 0
+
+	// A missing file is a download error
+	var downloadErr *DownloadError
+	if err := deployer.deployVerifiedAsset("missing.tar.gz", checksum, deployPath); !errors.As(err, &downloadErr) {
+		t.Errorf("Expected a download error for a missing asset, got %v", err)
+	}
+}
+
+func TestDeployerDeployAssetFile(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := NewLocalRepositoryAdapter(filepath.Join(tempDir, "repo"))
+	content := "geoip database"
+	if err := repo.StoreArtifact(strings.NewReader(content), "GeoLite2-City-2025.mmdb"); err != nil {
+		t.Fatalf("Failed to store asset: %v", err)
+	}
+	digest := sha256.Sum256([]byte(content))
+	checksum := hex.EncodeToString(digest[:])
+	deployer := NewDeployer(&ArtifactsConfig{RootDirectory: tempDir}, repo)
+	deployer.Observer = nil
+
+	deployPath := filepath.Join(tempDir, "geoip")
+	if err := os.MkdirAll(deployPath, 0755); err != nil {
+		t.Fatalf("Failed to create deploy directory: %v", err)
+	}
+	destPath := filepath.Join(deployPath, "GeoLite2-City.mmdb")
+	if err := os.WriteFile(destPath, []byte("old database"), 0644); err != nil {
+		t.Fatalf("Failed to write existing file: %v", err)
+	}
+
+	// A mismatch leaves the deployed file alone
+	err := deployer.deployAssetFile("GeoLite2-City-2025.mmdb", strings.Repeat("0", 64), destPath)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Expected a checksum mismatch, got %v", err)
+	}
+	if data, _ := os.ReadFile(destPath); string(data) != "old database" {
+		t.Errorf("Expected the existing file to be kept on a mismatch, got %q", data)
+	}
+
+	if err := deployer.deployAssetFile("GeoLite2-City-2025.mmdb", checksum, destPath); err != nil {
+		t.Fatalf("deployAssetFile failed: %v", err)
+	}
+	if data, _ := os.ReadFile(destPath); string(data) != content {
+		t.Errorf("Expected the asset to be copied, got %q", data)
+	}
+	info, err := os.Stat(destPath)
+	if err != nil {
+		t.Fatalf("Failed to stat deployed asset: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Expected the asset to be readable, got %v", info.Mode())
+	}
+
+	deployer.Config.Extraction.MaxArchiveBytes = 4
+	err = deployer.deployAssetFile("GeoLite2-City-2025.mmdb", "", destPath)
+	if !errors.Is(err, ErrExtractionLimit) {
+		t.Errorf("Expected a max_archive_bytes error, got %v", err)
+	}
+
+	deployer.Config.Extraction.MaxArchiveBytes = 0
+	err = deployer.deployAssetFile("missing.mmdb", "", destPath)
+	var downloadErr *DownloadError
+	if !errors.As(err, &downloadErr) {
+		t.Errorf("Expected a download error for a missing asset, got %v", err)
+	}
+
+	// Only the deployed file is left behind
+	entries, err := os.ReadDir(deployPath)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("Expected only the deployed file in %s, got %d entries (%v)", deployPath, len(entries), err)
+	}
+}
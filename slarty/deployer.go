@@ -0,0 +1,376 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dstockto/slarty/slarty/archive"
+)
+
+// Deployer downloads artifacts from a repository and extracts them into their deploy
+// locations, or into releases for the symlink strategy. Like Builder, its repository
+// and clock can be swapped out, and its progress is reported to its Observer.
+type Deployer struct {
+	Config *ArtifactsConfig
+	Repo   RepositoryAdapter
+	// Backup keeps the old contents of each deploy location when it is set
+	Backup *Backup
+	// Keep is how many old releases are kept for the symlink strategy
+	Keep int
+	// MaxFileBytes caps the size of each extracted file, archive.DefaultMaxFileBytes
+	// when it is 0
+	MaxFileBytes int64
+	// SkipSpaceCheck extracts without first checking there is room for the contents,
+	// so a deploy fails part way through if the disk fills up. It is set by
+	// --no-space-check.
+	SkipSpaceCheck bool
+	// Runner runs the restart commands after a deploy
+	Runner CommandRunner
+	Now    func() time.Time
+	// Observer is told how the deploy progresses, and ignores it when nil
+	Observer Observer
+}
+
+// NewDeployer returns a Deployer extracting archives from repo, printing its progress
+// to stdout and stderr
+func NewDeployer(config *ArtifactsConfig, repo RepositoryAdapter) *Deployer {
+	return &Deployer{
+		Config:   config,
+		Repo:     repo,
+		Runner:   ShellRunner{},
+		Now:      time.Now,
+		Observer: &PrintObserver{},
+	}
+}
+
+// observer returns the Observer to report to
+func (d *Deployer) observer() Observer {
+	if d.Observer == nil {
+		return NopObserver{}
+	}
+	return d.Observer
+}
+
+// runner returns the CommandRunner to run commands with
+func (d *Deployer) runner() CommandRunner {
+	if d.Runner == nil {
+		return ShellRunner{}
+	}
+	return d.Runner
+}
+
+// DownloadError is returned when an archive could not be read from the repository,
+// as opposed to a problem extracting it
+type DownloadError struct {
+	Err error
+}
+
+func (e *DownloadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DownloadError) Unwrap() error {
+	return e.Err
+}
+
+// Deploy streams an artifact's archive from the repository and extracts it into the
+// artifact's deploy location as it downloads, or deploys it as a release for the
+// symlink strategy. A docker artifact's image is pulled from its registry instead.
+func (d *Deployer) Deploy(artifact ArtifactConfig, artifactName string) error {
+	d.observer().OnDeployStart(artifact, artifactName)
+	err := d.deploy(artifact, artifactName)
+	d.observer().OnDeployComplete(artifact, artifactName, err)
+	return err
+}
+
+// deploy deploys an artifact as Deploy describes
+func (d *Deployer) deploy(artifact ArtifactConfig, artifactName string) error {
+	if artifact.IsDocker() {
+		return d.deployImage(artifact, artifactName)
+	}
+	if artifact.UsesSymlinkStrategy() {
+		return d.deployRelease(artifact, artifactName)
+	}
+
+	deployPath := filepath.Join(d.Config.RootDirectory, artifact.DeployLocation)
+	if d.Backup != nil {
+		dest, err := d.Backup.Save(deployPath)
+		if err != nil {
+			return err
+		}
+		if dest != "" {
+			d.observer().OnMessage(fmt.Sprintf(" - Backed up the old contents to %s", dest))
+		}
+	}
+	if err := d.ExtractArtifact(artifact, artifactName, deployPath); err != nil {
+		return err
+	}
+
+	if err := d.ApplyPermissions(deployPath, artifact.Permissions()); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	return nil
+}
+
+// deployImage pulls a docker artifact's image and tags it as the artifact's
+// deploy_location when one is set
+func (d *Deployer) deployImage(artifact ArtifactConfig, imageRef string) error {
+	started := d.Now()
+	err := d.Config.DockerAdapter().PullImage(imageRef, artifact.DeployLocation)
+	d.retrieved(artifact, imageRef, 0, started, err)
+	if err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+
+	if artifact.DeployLocation != "" && artifact.DeployLocation != imageRef {
+		d.observer().OnMessage(fmt.Sprintf(" - Tagged image as %s", artifact.DeployLocation))
+	}
+	return nil
+}
+
+// deployRelease extracts an artifact into its own release directory and then points
+// the symlink at the deploy location to it, so the switch to the new code is atomic.
+// A release that is already extracted, such as the one being rolled back to, is only
+// relinked. Old releases beyond Keep are removed afterwards.
+func (d *Deployer) deployRelease(artifact ArtifactConfig, artifactName string) error {
+	release, err := d.ExtractRelease(artifact, artifactName)
+	if err != nil {
+		return err
+	}
+	if err := d.LinkRelease(artifact, release); err != nil {
+		return err
+	}
+	d.PruneReleases(artifact, release)
+
+	return nil
+}
+
+// ExtractRelease makes sure the release for an artifact name is extracted into the
+// artifact's releases directory and returns the release's name
+func (d *Deployer) ExtractRelease(artifact ArtifactConfig, artifactName string) (string, error) {
+	releasesPath := artifact.ReleasesPath(d.Config.RootDirectory)
+	release := ReleaseName(artifact, artifactName, d.Config)
+	releasePath := filepath.Join(releasesPath, release)
+
+	if _, err := os.Stat(releasePath); err == nil {
+		// Mark it as the newest release so it is not pruned
+		now := d.Now()
+		if err := os.Chtimes(releasePath, now, now); err != nil {
+			return "", fmt.Errorf("failed to update release %s: %w", release, err)
+		}
+		d.observer().OnMessage(fmt.Sprintf(" - Release %s is already extracted", release))
+		return release, nil
+	}
+
+	if err := os.MkdirAll(releasesPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create releases directory: %w", err)
+	}
+
+	// Extract next to the release and rename it into place once it is complete,
+	// so an interrupted deploy never leaves a partial release behind
+	tempPath, err := os.MkdirTemp(releasesPath, "."+release+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create release directory: %w", err)
+	}
+	defer os.RemoveAll(tempPath)
+
+	if err := d.ExtractArtifact(artifact, artifactName, tempPath); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tempPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create release directory: %w", err)
+	}
+	if err := d.ApplyPermissions(tempPath, artifact.Permissions()); err != nil {
+		return "", fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := os.Rename(tempPath, releasePath); err != nil {
+		return "", fmt.Errorf("failed to move release into place: %w", err)
+	}
+
+	return release, nil
+}
+
+// LinkRelease points the symlink at the artifact's deploy location to a release
+func (d *Deployer) LinkRelease(artifact ArtifactConfig, release string) error {
+	root := d.Config.RootDirectory
+	linkPath := filepath.Join(root, artifact.DeployLocation)
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		return fmt.Errorf("failed to create deploy directory: %w", err)
+	}
+	if err := LinkRelease(linkPath, filepath.Join(artifact.ReleasesPath(root), release)); err != nil {
+		return err
+	}
+	d.observer().OnMessage(fmt.Sprintf(" - Linked %s to release %s", artifact.DeployLocation, release))
+
+	return nil
+}
+
+// PruneReleases removes the artifact's old releases beyond Keep. The deploy has
+// succeeded by the time it runs, so failing to prune is only a warning.
+func (d *Deployer) PruneReleases(artifact ArtifactConfig, current string) {
+	removed, err := PruneReleases(artifact.ReleasesPath(d.Config.RootDirectory), d.Keep, current)
+	for _, name := range removed {
+		d.observer().OnMessage(fmt.Sprintf(" - Removed old release %s", name))
+	}
+	if err != nil {
+		d.observer().OnWarning(err.Error())
+	}
+}
+
+// ExtractArtifact streams an artifact's archive from the repository and extracts it
+// into deployPath as it downloads
+func (d *Deployer) ExtractArtifact(artifact ArtifactConfig, artifactName, deployPath string) error {
+	// Create the deploy location directory if it doesn't exist
+	err := os.MkdirAll(deployPath, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create deploy directory: %w", err)
+	}
+
+	// Download the artifact from the repository and extract it to the deploy location
+	started := d.Now()
+	size, err := d.Extract(artifactName, deployPath)
+	var downloadErr *DownloadError
+	if errors.As(err, &downloadErr) {
+		d.retrieved(artifact, artifactName, 0, started, downloadErr.Err)
+		return fmt.Errorf("failed to retrieve artifact from repository: %w", downloadErr.Err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract artifact: %w", err)
+	}
+	d.retrieved(artifact, artifactName, size, started, nil)
+
+	return nil
+}
+
+// retrieved reports a download to the Observer
+func (d *Deployer) retrieved(artifact ArtifactConfig, artifactName string, size int64, started time.Time, err error) {
+	d.observer().OnDownloadComplete(artifact, artifactName, size, d.Now().Sub(started), err)
+}
+
+// ApplyPermissions gives deployed files the owner, group and mode from the
+// configuration. Without the privileges to change ownership it warns and carries on,
+// so a deploy as an ordinary user still works.
+func (d *Deployer) ApplyPermissions(path string, perms Permissions) error {
+	if !perms.IsSet() {
+		return nil
+	}
+
+	err := ApplyPermissions(path, perms)
+	if errors.Is(err, ErrChownNotPermitted) {
+		d.observer().OnWarning(fmt.Sprintf("%v (run as root or with CAP_CHOWN to set the owner and group)", err))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	d.observer().OnMessage(" - Set owner, group and mode")
+
+	return nil
+}
+
+// Extract extracts an archive into destDir while it is downloaded from the
+// repository, so the archive is never written to disk, and returns its size. The
+// format is detected from the first bytes of the archive. A zip archive keeps its
+// index at the end, so it is downloaded to a temporary file under the root directory
+// first. A failed download is returned as a *DownloadError.
+func (d *Deployer) Extract(artifactName, destDir string) (int64, error) {
+	// Refuse an archive that is too large, or that there is no room to extract, before
+	// downloading any of it
+	if err := d.preflight(artifactName, "", destDir); err != nil {
+		return 0, err
+	}
+
+	pr, pw := io.Pipe()
+	downloaded := make(chan error, 1)
+	go func() {
+		err := d.Repo.RetrieveArtifact(artifactName, pw)
+		pw.CloseWithError(err)
+		downloaded <- err
+	}()
+
+	counter := &countingReader{r: pr}
+	extractErr := archive.Extract(counter, destDir, d.ReadOptions())
+	// Stop the download if extraction gave up part way through
+	pr.CloseWithError(extractErr)
+
+	// A download error that is only the extraction failure echoed back is reported as
+	// the extraction failure
+	if err := <-downloaded; err != nil && !errors.Is(err, extractErr) {
+		return 0, &DownloadError{Err: err}
+	}
+	if extractErr != nil {
+		return 0, extractErr
+	}
+
+	return counter.n, nil
+}
+
+// preflight checks an archive against the extraction limits and, unless SkipSpaceCheck
+// is set, that there is room to download it into downloadDir and extract it into
+// extractDir, leaving out either directory that is "". It is skipped when the
+// repository can't tell the size of the archive.
+func (d *Deployer) preflight(artifactName, downloadDir, extractDir string) error {
+	limits := d.Config.Extraction
+	sizer, ok := d.Repo.(ArtifactSizer)
+	if !ok || (limits.MaxArchiveBytes <= 0 && d.SkipSpaceCheck) {
+		return nil
+	}
+	size, err := sizer.ArtifactSize(artifactName)
+	if err != nil {
+		return nil
+	}
+
+	if err := limits.CheckArchiveSize(size); err != nil {
+		return err
+	}
+	if d.SkipSpaceCheck {
+		return nil
+	}
+	if downloadDir != "" {
+		if err := CheckFreeSpace(downloadDir, size, "downloading "+artifactName); err != nil {
+			return err
+		}
+	}
+	if extractDir != "" {
+		if err := CheckFreeSpace(extractDir, limits.EstimateExtractedSize(size), "extracting "+artifactName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadOptions returns how archives are extracted within the configured limits, with
+// any temporary copy a format needs, such as the download of a zip archive, kept in
+// the temp directory under the root directory
+func (d *Deployer) ReadOptions() archive.ReadOptions {
+	root := d.Config.RootDirectory
+	return archive.ReadOptions{
+		Limits:       d.Config.Extraction,
+		MaxFileBytes: d.MaxFileBytes,
+		TempFile: func(pattern string) (*os.File, error) {
+			return CreateTempArchive(root, pattern)
+		},
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+	// progress, when it is set, is called with the bytes read so far
+	progress func(int64)
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.progress != nil && n > 0 {
+		c.progress(c.n)
+	}
+	return n, err
+}
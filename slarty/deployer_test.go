@@ -0,0 +1,67 @@
+package slarty
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dstockto/slarty/slarty/archive"
+)
+
+func TestDeployerDeployRelease(t *testing.T) {
+	buildRoot, deployRoot := t.TempDir(), t.TempDir()
+	var out bytes.Buffer
+	builder := testBuilder(t, buildRoot, &outputRunner{}, &out)
+	for _, version := range []string{"111", "222"} {
+		dir := filepath.Join(buildRoot, version)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "VERSION"), []byte(version), 0644); err != nil {
+			t.Fatal(err)
+		}
+		sources, err := builder.OutputSources(ArtifactConfig{Name: "web", OutputDirectory: OutputDirectories{version}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := builder.StoreArchive(archive.TarGz, sources, nil, "web-"+version+".tar.gz", SizeBudget{}); err != nil {
+			t.Fatalf("StoreArchive failed: %v", err)
+		}
+	}
+
+	deployer := NewDeployer(&ArtifactsConfig{RootDirectory: deployRoot}, builder.Repo)
+	observer := &recordingObserver{PrintObserver: PrintObserver{Out: &out, Err: &out}}
+	deployer.Observer = observer
+	deployer.Keep = 1
+
+	artifact := ArtifactConfig{Name: "web", ArtifactPrefix: "web", DeployLocation: "current", DeployStrategy: DeployStrategySymlink}
+	for _, version := range []string{"111", "222"} {
+		if err := deployer.Deploy(artifact, "web-"+version+".tar.gz"); err != nil {
+			t.Fatalf("Deploy failed for %s: %v", version, err)
+		}
+		content, err := os.ReadFile(filepath.Join(deployRoot, "current", "VERSION"))
+		if err != nil || string(content) != version {
+			t.Errorf("Expected current to hold %s, got %q (%v)", version, content, err)
+		}
+	}
+	expected := []string{
+		"deploy start web-111.tar.gz", "download web-111.tar.gz", "deploy complete web-111.tar.gz",
+		"deploy start web-222.tar.gz", "download web-222.tar.gz", "deploy complete web-222.tar.gz",
+	}
+	if !reflect.DeepEqual(observer.events, expected) {
+		t.Errorf("Expected both deploys reported, got %v", observer.events)
+	}
+	if !strings.Contains(out.String(), " - Linked current to release 222") {
+		t.Errorf("Expected the relinking to be printed, got %q", out.String())
+	}
+
+	// A missing archive is a download error rather than a bad archive
+	var downloadErr *DownloadError
+	if _, err := deployer.Extract("web-333.tar.gz", filepath.Join(deployRoot, "missing")); !errors.As(err, &downloadErr) {
+		t.Errorf("Expected a DownloadError, got %v", err)
+	}
+}
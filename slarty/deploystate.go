@@ -0,0 +1,85 @@
+package slarty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DeployState records the artifacts a do-deploys run has deployed so far, so a run
+// that failed part way through can be resumed without deploying them again
+type DeployState struct {
+	StartedAt time.Time `json:"started_at"`
+	// Deployed maps each artifact deployed to the artifact name it was deployed from
+	Deployed map[string]string `json:"deployed"`
+}
+
+// NewDeployState returns the state for a run starting now
+func NewDeployState() *DeployState {
+	return &DeployState{StartedAt: time.Now().UTC(), Deployed: make(map[string]string)}
+}
+
+// DeployStatePath returns the file the deploy state is kept in under the project root
+func DeployStatePath(root string) string {
+	return filepath.Join(root, WorkDirName, "deploy-state.json")
+}
+
+// ReadDeployState reads the state left by a run that did not finish. It returns nil
+// if there is none.
+func ReadDeployState(root string) (*DeployState, error) {
+	data, err := os.ReadFile(DeployStatePath(root))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deploy state: %w", err)
+	}
+
+	var state DeployState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse deploy state %s: %w", DeployStatePath(root), err)
+	}
+	if state.Deployed == nil {
+		state.Deployed = make(map[string]string)
+	}
+	return &state, nil
+}
+
+// Save writes the state under the project root. It is written to a temporary file
+// and renamed into place, so a crash never leaves a partly written state behind.
+func (s *DeployState) Save(root string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := CreateTempArchive(root, "deploy-state-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save deploy state: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(append(data, '\n'))
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), DeployStatePath(root))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save deploy state: %w", err)
+	}
+	return nil
+}
+
+// RemoveDeployState removes the deploy state once a run has finished
+func RemoveDeployState(root string) error {
+	err := os.Remove(DeployStatePath(root))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove deploy state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package slarty
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDeployState(t *testing.T) {
+	root := t.TempDir()
+
+	if state, err := ReadDeployState(root); state != nil || err != nil {
+		t.Errorf("Expected no state before a run, got %v (%v)", state, err)
+	}
+
+	state := NewDeployState()
+	state.Deployed["api"] = "api-abc.tar.gz"
+	if err := state.Save(root); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	state.Deployed["web"] = "web-def.tar.gz"
+	if err := state.Save(root); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	read, err := ReadDeployState(root)
+	if err != nil {
+		t.Fatalf("ReadDeployState failed: %v", err)
+	}
+	if len(read.Deployed) != 2 || read.Deployed["web"] != "web-def.tar.gz" || !read.StartedAt.Equal(state.StartedAt) {
+		t.Errorf("Expected the saved state back, got %+v", read)
+	}
+
+	if err := RemoveDeployState(root); err != nil {
+		t.Fatalf("RemoveDeployState failed: %v", err)
+	}
+	if err := RemoveDeployState(root); err != nil {
+		t.Errorf("Expected removing a missing state to succeed, got %v", err)
+	}
+	if state, err := ReadDeployState(root); state != nil || err != nil {
+		t.Errorf("Expected no state after it is removed, got %v (%v)", state, err)
+	}
+
+	if err := os.WriteFile(DeployStatePath(root), []byte("{"), 0644); err != nil {
+		t.Fatalf("Failed to write state: %v", err)
+	}
+	if _, err := ReadDeployState(root); err == nil {
+		t.Errorf("Expected a corrupt state to be an error")
+	}
+}
@@ -0,0 +1,49 @@
+package slarty
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// deprecatedConfigKeys maps config keys that are still read, but only with a warning,
+// to the key that replaced them
+var deprecatedConfigKeys = map[string]string{
+	"repository.options.bucket_name": "bucket-name",
+	"repository.options.path_prefix": "path-prefix",
+}
+
+// applyDeprecatedKeys reads the deprecated keys in the config at path from data into
+// config, warning about each one. Where the key that replaced it is also set, that
+// key wins.
+func applyDeprecatedKeys(path string, data []byte, config *ArtifactsConfig) error {
+	var deprecated struct {
+		Repository struct {
+			Options struct {
+				BucketName *string `json:"bucket_name"`
+				PathPrefix *string `json:"path_prefix"`
+			} `json:"options"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(data, &deprecated); err != nil {
+		return err
+	}
+
+	options := &config.Repository.Options
+	apply := func(key string, value *string, field *string) {
+		if value == nil {
+			return
+		}
+		replacement := deprecatedConfigKeys[key]
+		if *field != "" {
+			fmt.Fprintf(os.Stderr, "WARNING: %s sets both %s and %s; %s is deprecated and ignored, and slarty config migrate removes it\n", path, key, replacement, key)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "WARNING: %s uses %s, which is deprecated: rename it to %s, or run slarty config migrate\n", path, key, replacement)
+		*field = *value
+	}
+	apply("repository.options.bucket_name", deprecated.Repository.Options.BucketName, &options.BucketName)
+	apply("repository.options.path_prefix", deprecated.Repository.Options.PathPrefix, &options.PathPrefix)
+
+	return nil
+}
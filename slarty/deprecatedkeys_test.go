@@ -0,0 +1,37 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadArtifactsJsonDeprecatedKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    string
+		bucketName string
+		pathPrefix string
+	}{
+		{"kebab-case", `{"bucket-name": "builds", "path-prefix": "app"}`, "builds", "app"},
+		{"snake_case", `{"bucket_name": "builds", "path_prefix": "app"}`, "builds", "app"},
+		{"both", `{"bucket-name": "builds", "bucket_name": "old", "path_prefix": "app"}`, "builds", "app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "artifacts.json")
+			content := `{"application": "Test", "repository": {"adapter": "s3", "options": ` + tt.options + `}}`
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+
+			config, err := ReadArtifactsJson(path)
+			if err != nil {
+				t.Fatalf("ReadArtifactsJson failed: %v", err)
+			}
+			if config.Repository.Options.BucketName != tt.bucketName || config.Repository.Options.PathPrefix != tt.pathPrefix {
+				t.Errorf("Expected bucket %q and prefix %q, got %q and %q", tt.bucketName, tt.pathPrefix, config.Repository.Options.BucketName, config.Repository.Options.PathPrefix)
+			}
+		})
+	}
+}
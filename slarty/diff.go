@@ -0,0 +1,272 @@
+package slarty
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// The kinds of FileChange
+const (
+	FileAdded   = "added"
+	FileRemoved = "removed"
+	FileChanged = "changed"
+)
+
+// MaxTextDiffBytes is the largest file a content diff is made for. Larger files are
+// only reported as changed.
+const MaxTextDiffBytes = 1 << 20
+
+// diffContext is the number of unchanged lines shown around each change in a
+// content diff
+const diffContext = 3
+
+// FileChange is a file that differs between two versions of an artifact
+type FileChange struct {
+	Path    string `json:"path"`
+	Change  string `json:"change"`
+	OldSize int64  `json:"old_size,omitempty"`
+	NewSize int64  `json:"new_size,omitempty"`
+	// Diff is the unified diff of a changed text file, when content diffs are made
+	Diff string `json:"diff,omitempty"`
+}
+
+// diffFile is what is compared of a file in DiffDirectories
+type diffFile struct {
+	path string
+	mode fs.FileMode
+	size int64
+	// sum is the SHA-256 of a regular file, or the target of a symlink
+	sum string
+}
+
+// DiffDirectories compares the files under oldDir with those under newDir, such as the
+// extracted contents of two archives, and returns the files added, removed or changed
+// in newDir, ordered by their slash separated path. A file is changed when its
+// contents, type or permissions differ. With content, changed text files up to
+// MaxTextDiffBytes also get a unified diff. Directories are not compared themselves.
+func DiffDirectories(oldDir, newDir string, content bool) ([]FileChange, error) {
+	oldFiles, err := diffFiles(oldDir)
+	if err != nil {
+		return nil, err
+	}
+	newFiles, err := diffFiles(newDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FileChange
+	for path, oldFile := range oldFiles {
+		newFile, ok := newFiles[path]
+		if !ok {
+			changes = append(changes, FileChange{Path: path, Change: FileRemoved, OldSize: oldFile.size})
+			continue
+		}
+		if oldFile.mode == newFile.mode && oldFile.sum == newFile.sum {
+			continue
+		}
+
+		change := FileChange{Path: path, Change: FileChanged, OldSize: oldFile.size, NewSize: newFile.size}
+		if content && oldFile.mode.IsRegular() && newFile.mode.IsRegular() && oldFile.sum != newFile.sum {
+			change.Diff, err = diffTextFiles(filepath.Join(oldDir, oldFile.path), filepath.Join(newDir, newFile.path), path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, change)
+	}
+	for path, newFile := range newFiles {
+		if _, ok := oldFiles[path]; !ok {
+			changes = append(changes, FileChange{Path: path, Change: FileAdded, NewSize: newFile.size})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// diffFiles returns the files and symlinks under dir by their slash separated path
+func diffFiles(dir string) (map[string]diffFile, error) {
+	files := make(map[string]diffFile)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		file := diffFile{path: rel, mode: info.Mode().Type() | info.Mode().Perm(), size: info.Size()}
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			file.sum, err = os.Readlink(path)
+		case info.Mode().IsRegular():
+			file.sum, err = fileSHA256(path)
+		}
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = file
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// diffTextFiles returns the unified diff of two versions of the file at path, or ""
+// when either is too large or not text
+func diffTextFiles(oldPath, newPath, path string) (string, error) {
+	oldText, ok, err := readText(oldPath)
+	if err != nil || !ok {
+		return "", err
+	}
+	newText, ok, err := readText(newPath)
+	if err != nil || !ok {
+		return "", err
+	}
+	return UnifiedDiff("a/"+path, "b/"+path, oldText, newText), nil
+}
+
+// readText reads the file at path, reporting whether it is text small enough to diff:
+// valid UTF-8 without NUL bytes, and no larger than MaxTextDiffBytes
+func readText(path string) (string, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false, err
+	}
+	if info.Size() > MaxTextDiffBytes {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", false, nil
+	}
+	return string(data), true, nil
+}
+
+// diffLine is a line of a unified diff, with ' ', '-' or '+' for an unchanged, removed
+// or added line
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// UnifiedDiff returns the changes from oldText to newText in the unified format of
+// diff -u, with three lines of context and oldName and newName in the header. It is
+// empty when the texts are the same.
+func UnifiedDiff(oldName, newName, oldText, newText string) string {
+	var lines []diffLine
+	for _, d := range diff.Do(oldText, newText) {
+		op := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			op = '-'
+		case diffmatchpatch.DiffInsert:
+			op = '+'
+		}
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text != "" {
+				lines = append(lines, diffLine{op: op, text: text})
+			}
+		}
+	}
+
+	var changed []int
+	for i, line := range lines {
+		if line.op != ' ' {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	// oldLine and newLine are the line numbers of lines[next] in each text
+	next, oldLine, newLine := 0, 1, 1
+	for i := 0; i < len(changed); {
+		// A hunk takes in the following changes that are close enough for their
+		// context to meet
+		j := i
+		for j+1 < len(changed) && changed[j+1]-changed[j] <= 2*diffContext+1 {
+			j++
+		}
+		start := max(changed[i]-diffContext, 0)
+		end := min(changed[j]+diffContext+1, len(lines))
+
+		for ; next < start; next++ {
+			oldLine++
+			newLine++
+		}
+		oldStart, newStart := oldLine, newLine
+		var hunk strings.Builder
+		for ; next < end; next++ {
+			line := lines[next]
+			if line.op != '+' {
+				oldLine++
+			}
+			if line.op != '-' {
+				newLine++
+			}
+			hunk.WriteByte(line.op)
+			hunk.WriteString(line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				hunk.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldLine-oldStart), hunkRange(newStart, newLine-newStart))
+		out.WriteString(hunk.String())
+		i = j + 1
+	}
+	return out.String()
+}
+
+// hunkRange formats the start and length of a hunk as diff -u does, where an empty
+// range starts at the line before it
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, length)
+}
@@ -0,0 +1,108 @@
+package slarty
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffDirectories(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	write := func(dir, name, content string, mode os.FileMode) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(oldDir, "index.html", "<html>\n<body>old</body>\n</html>\n", 0644)
+	write(newDir, "index.html", "<html>\n<body>new</body>\n</html>\n", 0644)
+	write(oldDir, "same.txt", "unchanged\n", 0644)
+	write(newDir, "same.txt", "unchanged\n", 0644)
+	write(oldDir, "bin/run", "#!/bin/sh\n", 0644)
+	write(newDir, "bin/run", "#!/bin/sh\n", 0755)
+	write(oldDir, "logo.png", "\x89PNG\x00old", 0644)
+	write(newDir, "logo.png", "\x89PNG\x00new", 0644)
+	write(oldDir, "legacy.js", "old()", 0644)
+	write(newDir, "assets/app.js", "app()", 0644)
+
+	changes, err := DiffDirectories(oldDir, newDir, true)
+	if err != nil {
+		t.Fatalf("DiffDirectories failed: %v", err)
+	}
+
+	var got []string
+	for _, change := range changes {
+		got = append(got, change.Change+" "+change.Path)
+	}
+	expected := "added assets/app.js,changed bin/run,changed index.html,removed legacy.js,changed logo.png"
+	if strings.Join(got, ",") != expected {
+		t.Fatalf("Expected %s, got %s", expected, strings.Join(got, ","))
+	}
+
+	byPath := make(map[string]FileChange)
+	for _, change := range changes {
+		byPath[change.Path] = change
+	}
+	if diff := byPath["index.html"].Diff; !strings.Contains(diff, "-<body>old</body>\n+<body>new</body>\n") {
+		t.Errorf("Expected a content diff of index.html, got:\n%s", diff)
+	}
+	if byPath["bin/run"].Diff != "" || byPath["logo.png"].Diff != "" {
+		t.Errorf("Expected no content diff for a mode change or a binary file, got %+v", changes)
+	}
+	if byPath["assets/app.js"].NewSize != 5 || byPath["legacy.js"].OldSize != 5 {
+		t.Errorf("Expected the sizes of added and removed files, got %+v", changes)
+	}
+
+	changes, err = DiffDirectories(oldDir, newDir, false)
+	if err != nil || changes[2].Path != "index.html" || changes[2].Diff != "" {
+		t.Errorf("Expected no content diffs without content, got %+v, %v", changes, err)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	var oldLines []string
+	for i := 1; i <= 20; i++ {
+		oldLines = append(oldLines, fmt.Sprintf("line %d\n", i))
+	}
+	oldText := strings.Join(oldLines, "")
+	newText := strings.Replace(oldText, "line 2\n", "line two\n", 1)
+	newText = strings.Replace(newText, "line 19\n", "", 1)
+
+	expected := `--- a/file
++++ b/file
+@@ -1,5 +1,5 @@
+ line 1
+-line 2
++line two
+ line 3
+ line 4
+ line 5
+@@ -16,5 +16,4 @@
+ line 16
+ line 17
+ line 18
+-line 19
+ line 20
+`
+	if got := UnifiedDiff("a/file", "b/file", oldText, newText); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	if got := UnifiedDiff("a", "b", oldText, oldText); got != "" {
+		t.Errorf("Expected no diff for the same text, got:\n%s", got)
+	}
+
+	expected = "--- a\n+++ b\n@@ -1 +1,2 @@\n-one\n\\ No newline at end of file\n+one\n+two\n"
+	if got := UnifiedDiff("a", "b", "one", "one\ntwo\n"); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
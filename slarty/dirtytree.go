@@ -0,0 +1,30 @@
+package slarty
+
+import "fmt"
+
+// Policies for tracked files under an artifact's directories that have unstaged
+// changes when it is built or deployed, which its name does not reflect
+const (
+	DirtyTreeWarn   = "warn"
+	DirtyTreeFail   = "fail"
+	DirtyTreeIgnore = "ignore"
+)
+
+// DirtyTreePolicy returns the configured dirty_tree policy, which is to warn unless
+// another is set
+func (ac *ArtifactsConfig) DirtyTreePolicy() string {
+	if ac.DirtyTree == "" {
+		return DirtyTreeWarn
+	}
+	return ac.DirtyTree
+}
+
+// ValidateDirtyTreePolicy checks that policy is one of the dirty_tree policies. An
+// empty policy is valid and means warn.
+func ValidateDirtyTreePolicy(policy string) error {
+	switch policy {
+	case "", DirtyTreeWarn, DirtyTreeFail, DirtyTreeIgnore:
+		return nil
+	}
+	return fmt.Errorf("unknown dirty_tree policy %q (expected %q, %q or %q)", policy, DirtyTreeWarn, DirtyTreeFail, DirtyTreeIgnore)
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd)
+
+package slarty
+
+import "errors"
+
+// FreeDiskSpace returns the bytes available to slarty on the filesystem holding path.
+// It is not supported on this platform.
+func FreeDiskSpace(path string) (int64, error) {
+	return 0, errors.New("free disk space is not supported on this platform")
+}
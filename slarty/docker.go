@@ -0,0 +1,144 @@
+package slarty
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ArtifactTypeDocker is the artifact type for artifacts built as docker images
+const ArtifactTypeDocker = "docker"
+
+// IsDocker reports whether the artifact is built as a docker image rather than a tar.gz
+func (a ArtifactConfig) IsDocker() bool {
+	return strings.EqualFold(a.Type, ArtifactTypeDocker)
+}
+
+// DockerAdapter works with docker artifacts the way a RepositoryAdapter works with
+// archives. The artifact names it works with are image references
+// ("registry/app:tag") and the repository is the registry they point at.
+type DockerAdapter struct {
+	binary string
+	// Offline keeps the adapter off the registry, failing what needs it with
+	// ErrOffline
+	Offline bool
+}
+
+// NewDockerAdapter creates a DockerAdapter that runs the given docker compatible CLI,
+// such as podman. An empty binary uses the SLARTY_DOCKER environment variable, or
+// docker when that is not set.
+func NewDockerAdapter(binary string) *DockerAdapter {
+	if binary == "" {
+		binary = os.Getenv("SLARTY_DOCKER")
+	}
+	if binary == "" {
+		binary = "docker"
+	}
+
+	return &DockerAdapter{
+		binary: binary,
+	}
+}
+
+// DockerAdapter returns the DockerAdapter for the config's docker artifacts and
+// containers, kept off the registry when the config is offline
+func (ac *ArtifactsConfig) DockerAdapter() *DockerAdapter {
+	docker := NewDockerAdapter("")
+	docker.Offline = ac.Offline
+	return docker
+}
+
+// run runs the CLI with its output going to stdout and stderr
+func (d *DockerAdapter) run(args ...string) error {
+	cmd := exec.Command(d.binary, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %w", d.binary, args[0], err)
+	}
+
+	return nil
+}
+
+// Build builds the artifact's image from its context and dockerfile, relative to the
+// root directory, and tags it as imageRef
+func (d *DockerAdapter) Build(artifact ArtifactConfig, rootDirectory, imageRef string) error {
+	buildContext := artifact.Context
+	if buildContext == "" {
+		buildContext = "."
+	}
+
+	args := []string{"build", "-t", imageRef}
+	if artifact.Dockerfile != "" {
+		args = append(args, "-f", artifact.Dockerfile)
+	}
+	args = append(args, buildContext)
+
+	cmd := exec.Command(d.binary, args...)
+	cmd.Dir = rootDirectory
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s build failed: %w", d.binary, err)
+	}
+
+	return nil
+}
+
+// PushImage pushes the local image to the registry as artifactName, tagging it first
+// if it was built under a different reference
+func (d *DockerAdapter) PushImage(localImage, artifactName string) error {
+	if err := offlineError(d.Offline, "the docker registry"); err != nil {
+		return err
+	}
+	if localImage != artifactName {
+		if err := d.run("tag", localImage, artifactName); err != nil {
+			return err
+		}
+	}
+
+	return d.run("push", artifactName)
+}
+
+// ArtifactExists checks the registry for the image without pulling it
+func (d *DockerAdapter) ArtifactExists(artifactName string) (bool, error) {
+	if err := offlineError(d.Offline, "the docker registry"); err != nil {
+		return false, err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(d.binary, "manifest", "inspect", artifactName)
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err == nil {
+		return true, nil
+	}
+
+	message := strings.ToLower(stderr.String())
+	if strings.Contains(message, "no such manifest") || strings.Contains(message, "manifest unknown") || strings.Contains(message, "not found") {
+		return false, nil
+	}
+
+	return false, fmt.Errorf("%s manifest inspect failed: %w: %s", d.binary, err, strings.TrimSpace(stderr.String()))
+}
+
+// PullImage pulls the image from the registry and, when destination is a different
+// image reference, tags it as that as well
+func (d *DockerAdapter) PullImage(artifactName, destination string) error {
+	if err := offlineError(d.Offline, "the docker registry"); err != nil {
+		return err
+	}
+	if err := d.run("pull", artifactName); err != nil {
+		return err
+	}
+
+	if destination == "" || destination == artifactName {
+		return nil
+	}
+
+	return d.run("tag", artifactName, destination)
+}
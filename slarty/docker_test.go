@@ -0,0 +1,134 @@
+package slarty
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeDocker writes a docker stand-in that logs its arguments and keeps pushed image
+// references in a registry file, returning the script and the log paths
+func fakeDocker(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "calls.log")
+	registry := filepath.Join(dir, "registry")
+
+	script := `#!/bin/sh
+echo "$@" >> "` + logPath + `"
+case "$1" in
+manifest)
+	grep -qx "$3" "` + registry + `" 2>/dev/null && exit 0
+	echo "no such manifest: $3" >&2
+	exit 1
+	;;
+push)
+	echo "$2" >> "` + registry + `"
+	;;
+image)
+	echo "sha256:${FAKE_DOCKER_DIGEST:-0000}"
+	;;
+run)
+	shift $(($# - 1))
+	sh -c "$1"
+	exit $?
+	;;
+esac
+exit 0
+`
+	path := filepath.Join(dir, "docker")
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake docker: %v", err)
+	}
+
+	return path, logPath
+}
+
+func TestDockerAdapter(t *testing.T) {
+	binary, logPath := fakeDocker(t)
+	docker := NewDockerAdapter(binary)
+	ref := "registry.example.com/api:api-abc123"
+
+	exists, err := docker.ArtifactExists(ref)
+	if err != nil {
+		t.Fatalf("ArtifactExists failed: %v", err)
+	}
+	if exists {
+		t.Fatalf("Expected image to be missing before it is pushed")
+	}
+
+	artifact := ArtifactConfig{Name: "api", Type: "docker", Image: "registry.example.com/api", Dockerfile: "docker/Dockerfile", Context: "services/api"}
+	if err := docker.Build(artifact, t.TempDir(), ref); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if err := docker.PushImage(ref, ref); err != nil {
+		t.Fatalf("PushImage failed: %v", err)
+	}
+
+	exists, err = docker.ArtifactExists(ref)
+	if err != nil {
+		t.Fatalf("ArtifactExists failed: %v", err)
+	}
+	if !exists {
+		t.Fatalf("Expected image to exist after it is pushed")
+	}
+
+	if err := docker.PullImage(ref, "registry.example.com/api:production"); err != nil {
+		t.Fatalf("PullImage failed: %v", err)
+	}
+
+	calls, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read calls: %v", err)
+	}
+	want := []string{
+		"manifest inspect " + ref,
+		"build -t " + ref + " -f docker/Dockerfile services/api",
+		"push " + ref,
+		"manifest inspect " + ref,
+		"pull " + ref,
+		"tag " + ref + " registry.example.com/api:production",
+	}
+	got := strings.Split(strings.TrimSpace(string(calls)), "\n")
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("Expected calls:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestGetArtifactNameDocker(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tempDir, "api"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "api", "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, args := range [][]string{{"init"}, {"add", "."}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	config := &ArtifactsConfig{
+		RootDirectory: tempDir,
+		Artifacts: []ArtifactConfig{
+			{Name: "api", Type: "docker", Image: "registry.example.com/api", Directories: []string{"api"}, ArtifactPrefix: "api"},
+		},
+	}
+
+	name, err := GetArtifactName("api", config)
+	if err != nil {
+		t.Fatalf("GetArtifactName failed: %v", err)
+	}
+	if !strings.HasPrefix(name, "registry.example.com/api:api-") || strings.HasSuffix(name, ".tar.gz") {
+		t.Fatalf("Expected an image reference tagged with the hash, got %s", name)
+	}
+}
@@ -0,0 +1,453 @@
+package slarty
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// Encrypted artifacts start with encryptionMagic, then a byte naming the kind of key
+// that wraps the artifact's data key, the length of the wrapped data key as two bytes
+// and the wrapped data key. The archive follows in segments of encryptionSegmentSize,
+// each sealed with AES-256-GCM under the data key. A segment's nonce holds its number
+// and whether it is the last, so segments cannot be reordered, dropped or truncated
+// without the artifact failing to decrypt. Every artifact has its own random data key,
+// so nonces are never reused.
+const (
+	encryptionMagic       = "slarty-encrypted-v1\n"
+	encryptionSegmentSize = 64 << 10
+	encryptionKeySize     = 32
+)
+
+// Kinds of key that wrap an encrypted artifact's data key
+const (
+	keyKindFile byte = 1
+	keyKindKMS  byte = 2
+)
+
+// encryptionKeyTimeout bounds how long creating or unwrapping a data key may take
+const encryptionKeyTimeout = time.Minute
+
+// ErrNotEncrypted is returned when retrieving an artifact that was stored without
+// encryption from a repository that encrypts artifacts
+var ErrNotEncrypted = errors.New("artifact is not encrypted")
+
+// Check reports a mistake in the encryption settings: exactly one of key-file and
+// kms-key-id must be set, and a key file must hold a valid key. A relative key file is
+// relative to rootDirectory.
+func (e RepositoryEncryption) Check(rootDirectory string) error {
+	switch {
+	case e.KeyFile != "" && e.KMSKeyID != "":
+		return errors.New("repository encryption sets both key-file and kms-key-id; set only one")
+	case e.KeyFile == "" && e.KMSKeyID == "":
+		return errors.New("repository encryption needs a key-file or a kms-key-id")
+	case e.KeyFile != "":
+		_, err := readEncryptionKeyFile(e.keyFilePath(rootDirectory))
+		return err
+	}
+	return nil
+}
+
+// keyFilePath returns the path of the key file, relative paths being relative to
+// rootDirectory
+func (e RepositoryEncryption) keyFilePath(rootDirectory string) string {
+	if filepath.IsAbs(e.KeyFile) {
+		return e.KeyFile
+	}
+	return filepath.Join(rootDirectory, e.KeyFile)
+}
+
+// readEncryptionKeyFile reads a base64 encoded 256 bit key from path
+func readEncryptionKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != encryptionKeySize {
+		return nil, fmt.Errorf("encryption key file %s must hold a base64 encoded %d byte key, such as one made with openssl rand -base64 %d", path, encryptionKeySize, encryptionKeySize)
+	}
+	return key, nil
+}
+
+// dataKeySource creates the data key each artifact is encrypted with, along with the
+// data key wrapped by a key that is kept out of the repository, and unwraps it again
+type dataKeySource interface {
+	kind() byte
+	newDataKey(ctx context.Context) (key, wrapped []byte, err error)
+	unwrapDataKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// keyFileKeys wraps data keys with AES-256-GCM under a key read from a file
+type keyFileKeys struct {
+	aead cipher.AEAD
+}
+
+func newKeyFileKeys(key []byte) (*keyFileKeys, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &keyFileKeys{aead: aead}, nil
+}
+
+func (k *keyFileKeys) kind() byte {
+	return keyKindFile
+}
+
+func (k *keyFileKeys) newDataKey(ctx context.Context) ([]byte, []byte, error) {
+	key := make([]byte, encryptionKeySize)
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return key, k.aead.Seal(nonce, nonce, key, nil), nil
+}
+
+func (k *keyFileKeys) unwrapDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < k.aead.NonceSize() {
+		return nil, errors.New("the artifact's data key is damaged")
+	}
+	nonce, sealed := wrapped[:k.aead.NonceSize()], wrapped[k.aead.NonceSize():]
+	key, err := k.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errors.New("the artifact's data key does not decrypt with the key file; it was stored with a different key")
+	}
+	return key, nil
+}
+
+// kmsClient is the part of the KMS API kmsKeys uses
+type kmsClient interface {
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// kmsKeys has AWS KMS create data keys and unwrap them again, so the key that wraps
+// them never leaves KMS
+type kmsKeys struct {
+	client kmsClient
+	keyID  string
+}
+
+func (k *kmsKeys) kind() byte {
+	return keyKindKMS
+}
+
+func (k *kmsKeys) newDataKey(ctx context.Context) ([]byte, []byte, error) {
+	output, err := k.client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(k.keyID),
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create a data key with KMS key %s: %w", k.keyID, err)
+	}
+	return output.Plaintext, output.CiphertextBlob, nil
+}
+
+func (k *kmsKeys) unwrapDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	output, err := k.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(k.keyID),
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the artifact's data key with KMS key %s: %w", k.keyID, err)
+	}
+	return output.Plaintext, nil
+}
+
+// newGCM returns AES-256-GCM with key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// segmentNonce returns the nonce of segment number counter, marking the last segment
+func segmentNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptedRepositoryAdapter wraps a repository so artifacts are encrypted before they
+// are stored and decrypted as they are retrieved, keeping them unreadable to anyone who
+// can only read the repository. It does not implement ArtifactComparer, since an
+// encrypted artifact cannot be compared with an archive without downloading it.
+type encryptedRepositoryAdapter struct {
+	RepositoryAdapter
+	keys dataKeySource
+	// allowUnencrypted lets artifacts stored before encryption was turned on be
+	// retrieved as they are
+	allowUnencrypted bool
+}
+
+func newEncryptedRepositoryAdapter(repo RepositoryAdapter, keys dataKeySource, allowUnencrypted bool) *encryptedRepositoryAdapter {
+	return &encryptedRepositoryAdapter{RepositoryAdapter: repo, keys: keys, allowUnencrypted: allowUnencrypted}
+}
+
+// StoreArtifact encrypts the archive read from r as it is stored. Each segment is
+// bound to artifactName, so an encrypted artifact cannot be passed off as another.
+func (e *encryptedRepositoryAdapter) StoreArtifact(r io.Reader, artifactName string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), encryptionKeyTimeout)
+	defer cancel()
+
+	key, wrapped, err := e.keys.newDataKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt artifact: %w", err)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt artifact: %w", err)
+	}
+	if len(wrapped) > 0xffff {
+		return errors.New("failed to encrypt artifact: the wrapped data key is too long")
+	}
+
+	header := []byte(encryptionMagic)
+	header = append(header, e.keys.kind())
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+	header = append(header, wrapped...)
+
+	encrypted := &encryptingReader{
+		aead:           aead,
+		source:         bufio.NewReaderSize(r, encryptionSegmentSize),
+		additionalData: []byte(artifactName),
+		plain:          make([]byte, encryptionSegmentSize),
+	}
+	return e.RepositoryAdapter.StoreArtifact(io.MultiReader(bytes.NewReader(header), encrypted), artifactName)
+}
+
+// RetrieveArtifact decrypts the stored artifact as it is written to w. Only segments
+// that decrypt are written, and an error is returned if the artifact was cut short.
+func (e *encryptedRepositoryAdapter) RetrieveArtifact(artifactName string, w io.Writer) error {
+	decrypted := &decryptingWriter{adapter: e, artifactName: artifactName, w: w}
+	if err := e.RepositoryAdapter.RetrieveArtifact(artifactName, decrypted); err != nil {
+		return err
+	}
+	return decrypted.Close()
+}
+
+// PrefetchArtifacts finds out which artifacts exist in bulk when the wrapped repository
+// can
+func (e *encryptedRepositoryAdapter) PrefetchArtifacts(artifactNames []string) {
+	PrefetchArtifacts(e.RepositoryAdapter, artifactNames)
+}
+
+// ArtifactSize returns the size of a stored artifact, including the small overhead of
+// encryption, when the wrapped repository can report it
+func (e *encryptedRepositoryAdapter) ArtifactSize(artifactName string) (int64, error) {
+	sizer, ok := e.RepositoryAdapter.(ArtifactSizer)
+	if !ok {
+		return 0, errors.New("repository cannot report artifact sizes")
+	}
+	return sizer.ArtifactSize(artifactName)
+}
+
+// LabelArtifact attaches labels to a stored artifact when the wrapped repository can
+func (e *encryptedRepositoryAdapter) LabelArtifact(artifactName string, labels map[string]string) error {
+	return LabelArtifact(e.RepositoryAdapter, artifactName, labels)
+}
+
+// ArtifactLabels returns the labels of a stored artifact when the wrapped repository
+// can
+func (e *encryptedRepositoryAdapter) ArtifactLabels(artifactName string) (map[string]string, error) {
+	return ArtifactLabels(e.RepositoryAdapter, artifactName)
+}
+
+// ListArtifacts lists the artifacts starting with prefix when the wrapped repository
+// can
+func (e *encryptedRepositoryAdapter) ListArtifacts(prefix string) ([]string, error) {
+	return ListArtifacts(e.RepositoryAdapter, prefix)
+}
+
+// encryptingReader reads the archive from source as sealed segments
+type encryptingReader struct {
+	aead           cipher.AEAD
+	source         *bufio.Reader
+	additionalData []byte
+	plain          []byte
+	sealed         []byte
+	counter        uint64
+	done           bool
+}
+
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	for len(r.sealed) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(r.source, r.plain)
+		switch err {
+		case io.EOF, io.ErrUnexpectedEOF:
+			r.done = true
+		case nil:
+			// A full segment is the last when nothing follows it
+			if _, err := r.source.Peek(1); err == io.EOF {
+				r.done = true
+			} else if err != nil {
+				return 0, err
+			}
+		default:
+			return 0, err
+		}
+		r.sealed = r.aead.Seal(r.sealed[:0], segmentNonce(r.counter, r.done), r.plain[:n], r.additionalData)
+		r.counter++
+	}
+	n := copy(p, r.sealed)
+	r.sealed = r.sealed[n:]
+	return n, nil
+}
+
+// decryptingWriter decrypts an encrypted artifact written to it, writing the archive
+// to w. A segment is only known to be the last once the artifact ends, so one segment
+// is held back until more follows or Close is called.
+type decryptingWriter struct {
+	adapter      *encryptedRepositoryAdapter
+	artifactName string
+	w            io.Writer
+	buffer       []byte
+	aead         cipher.AEAD
+	counter      uint64
+	// plaintext is set for an artifact stored without encryption, which is written
+	// as it is
+	plaintext bool
+}
+
+func (d *decryptingWriter) Write(p []byte) (int, error) {
+	if d.plaintext {
+		return d.w.Write(p)
+	}
+	d.buffer = append(d.buffer, p...)
+
+	if d.aead == nil {
+		ready, err := d.readHeader(false)
+		if err != nil || !ready {
+			return len(p), err
+		}
+		if d.plaintext {
+			_, err := d.w.Write(d.buffer)
+			d.buffer = nil
+			return len(p), err
+		}
+	}
+
+	sealedSize := encryptionSegmentSize + d.aead.Overhead()
+	for len(d.buffer) > sealedSize {
+		if err := d.openSegment(d.buffer[:sealedSize], false); err != nil {
+			return len(p), err
+		}
+		d.buffer = d.buffer[sealedSize:]
+	}
+	return len(p), nil
+}
+
+// Close decrypts the last segment
+func (d *decryptingWriter) Close() error {
+	if d.plaintext {
+		return nil
+	}
+	if d.aead == nil {
+		if _, err := d.readHeader(true); err != nil {
+			return err
+		}
+		if d.plaintext {
+			_, err := d.w.Write(d.buffer)
+			return err
+		}
+	}
+	return d.openSegment(d.buffer, true)
+}
+
+// readHeader reads the header from the buffer and unwraps the data key, reporting
+// false when more of the artifact is needed first. At the end of the artifact, an
+// incomplete header is an error.
+func (d *decryptingWriter) readHeader(end bool) (bool, error) {
+	magic := []byte(encryptionMagic)
+	prefix := d.buffer
+	if len(prefix) > len(magic) {
+		prefix = prefix[:len(magic)]
+	}
+	if !bytes.HasPrefix(magic, prefix) {
+		if !d.adapter.allowUnencrypted {
+			return false, fmt.Errorf("%w: %s was stored before encryption was turned on; rebuild it, or set allow-unencrypted to read it", ErrNotEncrypted, d.artifactName)
+		}
+		fmt.Fprintf(os.Stderr, "WARNING: %s is not encrypted; rebuild it to store it encrypted\n", d.artifactName)
+		d.plaintext = true
+		return true, nil
+	}
+
+	headerSize := len(magic) + 3
+	if len(d.buffer) >= headerSize {
+		headerSize += int(binary.BigEndian.Uint16(d.buffer[len(magic)+1:]))
+	}
+	if len(d.buffer) < headerSize {
+		if end {
+			return false, fmt.Errorf("encrypted artifact %s is cut short", d.artifactName)
+		}
+		return false, nil
+	}
+
+	kind := d.buffer[len(magic)]
+	if kind != d.adapter.keys.kind() {
+		return false, fmt.Errorf("%s was encrypted with %s, but the repository is set up to use %s", d.artifactName, keyKindName(kind), keyKindName(d.adapter.keys.kind()))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), encryptionKeyTimeout)
+	defer cancel()
+	key, err := d.adapter.keys.unwrapDataKey(ctx, d.buffer[len(magic)+3:headerSize])
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt %s: %w", d.artifactName, err)
+	}
+	d.aead, err = newGCM(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt %s: %w", d.artifactName, err)
+	}
+	d.buffer = d.buffer[headerSize:]
+	return true, nil
+}
+
+// openSegment decrypts a sealed segment and writes it to w
+func (d *decryptingWriter) openSegment(sealed []byte, last bool) error {
+	plain, err := d.aead.Open(nil, segmentNonce(d.counter, last), sealed, []byte(d.artifactName))
+	if err != nil {
+		return fmt.Errorf("encrypted artifact %s is damaged, cut short or stored under another name", d.artifactName)
+	}
+	d.counter++
+	_, err = d.w.Write(plain)
+	return err
+}
+
+// keyKindName describes a kind of key for messages
+func keyKindName(kind byte) string {
+	switch kind {
+	case keyKindFile:
+		return "a key file"
+	case keyKindKMS:
+		return "a KMS key"
+	}
+	return fmt.Sprintf("an unknown kind of key (%d)", kind)
+}
@@ -0,0 +1,196 @@
+package slarty
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// writeKeyFile writes a new key file in dir and returns its name
+func writeKeyFile(t *testing.T, dir string) string {
+	t.Helper()
+	key := make([]byte, encryptionKeySize)
+	rand.Read(key)
+	if err := os.WriteFile(filepath.Join(dir, "slarty.key"), []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return "slarty.key"
+}
+
+func TestEncryptedRepositoryAdapter(t *testing.T) {
+	dir := t.TempDir()
+	config := &ArtifactsConfig{RootDirectory: dir}
+	config.Repository.Adapter = "local"
+	config.Repository.Options.Root = filepath.Join(dir, "repo")
+	config.Repository.Encryption = &RepositoryEncryption{KeyFile: writeKeyFile(t, dir)}
+
+	repo, err := NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+
+	for _, size := range []int{0, 10, encryptionSegmentSize, 2*encryptionSegmentSize + 100} {
+		archive := make([]byte, size)
+		rand.Read(archive)
+		if err := repo.StoreArtifact(bytes.NewReader(archive), "a.tar.gz"); err != nil {
+			t.Fatalf("StoreArtifact failed for %d bytes: %v", size, err)
+		}
+
+		stored, _ := os.ReadFile(filepath.Join(dir, "repo", "a.tar.gz"))
+		if !bytes.HasPrefix(stored, []byte(encryptionMagic)) || (size > 0 && bytes.Contains(stored, archive)) {
+			t.Errorf("Expected the %d byte archive to be stored encrypted", size)
+		}
+
+		var retrieved bytes.Buffer
+		if err := repo.RetrieveArtifact("a.tar.gz", &retrieved); err != nil {
+			t.Fatalf("RetrieveArtifact failed for %d bytes: %v", size, err)
+		}
+		if !bytes.Equal(retrieved.Bytes(), archive) {
+			t.Errorf("Expected the %d byte archive back, got %d bytes", size, retrieved.Len())
+		}
+	}
+
+	// An encrypted artifact cannot be passed off as another, or cut short
+	stored, _ := os.ReadFile(filepath.Join(dir, "repo", "a.tar.gz"))
+	os.WriteFile(filepath.Join(dir, "repo", "b.tar.gz"), stored, 0644)
+	os.WriteFile(filepath.Join(dir, "repo", "c.tar.gz"), stored[:len(stored)-encryptionSegmentSize/2], 0644)
+	for _, name := range []string{"b.tar.gz", "c.tar.gz"} {
+		if err := repo.RetrieveArtifact(name, &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected retrieving %s to fail", name)
+		}
+	}
+
+	// Another key cannot read it
+	other := *config
+	other.Repository.Encryption = &RepositoryEncryption{KeyFile: "missing.key"}
+	if _, err := NewRepositoryAdapter(&other, false); err == nil {
+		t.Errorf("Expected a missing key file to be an error")
+	}
+	otherDir := t.TempDir()
+	other.Repository.Encryption.KeyFile = filepath.Join(otherDir, writeKeyFile(t, otherDir))
+	otherRepo, err := NewRepositoryAdapter(&other, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+	if err := otherRepo.RetrieveArtifact("a.tar.gz", &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "different key") {
+		t.Errorf("Expected retrieving with another key to fail, got %v", err)
+	}
+
+	// Artifacts stored before encryption was turned on are refused unless allowed
+	os.WriteFile(filepath.Join(dir, "repo", "plain.tar.gz"), []byte("plain archive"), 0644)
+	if err := repo.RetrieveArtifact("plain.tar.gz", &bytes.Buffer{}); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Expected ErrNotEncrypted, got %v", err)
+	}
+	config.Repository.Encryption.AllowUnencrypted = true
+	repo, _ = NewRepositoryAdapter(config, false)
+	var plain bytes.Buffer
+	if err := repo.RetrieveArtifact("plain.tar.gz", &plain); err != nil || plain.String() != "plain archive" {
+		t.Errorf("Expected the unencrypted artifact as it is, got %q (%v)", plain.String(), err)
+	}
+}
+
+func TestEncryptedImmutableRepository(t *testing.T) {
+	dir := t.TempDir()
+	config := &ArtifactsConfig{RootDirectory: dir}
+	config.Repository.Adapter = "local"
+	config.Repository.Immutable = true
+	config.Repository.Options.Root = filepath.Join(dir, "repo")
+	config.Repository.Encryption = &RepositoryEncryption{KeyFile: writeKeyFile(t, dir)}
+
+	repo, err := NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+	if _, ok := AllowOverwrite(repo).(*encryptedRepositoryAdapter); !ok {
+		t.Errorf("Expected AllowOverwrite to keep encryption, got %T", AllowOverwrite(repo))
+	}
+	if err := repo.StoreArtifact(strings.NewReader("first"), "a.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+	// Encrypted artifacts are never reported as the same, so they are stored again
+	if same, err := repo.(ArtifactComparer).SameContent("a.tar.gz", strings.NewReader("first")); same || err != nil {
+		t.Errorf("Expected an encrypted artifact not to be compared, got %v (%v)", same, err)
+	}
+}
+
+// fakeKMS wraps data keys by reversing them
+type fakeKMS struct {
+	keyID string
+}
+
+func (f *fakeKMS) GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	f.keyID = *params.KeyId
+	key := make([]byte, encryptionKeySize)
+	rand.Read(key)
+	return &kms.GenerateDataKeyOutput{Plaintext: key, CiphertextBlob: reversed(key)}, nil
+}
+
+func (f *fakeKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	if *params.KeyId != f.keyID {
+		return nil, errors.New("wrong key")
+	}
+	return &kms.DecryptOutput{Plaintext: reversed(params.CiphertextBlob)}, nil
+}
+
+func reversed(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[len(b)-1-i] = b[i]
+	}
+	return out
+}
+
+func TestEncryptedRepositoryAdapterKMS(t *testing.T) {
+	root := t.TempDir()
+	client := &fakeKMS{}
+	repo := newEncryptedRepositoryAdapter(NewLocalRepositoryAdapter(root), &kmsKeys{client: client, keyID: "alias/slarty"}, false)
+
+	if err := repo.StoreArtifact(strings.NewReader("archive"), "a.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+	if client.keyID != "alias/slarty" {
+		t.Errorf("Expected the data key to come from alias/slarty, got %q", client.keyID)
+	}
+	var retrieved bytes.Buffer
+	if err := repo.RetrieveArtifact("a.tar.gz", &retrieved); err != nil || retrieved.String() != "archive" {
+		t.Errorf("Expected the archive back, got %q (%v)", retrieved.String(), err)
+	}
+
+	// An artifact encrypted with KMS is not read with a key file
+	keys, _ := newKeyFileKeys(make([]byte, encryptionKeySize))
+	withKeyFile := newEncryptedRepositoryAdapter(NewLocalRepositoryAdapter(root), keys, false)
+	if err := withKeyFile.RetrieveArtifact("a.tar.gz", &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "a KMS key") {
+		t.Errorf("Expected the kind of key to be checked, got %v", err)
+	}
+}
+
+func TestRepositoryEncryptionCheck(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := writeKeyFile(t, dir)
+	os.WriteFile(filepath.Join(dir, "short.key"), []byte(base64.StdEncoding.EncodeToString([]byte("short"))), 0600)
+
+	tests := []struct {
+		encryption RepositoryEncryption
+		valid      bool
+	}{
+		{RepositoryEncryption{KeyFile: keyFile}, true},
+		{RepositoryEncryption{KMSKeyID: "alias/slarty"}, true},
+		{RepositoryEncryption{}, false},
+		{RepositoryEncryption{KeyFile: keyFile, KMSKeyID: "alias/slarty"}, false},
+		{RepositoryEncryption{KeyFile: "short.key"}, false},
+		{RepositoryEncryption{KeyFile: "missing.key"}, false},
+	}
+	for _, test := range tests {
+		if err := test.encryption.Check(dir); (err == nil) != test.valid {
+			t.Errorf("Check(%+v) = %v, expected valid to be %v", test.encryption, err, test.valid)
+		}
+	}
+}
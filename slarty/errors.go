@@ -0,0 +1,22 @@
+package slarty
+
+import "errors"
+
+// The errors below are wrapped by the errors slarty returns, so callers can tell the
+// kinds of failure apart with errors.Is rather than by their messages
+
+// ErrArtifactNotFound is wrapped by the errors for artifacts that are not in the
+// repository
+var ErrArtifactNotFound = errors.New("artifact not found in repository")
+
+// ErrConfigInvalid is wrapped by the errors for configurations that cannot be used,
+// such as one that does not parse or a repository that is missing a setting
+var ErrConfigInvalid = errors.New("invalid configuration")
+
+// ErrRepositoryUnavailable is wrapped by the errors for a repository that could not be
+// reached or did not carry out a request
+var ErrRepositoryUnavailable = errors.New("repository unavailable")
+
+// ErrBuildFailed is wrapped by the errors for an artifact's build command failing or
+// not producing the files it is expected to
+var ErrBuildFailed = errors.New("build failed")
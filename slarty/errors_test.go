@@ -0,0 +1,100 @@
+package slarty
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// unreachableS3 fails every download as if S3 could not be reached
+type unreachableS3 struct {
+	*fakeS3
+}
+
+func (unreachableS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return nil, errors.New("dial tcp: connection refused")
+}
+
+// failingRunner stands in for a build command that exits non-zero
+type failingRunner struct{}
+
+func (failingRunner) RunCommand(dir, command string, env map[string]string) error {
+	return errors.New("exit status 1")
+}
+
+func TestRepositoryErrors(t *testing.T) {
+	local := NewLocalRepositoryAdapter(t.TempDir())
+	if err := local.RetrieveArtifact("web-abc.tar.gz", io.Discard); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("Expected a missing local artifact to be ErrArtifactNotFound, got %v", err)
+	}
+	if _, err := local.ArtifactSize("web-abc.tar.gz"); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("Expected the size of a missing local artifact to be ErrArtifactNotFound, got %v", err)
+	}
+
+	repo := NewS3RepositoryAdapterWithClient(newFakeS3(), "builds", "")
+	if err := repo.RetrieveArtifact("web-abc.tar.gz", io.Discard); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("Expected a missing S3 artifact to be ErrArtifactNotFound, got %v", err)
+	}
+	if _, err := repo.ArtifactSize("web-abc.tar.gz"); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("Expected the size of a missing S3 artifact to be ErrArtifactNotFound, got %v", err)
+	}
+
+	client := unreachableS3{newFakeS3()}
+	repo = NewS3RepositoryAdapterWithClient(client, "builds", "")
+	if err := repo.StoreArtifact(bytes.NewReader([]byte("archive")), "web-abc.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+	err := repo.RetrieveArtifact("web-abc.tar.gz", io.Discard)
+	if !errors.Is(err, ErrRepositoryUnavailable) || errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("Expected a failed download to be ErrRepositoryUnavailable, got %v", err)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "artifacts.json")
+	if err := os.WriteFile(path, []byte(`{"artifacts": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadArtifactsJson(path); !errors.Is(err, ErrConfigInvalid) {
+		t.Errorf("Expected a config that does not parse to be ErrConfigInvalid, got %v", err)
+	}
+	// A config that can't be read at all isn't invalid
+	if _, err := ReadArtifactsJson(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrConfigInvalid) {
+		t.Errorf("Expected a missing config to be os.ErrNotExist, got %v", err)
+	}
+
+	for _, repository := range []Repository{
+		{Adapter: "s3", Options: RepositoryOptions{BucketName: "builds"}},
+		{Adapter: "local"},
+		{Adapter: "ftp"},
+		{Adapter: "local", Channel: "../main", Options: RepositoryOptions{Root: t.TempDir()}},
+	} {
+		_, err := NewRepositoryAdapter(&ArtifactsConfig{Repository: repository}, false)
+		if !errors.Is(err, ErrConfigInvalid) {
+			t.Errorf("Expected %+v to be ErrConfigInvalid, got %v", repository, err)
+		}
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	var out bytes.Buffer
+	artifact := ArtifactConfig{Name: "web", Command: "npm run build", OutputDirectory: OutputDirectories{"dist"}}
+
+	_, err := testBuilder(t, t.TempDir(), failingRunner{}, &out).Run(artifact)
+	if !errors.Is(err, ErrBuildFailed) {
+		t.Errorf("Expected a failing build command to be ErrBuildFailed, got %v", err)
+	}
+
+	artifact.Expect = []string{"index.html"}
+	runner := &outputRunner{files: map[string]string{"dist/app.js": "console.log(1)"}}
+	_, err = testBuilder(t, t.TempDir(), runner, &out).Run(artifact)
+	if !errors.Is(err, ErrBuildFailed) {
+		t.Errorf("Expected a build missing its expected output to be ErrBuildFailed, got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package slarty
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types emitted on the event stream
+const (
+	EventRunStarted     = "run-started"
+	EventRunFinished    = "run-finished"
+	EventBuildStarted   = "build-started"
+	EventBuildFinished  = "build-finished"
+	EventUploadProgress = "upload-progress"
+	EventDeployStarted  = "deploy-started"
+	EventDeployFinished = "deploy-finished"
+	EventRestart        = "restart"
+)
+
+// Event is a single machine-readable progress event. Events are written as
+// newline-delimited JSON so tooling can render progress without scraping output.
+type Event struct {
+	Type         string    `json:"type"`
+	Time         time.Time `json:"time"`
+	Command      string    `json:"command,omitempty"`
+	Artifact     string    `json:"artifact,omitempty"`
+	Service      string    `json:"service,omitempty"`
+	ArtifactName string    `json:"artifact_name,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Bytes        int64     `json:"bytes,omitempty"`
+	TotalBytes   int64     `json:"total_bytes,omitempty"`
+	Duration     float64   `json:"duration_seconds,omitempty"`
+	// Artifacts lists the names of the artifacts a run-started event's run selected
+	Artifacts []string `json:"artifacts,omitempty"`
+}
+
+// EventWriter writes events as newline-delimited JSON. A nil *EventWriter is valid
+// and discards every event, so callers never need to check whether events are on.
+type EventWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewEventWriter creates an EventWriter that writes to w
+func NewEventWriter(w io.Writer) *EventWriter {
+	return &EventWriter{w: w}
+}
+
+// OpenEventStream opens an event stream for the given target. The target is either
+// "fd:N" to write to an already open file descriptor, "-" for stderr, or a file path
+// which is created (or truncated).
+func OpenEventStream(target string) (*EventWriter, error) {
+	switch {
+	case target == "-":
+		return NewEventWriter(os.Stderr), nil
+	case strings.HasPrefix(target, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(target, "fd:"))
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid event file descriptor: %s", target)
+		}
+		file := os.NewFile(uintptr(fd), target)
+		if file == nil {
+			return nil, fmt.Errorf("invalid event file descriptor: %s", target)
+		}
+		return &EventWriter{w: file, closer: file}, nil
+	default:
+		file, err := os.Create(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open event stream: %w", err)
+		}
+		return &EventWriter{w: file, closer: file}, nil
+	}
+}
+
+// Emit writes the event, stamping the current time if none is set
+func (e *EventWriter) Emit(event Event) {
+	if e == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w.Write(append(line, '\n'))
+}
+
+// Close closes the underlying stream if it was opened by OpenEventStream
+func (e *EventWriter) Close() error {
+	if e == nil || e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// EventObserver is an Observer writing the progress of BuildAll and DeployAll to an
+// event stream as the events of command. A nil stream discards them.
+type EventObserver struct {
+	NopObserver
+	Events  *EventWriter
+	Command string
+
+	started time.Time
+	// artifacts are the names of the artifacts being built by their artifact name, so
+	// uploads can say which artifact they are for
+	artifacts map[string]string
+}
+
+// NewEventObserver returns an EventObserver writing to events as command's events
+func NewEventObserver(events *EventWriter, command string) *EventObserver {
+	return &EventObserver{Events: events, Command: command}
+}
+
+func (o *EventObserver) OnBuildsStart(artifacts []ArtifactConfig) {
+	o.started = time.Now()
+	o.artifacts = make(map[string]string)
+	o.Events.Emit(Event{Type: EventRunStarted, Command: o.Command, Artifacts: Names(artifacts)})
+}
+
+func (o *EventObserver) OnArtifactStart(artifact ArtifactConfig, artifactName string) {
+	if o.artifacts == nil {
+		o.artifacts = make(map[string]string)
+	}
+	o.artifacts[artifactName] = artifact.Name
+	o.Events.Emit(Event{Type: EventBuildStarted, Command: o.Command, Artifact: artifact.Name, ArtifactName: artifactName})
+}
+
+func (o *EventObserver) OnUploadStart(artifactName string) {
+	o.Events.Emit(Event{Type: EventUploadProgress, Command: o.Command, Artifact: o.artifacts[artifactName], ArtifactName: artifactName})
+}
+
+func (o *EventObserver) OnUploadComplete(artifactName string, size int64, elapsed time.Duration, err error) {
+	if err != nil {
+		return
+	}
+	o.Events.Emit(Event{Type: EventUploadProgress, Command: o.Command, Artifact: o.artifacts[artifactName], ArtifactName: artifactName, Bytes: size, TotalBytes: size})
+}
+
+func (o *EventObserver) OnArtifactComplete(result BuildResult, built, needed int) {
+	event := Event{
+		Type:         EventBuildFinished,
+		Command:      o.Command,
+		Artifact:     result.Artifact.Name,
+		ArtifactName: result.ArtifactName,
+		Status:       result.Status,
+		Duration:     result.Duration.Seconds(),
+	}
+	if result.Err != nil {
+		event.Error = result.Err.Error()
+	}
+	o.Events.Emit(event)
+}
+
+func (o *EventObserver) OnBuildsComplete(results []BuildResult, needed int) {
+	status := "succeeded"
+	for _, result := range results {
+		if result.Err != nil {
+			status = "failed"
+		}
+	}
+	o.Events.Emit(Event{Type: EventRunFinished, Command: o.Command, Status: status, Duration: time.Since(o.started).Round(time.Millisecond).Seconds()})
+}
+
+func (o *EventObserver) OnDeploysStart(artifacts []ArtifactConfig) {
+	o.started = time.Now()
+	o.Events.Emit(Event{Type: EventRunStarted, Command: o.Command, Artifacts: Names(artifacts)})
+}
+
+func (o *EventObserver) OnDeployStart(artifact ArtifactConfig, artifactName string) {
+	o.Events.Emit(Event{Type: EventDeployStarted, Command: o.Command, Artifact: artifact.Name, ArtifactName: artifactName})
+}
+
+func (o *EventObserver) OnArtifactDeployed(result DeployResult) {
+	event := Event{
+		Type:         EventDeployFinished,
+		Command:      o.Command,
+		Artifact:     result.Name(),
+		ArtifactName: result.ArtifactName,
+		Status:       result.Status,
+		Duration:     result.Duration.Seconds(),
+	}
+	if result.Err != nil {
+		event.Error = result.Err.Error()
+	}
+	o.Events.Emit(event)
+}
+
+func (o *EventObserver) OnRestartComplete(result RestartResult) {
+	event := Event{
+		Type:     EventRestart,
+		Command:  o.Command,
+		Service:  result.Name,
+		Status:   result.Status,
+		Duration: result.Duration.Seconds(),
+	}
+	if result.Err != nil {
+		event.Error = result.Err.Error()
+	}
+	o.Events.Emit(event)
+}
+
+func (o *EventObserver) OnDeploysComplete(report DeployReport, err error) {
+	status := "succeeded"
+	if err != nil {
+		status = "failed"
+	} else if report.RestartsFailed() > 0 {
+		status = "restart-failed"
+	}
+	o.Events.Emit(Event{Type: EventRunFinished, Command: o.Command, Status: status, Duration: time.Since(o.started).Round(time.Millisecond).Seconds()})
+}
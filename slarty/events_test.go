@@ -0,0 +1,123 @@
+package slarty
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEventWriterEmit(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewEventWriter(&buf)
+
+	writer.Emit(Event{Type: EventBuildStarted, Artifact: "api"})
+	writer.Emit(Event{Type: EventBuildFinished, Artifact: "api", Status: "built", Duration: 1.5})
+
+	scanner := bufio.NewScanner(&buf)
+	var events []Event
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Line is not valid JSON: %v: %s", err, scanner.Text())
+		}
+		events = append(events, event)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].Type != EventBuildStarted || events[0].Time.IsZero() {
+		t.Errorf("Expected stamped build-started event, got %+v", events[0])
+	}
+	if events[1].Status != "built" || events[1].Duration != 1.5 {
+		t.Errorf("Unexpected build-finished event %+v", events[1])
+	}
+}
+
+func TestNilEventWriterIsNoop(t *testing.T) {
+	var writer *EventWriter
+	writer.Emit(Event{Type: EventRunStarted})
+	if err := writer.Close(); err != nil {
+		t.Errorf("Expected nil writer Close to succeed, got %v", err)
+	}
+}
+
+func TestOpenEventStream(t *testing.T) {
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.ndjson")
+		writer, err := OpenEventStream(path)
+		if err != nil {
+			t.Fatalf("OpenEventStream failed: %v", err)
+		}
+		writer.Emit(Event{Type: EventRunStarted, Command: "do-builds"})
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read events file: %v", err)
+		}
+		if !bytes.Contains(content, []byte(`"type":"run-started"`)) {
+			t.Errorf("Unexpected events file content %s", content)
+		}
+	})
+
+	t.Run("InvalidFileDescriptor", func(t *testing.T) {
+		if _, err := OpenEventStream("fd:nope"); err == nil {
+			t.Error("Expected error for invalid file descriptor")
+		}
+	})
+}
+
+func TestEventObserver(t *testing.T) {
+	var out, stream bytes.Buffer
+	builder := testBuilder(t, t.TempDir(), &outputRunner{files: map[string]string{"dist/app.js": "console.log(1)"}}, &out)
+	builder.Namer = func(name string, config *ArtifactsConfig) (string, error) {
+		return name + "-abc.tar.gz", nil
+	}
+	builder.Observer = Observers{&PrintObserver{Out: &out, Err: &out}, NewEventObserver(NewEventWriter(&stream), "do-builds")}
+
+	artifact := ArtifactConfig{Name: "web", Command: "npm run build", OutputDirectory: OutputDirectories{"dist"}}
+	if _, err := builder.BuildAll([]ArtifactConfig{artifact}, BuildOptions{}); err != nil {
+		t.Fatalf("BuildAll failed: %v", err)
+	}
+
+	var types []string
+	var events []Event
+	scanner := bufio.NewScanner(&stream)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Line is not valid JSON: %v: %s", err, scanner.Text())
+		}
+		if event.Command != "do-builds" {
+			t.Errorf("Expected a do-builds event, got %+v", event)
+		}
+		types = append(types, event.Type)
+		events = append(events, event)
+	}
+	expected := []string{EventRunStarted, EventBuildStarted, EventUploadProgress, EventUploadProgress, EventBuildFinished, EventRunFinished}
+	if !reflect.DeepEqual(types, expected) {
+		t.Fatalf("Expected %v, got %v", expected, types)
+	}
+	if started := events[0]; !reflect.DeepEqual(started.Artifacts, []string{"web"}) {
+		t.Errorf("Expected the run to list the selected artifacts, got %+v", started)
+	}
+	if upload := events[3]; upload.Artifact != "web" || upload.ArtifactName != "web-abc.tar.gz" || upload.Bytes == 0 {
+		t.Errorf("Expected the finished upload of web's archive, got %+v", upload)
+	}
+	if finished := events[5]; finished.Status != "succeeded" {
+		t.Errorf("Expected the run to succeed, got %+v", finished)
+	}
+
+	// The printed progress is unchanged by the events going alongside it
+	if !strings.Contains(out.String(), "-- Saved web-abc.tar.gz to repository.") {
+		t.Errorf("Expected the build to be printed, got:\n%s", out.String())
+	}
+}
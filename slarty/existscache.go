@@ -0,0 +1,129 @@
+package slarty
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// existsCache remembers what is known about which artifacts exist in a repository, so
+// a run asks the repository about each artifact once. Artifacts found to exist may also
+// be kept in a file for ttl, so later runs skip asking about them too. Artifacts that
+// do not exist are only remembered for the run, since another run may store them at
+// any moment.
+type existsCache struct {
+	mu    sync.Mutex
+	known map[string]bool
+
+	// path is the file artifacts found to exist are kept in, or empty to keep them
+	// for the run only
+	path string
+	ttl  time.Duration
+	seen map[string]time.Time
+}
+
+func newExistsCache() *existsCache {
+	return &existsCache{known: make(map[string]bool)}
+}
+
+// existsCachePath returns the file the artifacts found to exist in the repository
+// identified by identity are kept in, below the user's cache directory
+func existsCachePath(identity string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(identity))
+	return filepath.Join(dir, "slarty", "exists", hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// keepOnDisk keeps the artifacts found to exist in the file at path for ttl, reading
+// those already in it that have not expired. A file that cannot be read is started
+// afresh.
+func (c *existsCache) keepOnDisk(path string, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.path = path
+	c.ttl = ttl
+	c.seen = make(map[string]time.Time)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var seen map[string]time.Time
+	if json.Unmarshal(data, &seen) != nil {
+		return
+	}
+	now := time.Now()
+	for name, at := range seen {
+		if now.Sub(at) < ttl {
+			c.seen[name] = at
+			c.known[name] = true
+		}
+	}
+}
+
+// lookup returns whether artifactName exists, and false for found when that is not
+// known yet
+func (c *existsCache) lookup(artifactName string) (exists, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exists, found = c.known[artifactName]
+	return exists, found
+}
+
+// record remembers whether artifactName exists
+func (c *existsCache) record(artifactName string, exists bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.known[artifactName] = exists
+	if !exists || c.path == "" {
+		return
+	}
+	c.seen[artifactName] = time.Now()
+	c.save()
+}
+
+// forget drops what is known about artifactName, such as after a store that failed
+// part way through
+func (c *existsCache) forget(artifactName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.known, artifactName)
+	if _, ok := c.seen[artifactName]; ok {
+		delete(c.seen, artifactName)
+		c.save()
+	}
+}
+
+// save writes the artifacts found to exist to the file. The cache only saves requests,
+// so failing to write it is not an error.
+func (c *existsCache) save() {
+	data, err := json.Marshal(c.seen)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return
+	}
+	temp, err := os.CreateTemp(filepath.Dir(c.path), ".exists.*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || os.Rename(temp.Name(), c.path) != nil {
+		os.Remove(temp.Name())
+	}
+}
@@ -0,0 +1,202 @@
+package slarty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awscredentials "github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestExistsCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exists.json")
+
+	cache := newExistsCache()
+	cache.keepOnDisk(path, time.Hour)
+	if _, found := cache.lookup("a.tar.gz"); found {
+		t.Errorf("Expected nothing to be known yet")
+	}
+	cache.record("a.tar.gz", true)
+	cache.record("b.tar.gz", false)
+	cache.record("c.tar.gz", true)
+	cache.forget("c.tar.gz")
+	if exists, found := cache.lookup("b.tar.gz"); !found || exists {
+		t.Errorf("Expected b.tar.gz to be known not to exist")
+	}
+
+	// Only the artifacts that exist are kept for the next run
+	next := newExistsCache()
+	next.keepOnDisk(path, time.Hour)
+	if exists, found := next.lookup("a.tar.gz"); !found || !exists {
+		t.Errorf("Expected a.tar.gz to be known to exist in the next run")
+	}
+	for _, name := range []string{"b.tar.gz", "c.tar.gz"} {
+		if _, found := next.lookup(name); found {
+			t.Errorf("Expected %s not to be kept for the next run", name)
+		}
+	}
+
+	// Once the ttl has passed, they are looked up again
+	expired := newExistsCache()
+	expired.keepOnDisk(path, time.Nanosecond)
+	if _, found := expired.lookup("a.tar.gz"); found {
+		t.Errorf("Expected a.tar.gz to have expired")
+	}
+}
+
+// newTestS3Adapter returns an S3 repository for the bucket builds served from url
+func newTestS3Adapter(t *testing.T, url, pathPrefix string) *S3RepositoryAdapter {
+	t.Helper()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	cfg, err := loadAWSConfig(context.Background(), "us-east-1", "", awscredentials.NewStaticCredentialsProvider("AKIAEXAMPLE", "secret", ""))
+	if err != nil {
+		t.Fatalf("loadAWSConfig failed: %v", err)
+	}
+	return &S3RepositoryAdapter{
+		client: s3.NewFromConfig(cfg, func(o *s3.Options) {
+			o.BaseEndpoint = aws.String(url)
+			o.UsePathStyle = true
+		}),
+		bucketName: "builds",
+		pathPrefix: pathPrefix,
+		exists:     newExistsCache(),
+	}
+}
+
+func TestS3RepositoryAdapterExistsCache(t *testing.T) {
+	var heads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead:
+			heads.Add(1)
+			if strings.HasSuffix(r.URL.Path, "/stored.tar.gz") {
+				w.WriteHeader(http.StatusOK)
+			} else {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	adapter := newTestS3Adapter(t, server.URL, "")
+
+	for i := 0; i < 3; i++ {
+		if exists, err := adapter.ArtifactExists("stored.tar.gz"); !exists || err != nil {
+			t.Fatalf("Expected stored.tar.gz to exist, got %v (%v)", exists, err)
+		}
+		if exists, err := adapter.ArtifactExists("new.tar.gz"); exists || err != nil {
+			t.Fatalf("Expected new.tar.gz not to exist, got %v (%v)", exists, err)
+		}
+	}
+	if n := heads.Load(); n != 2 {
+		t.Errorf("Expected each artifact to be looked up once, got %d requests", n)
+	}
+
+	if err := adapter.StoreArtifact(strings.NewReader("archive"), "new.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+	if exists, _ := adapter.ArtifactExists("new.tar.gz"); !exists || heads.Load() != 2 {
+		t.Errorf("Expected a stored artifact to be known to exist without looking it up")
+	}
+}
+
+func TestS3RepositoryAdapterPrefetchArtifacts(t *testing.T) {
+	stored := []string{"app/api-1111.tar.gz", "app/api-2222.tar.gz", "app/web-3333.tar.gz", "app/worker-4444.tar.gz", "app/zzz-9999.tar.gz"}
+	var lists, heads atomic.Int32
+	var prefixes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		lists.Add(1)
+		prefix := r.URL.Query().Get("prefix")
+		prefixes = append(prefixes, prefix)
+		// Two keys a page, to see the listing stop
+		start := 0
+		if token := r.URL.Query().Get("continuation-token"); token != "" {
+			fmt.Sscan(token, &start)
+		}
+		var matching []string
+		for _, key := range stored {
+			if strings.HasPrefix(key, prefix) {
+				matching = append(matching, key)
+			}
+		}
+		end := min(start+2, len(matching))
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>builds</Name><Prefix>%s</Prefix><MaxKeys>2</MaxKeys><KeyCount>%d</KeyCount>`, prefix, end-start)
+		for _, key := range matching[start:end] {
+			fmt.Fprintf(w, `<Contents><Key>%s</Key></Contents>`, key)
+		}
+		if end < len(matching) {
+			fmt.Fprintf(w, `<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>`, end)
+		} else {
+			w.Write([]byte(`<IsTruncated>false</IsTruncated>`))
+		}
+		w.Write([]byte(`</ListBucketResult>`))
+	}))
+	defer server.Close()
+
+	adapter := newTestS3Adapter(t, server.URL, "app")
+	adapter.PrefetchArtifacts([]string{"api-2222.tar.gz", "web-0000.tar.gz", "web-3333.tar.gz", "worker-4444.tar.gz"})
+	if len(prefixes) == 0 || prefixes[0] != "app/" {
+		t.Errorf("Expected the shared prefix app/ to be listed, got %v", prefixes)
+	}
+
+	for name, expected := range map[string]bool{"api-2222.tar.gz": true, "web-0000.tar.gz": false, "web-3333.tar.gz": true, "worker-4444.tar.gz": true} {
+		if exists, err := adapter.ArtifactExists(name); exists != expected || err != nil {
+			t.Errorf("Expected %s to exist to be %v, got %v (%v)", name, expected, exists, err)
+		}
+	}
+	// Two pages for four artifacts find three, and web-0000 is left to a lookup, since
+	// the listing stopped before it could tell it was missing
+	if lists.Load() != 2 || heads.Load() != 1 {
+		t.Errorf("Expected 2 listing pages and 1 lookup, got %d and %d", lists.Load(), heads.Load())
+	}
+
+	// A listing that passes the last artifact settles the ones it did not see
+	lists.Store(0)
+	heads.Store(0)
+	complete := newTestS3Adapter(t, server.URL, "app")
+	complete.PrefetchArtifacts([]string{"api-0000.tar.gz", "api-2222.tar.gz"})
+	if exists, found := complete.exists.lookup("api-0000.tar.gz"); !found || exists {
+		t.Errorf("Expected api-0000.tar.gz to be known not to exist")
+	}
+
+	// With a page per two artifacts, a listing that runs out leaves the rest to lookups
+	lists.Store(0)
+	limited := newTestS3Adapter(t, server.URL, "app")
+	limited.PrefetchArtifacts([]string{"api-1111.tar.gz", "web-3333.tar.gz"})
+	if lists.Load() != 1 {
+		t.Errorf("Expected one listing page for two artifacts, got %d", lists.Load())
+	}
+	if exists, found := limited.exists.lookup("api-1111.tar.gz"); !found || !exists {
+		t.Errorf("Expected api-1111.tar.gz to be found by the listing")
+	}
+	if _, found := limited.exists.lookup("web-3333.tar.gz"); found {
+		t.Errorf("Expected web-3333.tar.gz to be left to a lookup")
+	}
+
+	// A single artifact is looked up instead
+	lists.Store(0)
+	newTestS3Adapter(t, server.URL, "app").PrefetchArtifacts([]string{"api-1111.tar.gz"})
+	if lists.Load() != 0 {
+		t.Errorf("Expected no listing for one artifact")
+	}
+}
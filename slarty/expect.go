@@ -0,0 +1,83 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// OutputExpectations are the files a build must leave in its output directories,
+// written as globs like those of output_include
+type OutputExpectations struct {
+	patterns []string
+	compiled [][]string
+}
+
+// OutputExpectations returns the artifact's expect patterns, or nil when it has none
+func (a ArtifactConfig) OutputExpectations() (*OutputExpectations, error) {
+	if len(a.Expect) == 0 {
+		return nil, nil
+	}
+	compiled, err := compileOutputPatterns(a.Expect)
+	if err != nil {
+		return nil, fmt.Errorf("%s expect: %w", a.Name, err)
+	}
+	return &OutputExpectations{patterns: a.Expect, compiled: compiled}, nil
+}
+
+// Missing returns the patterns that match no file filter includes in any of dirs. A
+// directory that does not exist holds no files. A nil OutputExpectations misses
+// nothing.
+func (e *OutputExpectations) Missing(dirs []string, filter *OutputFilter) ([]string, error) {
+	if e == nil {
+		return nil, nil
+	}
+	found := make([]bool, len(e.patterns))
+	remaining := len(e.patterns)
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if path == dir && errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil || rel == "." {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if entry.IsDir() {
+				if entry.Name() == WorkDirName || filter.Excludes(rel) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !filter.Includes(rel) {
+				return nil
+			}
+			for i, pattern := range e.compiled {
+				if !found[i] && matchesAny([][]string{pattern}, rel) {
+					found[i] = true
+					remaining--
+				}
+			}
+			if remaining == 0 {
+				return fs.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to check output directory %s: %w", dir, err)
+		}
+	}
+
+	var missing []string
+	for i, pattern := range e.patterns {
+		if !found[i] {
+			missing = append(missing, pattern)
+		}
+	}
+	return missing, nil
+}
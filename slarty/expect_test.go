@@ -0,0 +1,51 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOutputExpectations(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"index.html", "assets/app.js"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	artifact := ArtifactConfig{Name: "web", Expect: []string{"index.html", "assets/*.js", "*.css", "assets"}}
+	expectations, err := artifact.OutputExpectations()
+	if err != nil {
+		t.Fatalf("OutputExpectations failed: %v", err)
+	}
+	missing, err := expectations.Missing([]string{dir}, nil)
+	if err != nil || !reflect.DeepEqual(missing, []string{"*.css"}) {
+		t.Errorf("Expected only *.css to be missing, got %v, %v", missing, err)
+	}
+
+	// Files the archive leaves out do not count
+	filter, _ := ArtifactConfig{OutputExclude: []string{"*.js"}}.OutputFilter()
+	missing, _ = expectations.Missing([]string{dir}, filter)
+	if !reflect.DeepEqual(missing, []string{"assets/*.js", "*.css", "assets"}) {
+		t.Errorf("Expected excluded files not to count, got %v", missing)
+	}
+
+	// An output directory that was never created holds nothing
+	missing, err = expectations.Missing([]string{filepath.Join(dir, "nothing")}, nil)
+	if err != nil || len(missing) != 4 {
+		t.Errorf("Expected everything missing from a directory that does not exist, got %v, %v", missing, err)
+	}
+
+	if none, err := (ArtifactConfig{Name: "web"}).OutputExpectations(); err != nil || none != nil {
+		t.Errorf("Expected no expectations without expect, got %v, %v", none, err)
+	}
+	if _, err := (ArtifactConfig{Name: "web", Expect: []string{"["}}).OutputExpectations(); err == nil {
+		t.Errorf("Expected an invalid pattern to be rejected")
+	}
+}
@@ -0,0 +1,45 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrExtractionLimit is wrapped by the errors for archives that exceed an extraction limit
+var ErrExtractionLimit = errors.New("extraction limit exceeded")
+
+// ExtractionLimits bounds the archives slarty will extract, protecting deploy hosts
+// from decompression bombs and accidentally enormous artifacts. A zero value means
+// no limit.
+type ExtractionLimits struct {
+	MaxArchiveBytes   int64 `json:"max_archive_bytes,omitempty"`
+	MaxExtractedBytes int64 `json:"max_extracted_bytes,omitempty"`
+	MaxFiles          int   `json:"max_files,omitempty"`
+}
+
+// CheckArchiveSize returns an error if a compressed archive of the given size is
+// larger than allowed
+func (l ExtractionLimits) CheckArchiveSize(size int64) error {
+	if l.MaxArchiveBytes > 0 && size > l.MaxArchiveBytes {
+		return fmt.Errorf("%w: archive is larger than max_archive_bytes (%d)", ErrExtractionLimit, l.MaxArchiveBytes)
+	}
+	return nil
+}
+
+// ArchiveSizeLimit returns max_archive_bytes, the largest archive allowed, or 0 when
+// there is no limit
+func (l ExtractionLimits) ArchiveSizeLimit() int64 {
+	return l.MaxArchiveBytes
+}
+
+// CheckExtracted returns an error if extracting the given number of entries and
+// bytes would go over the limits
+func (l ExtractionLimits) CheckExtracted(files int, size int64) error {
+	if l.MaxFiles > 0 && files > l.MaxFiles {
+		return fmt.Errorf("%w: archive has more than max_files (%d) entries", ErrExtractionLimit, l.MaxFiles)
+	}
+	if l.MaxExtractedBytes > 0 && size > l.MaxExtractedBytes {
+		return fmt.Errorf("%w: archive extracts to more than max_extracted_bytes (%d)", ErrExtractionLimit, l.MaxExtractedBytes)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package slarty
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExtractionLimits(t *testing.T) {
+	limits := ExtractionLimits{MaxArchiveBytes: 100, MaxExtractedBytes: 1000, MaxFiles: 10}
+
+	if err := limits.CheckArchiveSize(100); err != nil {
+		t.Errorf("Expected an archive at the limit to be allowed, got %v", err)
+	}
+	if err := limits.CheckArchiveSize(101); !errors.Is(err, ErrExtractionLimit) {
+		t.Errorf("Expected an extraction limit error for a large archive, got %v", err)
+	}
+
+	if err := limits.CheckExtracted(10, 1000); err != nil {
+		t.Errorf("Expected extraction at the limits to be allowed, got %v", err)
+	}
+	if err := limits.CheckExtracted(11, 0); !errors.Is(err, ErrExtractionLimit) {
+		t.Errorf("Expected an extraction limit error for too many files, got %v", err)
+	}
+	if err := limits.CheckExtracted(1, 1001); !errors.Is(err, ErrExtractionLimit) {
+		t.Errorf("Expected an extraction limit error for too many bytes, got %v", err)
+	}
+
+	var unlimited ExtractionLimits
+	if err := unlimited.CheckArchiveSize(1 << 40); err != nil {
+		t.Errorf("Expected no archive limit by default, got %v", err)
+	}
+	if err := unlimited.CheckExtracted(1<<20, 1<<40); err != nil {
+		t.Errorf("Expected no extraction limits by default, got %v", err)
+	}
+}
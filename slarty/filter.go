@@ -0,0 +1,73 @@
+package slarty
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// NameMatcher matches artifact and asset names against a list of patterns. Matching
+// is case-insensitive. By default patterns are globs ("api-*", "web?"), where a
+// pattern without wildcards is an exact match; in regex mode each pattern is a
+// regular expression that must match the whole name.
+type NameMatcher struct {
+	patterns []string
+	regexps  []*regexp.Regexp
+}
+
+// NewNameMatcher creates a NameMatcher. Empty patterns are ignored. An error is only
+// returned in regex mode when a pattern fails to compile.
+func NewNameMatcher(patterns []string, useRegex bool) (*NameMatcher, error) {
+	m := &NameMatcher{}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		if useRegex {
+			re, err := regexp.Compile("(?i)^(?:" + p + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+			m.regexps = append(m.regexps, re)
+			continue
+		}
+
+		m.patterns = append(m.patterns, strings.ToLower(p))
+	}
+
+	return m, nil
+}
+
+// Empty reports whether the matcher has no patterns
+func (m *NameMatcher) Empty() bool {
+	return m == nil || (len(m.patterns) == 0 && len(m.regexps) == 0)
+}
+
+// Matches reports whether name matches any of the patterns. An empty matcher
+// matches nothing.
+func (m *NameMatcher) Matches(name string) bool {
+	if m == nil {
+		return false
+	}
+
+	for _, re := range m.regexps {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+
+	lower := strings.ToLower(name)
+	for _, p := range m.patterns {
+		if p == lower {
+			return true
+		}
+		if ok, err := path.Match(p, lower); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
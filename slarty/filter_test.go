@@ -0,0 +1,61 @@
+package slarty
+
+import "testing"
+
+func TestNameMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		regex    bool
+		input    string
+		expected bool
+	}{
+		{"ExactCaseInsensitive", []string{"API"}, false, "api", true},
+		{"ExactNoPartial", []string{"api"}, false, "api-gateway", false},
+		{"GlobStar", []string{"api-*"}, false, "API-Gateway", true},
+		{"GlobQuestion", []string{"web?"}, false, "web2", true},
+		{"GlobClass", []string{"svc-[ab]"}, false, "svc-c", false},
+		{"SecondPattern", []string{"web", "api*"}, false, "apis", true},
+		{"BadGlobStillExact", []string{"weird["}, false, "weird[", true},
+		{"TrimsWhitespace", []string{"  api "}, false, "api", true},
+		{"RegexWholeName", []string{"api-(v1|v2)"}, true, "API-v2", true},
+		{"RegexAnchored", []string{"api"}, true, "api-gateway", false},
+		{"RegexNoGlob", []string{"api-*"}, true, "api-gateway", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewNameMatcher(tt.patterns, tt.regex)
+			if err != nil {
+				t.Fatalf("NewNameMatcher failed: %v", err)
+			}
+			if got := m.Matches(tt.input); got != tt.expected {
+				t.Errorf("Matches(%q) with %v = %v, expected %v", tt.input, tt.patterns, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNameMatcherEmpty(t *testing.T) {
+	m, err := NewNameMatcher([]string{"", " "}, false)
+	if err != nil {
+		t.Fatalf("NewNameMatcher failed: %v", err)
+	}
+	if !m.Empty() {
+		t.Error("Expected matcher with only blank patterns to be empty")
+	}
+	if m.Matches("anything") {
+		t.Error("Expected empty matcher to match nothing")
+	}
+
+	var nilMatcher *NameMatcher
+	if !nilMatcher.Empty() || nilMatcher.Matches("x") {
+		t.Error("Expected nil matcher to be empty and match nothing")
+	}
+}
+
+func TestNameMatcherInvalidRegex(t *testing.T) {
+	if _, err := NewNameMatcher([]string{"api("}, true); err == nil {
+		t.Error("Expected error for invalid regex")
+	}
+}
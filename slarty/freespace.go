@@ -0,0 +1,71 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrInsufficientSpace is returned when a filesystem does not have room for an archive
+// or its contents
+var ErrInsufficientSpace = errors.New("not enough free disk space")
+
+// spaceHeadroom is the space left free after an archive is written or extracted, so
+// the rest of the host is not starved of space
+const spaceHeadroom = 100 << 20 // 100 MiB
+
+// archiveExpansion is how many times the size of a compressed archive its contents are
+// assumed to take once extracted
+const archiveExpansion = 3
+
+// EstimateExtractedSize estimates the space the contents of a compressed archive of
+// archiveSize bytes take once extracted, which is never more than max_extracted_bytes
+func (l ExtractionLimits) EstimateExtractedSize(archiveSize int64) int64 {
+	estimate := archiveSize * archiveExpansion
+	if l.MaxExtractedBytes > 0 {
+		estimate = min(estimate, l.MaxExtractedBytes)
+	}
+	return estimate
+}
+
+// CheckFreeSpace returns an error wrapping ErrInsufficientSpace when the filesystem
+// holding path, or the nearest directory above it that exists, has less than needed
+// bytes free with headroom to spare. what says what the space is for. When the free
+// space cannot be found out there is nothing to check.
+func CheckFreeSpace(path string, needed int64, what string) error {
+	if needed <= 0 {
+		return nil
+	}
+
+	dir := path
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	free, err := FreeDiskSpace(dir)
+	if err != nil {
+		return nil
+	}
+	if free < needed+spaceHeadroom {
+		return fmt.Errorf("%w in %s for %s: it needs about %s and leaves %s spare, but only %s is free; free up space, or pass --no-space-check to go ahead anyway",
+			ErrInsufficientSpace, dir, what, FormatBytes(needed), FormatBytes(spaceHeadroom), FormatBytes(free))
+	}
+	return nil
+}
+
+// FormatBytes formats a size in bytes for display
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,39 @@
+package slarty
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestEstimateExtractedSize(t *testing.T) {
+	if got := (ExtractionLimits{}).EstimateExtractedSize(100); got != 300 {
+		t.Errorf("Expected 300, got %d", got)
+	}
+	if got := (ExtractionLimits{MaxExtractedBytes: 200}).EstimateExtractedSize(100); got != 200 {
+		t.Errorf("Expected max_extracted_bytes to cap the estimate at 200, got %d", got)
+	}
+}
+
+func TestCheckFreeSpace(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "freebsd" {
+		t.Skip("free disk space is not supported on this platform")
+	}
+	dir := t.TempDir()
+	free, err := FreeDiskSpace(dir)
+	if err != nil || free <= 0 {
+		t.Fatalf("FreeDiskSpace failed: %d, %v", free, err)
+	}
+
+	// A directory that is not there yet is checked on the filesystem it will be made on
+	missing := filepath.Join(dir, "not", "yet")
+	if err := CheckFreeSpace(missing, 1, "testing"); err != nil {
+		t.Errorf("Expected room for a byte, got %v", err)
+	}
+	err = CheckFreeSpace(missing, free+1, "extracting a.tar.gz")
+	if !errors.Is(err, ErrInsufficientSpace) || !strings.Contains(err.Error(), "in "+dir+" for extracting a.tar.gz") {
+		t.Errorf("Expected not enough space in %s, got %v", dir, err)
+	}
+}
@@ -0,0 +1,100 @@
+package slarty
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// ChangedFiles returns the files under directories that differ between the git refs
+// since and to. Like "git diff since...to" the comparison is made against the merge
+// base of the two refs, so only changes made on the to side are reported.
+func ChangedFiles(root string, since string, to string, directories []string) ([]string, error) {
+	rootDir := root
+
+	if root == "__DIR__" {
+		workingDir, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		rootDir = workingDir
+	}
+	if _, err := os.Stat(rootDir); os.IsNotExist(err) {
+		return nil, errors.New(rootDir + " directory does not exist")
+	}
+
+	if to == "" {
+		to = "HEAD"
+	}
+
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	args := append([]string{"diff", "--name-only", since + "..." + to, "--"}, directories...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = rootDir
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, gitFailure("diff", rootDir, err, stderr.String())
+	}
+
+	var files []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+
+	return files, nil
+}
+
+// UnstagedChanges returns the tracked files under directories, relative to root, that
+// have been changed or deleted in the working tree since they were staged. These are
+// the changes an artifact name leaves out, since it is calculated from the index.
+func UnstagedChanges(root string, directories []string) ([]string, error) {
+	rootDir := root
+
+	if root == "__DIR__" {
+		workingDir, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		rootDir = workingDir
+	}
+	if _, err := os.Stat(rootDir); os.IsNotExist(err) {
+		return nil, errors.New(rootDir + " directory does not exist")
+	}
+
+	if !useGitBinary() {
+		var listing bytes.Buffer
+		files, err := goGitListDirectories(&listing, rootDir, directories, true)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+
+	own, others := groupByRepository(rootDir, directories)
+	repos := others
+	if len(own) > 0 || len(others) == 0 {
+		repos = append([]*gitRepository{{top: rootDir, paths: own}}, others...)
+	}
+
+	var files []string
+	for _, repo := range repos {
+		modified, err := repo.unstaged(rootDir)
+		if err != nil {
+			return nil, err
+		}
+		for name := range modified {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
@@ -0,0 +1,133 @@
+package slarty
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	git("init")
+	git("config", "user.email", "test@example.com")
+	git("config", "user.name", "Test User")
+	write("api/main.go", "package main")
+	write("web/index.html", "<html>")
+	git("add", ".")
+	git("commit", "-m", "Initial commit")
+	git("tag", "base")
+
+	write("api/main.go", "package main // changed")
+	write("api/extra.go", "package main")
+	git("add", ".")
+	git("commit", "-m", "Change api")
+
+	files, err := ChangedFiles(tempDir, "base", "", []string{"api"})
+	if err != nil {
+		t.Fatalf("ChangedFiles failed: %v", err)
+	}
+	expected := []string{"api/extra.go", "api/main.go"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+
+	files, err = ChangedFiles(tempDir, "base", "HEAD", []string{"web"})
+	if err != nil {
+		t.Fatalf("ChangedFiles failed: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Expected no changes in web, got %v", files)
+	}
+
+	if _, err := ChangedFiles(tempDir, "no-such-ref", "HEAD", []string{"api"}); err == nil {
+		t.Error("Expected an error for an unknown ref")
+	}
+}
+
+func TestUnstagedChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	git("init")
+	write("api/main.go", "package main")
+	write("api/util.go", "package main // util")
+	write("api/staged.go", "package main // staged")
+	write("web/index.html", "<html>")
+	git("add", ".")
+
+	files, err := UnstagedChanges(tempDir, []string{"api"})
+	if err != nil {
+		t.Fatalf("UnstagedChanges failed: %v", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("Expected no changes in a clean tree, got %v", files)
+	}
+
+	// Staged changes are in the artifact name, and untracked files and other
+	// directories are not part of it
+	write("api/staged.go", "package main // staged again")
+	git("add", "api/staged.go")
+	write("api/util.go", "package main // changed")
+	write("api/new.go", "package main // untracked")
+	write("web/index.html", "<html> changed")
+	if err := os.Remove(filepath.Join(tempDir, "api", "main.go")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	files, err = UnstagedChanges(tempDir, []string{"api"})
+	if err != nil {
+		t.Fatalf("UnstagedChanges failed: %v", err)
+	}
+	expected := []string{"api/main.go", "api/util.go"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+}
@@ -0,0 +1,66 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrNotGitRepository is returned when directories to hash are not in a git repository
+var ErrNotGitRepository = errors.New("not in a git repository")
+
+// ErrUntrackedDirectory is returned when a directory to hash has no files tracked by git
+var ErrUntrackedDirectory = errors.New("no files tracked by git")
+
+// gitFailure returns the error for the git command that failed in dir, keeping git's
+// own message and, for the common causes, adding what to do about it
+func gitFailure(command, dir string, err error, stderr string) error {
+	message := strings.TrimSpace(stderr)
+	switch {
+	case strings.Contains(message, "not a git repository"):
+		return fmt.Errorf("%s is %w, so slarty cannot hash it: run slarty from a git checkout or point root_directory at one (git %s failed: %w: %s)", dir, ErrNotGitRepository, command, err, message)
+	case strings.Contains(message, "dubious ownership"):
+		return fmt.Errorf("git refuses to read the repository at %s because it is owned by another user: if it is trusted, add it to safe.directory as git suggests (git %s failed: %w: %s)", dir, command, err, message)
+	case strings.Contains(message, "outside repository"):
+		return fmt.Errorf("a directory is outside the git repository holding %s: check the artifact's directories, or use a submodule (git %s failed: %w: %s)", dir, command, err, message)
+	}
+	return fmt.Errorf("git %s failed in %s: %w: %s", command, dir, err, message)
+}
+
+// untrackedDirectory returns the first of directories with no entry in listing, lines
+// of "<meta>\t<path>" with paths relative to the same directory, or "" if every
+// directory has at least one
+func untrackedDirectory(listing string, directories []string) string {
+	tracked := make(map[string]bool)
+	for _, line := range strings.Split(listing, "\n") {
+		_, name, found := strings.Cut(line, "\t")
+		if !found {
+			continue
+		}
+		// git quotes paths with unusual characters
+		if unquoted, err := strconv.Unquote(name); err == nil && strings.HasPrefix(name, `"`) {
+			name = unquoted
+		}
+		for _, dir := range directories {
+			clean := path.Clean(filepath.ToSlash(dir))
+			if clean == "." || name == clean || strings.HasPrefix(name, clean+"/") {
+				tracked[dir] = true
+			}
+		}
+	}
+
+	for _, dir := range directories {
+		if !tracked[dir] {
+			return dir
+		}
+	}
+	return ""
+}
+
+// untrackedError returns the error for a directory with no files tracked by git
+func untrackedError(rootDir, dir string) error {
+	return fmt.Errorf("%s in %s has %w: add its files with git add, or check the artifact's directories", dir, rootDir, ErrUntrackedDirectory)
+}
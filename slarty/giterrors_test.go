@@ -0,0 +1,72 @@
+package slarty
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitFailure(t *testing.T) {
+	exitErr := errors.New("exit status 128")
+	tests := []struct {
+		stderr   string
+		expected string
+	}{
+		{"fatal: not a git repository (or any of the parent directories): .git", "run slarty from a git checkout"},
+		{"fatal: detected dubious ownership in repository at '/app'", "add it to safe.directory"},
+		{"fatal: ../x: '../x' is outside repository at '/app'", "is outside the git repository"},
+		{"fatal: something else", "git ls-files failed in /app: exit status 128: fatal: something else"},
+	}
+	for _, tt := range tests {
+		err := gitFailure("ls-files", "/app", exitErr, tt.stderr+"\n")
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected the error for %q to contain %q, got: %v", tt.stderr, tt.expected, err)
+		}
+		if !strings.Contains(err.Error(), strings.TrimSpace(tt.stderr)) || !errors.Is(err, exitErr) {
+			t.Errorf("Expected the error to keep git's message and error, got: %v", err)
+		}
+	}
+}
+
+func TestHashDirectoriesUntrackedDirectory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+	cmd := exec.Command("git", "init")
+	cmd.Dir = tempDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, out)
+	}
+	for _, name := range []string{"api/main.go", "build/app"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	cmd = exec.Command("git", "add", "api")
+	cmd.Dir = tempDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git add failed: %v: %s", err, out)
+	}
+
+	check := func(description string) {
+		t.Helper()
+		if _, err := HashDirectories(tempDir, []string{"api"}); err != nil {
+			t.Errorf("%s: expected a tracked directory to hash, got: %v", description, err)
+		}
+		_, err := HashDirectories(tempDir, []string{"api", "build"})
+		if !errors.Is(err, ErrUntrackedDirectory) || !strings.Contains(err.Error(), "build") {
+			t.Errorf("%s: expected an untracked directory error naming build, got: %v", description, err)
+		}
+	}
+	check("git")
+	withoutGitBinary(t, func() { check("go-git") })
+}
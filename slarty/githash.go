@@ -6,71 +6,370 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// GitRoot returns the top-level directory of the git repository dir is in
+func GitRoot(dir string) (string, error) {
+	if !useGitBinary() {
+		return goGitRoot(dir)
+	}
+
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", gitFailure("rev-parse", dir, err, stderr.String())
+	}
+
+	return filepath.FromSlash(strings.TrimSpace(out.String())), nil
+}
+
 func HashDirectories(root string, directories []string) (string, error) {
+	hash, _, err := hashDirectories(root, directories, false, HashAlgorithmSHA1)
+	return hash, err
+}
+
+// HashDirectoriesWithAlgorithm calculates the same hash as HashDirectories, but
+// hashes the listing of the directories' files with algorithm, one of the
+// hash_algorithm values
+func HashDirectoriesWithAlgorithm(root string, directories []string, algorithm string) (string, error) {
+	hash, _, err := hashDirectories(root, directories, false, algorithm)
+	return hash, err
+}
+
+// HashDirectories hashes directories relative to the root directory with the
+// configured hash_algorithm
+func (ac *ArtifactsConfig) HashDirectories(directories []string) (string, error) {
+	return HashDirectoriesWithAlgorithm(ac.RootDirectory, directories, ac.HashAlgorithm)
+}
+
+// HashWorkingTree calculates the same hash as HashDirectories, except that tracked
+// files changed in the working tree since they were staged are hashed as they are
+// on disk, and deleted ones are left out. dirty reports whether there were any, in
+// which case the hash differs from HashDirectories.
+func HashWorkingTree(root string, directories []string) (hash string, dirty bool, err error) {
+	return hashDirectories(root, directories, true, HashAlgorithmSHA1)
+}
+
+// hashDirectories hashes the index entries of directories, or their working tree
+// content when workingTree is set, with algorithm
+func hashDirectories(root string, directories []string, workingTree bool, algorithm string) (string, bool, error) {
+	if err := ValidateHashAlgorithm(algorithm); err != nil {
+		return "", false, err
+	}
 	rootDir := root
 
 	if root == "__DIR__" {
 		workingDir, err := os.Getwd()
 		if err != nil {
-			return "", err
+			return "", false, err
 		}
 		rootDir = workingDir
 	}
 	_, err := os.Stat(rootDir)
 	if os.IsNotExist(err) {
-		return "", errors.New(rootDir + " directory does not exist")
+		return "", false, errors.New(rootDir + " directory does not exist")
 	}
 
 	for _, dir := range directories {
 		fullPath := rootDir + string(os.PathSeparator) + dir
 		_, err = os.Stat(fullPath)
 		if os.IsNotExist(err) {
-			return "", errors.New(fullPath + " directory does not exist")
+			return "", false, errors.New(fullPath + " directory does not exist")
 		}
 	}
 
+	if !useGitBinary() {
+		return goGitHashDirectories(rootDir, directories, workingTree, algorithm)
+	}
+
+	// Directories in submodules or sibling repositories are listed from their own
+	// repository and added after the root directory's, so directories all in the
+	// root directory's repository hash exactly as they always have
+	own, others := groupByRepository(rootDir, directories)
+
 	var out bytes.Buffer
 	var stderr bytes.Buffer
-	args := append([]string{"ls-files", "-s"}, directories...)
-	cmd := exec.Command("git", args...)
-	cmd.Dir = rootDir
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
-	err = cmd.Run()
+	// listed has every entry before any working tree changes, to check that each
+	// directory has files tracked by git
+	var listed bytes.Buffer
+	dirty := false
+	if len(own) > 0 || len(others) == 0 {
+		args := append([]string{"ls-files", "-s"}, own...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = rootDir
+		cmd.Stdout = &out
+		cmd.Stderr = &stderr
+		err = cmd.Run()
 
-	if err != nil {
-		return "", fmt.Errorf("git ls-files failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+		if err != nil {
+			return "", false, gitFailure("ls-files", rootDir, err, stderr.String())
+		}
+		listed.Write(out.Bytes())
+
+		if workingTree {
+			ownRepo := &gitRepository{top: rootDir, paths: own}
+			changed, err := ownRepo.applyWorkingTree(&out, rootDir)
+			if err != nil {
+				return "", false, err
+			}
+			dirty = dirty || changed
+		}
+	}
+	for _, repo := range others {
+		var listing bytes.Buffer
+		if err := repo.lsFiles(&listing, rootDir); err != nil {
+			return "", false, err
+		}
+		listed.Write(listing.Bytes())
+		if workingTree {
+			changed, err := repo.applyWorkingTree(&listing, rootDir)
+			if err != nil {
+				return "", false, err
+			}
+			dirty = dirty || changed
+		}
+		out.Write(listing.Bytes())
+	}
+	if dir := untrackedDirectory(listed.String(), directories); dir != "" {
+		return "", false, untrackedError(rootDir, dir)
+	}
+
+	// out now has all the stuff to pass to the next command and get the hash
+	hash, err := gitHashListing(rootDir, &out, algorithm)
+	return hash, dirty, err
+}
+
+// HashDirectoriesAtRef calculates the same hash as HashDirectories, but for the
+// directories as they are in the git ref (a branch, tag or commit) rather than in
+// the index. An empty ref hashes the index.
+func HashDirectoriesAtRef(root string, ref string, directories []string) (string, error) {
+	return hashDirectoriesAtRef(root, ref, directories, HashAlgorithmSHA1)
+}
+
+// hashDirectoriesAtRef is HashDirectoriesAtRef hashing with algorithm
+func hashDirectoriesAtRef(root string, ref string, directories []string, algorithm string) (string, error) {
+	if ref == "" {
+		return HashDirectoriesWithAlgorithm(root, directories, algorithm)
+	}
+	if err := ValidateHashAlgorithm(algorithm); err != nil {
+		return "", err
+	}
+
+	rootDir := root
+
+	if root == "__DIR__" {
+		workingDir, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		rootDir = workingDir
+	}
+	_, err := os.Stat(rootDir)
+	if os.IsNotExist(err) {
+		return "", errors.New(rootDir + " directory does not exist")
+	}
+
+	if !useGitBinary() {
+		return goGitHashDirectoriesAtRef(rootDir, ref, directories, algorithm)
+	}
+
+	// Directories in submodules are listed from the commit the submodule is recorded
+	// at in ref
+	own, others := groupByRepository(rootDir, directories)
+
+	var staged bytes.Buffer
+	if len(own) > 0 || len(others) == 0 {
+		var out bytes.Buffer
+		var stderr bytes.Buffer
+		args := append([]string{"ls-tree", "-r", ref, "--"}, own...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = rootDir
+		cmd.Stdout = &out
+		cmd.Stderr = &stderr
+		err = cmd.Run()
+
+		if err != nil {
+			return "", gitFailure("ls-tree", rootDir, err, stderr.String())
+		}
+
+		// Rewrite "<mode> <type> <hash>\t<path>" into the "<mode> <hash> <stage>\t<path>"
+		// format of git ls-files -s so the result matches HashDirectories
+		for _, line := range strings.Split(out.String(), "\n") {
+			meta, path, found := strings.Cut(line, "\t")
+			if !found {
+				continue
+			}
+			if meta, ok := lsTreeToStaged(meta); ok {
+				fmt.Fprintf(&staged, "%s\t%s\n", meta, path)
+			}
+		}
+	}
+	for _, repo := range others {
+		if err := repo.lsTree(&staged, rootDir, ref); err != nil {
+			return "", err
+		}
+	}
+
+	return gitHashListing(rootDir, &staged, algorithm)
+}
+
+// gitHashListing hashes the listing of an artifact's files with algorithm, using git
+// hash-object for sha1 as slarty always has
+func gitHashListing(rootDir string, listing *bytes.Buffer, algorithm string) (string, error) {
+	if algorithm != "" && algorithm != HashAlgorithmSHA1 {
+		return hashListing(listing.Bytes(), algorithm)
 	}
 
 	var hashout bytes.Buffer
 	var hashStderr bytes.Buffer
-	// out now has all the stuff to pass to the next command and get the hash
 	hashObject := exec.Command("git", "hash-object", "--stdin")
 	hashObject.Stdout = &hashout
 	hashObject.Stderr = &hashStderr
-	hashObject.Stdin = &out
-	err = hashObject.Run()
-	if err != nil {
-		return "", fmt.Errorf("git hash-object failed: %w: %s", err, strings.TrimSpace(hashStderr.String()))
+	hashObject.Stdin = listing
+	if err := hashObject.Run(); err != nil {
+		return "", gitFailure("hash-object", rootDir, err, hashStderr.String())
 	}
 
 	return strings.Trim(hashout.String(), "\n"), nil
 }
 
+// DirtyMarker is added to the hash in the name of an artifact built from a working
+// tree with unstaged changes, so it is never mistaken for a build of committed code
+const DirtyMarker = "-dirty"
+
 func GetArtifactName(artifactname string, artifactsConfig *ArtifactsConfig) (string, error) {
+	return GetArtifactNameAtRef(artifactname, artifactsConfig, "")
+}
+
+// GetArtifactNameAtRef returns the archive name the artifact would have for the code
+// in the given git ref, or the image reference for docker artifacts. An empty ref
+// uses the index, like GetArtifactName, or the working tree if HashWorkingTree is set.
+func GetArtifactNameAtRef(artifactname string, artifactsConfig *ArtifactsConfig, ref string) (string, error) {
+	config, err := artifactsConfig.GetArtifactConfig(artifactname)
+	if err != nil {
+		return "", err
+	}
+	hash, err := ArtifactHashAtRef(artifactname, artifactsConfig, ref)
+	if err != nil {
+		return "", err
+	}
+
+	return artifactNameAtRef(*config, hash, artifactsConfig, ref)
+}
+
+// ArtifactHash returns the full hash the artifact is named after, before it is
+// shortened to hash_length
+func ArtifactHash(artifactname string, artifactsConfig *ArtifactsConfig) (string, error) {
+	return ArtifactHashAtRef(artifactname, artifactsConfig, "")
+}
+
+// ArtifactHashAtRef is ArtifactHash for the code in the given git ref, as
+// GetArtifactNameAtRef names it
+func ArtifactHashAtRef(artifactname string, artifactsConfig *ArtifactsConfig, ref string) (string, error) {
 	// get config section
 	config, err := artifactsConfig.GetArtifactConfig(artifactname)
 	if err != nil {
 		return "", err
 	}
 
-	hash, err := HashDirectories(artifactsConfig.RootDirectory, config.Directories)
+	var hash string
+	dirty := false
+	if ref == "" && artifactsConfig.HashWorkingTree {
+		hash, dirty, err = hashDirectories(artifactsConfig.RootDirectory, config.Directories, true, artifactsConfig.HashAlgorithm)
+	} else {
+		hash, err = hashDirectoriesAtRef(artifactsConfig.RootDirectory, ref, config.Directories, artifactsConfig.HashAlgorithm)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", artifactname, err)
+	}
+
+	// Builds that run in a container also depend on the exact image they run in
+	if config.Container != nil && !config.IsDocker() {
+		digest, err := artifactsConfig.DockerAdapter().ImageDigest(config.Container.Image)
+		if err != nil {
+			return "", err
+		}
+		hash = withImageDigest(hash, digest)
+	}
+	if dirty {
+		hash += DirtyMarker
+	}
+
+	return hash, nil
+}
+
+// ArtifactNameForHash returns the archive name of the artifact built from code with
+// the given hash, or the image reference for docker artifacts. The hash is shortened
+// to hash_length, and the archive name follows the artifact's artifact_name template,
+// with {date} taken from HEAD.
+func ArtifactNameForHash(config ArtifactConfig, hash string, artifactsConfig *ArtifactsConfig) (string, error) {
+	return artifactNameAtRef(config, hash, artifactsConfig, "")
+}
+
+// artifactNameAtRef is ArtifactNameForHash for the code at ref
+func artifactNameAtRef(config ArtifactConfig, hash string, artifactsConfig *ArtifactsConfig, ref string) (string, error) {
+	length, err := artifactsConfig.ShortHashLength()
 	if err != nil {
 		return "", err
 	}
+	hash = ShortenHash(hash, length)
+
+	// Docker artifacts are image references tagged with the hash
+	if config.IsDocker() {
+		return fmt.Sprintf("%s:%s-%s", config.Image, config.ArtifactPrefix, hash), nil
+	}
+
+	return artifactsConfig.artifactNameForHash(config, hash, ref)
+}
+
+// CommitTime returns when the commit at ref, or HEAD when ref is empty, was made in
+// the git repository at root
+func CommitTime(root, ref string) (time.Time, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if !useGitBinary() {
+		return goGitCommitTime(root, ref)
+	}
 
-	return fmt.Sprintf("%s-%s.tar.gz", config.ArtifactPrefix, hash), nil
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "log", "-1", "--format=%ct", ref, "--")
+	cmd.Dir = root
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return time.Time{}, gitFailure("log", root, err, stderr.String())
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(out.String()), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read the commit time of %s: %w", ref, err)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// HeadCommit returns the commit checked out in the git repository at root
+func HeadCommit(root string) (string, error) {
+	if !useGitBinary() {
+		return goGitHeadCommit(root)
+	}
+
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = root
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", gitFailure("rev-parse", root, err, stderr.String())
+	}
+	return strings.TrimSpace(out.String()), nil
 }
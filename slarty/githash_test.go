@@ -1,6 +1,7 @@
 package slarty
 
 import (
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -126,6 +127,9 @@ func TestHashDirectoriesSurfacesGitStderr(t *testing.T) {
 	if !strings.Contains(err.Error(), "not a git repository") {
 		t.Fatalf("Expected error to contain git stderr 'not a git repository', got: %v", err)
 	}
+	if !errors.Is(err, ErrNotGitRepository) {
+		t.Errorf("Expected the error to be ErrNotGitRepository, got: %v", err)
+	}
 }
 
 // TestHashDirectories tests the HashDirectories function
@@ -275,3 +279,80 @@ func TestHashDirectories(t *testing.T) {
 		t.Fatalf("HashDirectories with multiple directories returned same hash as single directory: %s", hash4)
 	}
 }
+
+// TestHashDirectoriesAtRefMatchesIndex verifies that hashing a commit gives the same
+// result as hashing the index it was made from, and that older commits can be hashed
+func TestHashDirectoriesAtRefMatchesIndex(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+
+	files := map[string]string{
+		"src/a.txt":        "a",
+		"src/a-b.txt":      "a-b",
+		"src/a/nested.txt": "nested",
+		"src/run.sh":       "#!/bin/sh",
+		"other/file.txt":   "other",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(tempDir, "src/run.sh"), 0755); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+
+	git("init")
+	git("config", "user.email", "test@example.com")
+	git("config", "user.name", "Test User")
+	git("add", ".")
+	git("commit", "-m", "Initial commit")
+
+	for _, dirs := range [][]string{{"src"}, {"src", "other"}, {"."}} {
+		indexHash, err := HashDirectories(tempDir, dirs)
+		if err != nil {
+			t.Fatalf("HashDirectories failed: %v", err)
+		}
+		refHash, err := HashDirectoriesAtRef(tempDir, "HEAD", dirs)
+		if err != nil {
+			t.Fatalf("HashDirectoriesAtRef failed: %v", err)
+		}
+		if indexHash != refHash {
+			t.Errorf("Expected hash at HEAD to match index for %v: %s != %s", dirs, refHash, indexHash)
+		}
+	}
+
+	before, _ := HashDirectories(tempDir, []string{"src"})
+	if err := os.WriteFile(filepath.Join(tempDir, "src/a.txt"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	git("commit", "-am", "Change a")
+
+	old, err := HashDirectoriesAtRef(tempDir, "HEAD~1", []string{"src"})
+	if err != nil {
+		t.Fatalf("HashDirectoriesAtRef failed: %v", err)
+	}
+	if old != before {
+		t.Errorf("Expected hash at HEAD~1 to match the earlier index hash")
+	}
+
+	if _, err := HashDirectoriesAtRef(tempDir, "no-such-ref", []string{"src"}); err == nil {
+		t.Error("Expected an error for an unknown ref")
+	}
+}
@@ -0,0 +1,309 @@
+package slarty
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gitRepository is a git repository other than the root directory's, such as a
+// submodule or a sibling checkout, holding some of an artifact's directories
+type gitRepository struct {
+	top   string
+	paths []string
+}
+
+// findGitTop returns the top-level directory of the git repository holding path,
+// found by looking for the closest .git entry (a directory, or the file a submodule
+// or worktree has), or "" if path is not in one
+func findGitTop(path string) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// groupByRepository splits directories, relative to rootDir, into those in rootDir's
+// own git repository and those in other repositories. The other repositories are
+// sorted by their location so their files are always combined in the same order.
+func groupByRepository(rootDir string, directories []string) ([]string, []*gitRepository) {
+	rootTop := findGitTop(rootDir)
+
+	var own []string
+	repos := make(map[string]*gitRepository)
+	for _, dir := range directories {
+		fullPath := filepath.Join(rootDir, dir)
+		top := findGitTop(fullPath)
+		if top == "" || top == rootTop {
+			own = append(own, dir)
+			continue
+		}
+		abs, err := filepath.Abs(fullPath)
+		if err != nil {
+			own = append(own, dir)
+			continue
+		}
+		rel, err := filepath.Rel(top, abs)
+		if err != nil {
+			own = append(own, dir)
+			continue
+		}
+		if repos[top] == nil {
+			repos[top] = &gitRepository{top: top}
+		}
+		repos[top].paths = append(repos[top].paths, rel)
+	}
+
+	var others []*gitRepository
+	for _, repo := range repos {
+		others = append(others, repo)
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].top < others[j].top })
+
+	return own, others
+}
+
+// writeStaged writes the NUL-separated "<meta>\t<path>" entries of git ls-files -z
+// or ls-tree -z as lines of out, with each path made relative to rootDir instead of
+// the repository at top, so files in other repositories are named as they would be
+// if they were in the root directory's own repository
+func (r *gitRepository) writeStaged(out *bytes.Buffer, entries []byte, rootDir string, convert func(meta string) (string, bool)) error {
+	rootAbs, err := filepath.Abs(rootDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range strings.Split(string(entries), "\x00") {
+		meta, path, found := strings.Cut(entry, "\t")
+		if !found {
+			continue
+		}
+		meta, ok := convert(meta)
+		if !ok {
+			continue
+		}
+		rel, err := filepath.Rel(rootAbs, filepath.Join(r.top, path))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s\t%s\n", meta, filepath.ToSlash(rel))
+	}
+	return nil
+}
+
+// lsFiles writes the index entries of the repository's paths to out in the format of
+// git ls-files -s
+func (r *gitRepository) lsFiles(out *bytes.Buffer, rootDir string) error {
+	var entries bytes.Buffer
+	var stderr bytes.Buffer
+	args := append([]string{"ls-files", "-s", "-z", "--"}, r.paths...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.top
+	cmd.Stdout = &entries
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return gitFailure("ls-files", r.top, err, stderr.String())
+	}
+
+	return r.writeStaged(out, entries.Bytes(), rootDir, func(meta string) (string, bool) {
+		return meta, true
+	})
+}
+
+// lsTree writes the entries of the repository's paths at the commit a submodule is
+// recorded at in ref of the root directory's repository, in the format of git
+// ls-files -s. A repository that is not a submodule has no commit recorded in ref,
+// so it cannot be hashed at a ref.
+func (r *gitRepository) lsTree(out *bytes.Buffer, rootDir, ref string) error {
+	rootTop := findGitTop(rootDir)
+	submodulePath, err := filepath.Rel(rootTop, r.top)
+	if rootTop == "" || err != nil || !filepath.IsLocal(submodulePath) {
+		return fmt.Errorf("cannot hash %s at %s: it is a separate git repository, not a submodule", r.top, ref)
+	}
+
+	var gitlink bytes.Buffer
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "ls-tree", ref, "--", filepath.ToSlash(submodulePath))
+	cmd.Dir = rootTop
+	cmd.Stdout = &gitlink
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return gitFailure("ls-tree", rootTop, err, stderr.String())
+	}
+	fields := strings.Fields(gitlink.String())
+	if len(fields) < 3 || fields[1] != "commit" {
+		return fmt.Errorf("cannot hash %s at %s: no submodule commit is recorded for it", r.top, ref)
+	}
+
+	var entries bytes.Buffer
+	stderr.Reset()
+	args := append([]string{"ls-tree", "-r", "-z", fields[2], "--"}, r.paths...)
+	cmd = exec.Command("git", args...)
+	cmd.Dir = r.top
+	cmd.Stdout = &entries
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return gitFailure("ls-tree", r.top, err, stderr.String())
+	}
+
+	return r.writeStaged(out, entries.Bytes(), rootDir, lsTreeToStaged)
+}
+
+// lsTreeToStaged rewrites the "<mode> <type> <hash>" of a git ls-tree entry into the
+// "<mode> <hash> <stage>" of git ls-files -s
+func lsTreeToStaged(meta string) (string, bool) {
+	fields := strings.Fields(meta)
+	if len(fields) != 3 {
+		return "", false
+	}
+	return fmt.Sprintf("%s %s 0", fields[0], fields[2]), true
+}
+
+// applyWorkingTree rewrites the git ls-files -s lines in listing, whose paths are
+// relative to rootDir, so that the repository's files changed in the working tree
+// since they were staged have the hash and mode of their content on disk, and those
+// deleted are dropped. It reports whether anything was changed.
+func (r *gitRepository) applyWorkingTree(listing *bytes.Buffer, rootDir string) (bool, error) {
+	modified, err := r.unstaged(rootDir)
+	if err != nil || len(modified) == 0 {
+		return false, err
+	}
+
+	var rewritten bytes.Buffer
+	changed := false
+	for _, line := range strings.SplitAfter(listing.String(), "\n") {
+		meta, listed, found := strings.Cut(strings.TrimSuffix(line, "\n"), "\t")
+		if !found {
+			rewritten.WriteString(line)
+			continue
+		}
+		// git quotes paths with unusual characters in ls-files output
+		name := listed
+		if unquoted, err := strconv.Unquote(listed); err == nil && strings.HasPrefix(listed, `"`) {
+			name = unquoted
+		}
+		path, ok := modified[name]
+		if !ok {
+			rewritten.WriteString(line)
+			continue
+		}
+
+		entry, keep, err := workingTreeEntry(meta, path, r.top)
+		if err != nil {
+			return false, err
+		}
+		changed = true
+		if keep {
+			fmt.Fprintf(&rewritten, "%s\t%s\n", entry, listed)
+		}
+	}
+	*listing = rewritten
+
+	return changed, nil
+}
+
+// unstaged returns the files of the repository's paths changed in the working tree
+// since they were staged, including deleted ones, keyed by their path relative to
+// rootDir with the path on disk as the value
+func (r *gitRepository) unstaged(rootDir string) (map[string]string, error) {
+	var names bytes.Buffer
+	var stderr bytes.Buffer
+	args := append([]string{"diff", "--name-only", "--relative", "-z", "--"}, r.paths...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.top
+	cmd.Stdout = &names
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, gitFailure("diff", r.top, err, stderr.String())
+	}
+
+	rootAbs, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	modified := make(map[string]string)
+	for _, name := range strings.Split(names.String(), "\x00") {
+		if name == "" {
+			continue
+		}
+		path := filepath.Join(r.top, name)
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(rootAbs, abs)
+		if err != nil {
+			return nil, err
+		}
+		modified[filepath.ToSlash(rel)] = path
+	}
+
+	return modified, nil
+}
+
+// workingTreeEntry returns the "<mode> <hash> <stage>" of the file at path as it is on
+// disk, in place of meta from the index. keep is false when the file was deleted.
+// Anything other than a file or a symlink, such as a submodule, keeps its entry.
+func workingTreeEntry(meta, path, dir string) (string, bool, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	fields := strings.Fields(meta)
+	if len(fields) != 3 {
+		return meta, true, nil
+	}
+
+	var mode string
+	var cmd *exec.Cmd
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		// git stores where a symlink points, not what it points at
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", false, err
+		}
+		mode = "120000"
+		cmd = exec.Command("git", "hash-object", "--stdin")
+		cmd.Stdin = strings.NewReader(target)
+	case info.Mode().IsRegular():
+		mode = "100644"
+		if info.Mode()&0111 != 0 {
+			mode = "100755"
+		}
+		cmd = exec.Command("git", "hash-object", "--", path)
+	default:
+		return meta, true, nil
+	}
+
+	var out bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", false, gitFailure("hash-object", dir, err, stderr.String())
+	}
+
+	return fmt.Sprintf("%s %s %s", mode, strings.TrimSpace(out.String()), fields[2]), true, nil
+}
@@ -0,0 +1,190 @@
+package slarty
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHashDirectoriesAcrossRepositories(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+	mainDir := filepath.Join(tempDir, "main")
+	frontendDir := filepath.Join(tempDir, "frontend")
+	siblingDir := filepath.Join(tempDir, "sibling")
+
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "protocol.file.allow=always", "-c", "user.email=test@example.com", "-c", "user.name=Test User"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	for _, dir := range []string{mainDir, frontendDir, siblingDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+		git(dir, "init")
+	}
+	write(filepath.Join(frontendDir, "src", "app.js"), "console.log(1)")
+	git(frontendDir, "add", ".")
+	git(frontendDir, "commit", "-m", "Frontend")
+	write(filepath.Join(siblingDir, "lib", "lib.go"), "package lib")
+	git(siblingDir, "add", ".")
+
+	write(filepath.Join(mainDir, "api", "main.go"), "package main")
+	git(mainDir, "add", ".")
+	apiHash, err := HashDirectories(mainDir, []string{"api"})
+	if err != nil {
+		t.Fatalf("HashDirectories failed: %v", err)
+	}
+	git(mainDir, "submodule", "add", frontendDir, "frontend")
+	git(mainDir, "commit", "-m", "Add the frontend")
+
+	// Directories in the root's own repository hash as they did before
+	if hash, err := HashDirectories(mainDir, []string{"api"}); err != nil || hash != apiHash {
+		t.Errorf("Expected the api hash to be unchanged, got %s (%v), want %s", hash, err, apiHash)
+	}
+
+	// Files inside the submodule are hashed, not just its commit
+	directories := []string{"api", "frontend/src", "../sibling/lib"}
+	before, err := HashDirectories(mainDir, directories)
+	if err != nil {
+		t.Fatalf("HashDirectories failed: %v", err)
+	}
+	if before == apiHash {
+		t.Errorf("Expected the submodule and sibling files to change the hash")
+	}
+	again, err := HashDirectories(mainDir, directories)
+	if err != nil || again != before {
+		t.Errorf("Expected the hash to be stable, got %s (%v), want %s", again, err, before)
+	}
+
+	submodule := filepath.Join(mainDir, "frontend")
+	write(filepath.Join(submodule, "src", "app.js"), "console.log(2)")
+	git(submodule, "add", ".")
+	after, err := HashDirectories(mainDir, directories)
+	if err != nil {
+		t.Fatalf("HashDirectories failed: %v", err)
+	}
+	if after == before {
+		t.Errorf("Expected a change staged in the submodule to change the hash")
+	}
+
+	// At a ref, the submodule is read at the commit recorded for it
+	atHead, err := HashDirectoriesAtRef(mainDir, "HEAD", []string{"api", "frontend/src"})
+	if err != nil {
+		t.Fatalf("HashDirectoriesAtRef failed: %v", err)
+	}
+	git(submodule, "checkout", "--", ".")
+	git(submodule, "reset", "--hard")
+	inIndex, err := HashDirectories(mainDir, []string{"api", "frontend/src"})
+	if err != nil {
+		t.Fatalf("HashDirectories failed: %v", err)
+	}
+	if atHead != inIndex {
+		t.Errorf("Expected the hash at HEAD to match the index, got %s and %s", atHead, inIndex)
+	}
+
+	// A sibling repository has no commit recorded in the ref
+	if _, err := HashDirectoriesAtRef(mainDir, "HEAD", directories); err == nil {
+		t.Errorf("Expected hashing a sibling repository at a ref to fail")
+	}
+}
+
+func TestHashWorkingTree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	git("init")
+	write("api/main.go", "package main")
+	write("api/util.go", "package main // util")
+	write("api/my file.go", "package main // spaces")
+	write("web/index.html", "<html>")
+	git("add", ".")
+	directories := []string{"api"}
+
+	// A clean tree hashes like the index
+	staged, err := HashDirectories(tempDir, directories)
+	if err != nil {
+		t.Fatalf("HashDirectories failed: %v", err)
+	}
+	hash, dirty, err := HashWorkingTree(tempDir, directories)
+	if err != nil || dirty || hash != staged {
+		t.Fatalf("Expected a clean tree to hash like the index, got %s, %v, %v", hash, dirty, err)
+	}
+
+	// Unstaged changes give the hash the index would have once they are staged
+	write("api/main.go", "package main // changed")
+	write("api/my file.go", "package main // changed too")
+	write("web/index.html", "<html> changed")
+	if err := os.Remove(filepath.Join(tempDir, "api", "util.go")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	hash, dirty, err = HashWorkingTree(tempDir, directories)
+	if err != nil {
+		t.Fatalf("HashWorkingTree failed: %v", err)
+	}
+	if !dirty || hash == staged {
+		t.Errorf("Expected unstaged changes to make the hash dirty, got %s, %v", hash, dirty)
+	}
+	if indexHash, _ := HashDirectories(tempDir, directories); indexHash != staged {
+		t.Errorf("Expected the index hash to ignore unstaged changes")
+	}
+	git("add", "-A", "api")
+	if indexHash, _ := HashDirectories(tempDir, directories); indexHash != hash {
+		t.Errorf("Expected the working tree hash %s to match the index once staged, got %s", hash, indexHash)
+	}
+
+	// Names built from a dirty tree are marked
+	write("api/main.go", "package main // changed again")
+	config := &ArtifactsConfig{
+		RootDirectory:   tempDir,
+		Artifacts:       []ArtifactConfig{{Name: "api", Directories: directories, ArtifactPrefix: "api"}},
+		HashWorkingTree: true,
+	}
+	name, err := GetArtifactName("api", config)
+	if err != nil {
+		t.Fatalf("GetArtifactName failed: %v", err)
+	}
+	if !strings.HasSuffix(name, DirtyMarker+".tar.gz") {
+		t.Errorf("Expected a dirty artifact name, got %s", name)
+	}
+}
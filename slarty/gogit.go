@@ -0,0 +1,480 @@
+package slarty
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// useGitBinary reports whether git is run to hash directories. Without a git binary,
+// as in minimal containers, the repository is read with go-git instead, which gives
+// the same hashes.
+var useGitBinary = func() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// goGitRepository is a repository opened with go-git, along with its top-level
+// directory and whether paths are quoted in git's output as they are by default
+type goGitRepository struct {
+	repo      *git.Repository
+	top       string
+	quotePath bool
+}
+
+// goGitOpen opens the git repository holding dir
+func goGitOpen(dir string) (*goGitRepository, error) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	if err == git.ErrRepositoryNotExists {
+		return nil, fmt.Errorf("%s is %w, so slarty cannot hash it: run slarty from a git checkout or point root_directory at one", dir, ErrNotGitRepository)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the git repository at %s: %w", dir, err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the git repository at %s: %w", dir, err)
+	}
+	top, err := filepath.Abs(worktree.Filesystem.Root())
+	if err != nil {
+		return nil, err
+	}
+
+	quotePath := true
+	if config, err := repo.Config(); err == nil && config.Raw.Section("core").Option("quotepath") == "false" {
+		quotePath = false
+	}
+
+	return &goGitRepository{repo: repo, top: top, quotePath: quotePath}, nil
+}
+
+// pathspec matches the repository's paths against paths relative to dir, the way
+// git does for a command run in dir: everything under dir when there are none
+func (r *goGitRepository) pathspec(dir string, paths []string) (func(name string) bool, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := filepath.Rel(r.top, abs)
+	if err != nil {
+		return nil, err
+	}
+	prefix = filepath.ToSlash(prefix)
+
+	specs := []string{prefix}
+	if len(paths) > 0 {
+		specs = nil
+		for _, p := range paths {
+			specs = append(specs, path.Join(prefix, filepath.ToSlash(p)))
+		}
+	}
+
+	return func(name string) bool {
+		for _, spec := range specs {
+			if spec == "." || name == spec || strings.HasPrefix(name, spec+"/") {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// relative returns the repository path name relative to rootDir, quoted as git
+// quotes it when quote is set
+func (r *goGitRepository) relative(name, rootDir string, quote bool) (string, error) {
+	rootAbs, err := filepath.Abs(rootDir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(rootAbs, filepath.Join(r.top, filepath.FromSlash(name)))
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if quote {
+		rel = quoteGitPath(rel, r.quotePath)
+	}
+	return rel, nil
+}
+
+// listIndex writes the index entries of paths, relative to dir, to out in the
+// format of git ls-files -s run in dir, with paths relative to rootDir. With
+// workingTree set, files changed on disk are listed as they are there, and deleted
+// ones are left out. It returns the paths of the files that were changed.
+func (r *goGitRepository) listIndex(out *bytes.Buffer, dir, rootDir string, paths []string, workingTree, quote bool) ([]string, error) {
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the git index in %s: %w", r.top, err)
+	}
+	match, err := r.pathspec(dir, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, entry := range idx.Entries {
+		if !match(entry.Name) {
+			continue
+		}
+		rel, err := r.relative(entry.Name, rootDir, quote)
+		if err != nil {
+			return nil, err
+		}
+		meta := fmt.Sprintf("%06o %s %d", uint32(entry.Mode), entry.Hash, entry.Stage)
+
+		if workingTree {
+			onDisk, modified, keep, err := goGitWorkingTreeEntry(entry, filepath.Join(r.top, filepath.FromSlash(entry.Name)))
+			if err != nil {
+				return nil, err
+			}
+			if modified {
+				name, err := r.relative(entry.Name, rootDir, false)
+				if err != nil {
+					return nil, err
+				}
+				changed = append(changed, name)
+				if !keep {
+					continue
+				}
+				meta = onDisk
+			}
+		}
+		fmt.Fprintf(out, "%s\t%s\n", meta, rel)
+	}
+
+	return changed, nil
+}
+
+// listTree writes the entries of paths, relative to dir, in the tree of commit to
+// out in the format of git ls-files -s, with paths relative to rootDir
+func (r *goGitRepository) listTree(out *bytes.Buffer, commit plumbing.Hash, dir, rootDir string, paths []string, quote bool) error {
+	commitObject, err := r.repo.CommitObject(commit)
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s in %s: %w", commit, r.top, err)
+	}
+	tree, err := commitObject.Tree()
+	if err != nil {
+		return fmt.Errorf("failed to read the tree of commit %s in %s: %w", commit, r.top, err)
+	}
+	match, err := r.pathspec(dir, paths)
+	if err != nil {
+		return err
+	}
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read the tree of commit %s in %s: %w", commit, r.top, err)
+		}
+		if entry.Mode == filemode.Dir || !match(name) {
+			continue
+		}
+		rel, err := r.relative(name, rootDir, quote)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%06o %s 0\t%s\n", uint32(entry.Mode), entry.Hash, rel)
+	}
+
+	return nil
+}
+
+// resolve returns the commit ref (a branch, tag or commit) points to
+func (r *goGitRepository) resolve(ref string) (plumbing.Hash, error) {
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to resolve %s in %s: %w", ref, r.top, err)
+	}
+	return *hash, nil
+}
+
+// goGitWorkingTreeEntry returns the "<mode> <hash> <stage>" of the file at path as it
+// is on disk if it differs from its index entry, in which case modified is set. keep
+// is false when the file was deleted. Like git, a file whose size and modification
+// time match the index is taken to be unchanged without reading it.
+func goGitWorkingTreeEntry(entry *index.Entry, path string) (meta string, modified, keep bool, err error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return "", true, false, nil
+	}
+	if err != nil {
+		return "", false, false, err
+	}
+
+	var mode filemode.FileMode
+	var content []byte
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		// git stores where a symlink points, not what it points at
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", false, false, err
+		}
+		mode = filemode.Symlink
+		content = []byte(target)
+	case info.Mode().IsRegular():
+		mode = filemode.Regular
+		if info.Mode()&0111 != 0 {
+			mode = filemode.Executable
+		}
+		if mode == entry.Mode && info.Size() == int64(entry.Size) && info.ModTime().Equal(entry.ModifiedAt) {
+			return "", false, true, nil
+		}
+		content, err = os.ReadFile(path)
+		if err != nil {
+			return "", false, false, err
+		}
+	case info.IsDir() && entry.Mode == filemode.Submodule:
+		// As git diff does, a submodule checked out at another commit or with changes
+		// of its own is modified, though its entry stays as it is in the index
+		changed, err := goGitSubmoduleChanged(path, entry.Hash)
+		if err != nil || !changed {
+			return "", false, true, err
+		}
+		return fmt.Sprintf("%06o %s %d", uint32(entry.Mode), entry.Hash, entry.Stage), true, true, nil
+	default:
+		return "", false, true, nil
+	}
+
+	hash := hashBlob(content)
+	if mode == entry.Mode && hash == entry.Hash.String() {
+		return "", false, true, nil
+	}
+	return fmt.Sprintf("%06o %s %d", uint32(mode), hash, entry.Stage), true, true, nil
+}
+
+// goGitSubmoduleChanged reports whether the submodule checked out at path is not at
+// commit or has changes in its working tree. A submodule that is not checked out is
+// unchanged.
+func goGitSubmoduleChanged(path string, commit plumbing.Hash) (bool, error) {
+	repo, err := git.PlainOpen(path)
+	if err == git.ErrRepositoryNotExists {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to open the submodule at %s: %w", path, err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve HEAD in %s: %w", path, err)
+	}
+	if head.Hash() != commit {
+		return true, nil
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return false, fmt.Errorf("failed to open the submodule at %s: %w", path, err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return false, fmt.Errorf("failed to check the submodule at %s for changes: %w", path, err)
+	}
+	return !status.IsClean(), nil
+}
+
+// goGitHashDirectories is hashDirectories for when there is no git binary
+func goGitHashDirectories(rootDir string, directories []string, workingTree bool, algorithm string) (string, bool, error) {
+	var listed bytes.Buffer
+	if _, err := goGitListDirectories(&listed, rootDir, directories, false); err != nil {
+		return "", false, err
+	}
+	if dir := untrackedDirectory(listed.String(), directories); dir != "" {
+		return "", false, untrackedError(rootDir, dir)
+	}
+	if !workingTree {
+		hash, err := hashListing(listed.Bytes(), algorithm)
+		return hash, false, err
+	}
+
+	var out bytes.Buffer
+	changed, err := goGitListDirectories(&out, rootDir, directories, true)
+	if err != nil {
+		return "", false, err
+	}
+	hash, err := hashListing(out.Bytes(), algorithm)
+	return hash, len(changed) > 0, err
+}
+
+// goGitListDirectories writes the index entries of directories, relative to rootDir,
+// to out as hashDirectories lists them, returning the files changed in the working
+// tree when workingTree is set
+func goGitListDirectories(out *bytes.Buffer, rootDir string, directories []string, workingTree bool) ([]string, error) {
+	own, others := groupByRepository(rootDir, directories)
+
+	var changed []string
+	if len(own) > 0 || len(others) == 0 {
+		repo, err := goGitOpen(rootDir)
+		if err != nil {
+			return nil, err
+		}
+		files, err := repo.listIndex(out, rootDir, rootDir, own, workingTree, true)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, files...)
+	}
+	for _, other := range others {
+		repo, err := goGitOpen(other.top)
+		if err != nil {
+			return nil, err
+		}
+		files, err := repo.listIndex(out, other.top, rootDir, other.paths, workingTree, false)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, files...)
+	}
+
+	return changed, nil
+}
+
+// goGitHashDirectoriesAtRef is HashDirectoriesAtRef for when there is no git binary
+func goGitHashDirectoriesAtRef(rootDir, ref string, directories []string, algorithm string) (string, error) {
+	own, others := groupByRepository(rootDir, directories)
+
+	repo, err := goGitOpen(rootDir)
+	if err != nil {
+		return "", err
+	}
+	commit, err := repo.resolve(ref)
+	if err != nil {
+		return "", err
+	}
+
+	var staged bytes.Buffer
+	if len(own) > 0 || len(others) == 0 {
+		if err := repo.listTree(&staged, commit, rootDir, rootDir, own, true); err != nil {
+			return "", err
+		}
+	}
+	for _, other := range others {
+		// Like lsTree, a submodule is listed at the commit recorded for it in ref
+		submodulePath, err := filepath.Rel(repo.top, other.top)
+		if err != nil || !filepath.IsLocal(submodulePath) {
+			return "", fmt.Errorf("cannot hash %s at %s: it is a separate git repository, not a submodule", other.top, ref)
+		}
+		commitObject, err := repo.repo.CommitObject(commit)
+		if err != nil {
+			return "", fmt.Errorf("failed to read commit %s in %s: %w", commit, repo.top, err)
+		}
+		tree, err := commitObject.Tree()
+		if err != nil {
+			return "", fmt.Errorf("failed to read the tree of commit %s in %s: %w", commit, repo.top, err)
+		}
+		gitlink, err := tree.FindEntry(filepath.ToSlash(submodulePath))
+		if err != nil || gitlink.Mode != filemode.Submodule {
+			return "", fmt.Errorf("cannot hash %s at %s: no submodule commit is recorded for it", other.top, ref)
+		}
+
+		submodule, err := goGitOpen(other.top)
+		if err != nil {
+			return "", err
+		}
+		if err := submodule.listTree(&staged, gitlink.Hash, other.top, rootDir, other.paths, false); err != nil {
+			return "", err
+		}
+	}
+
+	return hashListing(staged.Bytes(), algorithm)
+}
+
+// goGitRoot is GitRoot for when there is no git binary
+func goGitRoot(dir string) (string, error) {
+	repo, err := goGitOpen(dir)
+	if err != nil {
+		return "", err
+	}
+	return repo.top, nil
+}
+
+// goGitHeadCommit is HeadCommit for when there is no git binary
+func goGitHeadCommit(root string) (string, error) {
+	repo, err := goGitOpen(root)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve HEAD in %s: %w", repo.top, err)
+	}
+	return head.Hash().String(), nil
+}
+
+// goGitCommitTime is CommitTime for when there is no git binary
+func goGitCommitTime(root, ref string) (time.Time, error) {
+	repo, err := goGitOpen(root)
+	if err != nil {
+		return time.Time{}, err
+	}
+	hash, err := repo.resolve(ref)
+	if err != nil {
+		return time.Time{}, err
+	}
+	commit, err := repo.repo.CommitObject(hash)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read commit %s in %s: %w", ref, repo.top, err)
+	}
+	return commit.Committer.When, nil
+}
+
+// hashBlob returns the hash git gives data stored as a file, as git hash-object does
+func hashBlob(data []byte) string {
+	hash := sha1.New()
+	fmt.Fprintf(hash, "blob %d\x00", len(data))
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// quoteGitPath quotes name the way git does in its output when it contains control
+// characters, a double quote or a backslash, or, with quotePath, bytes outside ASCII
+func quoteGitPath(name string, quotePath bool) string {
+	needsQuoting := func(c byte) bool {
+		return c < 0x20 || c == '"' || c == '\\' || c == 0x7f || (quotePath && c >= 0x80)
+	}
+	quoted := false
+	for i := 0; i < len(name); i++ {
+		if needsQuoting(name[i]) {
+			quoted = true
+			break
+		}
+	}
+	if !quoted {
+		return name
+	}
+
+	escapes := map[byte]string{'\a': `\a`, '\b': `\b`, '\t': `\t`, '\n': `\n`, '\v': `\v`, '\f': `\f`, '\r': `\r`, '"': `\"`, '\\': `\\`}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case escapes[c] != "":
+			b.WriteString(escapes[c])
+		case needsQuoting(c):
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,174 @@
+package slarty
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// withoutGitBinary runs fn as slarty runs without a git binary
+func withoutGitBinary(t *testing.T, fn func()) {
+	t.Helper()
+	original := useGitBinary
+	useGitBinary = func() bool { return false }
+	defer func() { useGitBinary = original }()
+	fn()
+}
+
+func TestGoGitMatchesGitBinary(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+	mainDir := filepath.Join(tempDir, "main")
+	frontendDir := filepath.Join(tempDir, "frontend")
+	appDir := filepath.Join(mainDir, "app")
+
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "protocol.file.allow=always", "-c", "user.email=test@example.com", "-c", "user.name=Test User"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	for _, dir := range []string{mainDir, frontendDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+		git(dir, "init")
+	}
+	write(filepath.Join(frontendDir, "src", "app.js"), "console.log(1)")
+	git(frontendDir, "add", ".")
+	git(frontendDir, "commit", "-m", "Frontend")
+
+	// Paths git quotes in its output must hash the same
+	write(filepath.Join(appDir, "api", "main.go"), "package main")
+	write(filepath.Join(appDir, "api", "my file.go"), "package main // spaces")
+	write(filepath.Join(appDir, "api", "café.go"), "package main // accent")
+	write(filepath.Join(appDir, "api", `say "hi".go`), "package main // quotes")
+	write(filepath.Join(appDir, "api", "run.sh"), "#!/bin/sh")
+	if err := os.Chmod(filepath.Join(appDir, "api", "run.sh"), 0755); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	if err := os.Symlink("main.go", filepath.Join(appDir, "api", "link.go")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	write(filepath.Join(mainDir, "shared", "shared.go"), "package shared")
+	write(filepath.Join(appDir, "web", "index.html"), "<html>")
+	git(mainDir, "add", ".")
+	git(mainDir, "submodule", "add", frontendDir, "app/frontend")
+	git(mainDir, "commit", "-m", "Initial commit")
+
+	// The root directory is below the top of the repository, so paths are relative
+	directorySets := [][]string{
+		{"api"},
+		{"api", "../shared"},
+		{"api", "frontend/src"},
+		{"."},
+		nil,
+	}
+	check := func(description string) {
+		t.Helper()
+		for _, directories := range directorySets {
+			staged, err := HashDirectories(appDir, directories)
+			if err != nil {
+				t.Fatalf("HashDirectories failed: %v", err)
+			}
+			workingTree, dirty, err := HashWorkingTree(appDir, directories)
+			if err != nil {
+				t.Fatalf("HashWorkingTree failed: %v", err)
+			}
+			unstaged, err := UnstagedChanges(appDir, directories)
+			if err != nil {
+				t.Fatalf("UnstagedChanges failed: %v", err)
+			}
+			atHead, err := HashDirectoriesAtRef(appDir, "HEAD", directories)
+			if err != nil {
+				t.Fatalf("HashDirectoriesAtRef failed: %v", err)
+			}
+
+			withoutGitBinary(t, func() {
+				if hash, err := HashDirectories(appDir, directories); err != nil || hash != staged {
+					t.Errorf("%s: expected HashDirectories(%v) to be %s without git, got %s (%v)", description, directories, staged, hash, err)
+				}
+				if hash, isDirty, err := HashWorkingTree(appDir, directories); err != nil || hash != workingTree || isDirty != dirty {
+					t.Errorf("%s: expected HashWorkingTree(%v) to be %s, %v without git, got %s, %v (%v)", description, directories, workingTree, dirty, hash, isDirty, err)
+				}
+				if files, err := UnstagedChanges(appDir, directories); err != nil || !reflect.DeepEqual(files, unstaged) {
+					t.Errorf("%s: expected UnstagedChanges(%v) to be %v without git, got %v (%v)", description, directories, unstaged, files, err)
+				}
+				if hash, err := HashDirectoriesAtRef(appDir, "HEAD", directories); err != nil || hash != atHead {
+					t.Errorf("%s: expected HashDirectoriesAtRef(%v) to be %s without git, got %s (%v)", description, directories, atHead, hash, err)
+				}
+			})
+		}
+	}
+
+	check("clean tree")
+
+	write(filepath.Join(appDir, "api", "main.go"), "package main // changed")
+	write(filepath.Join(appDir, "api", "café.go"), "package main // changed too")
+	if err := os.Remove(filepath.Join(appDir, "api", "my file.go")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(appDir, "api", "run.sh"), 0644); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	write(filepath.Join(appDir, "frontend", "src", "app.js"), "console.log(2)")
+	check("unstaged changes")
+
+	git(mainDir, "add", "-A")
+	git(filepath.Join(appDir, "frontend"), "add", ".")
+	check("staged changes")
+
+	root, err := GitRoot(appDir)
+	if err != nil {
+		t.Fatalf("GitRoot failed: %v", err)
+	}
+	head, err := HeadCommit(appDir)
+	if err != nil {
+		t.Fatalf("HeadCommit failed: %v", err)
+	}
+	withoutGitBinary(t, func() {
+		if found, err := GitRoot(appDir); err != nil || found != root {
+			t.Errorf("Expected GitRoot to be %s without git, got %s (%v)", root, found, err)
+		}
+		if found, err := HeadCommit(appDir); err != nil || found != head {
+			t.Errorf("Expected HeadCommit to be %s without git, got %s (%v)", head, found, err)
+		}
+	})
+}
+
+func TestQuoteGitPath(t *testing.T) {
+	tests := map[string]string{
+		"api/main.go":     "api/main.go",
+		"api/my file.go":  "api/my file.go",
+		`api/say "hi".go`: `"api/say \"hi\".go"`,
+		"api/tab\there":   `"api/tab\there"`,
+		`api/back\slash`:  `"api/back\\slash"`,
+		"api/café.go":     `"api/caf\303\251.go"`,
+	}
+	for name, expected := range tests {
+		if quoted := quoteGitPath(name, true); quoted != expected {
+			t.Errorf("quoteGitPath(%q) = %s, want %s", name, quoted, expected)
+		}
+	}
+
+	if quoted := quoteGitPath("api/café.go", false); quoted != "api/café.go" {
+		t.Errorf("Expected core.quotePath=false to leave non-ASCII paths unquoted, got %s", quoted)
+	}
+}
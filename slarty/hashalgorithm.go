@@ -0,0 +1,48 @@
+package slarty
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Algorithms the listing of an artifact's files is hashed with to name it. The
+// listing has git's SHA-1 ids of the files in it either way; sha256 only changes
+// how the listing itself is hashed, for policies against SHA-1 identifiers.
+const (
+	HashAlgorithmSHA1   = "sha1"
+	HashAlgorithmSHA256 = "sha256"
+)
+
+// HashAlgorithmName returns the configured hash_algorithm, which is sha1, as git
+// hash-object hashes, unless another is set
+func (ac *ArtifactsConfig) HashAlgorithmName() string {
+	if ac.HashAlgorithm == "" {
+		return HashAlgorithmSHA1
+	}
+	return ac.HashAlgorithm
+}
+
+// ValidateHashAlgorithm checks that algorithm is one of the hash algorithms. An
+// empty algorithm is valid and means sha1.
+func ValidateHashAlgorithm(algorithm string) error {
+	switch algorithm {
+	case "", HashAlgorithmSHA1, HashAlgorithmSHA256:
+		return nil
+	}
+	return fmt.Errorf("unknown hash_algorithm %q (expected %q or %q)", algorithm, HashAlgorithmSHA1, HashAlgorithmSHA256)
+}
+
+// hashListing hashes the listing of an artifact's files with algorithm. sha1 gives
+// the hash git hash-object gives the listing, so artifacts keep the names they have
+// always had.
+func hashListing(listing []byte, algorithm string) (string, error) {
+	switch algorithm {
+	case "", HashAlgorithmSHA1:
+		return hashBlob(listing), nil
+	case HashAlgorithmSHA256:
+		sum := sha256.Sum256(listing)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	return "", ValidateHashAlgorithm(algorithm)
+}
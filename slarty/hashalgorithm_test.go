@@ -0,0 +1,76 @@
+package slarty
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHashAlgorithms(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+	git := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+		return out
+	}
+	git("init")
+	git("config", "user.email", "test@example.com")
+	git("config", "user.name", "Test User")
+	if err := os.MkdirAll(filepath.Join(tempDir, "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "web", "index.html"), []byte("<html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-m", "Initial commit")
+
+	// sha256 is the SHA-256 of the listing sha1 has git hash
+	sum := sha256.Sum256(git("ls-files", "-s", "web"))
+	want := hex.EncodeToString(sum[:])
+
+	config := &ArtifactsConfig{RootDirectory: tempDir, HashAlgorithm: HashAlgorithmSHA256}
+	config.Artifacts = []ArtifactConfig{{Name: "web", ArtifactPrefix: "web", Directories: []string{"web"}}}
+	hash, err := ArtifactHash("web", config)
+	if err != nil || hash != want {
+		t.Fatalf("Expected %s, got %s (%v)", want, hash, err)
+	}
+	if atRef, err := ArtifactHashAtRef("web", config, "HEAD"); err != nil || atRef != want {
+		t.Errorf("Expected %s at HEAD, got %s (%v)", want, atRef, err)
+	}
+	withoutGitBinary(t, func() {
+		if goGitHash, err := config.HashDirectories([]string{"web"}); err != nil || goGitHash != want {
+			t.Errorf("Expected %s without the git binary, got %s (%v)", want, goGitHash, err)
+		}
+	})
+
+	// sha1, and leaving hash_algorithm out, keep the names artifacts always had
+	sha1Hash, err := HashDirectories(tempDir, []string{"web"})
+	if err != nil || len(sha1Hash) != 40 {
+		t.Fatalf("Expected a SHA-1 hash, got %s (%v)", sha1Hash, err)
+	}
+	for _, algorithm := range []string{"", HashAlgorithmSHA1} {
+		config.HashAlgorithm = algorithm
+		if hash, err := ArtifactHash("web", config); err != nil || hash != sha1Hash {
+			t.Errorf("%q: expected %s, got %s (%v)", algorithm, sha1Hash, hash, err)
+		}
+	}
+
+	config.HashAlgorithm = "blake3"
+	if _, err := ArtifactHash("web", config); err == nil || !strings.Contains(err.Error(), "unknown hash_algorithm") {
+		t.Errorf("Expected an unknown algorithm to fail, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package slarty
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// BuildRecord is the outcome of a single successful artifact build
+type BuildRecord struct {
+	Artifact     string    `json:"artifact"`
+	ArtifactName string    `json:"artifact_name"`
+	Size         int64     `json:"size"`
+	Duration     float64   `json:"duration_seconds"`
+	BuiltAt      time.Time `json:"built_at"`
+}
+
+// AppendBuildRecord appends record to the newline-delimited JSON history file at
+// path, creating the file and its directory if needed
+func AppendBuildRecord(path string, record BuildRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+
+	return nil
+}
+
+// ReadBuildHistory reads every record from the history file at path, oldest first.
+// A missing file is an empty history. Lines that cannot be parsed are skipped.
+func ReadBuildHistory(path string) ([]BuildRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	var records []BuildRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record BuildRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	return records, nil
+}
+
+// LatestBuilds returns the most recent record for each artifact
+func LatestBuilds(records []BuildRecord) map[string]BuildRecord {
+	latest := make(map[string]BuildRecord)
+	for _, record := range records {
+		if current, ok := latest[record.Artifact]; !ok || !record.BuiltAt.Before(current.BuiltAt) {
+			latest[record.Artifact] = record
+		}
+	}
+
+	return latest
+}
+
+// BuildStats summarises the recorded builds of one artifact
+type BuildStats struct {
+	Artifact      string    `json:"artifact"`
+	Builds        int       `json:"builds"`
+	FirstSize     int64     `json:"first_size"`
+	LastSize      int64     `json:"last_size"`
+	MaxSize       int64     `json:"max_size"`
+	SizeGrowth    int64     `json:"size_growth"`
+	GrowthPercent float64   `json:"growth_percent"`
+	AvgDuration   float64   `json:"avg_duration_seconds"`
+	LastDuration  float64   `json:"last_duration_seconds"`
+	MaxDuration   float64   `json:"max_duration_seconds"`
+	LastBuiltAt   time.Time `json:"last_built_at"`
+}
+
+// SummarizeBuilds groups records by artifact and works out size and duration trends
+// between the oldest and newest build of each. Records are expected oldest first,
+// as returned by ReadBuildHistory. The result is sorted by artifact name.
+func SummarizeBuilds(records []BuildRecord) []BuildStats {
+	byArtifact := make(map[string]*BuildStats)
+	totalDuration := make(map[string]float64)
+	for _, record := range records {
+		stats, ok := byArtifact[record.Artifact]
+		if !ok {
+			stats = &BuildStats{Artifact: record.Artifact, FirstSize: record.Size}
+			byArtifact[record.Artifact] = stats
+		}
+		stats.Builds++
+		stats.LastSize = record.Size
+		stats.LastDuration = record.Duration
+		stats.LastBuiltAt = record.BuiltAt
+		if record.Size > stats.MaxSize {
+			stats.MaxSize = record.Size
+		}
+		if record.Duration > stats.MaxDuration {
+			stats.MaxDuration = record.Duration
+		}
+		totalDuration[record.Artifact] += record.Duration
+	}
+
+	summary := make([]BuildStats, 0, len(byArtifact))
+	for name, stats := range byArtifact {
+		stats.AvgDuration = totalDuration[name] / float64(stats.Builds)
+		stats.SizeGrowth = stats.LastSize - stats.FirstSize
+		if stats.FirstSize > 0 {
+			stats.GrowthPercent = float64(stats.SizeGrowth) / float64(stats.FirstSize) * 100
+		}
+		summary = append(summary, *stats)
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Artifact < summary[j].Artifact })
+
+	return summary
+}
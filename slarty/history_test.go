@@ -0,0 +1,86 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "history.jsonl")
+
+	// A missing history is empty
+	records, err := ReadBuildHistory(path)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Expected empty history, got %v, %v", records, err)
+	}
+
+	first := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i, record := range []BuildRecord{
+		{Artifact: "api", ArtifactName: "api-1.tar.gz", Size: 100, Duration: 10, BuiltAt: first},
+		{Artifact: "web", ArtifactName: "web-1.tar.gz", Size: 200, Duration: 20, BuiltAt: first.Add(time.Minute)},
+		{Artifact: "api", ArtifactName: "api-2.tar.gz", Size: 150, Duration: 12, BuiltAt: first.Add(time.Hour)},
+	} {
+		if err := AppendBuildRecord(path, record); err != nil {
+			t.Fatalf("AppendBuildRecord %d failed: %v", i, err)
+		}
+	}
+
+	// Corrupt lines are skipped rather than failing the whole history
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open history: %v", err)
+	}
+	file.WriteString("not json\n")
+	file.Close()
+
+	records, err = ReadBuildHistory(path)
+	if err != nil {
+		t.Fatalf("ReadBuildHistory failed: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+
+	latest := LatestBuilds(records)
+	if latest["api"].ArtifactName != "api-2.tar.gz" || latest["api"].Size != 150 {
+		t.Errorf("Expected latest api build to be api-2.tar.gz, got %+v", latest["api"])
+	}
+	if latest["web"].ArtifactName != "web-1.tar.gz" {
+		t.Errorf("Expected latest web build to be web-1.tar.gz, got %+v", latest["web"])
+	}
+}
+
+func TestSummarizeBuilds(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []BuildRecord{
+		{Artifact: "web", Size: 100, Duration: 10, BuiltAt: start},
+		{Artifact: "api", Size: 500, Duration: 30, BuiltAt: start},
+		{Artifact: "web", Size: 400, Duration: 20, BuiltAt: start.Add(time.Hour)},
+		{Artifact: "web", Size: 300, Duration: 60, BuiltAt: start.Add(2 * time.Hour)},
+	}
+
+	stats := SummarizeBuilds(records)
+	if len(stats) != 2 || stats[0].Artifact != "api" || stats[1].Artifact != "web" {
+		t.Fatalf("Expected stats for api and web, got %+v", stats)
+	}
+
+	web := stats[1]
+	if web.Builds != 3 || web.FirstSize != 100 || web.LastSize != 300 || web.MaxSize != 400 {
+		t.Errorf("Unexpected sizes for web: %+v", web)
+	}
+	if web.SizeGrowth != 200 || web.GrowthPercent != 200 {
+		t.Errorf("Expected web to grow by 200 bytes (200%%), got %d (%.1f%%)", web.SizeGrowth, web.GrowthPercent)
+	}
+	if web.AvgDuration != 30 || web.LastDuration != 60 || web.MaxDuration != 60 {
+		t.Errorf("Unexpected durations for web: %+v", web)
+	}
+	if !web.LastBuiltAt.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("Expected last build time to be the newest record, got %v", web.LastBuiltAt)
+	}
+
+	if stats[0].SizeGrowth != 0 || stats[0].Builds != 1 {
+		t.Errorf("Expected a single api build with no growth, got %+v", stats[0])
+	}
+}
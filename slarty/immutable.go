@@ -0,0 +1,88 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrArtifactExists is returned when storing an artifact whose name is already taken in
+// an immutable repository
+var ErrArtifactExists = errors.New("artifact already exists in immutable repository")
+
+// ImmutableRepositoryAdapter wraps a repository so that an artifact, once stored, is
+// never replaced. This protects released artifacts from being silently clobbered by a
+// hash collision or a misconfigured artifact prefix.
+type ImmutableRepositoryAdapter struct {
+	RepositoryAdapter
+}
+
+// NewImmutableRepositoryAdapter wraps repo so StoreArtifact refuses to overwrite
+func NewImmutableRepositoryAdapter(repo RepositoryAdapter) *ImmutableRepositoryAdapter {
+	return &ImmutableRepositoryAdapter{RepositoryAdapter: repo}
+}
+
+// StoreArtifact stores an artifact unless one with the same name already exists
+func (i *ImmutableRepositoryAdapter) StoreArtifact(r io.Reader, artifactName string) error {
+	exists, err := i.RepositoryAdapter.ArtifactExists(artifactName)
+	if err != nil {
+		return fmt.Errorf("failed to check if artifact exists in repository: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", ErrArtifactExists, artifactName)
+	}
+
+	return i.RepositoryAdapter.StoreArtifact(r, artifactName)
+}
+
+// PrefetchArtifacts finds out which artifacts exist in bulk when the wrapped repository
+// can
+func (i *ImmutableRepositoryAdapter) PrefetchArtifacts(artifactNames []string) {
+	PrefetchArtifacts(i.RepositoryAdapter, artifactNames)
+}
+
+// ArtifactSize returns the size of a stored artifact when the wrapped repository can
+// report it
+func (i *ImmutableRepositoryAdapter) ArtifactSize(artifactName string) (int64, error) {
+	sizer, ok := i.RepositoryAdapter.(ArtifactSizer)
+	if !ok {
+		return 0, errors.New("repository cannot report artifact sizes")
+	}
+	return sizer.ArtifactSize(artifactName)
+}
+
+// SameContent compares a stored artifact with an archive when the wrapped repository
+// can, and otherwise reports false
+func (i *ImmutableRepositoryAdapter) SameContent(artifactName string, r io.Reader) (bool, error) {
+	comparer, ok := i.RepositoryAdapter.(ArtifactComparer)
+	if !ok {
+		return false, nil
+	}
+	return comparer.SameContent(artifactName, r)
+}
+
+// LabelArtifact attaches labels to a stored artifact when the wrapped repository can
+func (i *ImmutableRepositoryAdapter) LabelArtifact(artifactName string, labels map[string]string) error {
+	return LabelArtifact(i.RepositoryAdapter, artifactName, labels)
+}
+
+// ArtifactLabels returns the labels of a stored artifact when the wrapped repository
+// can
+func (i *ImmutableRepositoryAdapter) ArtifactLabels(artifactName string) (map[string]string, error) {
+	return ArtifactLabels(i.RepositoryAdapter, artifactName)
+}
+
+// ListArtifacts lists the artifacts starting with prefix when the wrapped repository
+// can
+func (i *ImmutableRepositoryAdapter) ListArtifacts(prefix string) ([]string, error) {
+	return ListArtifacts(i.RepositoryAdapter, prefix)
+}
+
+// AllowOverwrite returns the repository behind an immutable repository, so artifacts
+// can be replaced. Any other repository is returned unchanged.
+func AllowOverwrite(repo RepositoryAdapter) RepositoryAdapter {
+	if immutable, ok := repo.(*ImmutableRepositoryAdapter); ok {
+		return immutable.RepositoryAdapter
+	}
+	return repo
+}
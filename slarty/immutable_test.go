@@ -0,0 +1,56 @@
+package slarty
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImmutableRepositoryAdapter(t *testing.T) {
+	root := t.TempDir()
+	config := &ArtifactsConfig{}
+	config.Repository.Adapter = "Local"
+	config.Repository.Options.Root = root
+	config.Repository.Immutable = true
+
+	repo, err := NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+	if _, ok := repo.(*ImmutableRepositoryAdapter); !ok {
+		t.Fatalf("Expected an immutable repository, got %T", repo)
+	}
+
+	if err := repo.StoreArtifact(strings.NewReader("first"), "a.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed for a new artifact: %v", err)
+	}
+	err = repo.StoreArtifact(strings.NewReader("second"), "a.tar.gz")
+	if !errors.Is(err, ErrArtifactExists) {
+		t.Fatalf("Expected ErrArtifactExists when overwriting, got %v", err)
+	}
+	if same, err := repo.(ArtifactComparer).SameContent("a.tar.gz", strings.NewReader("first")); !same || err != nil {
+		t.Errorf("Expected the original artifact to be kept, got %v (%v)", same, err)
+	}
+	if size, err := repo.(ArtifactSizer).ArtifactSize("a.tar.gz"); size != 5 || err != nil {
+		t.Errorf("Expected size 5, got %d (%v)", size, err)
+	}
+
+	// AllowOverwrite gives back the underlying repository
+	mutable := AllowOverwrite(repo)
+	if _, ok := mutable.(*LocalRepositoryAdapter); !ok {
+		t.Fatalf("Expected AllowOverwrite to return the local repository, got %T", mutable)
+	}
+	if err := mutable.StoreArtifact(strings.NewReader("second"), "a.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed with overwrite allowed: %v", err)
+	}
+	if same, _ := mutable.(ArtifactComparer).SameContent("a.tar.gz", strings.NewReader("second")); !same {
+		t.Errorf("Expected the artifact to be replaced")
+	}
+
+	// Repositories that are not immutable are left as they are
+	local := NewLocalRepositoryAdapter(filepath.Join(root, "other"))
+	if AllowOverwrite(local) != RepositoryAdapter(local) {
+		t.Errorf("Expected AllowOverwrite to return a mutable repository unchanged")
+	}
+}
@@ -0,0 +1,209 @@
+package slarty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrLabelsUnsupported is returned when labelling an artifact in a repository that
+// cannot store labels
+var ErrLabelsUnsupported = errors.New("repository cannot store artifact labels")
+
+// ArtifactLabeler is implemented by repository adapters that can attach labels, such
+// as the branch or build number, to stored artifacts
+type ArtifactLabeler interface {
+	// LabelArtifact attaches labels to a stored artifact, replacing any it had
+	LabelArtifact(artifactName string, labels map[string]string) error
+
+	// ArtifactLabels returns the labels of a stored artifact, which has none when it
+	// was never labelled
+	ArtifactLabels(artifactName string) (map[string]string, error)
+}
+
+// maxLabels is the most labels an artifact may have, which is as many tags as S3 keeps
+// on an object
+const maxLabels = 10
+
+// labelKeyPattern and labelValuePattern are the characters and lengths S3 allows in
+// object tags
+var (
+	labelKeyPattern   = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]{1,128}$`)
+	labelValuePattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]{0,256}$`)
+)
+
+// ParseLabels parses labels written as key=value, such as branch=main
+func ParseLabels(values []string) (map[string]string, error) {
+	labels := make(map[string]string, len(values))
+	for _, value := range values {
+		key, label, found := strings.Cut(value, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", value)
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(label)
+	}
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// ValidateLabels checks labels can be stored in any repository
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("an artifact can have at most %d labels, got %d", maxLabels, len(labels))
+	}
+	for key, value := range labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid label key %q: use up to 128 letters, numbers, spaces and _.:/=+-@", key)
+		}
+		if !labelValuePattern.MatchString(value) {
+			return fmt.Errorf("invalid value %q for label %s: use up to 256 letters, numbers, spaces and _.:/=+-@", value, key)
+		}
+	}
+	return nil
+}
+
+// FormatLabels writes labels as key=value pairs in key order
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + labels[key]
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// LabelArtifact attaches labels to a stored artifact when repo can store them
+func LabelArtifact(repo RepositoryAdapter, artifactName string, labels map[string]string) error {
+	labeler, ok := repo.(ArtifactLabeler)
+	if !ok {
+		return ErrLabelsUnsupported
+	}
+	return labeler.LabelArtifact(artifactName, labels)
+}
+
+// ArtifactLabels returns the labels of a stored artifact when repo can store them
+func ArtifactLabels(repo RepositoryAdapter, artifactName string) (map[string]string, error) {
+	labeler, ok := repo.(ArtifactLabeler)
+	if !ok {
+		return nil, ErrLabelsUnsupported
+	}
+	return labeler.ArtifactLabels(artifactName)
+}
+
+// labelsSuffix ends the name of the file holding an artifact's labels in the local
+// repository
+const labelsSuffix = ".labels.json"
+
+// labelsPath returns the path of the file next to an artifact in the local repository
+// that holds its labels
+func (l *LocalRepositoryAdapter) labelsPath(artifactName string) string {
+	return l.artifactPath(artifactName) + labelsSuffix
+}
+
+// LabelArtifact writes the artifact's labels to a file next to it
+func (l *LocalRepositoryAdapter) LabelArtifact(artifactName string, labels map[string]string) error {
+	if exists, err := l.ArtifactExists(artifactName); err != nil || !exists {
+		return fmt.Errorf("artifact not found in repository: %s", artifactName)
+	}
+	data, err := json.MarshalIndent(labels, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write a temporary file and rename it into place, as StoreArtifact does
+	path := l.labelsPath(artifactName)
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write artifact labels: %w", err)
+	}
+	_, err = temp.Write(append(data, '\n'))
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return fmt.Errorf("failed to write artifact labels: %w", err)
+	}
+	return nil
+}
+
+// ArtifactLabels reads the artifact's labels from the file next to it
+func (l *LocalRepositoryAdapter) ArtifactLabels(artifactName string) (map[string]string, error) {
+	data, err := os.ReadFile(l.labelsPath(artifactName))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact labels: %w", err)
+	}
+	labels := map[string]string{}
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("failed to read artifact labels: %w", err)
+	}
+	return labels, nil
+}
+
+// LabelArtifact sets the artifact's labels as the tags of its S3 object, which S3
+// lifecycle rules can filter on
+func (s *S3RepositoryAdapter) LabelArtifact(artifactName string, labels map[string]string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s3MetadataTimeout)
+	defer cancel()
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tags := make([]types.Tag, len(keys))
+	for i, key := range keys {
+		tags[i] = types.Tag{Key: aws.String(key), Value: aws.String(labels[key])}
+	}
+
+	_, err := s.client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(s.bucketName),
+		Key:     aws.String(s.getObjectKey(artifactName)),
+		Tagging: &types.Tagging{TagSet: tags},
+	})
+	if err != nil {
+		return s3Failure("tag artifact in S3", err)
+	}
+	return nil
+}
+
+// ArtifactLabels returns the tags of the artifact's S3 object
+func (s *S3RepositoryAdapter) ArtifactLabels(artifactName string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3MetadataTimeout)
+	defer cancel()
+
+	result, err := s.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(s.getObjectKey(artifactName)),
+	})
+	if err != nil {
+		return nil, s3Failure("get artifact tags from S3", err)
+	}
+	labels := make(map[string]string, len(result.TagSet))
+	for _, tag := range result.TagSet {
+		labels[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return labels, nil
+}
@@ -0,0 +1,108 @@
+package slarty
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels([]string{"branch=feature/login", "build = 42", "ticket=", "note=a=b"})
+	if err != nil {
+		t.Fatalf("ParseLabels failed: %v", err)
+	}
+	expected := map[string]string{"branch": "feature/login", "build": "42", "ticket": "", "note": "a=b"}
+	if !reflect.DeepEqual(labels, expected) {
+		t.Errorf("Expected %v, got %v", expected, labels)
+	}
+	if got := FormatLabels(labels); got != "branch=feature/login, build=42, note=a=b, ticket=" {
+		t.Errorf("Unexpected formatted labels %q", got)
+	}
+
+	tooMany := make([]string, maxLabels+1)
+	for i := range tooMany {
+		tooMany[i] = string(rune('a'+i)) + "=x"
+	}
+	for _, bad := range [][]string{{"branch"}, {"=main"}, {"branch=main;rm"}, {strings.Repeat("k", 129) + "=v"}, tooMany} {
+		if _, err := ParseLabels(bad); err == nil {
+			t.Errorf("Expected %v to be rejected", bad)
+		}
+	}
+}
+
+func TestLocalRepositoryAdapterLabels(t *testing.T) {
+	repo := NewImmutableRepositoryAdapter(NewLocalRepositoryAdapter(t.TempDir()))
+	if err := repo.StoreArtifact(strings.NewReader("archive"), "web-1.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+
+	if labels, err := ArtifactLabels(repo, "web-1.tar.gz"); err != nil || len(labels) != 0 {
+		t.Errorf("Expected no labels yet, got %v, %v", labels, err)
+	}
+	labels := map[string]string{"branch": "main", "build": "42"}
+	if err := LabelArtifact(repo, "web-1.tar.gz", labels); err != nil {
+		t.Fatalf("LabelArtifact failed: %v", err)
+	}
+	if got, err := ArtifactLabels(repo, "web-1.tar.gz"); err != nil || !reflect.DeepEqual(got, labels) {
+		t.Errorf("Expected %v, got %v, %v", labels, got, err)
+	}
+
+	// Labels replace the ones an artifact had
+	if err := LabelArtifact(repo, "web-1.tar.gz", map[string]string{"branch": "release"}); err != nil {
+		t.Fatalf("LabelArtifact failed: %v", err)
+	}
+	if got, _ := ArtifactLabels(repo, "web-1.tar.gz"); !reflect.DeepEqual(got, map[string]string{"branch": "release"}) {
+		t.Errorf("Expected the labels to be replaced, got %v", got)
+	}
+
+	if err := LabelArtifact(repo, "missing.tar.gz", labels); err == nil {
+		t.Errorf("Expected labelling a missing artifact to fail")
+	}
+	if err := LabelArtifact(struct{ RepositoryAdapter }{repo}, "web-1.tar.gz", labels); err != ErrLabelsUnsupported {
+		t.Errorf("Expected ErrLabelsUnsupported, got %v", err)
+	}
+}
+
+func TestS3RepositoryAdapterLabels(t *testing.T) {
+	var mu sync.Mutex
+	tagging := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["tagging"]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			tagging[r.URL.Path] = string(body)
+		case http.MethodGet:
+			body, ok := tagging[r.URL.Path]
+			if !ok {
+				body = "<Tagging><TagSet></TagSet></Tagging>"
+			}
+			io.WriteString(w, body)
+		}
+	}))
+	defer server.Close()
+
+	adapter := newTestS3Adapter(t, server.URL, "app")
+	labels := map[string]string{"branch": "feature/login", "build": "42"}
+	if err := adapter.LabelArtifact("web-1.tar.gz", labels); err != nil {
+		t.Fatalf("LabelArtifact failed: %v", err)
+	}
+	if _, ok := tagging["/builds/app/web-1.tar.gz"]; !ok {
+		t.Fatalf("Expected the object to be tagged, got %v", tagging)
+	}
+	if got, err := adapter.ArtifactLabels("web-1.tar.gz"); err != nil || !reflect.DeepEqual(got, labels) {
+		t.Errorf("Expected %v, got %v, %v", labels, got, err)
+	}
+	if got, err := adapter.ArtifactLabels("other.tar.gz"); err != nil || len(got) != 0 {
+		t.Errorf("Expected no labels on an untagged object, got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,88 @@
+package slarty
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LatestPointer records the artifact most recently stored for an artifact or asset,
+// so it can be deployed without knowing its hash
+type LatestPointer struct {
+	ArtifactName string    `json:"artifact_name"`
+	Hash         string    `json:"hash,omitempty"`
+	Commit       string    `json:"commit,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+}
+
+// LatestPointerName returns the name of the latest pointer for name, which is the
+// artifact prefix for artifacts, including any variant or platform, and the asset
+// name for assets
+func LatestPointerName(name string) string {
+	return name + "-latest.json"
+}
+
+// WriteLatestPointer stores pointer as the latest for name. Pointers move with every
+// build, so they are written even in an immutable repository.
+func WriteLatestPointer(repo RepositoryAdapter, name string, pointer LatestPointer) error {
+	out, err := json.MarshalIndent(pointer, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	pointerName := LatestPointerName(name)
+	if err := AllowOverwrite(repo).StoreArtifact(bytes.NewReader(append(out, '\n')), pointerName); err != nil {
+		return fmt.Errorf("failed to write latest pointer %s: %w", pointerName, err)
+	}
+	return nil
+}
+
+// ReadLatestPointer returns the latest pointer stored for name
+func ReadLatestPointer(repo RepositoryAdapter, name string) (*LatestPointer, error) {
+	pointerName := LatestPointerName(name)
+	exists, err := repo.ArtifactExists(pointerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for latest pointer %s: %w", pointerName, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("no latest pointer %s in repository", pointerName)
+	}
+
+	var buf bytes.Buffer
+	if err := repo.RetrieveArtifact(pointerName, &buf); err != nil {
+		return nil, fmt.Errorf("failed to read latest pointer %s: %w", pointerName, err)
+	}
+
+	var pointer LatestPointer
+	if err := json.Unmarshal(buf.Bytes(), &pointer); err != nil {
+		return nil, fmt.Errorf("failed to parse latest pointer %s: %w", pointerName, err)
+	}
+	if err := validateArtifactName(pointer.ArtifactName); err != nil {
+		return nil, fmt.Errorf("latest pointer %s: %w", pointerName, err)
+	}
+	return &pointer, nil
+}
+
+// HashFromArtifactName returns the hash an artifact name was built from by
+// ArtifactNameForHash, or an empty string when the name does not match the artifact
+func HashFromArtifactName(config ArtifactConfig, artifactName string, artifactsConfig *ArtifactsConfig) string {
+	if config.IsDocker() {
+		prefix := config.Image + ":" + config.ArtifactPrefix + "-"
+		if !strings.HasPrefix(artifactName, prefix) || len(artifactName) <= len(prefix) {
+			return ""
+		}
+		return artifactName[len(prefix):]
+	}
+
+	pattern, err := artifactsConfig.artifactNamePattern(config)
+	if err != nil {
+		return ""
+	}
+	match := pattern.FindStringSubmatch(artifactName)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
@@ -0,0 +1,65 @@
+package slarty
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLatestPointer(t *testing.T) {
+	config := &ArtifactsConfig{}
+	config.Repository.Adapter = "Local"
+	config.Repository.Options.Root = t.TempDir()
+	config.Repository.Immutable = true
+
+	repo, err := NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+
+	if _, err := ReadLatestPointer(repo, "web"); err == nil {
+		t.Errorf("Expected an error when there is no latest pointer")
+	}
+
+	// Pointers move even in an immutable repository
+	for _, hash := range []string{"aaa", "bbb"} {
+		pointer := LatestPointer{ArtifactName: "web-" + hash + ".tar.gz", Hash: hash, Commit: "c0ffee"}
+		if err := WriteLatestPointer(repo, "web", pointer); err != nil {
+			t.Fatalf("WriteLatestPointer failed: %v", err)
+		}
+	}
+	pointer, err := ReadLatestPointer(repo, "web")
+	if err != nil {
+		t.Fatalf("ReadLatestPointer failed: %v", err)
+	}
+	if pointer.ArtifactName != "web-bbb.tar.gz" || pointer.Hash != "bbb" || pointer.Commit != "c0ffee" {
+		t.Errorf("Expected the second pointer, got %+v", pointer)
+	}
+
+	// A pointer outside the repository, or one that is not JSON, is rejected
+	for _, content := range []string{`{"artifact_name": "../../etc/passwd"}`, "web-ccc.tar.gz"} {
+		if err := AllowOverwrite(repo).StoreArtifact(strings.NewReader(content), LatestPointerName("web")); err != nil {
+			t.Fatalf("StoreArtifact failed: %v", err)
+		}
+		if _, err := ReadLatestPointer(repo, "web"); err == nil {
+			t.Errorf("Expected latest pointer %q to be rejected", content)
+		}
+	}
+}
+
+func TestHashFromArtifactName(t *testing.T) {
+	config := &ArtifactsConfig{}
+	archive := ArtifactConfig{Name: "web", ArtifactPrefix: "web"}
+	name, _ := ArtifactNameForHash(archive, "abc123", config)
+	if hash := HashFromArtifactName(archive, name, config); hash != "abc123" {
+		t.Errorf("Expected abc123, got %q", hash)
+	}
+	if hash := HashFromArtifactName(archive, "api-abc123.tar.gz", config); hash != "" {
+		t.Errorf("Expected no hash for another artifact's name, got %q", hash)
+	}
+
+	image := ArtifactConfig{Name: "api", Type: ArtifactTypeDocker, Image: "registry.example.com/api", ArtifactPrefix: "api"}
+	name, _ = ArtifactNameForHash(image, "def456", config)
+	if hash := HashFromArtifactName(image, name, config); hash != "def456" {
+		t.Errorf("Expected def456, got %q", hash)
+	}
+}
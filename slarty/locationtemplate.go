@@ -0,0 +1,111 @@
+package slarty
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// locationPlaceholderPattern matches a placeholder in a deploy location, such as {env}
+var locationPlaceholderPattern = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// LocationVariableEnv returns the environment variable that sets a deploy location
+// placeholder, SLARTY_ followed by its name in upper case
+func LocationVariableEnv(name string) string {
+	return "SLARTY_" + strings.ToUpper(name)
+}
+
+// LocationVariable returns the value of a deploy location placeholder: its value in
+// vars, given with --var, then the environment variable named by LocationVariableEnv
+// or, for {hostname} when neither is set, the machine's hostname. Names match
+// regardless of case. A value must be a single directory name, so a placeholder can
+// never move a deploy location somewhere else with "..".
+func LocationVariable(name string, vars map[string]string) (string, error) {
+	value, ok := lookupVariable(vars, name)
+	if !ok {
+		value, ok = os.LookupEnv(LocationVariableEnv(name))
+	}
+	if !ok && strings.EqualFold(name, "hostname") {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return "", fmt.Errorf("failed to get the hostname for {%s}: %w", name, err)
+		}
+		value, ok = hostname, true
+	}
+	if !ok {
+		return "", fmt.Errorf("{%s} is not set; set %s or pass --var %s=value", name, LocationVariableEnv(name), name)
+	}
+	if err := CheckLocationVariable(name, value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// lookupVariable returns the value in vars named name, ignoring case
+func lookupVariable(vars map[string]string, name string) (string, bool) {
+	for key, value := range vars {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// CheckLocationVariable returns an error if value cannot be the value of the
+// placeholder name, because it is not a single directory name
+func CheckLocationVariable(name, value string) error {
+	if value == "" || value == "." || value == ".." || strings.ContainsAny(value, `/\`) {
+		return fmt.Errorf("{%s} must be a single directory name, got %q", name, value)
+	}
+	return nil
+}
+
+// ExpandLocation replaces the placeholders in a deploy location with their values
+// from vars or the environment. Placeholders without a usable value are left in place
+// for CheckLocation to report.
+func ExpandLocation(location string, vars map[string]string) string {
+	return locationPlaceholderPattern.ReplaceAllStringFunc(location, func(placeholder string) string {
+		value, err := LocationVariable(placeholder[1:len(placeholder)-1], vars)
+		if err != nil {
+			return placeholder
+		}
+		return value
+	})
+}
+
+// CheckLocation returns an error if an expanded deploy location still has a
+// placeholder, explaining why it could not be resolved. Values given with --var are
+// checked before the configuration is read and always expand, so only the
+// environment can explain a placeholder that is left.
+func CheckLocation(location string) error {
+	match := locationPlaceholderPattern.FindStringSubmatch(location)
+	if match == nil {
+		return nil
+	}
+	if _, err := LocationVariable(match[1], nil); err != nil {
+		return fmt.Errorf("deploy location %s: %w", location, err)
+	}
+	return nil
+}
+
+// CheckLocations returns an error if the artifact's deploy location or releases
+// directory still has a placeholder after expansion
+func (a ArtifactConfig) CheckLocations() error {
+	if err := CheckLocation(a.DeployLocation); err != nil {
+		return err
+	}
+	return CheckLocation(a.ReleasesDirectory)
+}
+
+// expandLocations resolves the placeholders in the deploy locations and releases
+// directories of the artifacts and assets, with the configuration's Variables
+func (ac *ArtifactsConfig) expandLocations() {
+	for i := range ac.Artifacts {
+		ac.Artifacts[i].DeployLocation = ExpandLocation(ac.Artifacts[i].DeployLocation, ac.Variables)
+		ac.Artifacts[i].ReleasesDirectory = ExpandLocation(ac.Artifacts[i].ReleasesDirectory, ac.Variables)
+	}
+	for i := range ac.Assets {
+		ac.Assets[i].DeployLocation = ExpandLocation(ac.Assets[i].DeployLocation, ac.Variables)
+	}
+}
@@ -0,0 +1,102 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandLocation(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("No hostname: %v", err)
+	}
+	t.Setenv("SLARTY_ENV", "prod")
+	t.Setenv("SLARTY_TENANT", "../escape")
+	for _, name := range []string{"SLARTY_HOSTNAME", "SLARTY_REGION"} {
+		// Setenv restores the variable afterwards
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+
+	tests := []struct {
+		location string
+		want     string
+		wantErr  string
+	}{
+		{"releases/{env}/api", "releases/prod/api", ""},
+		{"releases/{env}/{hostname}/api", "releases/prod/" + hostname + "/api", ""},
+		{"public", "public", ""},
+		{"releases/{region}/api", "releases/{region}/api", "set SLARTY_REGION or pass --var region=value"},
+		{"tenants/{tenant}", "tenants/{tenant}", "must be a single directory name"},
+	}
+	for _, tt := range tests {
+		got := ExpandLocation(tt.location, nil)
+		if got != tt.want {
+			t.Errorf("ExpandLocation(%q) = %q, want %q", tt.location, got, tt.want)
+		}
+		err := CheckLocation(got)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("CheckLocation(%q) failed: %v", got, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("Expected CheckLocation(%q) to fail with %q, got %v", got, tt.wantErr, err)
+		}
+	}
+
+	// SLARTY_HOSTNAME wins over the real hostname
+	t.Setenv("SLARTY_HOSTNAME", "web1")
+	if got := ExpandLocation("{hostname}/api", nil); got != "web1/api" {
+		t.Errorf("Expected SLARTY_HOSTNAME to be used, got %q", got)
+	}
+
+	// Values given with --var win over the environment without changing it
+	vars := map[string]string{"env": "staging", "Region": "eu"}
+	if got := ExpandLocation("releases/{env}/{region}/api", vars); got != "releases/staging/eu/api" {
+		t.Errorf("Expected the variables to be used, got %q", got)
+	}
+	if got := os.Getenv("SLARTY_ENV"); got != "prod" {
+		t.Errorf("Expected SLARTY_ENV to be left alone, got %q", got)
+	}
+	if got := ExpandLocation("tenants/{tenant}", map[string]string{"tenant": "a/b"}); got != "tenants/{tenant}" {
+		t.Errorf("Expected a value that is not a directory name to be left unexpanded, got %q", got)
+	}
+}
+
+func TestReadArtifactsJsonExpandsLocations(t *testing.T) {
+	t.Setenv("SLARTY_ENV", "staging")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifacts.json")
+	content := `{
+		"application": "Test",
+		"root_directory": "__DIR__",
+		"artifacts": [{"name": "api", "deploy_location": "releases/{env}/api/current", "releases_directory": "releases/{env}/api/releases"}],
+		"assets": [{"name": "fonts", "filename": "fonts.tar.gz", "deploy_location": "{env}/fonts"}]
+	}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := ReadArtifactsJson(path)
+	if err != nil {
+		t.Fatalf("ReadArtifactsJson failed: %v", err)
+	}
+	if got := config.Artifacts[0].DeployLocation; got != "releases/staging/api/current" {
+		t.Errorf("Expected the artifact's deploy location to be expanded, got %q", got)
+	}
+	if got := config.Artifacts[0].ReleasesDirectory; got != "releases/staging/api/releases" {
+		t.Errorf("Expected the releases directory to be expanded, got %q", got)
+	}
+	if got := config.Assets[0].DeployLocation; got != "staging/fonts" {
+		t.Errorf("Expected the asset's deploy location to be expanded, got %q", got)
+	}
+
+	config, err = ReadArtifactsJsonWithOptions(path, ReadOptions{Variables: map[string]string{"env": "production"}})
+	if err != nil {
+		t.Fatalf("ReadArtifactsJsonWithOptions failed: %v", err)
+	}
+	if got := config.Artifacts[0].DeployLocation; got != "releases/production/api/current" {
+		t.Errorf("Expected the variables to win over the environment, got %q", got)
+	}
+}
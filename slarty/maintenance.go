@@ -0,0 +1,97 @@
+package slarty
+
+import "fmt"
+
+// MaintenanceConfig holds the commands that put the application into maintenance
+// mode for the duration of a deploy and take it out again
+type MaintenanceConfig struct {
+	EnableCmd  string `json:"enable_cmd"`
+	DisableCmd string `json:"disable_cmd"`
+}
+
+// Enabled reports whether either maintenance command has been configured
+func (m MaintenanceConfig) Enabled() bool {
+	return m.EnableCmd != "" || m.DisableCmd != ""
+}
+
+// Maintenance runs the maintenance commands around a deploy. The zero value, and one
+// for a configuration without maintenance commands, does nothing.
+type Maintenance struct {
+	Config MaintenanceConfig
+	// Dir is the directory the commands run from
+	Dir    string
+	Runner CommandRunner
+	// Observer is told when maintenance mode is entered and left, and ignores it when
+	// nil
+	Observer Observer
+	active   bool
+}
+
+// NewMaintenance returns the maintenance mode for a deploy under config, running its
+// commands with runner and printing to stdout and stderr
+func NewMaintenance(config *ArtifactsConfig, runner CommandRunner) *Maintenance {
+	return &Maintenance{Config: config.Maintenance, Dir: config.RootDirectory, Runner: runner, Observer: &PrintObserver{}}
+}
+
+// observer returns the Observer to report to
+func (m *Maintenance) observer() Observer {
+	if m.Observer == nil {
+		return NopObserver{}
+	}
+	return m.Observer
+}
+
+// Enable puts the application into maintenance mode
+func (m *Maintenance) Enable() error {
+	if !m.Config.Enabled() {
+		return nil
+	}
+
+	m.observer().OnMessage("Entering maintenance mode")
+	m.active = true
+	if err := m.run(m.Config.EnableCmd); err != nil {
+		return fmt.Errorf("failed to enter maintenance mode: %w", err)
+	}
+	return nil
+}
+
+// Disable takes the application out of maintenance mode
+func (m *Maintenance) Disable() error {
+	if !m.active {
+		return nil
+	}
+
+	m.observer().OnMessage("Leaving maintenance mode")
+	if err := m.run(m.Config.DisableCmd); err != nil {
+		return fmt.Errorf("failed to leave maintenance mode: %w", err)
+	}
+	m.active = false
+	return nil
+}
+
+// Active reports whether the application is in maintenance mode, or may be after a
+// command that failed
+func (m *Maintenance) Active() bool {
+	return m.active
+}
+
+// WarnIfActive warns that the application was left in maintenance mode by a deploy
+// that failed part way through, since it may be only partly deployed
+func (m *Maintenance) WarnIfActive() {
+	if m.active {
+		m.observer().OnWarning("the deploy failed, so the application was left in maintenance mode")
+	}
+}
+
+// run runs a maintenance command from Dir
+func (m *Maintenance) run(command string) error {
+	if command == "" {
+		return nil
+	}
+
+	runner := m.Runner
+	if runner == nil {
+		runner = ShellRunner{}
+	}
+	return runner.RunCommand(m.Dir, command, nil)
+}
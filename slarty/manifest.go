@@ -0,0 +1,105 @@
+package slarty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Manifest records the artifact names resolved for a build, so the same artifacts
+// can be deployed later without recomputing hashes from a git checkout
+type Manifest struct {
+	Application string          `json:"application,omitempty"`
+	Variant     string          `json:"variant,omitempty"`
+	Channel     string          `json:"channel,omitempty"`
+	Artifacts   []ManifestEntry `json:"artifacts"`
+}
+
+// ManifestEntry is the archive name, or image reference for docker artifacts, that an
+// artifact resolved to
+type ManifestEntry struct {
+	Name         string `json:"name"`
+	ArtifactName string `json:"artifact_name"`
+}
+
+// ArtifactName returns the artifact name recorded for the named artifact
+func (m *Manifest) ArtifactName(name string) (string, bool) {
+	for _, entry := range m.Artifacts {
+		if entry.Name == name {
+			return entry.ArtifactName, true
+		}
+	}
+	return "", false
+}
+
+// Validate checks that every entry has a name and a safe artifact name, and that no
+// artifact is listed twice
+func (m *Manifest) Validate() error {
+	if err := ValidateChannel(m.Channel); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range m.Artifacts {
+		if entry.Name == "" {
+			return errors.New("manifest entry has no name")
+		}
+		if seen[entry.Name] {
+			return fmt.Errorf("artifact %s is listed more than once", entry.Name)
+		}
+		seen[entry.Name] = true
+
+		if err := validateArtifactName(entry.ArtifactName); err != nil {
+			return fmt.Errorf("artifact %s: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateArtifactName checks an artifact name read from outside the configuration.
+// The name becomes a path in local repositories, so it must not be able to climb out
+// of the repository.
+func validateArtifactName(artifactName string) error {
+	if artifactName == "" {
+		return errors.New("artifact name is empty")
+	}
+	if strings.Contains(artifactName, `\`) || strings.HasPrefix(artifactName, "/") {
+		return fmt.Errorf("invalid artifact name %q", artifactName)
+	}
+	for _, segment := range strings.Split(artifactName, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid artifact name %q", artifactName)
+		}
+	}
+	return nil
+}
+
+// WriteManifest writes the manifest as indented JSON
+func WriteManifest(w io.Writer, m *Manifest) error {
+	out, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+// ReadManifest reads and validates a manifest written by WriteManifest
+func ReadManifest(path string) (*Manifest, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(file, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return &manifest, nil
+}
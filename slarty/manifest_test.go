@@ -0,0 +1,63 @@
+package slarty
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	manifest := &Manifest{
+		Application: "Test App",
+		Channel:     "release/1.4",
+		Artifacts: []ManifestEntry{
+			{Name: "web", ArtifactName: "web-abc123.tar.gz"},
+			{Name: "api", ArtifactName: "registry.example.com/api:api-def456"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteManifest(&buf, manifest); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	read, err := ReadManifest(path)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if read.Application != "Test App" || read.Channel != "release/1.4" {
+		t.Errorf("Expected application and channel to round trip, got %+v", read)
+	}
+	if name, ok := read.ArtifactName("api"); !ok || name != "registry.example.com/api:api-def456" {
+		t.Errorf("Expected the api image reference, got %q, %v", name, ok)
+	}
+	if _, ok := read.ArtifactName("missing"); ok {
+		t.Errorf("Expected no artifact name for an artifact that is not in the manifest")
+	}
+}
+
+func TestManifestValidate(t *testing.T) {
+	invalid := []Manifest{
+		{Artifacts: []ManifestEntry{{ArtifactName: "web-abc.tar.gz"}}},
+		{Artifacts: []ManifestEntry{{Name: "web"}}},
+		{Artifacts: []ManifestEntry{{Name: "web", ArtifactName: "a.tar.gz"}, {Name: "web", ArtifactName: "b.tar.gz"}}},
+		{Artifacts: []ManifestEntry{{Name: "web", ArtifactName: "../../etc/passwd"}}},
+		{Artifacts: []ManifestEntry{{Name: "web", ArtifactName: "/etc/passwd"}}},
+		{Artifacts: []ManifestEntry{{Name: "web", ArtifactName: `..\web.tar.gz`}}},
+		{Channel: "../other", Artifacts: []ManifestEntry{{Name: "web", ArtifactName: "web.tar.gz"}}},
+	}
+	for i, manifest := range invalid {
+		if err := manifest.Validate(); err == nil {
+			t.Errorf("Expected manifest %d to be rejected", i)
+		}
+	}
+
+	if _, err := ReadManifest(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("Expected an error for a missing manifest")
+	}
+}
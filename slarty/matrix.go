@@ -0,0 +1,79 @@
+package slarty
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// HostPlatform returns the os/arch platform of the machine Slarty is running on
+func HostPlatform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// expandMatrix replaces every artifact that defines a matrix with one artifact per
+// platform. Each expanded artifact is named and prefixed with the platform
+// ("api-linux-amd64"), builds with GOOS and GOARCH set, and has {os} and {arch}
+// replaced in its command, output_directory and deploy_location.
+func (ac *ArtifactsConfig) expandMatrix() error {
+	var expanded []ArtifactConfig
+
+	for _, artifact := range ac.Artifacts {
+		if len(artifact.Matrix) == 0 {
+			expanded = append(expanded, artifact)
+			continue
+		}
+
+		for _, platform := range artifact.Matrix {
+			goos, goarch, found := strings.Cut(strings.TrimSpace(platform), "/")
+			if !found || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+				return fmt.Errorf("artifact %s has invalid matrix platform %q, expected os/arch", artifact.Name, platform)
+			}
+
+			replacer := strings.NewReplacer("{os}", goos, "{arch}", goarch)
+			suffix := "-" + goos + "-" + goarch
+
+			entry := artifact
+			entry.Name = artifact.Name + suffix
+			entry.ArtifactPrefix = artifact.ArtifactPrefix + suffix
+			entry.Command = replacer.Replace(artifact.Command)
+			entry.OutputDirectory = artifact.OutputDirectory.Map(replacer.Replace)
+			entry.DeployLocation = replacer.Replace(artifact.DeployLocation)
+			entry.ReleasesDirectory = replacer.Replace(artifact.ReleasesDirectory)
+			entry.Platform = goos + "/" + goarch
+			entry.Matrix = nil
+
+			entry.Env = make(map[string]string, len(artifact.Env)+2)
+			for key, value := range artifact.Env {
+				entry.Env[key] = value
+			}
+			entry.Env["GOOS"] = goos
+			entry.Env["GOARCH"] = goarch
+
+			if len(artifact.Variants) > 0 {
+				entry.Variants = make(map[string]string, len(artifact.Variants))
+				for name, command := range artifact.Variants {
+					entry.Variants[name] = replacer.Replace(command)
+				}
+			}
+
+			expanded = append(expanded, entry)
+		}
+	}
+
+	ac.Artifacts = expanded
+	return nil
+}
+
+// SelectPlatform returns the artifacts that deploy to the given os/arch platform:
+// those expanded from a matrix for that platform, and every artifact without a matrix
+func SelectPlatform(artifacts []ArtifactConfig, platform string) []ArtifactConfig {
+	var selected []ArtifactConfig
+	for _, artifact := range artifacts {
+		if artifact.Platform == "" || strings.EqualFold(artifact.Platform, platform) {
+			selected = append(selected, artifact)
+		}
+	}
+
+	return selected
+}
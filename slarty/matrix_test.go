@@ -0,0 +1,85 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadArtifactsJsonMatrix(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "artifacts.json")
+
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+	}
+
+	write(`{
+		"root_directory": "__DIR__",
+		"artifacts": [
+			{
+				"name": "api",
+				"directories": ["cmd/api"],
+				"command": "go build -o dist/{os}-{arch}/api ./cmd/api",
+				"output_directory": "dist/{os}-{arch}",
+				"deploy_location": "bin",
+				"artifact_prefix": "api",
+				"env": {"CGO_ENABLED": "0"},
+				"matrix": ["linux/amd64", "linux/arm64"]
+			},
+			{"name": "web", "directories": ["web"], "command": "make web", "output_directory": "web/dist", "deploy_location": "public", "artifact_prefix": "web"}
+		]
+	}`)
+
+	config, err := ReadArtifactsJson(configPath)
+	if err != nil {
+		t.Fatalf("ReadArtifactsJson failed: %v", err)
+	}
+
+	if len(config.Artifacts) != 3 {
+		t.Fatalf("Expected 3 artifacts after expanding the matrix, got %d", len(config.Artifacts))
+	}
+
+	arm, err := config.GetArtifactConfig("api-linux-arm64")
+	if err != nil {
+		t.Fatalf("GetArtifactConfig failed: %v", err)
+	}
+	if arm.ArtifactPrefix != "api-linux-arm64" {
+		t.Errorf("Expected platform in artifact prefix, got %q", arm.ArtifactPrefix)
+	}
+	if arm.Command != "go build -o dist/linux-arm64/api ./cmd/api" || arm.OutputDirectory.String() != "dist/linux-arm64" {
+		t.Errorf("Expected {os} and {arch} to be replaced, got %q and %q", arm.Command, arm.OutputDirectory)
+	}
+	if arm.Platform != "linux/arm64" {
+		t.Errorf("Expected platform linux/arm64, got %q", arm.Platform)
+	}
+	if arm.Env["GOOS"] != "linux" || arm.Env["GOARCH"] != "arm64" || arm.Env["CGO_ENABLED"] != "0" {
+		t.Errorf("Expected GOOS, GOARCH and the artifact's env, got %v", arm.Env)
+	}
+
+	amd, _ := config.GetArtifactConfig("api-linux-amd64")
+	if amd == nil || amd.Env["GOARCH"] != "amd64" {
+		t.Fatalf("Expected expanded entries to have their own env, got %+v", amd)
+	}
+
+	t.Run("SelectPlatform", func(t *testing.T) {
+		selected := SelectPlatform(config.Artifacts, "linux/amd64")
+		var names []string
+		for _, a := range selected {
+			names = append(names, a.Name)
+		}
+		if len(names) != 2 || names[0] != "api-linux-amd64" || names[1] != "web" {
+			t.Fatalf("Expected [api-linux-amd64 web], got %v", names)
+		}
+	})
+
+	t.Run("InvalidPlatform", func(t *testing.T) {
+		write(`{"root_directory": "__DIR__", "artifacts": [{"name": "api", "matrix": ["linux"]}]}`)
+		if _, err := ReadArtifactsJson(configPath); err == nil {
+			t.Fatalf("ReadArtifactsJson did not fail for an invalid matrix platform")
+		}
+	})
+}
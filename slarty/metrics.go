@@ -0,0 +1,188 @@
+package slarty
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsTimeout bounds how long pushing metrics may take
+const metricsTimeout = 30 * time.Second
+
+// MetricsConfig configures where per-run metrics are pushed. Type is either
+// "pushgateway" (a Prometheus Pushgateway URL) or "statsd" (a host:port that
+// accepts StatsD over UDP, including the OpenTelemetry collector's statsd receiver).
+type MetricsConfig struct {
+	Type     string `json:"type"`
+	Endpoint string `json:"endpoint"`
+	Job      string `json:"job"`
+	Prefix   string `json:"prefix"`
+}
+
+// Enabled reports whether a metrics endpoint has been configured
+func (m MetricsConfig) Enabled() bool {
+	return strings.TrimSpace(m.Endpoint) != ""
+}
+
+// Metric is a single named measurement with optional labels
+type Metric struct {
+	Name   string
+	Value  float64
+	Labels map[string]string
+}
+
+// MetricsRecorder collects metrics during a run. It is safe for concurrent use.
+type MetricsRecorder struct {
+	mu      sync.Mutex
+	metrics []Metric
+}
+
+// NewMetricsRecorder creates an empty MetricsRecorder
+func NewMetricsRecorder() *MetricsRecorder {
+	return &MetricsRecorder{}
+}
+
+// Observe records a value for the named metric
+func (r *MetricsRecorder) Observe(name string, value float64, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, Metric{Name: name, Value: value, Labels: labels})
+}
+
+// ObserveDuration records a duration in seconds for the named metric
+func (r *MetricsRecorder) ObserveDuration(name string, d time.Duration, labels map[string]string) {
+	r.Observe(name, d.Seconds(), labels)
+}
+
+// Metrics returns a copy of everything recorded so far
+func (r *MetricsRecorder) Metrics() []Metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Metric(nil), r.metrics...)
+}
+
+// PushMetrics sends the metrics to the configured endpoint. Offline, it fails with
+// ErrOffline.
+func PushMetrics(cfg MetricsConfig, metrics []Metric, offline bool) error {
+	if !cfg.Enabled() || len(metrics) == 0 {
+		return nil
+	}
+	if err := offlineError(offline, "the metrics endpoint"); err != nil {
+		return err
+	}
+
+	prefix := cfg.Prefix
+	if prefix == "" {
+		prefix = "slarty"
+	}
+
+	switch strings.ToLower(cfg.Type) {
+	case "pushgateway", "prometheus", "":
+		return pushToPushgateway(cfg, prefix, metrics)
+	case "statsd":
+		return pushToStatsd(cfg, prefix, metrics)
+	default:
+		return fmt.Errorf("unknown metrics type: %s", cfg.Type)
+	}
+}
+
+// FormatPrometheus renders metrics in the Prometheus text exposition format
+func FormatPrometheus(prefix string, metrics []Metric) string {
+	var out strings.Builder
+	for _, m := range metrics {
+		out.WriteString(prefix + "_" + m.Name)
+		if len(m.Labels) > 0 {
+			keys := sortedLabelKeys(m.Labels)
+			pairs := make([]string, 0, len(keys))
+			for _, k := range keys {
+				pairs = append(pairs, k+"="+strconv.Quote(m.Labels[k]))
+			}
+			out.WriteString("{" + strings.Join(pairs, ",") + "}")
+		}
+		out.WriteString(" " + strconv.FormatFloat(m.Value, 'g', -1, 64) + "\n")
+	}
+	return out.String()
+}
+
+// FormatStatsd renders metrics as StatsD gauges, one per line, using the DogStatsD
+// tag extension for labels.
+func FormatStatsd(prefix string, metrics []Metric) []string {
+	lines := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		line := prefix + "." + m.Name + ":" + strconv.FormatFloat(m.Value, 'f', -1, 64) + "|g"
+		if len(m.Labels) > 0 {
+			keys := sortedLabelKeys(m.Labels)
+			tags := make([]string, 0, len(keys))
+			for _, k := range keys {
+				tags = append(tags, k+":"+m.Labels[k])
+			}
+			line += "|#" + strings.Join(tags, ",")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func pushToPushgateway(cfg MetricsConfig, prefix string, metrics []Metric) error {
+	job := cfg.Job
+	if job == "" {
+		job = "slarty"
+	}
+
+	endpoint := strings.TrimRight(cfg.Endpoint, "/") + "/metrics/job/" + url.PathEscape(job)
+	body := FormatPrometheus(prefix, metrics)
+
+	ctx, cancel := context.WithTimeout(context.Background(), metricsTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBufferString(body))
+	if err != nil {
+		return fmt.Errorf("failed to create metrics request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("pushgateway returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+func pushToStatsd(cfg MetricsConfig, prefix string, metrics []Metric) error {
+	conn, err := net.DialTimeout("udp", cfg.Endpoint, metricsTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to statsd: %w", err)
+	}
+	defer conn.Close()
+
+	for _, line := range FormatStatsd(prefix, metrics) {
+		if _, err := conn.Write([]byte(line)); err != nil {
+			return fmt.Errorf("failed to send metrics to statsd: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func sortedLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,114 @@
+package slarty
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsRecorder(t *testing.T) {
+	recorder := NewMetricsRecorder()
+	recorder.Observe("archive_size_bytes", 1024, map[string]string{"artifact": "api"})
+	recorder.ObserveDuration("build_duration_seconds", 1500*time.Millisecond, nil)
+
+	metrics := recorder.Metrics()
+	if len(metrics) != 2 {
+		t.Fatalf("Expected 2 metrics, got %d", len(metrics))
+	}
+	if metrics[1].Value != 1.5 {
+		t.Errorf("Expected duration of 1.5 seconds, got %v", metrics[1].Value)
+	}
+}
+
+func TestFormatPrometheus(t *testing.T) {
+	out := FormatPrometheus("slarty", []Metric{
+		{Name: "archive_size_bytes", Value: 1024, Labels: map[string]string{"artifact": "api", "app": "x"}},
+		{Name: "cache_hit_ratio", Value: 0.5},
+	})
+
+	expected := "slarty_archive_size_bytes{app=\"x\",artifact=\"api\"} 1024\nslarty_cache_hit_ratio 0.5\n"
+	if out != expected {
+		t.Errorf("Unexpected output:\n%s\nexpected:\n%s", out, expected)
+	}
+}
+
+func TestFormatStatsd(t *testing.T) {
+	lines := FormatStatsd("slarty", []Metric{
+		{Name: "build_duration_seconds", Value: 2.5, Labels: map[string]string{"artifact": "api"}},
+		{Name: "cache_hits", Value: 3},
+	})
+
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	if lines[0] != "slarty.build_duration_seconds:2.5|g|#artifact:api" {
+		t.Errorf("Unexpected first line %q", lines[0])
+	}
+	if lines[1] != "slarty.cache_hits:3|g" {
+		t.Errorf("Unexpected second line %q", lines[1])
+	}
+}
+
+func TestPushMetrics(t *testing.T) {
+	metrics := []Metric{{Name: "cache_hits", Value: 3}}
+
+	t.Run("Pushgateway", func(t *testing.T) {
+		var path, body string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			b, _ := io.ReadAll(r.Body)
+			body = string(b)
+		}))
+		defer server.Close()
+
+		err := PushMetrics(MetricsConfig{Type: "pushgateway", Endpoint: server.URL, Job: "ci"}, metrics, false)
+		if err != nil {
+			t.Fatalf("PushMetrics failed: %v", err)
+		}
+		if path != "/metrics/job/ci" {
+			t.Errorf("Expected push to /metrics/job/ci, got %s", path)
+		}
+		if !strings.Contains(body, "slarty_cache_hits 3") {
+			t.Errorf("Unexpected body %q", body)
+		}
+	})
+
+	t.Run("Statsd", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Skipf("Unable to listen on UDP: %v", err)
+		}
+		defer conn.Close()
+
+		err = PushMetrics(MetricsConfig{Type: "statsd", Endpoint: conn.LocalAddr().String(), Prefix: "ci"}, metrics, false)
+		if err != nil {
+			t.Fatalf("PushMetrics failed: %v", err)
+		}
+
+		buf := make([]byte, 512)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Failed to read statsd packet: %v", err)
+		}
+		if string(buf[:n]) != "ci.cache_hits:3|g" {
+			t.Errorf("Unexpected statsd packet %q", string(buf[:n]))
+		}
+	})
+
+	t.Run("UnknownType", func(t *testing.T) {
+		if err := PushMetrics(MetricsConfig{Type: "carrier-pigeon", Endpoint: "somewhere"}, metrics, false); err == nil {
+			t.Error("Expected error for unknown metrics type")
+		}
+	})
+
+	t.Run("DisabledIsNoop", func(t *testing.T) {
+		if err := PushMetrics(MetricsConfig{}, metrics, false); err != nil {
+			t.Errorf("Expected no error when metrics are disabled, got %v", err)
+		}
+	})
+}
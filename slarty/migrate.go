@@ -0,0 +1,265 @@
+package slarty
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// CurrentSchemaVersion is the schema_version of the config format this slarty reads and
+// writes. A config without schema_version is version 1.
+const CurrentSchemaVersion = 2
+
+// ErrNewerSchemaVersion is returned for a config written for a newer slarty
+var ErrNewerSchemaVersion = errors.New("config is for a newer slarty")
+
+// configMigrations upgrade a config from the version one above their index to the next
+// version, returning a description of each change they make
+var configMigrations = []func(config *orderedObject) []string{
+	migrateRepositoryOptionKeys,
+}
+
+// checkSchemaVersion returns an error if the config at path has a schema_version this
+// slarty does not understand
+func checkSchemaVersion(path string, version int) error {
+	if version > CurrentSchemaVersion {
+		return fmt.Errorf("%s has schema_version %d, but this slarty only understands up to %d: %w, so upgrade slarty", path, version, CurrentSchemaVersion, ErrNewerSchemaVersion)
+	}
+	if version < 0 {
+		return fmt.Errorf("%s has invalid schema_version %d", path, version)
+	}
+	return nil
+}
+
+// MigrateConfig upgrades the raw config data to CurrentSchemaVersion. It returns the
+// upgraded data, keeping the order of the keys and the file's indentation, along with a
+// description of each change made, which is empty if the config is already current.
+func MigrateConfig(data []byte) ([]byte, []string, error) {
+	value, err := decodeOrdered(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	config, ok := value.(*orderedObject)
+	if !ok {
+		return nil, nil, errors.New("configuration is not a JSON object")
+	}
+
+	version := 1
+	if raw, found := config.get("schema_version"); found {
+		number, ok := raw.(json.Number)
+		if !ok {
+			return nil, nil, errors.New("schema_version is not a number")
+		}
+		parsed, err := strconv.Atoi(number.String())
+		if err != nil || parsed < 1 {
+			return nil, nil, fmt.Errorf("schema_version %s is not a version, which starts at 1", number)
+		}
+		version = parsed
+	}
+	if err := checkSchemaVersion("the configuration", version); err != nil {
+		return nil, nil, err
+	}
+	if version == CurrentSchemaVersion {
+		return data, nil, nil
+	}
+
+	var changes []string
+	for v := version; v < CurrentSchemaVersion; v++ {
+		changes = append(changes, configMigrations[v-1](config)...)
+	}
+	config.setFirst("schema_version", json.Number(strconv.Itoa(CurrentSchemaVersion)), "$schema")
+	changes = append(changes, fmt.Sprintf("set schema_version to %d", CurrentSchemaVersion))
+
+	var out bytes.Buffer
+	writeOrdered(&out, config, "", detectIndentUnit(data))
+	if bytes.HasSuffix(bytes.TrimRight(data, " \t\r"), []byte("\n")) {
+		out.WriteString("\n")
+	}
+	return out.Bytes(), changes, nil
+}
+
+// migrateRepositoryOptionKeys renames the snake_case repository options of version 1
+// configs to the kebab-case keys of version 2
+func migrateRepositoryOptionKeys(config *orderedObject) []string {
+	repository, _ := config.getObject("repository")
+	if repository == nil {
+		return nil
+	}
+	options, _ := repository.getObject("options")
+	if options == nil {
+		return nil
+	}
+
+	var changes []string
+	for _, rename := range [][2]string{{"bucket_name", "bucket-name"}, {"path_prefix", "path-prefix"}} {
+		old, replacement := rename[0], rename[1]
+		if _, found := options.get(old); !found {
+			continue
+		}
+		if _, found := options.get(replacement); found {
+			options.remove(old)
+			changes = append(changes, fmt.Sprintf("removed repository.options.%s, which %s replaces", old, replacement))
+			continue
+		}
+		options.rename(old, replacement)
+		changes = append(changes, fmt.Sprintf("renamed repository.options.%s to %s", old, replacement))
+	}
+	return changes
+}
+
+// orderedObject is a JSON object that keeps the order of its keys
+type orderedObject struct {
+	members []orderedMember
+}
+
+type orderedMember struct {
+	key   string
+	value interface{}
+}
+
+func (o *orderedObject) index(key string) int {
+	for i, member := range o.members {
+		if member.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (o *orderedObject) get(key string) (interface{}, bool) {
+	if i := o.index(key); i >= 0 {
+		return o.members[i].value, true
+	}
+	return nil, false
+}
+
+func (o *orderedObject) getObject(key string) (*orderedObject, bool) {
+	value, _ := o.get(key)
+	object, ok := value.(*orderedObject)
+	return object, ok
+}
+
+func (o *orderedObject) remove(key string) {
+	if i := o.index(key); i >= 0 {
+		o.members = append(o.members[:i], o.members[i+1:]...)
+	}
+}
+
+func (o *orderedObject) rename(key, replacement string) {
+	if i := o.index(key); i >= 0 {
+		o.members[i].key = replacement
+	}
+}
+
+// setFirst sets key to value. A new key goes at the start of the object, or second when
+// the first key is skip, such as $schema, which editors expect to come first.
+func (o *orderedObject) setFirst(key string, value interface{}, skip string) {
+	if i := o.index(key); i >= 0 {
+		o.members[i].value = value
+		return
+	}
+	position := 0
+	if len(o.members) > 0 && o.members[0].key == skip {
+		position = 1
+	}
+	o.members = append(o.members[:position], append([]orderedMember{{key, value}}, o.members[position:]...)...)
+}
+
+// decodeOrdered decodes JSON into objects that keep the order of their keys, arrays,
+// and json.Number, string, bool and nil values
+func decodeOrdered(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	value, err := decodeOrderedValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the top-level value")
+	}
+	return value, nil
+}
+
+func decodeOrderedValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := &orderedObject{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object.members = append(object.members, orderedMember{key.(string), value})
+		}
+		_, err := decoder.Token()
+		return object, err
+	case json.Delim('['):
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrderedValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err := decoder.Token()
+		return array, err
+	}
+	return token, nil
+}
+
+// writeOrdered writes value decoded by decodeOrdered as indented JSON, the way
+// json.MarshalIndent does but without escaping HTML characters such as & in commands
+func writeOrdered(out *bytes.Buffer, value interface{}, indent, unit string) {
+	switch v := value.(type) {
+	case *orderedObject:
+		if len(v.members) == 0 {
+			out.WriteString("{}")
+			return
+		}
+		out.WriteString("{\n")
+		for i, member := range v.members {
+			out.WriteString(indent + unit)
+			writeOrdered(out, member.key, "", unit)
+			out.WriteString(": ")
+			writeOrdered(out, member.value, indent+unit, unit)
+			if i < len(v.members)-1 {
+				out.WriteString(",")
+			}
+			out.WriteString("\n")
+		}
+		out.WriteString(indent + "}")
+	case []interface{}:
+		if len(v) == 0 {
+			out.WriteString("[]")
+			return
+		}
+		out.WriteString("[\n")
+		for i, item := range v {
+			out.WriteString(indent + unit)
+			writeOrdered(out, item, indent+unit, unit)
+			if i < len(v)-1 {
+				out.WriteString(",")
+			}
+			out.WriteString("\n")
+		}
+		out.WriteString(indent + "]")
+	default:
+		var encoded bytes.Buffer
+		encoder := json.NewEncoder(&encoded)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(v)
+		out.Write(bytes.TrimRight(encoded.Bytes(), "\n"))
+	}
+}
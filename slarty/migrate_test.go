@@ -0,0 +1,109 @@
+package slarty
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	config := `{
+    "$schema": "./artifacts.schema.json",
+    "application": "Test",
+    "repository": {
+        "adapter": "s3",
+        "options": {
+            "region": "us-east-1",
+            "bucket_name": "builds",
+            "path-prefix": "app",
+            "path_prefix": "old"
+        }
+    },
+    "artifacts": [
+        {
+            "name": "api",
+            "directories": ["api"],
+            "command": "make api && make test",
+            "env": {}
+        }
+    ],
+    "assets": []
+}
+`
+	expected := `{
+    "$schema": "./artifacts.schema.json",
+    "schema_version": 2,
+    "application": "Test",
+    "repository": {
+        "adapter": "s3",
+        "options": {
+            "region": "us-east-1",
+            "bucket-name": "builds",
+            "path-prefix": "app"
+        }
+    },
+    "artifacts": [
+        {
+            "name": "api",
+            "directories": [
+                "api"
+            ],
+            "command": "make api && make test",
+            "env": {}
+        }
+    ],
+    "assets": []
+}
+`
+	migrated, changes, err := MigrateConfig([]byte(config))
+	if err != nil {
+		t.Fatalf("MigrateConfig failed: %v", err)
+	}
+	if string(migrated) != expected {
+		t.Errorf("Expected the migrated config to be\n%s\ngot\n%s", expected, migrated)
+	}
+	expectedChanges := []string{
+		"renamed repository.options.bucket_name to bucket-name",
+		"removed repository.options.path_prefix, which path-prefix replaces",
+		"set schema_version to 2",
+	}
+	if !reflect.DeepEqual(changes, expectedChanges) {
+		t.Errorf("Expected changes %v, got %v", expectedChanges, changes)
+	}
+
+	// A current config is left alone
+	again, changes, err := MigrateConfig(migrated)
+	if err != nil || len(changes) != 0 || string(again) != string(migrated) {
+		t.Errorf("Expected a current config to be unchanged, got %v (%v):\n%s", changes, err, again)
+	}
+
+	if _, _, err := MigrateConfig([]byte(`{"schema_version": 99}`)); !errors.Is(err, ErrNewerSchemaVersion) {
+		t.Errorf("Expected ErrNewerSchemaVersion for a newer config, got %v", err)
+	}
+	if _, _, err := MigrateConfig([]byte(`{"schema_version": 0}`)); err == nil {
+		t.Errorf("Expected schema_version 0 to be an error")
+	}
+	if _, _, err := MigrateConfig([]byte(`[]`)); err == nil {
+		t.Errorf("Expected a config that is not an object to be an error")
+	}
+}
+
+func TestReadArtifactsJsonSchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "artifacts.json")
+
+	if err := os.WriteFile(path, []byte(`{"schema_version": 2, "application": "Test"}`), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := ReadArtifactsJson(path); err != nil {
+		t.Errorf("Expected the current schema_version to be read, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"schema_version": 3, "application": "Test"}`), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := ReadArtifactsJson(path); !errors.Is(err, ErrNewerSchemaVersion) {
+		t.Errorf("Expected ErrNewerSchemaVersion for a newer config, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package slarty
+
+import (
+	"errors"
+	"strings"
+)
+
+// MirrorRepositories opens the remote repository in config and the local copy of it at
+// the repository root, laid out the same way, for copying artifacts between them.
+// Artifacts are copied as they are stored, so encrypted artifacts stay encrypted in
+// the copy and are read from it with the same key. Only transfers with the remote
+// repository are held to its limit-rate.
+func MirrorRepositories(config *ArtifactsConfig) (remote, local RepositoryAdapter, err error) {
+	if err := offlineError(config.Offline, "the remote repository"); err != nil {
+		return nil, nil, err
+	}
+	if strings.EqualFold(config.Repository.Adapter, "local") {
+		return nil, nil, errors.New("the repository is local, so there is no remote repository to mirror")
+	}
+
+	stored := *config
+	stored.Repository.Encryption = nil
+	if remote, err = NewRepositoryAdapter(&stored, false); err != nil {
+		return nil, nil, err
+	}
+
+	stored.Repository.LimitRate = ""
+	stored.Repository.Immutable = false
+	stored.Repository.ReadOnly = false
+	if local, err = NewRepositoryAdapter(&stored, true); err != nil {
+		return nil, nil, err
+	}
+	return remote, local, nil
+}
@@ -0,0 +1,60 @@
+package slarty
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMirrorRepositories(t *testing.T) {
+	dir := t.TempDir()
+	config := &ArtifactsConfig{RootDirectory: dir}
+	config.Repository.Adapter = "local"
+	config.Repository.Options.Root = filepath.Join(dir, "copy")
+	if _, _, err := MirrorRepositories(config); err == nil {
+		t.Errorf("Expected a local repository to have nothing to mirror")
+	}
+
+	config.Repository.Adapter = "s3"
+	config.Repository.Options.Region = "us-east-1"
+	config.Repository.Options.BucketName = "builds"
+	config.Repository.Channel = "main"
+	config.Repository.LimitRate = "1M"
+	config.Repository.Immutable = true
+	config.Repository.Encryption = &RepositoryEncryption{KeyFile: writeKeyFile(t, dir)}
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CA_BUNDLE", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "aws-config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "aws-credentials"))
+
+	remote, local, err := MirrorRepositories(config)
+	if err != nil {
+		t.Fatalf("MirrorRepositories failed: %v", err)
+	}
+	// The remote repository keeps its rate, and neither decrypts artifacts
+	immutable, ok := remote.(*ImmutableRepositoryAdapter)
+	if !ok {
+		t.Fatalf("Expected the remote repository to stay immutable, got %T", remote)
+	}
+	if limited, ok := immutable.RepositoryAdapter.(*rateLimitedRepositoryAdapter); !ok {
+		t.Errorf("Expected the remote repository to be rate limited, got %T", immutable.RepositoryAdapter)
+	} else if _, ok := limited.RepositoryAdapter.(*S3RepositoryAdapter); !ok {
+		t.Errorf("Expected the remote repository to be S3, got %T", limited.RepositoryAdapter)
+	}
+
+	// The copy is laid out like the bucket, below the channel
+	if err := local.StoreArtifact(bytes.NewReader([]byte("stored")), "a.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+	if stored, err := os.ReadFile(filepath.Join(dir, "copy", "main", "a.tar.gz")); err != nil || string(stored) != "stored" {
+		t.Errorf("Expected the artifact as it is below the channel, got %q (%v)", stored, err)
+	}
+
+	config.Offline = true
+	if _, _, err := MirrorRepositories(config); !errors.Is(err, ErrOffline) {
+		t.Errorf("Expected mirroring to be unavailable offline, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package slarty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// notificationTimeout bounds how long posting a notification may take so a slow
+// or unreachable webhook cannot hold up a build or deploy.
+const notificationTimeout = 30 * time.Second
+
+// defaultNotificationTemplate is used when the configuration does not provide one
+const defaultNotificationTemplate = `slarty {{.Command}} for {{.Application}}: {{.Succeeded}} succeeded, {{.Failed}} failed, {{.Skipped}} skipped ({{.Duration}})
+{{range .Results}}- {{.Name}} {{.Status}}{{if .ArtifactName}} {{.ArtifactName}}{{end}}{{if .Duration}} ({{.Duration}}){{end}}{{if .Error}}: {{.Error}}{{end}}
+{{end}}`
+
+// Notifications configures where build and deploy summaries are posted
+type Notifications struct {
+	WebhookURL   string `json:"webhook_url"`
+	SlackChannel string `json:"slack_channel"`
+	Template     string `json:"template"`
+}
+
+// Enabled reports whether a notification target has been configured
+func (n Notifications) Enabled() bool {
+	return strings.TrimSpace(n.WebhookURL) != ""
+}
+
+// RunResult is the outcome for a single artifact or asset within a run
+type RunResult struct {
+	Name         string        `json:"name"`
+	ArtifactName string        `json:"artifact_name,omitempty"`
+	Status       string        `json:"status"`
+	Duration     time.Duration `json:"duration"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// RunSummary describes the outcome of a command run such as do-builds or do-deploys
+type RunSummary struct {
+	Command     string        `json:"command"`
+	Application string        `json:"application"`
+	Duration    time.Duration `json:"duration"`
+	Results     []RunResult   `json:"results"`
+}
+
+// Succeeded returns the number of results that were built or deployed
+func (s RunSummary) Succeeded() int {
+	return s.count("built", "deployed")
+}
+
+// Failed returns the number of results that failed
+func (s RunSummary) Failed() int {
+	return s.count("failed")
+}
+
+// Skipped returns the number of results that did not need any work
+func (s RunSummary) Skipped() int {
+	return s.count("skipped")
+}
+
+func (s RunSummary) count(statuses ...string) int {
+	var n int
+	for _, result := range s.Results {
+		for _, status := range statuses {
+			if result.Status == status {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// RenderNotification renders the summary text using the configured template, or the
+// default template if none is configured.
+func RenderNotification(n Notifications, summary RunSummary) (string, error) {
+	text := n.Template
+	if text == "" {
+		text = defaultNotificationTemplate
+	}
+
+	tmpl, err := template.New("notification").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid notification template: %w", err)
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, summary); err != nil {
+		return "", fmt.Errorf("failed to render notification: %w", err)
+	}
+
+	return strings.TrimRight(out.String(), "\n"), nil
+}
+
+// Notify posts the run summary to the configured webhook. The payload is compatible
+// with Slack incoming webhooks (text and channel) and also carries the structured
+// summary for generic webhook consumers. Offline, it fails with ErrOffline.
+func Notify(n Notifications, summary RunSummary, offline bool) error {
+	if !n.Enabled() {
+		return nil
+	}
+	if err := offlineError(offline, "the notification webhook"); err != nil {
+		return err
+	}
+
+	text, err := RenderNotification(n, summary)
+	if err != nil {
+		return err
+	}
+
+	payload := struct {
+		Text    string     `json:"text"`
+		Channel string     `json:"channel,omitempty"`
+		Summary RunSummary `json:"summary"`
+	}{text, n.SlackChannel, summary}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package slarty
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunSummaryCounts(t *testing.T) {
+	summary := RunSummary{Results: []RunResult{
+		{Name: "a", Status: "built"},
+		{Name: "b", Status: "deployed"},
+		{Name: "c", Status: "failed"},
+		{Name: "d", Status: "skipped"},
+		{Name: "e", Status: "skipped"},
+	}}
+
+	if summary.Succeeded() != 2 {
+		t.Errorf("Expected 2 succeeded, got %d", summary.Succeeded())
+	}
+	if summary.Failed() != 1 {
+		t.Errorf("Expected 1 failed, got %d", summary.Failed())
+	}
+	if summary.Skipped() != 2 {
+		t.Errorf("Expected 2 skipped, got %d", summary.Skipped())
+	}
+}
+
+func TestRenderNotification(t *testing.T) {
+	summary := RunSummary{
+		Command:     "do-builds",
+		Application: "Test App",
+		Duration:    2 * time.Second,
+		Results: []RunResult{
+			{Name: "api", ArtifactName: "api-abc.tar.gz", Status: "built", Duration: time.Second},
+			{Name: "web", Status: "failed", Error: "build command failed"},
+		},
+	}
+
+	t.Run("DefaultTemplate", func(t *testing.T) {
+		text, err := RenderNotification(Notifications{}, summary)
+		if err != nil {
+			t.Fatalf("RenderNotification failed: %v", err)
+		}
+		if !strings.Contains(text, "do-builds for Test App: 1 succeeded, 1 failed, 0 skipped") {
+			t.Errorf("Unexpected header in:\n%s", text)
+		}
+		if !strings.Contains(text, "- api built api-abc.tar.gz (1s)") {
+			t.Errorf("Expected api line in:\n%s", text)
+		}
+		if !strings.Contains(text, "- web failed: build command failed") {
+			t.Errorf("Expected web failure line in:\n%s", text)
+		}
+	})
+
+	t.Run("CustomTemplate", func(t *testing.T) {
+		text, err := RenderNotification(Notifications{Template: "{{.Application}} had {{.Failed}} failure(s)"}, summary)
+		if err != nil {
+			t.Fatalf("RenderNotification failed: %v", err)
+		}
+		if text != "Test App had 1 failure(s)" {
+			t.Errorf("Unexpected rendered text %q", text)
+		}
+	})
+
+	t.Run("InvalidTemplate", func(t *testing.T) {
+		if _, err := RenderNotification(Notifications{Template: "{{.Nope"}, summary); err == nil {
+			t.Error("Expected error for invalid template")
+		}
+	})
+}
+
+func TestNotify(t *testing.T) {
+	summary := RunSummary{Command: "do-deploys", Application: "Test App", Results: []RunResult{{Name: "api", Status: "deployed"}}}
+
+	t.Run("PostsPayload", func(t *testing.T) {
+		var received map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("Expected JSON content type, got %q", r.Header.Get("Content-Type"))
+			}
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &received); err != nil {
+				t.Errorf("Payload is not valid JSON: %v", err)
+			}
+		}))
+		defer server.Close()
+
+		err := Notify(Notifications{WebhookURL: server.URL, SlackChannel: "#deploys"}, summary, false)
+		if err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+		if received["channel"] != "#deploys" {
+			t.Errorf("Expected channel #deploys, got %v", received["channel"])
+		}
+		if !strings.Contains(received["text"].(string), "do-deploys for Test App") {
+			t.Errorf("Unexpected text %v", received["text"])
+		}
+		if _, ok := received["summary"].(map[string]interface{}); !ok {
+			t.Errorf("Expected structured summary in payload, got %v", received["summary"])
+		}
+	})
+
+	t.Run("ErrorStatus", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		if err := Notify(Notifications{WebhookURL: server.URL}, summary, false); err == nil {
+			t.Error("Expected error for non-2xx response")
+		}
+	})
+
+	t.Run("DisabledIsNoop", func(t *testing.T) {
+		if err := Notify(Notifications{}, summary, false); err != nil {
+			t.Errorf("Expected no error when notifications are disabled, got %v", err)
+		}
+	})
+}
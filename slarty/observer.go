@@ -0,0 +1,314 @@
+package slarty
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Observer is told how the builds and deploys run by a Builder or Deployer progress,
+// so a program embedding slarty can show them its own way. PrintObserver prints them
+// as the slarty command does. Embed NopObserver to only handle some of them.
+type Observer interface {
+	// OnBuildsStart is called when BuildAll starts on the artifacts
+	OnBuildsStart(artifacts []ArtifactConfig)
+	// OnBuildChecked is called once BuildAll has found out whether an artifact needs
+	// building, because it is not in the repository yet or builds are forced
+	OnBuildChecked(artifact ArtifactConfig, artifactName string, needed bool)
+	// OnArtifactStart is called before BuildAll builds and stores an artifact
+	OnArtifactStart(artifact ArtifactConfig, artifactName string)
+	// OnArtifactComplete is called once BuildAll is done with an artifact, whether it
+	// was built, skipped or failed, with how many of the needed builds have succeeded
+	OnArtifactComplete(result BuildResult, built, needed int)
+	// OnBuildsComplete is called with the results once BuildAll is done
+	OnBuildsComplete(results []BuildResult, needed int)
+	// OnBuildStart is called before an artifact's build command runs
+	OnBuildStart(artifact ArtifactConfig)
+	// OnBuildComplete is called once the build command has finished, with how long it
+	// ran and its error if it failed
+	OnBuildComplete(artifact ArtifactConfig, duration time.Duration, err error)
+	// OnUploadStart is called before an archive is sent to the repository
+	OnUploadStart(artifactName string)
+	// OnUploadProgress is called as an archive is sent, with the bytes sent so far
+	OnUploadProgress(artifactName string, sent int64)
+	// OnUploadComplete is called once an archive is stored, or has failed to be
+	OnUploadComplete(artifactName string, size int64, elapsed time.Duration, err error)
+	// OnDeploysStart is called when DeployAll starts on the artifacts
+	OnDeploysStart(artifacts []ArtifactConfig)
+	// OnDeployStart is called before an artifact is deployed
+	OnDeployStart(artifact ArtifactConfig, artifactName string)
+	// OnDownloadComplete is called after an archive is downloaded and extracted, with
+	// its size and how long that took, or when it could not be downloaded
+	OnDownloadComplete(artifact ArtifactConfig, artifactName string, size int64, elapsed time.Duration, err error)
+	// OnDeployComplete is called once an artifact is deployed, or has failed to be
+	OnDeployComplete(artifact ArtifactConfig, artifactName string, err error)
+	// OnArtifactDeployed is called once DeployAll is done with an artifact, whether it
+	// was deployed, skipped or failed, and with a step of the deploy that failed
+	OnArtifactDeployed(result DeployResult)
+	// OnRestartComplete is called once a service has been restarted, or has failed to
+	// be
+	OnRestartComplete(result RestartResult)
+	// OnDeploysComplete is called with what was done once DeployAll is done, and the
+	// error it returns if the deploy failed
+	OnDeploysComplete(report DeployReport, err error)
+	// OnMessage is called with the other steps taken, such as a release being linked
+	OnMessage(message string)
+	// OnWarning is called with problems that don't stop the build or deploy
+	OnWarning(message string)
+}
+
+// NopObserver ignores everything
+type NopObserver struct{}
+
+func (NopObserver) OnBuildsStart([]ArtifactConfig)                                         {}
+func (NopObserver) OnBuildChecked(ArtifactConfig, string, bool)                            {}
+func (NopObserver) OnArtifactStart(ArtifactConfig, string)                                 {}
+func (NopObserver) OnArtifactComplete(BuildResult, int, int)                               {}
+func (NopObserver) OnBuildsComplete([]BuildResult, int)                                    {}
+func (NopObserver) OnBuildStart(ArtifactConfig)                                            {}
+func (NopObserver) OnBuildComplete(ArtifactConfig, time.Duration, error)                   {}
+func (NopObserver) OnUploadStart(string)                                                   {}
+func (NopObserver) OnUploadProgress(string, int64)                                         {}
+func (NopObserver) OnUploadComplete(string, int64, time.Duration, error)                   {}
+func (NopObserver) OnDeploysStart([]ArtifactConfig)                                        {}
+func (NopObserver) OnDeployStart(ArtifactConfig, string)                                   {}
+func (NopObserver) OnDownloadComplete(ArtifactConfig, string, int64, time.Duration, error) {}
+func (NopObserver) OnDeployComplete(ArtifactConfig, string, error)                         {}
+func (NopObserver) OnArtifactDeployed(DeployResult)                                        {}
+func (NopObserver) OnRestartComplete(RestartResult)                                        {}
+func (NopObserver) OnDeploysComplete(DeployReport, error)                                  {}
+func (NopObserver) OnMessage(string)                                                       {}
+func (NopObserver) OnWarning(string)                                                       {}
+
+// PrintObserver prints progress to Out and warnings to Err, or to stdout and stderr
+// when they are nil
+type PrintObserver struct {
+	NopObserver
+	Out io.Writer
+	Err io.Writer
+}
+
+func (p *PrintObserver) out() io.Writer {
+	if p.Out == nil {
+		return os.Stdout
+	}
+	return p.Out
+}
+
+func (p *PrintObserver) err() io.Writer {
+	if p.Err == nil {
+		return os.Stderr
+	}
+	return p.Err
+}
+
+func (p *PrintObserver) OnBuildChecked(artifact ArtifactConfig, artifactName string, needed bool) {
+	status := "NO"
+	if needed {
+		status = "YES"
+	}
+	fmt.Fprintf(p.out(), "Doing build for %s - %s\n", artifact.Name, status)
+}
+
+func (p *PrintObserver) OnArtifactStart(artifact ArtifactConfig, artifactName string) {
+	fmt.Fprintf(p.out(), "\nBeginning build for %s application\n", artifact.Name)
+	fmt.Fprintln(p.out(), strings.Repeat("-", 40+len(artifact.Name)))
+}
+
+func (p *PrintObserver) OnArtifactComplete(result BuildResult, built, needed int) {
+	if result.Err != nil {
+		fmt.Fprintf(p.out(), "Build failed for %s: %v\n", result.Artifact.Name, result.Err)
+		return
+	}
+	if result.Status != BuildBuilt {
+		return
+	}
+
+	// A progress bar of the builds needed
+	const progressWidth = 28
+	completedWidth := built * progressWidth / needed
+	bar := strings.Repeat("=", completedWidth)
+	if completedWidth < progressWidth {
+		bar += ">" + strings.Repeat("-", progressWidth-completedWidth-1)
+	}
+	fmt.Fprintf(p.out(), " %d/%d [%s] %3d%%\n", built, needed, bar, built*100/needed)
+	fmt.Fprintf(p.out(), "-- Saved %s to repository.\n", result.ArtifactName)
+}
+
+func (p *PrintObserver) OnBuildsComplete(results []BuildResult, needed int) {
+	var failed []string
+	built := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result.Artifact.Name)
+		} else if result.Status == BuildBuilt {
+			built++
+		}
+	}
+
+	if len(failed) == 0 {
+		fmt.Fprintf(p.out(), "\nBuilds succeeded for %d artifacts\n", built)
+		return
+	}
+	fmt.Fprintf(p.out(), "\nBuilds failed for %d/%d artifacts:\n", len(failed), needed)
+	for _, name := range failed {
+		fmt.Fprintf(p.out(), " - %s\n", name)
+	}
+}
+
+func (p *PrintObserver) OnBuildComplete(artifact ArtifactConfig, duration time.Duration, err error) {
+	if err == nil {
+		fmt.Fprintf(p.out(), "\n Build succeeded for %s\n", artifact.Name)
+	}
+}
+
+func (p *PrintObserver) OnDownloadComplete(artifact ArtifactConfig, artifactName string, size int64, elapsed time.Duration, err error) {
+	switch {
+	case err != nil:
+	case artifact.IsDocker():
+		fmt.Fprintln(p.out(), " - Pulled image")
+	default:
+		fmt.Fprintln(p.out(), " - Downloaded and extracted artifact")
+	}
+}
+
+func (p *PrintObserver) OnRestartComplete(result RestartResult) {
+	if result.Err != nil {
+		fmt.Fprintf(p.err(), "RESTART FAILED: %s: %v\n", result.Name, result.Err)
+		return
+	}
+	fmt.Fprintf(p.out(), " - Restarted %s\n", result.Name)
+}
+
+func (p *PrintObserver) OnDeploysComplete(report DeployReport, err error) {
+	if failed := report.RestartsFailed(); err == nil && failed > 0 {
+		fmt.Fprintf(p.err(), "All artifacts were deployed, but %d restart(s) failed\n", failed)
+	}
+}
+
+func (p *PrintObserver) OnMessage(message string) {
+	fmt.Fprintln(p.out(), message)
+}
+
+func (p *PrintObserver) OnWarning(message string) {
+	fmt.Fprintf(p.err(), "WARNING: %s\n", message)
+}
+
+// Observers tells each of several Observers about everything, in order
+type Observers []Observer
+
+func (o Observers) OnBuildsStart(artifacts []ArtifactConfig) {
+	for _, observer := range o {
+		observer.OnBuildsStart(artifacts)
+	}
+}
+
+func (o Observers) OnBuildChecked(artifact ArtifactConfig, artifactName string, needed bool) {
+	for _, observer := range o {
+		observer.OnBuildChecked(artifact, artifactName, needed)
+	}
+}
+
+func (o Observers) OnArtifactStart(artifact ArtifactConfig, artifactName string) {
+	for _, observer := range o {
+		observer.OnArtifactStart(artifact, artifactName)
+	}
+}
+
+func (o Observers) OnArtifactComplete(result BuildResult, built, needed int) {
+	for _, observer := range o {
+		observer.OnArtifactComplete(result, built, needed)
+	}
+}
+
+func (o Observers) OnBuildsComplete(results []BuildResult, needed int) {
+	for _, observer := range o {
+		observer.OnBuildsComplete(results, needed)
+	}
+}
+
+func (o Observers) OnBuildStart(artifact ArtifactConfig) {
+	for _, observer := range o {
+		observer.OnBuildStart(artifact)
+	}
+}
+
+func (o Observers) OnBuildComplete(artifact ArtifactConfig, duration time.Duration, err error) {
+	for _, observer := range o {
+		observer.OnBuildComplete(artifact, duration, err)
+	}
+}
+
+func (o Observers) OnUploadStart(artifactName string) {
+	for _, observer := range o {
+		observer.OnUploadStart(artifactName)
+	}
+}
+
+func (o Observers) OnUploadProgress(artifactName string, sent int64) {
+	for _, observer := range o {
+		observer.OnUploadProgress(artifactName, sent)
+	}
+}
+
+func (o Observers) OnUploadComplete(artifactName string, size int64, elapsed time.Duration, err error) {
+	for _, observer := range o {
+		observer.OnUploadComplete(artifactName, size, elapsed, err)
+	}
+}
+
+func (o Observers) OnDeploysStart(artifacts []ArtifactConfig) {
+	for _, observer := range o {
+		observer.OnDeploysStart(artifacts)
+	}
+}
+
+func (o Observers) OnDeployStart(artifact ArtifactConfig, artifactName string) {
+	for _, observer := range o {
+		observer.OnDeployStart(artifact, artifactName)
+	}
+}
+
+func (o Observers) OnDownloadComplete(artifact ArtifactConfig, artifactName string, size int64, elapsed time.Duration, err error) {
+	for _, observer := range o {
+		observer.OnDownloadComplete(artifact, artifactName, size, elapsed, err)
+	}
+}
+
+func (o Observers) OnDeployComplete(artifact ArtifactConfig, artifactName string, err error) {
+	for _, observer := range o {
+		observer.OnDeployComplete(artifact, artifactName, err)
+	}
+}
+
+func (o Observers) OnArtifactDeployed(result DeployResult) {
+	for _, observer := range o {
+		observer.OnArtifactDeployed(result)
+	}
+}
+
+func (o Observers) OnRestartComplete(result RestartResult) {
+	for _, observer := range o {
+		observer.OnRestartComplete(result)
+	}
+}
+
+func (o Observers) OnDeploysComplete(report DeployReport, err error) {
+	for _, observer := range o {
+		observer.OnDeploysComplete(report, err)
+	}
+}
+
+func (o Observers) OnMessage(message string) {
+	for _, observer := range o {
+		observer.OnMessage(message)
+	}
+}
+
+func (o Observers) OnWarning(message string) {
+	for _, observer := range o {
+		observer.OnWarning(message)
+	}
+}
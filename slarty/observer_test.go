@@ -0,0 +1,103 @@
+package slarty
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordingObserver prints like PrintObserver and also records the builds, uploads
+// and deploys it is told about
+type recordingObserver struct {
+	PrintObserver
+	events []string
+	// sent is the most bytes an upload was reported to have sent
+	sent int64
+}
+
+func (r *recordingObserver) OnBuildStart(artifact ArtifactConfig) {
+	r.events = append(r.events, "build start "+artifact.Name)
+}
+
+func (r *recordingObserver) OnBuildComplete(artifact ArtifactConfig, duration time.Duration, err error) {
+	r.PrintObserver.OnBuildComplete(artifact, duration, err)
+	r.events = append(r.events, fmt.Sprintf("build complete %s %v", artifact.Name, err))
+}
+
+func (r *recordingObserver) OnUploadStart(artifactName string) {
+	r.events = append(r.events, "upload start "+artifactName)
+}
+
+func (r *recordingObserver) OnUploadProgress(artifactName string, sent int64) {
+	r.sent = sent
+}
+
+func (r *recordingObserver) OnUploadComplete(artifactName string, size int64, elapsed time.Duration, err error) {
+	r.events = append(r.events, fmt.Sprintf("upload complete %s %v", artifactName, err))
+}
+
+func (r *recordingObserver) OnDeployStart(artifact ArtifactConfig, artifactName string) {
+	r.events = append(r.events, "deploy start "+artifactName)
+}
+
+func (r *recordingObserver) OnDownloadComplete(artifact ArtifactConfig, artifactName string, size int64, elapsed time.Duration, err error) {
+	r.PrintObserver.OnDownloadComplete(artifact, artifactName, size, elapsed, err)
+	if err == nil && size > 0 {
+		r.events = append(r.events, "download "+artifactName)
+	}
+}
+
+func (r *recordingObserver) OnDeployComplete(artifact ArtifactConfig, artifactName string, err error) {
+	r.events = append(r.events, "deploy complete "+artifactName)
+}
+
+func TestBuilderObserver(t *testing.T) {
+	for _, cached := range []bool{false, true} {
+		root := t.TempDir()
+		var out bytes.Buffer
+		runner := &outputRunner{files: map[string]string{"dist/app.js": "console.log(1)"}}
+		builder := testBuilder(t, root, runner, &out)
+		observer := &recordingObserver{PrintObserver: PrintObserver{Out: &out, Err: &out}}
+		builder.Observer = observer
+		if cached {
+			// A cached build is stored through a temporary file
+			cache := NewLocalRepositoryAdapter(t.TempDir())
+			builder.OpenCache = func() (RepositoryAdapter, error) { return cache, nil }
+		}
+		artifact := ArtifactConfig{Name: "web", Command: "npm run build", OutputDirectory: OutputDirectories{"dist"}}
+
+		output, err := builder.Run(artifact)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		size, err := builder.Store(output, "web-abc.tar.gz", false)
+		if err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+
+		expected := []string{"build start web", "build complete web <nil>", "upload start web-abc.tar.gz", "upload complete web-abc.tar.gz <nil>"}
+		if !reflect.DeepEqual(observer.events, expected) {
+			t.Errorf("Expected %v, got %v", expected, observer.events)
+		}
+		if observer.sent != size {
+			t.Errorf("Expected progress up to %d bytes, got %d", size, observer.sent)
+		}
+		if out.String() != "\n Build succeeded for web\n" {
+			t.Errorf("Expected the build to be printed, got %q", out.String())
+		}
+	}
+
+	// Without an Observer nothing is reported
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "dist"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	builder := &Builder{Config: &ArtifactsConfig{RootDirectory: root}, Repo: NewLocalRepositoryAdapter(t.TempDir()), Runner: &outputRunner{}, Now: time.Now, AllowEmpty: true}
+	if _, err := builder.Run(ArtifactConfig{Name: "web", Command: "true", OutputDirectory: OutputDirectories{"dist"}}); err != nil {
+		t.Errorf("Expected a builder without an Observer to run, got %v", err)
+	}
+}
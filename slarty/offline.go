@@ -0,0 +1,19 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOffline is returned for anything that needs the network while slarty is offline,
+// as set by ArtifactsConfig.Offline
+var ErrOffline = errors.New("unavailable offline")
+
+// offlineError returns an error saying what is unavailable when offline, and nil
+// otherwise
+func offlineError(offline bool, what string) error {
+	if !offline {
+		return nil
+	}
+	return fmt.Errorf("%s is %w", what, ErrOffline)
+}
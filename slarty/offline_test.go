@@ -0,0 +1,75 @@
+package slarty
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOffline(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	// An S3 repository is read from the synced copy at its local root
+	dir := t.TempDir()
+	config := &ArtifactsConfig{RootDirectory: dir, Offline: true}
+	config.Repository.Adapter = "s3"
+	config.Repository.Options.Region = "us-east-1"
+	config.Repository.Options.BucketName = "builds"
+	config.Repository.Options.Root = filepath.Join(dir, "repo")
+	repo, err := NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+	if _, ok := repo.(*LocalRepositoryAdapter); !ok {
+		t.Errorf("Expected a local repository offline, got %T", repo)
+	}
+
+	config.Repository.Options.Root = ""
+	if _, err := NewRepositoryAdapter(config, false); err == nil || !strings.Contains(err.Error(), "offline") {
+		t.Errorf("Expected a missing root to be reported as needed offline, got %v", err)
+	}
+
+	config.Repository.Options.Root = filepath.Join(dir, "repo")
+	config.Repository.Encryption = &RepositoryEncryption{KMSKeyID: "alias/slarty"}
+	if _, err := NewRepositoryAdapter(config, false); !errors.Is(err, ErrOffline) {
+		t.Errorf("Expected KMS to be unavailable offline, got %v", err)
+	}
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+	for _, value := range []string{"secret_ref:aws-secretsmanager:prod/slarty", "secret_ref:vault:secret/slarty#root"} {
+		ref, _ := ParseSecretRef(value)
+		if _, err := ref.Resolve(context.Background(), "us-east-1", "", true); !errors.Is(err, ErrOffline) {
+			t.Errorf("Expected %s to be unavailable offline, got %v", value, err)
+		}
+	}
+
+	if err := Notify(Notifications{WebhookURL: server.URL}, RunSummary{Command: "do-deploys"}, true); !errors.Is(err, ErrOffline) {
+		t.Errorf("Expected notifications to be unavailable offline, got %v", err)
+	}
+	metrics := []Metric{{Name: "builds", Value: 1}}
+	if err := PushMetrics(MetricsConfig{Endpoint: server.URL}, metrics, true); !errors.Is(err, ErrOffline) {
+		t.Errorf("Expected metrics to be unavailable offline, got %v", err)
+	}
+	if requests.Load() != 0 {
+		t.Errorf("Expected no requests offline, got %d", requests.Load())
+	}
+
+	docker := NewDockerAdapter("false")
+	docker.Offline = true
+	if err := docker.PushImage("app:1", "registry.example.com/app:1"); !errors.Is(err, ErrOffline) {
+		t.Errorf("Expected pushing an image to be unavailable offline, got %v", err)
+	}
+	if _, err := docker.ArtifactExists("registry.example.com/app:1"); !errors.Is(err, ErrOffline) {
+		t.Errorf("Expected the registry to be unavailable offline, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package slarty
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// OutputDirectories are the directories, relative to the root directory, that are
+// archived once an artifact's command has run. In artifacts.json output_directory is
+// either one path or a list of them.
+type OutputDirectories []string
+
+func (o *OutputDirectories) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*o = OutputDirectories{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("output_directory must be a path or a list of paths")
+	}
+	*o = list
+	return nil
+}
+
+// MarshalJSON writes a single directory as a plain path
+func (o OutputDirectories) MarshalJSON() ([]byte, error) {
+	if len(o) == 1 {
+		return json.Marshal(o[0])
+	}
+	return json.Marshal([]string(o))
+}
+
+// String lists the directories separated by commas
+func (o OutputDirectories) String() string {
+	return strings.Join(o, ", ")
+}
+
+// Map returns the directories with f applied to each, leaving o unchanged
+func (o OutputDirectories) Map(f func(string) string) OutputDirectories {
+	if o == nil {
+		return nil
+	}
+	mapped := make(OutputDirectories, len(o))
+	for i, dir := range o {
+		mapped[i] = f(dir)
+	}
+	return mapped
+}
+
+// ArchivePrefixes returns the folder each directory's contents go under in the
+// artifact's archive. A single directory is archived at the top of the archive, and
+// each of several directories under its path relative to root, so that extracting
+// the archive into root puts every directory back in its place.
+func (o OutputDirectories) ArchivePrefixes() ([]string, error) {
+	if len(o) == 1 {
+		return []string{""}, nil
+	}
+	prefixes := make([]string, len(o))
+	for i, dir := range o {
+		cleaned := filepath.ToSlash(filepath.Clean(strings.TrimSpace(dir)))
+		if cleaned == "." || cleaned == ".." || filepath.IsAbs(dir) || strings.HasPrefix(cleaned, "../") {
+			return nil, fmt.Errorf("output directory %q must be inside the root directory when there are several", dir)
+		}
+		for _, other := range prefixes[:i] {
+			if cleaned == other || strings.HasPrefix(cleaned, other+"/") || strings.HasPrefix(other, cleaned+"/") {
+				return nil, fmt.Errorf("output directories %q and %q overlap", other, cleaned)
+			}
+		}
+		prefixes[i] = cleaned
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,54 @@
+package slarty
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOutputDirectoriesJSON(t *testing.T) {
+	var artifact ArtifactConfig
+	if err := json.Unmarshal([]byte(`{"output_directory": "dist"}`), &artifact); err != nil {
+		t.Fatalf("Failed to read a single output directory: %v", err)
+	}
+	if artifact.OutputDirectory.String() != "dist" {
+		t.Errorf("Expected dist, got %v", artifact.OutputDirectory)
+	}
+	if err := json.Unmarshal([]byte(`{"output_directory": ["dist", "public/build"]}`), &artifact); err != nil {
+		t.Fatalf("Failed to read a list of output directories: %v", err)
+	}
+	if len(artifact.OutputDirectory) != 2 || artifact.OutputDirectory[1] != "public/build" {
+		t.Errorf("Expected dist and public/build, got %v", artifact.OutputDirectory)
+	}
+	if err := json.Unmarshal([]byte(`{"output_directory": 3}`), &artifact); err == nil {
+		t.Errorf("Expected a number to be rejected")
+	}
+
+	// A single directory is written back as a plain path
+	if data, err := json.Marshal(OutputDirectories{"dist"}); err != nil || string(data) != `"dist"` {
+		t.Errorf("Expected \"dist\", got %s (%v)", data, err)
+	}
+	if data, err := json.Marshal(OutputDirectories{"dist", "public"}); err != nil || string(data) != `["dist","public"]` {
+		t.Errorf("Expected a list, got %s (%v)", data, err)
+	}
+}
+
+func TestOutputDirectoriesArchivePrefixes(t *testing.T) {
+	if prefixes, err := (OutputDirectories{"../dist"}).ArchivePrefixes(); err != nil || len(prefixes) != 1 || prefixes[0] != "" {
+		t.Errorf("Expected a single directory at the top of the archive, got %v, %v", prefixes, err)
+	}
+	prefixes, err := OutputDirectories{"dist/", "./public/build"}.ArchivePrefixes()
+	if err != nil || prefixes[0] != "dist" || prefixes[1] != "public/build" {
+		t.Errorf("Expected cleaned prefixes, got %v, %v", prefixes, err)
+	}
+
+	for _, bad := range []OutputDirectories{
+		{"dist", "dist/css"},
+		{"public", "./public"},
+		{"dist", "."},
+		{"dist", "../shared"},
+	} {
+		if _, err := bad.ArchivePrefixes(); err == nil {
+			t.Errorf("Expected %v to be rejected", bad)
+		}
+	}
+}
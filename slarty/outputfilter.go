@@ -0,0 +1,113 @@
+package slarty
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// OutputFilter picks the files under an artifact's output directory that go into its
+// archive. Patterns are slash-separated globs relative to the output directory, in
+// which ** matches any number of directories. As in .gitignore, a pattern with no
+// slash, apart from a trailing one, matches a name at any depth, and a pattern that
+// matches a directory matches everything inside it.
+type OutputFilter struct {
+	include [][]string
+	exclude [][]string
+}
+
+// OutputFilter returns the filter for the artifact's output_include and
+// output_exclude, or nil when it has neither
+func (a ArtifactConfig) OutputFilter() (*OutputFilter, error) {
+	if len(a.OutputInclude) == 0 && len(a.OutputExclude) == 0 {
+		return nil, nil
+	}
+	include, err := compileOutputPatterns(a.OutputInclude)
+	if err != nil {
+		return nil, fmt.Errorf("%s output_include: %w", a.Name, err)
+	}
+	exclude, err := compileOutputPatterns(a.OutputExclude)
+	if err != nil {
+		return nil, fmt.Errorf("%s output_exclude: %w", a.Name, err)
+	}
+	return &OutputFilter{include: include, exclude: exclude}, nil
+}
+
+// compileOutputPatterns splits each pattern into its path segments
+func compileOutputPatterns(patterns []string) ([][]string, error) {
+	var compiled [][]string
+	for _, pattern := range patterns {
+		trimmed := strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+		anchored := strings.Contains(trimmed, "/")
+		trimmed = strings.TrimPrefix(trimmed, "/")
+		if trimmed == "" {
+			return nil, fmt.Errorf("empty pattern")
+		}
+		if _, err := path.Match(trimmed, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		segments := strings.Split(trimmed, "/")
+		if !anchored {
+			segments = []string{"**", segments[0]}
+		}
+		compiled = append(compiled, segments)
+	}
+	return compiled, nil
+}
+
+// Excludes reports whether the file or directory at relPath, relative to the output
+// directory, is left out of the archive along with everything inside it. A nil filter
+// excludes nothing.
+func (f *OutputFilter) Excludes(relPath string) bool {
+	return f != nil && matchesAny(f.exclude, relPath)
+}
+
+// Includes reports whether the file or directory at relPath is archived, which it is
+// when nothing excludes it and, if there are output_include patterns, one of them
+// matches it. A nil filter includes everything.
+func (f *OutputFilter) Includes(relPath string) bool {
+	if f == nil {
+		return true
+	}
+	if f.Excludes(relPath) {
+		return false
+	}
+	return len(f.include) == 0 || matchesAny(f.include, relPath)
+}
+
+// matchesAny reports whether relPath, or a directory containing it, matches any of
+// the patterns
+func matchesAny(patterns [][]string, relPath string) bool {
+	segments := strings.Split(strings.Trim(relPath, "/"), "/")
+	for _, pattern := range patterns {
+		for end := 1; end <= len(segments); end++ {
+			if matchSegments(pattern, segments[:end]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, where a ** segment
+// matches any number of path segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for skip := 0; skip <= len(segments); skip++ {
+			if matchSegments(pattern[1:], segments[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
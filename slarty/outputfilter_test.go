@@ -0,0 +1,47 @@
+package slarty
+
+import "testing"
+
+func TestOutputFilter(t *testing.T) {
+	if filter, err := (ArtifactConfig{Name: "web"}).OutputFilter(); err != nil || filter != nil {
+		t.Fatalf("Expected no filter without patterns, got %v, %v", filter, err)
+	}
+	var none *OutputFilter
+	if !none.Includes("anything/at/all") || none.Excludes("anything") {
+		t.Errorf("Expected a nil filter to include everything")
+	}
+
+	filter, err := ArtifactConfig{
+		Name:          "web",
+		OutputInclude: []string{"public/**/*.css", "/bin/"},
+		OutputExclude: []string{"*.map", "public/vendor"},
+	}.OutputFilter()
+	if err != nil {
+		t.Fatalf("OutputFilter failed: %v", err)
+	}
+	cases := map[string]bool{
+		"public/site.css":          true,
+		"public/css/deep/site.css": true,
+		"public/site.css.map":      false,
+		"public/vendor/lib.css":    false,
+		"public/site.js":           false,
+		"bin":                      true,
+		"bin/server":               true,
+		"bin/server.map":           false,
+		"src/bin/server":           false,
+	}
+	for path, expected := range cases {
+		if got := filter.Includes(path); got != expected {
+			t.Errorf("Includes(%q) = %v, want %v", path, got, expected)
+		}
+	}
+
+	for _, bad := range []ArtifactConfig{
+		{Name: "web", OutputInclude: []string{"["}},
+		{Name: "web", OutputExclude: []string{" "}},
+	} {
+		if _, err := bad.OutputFilter(); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+}
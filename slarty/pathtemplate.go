@@ -0,0 +1,100 @@
+package slarty
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// pathPlaceholderPattern matches a placeholder in a repository root or path prefix
+var pathPlaceholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// nonPathCharacters matches what is replaced when a name is used as a path segment
+var nonPathCharacters = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// artifactPlaceholder is expanded for each artifact as it is stored or retrieved,
+// while every other placeholder is expanded once when the repository is opened
+const artifactPlaceholder = "{artifact}"
+
+// ExpandPathTemplate expands the placeholders of a repository root or S3 path prefix
+// that are the same for every artifact: {app}, {channel}, {yyyy}, {mm} and {dd}.
+// {artifact} is left in place to be expanded per artifact. Any other placeholder is
+// an error.
+func ExpandPathTemplate(template, app, channel string, now time.Time) (string, error) {
+	var unknown string
+	expanded := pathPlaceholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch placeholder {
+		case "{app}":
+			return pathSegment(app)
+		case "{channel}":
+			return channel
+		case "{yyyy}":
+			return now.Format("2006")
+		case "{mm}":
+			return now.Format("01")
+		case "{dd}":
+			return now.Format("02")
+		case artifactPlaceholder:
+			return placeholder
+		}
+		if unknown == "" {
+			unknown = placeholder
+		}
+		return placeholder
+	})
+	if unknown != "" {
+		return "", fmt.Errorf("unknown placeholder %s in repository path %q", unknown, template)
+	}
+	return expanded, nil
+}
+
+// HasChannelPlaceholder reports whether a repository path places the channel itself,
+// rather than having it added to the end
+func HasChannelPlaceholder(template string) bool {
+	return strings.Contains(template, "{channel}")
+}
+
+// expandArtifactPath expands {artifact} in path for the named artifact with pathName,
+// or artifactPathName when it is nil, and drops the empty segments left by
+// placeholders that expanded to nothing
+func expandArtifactPath(path, artifactName string, pathName func(string) string) string {
+	if strings.Contains(path, artifactPlaceholder) {
+		if pathName == nil {
+			pathName = artifactPathName
+		}
+		path = strings.ReplaceAll(path, artifactPlaceholder, pathName(artifactName))
+	}
+
+	leadingSlash := strings.HasPrefix(path, "/")
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	path = strings.Join(segments, "/")
+	if leadingSlash {
+		path = "/" + path
+	}
+	return path
+}
+
+// artifactPathName returns the part of an artifact name that {artifact} expands to.
+// Artifact names are {prefix}-{hash}.tar.gz by default and latest pointers
+// {prefix}-latest.json, so this is the artifact prefix: everything before the last
+// "-". A name without one is used whole. Names from an artifact_name template are
+// worked out by ArtifactsConfig.artifactPathNamer instead.
+func artifactPathName(artifactName string) string {
+	artifactName = artifactName[strings.LastIndex(artifactName, "/")+1:]
+	if i := strings.LastIndex(artifactName, "-"); i > 0 {
+		return artifactName[:i]
+	}
+	return artifactName
+}
+
+// pathSegment makes a name, such as the application name, safe to use as a single
+// path segment
+func pathSegment(name string) string {
+	return strings.Trim(nonPathCharacters.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
@@ -0,0 +1,96 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpandPathTemplate(t *testing.T) {
+	now := time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC)
+
+	path, err := ExpandPathTemplate("artifacts/{app}/{channel}/{artifact}/{yyyy}/{mm}/{dd}/", "My Shop", "main", now)
+	if err != nil {
+		t.Fatalf("ExpandPathTemplate failed: %v", err)
+	}
+	if path != "artifacts/my-shop/main/{artifact}/2026/03/07/" {
+		t.Errorf("Expected the placeholders to be expanded, got %s", path)
+	}
+
+	if _, err := ExpandPathTemplate("{app}/{application}", "shop", "", now); err == nil {
+		t.Errorf("Expected an unknown placeholder to be rejected")
+	}
+}
+
+func TestExpandArtifactPath(t *testing.T) {
+	tests := map[string]string{
+		"{artifact}/2026":      "slarty-models/2026",
+		"shop//{artifact}/":    "shop/slarty-models",
+		"/srv/repo/{artifact}": "/srv/repo/slarty-models",
+		"":                     "",
+	}
+	for path, expected := range tests {
+		if expanded := expandArtifactPath(path, "slarty-models-51286ac.tar.gz", nil); expanded != expected {
+			t.Errorf("Expected %q to expand to %q, got %q", path, expected, expanded)
+		}
+	}
+
+	if name := artifactPathName("web-latest.json"); name != "web" {
+		t.Errorf("Expected a latest pointer to expand to its prefix, got %s", name)
+	}
+	if name := artifactPathName("extjs.tar.gz"); name != "extjs.tar.gz" {
+		t.Errorf("Expected a name without a hash to be used whole, got %s", name)
+	}
+}
+
+func TestNewRepositoryAdapterPathTemplate(t *testing.T) {
+	root := t.TempDir()
+	config := &ArtifactsConfig{Application: "Shop"}
+	config.Repository.Adapter = "Local"
+	config.Repository.Options.Root = root + "/{app}/{artifact}"
+	config.Repository.Channel = "main"
+
+	repo, err := NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+	for _, name := range []string{"web-abc.tar.gz", "api-def.tar.gz"} {
+		if err := repo.StoreArtifact(strings.NewReader("archive"), name); err != nil {
+			t.Fatalf("StoreArtifact failed: %v", err)
+		}
+	}
+
+	// The channel goes at the end unless the template places it
+	if _, err := os.Stat(filepath.Join(root, "shop", "web", "main", "web-abc.tar.gz")); err != nil {
+		t.Errorf("Expected web under its own directory: %v", err)
+	}
+	if exists, err := repo.ArtifactExists("api-def.tar.gz"); !exists || err != nil {
+		t.Errorf("Expected api to be found under its own directory, got %v (%v)", exists, err)
+	}
+
+	config.Repository.Options.Root = root + "/{channel}/{artifact}"
+	repo, err = NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+	if err := repo.StoreArtifact(strings.NewReader("archive"), "web-abc.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "main", "web", "web-abc.tar.gz")); err != nil {
+		t.Errorf("Expected the channel where the template places it: %v", err)
+	}
+
+	config.Repository.Options.Root = root + "/{bucket}"
+	if _, err := NewRepositoryAdapter(config, false); err == nil {
+		t.Errorf("Expected an unknown placeholder to be rejected")
+	}
+}
+
+func TestS3ObjectKeyPathTemplate(t *testing.T) {
+	s3 := &S3RepositoryAdapter{pathPrefix: "shop/{artifact}/"}
+	if key := s3.getObjectKey("web-abc.tar.gz"); key != "shop/web/web-abc.tar.gz" {
+		t.Errorf("Expected shop/web/web-abc.tar.gz, got %s", key)
+	}
+}
@@ -0,0 +1,164 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// ErrChownNotPermitted is returned by ApplyPermissions when the files could not be
+// given their owner or group because slarty is not running as root or with the
+// capability to change ownership. The mode has still been applied.
+var ErrChownNotPermitted = errors.New("not permitted to change ownership")
+
+// Permissions is the owner, group and mode given to deployed files
+type Permissions struct {
+	Owner string
+	Group string
+	Mode  string
+}
+
+// Permissions returns the ownership and mode configured for the artifact
+func (a ArtifactConfig) Permissions() Permissions {
+	return Permissions{Owner: a.Owner, Group: a.Group, Mode: a.Mode}
+}
+
+// Permissions returns the ownership and mode configured for the asset
+func (a Asset) Permissions() Permissions {
+	return Permissions{Owner: a.Owner, Group: a.Group, Mode: a.Mode}
+}
+
+// IsSet reports whether any of owner, group or mode is configured
+func (p Permissions) IsSet() bool {
+	return p.Owner != "" || p.Group != "" || p.Mode != ""
+}
+
+// ParseMode parses an octal permission mode such as "0644" or "755"
+func ParseMode(mode string) (os.FileMode, error) {
+	value, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || value > 0o777 {
+		return 0, fmt.Errorf("invalid mode %q: expected octal permissions such as 0644", mode)
+	}
+	return os.FileMode(value), nil
+}
+
+// DirectoryMode is the mode given to directories for a file mode. Directories can
+// be searched by whoever can read them, as with chmod's X.
+func DirectoryMode(mode os.FileMode) os.FileMode {
+	return mode | (mode&0o444)>>2
+}
+
+// lookupID resolves a user or group name, or a numeric id, to its id
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	id, err := lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// LookupOwnership resolves an owner and group to the ids passed to chown. Either can
+// be a name or a numeric id, and an empty one is -1 so it is left unchanged.
+func LookupOwnership(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if owner != "" {
+		id, err := lookupID(owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		var unknown user.UnknownUserError
+		if errors.As(err, &unknown) {
+			return 0, 0, fmt.Errorf("unknown owner %s", owner)
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to look up owner %s: %w", owner, err)
+		}
+		uid = id
+	}
+	if group != "" {
+		id, err := lookupID(group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		var unknown user.UnknownGroupError
+		if errors.As(err, &unknown) {
+			return 0, 0, fmt.Errorf("unknown group %s", group)
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to look up group %s: %w", group, err)
+		}
+		gid = id
+	}
+	return uid, gid, nil
+}
+
+// ApplyPermissions sets the mode, owner and group on path and, if it is a directory,
+// everything below it. Symlinks are left alone. If ownership cannot be changed for
+// lack of privileges the mode is still applied and ErrChownNotPermitted is returned.
+func ApplyPermissions(path string, p Permissions) error {
+	if !p.IsSet() {
+		return nil
+	}
+
+	var mode os.FileMode
+	if p.Mode != "" {
+		var err error
+		if mode, err = ParseMode(p.Mode); err != nil {
+			return err
+		}
+	}
+	uid, gid, err := LookupOwnership(p.Owner, p.Group)
+	if err != nil {
+		return err
+	}
+	chown := p.Owner != "" || p.Group != ""
+
+	var chownErr error
+	err = filepath.WalkDir(path, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		if p.Mode != "" {
+			entryMode := mode
+			if entry.IsDir() {
+				entryMode = DirectoryMode(mode)
+			}
+			if err := os.Chmod(current, entryMode); err != nil {
+				return err
+			}
+		}
+
+		if chown && chownErr == nil {
+			if err := os.Chown(current, uid, gid); err != nil {
+				if !errors.Is(err, fs.ErrPermission) {
+					return err
+				}
+				// Keep applying the mode, but don't try every file again
+				chownErr = fmt.Errorf("%w on %s: %v", ErrChownNotPermitted, path, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+
+	return chownErr
+}
@@ -0,0 +1,89 @@
+package slarty
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	for mode, expected := range map[string]os.FileMode{"0644": 0o644, "755": 0o755, "0": 0} {
+		parsed, err := ParseMode(mode)
+		if err != nil || parsed != expected {
+			t.Errorf("Expected %q to parse as %o, got %o (%v)", mode, expected, parsed, err)
+		}
+	}
+	for _, mode := range []string{"", "rw-r--r--", "0888", "1777", "-1"} {
+		if _, err := ParseMode(mode); err == nil {
+			t.Errorf("Expected %q to be rejected", mode)
+		}
+	}
+
+	if mode := DirectoryMode(0o640); mode != 0o750 {
+		t.Errorf("Expected directory mode 0750 for 0640, got %o", mode)
+	}
+}
+
+func TestLookupOwnership(t *testing.T) {
+	uid, gid, err := LookupOwnership("", "")
+	if err != nil || uid != -1 || gid != -1 {
+		t.Errorf("Expected no change without an owner or group, got %d:%d (%v)", uid, gid, err)
+	}
+	uid, gid, err = LookupOwnership("33", "33")
+	if err != nil || uid != 33 || gid != 33 {
+		t.Errorf("Expected numeric ids to be used as they are, got %d:%d (%v)", uid, gid, err)
+	}
+	if _, _, err := LookupOwnership("slarty-no-such-user", ""); err == nil {
+		t.Errorf("Expected an unknown owner to be an error")
+	}
+}
+
+func TestApplyPermissions(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "deploy")
+	if err := os.MkdirAll(filepath.Join(root, "public"), 0700); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+	file := filepath.Join(root, "public", "index.html")
+	if err := os.WriteFile(file, []byte("<html>"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Symlink(file, filepath.Join(root, "link")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	// Changing to the current owner is always allowed
+	perms := Permissions{Owner: strconv.Itoa(os.Getuid()), Group: strconv.Itoa(os.Getgid()), Mode: "0644"}
+	if err := ApplyPermissions(root, perms); err != nil {
+		t.Fatalf("ApplyPermissions failed: %v", err)
+	}
+
+	for path, expected := range map[string]os.FileMode{root: 0o755, filepath.Join(root, "public"): 0o755, file: 0o644} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat %s: %v", path, err)
+		}
+		if info.Mode().Perm() != expected {
+			t.Errorf("Expected %s to have mode %o, got %o", path, expected, info.Mode().Perm())
+		}
+	}
+
+	if err := ApplyPermissions(root, Permissions{Mode: "bad"}); err == nil {
+		t.Errorf("Expected an invalid mode to be an error")
+	}
+	if err := ApplyPermissions(root, Permissions{}); err != nil {
+		t.Errorf("Expected nothing to be done without permissions, got %v", err)
+	}
+
+	// Without privileges the mode is applied and the ownership change is reported
+	if os.Geteuid() != 0 {
+		err := ApplyPermissions(root, Permissions{Owner: "0", Mode: "0600"})
+		if !errors.Is(err, ErrChownNotPermitted) {
+			t.Errorf("Expected ErrChownNotPermitted, got %v", err)
+		}
+		if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0o600 {
+			t.Errorf("Expected the mode to be applied without privileges")
+		}
+	}
+}
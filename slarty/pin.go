@@ -0,0 +1,58 @@
+package slarty
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// pinHashPattern matches the hashes artifact names are built from
+var pinHashPattern = regexp.MustCompile(`^[0-9a-f]+$`)
+
+// ParsePins parses pins of the form artifact=hash into a map of artifact name to hash.
+// A pin for an artifact that is pinned twice to different hashes is an error.
+func ParsePins(specs []string) (map[string]string, error) {
+	pins := make(map[string]string)
+	for _, spec := range specs {
+		name, hash, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		hash = strings.ToLower(strings.TrimSpace(hash))
+		if !ok || name == "" || hash == "" {
+			return nil, fmt.Errorf("invalid pin %q: expected artifact=hash", spec)
+		}
+		if !pinHashPattern.MatchString(hash) {
+			return nil, fmt.Errorf("invalid pin %q: hash must be hexadecimal", spec)
+		}
+		if existing, ok := pins[name]; ok && existing != hash {
+			return nil, fmt.Errorf("artifact %s is pinned to both %s and %s", name, existing, hash)
+		}
+		pins[name] = hash
+	}
+	return pins, nil
+}
+
+// ReadPinFile reads pins from a file with one artifact=hash pin per line. Blank lines
+// and lines starting with # are ignored.
+func ReadPinFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pin file: %w", err)
+	}
+	defer file.Close()
+
+	var specs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		specs = append(specs, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pin file: %w", err)
+	}
+	return specs, nil
+}
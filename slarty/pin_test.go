@@ -0,0 +1,59 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePins(t *testing.T) {
+	pins, err := ParsePins([]string{"web=ABC123", " api = 0f0f "})
+	if err != nil {
+		t.Fatalf("ParsePins failed: %v", err)
+	}
+	if pins["web"] != "abc123" || pins["api"] != "0f0f" {
+		t.Errorf("Expected parsed pins, got %v", pins)
+	}
+
+	for _, spec := range []string{"web", "=abc", "web=", "web=xyz", "web=../abc"} {
+		if _, err := ParsePins([]string{spec}); err == nil {
+			t.Errorf("Expected %q to be rejected", spec)
+		}
+	}
+	if _, err := ParsePins([]string{"web=abc", "web=def"}); err == nil {
+		t.Errorf("Expected conflicting pins to be rejected")
+	}
+}
+
+func TestReadPinFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pins")
+	content := "# rollback for incident 42\nweb=abc123\n\n  api=def456  \n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write pin file: %v", err)
+	}
+
+	specs, err := ReadPinFile(path)
+	if err != nil {
+		t.Fatalf("ReadPinFile failed: %v", err)
+	}
+	if len(specs) != 2 || specs[0] != "web=abc123" || specs[1] != "api=def456" {
+		t.Errorf("Expected two pins, got %v", specs)
+	}
+
+	if _, err := ReadPinFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("Expected an error for a missing pin file")
+	}
+}
+
+func TestArtifactNameForHash(t *testing.T) {
+	config := &ArtifactsConfig{}
+	archive := ArtifactConfig{Name: "web", ArtifactPrefix: "web"}
+	if name, err := ArtifactNameForHash(archive, "abc", config); name != "web-abc.tar.gz" || err != nil {
+		t.Errorf("Expected web-abc.tar.gz, got %s (%v)", name, err)
+	}
+
+	image := ArtifactConfig{Name: "api", Type: ArtifactTypeDocker, Image: "registry.example.com/api", ArtifactPrefix: "api", NameTemplate: "{prefix}-{hash}-{app}.{ext}"}
+	if name, err := ArtifactNameForHash(image, "abc", config); name != "registry.example.com/api:api-abc" || err != nil {
+		t.Errorf("Expected registry.example.com/api:api-abc, got %s (%v)", name, err)
+	}
+}
@@ -0,0 +1,101 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PipelineNames returns the names of the pipelines defined in the configuration, sorted
+func (ac *ArtifactsConfig) PipelineNames() []string {
+	names := make([]string, 0, len(ac.Pipelines))
+	for name := range ac.Pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pipeline returns the steps of the named pipeline, each split into the slarty command
+// and arguments it runs
+func (ac *ArtifactsConfig) Pipeline(name string) ([][]string, error) {
+	steps, ok := ac.Pipelines[name]
+	if !ok {
+		return nil, fmt.Errorf("pipeline %s not found in configuration", name)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("pipeline %s has no steps", name)
+	}
+
+	parsed := make([][]string, 0, len(steps))
+	for i, step := range steps {
+		args, err := SplitCommandLine(step)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline %s step %d: %w", name, i+1, err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("pipeline %s step %d is empty", name, i+1)
+		}
+		if args[0] == "slarty" {
+			args = args[1:]
+		}
+		if len(args) == 0 || args[0] == "run" {
+			return nil, fmt.Errorf("pipeline %s step %d must run a slarty command other than run", name, i+1)
+		}
+		parsed = append(parsed, args)
+	}
+	return parsed, nil
+}
+
+// SplitCommandLine splits a command line into arguments at whitespace. Single and
+// double quotes group words into one argument, and a backslash outside single quotes
+// escapes the next character. Nothing else is interpreted, since the arguments are
+// passed to slarty rather than a shell.
+func SplitCommandLine(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if escaped {
+		return nil, errors.New("command line ends with a backslash")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command line", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,62 @@
+package slarty
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := map[string][]string{
+		"do-deploys --filter api":         {"do-deploys", "--filter", "api"},
+		`  do-builds   --filter "a, b"  `: {"do-builds", "--filter", "a, b"},
+		`should-build --filter 'it''s'`:   {"should-build", "--filter", "its"},
+		`plan --ref "" --base main`:       {"plan", "--ref", "", "--base", "main"},
+		`do-builds --filter web\ admin`:   {"do-builds", "--filter", "web admin"},
+		`do-builds --filter 'back\slash'`: {"do-builds", "--filter", `back\slash`},
+		"":                                nil,
+	}
+	for line, expected := range tests {
+		args, err := SplitCommandLine(line)
+		if err != nil {
+			t.Errorf("SplitCommandLine(%q) failed: %v", line, err)
+			continue
+		}
+		if !reflect.DeepEqual(args, expected) {
+			t.Errorf("SplitCommandLine(%q) = %q, expected %q", line, args, expected)
+		}
+	}
+
+	for _, line := range []string{`do-builds --filter "web`, `do-builds \`} {
+		if _, err := SplitCommandLine(line); err == nil {
+			t.Errorf("Expected %q to be rejected", line)
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	config := &ArtifactsConfig{Pipelines: map[string][]string{
+		"release": {"should-build", "slarty do-builds", "do-deploys --filter api"},
+		"nested":  {"run release"},
+		"empty":   {},
+		"blank":   {"do-builds", "  "},
+	}}
+
+	steps, err := config.Pipeline("release")
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	expected := [][]string{{"should-build"}, {"do-builds"}, {"do-deploys", "--filter", "api"}}
+	if !reflect.DeepEqual(steps, expected) {
+		t.Errorf("Expected %q, got %q", expected, steps)
+	}
+
+	for _, name := range []string{"nested", "empty", "blank", "missing"} {
+		if _, err := config.Pipeline(name); err == nil {
+			t.Errorf("Expected pipeline %s to be rejected", name)
+		}
+	}
+
+	if names := config.PipelineNames(); !reflect.DeepEqual(names, []string{"blank", "empty", "nested", "release"}) {
+		t.Errorf("Expected sorted pipeline names, got %v", names)
+	}
+}
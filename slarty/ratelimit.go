@@ -0,0 +1,205 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateChunkSize is the most a rate limited transfer reads or writes at once, so a large
+// read never has to wait for a long stretch all at once
+const rateChunkSize = 32 << 10
+
+// errNotByteCount and errUnderOneByte are returned by parseByteCount
+var (
+	errNotByteCount = errors.New("not a byte count")
+	errUnderOneByte = errors.New("less than one byte")
+)
+
+// parseByteCount parses a number of bytes, such as 512, 500K, 10M or 1.5G, with
+// suffixes in powers of 1024. An empty value is 0.
+func parseByteCount(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	multiplier := 1.0
+	switch strings.ToUpper(value[len(value)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, errNotByteCount
+	}
+	count := int64(number * multiplier)
+	if number > 0 && count < 1 {
+		return 0, errUnderOneByte
+	}
+	return count, nil
+}
+
+// ParseRate parses a transfer rate in bytes per second, such as 500K, 10M or 1.5G, with
+// suffixes in powers of 1024. An empty rate or 0 means no limit.
+func ParseRate(rate string) (int64, error) {
+	bytesPerSecond, err := parseByteCount(rate)
+	if errors.Is(err, errUnderOneByte) {
+		return 0, fmt.Errorf("invalid rate %q, which is less than one byte per second", rate)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q, expected bytes per second such as 500K, 10M or 1G", rate)
+	}
+	return bytesPerSecond, nil
+}
+
+// rateLimiter is a token bucket shared by every transfer through a repository, so
+// transfers that run at the same time share the rate between them. Tokens are bytes,
+// and the bucket holds up to one second of them.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+	sleep  func(time.Duration)
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{rate: float64(bytesPerSecond), tokens: float64(bytesPerSecond), last: time.Now(), sleep: time.Sleep}
+}
+
+// wait blocks until n bytes may be transferred. The bytes are taken from the bucket
+// straight away, so later callers wait behind them.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay > 0 {
+		l.sleep(delay)
+	}
+}
+
+// rateLimitedReader reads from r no faster than limiter allows
+type rateLimitedReader struct {
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateChunkSize {
+		p = p[:rateChunkSize]
+	}
+	n, err := r.r.Read(p)
+	r.limiter.wait(n)
+	return n, err
+}
+
+// rateLimitedWriter writes to w no faster than limiter allows
+type rateLimitedWriter struct {
+	w       io.Writer
+	limiter *rateLimiter
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > rateChunkSize {
+			chunk = chunk[:rateChunkSize]
+		}
+		w.limiter.wait(len(chunk))
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// rateLimitedRepositoryAdapter wraps a repository so artifacts are stored and retrieved
+// no faster than a set rate, keeping deploys from saturating a production host's
+// network. Uploads to S3 are sent in parts of s3PartSize, so they keep to the rate on
+// average rather than at every instant.
+type rateLimitedRepositoryAdapter struct {
+	RepositoryAdapter
+	limiter *rateLimiter
+}
+
+func newRateLimitedRepositoryAdapter(repo RepositoryAdapter, bytesPerSecond int64) *rateLimitedRepositoryAdapter {
+	return &rateLimitedRepositoryAdapter{RepositoryAdapter: repo, limiter: newRateLimiter(bytesPerSecond)}
+}
+
+// StoreArtifact stores the archive read from r at the limited rate
+func (l *rateLimitedRepositoryAdapter) StoreArtifact(r io.Reader, artifactName string) error {
+	return l.RepositoryAdapter.StoreArtifact(&rateLimitedReader{r: r, limiter: l.limiter}, artifactName)
+}
+
+// RetrieveArtifact writes an archive to w at the limited rate
+func (l *rateLimitedRepositoryAdapter) RetrieveArtifact(artifactName string, w io.Writer) error {
+	return l.RepositoryAdapter.RetrieveArtifact(artifactName, &rateLimitedWriter{w: w, limiter: l.limiter})
+}
+
+// PrefetchArtifacts finds out which artifacts exist in bulk when the wrapped repository
+// can
+func (l *rateLimitedRepositoryAdapter) PrefetchArtifacts(artifactNames []string) {
+	PrefetchArtifacts(l.RepositoryAdapter, artifactNames)
+}
+
+// ArtifactSize returns the size of a stored artifact when the wrapped repository can
+// report it
+func (l *rateLimitedRepositoryAdapter) ArtifactSize(artifactName string) (int64, error) {
+	sizer, ok := l.RepositoryAdapter.(ArtifactSizer)
+	if !ok {
+		return 0, errors.New("repository cannot report artifact sizes")
+	}
+	return sizer.ArtifactSize(artifactName)
+}
+
+// SameContent compares a stored artifact with an archive when the wrapped repository
+// can, and otherwise reports false
+func (l *rateLimitedRepositoryAdapter) SameContent(artifactName string, r io.Reader) (bool, error) {
+	comparer, ok := l.RepositoryAdapter.(ArtifactComparer)
+	if !ok {
+		return false, nil
+	}
+	return comparer.SameContent(artifactName, r)
+}
+
+// LabelArtifact attaches labels to a stored artifact when the wrapped repository can
+func (l *rateLimitedRepositoryAdapter) LabelArtifact(artifactName string, labels map[string]string) error {
+	return LabelArtifact(l.RepositoryAdapter, artifactName, labels)
+}
+
+// ArtifactLabels returns the labels of a stored artifact when the wrapped repository
+// can
+func (l *rateLimitedRepositoryAdapter) ArtifactLabels(artifactName string) (map[string]string, error) {
+	return ArtifactLabels(l.RepositoryAdapter, artifactName)
+}
+
+// ListArtifacts lists the artifacts starting with prefix when the wrapped repository
+// can
+func (l *rateLimitedRepositoryAdapter) ListArtifacts(prefix string) ([]string, error) {
+	return ListArtifacts(l.RepositoryAdapter, prefix)
+}
@@ -0,0 +1,93 @@
+package slarty
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	valid := map[string]int64{
+		"":     0,
+		"0":    0,
+		"512":  512,
+		"500K": 500 << 10,
+		"10m":  10 << 20,
+		"1.5G": 3 << 29,
+	}
+	for rate, expected := range valid {
+		if got, err := ParseRate(rate); err != nil || got != expected {
+			t.Errorf("ParseRate(%q) = %d, %v, want %d", rate, got, err, expected)
+		}
+	}
+
+	for _, rate := range []string{"fast", "10MB", "-1K", "K", "0.1"} {
+		if _, err := ParseRate(rate); err == nil {
+			t.Errorf("Expected ParseRate(%q) to fail", rate)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(1000)
+	var slept time.Duration
+	limiter.sleep = func(d time.Duration) { slept += d }
+
+	// The first second's worth goes straight away, and the rest waits its turn
+	limiter.wait(1000)
+	if slept != 0 {
+		t.Errorf("Expected a full bucket not to wait, slept %v", slept)
+	}
+	limiter.wait(500)
+	limiter.wait(500)
+	if slept < 1400*time.Millisecond || slept > 1500*time.Millisecond {
+		t.Errorf("Expected to wait about 1.5s for 1000 bytes more, slept %v", slept)
+	}
+}
+
+func TestRateLimitedRepositoryAdapter(t *testing.T) {
+	root := t.TempDir()
+	config := &ArtifactsConfig{}
+	config.Repository.Adapter = "local"
+	config.Repository.Options.Root = root
+	config.Repository.LimitRate = "64K"
+
+	repo, err := NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+	limited, ok := repo.(*rateLimitedRepositoryAdapter)
+	if !ok {
+		t.Fatalf("Expected a rate limited repository, got %T", repo)
+	}
+	var slept time.Duration
+	limited.limiter.sleep = func(d time.Duration) { slept += d }
+
+	archive := bytes.Repeat([]byte("a"), 256<<10)
+	if err := repo.StoreArtifact(bytes.NewReader(archive), "a.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+	var retrieved bytes.Buffer
+	if err := repo.RetrieveArtifact("a.tar.gz", &retrieved); err != nil || !bytes.Equal(retrieved.Bytes(), archive) {
+		t.Fatalf("Expected the archive back (%v)", err)
+	}
+	// 512K both ways at 64K a second, less the second's worth the bucket starts with
+	if slept < 6*time.Second {
+		t.Errorf("Expected the transfers to be held to the rate, slept %v", slept)
+	}
+
+	if same, err := repo.(ArtifactComparer).SameContent("a.tar.gz", bytes.NewReader(archive)); !same || err != nil {
+		t.Errorf("Expected the stored artifact to match, got %v (%v)", same, err)
+	}
+	if size, err := repo.(ArtifactSizer).ArtifactSize("a.tar.gz"); size != int64(len(archive)) || err != nil {
+		t.Errorf("Expected size %d, got %d (%v)", len(archive), size, err)
+	}
+
+	config.Repository.LimitRate = "fast"
+	config.Repository.Options.Root = filepath.Join(root, "other")
+	if _, err := NewRepositoryAdapter(config, false); err == nil || !strings.Contains(err.Error(), "limit-rate") {
+		t.Errorf("Expected an invalid limit-rate to be an error, got %v", err)
+	}
+}
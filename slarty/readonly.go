@@ -0,0 +1,70 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrReadOnly is returned when changing a repository opened read-only
+var ErrReadOnly = errors.New("repository is read-only")
+
+// readOnlyRepositoryAdapter wraps a repository so nothing in it can be stored, replaced,
+// labelled or deleted, while artifacts can still be looked up and retrieved. It lets
+// slarty be pointed at the production bucket from a workstation for inspection
+// without any risk of changing it.
+type readOnlyRepositoryAdapter struct {
+	RepositoryAdapter
+}
+
+func newReadOnlyRepositoryAdapter(repo RepositoryAdapter) *readOnlyRepositoryAdapter {
+	return &readOnlyRepositoryAdapter{RepositoryAdapter: repo}
+}
+
+// StoreArtifact refuses to store the artifact
+func (o *readOnlyRepositoryAdapter) StoreArtifact(r io.Reader, artifactName string) error {
+	return fmt.Errorf("%w: refusing to store %s", ErrReadOnly, artifactName)
+}
+
+// LabelArtifact refuses to label the artifact
+func (o *readOnlyRepositoryAdapter) LabelArtifact(artifactName string, labels map[string]string) error {
+	return fmt.Errorf("%w: refusing to label %s", ErrReadOnly, artifactName)
+}
+
+// PrefetchArtifacts finds out which artifacts exist in bulk when the wrapped repository
+// can
+func (o *readOnlyRepositoryAdapter) PrefetchArtifacts(artifactNames []string) {
+	PrefetchArtifacts(o.RepositoryAdapter, artifactNames)
+}
+
+// ArtifactSize returns the size of a stored artifact when the wrapped repository can
+// report it
+func (o *readOnlyRepositoryAdapter) ArtifactSize(artifactName string) (int64, error) {
+	sizer, ok := o.RepositoryAdapter.(ArtifactSizer)
+	if !ok {
+		return 0, errors.New("repository cannot report artifact sizes")
+	}
+	return sizer.ArtifactSize(artifactName)
+}
+
+// SameContent compares a stored artifact with an archive when the wrapped repository
+// can, and otherwise reports false
+func (o *readOnlyRepositoryAdapter) SameContent(artifactName string, r io.Reader) (bool, error) {
+	comparer, ok := o.RepositoryAdapter.(ArtifactComparer)
+	if !ok {
+		return false, nil
+	}
+	return comparer.SameContent(artifactName, r)
+}
+
+// ArtifactLabels returns the labels of a stored artifact when the wrapped repository
+// can
+func (o *readOnlyRepositoryAdapter) ArtifactLabels(artifactName string) (map[string]string, error) {
+	return ArtifactLabels(o.RepositoryAdapter, artifactName)
+}
+
+// ListArtifacts lists the artifacts starting with prefix when the wrapped repository
+// can
+func (o *readOnlyRepositoryAdapter) ListArtifacts(prefix string) ([]string, error) {
+	return ListArtifacts(o.RepositoryAdapter, prefix)
+}
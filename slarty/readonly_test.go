@@ -0,0 +1,82 @@
+package slarty
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyRepositoryAdapter(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.tar.gz"), []byte("stored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ArtifactsConfig{}
+	config.Repository.Adapter = "Local"
+	config.Repository.Options.Root = root
+	config.Repository.Immutable = true
+	config.Repository.ReadOnly = true
+
+	repo, err := NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+
+	// Artifacts can still be looked up and retrieved
+	if exists, err := repo.ArtifactExists("a.tar.gz"); !exists || err != nil {
+		t.Errorf("Expected a.tar.gz to exist, got %v (%v)", exists, err)
+	}
+	var buf bytes.Buffer
+	if err := repo.RetrieveArtifact("a.tar.gz", &buf); err != nil || buf.String() != "stored" {
+		t.Errorf("Expected to retrieve a.tar.gz, got %q (%v)", buf.String(), err)
+	}
+	if size, err := repo.(ArtifactSizer).ArtifactSize("a.tar.gz"); size != 6 || err != nil {
+		t.Errorf("Expected size 6, got %d (%v)", size, err)
+	}
+	if names, err := ListArtifacts(repo, "a"); err != nil || len(names) != 1 {
+		t.Errorf("Expected to list a.tar.gz, got %v (%v)", names, err)
+	}
+
+	// Nothing can be changed, even with overwriting allowed
+	err = AllowOverwrite(repo).StoreArtifact(strings.NewReader("new"), "b.tar.gz")
+	if !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly when storing, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "b.tar.gz")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be stored, got %v", err)
+	}
+	if err := LabelArtifact(repo, "a.tar.gz", map[string]string{"release": "1"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly when labelling, got %v", err)
+	}
+	if err := StoreAuditRecords(repo, []AuditRecord{{Action: AuditDeploy}}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly when storing an audit log, got %v", err)
+	}
+}
+
+func TestMirrorRepositoriesReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	config := &ArtifactsConfig{RootDirectory: dir}
+	config.Repository.Adapter = "s3"
+	config.Repository.ReadOnly = true
+	config.Repository.Options = RepositoryOptions{Root: filepath.Join(dir, "copy"), Region: "us-east-1", BucketName: "builds"}
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CA_BUNDLE", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "aws-config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "aws-credentials"))
+
+	remote, local, err := MirrorRepositories(config)
+	if err != nil {
+		t.Fatalf("MirrorRepositories failed: %v", err)
+	}
+	if err := remote.StoreArtifact(strings.NewReader("x"), "a.tar.gz"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected the remote repository to be read-only, got %v", err)
+	}
+	if err := local.StoreArtifact(strings.NewReader("x"), "a.tar.gz"); err != nil {
+		t.Errorf("Expected the local copy to be writable, got %v", err)
+	}
+}
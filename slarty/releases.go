@@ -0,0 +1,151 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DeployStrategySymlink deploys each release into its own directory and points a
+// symlink at deploy_location to the current one
+const DeployStrategySymlink = "symlink"
+
+// Release is a release directory created by the symlink deploy strategy
+type Release struct {
+	Name    string
+	ModTime time.Time
+}
+
+// UsesSymlinkStrategy reports whether the artifact is deployed with the symlink strategy
+func (a ArtifactConfig) UsesSymlinkStrategy() bool {
+	return a.DeployStrategy == DeployStrategySymlink
+}
+
+// ReleasesPath returns the directory the artifact's releases are kept in, which is
+// releases_directory or, by default, a releases directory next to deploy_location
+func (a ArtifactConfig) ReleasesPath(root string) string {
+	if a.ReleasesDirectory != "" {
+		return filepath.Join(root, a.ReleasesDirectory)
+	}
+	return filepath.Join(filepath.Dir(filepath.Join(root, a.DeployLocation)), "releases")
+}
+
+// ReleaseName returns the name of the release directory for an artifact name, which
+// is the hash it was built from when that can be worked out and otherwise the
+// artifact name without its extension
+func ReleaseName(config ArtifactConfig, artifactName string, artifactsConfig *ArtifactsConfig) string {
+	if hash := HashFromArtifactName(config, artifactName, artifactsConfig); hash != "" {
+		return hash
+	}
+	return strings.TrimSuffix(path.Base(artifactName), "."+config.archiveExtension())
+}
+
+// CurrentRelease returns the name of the release the symlink at linkPath points to,
+// or an empty name if there is no symlink yet
+func CurrentRelease(linkPath string) (string, error) {
+	target, err := os.Readlink(linkPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s is not a release symlink: %w", linkPath, err)
+	}
+	return filepath.Base(target), nil
+}
+
+// LinkRelease points the symlink at linkPath to releaseDir. A new symlink is created
+// next to linkPath and renamed over it, so the switch is atomic and linkPath always
+// points at a complete release.
+func LinkRelease(linkPath, releaseDir string) error {
+	info, err := os.Lstat(linkPath)
+	if err == nil && info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("%s exists and is not a symlink; move it out of the way to use the symlink deploy strategy", linkPath)
+	}
+
+	target, err := filepath.Rel(filepath.Dir(linkPath), releaseDir)
+	if err != nil {
+		target = releaseDir
+	}
+
+	tempLink := fmt.Sprintf("%s.slarty-%d", linkPath, os.Getpid())
+	os.Remove(tempLink)
+	if err := os.Symlink(target, tempLink); err != nil {
+		return fmt.Errorf("failed to create symlink: %w", err)
+	}
+	if err := os.Rename(tempLink, linkPath); err != nil {
+		os.Remove(tempLink)
+		return fmt.Errorf("failed to switch symlink: %w", err)
+	}
+	return nil
+}
+
+// ListReleases returns the releases in releasesDir from oldest to newest
+func ListReleases(releasesDir string) ([]Release, error) {
+	entries, err := os.ReadDir(releasesDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []Release
+	for _, entry := range entries {
+		// Hidden entries are releases still being extracted
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, Release{Name: entry.Name(), ModTime: info.ModTime()})
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].ModTime.Before(releases[j].ModTime)
+	})
+	return releases, nil
+}
+
+// PreviousRelease returns the newest release older than current, or an empty name if
+// there is none
+func PreviousRelease(releases []Release, current string) string {
+	previous := ""
+	for _, release := range releases {
+		if release.Name == current {
+			return previous
+		}
+		previous = release.Name
+	}
+	return ""
+}
+
+// PruneReleases removes all but the newest keep releases in releasesDir, not counting
+// the current release, which is never removed. It returns the names removed.
+func PruneReleases(releasesDir string, keep int, current string) ([]string, error) {
+	releases, err := ListReleases(releasesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var old []Release
+	for _, release := range releases {
+		if release.Name != current {
+			old = append(old, release)
+		}
+	}
+
+	var removed []string
+	for i := 0; i < len(old)-keep; i++ {
+		if err := os.RemoveAll(filepath.Join(releasesDir, old[i].Name)); err != nil {
+			return removed, fmt.Errorf("failed to remove release %s: %w", old[i].Name, err)
+		}
+		removed = append(removed, old[i].Name)
+	}
+	return removed, nil
+}
@@ -0,0 +1,124 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// makeReleases creates release directories in releasesDir, each newer than the last
+func makeReleases(t *testing.T, releasesDir string, names ...string) {
+	t.Helper()
+	base := time.Now().Add(-time.Hour)
+	for i, name := range names {
+		path := filepath.Join(releasesDir, name)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatalf("Failed to create release %s: %v", name, err)
+		}
+		modTime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set time on release %s: %v", name, err)
+		}
+	}
+}
+
+func TestReleasesPathAndName(t *testing.T) {
+	artifact := ArtifactConfig{Name: "web", ArtifactPrefix: "web", DeployLocation: "app/current"}
+	if path := artifact.ReleasesPath("/srv"); path != "/srv/app/releases" {
+		t.Errorf("Expected releases next to the deploy location, got %s", path)
+	}
+	artifact.ReleasesDirectory = "app/web-releases"
+	if path := artifact.ReleasesPath("/srv"); path != "/srv/app/web-releases" {
+		t.Errorf("Expected the configured releases directory, got %s", path)
+	}
+
+	if name := ReleaseName(artifact, "web-abc123.tar.gz", &ArtifactsConfig{}); name != "abc123" {
+		t.Errorf("Expected the hash as the release name, got %s", name)
+	}
+	if name := ReleaseName(artifact, "builds/other-abc123.tar.gz", &ArtifactsConfig{}); name != "other-abc123" {
+		t.Errorf("Expected the artifact name without its extension, got %s", name)
+	}
+}
+
+func TestLinkRelease(t *testing.T) {
+	root := t.TempDir()
+	releasesDir := filepath.Join(root, "releases")
+	makeReleases(t, releasesDir, "aaa", "bbb")
+	linkPath := filepath.Join(root, "current")
+
+	if current, err := CurrentRelease(linkPath); err != nil || current != "" {
+		t.Errorf("Expected no current release before the first deploy, got %q (%v)", current, err)
+	}
+
+	for _, release := range []string{"aaa", "bbb"} {
+		if err := LinkRelease(linkPath, filepath.Join(releasesDir, release)); err != nil {
+			t.Fatalf("LinkRelease failed: %v", err)
+		}
+		if current, err := CurrentRelease(linkPath); err != nil || current != release {
+			t.Errorf("Expected current release %s, got %q (%v)", release, current, err)
+		}
+	}
+
+	// The link is relative so the project can be moved
+	if target, _ := os.Readlink(linkPath); target != filepath.Join("releases", "bbb") {
+		t.Errorf("Expected a relative symlink, got %s", target)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 2 {
+		t.Errorf("Expected no temporary links to be left behind, got %d entries", len(entries))
+	}
+
+	// A real directory is not replaced
+	dirPath := filepath.Join(root, "public")
+	if err := os.Mkdir(dirPath, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := LinkRelease(dirPath, filepath.Join(releasesDir, "aaa")); err == nil {
+		t.Errorf("Expected an existing directory to be left alone")
+	}
+	if _, err := CurrentRelease(dirPath); err == nil {
+		t.Errorf("Expected a directory not to be read as a release symlink")
+	}
+}
+
+func TestListAndPruneReleases(t *testing.T) {
+	releasesDir := filepath.Join(t.TempDir(), "releases")
+	if releases, err := ListReleases(releasesDir); err != nil || len(releases) != 0 {
+		t.Errorf("Expected no releases before the first deploy, got %v (%v)", releases, err)
+	}
+
+	makeReleases(t, releasesDir, "r1", "r2", "r3", "r4", ".r5-partial")
+	releases, err := ListReleases(releasesDir)
+	if err != nil {
+		t.Fatalf("ListReleases failed: %v", err)
+	}
+	var names []string
+	for _, release := range releases {
+		names = append(names, release.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"r1", "r2", "r3", "r4"}) {
+		t.Errorf("Expected releases oldest first without partial ones, got %v", names)
+	}
+
+	if previous := PreviousRelease(releases, "r3"); previous != "r2" {
+		t.Errorf("Expected r2 before r3, got %q", previous)
+	}
+	if previous := PreviousRelease(releases, "r1"); previous != "" {
+		t.Errorf("Expected nothing before the oldest release, got %q", previous)
+	}
+
+	// The current release is kept even though it is the oldest
+	removed, err := PruneReleases(releasesDir, 1, "r1")
+	if err != nil {
+		t.Fatalf("PruneReleases failed: %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{"r2", "r3"}) {
+		t.Errorf("Expected r2 and r3 to be removed, got %v", removed)
+	}
+	for name, exists := range map[string]bool{"r1": true, "r2": false, "r3": false, "r4": true} {
+		if _, err := os.Stat(filepath.Join(releasesDir, name)); (err == nil) != exists {
+			t.Errorf("Expected %s to exist: %v", name, exists)
+		}
+	}
+}
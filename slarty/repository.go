@@ -1,18 +1,23 @@
 package slarty
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	awscredentials "github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
@@ -21,63 +26,302 @@ import (
 // preventing an operation from hanging indefinitely.
 const s3OperationTimeout = 30 * time.Minute
 
-// RepositoryAdapter defines the interface for repository adapters
-type RepositoryAdapter interface {
-	// StoreArtifact stores an artifact in the repository
-	StoreArtifact(artifactPath, artifactName string) error
+// s3MetadataTimeout bounds S3 operations that only look an artifact up, which never
+// need long
+const s3MetadataTimeout = time.Minute
+
+// s3PartSize is the size of each part of a streamed S3 upload. Archives smaller than
+// this are uploaded with a single request.
+const s3PartSize = 16 << 20 // 16 MiB
 
+// ArtifactChecker is implemented by anything that can tell whether an artifact has
+// already been built and stored
+type ArtifactChecker interface {
 	// ArtifactExists checks if an artifact exists in the repository
 	ArtifactExists(artifactName string) (bool, error)
+}
+
+// RepositoryAdapter defines the interface for repository adapters. Archives are
+// streamed in and out so they never need to be held on disk or in memory in full.
+type RepositoryAdapter interface {
+	ArtifactChecker
+
+	// StoreArtifact stores the archive read from r in the repository
+	StoreArtifact(r io.Reader, artifactName string) error
+
+	// RetrieveArtifact writes an archive from the repository to w
+	RetrieveArtifact(artifactName string, w io.Writer) error
+}
+
+// StoreArtifactFile stores the archive at artifactPath in the repository
+func StoreArtifactFile(repo RepositoryAdapter, artifactPath, artifactName string) error {
+	source, err := os.Open(artifactPath)
+	if err != nil {
+		return fmt.Errorf("failed to open artifact file: %w", err)
+	}
+	defer source.Close()
+
+	return repo.StoreArtifact(source, artifactName)
+}
+
+// RetrieveArtifactFile retrieves an archive from the repository into destinationPath
+func RetrieveArtifactFile(repo RepositoryAdapter, artifactName, destinationPath string) error {
+	// Ensure destination directory exists
+	if err := os.MkdirAll(filepath.Dir(destinationPath), 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	destination, err := os.Create(destinationPath)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+
+	err = repo.RetrieveArtifact(artifactName, destination)
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(destinationPath)
+	}
+
+	return err
+}
+
+// ArtifactSizer is implemented by repository adapters that can report the size of a
+// stored artifact without retrieving it
+type ArtifactSizer interface {
+	// ArtifactSize returns the size in bytes of an artifact in the repository
+	ArtifactSize(artifactName string) (int64, error)
+}
 
-	// RetrieveArtifact retrieves an artifact from the repository
-	RetrieveArtifact(artifactName, destinationPath string) error
+// ArtifactComparer is implemented by repository adapters that can tell whether a stored
+// artifact has the same content as a local archive without retrieving it
+type ArtifactComparer interface {
+	// SameContent reports whether the stored artifact is identical to the archive read
+	// from r. It reports false when the repository has nothing to compare against.
+	SameContent(artifactName string, r io.Reader) (bool, error)
 }
 
-// NewRepositoryAdapter creates a new repository adapter based on the configuration
+// ArtifactPrefetcher is implemented by repository adapters that can find out whether
+// many artifacts exist in fewer requests than looking each one up
+type ArtifactPrefetcher interface {
+	// PrefetchArtifacts finds out which of the artifacts exist, so that ArtifactExists
+	// answers for them without a request of its own. It is only an optimization: any
+	// artifact it could not find out about is looked up as usual.
+	PrefetchArtifacts(artifactNames []string)
+}
+
+// PrefetchArtifacts lets repo find out whether the artifacts exist in bulk, ahead of
+// checking each one, when it can
+func PrefetchArtifacts(repo ArtifactChecker, artifactNames []string) {
+	if prefetcher, ok := repo.(ArtifactPrefetcher); ok {
+		prefetcher.PrefetchArtifacts(artifactNames)
+	}
+}
+
+// ErrListingUnsupported is returned when listing a repository that cannot be listed
+var ErrListingUnsupported = errors.New("repository cannot list artifacts")
+
+// ArtifactLister is implemented by repository adapters that can list what they hold
+type ArtifactLister interface {
+	// ListArtifacts returns the names of the stored artifacts starting with prefix,
+	// in name order
+	ListArtifacts(prefix string) ([]string, error)
+}
+
+// ListArtifacts returns the names of the artifacts in repo starting with prefix when
+// repo can list them
+func ListArtifacts(repo RepositoryAdapter, prefix string) ([]string, error) {
+	lister, ok := repo.(ArtifactLister)
+	if !ok {
+		return nil, ErrListingUnsupported
+	}
+	return lister.ListArtifacts(prefix)
+}
+
+// NewRepositoryAdapter creates a new repository adapter based on the configuration.
+// Artifacts are kept below the repository's channel, when it has one, and the adapter
+// refuses to overwrite artifacts when the repository is marked immutable, or to change
+// anything at all when it is marked read-only.
 func NewRepositoryAdapter(config *ArtifactsConfig, useLocal bool) (RepositoryAdapter, error) {
+	if err := ValidateChannel(config.Repository.Channel); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+
+	repo, err := newRepositoryAdapter(config, useLocal)
+	if err != nil {
+		return nil, err
+	}
+	if config.Repository.Immutable {
+		repo = NewImmutableRepositoryAdapter(repo)
+	}
+	if config.Repository.ReadOnly {
+		repo = newReadOnlyRepositoryAdapter(repo)
+	}
+	return repo, nil
+}
+
+func newRepositoryAdapter(config *ArtifactsConfig, useLocal bool) (RepositoryAdapter, error) {
+	// Secrets are only fetched here, so they never appear in the resolved configuration,
+	// and before the paths are expanded, since a path may be a secret_ref
+	if problems := config.Repository.Options.CheckSecrets(); len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %w", ErrConfigInvalid, problems[0])
+	}
+	useLocal = useLocal || config.Offline
+	adapter := strings.ToLower(config.Repository.Adapter)
 	if useLocal {
-		// If local flag is set, use local repository adapter regardless of config
-		root := config.Repository.Options.Root
-		if root == "" {
-			return nil, errors.New("local repository root not specified")
-		}
-		return NewLocalRepositoryAdapter(root), nil
+		adapter = "local"
 	}
+	withSecrets := *config
+	options, err := config.Repository.Options.resolveSecrets(context.Background(), adapter, config.Offline)
+	if err != nil {
+		return nil, err
+	}
+	withSecrets.Repository.Options = options
 
-	adapterType := config.Repository.Adapter
-	switch adapterType {
-	case "Local", "local":
-		root := config.Repository.Options.Root
-		if root == "" {
-			return nil, errors.New("local repository root not specified")
-		}
-		return NewLocalRepositoryAdapter(root), nil
-	case "S3", "s3":
-		region := config.Repository.Options.Region
-		bucketName := config.Repository.Options.BucketName
-		pathPrefix := config.Repository.Options.PathPrefix
-		profile := config.Repository.Options.Profile
+	repository, err := withSecrets.ResolvedRepository(useLocal)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+
+	var credentials aws.CredentialsProvider
+	if repository.Options.AccessKeyID != "" {
+		credentials = awscredentials.NewStaticCredentialsProvider(repository.Options.AccessKeyID, repository.Options.SecretAccessKey, repository.Options.SessionToken)
+	}
 
-		if region == "" {
-			return nil, errors.New("S3 region not specified")
+	var repo RepositoryAdapter
+	switch repository.Adapter {
+	case "local":
+		if repository.Options.Root == "" && config.Offline {
+			return nil, fmt.Errorf("%w: offline, artifacts come from a local copy of the repository, but the repository root is not specified", ErrConfigInvalid)
+		}
+		if repository.Options.Root == "" {
+			return nil, fmt.Errorf("%w: local repository root not specified", ErrConfigInvalid)
 		}
-		if bucketName == "" {
-			return nil, errors.New("S3 bucket name not specified")
+		local := NewLocalRepositoryAdapter(repository.Options.Root)
+		local.pathName = config.artifactPathNamer()
+		repo = local
+	case "s3":
+		if repository.Options.Region == "" {
+			return nil, fmt.Errorf("%w: S3 region not specified", ErrConfigInvalid)
+		}
+		if repository.Options.BucketName == "" {
+			return nil, fmt.Errorf("%w: S3 bucket name not specified", ErrConfigInvalid)
 		}
 
-		adapter, err := NewS3RepositoryAdapter(region, bucketName, pathPrefix, profile)
+		adapter, err := NewS3RepositoryAdapter(repository.Options.Region, repository.Options.BucketName, repository.Options.PathPrefix, repository.Options.Profile, credentials)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create S3 repository adapter: %w", err)
+			return nil, fmt.Errorf("%w: failed to create S3 repository adapter: %w", ErrRepositoryUnavailable, err)
+		}
+		if repository.ExistsCacheTTL != "" {
+			ttl, err := time.ParseDuration(repository.ExistsCacheTTL)
+			if err != nil || ttl < 0 {
+				return nil, fmt.Errorf("%w: repository exists-cache-ttl %q is not a duration such as 10m", ErrConfigInvalid, repository.ExistsCacheTTL)
+			}
+			if ttl > 0 {
+				path, err := existsCachePath("s3://" + repository.Options.BucketName + "/" + repository.Options.PathPrefix)
+				if err != nil {
+					return nil, err
+				}
+				adapter.exists.keepOnDisk(path, ttl)
+			}
 		}
-		return adapter, nil
+		adapter.pathName = config.artifactPathNamer()
+		repo = adapter
 	default:
-		return nil, fmt.Errorf("unknown repository adapter type: %s", adapterType)
+		return nil, fmt.Errorf("%w: unknown repository adapter type: %s", ErrConfigInvalid, config.Repository.Adapter)
 	}
+
+	rate, err := ParseRate(repository.LimitRate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: repository limit-rate: %w", ErrConfigInvalid, err)
+	}
+	if rate > 0 {
+		repo = newRateLimitedRepositoryAdapter(repo, rate)
+	}
+
+	encryption := repository.Encryption
+	if encryption == nil {
+		return repo, nil
+	}
+	if err := encryption.Check(config.RootDirectory); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfigInvalid, err)
+	}
+	var keys dataKeySource
+	if encryption.KeyFile != "" {
+		key, err := readEncryptionKeyFile(encryption.keyFilePath(config.RootDirectory))
+		if err != nil {
+			return nil, err
+		}
+		if keys, err = newKeyFileKeys(key); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := offlineError(config.Offline, "AWS"); err != nil {
+			return nil, fmt.Errorf("%w: repository encryption with kms-key-id: %w", ErrRepositoryUnavailable, err)
+		}
+		cfg, err := loadAWSConfig(context.Background(), repository.Options.Region, repository.Options.Profile, credentials)
+		if err != nil {
+			return nil, fmt.Errorf("%w: repository encryption with kms-key-id: %w", ErrRepositoryUnavailable, err)
+		}
+		keys = &kmsKeys{client: kms.NewFromConfig(cfg), keyID: encryption.KMSKeyID}
+	}
+	return newEncryptedRepositoryAdapter(repo, keys, encryption.AllowUnencrypted), nil
+}
+
+// ResolvedRepository returns the repository settings an adapter is created with: the
+// adapter type in lower case, local when useLocal or Offline is set, and the local root or S3
+// path prefix with its placeholders expanded and the channel added
+func (ac *ArtifactsConfig) ResolvedRepository(useLocal bool) (Repository, error) {
+	repository := ac.Repository
+	if useLocal || ac.Offline {
+		// If local flag is set, or slarty is offline, use local repository adapter regardless of config
+		repository.Adapter = "Local"
+	}
+
+	switch repository.Adapter {
+	case "Local", "local":
+		repository.Adapter = "local"
+		if repository.Options.Root == "" {
+			return repository, nil
+		}
+		root, err := repositoryPath(ac, repository.Options.Root)
+		if err != nil {
+			return repository, err
+		}
+		repository.Options.Root = root
+	case "S3", "s3":
+		repository.Adapter = "s3"
+		pathPrefix, err := repositoryPath(ac, repository.Options.PathPrefix)
+		if err != nil {
+			return repository, err
+		}
+		repository.Options.PathPrefix = strings.TrimPrefix(pathPrefix, "/")
+	}
+	return repository, nil
+}
+
+// repositoryPath expands the placeholders in a local root or S3 path prefix and adds
+// the channel to the end, unless the path places it with {channel}
+func repositoryPath(config *ArtifactsConfig, template string) (string, error) {
+	channel := config.Repository.Channel
+	path, err := ExpandPathTemplate(template, config.Application, channel, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	if channel != "" && !HasChannelPlaceholder(template) {
+		path = strings.TrimRight(path, "/") + "/" + channel
+	}
+	return path, nil
 }
 
 // LocalRepositoryAdapter implements the RepositoryAdapter interface for local file system
 type LocalRepositoryAdapter struct {
+	// root may contain an {artifact} placeholder, expanded for each artifact
 	root string
+	// pathName is what {artifact} expands to for an artifact name, when that isn't
+	// artifactPathName
+	pathName func(artifactName string) string
 }
 
 // NewLocalRepositoryAdapter creates a new LocalRepositoryAdapter
@@ -87,80 +331,97 @@ func NewLocalRepositoryAdapter(root string) *LocalRepositoryAdapter {
 	}
 }
 
-// StoreArtifact stores an artifact in the local repository
-func (l *LocalRepositoryAdapter) StoreArtifact(artifactPath, artifactName string) error {
+// artifactPath returns the path of an artifact in the local repository
+func (l *LocalRepositoryAdapter) artifactPath(artifactName string) string {
+	return filepath.Join(filepath.FromSlash(expandArtifactPath(l.root, artifactName, l.pathName)), artifactName)
+}
+
+// StoreArtifact stores an artifact in the local repository. The archive is written to
+// a temporary file and renamed into place so a failed or interrupted store never
+// leaves a partial artifact behind.
+func (l *LocalRepositoryAdapter) StoreArtifact(r io.Reader, artifactName string) error {
+	destPath := l.artifactPath(artifactName)
+
 	// Ensure repository directory exists
-	err := os.MkdirAll(l.root, 0755)
+	err := os.MkdirAll(filepath.Dir(destPath), 0755)
 	if err != nil {
 		return fmt.Errorf("failed to create repository directory: %w", err)
 	}
 
-	// Open source file
-	source, err := os.Open(artifactPath)
-	if err != nil {
-		return fmt.Errorf("failed to open artifact file: %w", err)
-	}
-	defer source.Close()
-
-	// Create destination file
-	destPath := filepath.Join(l.root, artifactName)
-	destination, err := os.Create(destPath)
+	destination, err := os.CreateTemp(filepath.Dir(destPath), "."+filepath.Base(artifactName)+".*.tmp")
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
 	}
-	defer destination.Close()
+	tempPath := destination.Name()
 
-	// Copy the file
-	_, err = io.Copy(destination, source)
+	// Copy the archive
+	_, err = io.Copy(destination, r)
+	if closeErr := destination.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
+		os.Remove(tempPath)
 		return fmt.Errorf("failed to copy artifact to repository: %w", err)
 	}
 
+	if err := os.Rename(tempPath, destPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to move artifact into repository: %w", err)
+	}
+
 	return nil
 }
 
 // ArtifactExists checks if an artifact exists in the local repository
 func (l *LocalRepositoryAdapter) ArtifactExists(artifactName string) (bool, error) {
-	artifactPath := filepath.Join(l.root, artifactName)
-	_, err := os.Stat(artifactPath)
+	_, err := os.Stat(l.artifactPath(artifactName))
 	if os.IsNotExist(err) {
 		return false, nil
 	}
 	return err == nil, err
 }
 
-// RetrieveArtifact retrieves an artifact from the local repository
-func (l *LocalRepositoryAdapter) RetrieveArtifact(artifactName, destinationPath string) error {
-	// Check if artifact exists
-	artifactPath := filepath.Join(l.root, artifactName)
-	_, err := os.Stat(artifactPath)
+// ArtifactSize returns the size of an artifact in the local repository
+func (l *LocalRepositoryAdapter) ArtifactSize(artifactName string) (int64, error) {
+	info, err := os.Stat(l.artifactPath(artifactName))
 	if err != nil {
-		return fmt.Errorf("artifact not found in repository: %w", err)
+		return 0, missingArtifact(err)
 	}
+	return info.Size(), nil
+}
 
-	// Ensure destination directory exists
-	destDir := filepath.Dir(destinationPath)
-	err = os.MkdirAll(destDir, 0755)
+// SameContent compares the SHA-256 digest of the stored artifact with that of the
+// archive read from r
+func (l *LocalRepositoryAdapter) SameContent(artifactName string, r io.Reader) (bool, error) {
+	source, err := os.Open(l.artifactPath(artifactName))
 	if err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+		return false, missingArtifact(err)
+	}
+	defer source.Close()
+
+	stored := sha256.New()
+	if _, err := io.Copy(stored, source); err != nil {
+		return false, fmt.Errorf("failed to read artifact from repository: %w", err)
 	}
+	local := sha256.New()
+	if _, err := io.Copy(local, r); err != nil {
+		return false, fmt.Errorf("failed to read artifact: %w", err)
+	}
+
+	return bytes.Equal(stored.Sum(nil), local.Sum(nil)), nil
+}
 
+// RetrieveArtifact retrieves an artifact from the local repository
+func (l *LocalRepositoryAdapter) RetrieveArtifact(artifactName string, w io.Writer) error {
 	// Open source file
-	source, err := os.Open(artifactPath)
+	source, err := os.Open(l.artifactPath(artifactName))
 	if err != nil {
-		return fmt.Errorf("failed to open artifact file: %w", err)
+		return missingArtifact(err)
 	}
 	defer source.Close()
 
-	// Create destination file
-	destination, err := os.Create(destinationPath)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
-	}
-	defer destination.Close()
-
-	// Copy the file
-	_, err = io.Copy(destination, source)
+	// Copy the archive
+	_, err = io.Copy(w, source)
 	if err != nil {
 		return fmt.Errorf("failed to copy artifact from repository: %w", err)
 	}
@@ -168,86 +429,205 @@ func (l *LocalRepositoryAdapter) RetrieveArtifact(artifactName, destinationPath
 	return nil
 }
 
-// S3RepositoryAdapter implements the RepositoryAdapter interface for AWS S3
-type S3RepositoryAdapter struct {
-	client     *s3.Client
-	bucketName string
-	pathPrefix string
+// missingArtifact returns the error for an artifact in the local repository that could
+// not be opened, wrapping ErrArtifactNotFound when it is not there
+func missingArtifact(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrArtifactNotFound, err)
+	}
+	return fmt.Errorf("%w: failed to read artifact: %w", ErrRepositoryUnavailable, err)
 }
 
-// NewS3RepositoryAdapter creates a new S3RepositoryAdapter
-func NewS3RepositoryAdapter(region, bucketName, pathPrefix, profile string) (*S3RepositoryAdapter, error) {
-	// Create a context
-	ctx := context.Background()
-
-	// Load AWS configuration
-	//cfg, err := config.LoadDefaultConfig(ctx,
-	//	config.WithRegion(region),
-	//	config.WithSharedConfigProfile(profile),
-	//)
-
-	configurers := []func(*config.LoadOptions) error{
-		config.WithRegion(region),
+// ListArtifacts lists the artifacts in the local repository starting with prefix.
+// Files being written and the label files kept next to artifacts are left out.
+func (l *LocalRepositoryAdapter) ListArtifacts(prefix string) ([]string, error) {
+	namePrefix := prefix[:strings.LastIndex(prefix, "/")+1]
+	dir := filepath.Dir(l.artifactPath(prefix + "x"))
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository: %w", err)
 	}
 
-	if profile != "" {
-		configurers = append(configurers, config.WithSharedConfigProfile(profile))
+	var names []string
+	for _, entry := range entries {
+		name := namePrefix + entry.Name()
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || strings.HasSuffix(name, labelsSuffix) || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		names = append(names, name)
 	}
+	return names, nil
+}
 
-	cfg, err := config.LoadDefaultConfig(ctx, configurers...)
+// S3API is the part of the S3 API an S3RepositoryAdapter uses. *s3.Client implements
+// it, and tests can use a fake in its place.
+type S3API interface {
+	s3.ListObjectsV2APIClient
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
+	GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+}
+
+// S3RepositoryAdapter implements the RepositoryAdapter interface for AWS S3
+type S3RepositoryAdapter struct {
+	client     S3API
+	bucketName string
+	pathPrefix string
+	// pathName is what {artifact} expands to for an artifact name, when that isn't
+	// artifactPathName
+	pathName func(artifactName string) string
+	// exists saves asking S3 about the same artifact more than once
+	exists *existsCache
+}
 
+// NewS3RepositoryAdapter creates a new S3RepositoryAdapter. Credentials come from the
+// AWS credential chain unless credentials is given.
+func NewS3RepositoryAdapter(region, bucketName, pathPrefix, profile string, credentials aws.CredentialsProvider) (*S3RepositoryAdapter, error) {
+	cfg, err := loadAWSConfig(context.Background(), region, profile, credentials)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+		return nil, err
 	}
 
 	// Create S3 client
 	client := s3.NewFromConfig(cfg)
 
+	return NewS3RepositoryAdapterWithClient(client, bucketName, pathPrefix), nil
+}
+
+// NewS3RepositoryAdapterWithClient creates an S3RepositoryAdapter storing artifacts
+// through client, which can be an S3 client set up some other way, such as for an
+// S3-compatible server, or a fake in tests
+func NewS3RepositoryAdapterWithClient(client S3API, bucketName, pathPrefix string) *S3RepositoryAdapter {
 	return &S3RepositoryAdapter{
 		client:     client,
 		bucketName: bucketName,
 		pathPrefix: pathPrefix,
-	}, nil
+		exists:     newExistsCache(),
+	}
 }
 
-// getObjectKey returns the full S3 object key for an artifact
+// getObjectKey returns the full S3 object key for an artifact, expanding any
+// {artifact} placeholder in the path prefix
 func (s *S3RepositoryAdapter) getObjectKey(artifactName string) string {
-	if s.pathPrefix == "" {
+	pathPrefix := expandArtifactPath(s.pathPrefix, artifactName, s.pathName)
+	if pathPrefix == "" {
 		return artifactName
 	}
-	return strings.TrimRight(s.pathPrefix, "/") + "/" + artifactName
+	return strings.TrimRight(pathPrefix, "/") + "/" + artifactName
 }
 
-// StoreArtifact stores an artifact in the S3 repository
-func (s *S3RepositoryAdapter) StoreArtifact(artifactPath, artifactName string) error {
-	// Open source file
-	file, err := os.Open(artifactPath)
-	if err != nil {
-		return fmt.Errorf("failed to open artifact file: %w", err)
+// StoreArtifact stores an artifact in the S3 repository, remembering that it exists
+func (s *S3RepositoryAdapter) StoreArtifact(r io.Reader, artifactName string) error {
+	if err := s.upload(r, artifactName); err != nil {
+		s.exists.forget(artifactName)
+		return err
 	}
-	defer file.Close()
+	s.exists.record(artifactName, true)
+	return nil
+}
 
+// upload sends an archive to S3. The archive is read in parts of s3PartSize and sent
+// with a multipart upload, so only one part is held in memory at a time. Archives that
+// fit in a single part are sent with one PutObject. S3 is asked to record a SHA-256
+// checksum, which SameContent compares against.
+func (s *S3RepositoryAdapter) upload(r io.Reader, artifactName string) error {
 	// Create a context with a generous timeout
 	ctx, cancel := context.WithTimeout(context.Background(), s3OperationTimeout)
 	defer cancel()
 
-	// Upload the file to S3
-	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(s.bucketName),
-		Key:    aws.String(s.getObjectKey(artifactName)),
-		Body:   file,
+	key := s.getObjectKey(artifactName)
+	part := make([]byte, s3PartSize)
+
+	n, err := io.ReadFull(r, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// The whole archive fits in one part
+		_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:            aws.String(s.bucketName),
+			Key:               aws.String(key),
+			Body:              bytes.NewReader(part[:n]),
+			ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		})
+		if err != nil {
+			return s3Failure("upload artifact to S3", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read artifact: %w", err)
+	}
+
+	upload, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String(s.bucketName),
+		Key:               aws.String(key),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to upload artifact to S3: %w", err)
+		return s3Failure("start upload of artifact to S3", err)
+	}
+
+	abort := func(cause error) error {
+		// Use a fresh context so the abort still happens when ctx has timed out
+		abortCtx, abortCancel := context.WithTimeout(context.Background(), time.Minute)
+		defer abortCancel()
+		s.client.AbortMultipartUpload(abortCtx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucketName),
+			Key:      aws.String(key),
+			UploadId: upload.UploadId,
+		})
+		return cause
+	}
+
+	var completed []types.CompletedPart
+	for partNumber := int32(1); n > 0; partNumber++ {
+		result, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:            aws.String(s.bucketName),
+			Key:               aws.String(key),
+			UploadId:          upload.UploadId,
+			PartNumber:        aws.Int32(partNumber),
+			Body:              bytes.NewReader(part[:n]),
+			ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		})
+		if err != nil {
+			return abort(s3Failure("upload artifact to S3", err))
+		}
+		completed = append(completed, types.CompletedPart{ETag: result.ETag, PartNumber: aws.Int32(partNumber), ChecksumSHA256: result.ChecksumSHA256})
+
+		n, err = io.ReadFull(r, part)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return abort(fmt.Errorf("failed to read artifact: %w", err))
+		}
+	}
+
+	_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucketName),
+		Key:             aws.String(key),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return abort(s3Failure("complete upload of artifact to S3", err))
 	}
 
 	return nil
 }
 
-// ArtifactExists checks if an artifact exists in the S3 repository
+// ArtifactExists checks if an artifact exists in the S3 repository. Each artifact is
+// only looked up once, after which the answer is remembered.
 func (s *S3RepositoryAdapter) ArtifactExists(artifactName string) (bool, error) {
-	// Create a context with a generous timeout
-	ctx, cancel := context.WithTimeout(context.Background(), s3OperationTimeout)
+	if exists, found := s.exists.lookup(artifactName); found {
+		return exists, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3MetadataTimeout)
 	defer cancel()
 
 	// Check if the object exists in S3
@@ -258,49 +638,231 @@ func (s *S3RepositoryAdapter) ArtifactExists(artifactName string) (bool, error)
 
 	var notFound *types.NotFound
 	if errors.As(err, &notFound) {
+		s.exists.record(artifactName, false)
 		return false, nil
 	}
 	if err != nil {
-		return false, fmt.Errorf("failed to check if artifact exists in S3: %w", err)
+		return false, s3Failure("check if artifact exists in S3", err)
 	}
 
+	s.exists.record(artifactName, true)
 	return true, nil
 }
 
-// RetrieveArtifact retrieves an artifact from the S3 repository
-func (s *S3RepositoryAdapter) RetrieveArtifact(artifactName, destinationPath string) error {
-	// Create a context with a generous timeout
-	ctx, cancel := context.WithTimeout(context.Background(), s3OperationTimeout)
+// PrefetchArtifacts finds out which of the artifacts exist by listing the keys around
+// them, which takes fewer requests than looking each one up when there are many. The
+// artifacts stored under the same prefix are found with one listing of the part of
+// their keys they share. A listing stops after one page per two artifacts, leaving any
+// it has not reached to be looked up one at a time, so a prefix crowded with old
+// builds costs no more than looking the artifacts up would. Listing needs
+// s3:ListBucket; without it, every artifact is looked up.
+func (s *S3RepositoryAdapter) PrefetchArtifacts(artifactNames []string) {
+	groups := make(map[string][]string)
+	names := make(map[string]string)
+	for _, artifactName := range artifactNames {
+		if _, found := s.exists.lookup(artifactName); found {
+			continue
+		}
+		key := s.getObjectKey(artifactName)
+		names[key] = artifactName
+		directory := key[:strings.LastIndex(key, "/")+1]
+		groups[directory] = append(groups[directory], key)
+	}
+
+	for _, keys := range groups {
+		s.listArtifacts(keys, names)
+	}
+}
+
+// listArtifacts lists the keys sharing a directory and records which exist. names maps
+// each key to its artifact name.
+func (s *S3RepositoryAdapter) listArtifacts(keys []string, names map[string]string) {
+	pages := len(keys) / 2
+	if pages == 0 {
+		return
+	}
+
+	sort.Strings(keys)
+	first, last := keys[0], keys[len(keys)-1]
+	prefix := first
+	for !strings.HasPrefix(last, prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3MetadataTimeout)
 	defer cancel()
 
-	// Get the object from S3
-	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+	found := make(map[string]bool)
+	complete := false
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucketName),
+		Prefix: aws.String(prefix),
+	})
+	for page := 0; page < pages && !complete; page++ {
+		if !paginator.HasMorePages() {
+			complete = true
+			break
+		}
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			// Listing is only an optimization, so the artifacts are looked up instead
+			return
+		}
+		for _, object := range output.Contents {
+			key := aws.ToString(object.Key)
+			if key > last {
+				// Keys are listed in order, so every artifact has been passed
+				complete = true
+				break
+			}
+			if _, wanted := names[key]; wanted {
+				found[key] = true
+			}
+		}
+	}
+	if !complete && !paginator.HasMorePages() {
+		complete = true
+	}
+
+	for _, key := range keys {
+		// Without a complete listing, an artifact not seen yet may still come later
+		if found[key] || complete {
+			s.exists.record(names[key], found[key])
+		}
+	}
+}
+
+// ArtifactSize returns the size of an artifact in the S3 repository
+func (s *S3RepositoryAdapter) ArtifactSize(artifactName string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3MetadataTimeout)
+	defer cancel()
+
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(s.bucketName),
 		Key:    aws.String(s.getObjectKey(artifactName)),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to get artifact from S3: %w", err)
+		return 0, s3Failure("get artifact size from S3", err)
 	}
-	defer result.Body.Close()
 
-	// Ensure destination directory exists
-	destDir := filepath.Dir(destinationPath)
-	err = os.MkdirAll(destDir, 0755)
+	return aws.ToInt64(result.ContentLength), nil
+}
+
+// SameContent compares the SHA-256 checksum S3 recorded for the artifact when it was
+// uploaded with the checksum of the archive read from r. Artifacts uploaded without a
+// checksum never match.
+func (s *S3RepositoryAdapter) SameContent(artifactName string, r io.Reader) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s3MetadataTimeout)
+	defer cancel()
+
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(s.bucketName),
+		Key:          aws.String(s.getObjectKey(artifactName)),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create destination directory: %w", err)
+		return false, s3Failure("get artifact checksum from S3", err)
+	}
+	stored := aws.ToString(result.ChecksumSHA256)
+	if stored == "" {
+		return false, nil
 	}
 
-	// Create destination file
-	destination, err := os.Create(destinationPath)
+	checksum, err := s3Checksum(r, s3PartSize)
 	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+		return false, err
 	}
-	defer destination.Close()
 
-	// Copy the file
-	_, err = io.Copy(destination, result.Body)
+	return checksum == stored, nil
+}
+
+// s3Failure returns the error for an S3 request that failed, wrapping
+// ErrArtifactNotFound when the object is not there and ErrRepositoryUnavailable
+// otherwise
+func s3Failure(what string, err error) error {
+	var notFound *types.NotFound
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &notFound) || errors.As(err, &noSuchKey) {
+		return fmt.Errorf("%w: failed to %s: %w", ErrArtifactNotFound, what, err)
+	}
+	return fmt.Errorf("%w: failed to %s: %w", ErrRepositoryUnavailable, what, err)
+}
+
+// s3Checksum returns the SHA-256 checksum S3 records for an archive uploaded by
+// StoreArtifact in parts of partSize: the base64 digest of the archive when it fits in
+// one PutObject, otherwise the digest of the part digests followed by the part count
+func s3Checksum(r io.Reader, partSize int) (string, error) {
+	part := make([]byte, partSize)
+
+	n, err := io.ReadFull(r, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		sum := sha256.Sum256(part[:n])
+		return base64.StdEncoding.EncodeToString(sum[:]), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read artifact: %w", err)
+	}
+
+	digests := sha256.New()
+	parts := 0
+	for n > 0 {
+		sum := sha256.Sum256(part[:n])
+		digests.Write(sum[:])
+		parts++
+
+		n, err = io.ReadFull(r, part)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return "", fmt.Errorf("failed to read artifact: %w", err)
+		}
+	}
+
+	return fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(digests.Sum(nil)), parts), nil
+}
+
+// ListArtifacts lists the artifacts in the S3 repository starting with prefix. Listing
+// needs s3:ListBucket.
+func (s *S3RepositoryAdapter) ListArtifacts(prefix string) ([]string, error) {
+	keyPrefix := s.getObjectKey(prefix)
+
+	var names []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucketName),
+		Prefix: aws.String(keyPrefix),
+	})
+	for paginator.HasMorePages() {
+		ctx, cancel := context.WithTimeout(context.Background(), s3MetadataTimeout)
+		output, err := paginator.NextPage(ctx)
+		cancel()
+		if err != nil {
+			return nil, s3Failure("list artifacts in S3", err)
+		}
+		for _, object := range output.Contents {
+			names = append(names, prefix+strings.TrimPrefix(aws.ToString(object.Key), keyPrefix))
+		}
+	}
+	return names, nil
+}
+
+// RetrieveArtifact retrieves an artifact from the S3 repository
+func (s *S3RepositoryAdapter) RetrieveArtifact(artifactName string, w io.Writer) error {
+	// Create a context with a generous timeout
+	ctx, cancel := context.WithTimeout(context.Background(), s3OperationTimeout)
+	defer cancel()
+
+	// Get the object from S3
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    aws.String(s.getObjectKey(artifactName)),
+	})
+	if err != nil {
+		return s3Failure("get artifact from S3", err)
+	}
+	defer result.Body.Close()
+
+	// Copy the archive
+	_, err = io.Copy(w, result.Body)
 	if err != nil {
-		return fmt.Errorf("failed to copy artifact from S3: %w", err)
+		return fmt.Errorf("%w: failed to copy artifact from S3: %w", ErrRepositoryUnavailable, err)
 	}
 
 	return nil
@@ -1,9 +1,16 @@
 package slarty
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestLocalRepositoryAdapter(t *testing.T) {
@@ -34,7 +41,7 @@ func TestLocalRepositoryAdapter(t *testing.T) {
 
 	// Test StoreArtifact
 	t.Run("StoreArtifact", func(t *testing.T) {
-		err := adapter.StoreArtifact(artifactPath, "test-artifact.tar.gz")
+		err := StoreArtifactFile(adapter, artifactPath, "test-artifact.tar.gz")
 		if err != nil {
 			t.Fatalf("StoreArtifact failed: %v", err)
 		}
@@ -74,10 +81,25 @@ func TestLocalRepositoryAdapter(t *testing.T) {
 		}
 	})
 
+	// Test ArtifactSize
+	t.Run("ArtifactSize", func(t *testing.T) {
+		size, err := adapter.ArtifactSize("test-artifact.tar.gz")
+		if err != nil {
+			t.Fatalf("ArtifactSize failed: %v", err)
+		}
+		if size != int64(len(artifactContent)) {
+			t.Fatalf("Expected size %d, got %d", len(artifactContent), size)
+		}
+
+		if _, err := adapter.ArtifactSize("non-existing-artifact.tar.gz"); err == nil {
+			t.Fatalf("ArtifactSize did not fail for non-existing artifact")
+		}
+	})
+
 	// Test RetrieveArtifact
 	t.Run("RetrieveArtifact", func(t *testing.T) {
 		retrievePath := filepath.Join(tempDir, "retrieved-artifact.tar.gz")
-		err := adapter.RetrieveArtifact("test-artifact.tar.gz", retrievePath)
+		err := RetrieveArtifactFile(adapter, "test-artifact.tar.gz", retrievePath)
 		if err != nil {
 			t.Fatalf("RetrieveArtifact failed: %v", err)
 		}
@@ -97,11 +119,94 @@ func TestLocalRepositoryAdapter(t *testing.T) {
 		}
 
 		// Test retrieving non-existing artifact
-		err = adapter.RetrieveArtifact("non-existing-artifact.tar.gz", retrievePath)
+		err = RetrieveArtifactFile(adapter, "non-existing-artifact.tar.gz", retrievePath)
 		if err == nil {
 			t.Fatalf("RetrieveArtifact did not fail for non-existing artifact")
 		}
 	})
+
+	// Test streaming to and from the repository
+	t.Run("Streaming", func(t *testing.T) {
+		if err := adapter.StoreArtifact(strings.NewReader("streamed content"), "streamed.tar.gz"); err != nil {
+			t.Fatalf("StoreArtifact failed: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if err := adapter.RetrieveArtifact("streamed.tar.gz", &buf); err != nil {
+			t.Fatalf("RetrieveArtifact failed: %v", err)
+		}
+		if buf.String() != "streamed content" {
+			t.Fatalf("Expected streamed content, got %q", buf.String())
+		}
+	})
+
+	// A failed upload must not leave a partial artifact behind
+	t.Run("StoreArtifactReadError", func(t *testing.T) {
+		r := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("archive failed")))
+		if err := adapter.StoreArtifact(r, "partial.tar.gz"); err == nil {
+			t.Fatalf("StoreArtifact did not fail for a failing reader")
+		}
+
+		if exists, err := adapter.ArtifactExists("partial.tar.gz"); exists || err != nil {
+			t.Fatalf("StoreArtifact left a partial artifact in the repository")
+		}
+		entries, err := os.ReadDir(repoDir)
+		if err != nil {
+			t.Fatalf("Failed to read repo directory: %v", err)
+		}
+		for _, entry := range entries {
+			if strings.Contains(entry.Name(), "partial") {
+				t.Fatalf("StoreArtifact left %s in the repository", entry.Name())
+			}
+		}
+	})
+}
+
+func TestLocalRepositoryAdapterSameContent(t *testing.T) {
+	adapter := NewLocalRepositoryAdapter(t.TempDir())
+	if err := adapter.StoreArtifact(strings.NewReader("archive"), "a.tar.gz"); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+
+	if same, err := adapter.SameContent("a.tar.gz", strings.NewReader("archive")); !same || err != nil {
+		t.Errorf("Expected identical content to match, got %v (%v)", same, err)
+	}
+	if same, err := adapter.SameContent("a.tar.gz", strings.NewReader("changed")); same || err != nil {
+		t.Errorf("Expected different content not to match, got %v (%v)", same, err)
+	}
+	if _, err := adapter.SameContent("missing.tar.gz", strings.NewReader("archive")); err == nil {
+		t.Errorf("Expected an error for a missing artifact")
+	}
+}
+
+func TestS3Checksum(t *testing.T) {
+	digest := func(data []byte) []byte {
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+
+	// Smaller than a part: the digest of the whole archive, as PutObject records it
+	checksum, err := s3Checksum(strings.NewReader("abc"), 4)
+	if err != nil {
+		t.Fatalf("s3Checksum failed: %v", err)
+	}
+	if want := base64.StdEncoding.EncodeToString(digest([]byte("abc"))); checksum != want {
+		t.Errorf("Expected %s, got %s", want, checksum)
+	}
+
+	// Larger than a part: the digest of the part digests and the part count, as a
+	// multipart upload records it
+	checksum, err = s3Checksum(strings.NewReader("abcdefghij"), 4)
+	if err != nil {
+		t.Fatalf("s3Checksum failed: %v", err)
+	}
+	var parts []byte
+	for _, part := range []string{"abcd", "efgh", "ij"} {
+		parts = append(parts, digest([]byte(part))...)
+	}
+	if want := base64.StdEncoding.EncodeToString(digest(parts)) + "-3"; checksum != want {
+		t.Errorf("Expected %s, got %s", want, checksum)
+	}
 }
 
 func TestNewRepositoryAdapter(t *testing.T) {
@@ -110,21 +215,9 @@ func TestNewRepositoryAdapter(t *testing.T) {
 		config := &ArtifactsConfig{
 			Repository: Repository{
 				Adapter: "Local",
-				Options: struct {
-					Root       string `json:"root"`
-					Region     string `json:"region"`
-					BucketName string `json:"bucket-name"`
-					PathPrefix string `json:"path-prefix"`
-					Profile    string `json:"profile"`
-				}(struct {
-					Root       string `json:"root"`
-					Region     string `json:"region"`
-					BucketName string `json:"bucket_name"`
-					PathPrefix string `json:"path_prefix"`
-					Profile    string `json:"profile"`
-				}{
+				Options: RepositoryOptions{
 					Root: "/tmp/repo",
-				}),
+				},
 			},
 		}
 
@@ -142,21 +235,9 @@ func TestNewRepositoryAdapter(t *testing.T) {
 		config := &ArtifactsConfig{
 			Repository: Repository{
 				Adapter: "S3",
-				Options: struct {
-					Root       string `json:"root"`
-					Region     string `json:"region"`
-					BucketName string `json:"bucket-name"`
-					PathPrefix string `json:"path-prefix"`
-					Profile    string `json:"profile"`
-				}(struct {
-					Root       string `json:"root"`
-					Region     string `json:"region"`
-					BucketName string `json:"bucket_name"`
-					PathPrefix string `json:"path_prefix"`
-					Profile    string `json:"profile"`
-				}{
+				Options: RepositoryOptions{
 					Root: "/tmp/repo",
-				}),
+				},
 			},
 		}
 
@@ -178,22 +259,10 @@ func TestNewRepositoryAdapter(t *testing.T) {
 		config := &ArtifactsConfig{
 			Repository: Repository{
 				Adapter: "S3",
-				Options: struct {
-					Root       string `json:"root"`
-					Region     string `json:"region"`
-					BucketName string `json:"bucket-name"`
-					PathPrefix string `json:"path-prefix"`
-					Profile    string `json:"profile"`
-				}(struct {
-					Root       string `json:"root"`
-					Region     string `json:"region"`
-					BucketName string `json:"bucket_name"`
-					PathPrefix string `json:"path_prefix"`
-					Profile    string `json:"profile"`
-				}{
+				Options: RepositoryOptions{
 					Region:     "us-west-1",
 					BucketName: "test-bucket",
-				}),
+				},
 			},
 		}
 
@@ -224,20 +293,8 @@ func TestNewRepositoryAdapter(t *testing.T) {
 	t.Run("MissingRoot", func(t *testing.T) {
 		config := &ArtifactsConfig{
 			Repository: Repository{
-				"Local",
-				struct {
-					Root       string `json:"root"`
-					Region     string `json:"region"`
-					BucketName string `json:"bucket-name"`
-					PathPrefix string `json:"path-prefix"`
-					Profile    string `json:"profile"`
-				}(struct {
-					Root       string `json:"root"`
-					Region     string `json:"region"`
-					BucketName string `json:"bucket_name"`
-					PathPrefix string `json:"path_prefix"`
-					Profile    string `json:"profile"`
-				}{}),
+				Adapter: "Local",
+				Options: RepositoryOptions{},
 			},
 		}
 
@@ -0,0 +1,47 @@
+package slarty
+
+import (
+	"io"
+	"os"
+	"os/exec"
+)
+
+// CommandRunner runs the shell commands slarty is configured with: build commands,
+// and the restart and maintenance commands run around a deploy. It is an interface so
+// tests, and programs embedding slarty, can run them without starting processes.
+type CommandRunner interface {
+	// RunCommand runs command from dir with env added to the environment
+	RunCommand(dir, command string, env map[string]string) error
+}
+
+// ShellRunner runs commands with sh -c, writing their output to Stdout and Stderr, or
+// to the process's own stdout and stderr when they are nil
+type ShellRunner struct {
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// RunCommand runs command with sh -c from dir
+func (r ShellRunner) RunCommand(dir, command string, env map[string]string) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = os.Environ()
+		for key, value := range env {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if r.Stdout != nil {
+		cmd.Stdout = r.Stdout
+	}
+	if r.Stderr != nil {
+		cmd.Stderr = r.Stderr
+	}
+
+	return cmd.Run()
+}
+
+// ArtifactNamer names the archive for an artifact's current code, as GetArtifactName
+// does. It can be replaced to name artifacts without hashing a git checkout.
+type ArtifactNamer func(artifactName string, artifactsConfig *ArtifactsConfig) (string, error)
@@ -0,0 +1,81 @@
+package slarty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awscredentials "github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// TestS3Integration runs the S3 repository against a real S3-compatible server, such
+// as LocalStack or MinIO, when SLARTY_S3_ENDPOINT is set to its URL. The bucket,
+// SLARTY_S3_BUCKET or slarty-integration, is created if it does not exist. The
+// credentials come from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, defaulting to
+// LocalStack's test credentials.
+func TestS3Integration(t *testing.T) {
+	endpoint := os.Getenv("SLARTY_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("set SLARTY_S3_ENDPOINT to run against an S3-compatible server")
+	}
+	bucket := envOr("SLARTY_S3_BUCKET", "slarty-integration")
+	credentials := awscredentials.NewStaticCredentialsProvider(
+		envOr("AWS_ACCESS_KEY_ID", "test"), envOr("AWS_SECRET_ACCESS_KEY", "test"), "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	cfg, err := loadAWSConfig(ctx, envOr("AWS_REGION", "us-east-1"), "", credentials)
+	if err != nil {
+		t.Fatalf("loadAWSConfig failed: %v", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+		o.UsePathStyle = true
+	})
+
+	_, err = client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+	var owned *types.BucketAlreadyOwnedByYou
+	if err != nil && !errors.As(err, &owned) {
+		t.Fatalf("Failed to create bucket %s: %v", bucket, err)
+	}
+
+	// Keep each run's archives apart so runs against the same bucket don't interfere
+	prefix := fmt.Sprintf("integration/%d/{artifact}", time.Now().UnixNano())
+	repo := NewS3RepositoryAdapterWithClient(client, bucket, prefix)
+
+	checkS3Roundtrip(t, repo, "web-abc.tar.gz", []byte("a small archive"))
+	checkS3Roundtrip(t, repo, "web-def.tar.gz", []byte(strings.Repeat("0123456789abcdef", s3PartSize/16+1)))
+
+	exists, err := NewS3RepositoryAdapterWithClient(client, bucket, prefix).ArtifactExists("web-ghi.tar.gz")
+	if err != nil || exists {
+		t.Errorf("Expected a missing artifact not to exist, got %v, %v", exists, err)
+	}
+
+	if err := repo.LabelArtifact("web-abc.tar.gz", map[string]string{"branch": "main"}); err != nil {
+		t.Fatalf("LabelArtifact failed: %v", err)
+	}
+	labels, err := repo.ArtifactLabels("web-abc.tar.gz")
+	if err != nil || labels["branch"] != "main" {
+		t.Errorf("Expected the branch label back, got %v, %v", labels, err)
+	}
+
+	names, err := repo.ListArtifacts("web-")
+	if err != nil || strings.Join(names, ",") != "web-abc.tar.gz,web-def.tar.gz" {
+		t.Errorf("Expected both archives to be listed, got %v, %v", names, err)
+	}
+}
+
+// envOr returns the environment variable, or fallback when it is unset
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
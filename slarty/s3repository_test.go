@@ -0,0 +1,330 @@
+package slarty
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeS3Object is an object stored by fakeS3
+type fakeS3Object struct {
+	body     []byte
+	checksum string
+	tags     []types.Tag
+}
+
+// fakeS3 keeps objects in memory and records the calls made to it, recording SHA-256
+// checksums the way S3 does for single and multipart uploads
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]*fakeS3Object
+	// parts holds the parts of the multipart uploads in progress, by upload ID
+	parts   map[string][][]byte
+	uploads int
+	calls   []string
+	// failUploadPart makes UploadPart fail
+	failUploadPart error
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string]*fakeS3Object), parts: make(map[string][][]byte)}
+}
+
+func (f *fakeS3) called(name string) {
+	f.calls = append(f.calls, name)
+}
+
+func (f *fakeS3) count(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, call := range f.calls {
+		if call == name {
+			n++
+		}
+	}
+	return n
+}
+
+func fakeChecksum(body []byte) string {
+	sum := sha256.Sum256(body)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.called("PutObject")
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	object := &fakeS3Object{body: body}
+	if params.ChecksumAlgorithm == types.ChecksumAlgorithmSha256 {
+		object.checksum = fakeChecksum(body)
+	}
+	f.objects[aws.ToString(params.Key)] = object
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.called("CreateMultipartUpload")
+	f.uploads++
+	id := fmt.Sprintf("upload-%d", f.uploads)
+	f.parts[id] = nil
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+}
+
+func (f *fakeS3) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.called("UploadPart")
+	if f.failUploadPart != nil {
+		return nil, f.failUploadPart
+	}
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	id := aws.ToString(params.UploadId)
+	f.parts[id] = append(f.parts[id], body)
+	return &s3.UploadPartOutput{
+		ETag:           aws.String(fmt.Sprintf("etag-%d", aws.ToInt32(params.PartNumber))),
+		ChecksumSHA256: aws.String(fakeChecksum(body)),
+	}, nil
+}
+
+func (f *fakeS3) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.called("CompleteMultipartUpload")
+	id := aws.ToString(params.UploadId)
+	parts, ok := f.parts[id]
+	if !ok {
+		return nil, fmt.Errorf("no such upload %s", id)
+	}
+	if len(params.MultipartUpload.Parts) != len(parts) {
+		return nil, fmt.Errorf("expected %d parts, got %d", len(parts), len(params.MultipartUpload.Parts))
+	}
+
+	var body []byte
+	digests := sha256.New()
+	for _, part := range params.MultipartUpload.Parts {
+		data := parts[aws.ToInt32(part.PartNumber)-1]
+		if aws.ToString(part.ChecksumSHA256) != fakeChecksum(data) {
+			return nil, fmt.Errorf("part %d has the wrong checksum", aws.ToInt32(part.PartNumber))
+		}
+		body = append(body, data...)
+		sum := sha256.Sum256(data)
+		digests.Write(sum[:])
+	}
+	delete(f.parts, id)
+	f.objects[aws.ToString(params.Key)] = &fakeS3Object{
+		body:     body,
+		checksum: fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(digests.Sum(nil)), len(parts)),
+	}
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeS3) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.called("AbortMultipartUpload")
+	delete(f.parts, aws.ToString(params.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func (f *fakeS3) object(key *string) (*fakeS3Object, error) {
+	object, ok := f.objects[aws.ToString(key)]
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	return object, nil
+}
+
+func (f *fakeS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.called("HeadObject")
+	object, err := f.object(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	output := &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(object.body)))}
+	if params.ChecksumMode == types.ChecksumModeEnabled && object.checksum != "" {
+		output.ChecksumSHA256 = aws.String(object.checksum)
+	}
+	return output, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.called("GetObject")
+	object, err := f.object(params.Key)
+	if err != nil {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(object.body))}, nil
+}
+
+func (f *fakeS3) PutObjectTagging(ctx context.Context, params *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.called("PutObjectTagging")
+	object, err := f.object(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	object.tags = params.Tagging.TagSet
+	return &s3.PutObjectTaggingOutput{}, nil
+}
+
+func (f *fakeS3) GetObjectTagging(ctx context.Context, params *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.called("GetObjectTagging")
+	object, err := f.object(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetObjectTaggingOutput{TagSet: object.tags}, nil
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.called("ListObjectsV2")
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	output := &s3.ListObjectsV2Output{IsTruncated: aws.Bool(false)}
+	for _, key := range keys {
+		output.Contents = append(output.Contents, types.Object{Key: aws.String(key)})
+	}
+	return output, nil
+}
+
+// checkS3Roundtrip stores body as an artifact in repo and checks it can be found,
+// compared and read back
+func checkS3Roundtrip(t *testing.T, repo *S3RepositoryAdapter, artifactName string, body []byte) {
+	t.Helper()
+	if err := repo.StoreArtifact(bytes.NewReader(body), artifactName); err != nil {
+		t.Fatalf("StoreArtifact failed: %v", err)
+	}
+
+	exists, err := repo.ArtifactExists(artifactName)
+	if err != nil || !exists {
+		t.Errorf("Expected %s to exist, got %v, %v", artifactName, exists, err)
+	}
+	size, err := repo.ArtifactSize(artifactName)
+	if err != nil || size != int64(len(body)) {
+		t.Errorf("Expected a size of %d, got %d, %v", len(body), size, err)
+	}
+	same, err := repo.SameContent(artifactName, bytes.NewReader(body))
+	if err != nil || !same {
+		t.Errorf("Expected the stored checksum to match, got %v, %v", same, err)
+	}
+	same, err = repo.SameContent(artifactName, strings.NewReader("something else"))
+	if err != nil || same {
+		t.Errorf("Expected different content not to match, got %v, %v", same, err)
+	}
+
+	var retrieved bytes.Buffer
+	if err := repo.RetrieveArtifact(artifactName, &retrieved); err != nil {
+		t.Fatalf("RetrieveArtifact failed: %v", err)
+	}
+	if !bytes.Equal(retrieved.Bytes(), body) {
+		t.Errorf("Expected the stored archive back, got %d bytes", retrieved.Len())
+	}
+}
+
+func TestS3RepositoryAdapterWithClient(t *testing.T) {
+	client := newFakeS3()
+	repo := NewS3RepositoryAdapterWithClient(client, "builds", "shop/{artifact}/")
+
+	checkS3Roundtrip(t, repo, "web-abc.tar.gz", []byte("a small archive"))
+	if _, ok := client.objects["shop/web/web-abc.tar.gz"]; !ok {
+		t.Errorf("Expected the archive under the path prefix, got %v", client.calls)
+	}
+	if client.count("PutObject") != 1 || client.count("CreateMultipartUpload") != 0 {
+		t.Errorf("Expected a small archive to be sent with one PutObject, got %v", client.calls)
+	}
+	// Storing the archive recorded that it exists, so it is only looked up for its size
+	// and the two checksum comparisons
+	if client.count("HeadObject") != 3 {
+		t.Errorf("Expected HeadObject only for the size and checksums, got %v", client.calls)
+	}
+
+	exists, err := repo.ArtifactExists("web-def.tar.gz")
+	if err != nil || exists {
+		t.Errorf("Expected a missing artifact not to exist, got %v, %v", exists, err)
+	}
+	if err := repo.RetrieveArtifact("web-def.tar.gz", io.Discard); err == nil {
+		t.Errorf("Expected retrieving a missing artifact to fail")
+	}
+
+	if err := repo.LabelArtifact("web-abc.tar.gz", map[string]string{"branch": "main"}); err != nil {
+		t.Fatalf("LabelArtifact failed: %v", err)
+	}
+	labels, err := repo.ArtifactLabels("web-abc.tar.gz")
+	if err != nil || labels["branch"] != "main" {
+		t.Errorf("Expected the branch label back, got %v, %v", labels, err)
+	}
+
+	checkS3Roundtrip(t, repo, "web-def.tar.gz", []byte("another archive"))
+	names, err := repo.ListArtifacts("web-")
+	if err != nil || strings.Join(names, ",") != "web-abc.tar.gz,web-def.tar.gz" {
+		t.Errorf("Expected both archives to be listed, got %v, %v", names, err)
+	}
+}
+
+func TestS3RepositoryAdapterMultipartUpload(t *testing.T) {
+	client := newFakeS3()
+	repo := NewS3RepositoryAdapterWithClient(client, "builds", "")
+
+	body := bytes.Repeat([]byte("0123456789abcdef"), s3PartSize/16*2+1)
+	checkS3Roundtrip(t, repo, "web-abc.tar.gz", body)
+	if client.count("PutObject") != 0 || client.count("UploadPart") != 3 || client.count("CompleteMultipartUpload") != 1 {
+		t.Errorf("Expected a multipart upload of three parts, got %v", client.calls)
+	}
+}
+
+func TestS3RepositoryAdapterAbortsFailedUpload(t *testing.T) {
+	client := newFakeS3()
+	client.failUploadPart = errors.New("connection reset")
+	repo := NewS3RepositoryAdapterWithClient(client, "builds", "")
+
+	body := make([]byte, s3PartSize+1)
+	err := repo.StoreArtifact(bytes.NewReader(body), "web-abc.tar.gz")
+	if err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("Expected the upload to fail, got %v", err)
+	}
+	if client.count("AbortMultipartUpload") != 1 || len(client.parts) != 0 {
+		t.Errorf("Expected the multipart upload to be aborted, got %v", client.calls)
+	}
+
+	// The failed upload is not remembered as stored
+	exists, err := repo.ArtifactExists("web-abc.tar.gz")
+	if err != nil || exists {
+		t.Errorf("Expected the artifact not to exist, got %v, %v", exists, err)
+	}
+}
@@ -0,0 +1,212 @@
+package slarty
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// configSchema is the JSON Schema of artifacts.json
+//
+//go:embed artifacts.schema.json
+var configSchema []byte
+
+// ConfigSchema returns the JSON Schema of artifacts.json, for editors to validate and
+// complete the file with
+func ConfigSchema() []byte {
+	return configSchema
+}
+
+// schemaNode is the part of JSON Schema the config schema uses
+type schemaNode struct {
+	Ref                  string                 `json:"$ref"`
+	Description          string                 `json:"description"`
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*schemaNode `json:"properties"`
+	AdditionalProperties *schemaNode            `json:"additionalProperties"`
+	Items                *schemaNode            `json:"items"`
+	Required             []string               `json:"required"`
+	Enum                 []interface{}          `json:"enum"`
+	Pattern              string                 `json:"pattern"`
+	Minimum              *float64               `json:"minimum"`
+	Definitions          map[string]*schemaNode `json:"definitions"`
+
+	// never is set for the schema false, which nothing matches
+	never bool
+}
+
+// UnmarshalJSON reads a schema, which may also be true, matching anything, or false,
+// matching nothing
+func (n *schemaNode) UnmarshalJSON(data []byte) error {
+	var boolean bool
+	if json.Unmarshal(data, &boolean) == nil {
+		n.never = !boolean
+		return nil
+	}
+	type node schemaNode
+	return json.Unmarshal(data, (*node)(n))
+}
+
+// schemaTypes is the type keyword, which is either one type or a list of them
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// ValidateConfigSchema checks config data against the schema of artifacts.json and
+// returns the problems found, each naming the key it is about as a dotted path such as
+// artifacts[0].directories. It returns an error if data is not JSON.
+func ValidateConfigSchema(data []byte) ([]string, error) {
+	var root schemaNode
+	if err := json.Unmarshal(configSchema, &root); err != nil {
+		return nil, fmt.Errorf("invalid config schema: %w", err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	validateSchemaNode(&root, &root, value, "", &problems)
+	return problems, nil
+}
+
+// validateSchemaNode appends the ways value, at path, does not match node to problems.
+// References are resolved against the definitions of root.
+func validateSchemaNode(root, node *schemaNode, value interface{}, path string, problems *[]string) {
+	label := path
+	if label == "" {
+		label = "the configuration"
+	}
+	report := func(format string, a ...interface{}) {
+		*problems = append(*problems, label+" "+fmt.Sprintf(format, a...))
+	}
+
+	if node.Ref != "" {
+		name := strings.TrimPrefix(node.Ref, "#/definitions/")
+		definition, ok := root.Definitions[name]
+		if !ok {
+			report("refers to unknown schema %s", node.Ref)
+			return
+		}
+		node = definition
+	}
+	if node.never {
+		report("is not a recognized key")
+		return
+	}
+
+	if len(node.Type) > 0 && !matchesSchemaType(node.Type, value) {
+		report("must be %s, not %s", strings.Join(node.Type, " or "), jsonTypeName(value))
+		return
+	}
+	if len(node.Enum) > 0 && !containsValue(node.Enum, value) {
+		var allowed []string
+		for _, v := range node.Enum {
+			encoded, _ := json.Marshal(v)
+			allowed = append(allowed, string(encoded))
+		}
+		report("must be one of %s", strings.Join(allowed, ", "))
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		if node.Pattern != "" {
+			if pattern, err := regexp.Compile(node.Pattern); err == nil && !pattern.MatchString(v) {
+				// The description says what is allowed in words, which beats the pattern
+				if node.Description != "" {
+					description := strings.TrimSuffix(node.Description, ".")
+					report("%q is not valid: %s", v, strings.ToLower(description[:1])+description[1:])
+				} else {
+					report("%q does not match %s", v, node.Pattern)
+				}
+			}
+		}
+	case float64:
+		if node.Minimum != nil && v < *node.Minimum {
+			report("must be at least %v", *node.Minimum)
+		}
+	case []interface{}:
+		if node.Items != nil {
+			for i, item := range v {
+				validateSchemaNode(root, node.Items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range node.Required {
+			if _, ok := v[key]; !ok {
+				report("is missing %s", key)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := node.Properties[key]
+			if child == nil {
+				child = node.AdditionalProperties
+			}
+			if child != nil {
+				validateSchemaNode(root, child, v[key], joinKey(path, key), problems)
+			}
+		}
+	}
+}
+
+// matchesSchemaType reports whether value is one of the JSON Schema types
+func matchesSchemaType(types []string, value interface{}) bool {
+	for _, t := range types {
+		switch v := value.(type) {
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		default:
+			if t == jsonTypeName(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonTypeName returns the JSON Schema type of decoded JSON
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// containsValue reports whether values holds value
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package slarty
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigSchemaCoversConfig(t *testing.T) {
+	var root schemaNode
+	if err := json.Unmarshal(ConfigSchema(), &root); err != nil {
+		t.Fatalf("Failed to parse the config schema: %v", err)
+	}
+
+	// Every key slarty reads must be in the schema, or editors would reject it
+	var check func(node *schemaNode, typ reflect.Type, path string)
+	check = func(node *schemaNode, typ reflect.Type, path string) {
+		if node.Ref != "" {
+			node = root.Definitions[strings.TrimPrefix(node.Ref, "#/definitions/")]
+		}
+		for typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		switch typ.Kind() {
+		case reflect.Struct:
+			for key, field := range jsonFields(typ) {
+				child, ok := node.Properties[key]
+				if !ok {
+					t.Errorf("Expected the schema to describe %s", joinKey(path, key))
+					continue
+				}
+				check(child, field.Type, joinKey(path, key))
+			}
+		case reflect.Slice:
+			if node.Items == nil {
+				t.Errorf("Expected the schema to describe the items of %s", path)
+				return
+			}
+			check(node.Items, typ.Elem(), path+"[]")
+		}
+	}
+	check(&root, reflect.TypeOf(ArtifactsConfig{}), "")
+}
+
+func TestValidateConfigSchema(t *testing.T) {
+	data, err := os.ReadFile("../artifacts.json")
+	if err != nil {
+		t.Fatalf("Failed to read the example artifacts.json: %v", err)
+	}
+	problems, err := ValidateConfigSchema(data)
+	if err != nil {
+		t.Fatalf("ValidateConfigSchema failed: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Expected the example artifacts.json to match the schema, got %v", problems)
+	}
+
+	config := `{
+		"$schema": "./artifacts.schema.json",
+		"application": "Test",
+		"repository": {"adapter": "ftp", "options": {"bucket_name": "builds"}},
+		"artifacts": [
+			{"name": "api", "directories": "api", "mode": "0644"},
+			{"directories": ["web"], "deploy_strategy": "copy", "comment": "x"}
+		],
+		"extraction": {"max_files": -1},
+		"dirty_tree": "fail"
+	}`
+	problems, err = ValidateConfigSchema([]byte(config))
+	if err != nil {
+		t.Fatalf("ValidateConfigSchema failed: %v", err)
+	}
+	expected := []string{
+		"artifacts[0].directories must be array or null, not string",
+		"artifacts[1] is missing name",
+		"artifacts[1].comment is not a recognized key",
+		`artifacts[1].deploy_strategy must be one of "", "symlink"`,
+		"extraction.max_files must be at least 0",
+		`repository.adapter "ftp" is not valid: the kind of repository: local or s3`,
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Expected problems\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(problems, "\n"))
+	}
+
+	if _, err := ValidateConfigSchema([]byte(`{"application": `)); err == nil {
+		t.Errorf("Expected invalid JSON to be an error")
+	}
+}
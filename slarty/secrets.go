@@ -0,0 +1,281 @@
+package slarty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// secretRefPrefix starts a repository option that names a secret to fetch at runtime
+// rather than holding the value itself
+const secretRefPrefix = "secret_ref:"
+
+// Secret providers a secret_ref can name
+const (
+	SecretProviderEnv               = "env"
+	SecretProviderAWSSecretsManager = "aws-secretsmanager"
+	SecretProviderVault             = "vault"
+)
+
+// ErrSecretNotFound is returned when a secret_ref names a secret that does not exist
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretRef is a reference to a secret held outside artifacts.json, written as
+// secret_ref:<provider>:<name>, with an optional #<key> to pick one field of a secret
+// that holds JSON
+type SecretRef struct {
+	Provider string
+	Name     string
+	Key      string
+}
+
+// IsSecretRef reports whether value is a secret_ref rather than a plain value
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretRefPrefix)
+}
+
+// ParseSecretRef parses a secret_ref:<provider>:<name>[#<key>] value
+func ParseSecretRef(value string) (SecretRef, error) {
+	if !IsSecretRef(value) {
+		return SecretRef{}, fmt.Errorf("%q is not a secret_ref", value)
+	}
+	provider, rest, found := strings.Cut(strings.TrimPrefix(value, secretRefPrefix), ":")
+	if !found || rest == "" {
+		return SecretRef{}, fmt.Errorf("invalid secret_ref %q, expected secret_ref:<provider>:<name>", value)
+	}
+	ref := SecretRef{Provider: provider}
+	ref.Name, ref.Key, _ = strings.Cut(rest, "#")
+
+	switch provider {
+	case SecretProviderEnv:
+		if ref.Key != "" {
+			return SecretRef{}, fmt.Errorf("invalid secret_ref %q: environment variables have no keys", value)
+		}
+	case SecretProviderAWSSecretsManager:
+	case SecretProviderVault:
+		if ref.Key == "" {
+			return SecretRef{}, fmt.Errorf("invalid secret_ref %q: vault secrets need a #key naming the field to use", value)
+		}
+	default:
+		return SecretRef{}, fmt.Errorf("invalid secret_ref %q: unknown provider %q (expected %q, %q or %q)", value, provider, SecretProviderEnv, SecretProviderAWSSecretsManager, SecretProviderVault)
+	}
+	if ref.Name == "" {
+		return SecretRef{}, fmt.Errorf("invalid secret_ref %q: the secret's name is empty", value)
+	}
+	return ref, nil
+}
+
+// fetchAWSSecret returns the value of a secret in AWS Secrets Manager, read with the
+// repository's region and profile
+var fetchAWSSecret = func(ctx context.Context, region, profile, secretID string) (string, error) {
+	cfg, err := loadAWSConfig(ctx, region, profile, nil)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := secretsmanager.NewFromConfig(cfg).GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretID),
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.SecretString != nil {
+		return *output.SecretString, nil
+	}
+	return string(output.SecretBinary), nil
+}
+
+// fetchVaultSecret returns the data of a secret in HashiCorp Vault, read from VAULT_ADDR
+// with VAULT_TOKEN. Version 2 key/value secrets keep their data one level further down,
+// which is unwrapped.
+func fetchVaultSecret(ctx context.Context, path string) (map[string]interface{}, error) {
+	address := os.Getenv("VAULT_ADDR")
+	token := os.Getenv("VAULT_TOKEN")
+	if address == "" || token == "" {
+		return nil, errors.New("VAULT_ADDR and VAULT_TOKEN must be set to read secrets from vault")
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(address, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		request.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrSecretNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, fmt.Errorf("vault returned %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("invalid response from vault: %w", err)
+	}
+	if inner, ok := secret.Data["data"].(map[string]interface{}); ok {
+		if _, versioned := secret.Data["metadata"]; versioned {
+			return inner, nil
+		}
+	}
+	return secret.Data, nil
+}
+
+// Resolve fetches the secret. region and profile are those of the repository, used to
+// reach AWS Secrets Manager. Offline, only environment variables can be read.
+func (ref SecretRef) Resolve(ctx context.Context, region, profile string, offline bool) (string, error) {
+	switch ref.Provider {
+	case SecretProviderEnv:
+		value, found := os.LookupEnv(ref.Name)
+		if !found {
+			return "", fmt.Errorf("environment variable %s: %w", ref.Name, ErrSecretNotFound)
+		}
+		return value, nil
+	case SecretProviderAWSSecretsManager:
+		if err := offlineError(offline, "AWS"); err != nil {
+			return "", fmt.Errorf("failed to read %s from AWS Secrets Manager: %w", ref.Name, err)
+		}
+		value, err := fetchAWSSecret(ctx, region, profile, ref.Name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s from AWS Secrets Manager: %w", ref.Name, err)
+		}
+		if ref.Key == "" {
+			return value, nil
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &fields); err != nil {
+			return "", fmt.Errorf("secret %s is not JSON, so it has no key %s", ref.Name, ref.Key)
+		}
+		return secretField(ref, fields)
+	case SecretProviderVault:
+		if err := offlineError(offline, "vault"); err != nil {
+			return "", fmt.Errorf("failed to read %s from vault: %w", ref.Name, err)
+		}
+		fields, err := fetchVaultSecret(ctx, ref.Name)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s from vault: %w", ref.Name, err)
+		}
+		return secretField(ref, fields)
+	}
+	return "", fmt.Errorf("unknown secret provider %q", ref.Provider)
+}
+
+// secretField returns the field of a secret that ref's key names
+func secretField(ref SecretRef, fields map[string]interface{}) (string, error) {
+	value, found := fields[ref.Key]
+	if !found {
+		return "", fmt.Errorf("secret %s has no key %s: %w", ref.Name, ref.Key, ErrSecretNotFound)
+	}
+	if text, ok := value.(string); ok {
+		return text, nil
+	}
+	encoded, err := json.Marshal(value)
+	return string(encoded), err
+}
+
+// resolveSecrets returns the options with the secret_refs that the adapter uses replaced
+// by the secrets they name, so a local repository never reaches for S3 credentials. The
+// region and profile are resolved first, since reading from AWS Secrets Manager needs
+// them.
+func (o RepositoryOptions) resolveSecrets(ctx context.Context, adapter string, offline bool) (RepositoryOptions, error) {
+	resolve := func(name string, value *string) error {
+		if !IsSecretRef(*value) {
+			return nil
+		}
+		ref, err := ParseSecretRef(*value)
+		if err != nil {
+			return fmt.Errorf("repository option %s: %w", name, err)
+		}
+		// Until the region and profile are resolved, Secrets Manager uses the defaults
+		region, profile := o.Region, o.Profile
+		if IsSecretRef(region) {
+			region = ""
+		}
+		if IsSecretRef(profile) {
+			profile = ""
+		}
+		resolved, err := ref.Resolve(ctx, region, profile, offline)
+		if err != nil {
+			return fmt.Errorf("repository option %s: %w", name, err)
+		}
+		*value = resolved
+		return nil
+	}
+
+	for _, option := range o.secretFields() {
+		if (option.name == "root") != (adapter == "local") {
+			continue
+		}
+		if err := resolve(option.name, option.value); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+// secretOption is a repository option that may be a secret_ref
+type secretOption struct {
+	name  string
+	value *string
+}
+
+// secretFields returns the options that may be secret_refs, with the region and
+// profile first
+func (o *RepositoryOptions) secretFields() []secretOption {
+	return append([]secretOption{
+		{"region", &o.Region},
+		{"profile", &o.Profile},
+		{"root", &o.Root},
+		{"bucket-name", &o.BucketName},
+		{"path-prefix", &o.PathPrefix},
+	}, o.credentialFields()...)
+}
+
+// credentialFields returns the options that hold credentials, which must be secret_refs
+func (o *RepositoryOptions) credentialFields() []secretOption {
+	return []secretOption{
+		{"access-key-id", &o.AccessKeyID},
+		{"secret-access-key", &o.SecretAccessKey},
+		{"session-token", &o.SessionToken},
+	}
+}
+
+// CheckSecrets reports problems with the options' secret_refs without fetching them:
+// refs that do not parse, and credentials written into the file instead of referenced
+func (o RepositoryOptions) CheckSecrets() []error {
+	var problems []error
+	for _, option := range o.secretFields() {
+		if IsSecretRef(*option.value) {
+			if _, err := ParseSecretRef(*option.value); err != nil {
+				problems = append(problems, fmt.Errorf("repository option %s: %w", option.name, err))
+			}
+		}
+	}
+	for _, option := range o.credentialFields() {
+		if *option.value != "" && !IsSecretRef(*option.value) {
+			problems = append(problems, fmt.Errorf("repository option %s must be a secret_ref, so the credential is not committed in artifacts.json", option.name))
+		}
+	}
+	if (o.AccessKeyID == "") != (o.SecretAccessKey == "") {
+		problems = append(problems, errors.New("repository options access-key-id and secret-access-key must be set together"))
+	}
+	return problems
+}
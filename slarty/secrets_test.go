@@ -0,0 +1,163 @@
+package slarty
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSecretRef(t *testing.T) {
+	valid := map[string]SecretRef{
+		"secret_ref:env:BUCKET":                        {Provider: "env", Name: "BUCKET"},
+		"secret_ref:aws-secretsmanager:prod/slarty":    {Provider: "aws-secretsmanager", Name: "prod/slarty"},
+		"secret_ref:aws-secretsmanager:prod/slarty#id": {Provider: "aws-secretsmanager", Name: "prod/slarty", Key: "id"},
+		"secret_ref:vault:secret/data/slarty#key":      {Provider: "vault", Name: "secret/data/slarty", Key: "key"},
+	}
+	for value, expected := range valid {
+		ref, err := ParseSecretRef(value)
+		if err != nil || ref != expected {
+			t.Errorf("ParseSecretRef(%q) = %+v, %v, want %+v", value, ref, err, expected)
+		}
+	}
+
+	for _, value := range []string{
+		"builds",
+		"secret_ref:env",
+		"secret_ref:env:",
+		"secret_ref:env:BUCKET#key",
+		"secret_ref:vault:secret/data/slarty",
+		"secret_ref:aws-secretsmanager:#key",
+		"secret_ref:keychain:slarty",
+	} {
+		if _, err := ParseSecretRef(value); err == nil {
+			t.Errorf("Expected ParseSecretRef(%q) to fail", value)
+		}
+	}
+}
+
+func TestSecretRefResolve(t *testing.T) {
+	ctx := context.Background()
+
+	t.Setenv("SLARTY_TEST_BUCKET", "builds")
+	if value, err := (SecretRef{Provider: "env", Name: "SLARTY_TEST_BUCKET"}).Resolve(ctx, "", "", false); err != nil || value != "builds" {
+		t.Errorf("Expected the environment variable, got %q (%v)", value, err)
+	}
+	if _, err := (SecretRef{Provider: "env", Name: "SLARTY_TEST_MISSING"}).Resolve(ctx, "", "", false); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound for a missing variable, got %v", err)
+	}
+
+	original := fetchAWSSecret
+	defer func() { fetchAWSSecret = original }()
+	fetchAWSSecret = func(ctx context.Context, region, profile, secretID string) (string, error) {
+		if region != "us-east-1" || profile != "deploy" || secretID != "prod/slarty" {
+			t.Errorf("Unexpected Secrets Manager request for %s in %s with %s", secretID, region, profile)
+		}
+		return `{"id": "AKIAEXAMPLE", "port": 443}`, nil
+	}
+	if value, err := (SecretRef{Provider: "aws-secretsmanager", Name: "prod/slarty", Key: "id"}).Resolve(ctx, "us-east-1", "deploy", false); err != nil || value != "AKIAEXAMPLE" {
+		t.Errorf("Expected the secret's id, got %q (%v)", value, err)
+	}
+	if value, err := (SecretRef{Provider: "aws-secretsmanager", Name: "prod/slarty", Key: "port"}).Resolve(ctx, "us-east-1", "deploy", false); err != nil || value != "443" {
+		t.Errorf("Expected the secret's port as text, got %q (%v)", value, err)
+	}
+	if _, err := (SecretRef{Provider: "aws-secretsmanager", Name: "prod/slarty", Key: "other"}).Resolve(ctx, "us-east-1", "deploy", false); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound for a missing key, got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/slarty":
+			w.Write([]byte(`{"data": {"data": {"key": "from-v2"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/slarty":
+			w.Write([]byte(`{"data": {"key": "from-v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	for path, expected := range map[string]string{"secret/data/slarty": "from-v2", "kv/slarty": "from-v1"} {
+		if value, err := (SecretRef{Provider: "vault", Name: path, Key: "key"}).Resolve(ctx, "", "", false); err != nil || value != expected {
+			t.Errorf("Expected %s from vault, got %q (%v)", expected, value, err)
+		}
+	}
+	if _, err := (SecretRef{Provider: "vault", Name: "secret/data/missing", Key: "key"}).Resolve(ctx, "", "", false); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected ErrSecretNotFound for a missing vault secret, got %v", err)
+	}
+	t.Setenv("VAULT_TOKEN", "wrong")
+	if _, err := (SecretRef{Provider: "vault", Name: "kv/slarty", Key: "key"}).Resolve(ctx, "", "", false); err == nil {
+		t.Errorf("Expected a refused vault request to fail")
+	}
+}
+
+func TestRepositoryOptionsSecrets(t *testing.T) {
+	t.Setenv("SLARTY_TEST_REGION", "eu-west-1")
+	t.Setenv("SLARTY_TEST_KEY", "AKIAEXAMPLE")
+
+	original := fetchAWSSecret
+	defer func() { fetchAWSSecret = original }()
+	fetchAWSSecret = func(ctx context.Context, region, profile, secretID string) (string, error) {
+		// The region is resolved before the secrets that need it
+		if region != "eu-west-1" {
+			t.Errorf("Expected Secrets Manager to be reached in eu-west-1, got %q", region)
+		}
+		return "shh", nil
+	}
+
+	options := RepositoryOptions{
+		Root:            "secret_ref:env:SLARTY_TEST_UNSET",
+		Region:          "secret_ref:env:SLARTY_TEST_REGION",
+		BucketName:      "builds",
+		AccessKeyID:     "secret_ref:env:SLARTY_TEST_KEY",
+		SecretAccessKey: "secret_ref:aws-secretsmanager:prod/slarty",
+	}
+	if problems := options.CheckSecrets(); len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+	resolved, err := options.resolveSecrets(context.Background(), "s3", false)
+	if err != nil {
+		t.Fatalf("resolveSecrets failed: %v", err)
+	}
+	// The local root is not used by S3, so its unset variable does not matter
+	expected := RepositoryOptions{Root: options.Root, Region: "eu-west-1", BucketName: "builds", AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "shh"}
+	if resolved != expected {
+		t.Errorf("Expected %+v, got %+v", expected, resolved)
+	}
+	if _, err := options.resolveSecrets(context.Background(), "local", false); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected the local root's missing variable to be an error, got %v", err)
+	}
+
+	plain := RepositoryOptions{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret_ref:env:SLARTY_TEST_KEY", SessionToken: "secret_ref:keychain:token"}
+	if problems := plain.CheckSecrets(); len(problems) != 2 {
+		t.Errorf("Expected a plain credential and an invalid secret_ref to be reported, got %v", problems)
+	}
+	if problems := (RepositoryOptions{AccessKeyID: "secret_ref:env:SLARTY_TEST_KEY"}).CheckSecrets(); len(problems) != 1 {
+		t.Errorf("Expected an access key id without a secret access key to be reported, got %v", problems)
+	}
+}
+
+func TestNewRepositoryAdapterSecretRoot(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("SLARTY_TEST_ROOT", root)
+
+	config := &ArtifactsConfig{Repository: Repository{Adapter: "local", Channel: "main", Options: RepositoryOptions{Root: "secret_ref:env:SLARTY_TEST_ROOT"}}}
+	adapter, err := NewRepositoryAdapter(config, false)
+	if err != nil {
+		t.Fatalf("NewRepositoryAdapter failed: %v", err)
+	}
+	if local, ok := adapter.(*LocalRepositoryAdapter); !ok || local.root != filepath.Join(root, "main") {
+		t.Errorf("Expected a local repository in %s, got %+v", filepath.Join(root, "main"), adapter)
+	}
+	if config.Repository.Options.Root != "secret_ref:env:SLARTY_TEST_ROOT" {
+		t.Errorf("Expected the configuration to keep the secret_ref, got %s", config.Repository.Options.Root)
+	}
+}
@@ -0,0 +1,121 @@
+package slarty
+
+import "strings"
+
+// Selection describes which artifacts or assets a command acts on. An item is
+// selected when it matches Names (if any), is in one of Groups (if any), carries one
+// of Tags (if any), and is not matched by ExcludeNames or tagged with one of
+// ExcludeTags. The zero Selection selects everything.
+type Selection struct {
+	Names        *NameMatcher
+	ExcludeNames *NameMatcher
+	Groups       []string
+	Tags         []string
+	ExcludeTags  []string
+}
+
+// Includes reports whether an item with the given name, groups and tags is selected
+func (s Selection) Includes(name string, groups, tags []string) bool {
+	if s.ExcludeNames.Matches(name) || hasAnyTag(tags, s.ExcludeTags) {
+		return false
+	}
+
+	if !s.Names.Empty() && !s.Names.Matches(name) {
+		return false
+	}
+
+	if len(s.Groups) > 0 && !hasAnyTag(groups, s.Groups) {
+		return false
+	}
+
+	if len(s.Tags) > 0 && !hasAnyTag(tags, s.Tags) {
+		return false
+	}
+
+	return true
+}
+
+// Selectable is implemented by configuration entries that can be selected by name,
+// groups and tags
+type Selectable interface {
+	SelectionKey() (name string, groups, tags []string)
+}
+
+// SelectionKey returns the name, groups and tags used to select the artifact
+func (a ArtifactConfig) SelectionKey() (string, []string, []string) {
+	return a.Name, a.Groups, a.Tags
+}
+
+// SelectionKey returns the name, groups and tags used to select the asset
+func (a Asset) SelectionKey() (string, []string, []string) {
+	return a.Name, a.Groups, a.Tags
+}
+
+// Select returns the items included by s, keeping their order
+func Select[T Selectable](items []T, s Selection) []T {
+	var selected []T
+	for _, item := range items {
+		if s.Includes(item.SelectionKey()) {
+			selected = append(selected, item)
+		}
+	}
+
+	return selected
+}
+
+// Names returns the names of the items, keeping their order
+func Names[T Selectable](items []T) []string {
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i], _, _ = item.SelectionKey()
+	}
+
+	return names
+}
+
+// SelectArtifacts returns the selected artifacts in config order
+func (ac *ArtifactsConfig) SelectArtifacts(s Selection) []ArtifactConfig {
+	return Select(ac.Artifacts, s)
+}
+
+// SelectAssets returns the selected assets in config order
+func (ac *ArtifactsConfig) SelectAssets(s Selection) []Asset {
+	return Select(ac.Assets, s)
+}
+
+// GroupNames returns the groups the artifacts and assets belong to, in the order they
+// first appear
+func (ac *ArtifactsConfig) GroupNames() []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(groups []string) {
+		for _, group := range groups {
+			if key := strings.ToLower(group); !seen[key] {
+				seen[key] = true
+				names = append(names, group)
+			}
+		}
+	}
+	for _, artifact := range ac.Artifacts {
+		add(artifact.Groups)
+	}
+	for _, asset := range ac.Assets {
+		add(asset.Groups)
+	}
+
+	return names
+}
+
+// hasAnyTag reports whether tags, or groups, contains any of wanted, ignoring case
+func hasAnyTag(tags, wanted []string) bool {
+	for _, w := range wanted {
+		w = strings.TrimSpace(w)
+		for _, tag := range tags {
+			if strings.EqualFold(tag, w) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,263 @@
+package slarty
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelectAssetsWithExclusion(t *testing.T) {
+	// Create test assets
+	assets := []Asset{
+		{Name: "asset1", Filename: "file1.tar.gz", DeployLocation: "deploy/asset1"},
+		{Name: "asset2", Filename: "file2.tar.gz", DeployLocation: "deploy/asset2"},
+		{Name: "asset3", Filename: "file3.tar.gz", DeployLocation: "deploy/asset3"},
+	}
+	config := &ArtifactsConfig{Assets: assets}
+
+	// Test with no filter and no exclude
+	filtered := config.SelectAssets(Selection{})
+	if len(filtered) != 3 {
+		t.Errorf("Expected 3 assets with no filter and no exclude, got %d", len(filtered))
+	}
+
+	// Test with filter only
+	filtered = config.SelectAssets(Selection{Names: testMatcher(t, "asset1", "asset2")})
+	if len(filtered) != 2 {
+		t.Errorf("Expected 2 assets with filter, got %d", len(filtered))
+	}
+	if filtered[0].Name != "asset1" || filtered[1].Name != "asset2" {
+		t.Errorf("Expected filtered assets to be asset1 and asset2, got %s and %s", filtered[0].Name, filtered[1].Name)
+	}
+
+	// Test with exclude only
+	filtered = config.SelectAssets(Selection{ExcludeNames: testMatcher(t, "asset3")})
+	if len(filtered) != 2 {
+		t.Errorf("Expected 2 assets with exclude, got %d", len(filtered))
+	}
+	if filtered[0].Name != "asset1" || filtered[1].Name != "asset2" {
+		t.Errorf("Expected filtered assets to be asset1 and asset2, got %s and %s", filtered[0].Name, filtered[1].Name)
+	}
+
+	// Test with both filter and exclude
+	filtered = config.SelectAssets(Selection{Names: testMatcher(t, "asset1", "asset3"), ExcludeNames: testMatcher(t, "asset3")})
+	if len(filtered) != 1 {
+		t.Errorf("Expected 1 asset with filter and exclude, got %d", len(filtered))
+	}
+	if filtered[0].Name != "asset1" {
+		t.Errorf("Expected filtered asset to be asset1, got %s", filtered[0].Name)
+	}
+
+	// Test with case insensitivity
+	filtered = config.SelectAssets(Selection{Names: testMatcher(t, "ASSET1")})
+	if len(filtered) != 1 {
+		t.Errorf("Expected 1 asset with case-insensitive filter, got %d", len(filtered))
+	}
+	if filtered[0].Name != "asset1" {
+		t.Errorf("Expected filtered asset to be asset1, got %s", filtered[0].Name)
+	}
+}
+
+func TestSelectAssetsWithPatterns(t *testing.T) {
+	config := &ArtifactsConfig{Assets: []Asset{{Name: "api-v1"}, {Name: "api-v2"}, {Name: "web"}}}
+
+	// Globs select and exclude by wildcard
+	filtered := config.SelectAssets(Selection{Names: testMatcher(t, "api-*"), ExcludeNames: testMatcher(t, "*-v2")})
+	if len(filtered) != 1 || filtered[0].Name != "api-v1" {
+		t.Errorf("Expected only api-v1 with glob filter and exclude, got %+v", filtered)
+	}
+
+	// Regular expressions must match the whole name
+	regex, err := NewNameMatcher([]string{"api-v[12]"}, true)
+	if err != nil {
+		t.Fatalf("Failed to create regex matcher: %v", err)
+	}
+	filtered = config.SelectAssets(Selection{Names: regex})
+	if len(filtered) != 2 {
+		t.Errorf("Expected 2 assets with regex filter, got %d", len(filtered))
+	}
+}
+
+// testMatcher builds a glob matcher for the given patterns
+func testMatcher(t *testing.T, patterns ...string) *NameMatcher {
+	t.Helper()
+	matcher, err := NewNameMatcher(patterns, false)
+	if err != nil {
+		t.Fatalf("Failed to create matcher: %v", err)
+	}
+	return matcher
+}
+
+func TestSelectionTags(t *testing.T) {
+	config := &ArtifactsConfig{
+		Artifacts: []ArtifactConfig{
+			{Name: "api", Tags: []string{"backend", "php"}},
+			{Name: "worker", Tags: []string{"backend", "go"}},
+			{Name: "web", Tags: []string{"frontend"}},
+			{Name: "docs"},
+		},
+	}
+
+	names := func(artifacts []ArtifactConfig) string {
+		var n []string
+		for _, a := range artifacts {
+			n = append(n, a.Name)
+		}
+		return strings.Join(n, ",")
+	}
+
+	tests := []struct {
+		name      string
+		selection Selection
+		expected  string
+	}{
+		{"Everything", Selection{}, "api,worker,web,docs"},
+		{"Tag", Selection{Tags: []string{"backend"}}, "api,worker"},
+		{"TagCaseInsensitive", Selection{Tags: []string{"FRONTEND"}}, "web"},
+		{"AnyOfTags", Selection{Tags: []string{"php", "frontend"}}, "api,web"},
+		{"ExcludeTag", Selection{ExcludeTags: []string{"backend"}}, "web,docs"},
+		{"TagAndExcludeTag", Selection{Tags: []string{"backend"}, ExcludeTags: []string{"php"}}, "worker"},
+		{"TagAndName", Selection{Names: testMatcher(t, "w*"), Tags: []string{"backend"}}, "worker"},
+		{"TagAndExcludeName", Selection{ExcludeNames: testMatcher(t, "api"), Tags: []string{"backend"}}, "worker"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(config.SelectArtifacts(tt.selection)); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSelectionGroups(t *testing.T) {
+	config := &ArtifactsConfig{
+		Artifacts: []ArtifactConfig{
+			{Name: "web", Groups: []string{"frontend"}, Tags: []string{"node"}},
+			{Name: "admin", Groups: []string{"frontend", "internal"}},
+			{Name: "api", Groups: []string{"backend"}, Tags: []string{"php"}},
+			{Name: "docs"},
+		},
+		Assets: []Asset{
+			{Name: "fonts", Groups: []string{"Frontend"}},
+			{Name: "geoip", Groups: []string{"backend"}},
+		},
+	}
+
+	names := func(artifacts []ArtifactConfig) string {
+		var n []string
+		for _, a := range artifacts {
+			n = append(n, a.Name)
+		}
+		return strings.Join(n, ",")
+	}
+
+	tests := []struct {
+		name      string
+		selection Selection
+		expected  string
+	}{
+		{"Group", Selection{Groups: []string{"frontend"}}, "web,admin"},
+		{"AnyOfGroups", Selection{Groups: []string{"internal", "backend"}}, "admin,api"},
+		{"GroupCaseInsensitive", Selection{Groups: []string{"BACKEND"}}, "api"},
+		{"GroupAndExcludeName", Selection{Groups: []string{"frontend"}, ExcludeNames: testMatcher(t, "admin")}, "web"},
+		{"GroupAndTag", Selection{Groups: []string{"frontend"}, Tags: []string{"node"}}, "web"},
+		{"UnknownGroup", Selection{Groups: []string{"mobile"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(config.SelectArtifacts(tt.selection)); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	assets := config.SelectAssets(Selection{Groups: []string{"frontend"}})
+	if len(assets) != 1 || assets[0].Name != "fonts" {
+		t.Errorf("Expected the frontend assets, got %+v", assets)
+	}
+
+	if got := strings.Join(config.GroupNames(), ","); got != "frontend,internal,backend" {
+		t.Errorf("Expected each group once in config order, got %q", got)
+	}
+}
+
+func TestSelectByName(t *testing.T) {
+	// Create a test configuration
+	config := &ArtifactsConfig{
+		Artifacts: []ArtifactConfig{
+			{
+				Name:           "test-artifact",
+				Directories:    []string{"dir1", "dir2"},
+				Command:        "make test",
+				ArtifactPrefix: "test",
+			},
+			{
+				Name:           "another-artifact",
+				Directories:    []string{"dir3"},
+				Command:        "make another",
+				ArtifactPrefix: "another",
+			},
+			{
+				Name:           "third-artifact",
+				Directories:    []string{"dir4"},
+				Command:        "make third",
+				ArtifactPrefix: "third",
+			},
+		},
+	}
+
+	// Test with no filter
+	t.Run("NoFilter", func(t *testing.T) {
+		artifacts := Select(config.Artifacts, Selection{})
+		if len(artifacts) != 3 {
+			t.Fatalf("Expected 3 artifacts, got %d", len(artifacts))
+		}
+	})
+
+	// Test with a filter matching one artifact
+	t.Run("SingleFilter", func(t *testing.T) {
+		artifacts := Select(config.Artifacts, Selection{Names: testMatcher(t, "test-artifact")})
+		if len(artifacts) != 1 {
+			t.Fatalf("Expected 1 artifact, got %d", len(artifacts))
+		}
+		if artifacts[0].Name != "test-artifact" {
+			t.Fatalf("Expected artifact name 'test-artifact', got '%s'", artifacts[0].Name)
+		}
+	})
+
+	// Test with a filter matching multiple artifacts
+	t.Run("MultipleFilters", func(t *testing.T) {
+		artifacts := Select(config.Artifacts, Selection{Names: testMatcher(t, "test-artifact", "third-artifact")})
+		if len(artifacts) != 2 {
+			t.Fatalf("Expected 2 artifacts, got %d", len(artifacts))
+		}
+		// Check that the correct artifacts were returned
+		names := make(map[string]bool)
+		for _, a := range artifacts {
+			names[a.Name] = true
+		}
+		if !names["test-artifact"] || !names["third-artifact"] {
+			t.Fatalf("Expected artifacts 'test-artifact' and 'third-artifact', got %v", names)
+		}
+	})
+
+	// Test with a filter matching no artifacts
+	t.Run("NoMatchFilter", func(t *testing.T) {
+		artifacts := Select(config.Artifacts, Selection{Names: testMatcher(t, "non-existing")})
+		if len(artifacts) != 0 {
+			t.Fatalf("Expected 0 artifacts, got %d", len(artifacts))
+		}
+	})
+
+	// Test with case-insensitive matching
+	t.Run("CaseInsensitiveFilter", func(t *testing.T) {
+		artifacts := Select(config.Artifacts, Selection{Names: testMatcher(t, "TEST-ARTIFACT")})
+		if len(artifacts) != 1 {
+			t.Fatalf("Expected 1 artifact, got %d", len(artifacts))
+		}
+		if artifacts[0].Name != "test-artifact" {
+			t.Fatalf("Expected artifact name 'test-artifact', got '%s'", artifacts[0].Name)
+		}
+	})
+}
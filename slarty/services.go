@@ -0,0 +1,58 @@
+package slarty
+
+import (
+	"strings"
+)
+
+// DefaultRestartCommand restarts a service when restart_command is not configured
+const DefaultRestartCommand = "systemctl restart {service}"
+
+// Restart is a command run after a deploy to restart a service, or an artifact's own
+// restart command
+type Restart struct {
+	// Name is the service, or the artifact for an artifact's own command
+	Name    string
+	Command string
+}
+
+// RestartTemplate returns the command template used to restart the artifact's
+// services: the artifact's restart_command, then the top level one, then systemctl
+func (ac *ArtifactsConfig) RestartTemplate(artifact ArtifactConfig) string {
+	if artifact.RestartCommand != "" {
+		return artifact.RestartCommand
+	}
+	if ac.RestartCommand != "" {
+		return ac.RestartCommand
+	}
+	return DefaultRestartCommand
+}
+
+// Restarts returns the restarts to run after deploying artifacts. Each of an
+// artifact's services is restarted with its restart template, and an artifact with
+// a restart_command but no services runs that command as it is. A command needed by
+// several artifacts, such as restarting a shared php-fpm, is only run once.
+func (ac *ArtifactsConfig) Restarts(artifacts []ArtifactConfig) []Restart {
+	var restarts []Restart
+	seen := make(map[string]bool)
+	add := func(name, command string) {
+		if seen[command] {
+			return
+		}
+		seen[command] = true
+		restarts = append(restarts, Restart{Name: name, Command: command})
+	}
+
+	for _, artifact := range artifacts {
+		if len(artifact.Services) == 0 {
+			if artifact.RestartCommand != "" {
+				add(artifact.Name, artifact.RestartCommand)
+			}
+			continue
+		}
+		template := ac.RestartTemplate(artifact)
+		for _, service := range artifact.Services {
+			add(service, strings.ReplaceAll(template, "{service}", shellQuote(service)))
+		}
+	}
+	return restarts
+}
@@ -0,0 +1,42 @@
+package slarty
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRestarts(t *testing.T) {
+	config := &ArtifactsConfig{}
+	artifacts := []ArtifactConfig{
+		{Name: "web", Services: []string{"php-fpm", "nginx"}},
+		{Name: "api", Services: []string{"php-fpm"}},
+		{Name: "worker", RestartCommand: "supervisorctl restart worker:*"},
+		{Name: "assets"},
+	}
+
+	expected := []Restart{
+		{Name: "php-fpm", Command: "systemctl restart 'php-fpm'"},
+		{Name: "nginx", Command: "systemctl restart 'nginx'"},
+		{Name: "worker", Command: "supervisorctl restart worker:*"},
+	}
+	if restarts := config.Restarts(artifacts); !reflect.DeepEqual(restarts, expected) {
+		t.Errorf("Expected %v, got %v", expected, restarts)
+	}
+
+	// The top level template is used unless the artifact has its own
+	config.RestartCommand = "sudo service {service} reload"
+	artifacts[1].RestartCommand = "sudo systemctl reload {service}"
+	expected = []Restart{
+		{Name: "php-fpm", Command: "sudo service 'php-fpm' reload"},
+		{Name: "nginx", Command: "sudo service 'nginx' reload"},
+		{Name: "php-fpm", Command: "sudo systemctl reload 'php-fpm'"},
+		{Name: "worker", Command: "supervisorctl restart worker:*"},
+	}
+	if restarts := config.Restarts(artifacts); !reflect.DeepEqual(restarts, expected) {
+		t.Errorf("Expected %v, got %v", expected, restarts)
+	}
+
+	if restarts := config.Restarts(nil); len(restarts) != 0 {
+		t.Errorf("Expected no restarts without artifacts, got %v", restarts)
+	}
+}
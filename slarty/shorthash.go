@@ -0,0 +1,111 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrHashCollision is returned when a shortened hash names an artifact that was built
+// from different code
+var ErrHashCollision = errors.New("shortened hash collides with another build")
+
+// fullHashLabel is the label holding the full hash of an artifact stored under a
+// shortened one, so a collision can be told apart from the same build
+const fullHashLabel = "slarty-hash"
+
+// ValidateHashLength returns an error unless length is 0, for full hashes, or at
+// least long enough to make collisions unlikely
+func ValidateHashLength(length int) error {
+	if length != 0 && length < shortHashLength {
+		return fmt.Errorf("hash_length must be 0, for full hashes, or at least %d, got %d", shortHashLength, length)
+	}
+	return nil
+}
+
+// ShortHashLength returns how many characters hashes are shortened to, from
+// hash_length, or 0 when they are kept whole
+func (ac *ArtifactsConfig) ShortHashLength() (int, error) {
+	if err := ValidateHashLength(ac.HashLength); err != nil {
+		return 0, err
+	}
+	return ac.HashLength, nil
+}
+
+// ShortenHash returns the first length characters of a hash, keeping the dirty
+// marker of a build from a working tree with unstaged changes. A length of 0 keeps
+// the whole hash.
+func ShortenHash(hash string, length int) string {
+	base, dirty := strings.CutSuffix(hash, DirtyMarker)
+	if length <= 0 || len(base) <= length {
+		return hash
+	}
+	base = base[:length]
+	if dirty {
+		base += DirtyMarker
+	}
+	return base
+}
+
+// DisplayHash shortens a hash for display as artifact names shorten it
+func (ac *ArtifactsConfig) DisplayHash(hash string) string {
+	length, err := ac.ShortHashLength()
+	if err != nil {
+		return hash
+	}
+	return ShortenHash(hash, length)
+}
+
+// WithFullHashLabel returns labels with the artifact's full hash added when hashes
+// are shortened, for CheckHashCollision to compare against later. Otherwise labels
+// are returned as they are.
+func WithFullHashLabel(artifactsConfig *ArtifactsConfig, artifact ArtifactConfig, labels map[string]string) (map[string]string, error) {
+	length, err := artifactsConfig.ShortHashLength()
+	if err != nil || length == 0 || artifact.IsDocker() {
+		return labels, err
+	}
+
+	fullHash, err := ArtifactHash(artifact.Name, artifactsConfig)
+	if err != nil {
+		return nil, err
+	}
+	labelled := make(map[string]string, len(labels)+1)
+	for key, value := range labels {
+		labelled[key] = value
+	}
+	labelled[fullHashLabel] = fullHash
+	return labelled, nil
+}
+
+// CheckHashCollision returns an error wrapping ErrHashCollision when hashes are
+// shortened and the artifact stored as artifactName was built from code with a
+// different full hash than the artifact's code has now. The full hash is read from
+// the artifact's labels, so nothing is looked up when hashes are kept whole, and
+// artifacts stored without one, or in a repository without labels, pass.
+func CheckHashCollision(repo RepositoryAdapter, artifactsConfig *ArtifactsConfig, artifact ArtifactConfig, artifactName string) error {
+	length, err := artifactsConfig.ShortHashLength()
+	if err != nil || length == 0 || artifact.IsDocker() {
+		return err
+	}
+
+	labels, err := ArtifactLabels(repo, artifactName)
+	if errors.Is(err, ErrLabelsUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check %s for a hash collision: %w", artifactName, err)
+	}
+	stored, ok := labels[fullHashLabel]
+	if !ok {
+		return nil
+	}
+
+	fullHash, err := ArtifactHash(artifact.Name, artifactsConfig)
+	if err != nil {
+		return err
+	}
+	if stored != fullHash {
+		return fmt.Errorf("%w: %s was built from %s, not %s; raise hash_length", ErrHashCollision, artifactName, stored, fullHash)
+	}
+	return nil
+}
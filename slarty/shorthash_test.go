@@ -0,0 +1,146 @@
+package slarty
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShortenHash(t *testing.T) {
+	hash := "51286ac9e1f0d3b2a8c7e6f5d4c3b2a1f0e9d8c7"
+	tests := []struct {
+		hash   string
+		length int
+		want   string
+	}{
+		{hash, 0, hash},
+		{hash, 12, "51286ac9e1f0"},
+		{hash, 64, hash},
+		{hash + DirtyMarker, 8, "51286ac9" + DirtyMarker},
+	}
+	for _, tt := range tests {
+		if got := ShortenHash(tt.hash, tt.length); got != tt.want {
+			t.Errorf("ShortenHash(%q, %d) = %q, want %q", tt.hash, tt.length, got, tt.want)
+		}
+	}
+
+	for _, length := range []int{0, 7, 40} {
+		if err := ValidateHashLength(length); err != nil {
+			t.Errorf("Expected %d to be a valid hash length, got %v", length, err)
+		}
+	}
+	for _, length := range []int{-1, 1, 6} {
+		if err := ValidateHashLength(length); err == nil {
+			t.Errorf("Expected %d to be rejected", length)
+		}
+	}
+}
+
+func TestShortHashLength(t *testing.T) {
+	config := &ArtifactsConfig{HashLength: 10}
+	artifact := ArtifactConfig{Name: "web", ArtifactPrefix: "web"}
+	hash := "51286ac9e1f0d3b2a8c7e6f5d4c3b2a1f0e9d8c7"
+
+	name, err := ArtifactNameForHash(artifact, hash, config)
+	if err != nil || name != "web-51286ac9e1.tar.gz" {
+		t.Errorf("Expected web-51286ac9e1.tar.gz, got %s (%v)", name, err)
+	}
+	if got := HashFromArtifactName(artifact, name, config); got != "51286ac9e1" {
+		t.Errorf("Expected the shortened hash back, got %q", got)
+	}
+	if got := config.DisplayHash(hash); got != "51286ac9e1" {
+		t.Errorf("Expected the hash shortened for display, got %q", got)
+	}
+
+	config.HashLength = 8
+	if name, err := ArtifactNameForHash(artifact, hash, config); err != nil || name != "web-51286ac9.tar.gz" {
+		t.Errorf("Expected web-51286ac9.tar.gz, got %s (%v)", name, err)
+	}
+
+	// An invalid length is an error rather than a name
+	config.HashLength = 3
+	if _, err := ArtifactNameForHash(artifact, hash, config); err == nil {
+		t.Errorf("Expected an error for a hash_length of 3")
+	}
+}
+
+func TestCheckHashCollision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available, skipping test")
+	}
+
+	tempDir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v: %s", args, err, out)
+		}
+	}
+	git("init")
+	git("config", "user.email", "test@example.com")
+	git("config", "user.name", "Test User")
+	if err := os.MkdirAll(filepath.Join(tempDir, "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "web", "index.html"), []byte("<html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "-m", "Initial commit")
+
+	config := &ArtifactsConfig{RootDirectory: tempDir, HashLength: 7}
+	config.Artifacts = []ArtifactConfig{{Name: "web", ArtifactPrefix: "web", Directories: []string{"web"}}}
+	artifact := config.Artifacts[0]
+	repo := NewLocalRepositoryAdapter(filepath.Join(tempDir, "repo"))
+
+	artifactName, err := GetArtifactName("web", config)
+	if err != nil {
+		t.Fatalf("GetArtifactName failed: %v", err)
+	}
+	if err := repo.StoreArtifact(strings.NewReader("archive"), artifactName); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a full hash to compare there is nothing to tell
+	if err := CheckHashCollision(repo, config, artifact, artifactName); err != nil {
+		t.Errorf("Expected no collision without a full hash, got %v", err)
+	}
+
+	labels, err := WithFullHashLabel(config, artifact, map[string]string{"branch": "main"})
+	if err != nil {
+		t.Fatalf("WithFullHashLabel failed: %v", err)
+	}
+	fullHash, _ := ArtifactHash("web", config)
+	if labels[fullHashLabel] != fullHash || labels["branch"] != "main" {
+		t.Fatalf("Expected the full hash added to the labels, got %v", labels)
+	}
+	if err := repo.LabelArtifact(artifactName, labels); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckHashCollision(repo, config, artifact, artifactName); err != nil {
+		t.Errorf("Expected no collision for the same code, got %v", err)
+	}
+
+	// Another build whose hash starts the same way is a collision
+	labels[fullHashLabel] = strings.Repeat("0", 40)
+	if err := repo.LabelArtifact(artifactName, labels); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckHashCollision(repo, config, artifact, artifactName); !errors.Is(err, ErrHashCollision) {
+		t.Errorf("Expected ErrHashCollision, got %v", err)
+	}
+
+	// Full hashes never collide, so nothing is looked up
+	config.HashLength = 0
+	if err := CheckHashCollision(repo, config, artifact, artifactName); err != nil {
+		t.Errorf("Expected no check with full hashes, got %v", err)
+	}
+	if labels, _ := WithFullHashLabel(config, artifact, nil); labels != nil {
+		t.Errorf("Expected no labels added with full hashes, got %v", labels)
+	}
+}
@@ -0,0 +1,80 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOverSizeBudget is returned when an artifact's archive is larger than its max_size
+var ErrOverSizeBudget = errors.New("archive is larger than max_size")
+
+// ErrUnderSizeBudget is returned when an artifact's archive is smaller than its min_size
+var ErrUnderSizeBudget = errors.New("archive is smaller than min_size")
+
+// ErrEmptyArchive is returned when there are no files to archive for an artifact
+var ErrEmptyArchive = errors.New("nothing to archive")
+
+// ParseSize parses a size in bytes, such as 512, 80M or 1.5G, with suffixes in powers
+// of 1024. An empty size or 0 means no limit.
+func ParseSize(size string) (int64, error) {
+	bytes, err := parseByteCount(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q, expected bytes such as 512K, 80M or 1G", size)
+	}
+	return bytes, nil
+}
+
+// SizeBudget is the most an artifact's archive may be, the size over which a build
+// warns that it is getting large, and the least it may be, in bytes. Zero means no
+// limit.
+type SizeBudget struct {
+	Max  int64
+	Warn int64
+	Min  int64
+}
+
+// SizeBudget returns the artifact's max_size, warn_size and min_size
+func (a ArtifactConfig) SizeBudget() (SizeBudget, error) {
+	max, err := ParseSize(a.MaxSize)
+	if err != nil {
+		return SizeBudget{}, fmt.Errorf("%s max_size: %w", a.Name, err)
+	}
+	warn, err := ParseSize(a.WarnSize)
+	if err != nil {
+		return SizeBudget{}, fmt.Errorf("%s warn_size: %w", a.Name, err)
+	}
+	min, err := ParseSize(a.MinSize)
+	if err != nil {
+		return SizeBudget{}, fmt.Errorf("%s min_size: %w", a.Name, err)
+	}
+	if max > 0 && warn > max {
+		return SizeBudget{}, fmt.Errorf("%s warn_size %s is larger than its max_size %s", a.Name, a.WarnSize, a.MaxSize)
+	}
+	if max > 0 && min > max {
+		return SizeBudget{}, fmt.Errorf("%s min_size %s is larger than its max_size %s", a.Name, a.MinSize, a.MaxSize)
+	}
+	return SizeBudget{Max: max, Warn: warn, Min: min}, nil
+}
+
+// CheckSize returns an error wrapping ErrOverSizeBudget when an archive of size bytes
+// is larger than Max
+func (b SizeBudget) CheckSize(size int64) error {
+	if b.Max > 0 && size > b.Max {
+		return fmt.Errorf("%w (%s)", ErrOverSizeBudget, FormatBytes(b.Max))
+	}
+	return nil
+}
+
+// CheckMinimum returns an error wrapping ErrUnderSizeBudget when a finished archive of
+// size bytes is smaller than Min
+func (b SizeBudget) CheckMinimum(size int64) error {
+	if size < b.Min {
+		return fmt.Errorf("%w (%s): the archive is %s", ErrUnderSizeBudget, FormatBytes(b.Min), FormatBytes(size))
+	}
+	return nil
+}
+
+// OverWarning reports whether an archive of size bytes is larger than Warn
+func (b SizeBudget) OverWarning(size int64) bool {
+	return b.Warn > 0 && size > b.Warn
+}
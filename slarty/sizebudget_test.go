@@ -0,0 +1,48 @@
+package slarty
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSizeBudget(t *testing.T) {
+	budget, err := ArtifactConfig{Name: "web", MaxSize: "1M", WarnSize: "512K", MinSize: "1K"}.SizeBudget()
+	if err != nil {
+		t.Fatalf("SizeBudget failed: %v", err)
+	}
+	if budget.Max != 1<<20 || budget.Warn != 512<<10 || budget.Min != 1<<10 {
+		t.Fatalf("Expected max 1M, warn 512K and min 1K, got %+v", budget)
+	}
+	if err := budget.CheckMinimum(1<<10 - 1); !errors.Is(err, ErrUnderSizeBudget) {
+		t.Errorf("Expected ErrUnderSizeBudget, got %v", err)
+	}
+	if err := budget.CheckMinimum(1 << 10); err != nil {
+		t.Errorf("Expected an archive of exactly min_size to pass, got %v", err)
+	}
+	if err := budget.CheckSize(1 << 20); err != nil {
+		t.Errorf("Expected an archive of exactly max_size to pass, got %v", err)
+	}
+	if err := budget.CheckSize(1<<20 + 1); !errors.Is(err, ErrOverSizeBudget) {
+		t.Errorf("Expected ErrOverSizeBudget, got %v", err)
+	}
+	if budget.OverWarning(512<<10) || !budget.OverWarning(512<<10+1) {
+		t.Errorf("Expected only sizes over warn_size to warn")
+	}
+
+	// No budget never fails or warns
+	if err := (SizeBudget{}).CheckSize(1 << 40); err != nil || (SizeBudget{}).OverWarning(1<<40) {
+		t.Errorf("Expected no budget to allow any size")
+	}
+
+	for _, bad := range []ArtifactConfig{
+		{Name: "web", MaxSize: "lots"},
+		{Name: "web", WarnSize: "10MB"},
+		{Name: "web", MaxSize: "1M", WarnSize: "2M"},
+		{Name: "web", MinSize: "small"},
+		{Name: "web", MaxSize: "1M", MinSize: "2M"},
+	} {
+		if _, err := bad.SizeBudget(); err == nil {
+			t.Errorf("Expected %+v to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package slarty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownConfigKeys is returned when a config has keys slarty does not recognize
+var ErrUnknownConfigKeys = errors.New("unrecognized keys")
+
+// decodeConfig decodes the config at path from data into config. Unrecognized keys,
+// which are usually misspellings that would otherwise be silently ignored, are a
+// warning, or an error with opts.Strict. They are found by UnknownConfigKeys rather
+// than the decoder's DisallowUnknownFields, which stops at the first one and does not
+// know about deprecated keys.
+func decodeConfig(path string, data []byte, config *ArtifactsConfig, opts ReadOptions) error {
+	if err := json.Unmarshal(data, config); err != nil {
+		return err
+	}
+	if err := checkSchemaVersion(path, config.SchemaVersion); err != nil {
+		return err
+	}
+
+	keys, err := UnknownConfigKeys(data)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		if opts.Strict {
+			return fmt.Errorf("%s has %w: %s; fix their spelling, or leave out --strict to only warn about them", path, ErrUnknownConfigKeys, strings.Join(keys, ", "))
+		}
+		fmt.Fprintf(os.Stderr, "WARNING: %s has %v, which are ignored: %s\n", path, ErrUnknownConfigKeys, strings.Join(keys, ", "))
+	}
+
+	return applyDeprecatedKeys(path, data, config)
+}
+
+// UnknownConfigKeys returns the keys in the config data that slarty does not
+// recognize, as dotted paths such as repository.options.bucketName, with a suggestion
+// when a recognized key differs only in case or punctuation
+func UnknownConfigKeys(data []byte) ([]string, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	collectUnknownKeys(value, reflect.TypeOf(ArtifactsConfig{}), "", &keys)
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// collectUnknownKeys appends the keys in value, decoded JSON at prefix, that have no
+// field in the type t it is decoded into
+func collectUnknownKeys(value interface{}, t reflect.Type, prefix string, keys *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		for key, child := range object {
+			name := joinKey(prefix, key)
+			if _, deprecated := deprecatedConfigKeys[name]; deprecated {
+				continue
+			}
+			field, found := matchField(fields, key)
+			if !found {
+				if suggestion := suggestField(fields, key); suggestion != "" {
+					name += " (did you mean " + suggestion + "?)"
+				}
+				*keys = append(*keys, name)
+				continue
+			}
+			collectUnknownKeys(child, field.Type, name, keys)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			collectUnknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", prefix, i), keys)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, child := range object {
+			collectUnknownKeys(child, t.Elem(), joinKey(prefix, key), keys)
+		}
+	}
+}
+
+// jsonFields returns the fields of the struct type t by the key they are decoded from
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// matchField finds the field for key the way encoding/json does, preferring an exact
+// match and falling back to one that differs only in case
+func matchField(fields map[string]reflect.StructField, key string) (reflect.StructField, bool) {
+	if field, ok := fields[key]; ok {
+		return field, true
+	}
+	for name, field := range fields {
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// suggestField returns the recognized key that differs from key only in case, dashes
+// and underscores, such as bucket-name for bucketName, or ""
+func suggestField(fields map[string]reflect.StructField, key string) string {
+	normalize := strings.NewReplacer("-", "", "_", "")
+	for name := range fields {
+		if strings.EqualFold(normalize.Replace(name), normalize.Replace(key)) {
+			return name
+		}
+	}
+	return ""
+}
+
+// joinKey returns key below prefix as a dotted path
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
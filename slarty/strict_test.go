@@ -0,0 +1,67 @@
+package slarty
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnknownConfigKeys(t *testing.T) {
+	config := `{
+		"application": "Test",
+		"Root_Directory": ".",
+		"comment": "not a setting",
+		"repository": {
+			"adapter": "s3",
+			"options": {"bucketName": "builds", "REGION": "us-east-1"}
+		},
+		"artifacts": [
+			{"name": "api", "directories": ["api"], "container": {"imag": "x"}},
+			{"name": "web", "directory": ["web"], "env": {"ANY_NAME": "ok"}}
+		],
+		"pipelines": {"release": ["api"]}
+	}`
+
+	keys, err := UnknownConfigKeys([]byte(config))
+	if err != nil {
+		t.Fatalf("UnknownConfigKeys failed: %v", err)
+	}
+	expected := []string{
+		"artifacts[0].container.imag",
+		"artifacts[1].directory",
+		"comment",
+		"repository.options.bucketName (did you mean bucket-name?)",
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected unknown keys %v, got %v", expected, keys)
+	}
+}
+
+func TestReadArtifactsJsonStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "artifacts.json")
+	content := `{"application": "Test", "repository": {"adapter": "s3", "options": {"bucketName": "builds"}}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	_, err := ReadArtifactsJsonWithOptions(path, ReadOptions{Strict: true})
+	if !errors.Is(err, ErrUnknownConfigKeys) {
+		t.Fatalf("Expected ErrUnknownConfigKeys, got %v", err)
+	}
+	for _, want := range []string{"repository.options.bucketName", "did you mean bucket-name?", "--strict"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+
+	config, err := ReadArtifactsJson(path)
+	if err != nil {
+		t.Fatalf("Expected unknown keys to only be warned about by default, got %v", err)
+	}
+	if config.Application != "Test" || config.Repository.Adapter != "s3" {
+		t.Errorf("Expected the recognized keys to be read, got %+v", config)
+	}
+}
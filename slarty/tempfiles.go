@@ -0,0 +1,119 @@
+package slarty
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WorkDirName is the directory slarty keeps its working files in, under the project root
+const WorkDirName = ".slarty"
+
+// StaleTempFileAge is how old a temporary file must be before it is assumed to be left
+// over from a run that crashed and is removed. It is far longer than any run so files
+// in use by a concurrent run are never touched.
+const StaleTempFileAge = 24 * time.Hour
+
+// cleanedTempDirs records the temp directories already cleaned by this process
+var cleanedTempDirs sync.Map
+
+// TempDirectory returns the directory for temporary archives under the project root,
+// creating it if needed. The first time a process uses the directory, files older than
+// StaleTempFileAge are removed. A .gitignore keeps the work directory out of git.
+func TempDirectory(root string) (string, error) {
+	workDir := filepath.Join(root, WorkDirName)
+	tempDir := filepath.Join(workDir, "tmp")
+
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	gitignore := filepath.Join(workDir, ".gitignore")
+	if _, err := os.Stat(gitignore); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(gitignore, []byte("*\n"), 0644); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", gitignore, err)
+		}
+	}
+
+	if _, cleaned := cleanedTempDirs.LoadOrStore(tempDir, true); !cleaned {
+		if _, err := CleanStaleTempFiles(tempDir, StaleTempFileAge); err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: failed to clean up stale temp files: %v\n", err)
+		}
+	}
+
+	return tempDir, nil
+}
+
+// CreateTempArchive creates a new temporary file in the project's temp directory. The
+// pattern is used as in os.CreateTemp. The caller is responsible for removing the file.
+func CreateTempArchive(root, pattern string) (*os.File, error) {
+	tempDir, err := TempDirectory(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.CreateTemp(tempDir, pattern)
+}
+
+// CleanStaleTempFiles removes the entries in dir last modified more than maxAge ago and
+// returns how many were removed
+func CleanStaleTempFiles(dir string, maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			// Removed by another run since the directory was read
+			continue
+		}
+		if info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// CleanStalePartialFiles removes the files below dir that a store or cache write left
+// behind part way through, named like .name.*.tmp, once they were last modified more
+// than maxAge ago, and returns how many were removed. A missing dir has none.
+func CleanStalePartialFiles(dir string, maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".tmp") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
@@ -0,0 +1,118 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateTempArchive(t *testing.T) {
+	root := t.TempDir()
+
+	// A file left behind by a crashed run, and one from a run that is still going
+	tempDir := filepath.Join(root, WorkDirName, "tmp")
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	stale := filepath.Join(tempDir, "slarty-stale.tar.gz")
+	recent := filepath.Join(tempDir, "slarty-recent.tar.gz")
+	for _, path := range []string{stale, recent} {
+		if err := os.WriteFile(path, []byte("archive"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	old := time.Now().Add(-2 * StaleTempFileAge)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("Failed to age file: %v", err)
+	}
+
+	f, err := CreateTempArchive(root, "slarty-*.tar.gz")
+	if err != nil {
+		t.Fatalf("CreateTempArchive failed: %v", err)
+	}
+	f.Close()
+
+	if filepath.Dir(f.Name()) != tempDir || !strings.HasSuffix(f.Name(), ".tar.gz") {
+		t.Errorf("Expected the archive in %s, got %s", tempDir, f.Name())
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale temp file to be removed")
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("Expected the recent temp file to be kept: %v", err)
+	}
+
+	gitignore, err := os.ReadFile(filepath.Join(root, WorkDirName, ".gitignore"))
+	if err != nil || string(gitignore) != "*\n" {
+		t.Errorf("Expected a .gitignore ignoring the work directory, got %q, %v", gitignore, err)
+	}
+}
+
+func TestCleanStaleTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	for i, age := range []time.Duration{time.Minute, 2 * time.Hour, 3 * time.Hour} {
+		path := filepath.Join(dir, "file"+string(rune('a'+i)))
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		modified := time.Now().Add(-age)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatalf("Failed to age file: %v", err)
+		}
+	}
+
+	removed, err := CleanStaleTempFiles(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("CleanStaleTempFiles failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 files removed, got %d", removed)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "filea" {
+		t.Errorf("Expected only the recent file to remain, got %v", entries)
+	}
+}
+
+func TestCleanStalePartialFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	files := map[string]bool{
+		"a.tar.gz":                     false,
+		".a.tar.gz.123.tmp":            true,
+		"main/.b.tar.gz.456.tmp":       true,
+		"main/.c.tar.gz.789.tmp":       false,
+		"exists/.exists.1.tmp":         true,
+		"exists/0123456789abcdef.json": false,
+	}
+	for name, stale := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if stale || name == "a.tar.gz" {
+			os.Chtimes(path, old, old)
+		}
+	}
+
+	removed, err := CleanStalePartialFiles(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("CleanStalePartialFiles failed: %v", err)
+	}
+	if removed != 3 {
+		t.Errorf("Expected 3 files removed, got %d", removed)
+	}
+	for name, stale := range files {
+		if _, err := os.Stat(filepath.Join(dir, name)); os.IsNotExist(err) != stale {
+			t.Errorf("Expected %s removed to be %v", name, stale)
+		}
+	}
+
+	if removed, err := CleanStalePartialFiles(filepath.Join(dir, "missing"), time.Hour); removed != 0 || err != nil {
+		t.Errorf("Expected a missing directory to have nothing to clean, got %d, %v", removed, err)
+	}
+}
@@ -0,0 +1,44 @@
+package slarty
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ApplyVariant switches every artifact that defines variants over to the named
+// variant's command, and folds the variant name into its artifact prefix so each
+// variant is stored under its own archive name ({prefix}-{variant}-{hash}.tar.gz).
+// Artifacts without variants are left alone and shared by every variant. An empty
+// variant keeps each artifact's default command and prefix.
+func (ac *ArtifactsConfig) ApplyVariant(variant string) error {
+	if variant == "" {
+		return nil
+	}
+
+	for i, artifact := range ac.Artifacts {
+		if len(artifact.Variants) == 0 {
+			continue
+		}
+
+		command, ok := artifact.Variants[variant]
+		if !ok {
+			return fmt.Errorf("artifact %s has no variant %q (available: %v)", artifact.Name, variant, artifact.VariantNames())
+		}
+
+		ac.Artifacts[i].Command = command
+		ac.Artifacts[i].ArtifactPrefix = artifact.ArtifactPrefix + "-" + variant
+	}
+
+	return nil
+}
+
+// VariantNames returns the artifact's variant names in sorted order
+func (a ArtifactConfig) VariantNames() []string {
+	names := make([]string, 0, len(a.Variants))
+	for name := range a.Variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,57 @@
+package slarty
+
+import (
+	"testing"
+)
+
+func TestApplyVariant(t *testing.T) {
+	newConfig := func() *ArtifactsConfig {
+		return &ArtifactsConfig{
+			Artifacts: []ArtifactConfig{
+				{
+					Name:           "api",
+					Command:        "make api",
+					ArtifactPrefix: "api",
+					Variants: map[string]string{
+						"debug":   "make api DEBUG=1",
+						"release": "make api RELEASE=1",
+					},
+				},
+				{Name: "docs", Command: "make docs", ArtifactPrefix: "docs"},
+			},
+		}
+	}
+
+	t.Run("NoVariant", func(t *testing.T) {
+		config := newConfig()
+		if err := config.ApplyVariant(""); err != nil {
+			t.Fatalf("ApplyVariant failed: %v", err)
+		}
+		if config.Artifacts[0].Command != "make api" || config.Artifacts[0].ArtifactPrefix != "api" {
+			t.Fatalf("Expected default command and prefix, got %+v", config.Artifacts[0])
+		}
+	})
+
+	t.Run("Variant", func(t *testing.T) {
+		config := newConfig()
+		if err := config.ApplyVariant("debug"); err != nil {
+			t.Fatalf("ApplyVariant failed: %v", err)
+		}
+		if config.Artifacts[0].Command != "make api DEBUG=1" {
+			t.Errorf("Expected debug command, got %q", config.Artifacts[0].Command)
+		}
+		if config.Artifacts[0].ArtifactPrefix != "api-debug" {
+			t.Errorf("Expected variant folded into prefix, got %q", config.Artifacts[0].ArtifactPrefix)
+		}
+		if config.Artifacts[1].Command != "make docs" || config.Artifacts[1].ArtifactPrefix != "docs" {
+			t.Errorf("Expected artifact without variants to be unchanged, got %+v", config.Artifacts[1])
+		}
+	})
+
+	t.Run("UnknownVariant", func(t *testing.T) {
+		config := newConfig()
+		if err := config.ApplyVariant("profile"); err == nil {
+			t.Fatalf("ApplyVariant did not fail for an unknown variant")
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package slarty
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions compares two semantic versions such as "1.4.0" or "v1.4.0-rc.1",
+// returning -1, 0 or 1. Missing components count as 0 and a pre-release sorts before
+// the release it leads up to. ok is false when either version is not of this form,
+// such as a "dev" build.
+func CompareVersions(a, b string) (result int, ok bool) {
+	aParts, aPre, aOk := parseVersion(a)
+	bParts, bPre, bOk := parseVersion(b)
+	if !aOk || !bOk {
+		return 0, false
+	}
+
+	for i := 0; i < 3; i++ {
+		if aParts[i] != bParts[i] {
+			if aParts[i] < bParts[i] {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0, true
+	case aPre == "":
+		return 1, true
+	case bPre == "":
+		return -1, true
+	case aPre < bPre:
+		return -1, true
+	default:
+		return 1, true
+	}
+}
+
+// parseVersion splits a version into its major, minor and patch numbers and its
+// pre-release suffix. Build metadata after "+" is ignored.
+func parseVersion(version string) ([3]int, string, bool) {
+	var parts [3]int
+
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	version, _, _ = strings.Cut(version, "+")
+	version, pre, _ := strings.Cut(version, "-")
+	if version == "" {
+		return parts, "", false
+	}
+
+	numbers := strings.Split(version, ".")
+	if len(numbers) > 3 {
+		return parts, "", false
+	}
+	for i, number := range numbers {
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 0 {
+			return parts, "", false
+		}
+		parts[i] = n
+	}
+
+	return parts, pre, true
+}
@@ -0,0 +1,33 @@
+package slarty
+
+import (
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		result int
+		ok     bool
+	}{
+		{"1.2.3", "1.2.3", 0, true},
+		{"v1.2.3", "1.2.3", 0, true},
+		{"1.2", "1.2.0", 0, true},
+		{"1.2.3", "1.10.0", -1, true},
+		{"2.0.0", "1.99.99", 1, true},
+		{"1.4.0-rc.1", "1.4.0", -1, true},
+		{"1.4.0", "1.4.0-rc.1", 1, true},
+		{"1.4.0-rc.1", "1.4.0-rc.2", -1, true},
+		{"1.4.0+build.5", "1.4.0", 0, true},
+		{"dev", "1.0.0", 0, false},
+		{"1.0.0", "", 0, false},
+		{"1.2.3.4", "1.2.3", 0, false},
+	}
+
+	for _, tt := range tests {
+		result, ok := CompareVersions(tt.a, tt.b)
+		if result != tt.result || ok != tt.ok {
+			t.Errorf("CompareVersions(%q, %q) = %d, %t; want %d, %t", tt.a, tt.b, result, ok, tt.result, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package slarty
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// loadWorkspaces reads the artifacts.json of every workspace matched by the config's
+// workspace patterns and merges their artifacts and assets into the config. Entries
+// are namespaced with the workspace directory ("services/api/web") and their paths
+// and commands are rebased so they work from the top-level root directory. visited
+// holds the absolute paths of configs already read, to stop a workspace including
+// itself or its parent. The workspaces are read with opts.
+func (ac *ArtifactsConfig) loadWorkspaces(configDir string, visited map[string]bool, opts ReadOptions) error {
+	rootAbs, err := filepath.Abs(ac.RootDirectory)
+	if err != nil {
+		return err
+	}
+	configDirAbs, err := filepath.Abs(configDir)
+	if err != nil {
+		return err
+	}
+
+	var configs []string
+	for _, pattern := range ac.Workspaces {
+		matches, err := filepath.Glob(filepath.Join(configDirAbs, pattern))
+		if err != nil {
+			return fmt.Errorf("invalid workspace pattern %q: %w", pattern, err)
+		}
+		for _, match := range matches {
+			candidate := filepath.Join(match, "artifacts.json")
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() && !visited[candidate] {
+				visited[candidate] = true
+				configs = append(configs, candidate)
+			}
+		}
+	}
+	sort.Strings(configs)
+
+	for _, configPath := range configs {
+		workspace, err := readArtifactsJson(configPath, visited, opts)
+		if err != nil {
+			return fmt.Errorf("failed to read workspace %s: %w", configPath, err)
+		}
+
+		namespaceDir, err := filepath.Rel(configDirAbs, filepath.Dir(configPath))
+		if err != nil {
+			return err
+		}
+		namespace := filepath.ToSlash(namespaceDir)
+
+		workspaceRoot, err := filepath.Abs(workspace.RootDirectory)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(rootAbs, workspaceRoot)
+		if err != nil {
+			return err
+		}
+
+		for _, artifact := range workspace.Artifacts {
+			artifact.Name = path.Join(namespace, artifact.Name)
+			for i, dir := range artifact.Directories {
+				artifact.Directories[i] = filepath.Join(rel, dir)
+			}
+			artifact.OutputDirectory = artifact.OutputDirectory.Map(func(dir string) string {
+				return filepath.Join(rel, dir)
+			})
+			artifact.DeployLocation = filepath.Join(rel, artifact.DeployLocation)
+			if artifact.ReleasesDirectory != "" {
+				artifact.ReleasesDirectory = filepath.Join(rel, artifact.ReleasesDirectory)
+			}
+			if rel != "." {
+				if artifact.Command != "" {
+					artifact.Command = "cd " + shellQuote(rel) + " && " + artifact.Command
+				}
+				for variant, command := range artifact.Variants {
+					artifact.Variants[variant] = "cd " + shellQuote(rel) + " && " + command
+				}
+			}
+			ac.Artifacts = append(ac.Artifacts, artifact)
+		}
+
+		for _, asset := range workspace.Assets {
+			asset.Name = path.Join(namespace, asset.Name)
+			asset.DeployLocation = filepath.Join(rel, asset.DeployLocation)
+			ac.Assets = append(ac.Assets, asset)
+		}
+	}
+
+	return nil
+}
+
+// shellQuote quotes s for use as a single word in a sh command line
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
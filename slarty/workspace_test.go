@@ -0,0 +1,109 @@
+package slarty
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadArtifactsJsonWorkspaces(t *testing.T) {
+	root := t.TempDir()
+
+	write := func(rel, content string) {
+		t.Helper()
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", rel, err)
+		}
+	}
+
+	write("artifacts.json", `{
+		"application": "Mono",
+		"root_directory": "__DIR__",
+		"repository": {"adapter": "Local", "options": {"root": "/tmp/repo"}},
+		"workspaces": ["services/*", "."],
+		"artifacts": [
+			{"name": "shared", "directories": ["lib"], "command": "make lib", "output_directory": "lib/dist", "deploy_location": "deploy/lib", "artifact_prefix": "shared"}
+		]
+	}`)
+	write("services/api/artifacts.json", `{
+		"application": "API",
+		"root_directory": "__DIR__",
+		"repository": {"adapter": "s3", "options": {"bucket-name": "ignored"}},
+		"artifacts": [
+			{"name": "web", "directories": ["src"], "command": "make web", "output_directory": "dist", "deploy_location": "public", "artifact_prefix": "api-web"}
+		],
+		"assets": [
+			{"name": "fonts", "filename": "fonts.tar.gz", "deploy_location": "public/fonts"}
+		]
+	}`)
+	write("services/worker/artifacts.json", `{
+		"root_directory": "__DIR__",
+		"workspaces": ["../.."],
+		"artifacts": [
+			{"name": "jobs", "directories": ["jobs"], "command": "go build ./...", "output_directory": "bin", "deploy_location": "bin", "artifact_prefix": "worker-jobs"}
+		]
+	}`)
+	// Directories without an artifacts.json are skipped
+	write("services/docs/README.md", "docs")
+
+	config, err := ReadArtifactsJson(filepath.Join(root, "artifacts.json"))
+	if err != nil {
+		t.Fatalf("ReadArtifactsJson failed: %v", err)
+	}
+
+	if config.Application != "Mono" || config.Repository.Adapter != "Local" {
+		t.Fatalf("Expected the root config's application and repository, got %q and %q", config.Application, config.Repository.Adapter)
+	}
+
+	var names []string
+	for _, a := range config.Artifacts {
+		names = append(names, a.Name)
+	}
+	want := []string{"shared", "services/api/web", "services/worker/jobs"}
+	if len(names) != len(want) {
+		t.Fatalf("Expected artifacts %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Expected artifacts %v, got %v", want, names)
+		}
+	}
+
+	web, err := config.GetArtifactConfig("services/api/web")
+	if err != nil {
+		t.Fatalf("GetArtifactConfig failed: %v", err)
+	}
+	if web.Directories[0] != filepath.Join("services", "api", "src") {
+		t.Errorf("Expected rebased directory, got %q", web.Directories[0])
+	}
+	if web.OutputDirectory.String() != filepath.Join("services", "api", "dist") {
+		t.Errorf("Expected rebased output directory, got %q", web.OutputDirectory)
+	}
+	if web.DeployLocation != filepath.Join("services", "api", "public") {
+		t.Errorf("Expected rebased deploy location, got %q", web.DeployLocation)
+	}
+	if web.Command != "cd 'services/api' && make web" {
+		t.Errorf("Expected command to run from the workspace, got %q", web.Command)
+	}
+
+	if len(config.Assets) != 1 || config.Assets[0].Name != "services/api/fonts" {
+		t.Fatalf("Expected namespaced workspace asset, got %+v", config.Assets)
+	}
+	if config.Assets[0].DeployLocation != filepath.Join("services", "api", "public", "fonts") {
+		t.Errorf("Expected rebased asset deploy location, got %q", config.Assets[0].DeployLocation)
+	}
+
+	t.Run("InvalidWorkspace", func(t *testing.T) {
+		write("broken/artifacts.json", "invalid json")
+		write("with-broken.json", `{"root_directory": "__DIR__", "workspaces": ["broken"]}`)
+
+		_, err := ReadArtifactsJson(filepath.Join(root, "with-broken.json"))
+		if err == nil {
+			t.Fatalf("ReadArtifactsJson did not fail for an invalid workspace")
+		}
+	})
+}